@@ -32,7 +32,9 @@ func InitCacheProxy(cachesConfig map[string]cachingenv.CacheConnectorConfig) boo
 			if err != nil {
 				logger.Error.Printf("connect %s %s failed with error:%v", cnf.Driver, rc.GetConnectionString(), err)
 			}
-			if rc != nil {
+			if rc != nil && cnf.LocalCache.MaxEntries > 0 {
+				cachesessions[name] = NewTieredCache(rc, cnf.LocalCache)
+			} else if rc != nil {
 				cachesessions[name] = rc
 			}
 		} else if cachingenv.CachingDriverMemcache == cnf.Driver {
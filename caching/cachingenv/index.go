@@ -8,10 +8,18 @@ const (
 
 // CacheConnectorConfig connector config
 type CacheConnectorConfig struct {
-	Driver      string `yaml:"driver"`
-	Host        string `yaml:"host"`
-	Port        int    `yaml:"port"`
-	Password    string `yaml:"password"`
-	Index       int    `yaml:"db"`
-	ClusterMode bool   `yaml:"clusterMode"`
+	Driver      string           `yaml:"driver"`
+	Host        string           `yaml:"host"`
+	Port        int              `yaml:"port"`
+	Password    string           `yaml:"password"`
+	Index       int              `yaml:"db"`
+	ClusterMode bool             `yaml:"clusterMode"`
+	LocalCache  LocalCacheConfig `yaml:"localCache"`
+}
+
+// LocalCacheConfig in-memory first level of the redis cache, which is enabled if MaxEntries > 0
+type LocalCacheConfig struct {
+	MaxEntries int    `yaml:"maxEntries"`
+	TTL        int    `yaml:"ttl"`     // seconds, 60 if 0
+	Channel    string `yaml:"channel"` // pub/sub channel of invalidations, "golib:cache:invalidations" if empty
 }
@@ -2,6 +2,7 @@ package redisadapter
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/libpub/golib/caching/cachingenv"
@@ -21,6 +22,7 @@ type RedisCacheSession struct {
 	Name   string
 	Addr   string
 	ticker *time.Ticker
+	once   sync.Once
 }
 
 // NewRedisSession new session
@@ -73,18 +75,20 @@ func (s *RedisCacheSession) Initialized() bool {
 // OnConnected event
 func (s *RedisCacheSession) OnConnected(*redis.Conn) error {
 	logger.Info.Printf("redis %s connection:%s connected.", s.GetName(), s.GetConnectionString())
-	if s.ticker == nil {
-		go s.StartKeepalive()
-	}
+	go s.StartKeepalive()
 	return nil
 }
 
-// StartKeepalive keepalive
+// StartKeepalive keepalive, only the first call pings while the others return immediately
 func (s *RedisCacheSession) StartKeepalive() {
-	if s.ticker != nil {
+	started := false
+	s.once.Do(func() {
+		s.ticker = time.NewTicker(time.Second * RedisPingInterval)
+		started = true
+	})
+	if !started {
 		return
 	}
-	s.ticker = time.NewTicker(time.Second * RedisPingInterval)
 	for {
 		select {
 		case <-s.ticker.C:
@@ -117,3 +121,13 @@ func (s *RedisCacheSession) Set(key string, value []byte, expire time.Duration)
 	}
 	return true
 }
+
+// Delete value by key
+func (s *RedisCacheSession) Delete(key string) bool {
+	err := s.Client.Del(key).Err()
+	if nil != err {
+		logger.Error.Printf("delete %s failed with error:%v", key, err)
+		return false
+	}
+	return true
+}
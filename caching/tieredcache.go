@@ -0,0 +1,197 @@
+package caching
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/caching/cachingenv"
+	"github.com/libpub/golib/caching/redisadapter"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils"
+)
+
+// Constants of tiered cache
+const (
+	DefaultLocalCacheTTL       = 60 * time.Second
+	DefaultInvalidationChannel = "golib:cache:invalidations"
+)
+
+// TieredCache two-level cache of an in-memory LRU in front of a redis session, the keys written or deleted
+// by an instance are published to the invalidation channel, so that the other instances evict their local
+// copies. Invalidations missed while the subscription reconnecting are bounded by the local ttl.
+type TieredCache struct {
+	remote     *redisadapter.RedisCacheSession
+	maxEntries int
+	ttl        time.Duration
+	channel    string
+	instanceID string
+	pubsub     *redis.PubSub
+	m          sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List
+	generation uint64
+}
+
+type tieredCacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewTieredCache caches the values of remote in memory and subscribes the invalidations of the other instances
+func NewTieredCache(remote *redisadapter.RedisCacheSession, conf cachingenv.LocalCacheConfig) *TieredCache {
+	c := &TieredCache{
+		remote:     remote,
+		maxEntries: conf.MaxEntries,
+		ttl:        time.Duration(conf.TTL) * time.Second,
+		channel:    conf.Channel,
+		instanceID: utils.GenUUID(),
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+	if c.ttl <= 0 {
+		c.ttl = DefaultLocalCacheTTL
+	}
+	if "" == c.channel {
+		c.channel = DefaultInvalidationChannel
+	}
+	c.pubsub = remote.Client.Subscribe(c.channel)
+	go c.receiveInvalidations(c.pubsub.Channel())
+	return c
+}
+
+// GetName getter
+func (c *TieredCache) GetName() string {
+	return c.remote.GetName()
+}
+
+// GetConnectionString getter
+func (c *TieredCache) GetConnectionString() string {
+	return c.remote.GetConnectionString()
+}
+
+// Initialized getter
+func (c *TieredCache) Initialized() bool {
+	return c.remote.Initialized()
+}
+
+// Get value by key from the local cache, or from redis on missing
+func (c *TieredCache) Get(key string) ([]byte, error) {
+	c.m.Lock()
+	if value, ok := c.getLocal(key); ok {
+		c.m.Unlock()
+		return value, nil
+	}
+	generation := c.generation
+	c.m.Unlock()
+
+	value, err := c.remote.Get(key)
+	if nil != err {
+		return nil, err
+	}
+	c.m.Lock()
+	// the value read before an invalidation may be stale, leave it to the next get
+	if generation == c.generation {
+		c.setLocal(key, value, c.ttl)
+	}
+	c.m.Unlock()
+	return value, nil
+}
+
+// Set value by key to redis and the local cache, and invalidates the other instances
+func (c *TieredCache) Set(key string, value []byte, expire time.Duration) bool {
+	if !c.remote.Set(key, value, expire) {
+		c.evict(key)
+		return false
+	}
+	ttl := c.ttl
+	if expire > 0 && expire < ttl {
+		ttl = expire
+	}
+	c.m.Lock()
+	c.generation++
+	c.setLocal(key, value, ttl)
+	c.m.Unlock()
+	c.publish(key)
+	return true
+}
+
+// Delete value by key from redis and the local caches of all instances
+func (c *TieredCache) Delete(key string) bool {
+	c.evict(key)
+	if !c.remote.Delete(key) {
+		return false
+	}
+	c.publish(key)
+	return true
+}
+
+// Invalidate evicts the key from the local caches of all instances, and keeps the value in redis
+func (c *TieredCache) Invalidate(key string) {
+	c.evict(key)
+	c.publish(key)
+}
+
+// Close unsubscribes the invalidations
+func (c *TieredCache) Close() error {
+	return c.pubsub.Close()
+}
+
+func (c *TieredCache) publish(key string) {
+	if err := c.remote.Client.Publish(c.channel, c.instanceID+" "+key).Err(); nil != err {
+		logger.Error.Printf("publish invalidation of %s to %s failed with error:%v", key, c.channel, err)
+	}
+}
+
+func (c *TieredCache) receiveInvalidations(messages <-chan *redis.Message) {
+	for msg := range messages {
+		fields := strings.SplitN(msg.Payload, " ", 2)
+		if len(fields) != 2 || fields[0] == c.instanceID {
+			continue
+		}
+		c.evict(fields[1])
+	}
+}
+
+func (c *TieredCache) evict(key string) {
+	c.m.Lock()
+	c.generation++
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.m.Unlock()
+}
+
+func (c *TieredCache) getLocal(key string) ([]byte, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*tieredCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return append([]byte(nil), entry.value...), true
+}
+
+func (c *TieredCache) setLocal(key string, value []byte, ttl time.Duration) {
+	entry := &tieredCacheEntry{key: key, value: append([]byte(nil), value...), expires: time.Now().Add(ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*tieredCacheEntry).key)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/libpub/golib/definations"
 	"github.com/libpub/golib/logger"
 	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/validator"
 	"github.com/libpub/golib/yamlutils"
 )
 
@@ -123,3 +124,10 @@ func (n Server) FormatCallbackURL(uri string) string {
 	}
 	return n.DeployAddr + uri
 }
+
+// ValidateEnv validates the env loaded by Init with the validate tags and the Validate methods of the options
+// such as definations.TLSOptions, so that the misconfigured tls, proxies and db options fail on starting
+// instead of while connecting, see validator.ValidateOptions
+func ValidateEnv() error {
+	return validator.ValidateOptions(&env)
+}
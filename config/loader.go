@@ -0,0 +1,323 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/libpub/golib/validator"
+	"github.com/libpub/golib/validator/validates"
+	"gopkg.in/yaml.v2"
+)
+
+// LoaderOption options of Load
+type LoaderOption func(*loaderOptions)
+
+type loaderOptions struct {
+	files           []configFile
+	envPrefix       string
+	lookupEnv       func(string) (string, bool)
+	secretResolvers map[string]SecretResolver
+	skipSecrets     bool
+	validateOptions bool
+}
+
+type configFile struct {
+	path     string
+	optional bool
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// WithFiles the configuration files loaded in order, the files must exist
+func WithFiles(filePaths ...string) LoaderOption {
+	return func(o *loaderOptions) {
+		for _, filePath := range filePaths {
+			o.files = append(o.files, configFile{path: filePath})
+		}
+	}
+}
+
+// WithOptionalFiles the configuration files loaded in order, the files not exist are skipped such as local.app.yaml
+func WithOptionalFiles(filePaths ...string) LoaderOption {
+	return func(o *loaderOptions) {
+		for _, filePath := range filePaths {
+			o.files = append(o.files, configFile{path: filePath, optional: true})
+		}
+	}
+}
+
+// WithEnvPrefix the prefix of environment variables overriding the fields such as APP,
+// the fields are overridden by environment variables only if the prefix is set
+func WithEnvPrefix(prefix string) LoaderOption {
+	return func(o *loaderOptions) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithEnvLookup the lookup of environment variables, os.LookupEnv by default
+func WithEnvLookup(lookup func(string) (string, bool)) LoaderOption {
+	return func(o *loaderOptions) {
+		o.lookupEnv = lookup
+	}
+}
+
+// WithOptionsValidation validates all the nested fields and the Validate methods of options such as
+// definations.TLSOptions after loading, see validator.ValidateOptions
+func WithOptionsValidation() LoaderOption {
+	return func(o *loaderOptions) {
+		o.validateOptions = true
+	}
+}
+
+// Load loads the configuration into the struct pointer v:
+//  1. the files are loaded in order, the later ones overlay the earlier ones, the format is json for .json files and yaml for others
+//  2. the environment variables named as {prefix}_{PATH}_{TO}_{FIELD} override the fields, the path segment is the env tag,
+//     yaml tag, json tag or field name in upper snake case such as APP_SERVER_TLS_CERT_FILE, the map values are overridden
+//     by the existing keys, the env:"-" fields are skipped
+//  3. the empty fields are filled by default tags
+//  4. the string values of secret references such as "env:DB_PASSWORD" or "file:/run/secrets/tls.key" are resolved
+//     by the resolvers of scheme, see RegisterSecretResolver
+//  5. the struct is validated by validate tags of validator, or by validator.ValidateOptions WithOptionsValidation
+func Load(v interface{}, options ...LoaderOption) error {
+	opts := loaderOptions{lookupEnv: os.LookupEnv}
+	for _, option := range options {
+		option(&opts)
+	}
+	value := reflect.ValueOf(v)
+	if !value.IsValid() || reflect.Ptr != value.Kind() || value.IsNil() || reflect.Struct != value.Elem().Kind() {
+		return fmt.Errorf("load configuration into %T, a struct pointer is required", v)
+	}
+	for _, file := range opts.files {
+		if err := LoadFile(file.path, v); nil != err {
+			if file.optional && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	prefix := ""
+	if "" != opts.envPrefix {
+		prefix = toEnvName(opts.envPrefix)
+	}
+	if _, err := overlayStruct(value.Elem(), prefix, &opts); nil != err {
+		return err
+	}
+	if !opts.skipSecrets {
+		if err := resolveSecrets(value.Elem(), "", opts.secretResolvers); nil != err {
+			return err
+		}
+	}
+	if opts.validateOptions {
+		return validator.ValidateOptions(v)
+	}
+	return validator.Validate(v)
+}
+
+// LoadFile loads the json or yaml file into v by the extension
+func LoadFile(filePath string, v interface{}) error {
+	content, err := ioutil.ReadFile(filePath)
+	if nil != err {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		err = json.Unmarshal(content, v)
+	default:
+		err = yaml.Unmarshal(content, v)
+	}
+	if nil != err {
+		return fmt.Errorf("parse configure file %s failed with error:%v", filePath, err)
+	}
+	return nil
+}
+
+// overlayStruct overrides the fields of struct by environment variables and fills the default values,
+// returns if any field overridden
+func overlayStruct(value reflect.Value, prefix string, opts *loaderOptions) (bool, error) {
+	t := value.Type()
+	overridden := false
+	for i := 0; i < value.NumField(); i++ {
+		ft := t.Field(i)
+		f := value.Field(i)
+		if "" != ft.PkgPath || !f.CanSet() {
+			continue
+		}
+		envTag := ft.Tag.Get("env")
+		if "-" == envTag {
+			continue
+		}
+		name := prefix
+		if !ft.Anonymous || "" != envTag {
+			name = joinEnvName(prefix, fieldEnvName(ft))
+		}
+		ok, err := overlayValue(f, name, opts)
+		if nil != err {
+			return overridden, err
+		}
+		overridden = overridden || ok
+		if defaultText := ft.Tag.Get("default"); "" != defaultText && isScalarKind(f.Kind()) {
+			if err = validates.ValidateDefault(f, defaultText, ft.Name); nil != err {
+				return overridden, err
+			}
+		}
+	}
+	return overridden, nil
+}
+
+func overlayValue(f reflect.Value, name string, opts *loaderOptions) (bool, error) {
+	switch f.Kind() {
+	case reflect.Struct:
+		return overlayStruct(f, name, opts)
+	case reflect.Ptr:
+		if reflect.Struct != f.Type().Elem().Kind() {
+			break
+		}
+		if !f.IsNil() {
+			return overlayStruct(f.Elem(), name, opts)
+		}
+		// the nil struct pointer is set only if any field overridden
+		tmp := reflect.New(f.Type().Elem())
+		overridden, err := overlayStruct(tmp.Elem(), name, opts)
+		if overridden && nil == err {
+			f.Set(tmp)
+		}
+		return overridden, err
+	case reflect.Map:
+		if f.IsNil() || reflect.String != f.Type().Key().Kind() {
+			return false, nil
+		}
+		overridden := false
+		for _, key := range f.MapKeys() {
+			item := reflect.New(f.Type().Elem()).Elem()
+			item.Set(f.MapIndex(key))
+			ok, err := overlayValue(item, joinEnvName(name, toEnvName(key.String())), opts)
+			if nil != err {
+				return overridden, err
+			}
+			if ok {
+				overridden = true
+				f.SetMapIndex(key, item)
+			}
+		}
+		return overridden, nil
+	}
+	if "" == name || "" == opts.envPrefix {
+		return false, nil
+	}
+	text, ok := opts.lookupEnv(name)
+	if !ok {
+		return false, nil
+	}
+	if err := setFieldText(f, text); nil != err {
+		return false, fmt.Errorf("config environment variable %s=%s is invalid:%v", name, text, err)
+	}
+	return true, nil
+}
+
+func setFieldText(f reflect.Value, text string) error {
+	if f.Type() == durationType {
+		d, err := time.ParseDuration(text)
+		if nil != err {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if nil != err {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, f.Type().Bits())
+		if nil != err {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, f.Type().Bits())
+		if nil != err {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, f.Type().Bits())
+		if nil != err {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		if reflect.String != f.Type().Elem().Kind() {
+			return fmt.Errorf("unsupported type %s", f.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); "" != item {
+				items = append(items, item)
+			}
+		}
+		f.Set(reflect.ValueOf(items).Convert(f.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Type())
+	}
+	return nil
+}
+
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// fieldEnvName the env name segment of field by env, yaml, json tag or field name
+func fieldEnvName(ft reflect.StructField) string {
+	for _, tagName := range []string{"env", "yaml", "json"} {
+		if tag := strings.Split(ft.Tag.Get(tagName), ",")[0]; "" != tag && "-" != tag {
+			return toEnvName(tag)
+		}
+	}
+	return toEnvName(ft.Name)
+}
+
+func joinEnvName(prefix string, name string) string {
+	if "" == prefix {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// toEnvName converts the camel case name into upper snake case such as maxInFlight to MAX_IN_FLIGHT
+func toEnvName(name string) string {
+	rs := []rune(name)
+	result := make([]rune, 0, len(rs)+4)
+	for i, r := range rs {
+		if '-' == r || '.' == r || ' ' == r {
+			r = '_'
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1])) {
+				result = append(result, '_')
+			}
+		}
+		result = append(result, unicode.ToUpper(r))
+	}
+	return string(result)
+}
@@ -0,0 +1,110 @@
+package results
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/libpub/golib/errors"
+)
+
+// Pagination of the listed data
+type Pagination struct {
+	Page       int    `json:"page,omitempty" comment:"页码，从1开始"`
+	PageSize   int    `json:"pageSize,omitempty" comment:"每页条数"`
+	Total      int64  `json:"total" comment:"总条数"`
+	NextCursor string `json:"nextCursor,omitempty" comment:"下一页游标"`
+}
+
+// CodeError error of the failed result code, it matches its category by errors.Is
+type CodeError struct {
+	Code     int
+	Message  string
+	Category errors.Category
+}
+
+// Error message
+func (e *CodeError) Error() string {
+	return fmt.Sprintf("code:%d message:%s", e.Code, e.Message)
+}
+
+// Unwrap the category
+func (e *CodeError) Unwrap() error {
+	return e.Category
+}
+
+var (
+	codeCategories = map[int]errors.Category{
+		Unauthorized:       errors.Unauthorized,
+		Forbidden:          errors.Unauthorized,
+		NotLogin:           errors.Unauthorized,
+		RequestTimeout:     errors.Timeout,
+		Timeout:            errors.Timeout,
+		Conflict:           errors.Conflict,
+		InnerError:         errors.Upstream5xx,
+		NotImplemented:     errors.Upstream5xx,
+		ServiceUnavailable: errors.Upstream5xx,
+		429:                errors.TooManyRequests,
+	}
+	codeCategoriesMutex sync.RWMutex
+)
+
+// RegisterCodeCategory maps the result code to category, the codes not registered are errors.Unknown
+func RegisterCodeCategory(code int, category errors.Category) {
+	codeCategoriesMutex.Lock()
+	codeCategories[code] = category
+	codeCategoriesMutex.Unlock()
+}
+
+// CategoryOfCode the category of result code
+func CategoryOfCode(code int) errors.Category {
+	codeCategoriesMutex.RLock()
+	defer codeCategoriesMutex.RUnlock()
+	if category, ok := codeCategories[code]; ok {
+		return category
+	}
+	return errors.Unknown
+}
+
+// ErrorOf the error of result code, nil if code is OK
+func ErrorOf(code int, message string) error {
+	if OK == code {
+		return nil
+	}
+	return &CodeError{Code: code, Message: message, Category: CategoryOfCode(code)}
+}
+
+// CodeOf the result code of err, the code of CodeError is returned, or the http status mapped from the category
+// of err, OK if err nil
+func CodeOf(err error) int {
+	if nil == err {
+		return OK
+	}
+	var e *CodeError
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return errors.HTTPStatus(err)
+}
+
+// NewOKResult succeeded result of data
+func NewOKResult(data interface{}, pagination *Pagination) ResultObject {
+	return ResultObject{
+		Code:       OK,
+		Message:    "OK",
+		Data:       data,
+		Pagination: pagination,
+	}
+}
+
+// NewErrorResult failed result of err, the code is CodeOf(err)
+func NewErrorResult(err error) ResultObject {
+	return ResultObject{
+		Code:    CodeOf(err),
+		Message: err.Error(),
+	}
+}
+
+// Err error of the result code, nil if the code is OK
+func (r *ResultObject) Err() error {
+	return ErrorOf(r.Code, r.Message)
+}
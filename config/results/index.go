@@ -8,10 +8,11 @@ import (
 
 // ResultObject result
 type ResultObject struct {
-	Code      int         `json:"code" validate:"required" comment:"结果编码"`
-	Message   string      `json:"message" validate:"required" comment:"结果描述"`
-	Data      interface{} `json:"data,omitempty" validate:"optional" comment:"响应结果"`
-	RequestSn interface{} `json:"requestSn,omitempty" validate:"optional" comment:"请求序列号"`
+	Code       int         `json:"code" validate:"required" comment:"结果编码"`
+	Message    string      `json:"message" validate:"required" comment:"结果描述"`
+	Data       interface{} `json:"data,omitempty" validate:"optional" comment:"响应结果"`
+	RequestSn  interface{} `json:"requestSn,omitempty" validate:"optional" comment:"请求序列号"`
+	Pagination *Pagination `json:"pagination,omitempty" validate:"optional" comment:"分页信息"`
 }
 
 // NewResultObject result
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Secret reference schemes, the value such as "env:DB_PASSWORD" is resolved by the resolver of scheme
+const (
+	SecretSchemeEnv    = "env"
+	SecretSchemeFile   = "file"
+	SecretSchemeVault  = "vault"
+	SecretSchemeAWSSM  = "aws-sm"
+	secretRefSeparator = ":"
+)
+
+// SecretResolver resolves the secret reference without scheme prefix
+type SecretResolver interface {
+	ResolveSecret(ref string) (string, error)
+}
+
+// SecretResolverFunc function as SecretResolver
+type SecretResolverFunc func(ref string) (string, error)
+
+// ResolveSecret calls the function
+func (f SecretResolverFunc) ResolveSecret(ref string) (string, error) {
+	return f(ref)
+}
+
+var (
+	secretResolvers = map[string]SecretResolver{
+		SecretSchemeEnv:  SecretResolverFunc(resolveEnvSecret),
+		SecretSchemeFile: SecretResolverFunc(resolveFileSecret),
+	}
+	secretResolversMutex = sync.RWMutex{}
+)
+
+// RegisterSecretResolver registers the resolver of scheme for all loadings, the env and file schemes are registered
+// by default, the vault and aws-sm resolvers should be registered with their connection options
+func RegisterSecretResolver(scheme string, resolver SecretResolver) {
+	secretResolversMutex.Lock()
+	if nil == resolver {
+		delete(secretResolvers, scheme)
+	} else {
+		secretResolvers[scheme] = resolver
+	}
+	secretResolversMutex.Unlock()
+}
+
+// WithSecretResolver the resolver of scheme for the loading only
+func WithSecretResolver(scheme string, resolver SecretResolver) LoaderOption {
+	return func(o *loaderOptions) {
+		if nil == o.secretResolvers {
+			o.secretResolvers = map[string]SecretResolver{}
+		}
+		o.secretResolvers[scheme] = resolver
+	}
+}
+
+// WithoutSecrets disables resolving the secret references
+func WithoutSecrets() LoaderOption {
+	return func(o *loaderOptions) {
+		o.skipSecrets = true
+	}
+}
+
+// ResolveSecret resolves the value if it is a secret reference of registered scheme, otherwise returns the value
+func ResolveSecret(value string) (string, error) {
+	return resolveSecretValue(value, nil)
+}
+
+func resolveSecretValue(value string, resolvers map[string]SecretResolver) (string, error) {
+	pos := strings.Index(value, secretRefSeparator)
+	if pos <= 0 {
+		return value, nil
+	}
+	scheme := value[:pos]
+	resolver := resolvers[scheme]
+	if nil == resolver {
+		secretResolversMutex.RLock()
+		resolver = secretResolvers[scheme]
+		secretResolversMutex.RUnlock()
+	}
+	if nil == resolver {
+		return value, nil
+	}
+	return resolver.ResolveSecret(value[pos+1:])
+}
+
+// resolveSecrets replaces the secret references of string fields, map values and slice items
+func resolveSecrets(value reflect.Value, path string, resolvers map[string]SecretResolver) error {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			return resolveSecrets(value.Elem(), path, resolvers)
+		}
+	case reflect.Struct:
+		t := value.Type()
+		for i := 0; i < value.NumField(); i++ {
+			if "" != t.Field(i).PkgPath || !value.Field(i).CanSet() {
+				continue
+			}
+			if err := resolveSecrets(value.Field(i), joinSectionPath(path, t.Field(i).Name), resolvers); nil != err {
+				return err
+			}
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		for _, key := range value.MapKeys() {
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(key))
+			if err := resolveSecrets(item, joinSectionPath(path, fmt.Sprint(key.Interface())), resolvers); nil != err {
+				return err
+			}
+			value.SetMapIndex(key, item)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if err := resolveSecrets(value.Index(i), fmt.Sprintf("%s[%d]", path, i), resolvers); nil != err {
+				return err
+			}
+		}
+	case reflect.String:
+		if !value.CanSet() {
+			return nil
+		}
+		resolved, err := resolveSecretValue(value.String(), resolvers)
+		if nil != err {
+			// the reference is reported without the secret
+			return fmt.Errorf("resolve secret of %s failed with error:%v", path, err)
+		}
+		value.SetString(resolved)
+	}
+	return nil
+}
+
+func joinSectionPath(path string, name string) string {
+	if "" == path {
+		return name
+	}
+	return path + "." + name
+}
+
+func resolveEnvSecret(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", ref)
+	}
+	return value, nil
+}
+
+// resolveFileSecret the content of file without the trailing new line, such as the mounted kubernetes secrets
+func resolveFileSecret(ref string) (string, error) {
+	content, err := ioutil.ReadFile(ref)
+	if nil != err {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// secretField the field of json secret such as {"username":"u","password":"p"} by key, the whole text if key empty
+func secretField(text string, key string) (string, error) {
+	if "" == key {
+		return text, nil
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(text), &fields); nil != err {
+		return "", fmt.Errorf("parse secret as json failed with error:%v", err)
+	}
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret key %s not found", key)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/utils/cryptoes"
+)
+
+// VaultSecretResolver resolves the references such as "secret/data/app#password" by vault http api,
+// both kv version 1 and 2 are supported, the whole data is returned as json if no key
+type VaultSecretResolver struct {
+	Address   string
+	Token     string
+	Namespace string
+	Timeout   int // seconds
+}
+
+// NewVaultSecretResolver new vault resolver, the VAULT_ADDR and VAULT_TOKEN environment variables are used if empty
+func NewVaultSecretResolver(address string, token string) *VaultSecretResolver {
+	if "" == address {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if "" == token {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	return &VaultSecretResolver{Address: strings.TrimRight(address, "/"), Token: token, Namespace: os.Getenv("VAULT_NAMESPACE")}
+}
+
+// ResolveSecret reads the secret of path#key
+func (r *VaultSecretResolver) ResolveSecret(ref string) (string, error) {
+	if "" == r.Address {
+		return "", errors.New("vault address not configured")
+	}
+	secretPath, key := splitSecretKey(ref)
+	options := []httpclient.ClientOption{httpclient.WithHTTPHeader("X-Vault-Token", r.Token)}
+	if "" != r.Namespace {
+		options = append(options, httpclient.WithHTTPHeader("X-Vault-Namespace", r.Namespace))
+	}
+	if r.Timeout > 0 {
+		options = append(options, httpclient.WithTimeout(r.Timeout))
+	}
+	resp, err := httpclient.HTTPQuery("GET", r.Address+"/v1/"+strings.TrimLeft(secretPath, "/"), nil, options...)
+	if nil != err {
+		return "", fmt.Errorf("read vault secret %s failed with error:%v", secretPath, err)
+	}
+	result := struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}{}
+	if err = json.Unmarshal(resp, &result); nil != err {
+		return "", err
+	}
+	data := result.Data
+	if _, ok := data["metadata"]; ok {
+		// kv version 2 wraps the data
+		inner := map[string]json.RawMessage{}
+		if err = json.Unmarshal(data["data"], &inner); nil != err {
+			return "", err
+		}
+		data = inner
+	}
+	text, err := json.Marshal(data)
+	if nil != err {
+		return "", err
+	}
+	return secretField(string(text), key)
+}
+
+// AWSSecretsManagerResolver resolves the references such as "prod/db#password" or the secret arn
+// by aws secrets manager api, the whole secret string is returned if no key
+type AWSSecretsManagerResolver struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Endpoint        string           // https://secretsmanager.{region}.amazonaws.com by default
+	Timeout         int              // seconds
+	Now             func() time.Time // time.Now if nil
+}
+
+// NewAWSSecretsManagerResolver new aws secrets manager resolver with the credentials of AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, the AWS_REGION is used if region empty
+func NewAWSSecretsManagerResolver(region string) *AWSSecretsManagerResolver {
+	if "" == region {
+		region = os.Getenv("AWS_REGION")
+	}
+	if "" == region {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return &AWSSecretsManagerResolver{
+		Region:          region,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// ResolveSecret gets the secret value of id#key
+func (r *AWSSecretsManagerResolver) ResolveSecret(ref string) (string, error) {
+	if "" == r.Region || "" == r.AccessKeyID || "" == r.SecretAccessKey {
+		return "", errors.New("aws secrets manager region or credentials not configured")
+	}
+	secretID, key := splitSecretKey(ref)
+	endpoint := r.Endpoint
+	if "" == endpoint {
+		endpoint = "https://secretsmanager." + r.Region + ".amazonaws.com"
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if nil != err {
+		return "", err
+	}
+	options := []httpclient.ClientOption{
+		httpclient.WithHTTPHeader("Content-Type", "application/x-amz-json-1.1"),
+		httpclient.WithHTTPHeader("X-Amz-Target", "secretsmanager.GetSecretValue"),
+		httpclient.WithRequestInterceptor(func(req *http.Request, body []byte) error {
+			now := time.Now
+			if nil != r.Now {
+				now = r.Now
+			}
+			signAWSRequestV4(req, body, r.AccessKeyID, r.SecretAccessKey, r.SessionToken, r.Region, "secretsmanager", now())
+			return nil
+		}),
+	}
+	if r.Timeout > 0 {
+		options = append(options, httpclient.WithTimeout(r.Timeout))
+	}
+	resp, err := httpclient.HTTPQuery("POST", strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(body), options...)
+	if nil != err {
+		return "", fmt.Errorf("get aws secret %s failed with error:%v", secretID, err)
+	}
+	result := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+	if err = json.Unmarshal(resp, &result); nil != err {
+		return "", err
+	}
+	return secretField(result.SecretString, key)
+}
+
+// splitSecretKey splits the reference into path and key by the last #
+func splitSecretKey(ref string) (string, string) {
+	if pos := strings.LastIndex(ref, "#"); pos >= 0 {
+		return ref[:pos], ref[pos+1:]
+	}
+	return ref, ""
+}
+
+// signAWSRequestV4 signs the request by aws signature version 4 with the host and headers of request
+func signAWSRequestV4(req *http.Request, body []byte, accessKey, secretKey, sessionToken, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if "" != sessionToken {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	host := req.Host
+	if "" == host {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL.EscapedPath()),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signingKey := cryptoes.HMACSHA256([]byte(date), []byte("AWS4"+secretKey))
+	signingKey = cryptoes.HMACSHA256([]byte(region), signingKey)
+	signingKey = cryptoes.HMACSHA256([]byte(service), signingKey)
+	signingKey = cryptoes.HMACSHA256([]byte("aws4_request"), signingKey)
+	signature := cryptoes.HMACSHA256Hex([]byte(stringToSign), signingKey)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func awsCanonicalURI(path string) string {
+	if "" == path {
+		return "/"
+	}
+	return path
+}
+
+func awsCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode encodes all the characters except the unreserved ones A-Z a-z 0-9 - _ . ~
+func awsURIEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+)
+
+// Constants of watcher
+const (
+	DefaultWatchInterval = 2 * time.Second
+)
+
+// ChangeCallback called with the old and new value of section when the section changed
+type ChangeCallback func(section string, oldValue, newValue interface{})
+
+// Watcher reloads the configuration by the loader options when the files changed and notifies the subscribers
+// of changed sections, the loaded configuration is never modified so that it could be read without locking,
+// the latest one should be got by Current
+type Watcher struct {
+	options     []LoaderOption
+	files       []configFile
+	current     interface{}
+	fileStates  map[string]fileState
+	subscribers []subscriber
+	stop        chan struct{}
+	m           sync.RWMutex
+}
+
+type subscriber struct {
+	section  string
+	callback ChangeCallback
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// NewWatcher loads the configuration into the struct pointer v by Load and watches the files of options
+func NewWatcher(v interface{}, options ...LoaderOption) (*Watcher, error) {
+	if err := Load(v, options...); nil != err {
+		return nil, err
+	}
+	opts := loaderOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	w := &Watcher{
+		options: options,
+		files:   opts.files,
+		current: v,
+	}
+	w.fileStates = w.statFiles()
+	return w, nil
+}
+
+// Current the latest loaded configuration, the struct pointer of the same type as NewWatcher
+func (w *Watcher) Current() interface{} {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return w.current
+}
+
+// OnChange subscribes the changes of section, the section is the dotted path of fields by yaml tag, json tag
+// or field name such as "kafka" or "db.main", the empty section subscribes any change
+func (w *Watcher) OnChange(section string, callback ChangeCallback) {
+	if nil == callback {
+		return
+	}
+	w.m.Lock()
+	w.subscribers = append(w.subscribers, subscriber{section: section, callback: callback})
+	w.m.Unlock()
+}
+
+// Start checks the files at every interval and reloads if changed until Stop
+func (w *Watcher) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w.m.Lock()
+	defer w.m.Unlock()
+	if nil != w.stop {
+		return
+	}
+	w.stop = make(chan struct{})
+	go w.watch(interval, w.stop)
+}
+
+// Stop stops watching the files
+func (w *Watcher) Stop() {
+	w.m.Lock()
+	if nil != w.stop {
+		close(w.stop)
+		w.stop = nil
+	}
+	w.m.Unlock()
+}
+
+func (w *Watcher) watch(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			states := w.statFiles()
+			w.m.RLock()
+			changed := !reflect.DeepEqual(states, w.fileStates)
+			w.m.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := w.Reload(); nil != err {
+				logger.Error.Printf("reload configuration failed with error:%v, keeping the current configuration", err)
+			}
+			w.m.Lock()
+			// the invalid files are not reloaded again until modified
+			w.fileStates = states
+			w.m.Unlock()
+		}
+	}
+}
+
+// Reload loads the configuration again and notifies the subscribers of changed sections,
+// the current configuration is kept if loading failed
+func (w *Watcher) Reload() error {
+	w.m.RLock()
+	old := w.current
+	w.m.RUnlock()
+	if nil == old {
+		return errors.New("reload configuration without loaded one")
+	}
+	v := reflect.New(reflect.TypeOf(old).Elem()).Interface()
+	if err := Load(v, w.options...); nil != err {
+		return err
+	}
+	w.m.Lock()
+	w.current = v
+	subscribers := append([]subscriber{}, w.subscribers...)
+	w.m.Unlock()
+
+	for _, sub := range subscribers {
+		oldValue, _ := SectionValue(old, sub.section)
+		newValue, _ := SectionValue(v, sub.section)
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		logger.Info.Printf("configuration section:%s changed", sub.section)
+		sub.callback(sub.section, oldValue, newValue)
+	}
+	return nil
+}
+
+func (w *Watcher) statFiles() map[string]fileState {
+	states := map[string]fileState{}
+	for _, file := range w.files {
+		info, err := os.Stat(file.path)
+		if nil != err {
+			states[file.path] = fileState{}
+			continue
+		}
+		states[file.path] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
+
+// SectionValue the value of dotted section path in configuration v, the path segments are matched with
+// yaml tag, json tag or field name case insensitively and the keys of maps, returns v for empty section
+func SectionValue(v interface{}, section string) (interface{}, bool) {
+	value := reflect.ValueOf(v)
+	if "" == section {
+		return v, true
+	}
+	for _, segment := range strings.Split(section, ".") {
+		for reflect.Ptr == value.Kind() || reflect.Interface == value.Kind() {
+			if value.IsNil() {
+				return nil, false
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			value = sectionField(value, segment)
+		case reflect.Map:
+			if reflect.String != value.Type().Key().Kind() {
+				return nil, false
+			}
+			value = value.MapIndex(reflect.ValueOf(segment).Convert(value.Type().Key()))
+		default:
+			return nil, false
+		}
+		if !value.IsValid() {
+			return nil, false
+		}
+	}
+	if !value.CanInterface() {
+		return nil, false
+	}
+	return value.Interface(), true
+}
+
+func sectionField(value reflect.Value, segment string) reflect.Value {
+	t := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		ft := t.Field(i)
+		if "" != ft.PkgPath {
+			continue
+		}
+		for _, tagName := range []string{"yaml", "json"} {
+			if tag := strings.Split(ft.Tag.Get(tagName), ",")[0]; "" != tag && strings.EqualFold(tag, segment) {
+				return value.Field(i)
+			}
+		}
+		if strings.EqualFold(ft.Name, segment) {
+			return value.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
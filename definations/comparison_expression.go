@@ -0,0 +1,371 @@
+package definations
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type comparisonTokenKind int
+
+const (
+	comparisonTokenEOF = comparisonTokenKind(iota)
+	comparisonTokenIdent
+	comparisonTokenNumber
+	comparisonTokenString
+	comparisonTokenSymbol
+)
+
+type comparisonToken struct {
+	kind comparisonTokenKind
+	text string
+	pos  int
+}
+
+type comparisonParser struct {
+	tokens []comparisonToken
+	pos    int
+}
+
+// ParseComparison parses the compact expression into comparison such as
+//
+//	status in ('active', 'pending') and (age >= 18 or vip = true) and not name ~ '^test'
+//
+// the operators are =, ==, !=, <>, <, <=, >, >=, in, not in, contains, ~ or matches (regex), between a and b
+// and not between a and b, the conditions are grouped by and(&&), or(||), not(!) and parentheses,
+// the values are quoted strings, numbers, true, false or lists of them in parentheses or brackets
+func ParseComparison(expression string) (*ComparisonObject, error) {
+	tokens, err := tokenizeComparison(expression)
+	if nil != err {
+		return nil, err
+	}
+	p := &comparisonParser{tokens: tokens}
+	c, err := p.parseOr()
+	if nil != err {
+		return nil, err
+	}
+	if t := p.peek(); comparisonTokenEOF != t.kind {
+		return nil, p.errorf(t, "unexpected %s", t.text)
+	}
+	if err := c.Validate(); nil != err {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *comparisonParser) parseOr() (*ComparisonObject, error) {
+	items := []*ComparisonObject{}
+	for {
+		item, err := p.parseAnd()
+		if nil != err {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.accept("or", "||") {
+			break
+		}
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	c := NewComparisonObject()
+	for _, item := range items {
+		if meta, ok := item.singleMeta(); ok {
+			c.ors = append(c.ors, meta)
+		} else {
+			c.OrGroup(item)
+		}
+	}
+	return c, nil
+}
+
+func (p *comparisonParser) parseAnd() (*ComparisonObject, error) {
+	items := []*ComparisonObject{}
+	for {
+		item, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.accept("and", "&&") {
+			break
+		}
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	c := NewComparisonObject()
+	for _, item := range items {
+		if meta, ok := item.singleMeta(); ok {
+			c.ands = append(c.ands, meta)
+		} else {
+			c.AndGroup(item)
+		}
+	}
+	return c, nil
+}
+
+func (p *comparisonParser) parseUnary() (*ComparisonObject, error) {
+	if p.accept("not", "!") {
+		c, err := p.parseUnary()
+		if nil != err {
+			return nil, err
+		}
+		return Not(c), nil
+	}
+	if p.accept("(") {
+		c, err := p.parseOr()
+		if nil != err {
+			return nil, err
+		}
+		if t := p.next(); ")" != t.text {
+			return nil, p.errorf(t, "expecting ) but got %s", t.text)
+		}
+		return c, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *comparisonParser) parseCondition() (*ComparisonObject, error) {
+	t := p.next()
+	if comparisonTokenIdent != t.kind {
+		return nil, p.errorf(t, "expecting field but got %s", t.text)
+	}
+	field := t.text
+	op := p.next()
+	var compareType CompareType
+	switch strings.ToLower(op.text) {
+	case "=", "==":
+		compareType = CompareEquals
+	case "!=", "<>":
+		compareType = CompareNotEquals
+	case "<":
+		compareType = CompareLessThan
+	case "<=":
+		compareType = CompareLessEquals
+	case ">":
+		compareType = CompareGreaterThan
+	case ">=":
+		compareType = CompareGreaterEquals
+	case "~", "matches":
+		compareType = CompareRegex
+	case "contains":
+		compareType = CompareIncludes
+	case "in":
+		compareType = CompareInArray
+	case "between":
+		compareType = CompareBetween
+	case "not":
+		switch next := p.next(); strings.ToLower(next.text) {
+		case "in":
+			compareType = CompareNotInArray
+		case "between":
+			compareType = CompareNotBetween
+		default:
+			return nil, p.errorf(next, "expecting in or between but got %s", next.text)
+		}
+	default:
+		return nil, p.errorf(op, "unknown operator %s of field %s", op.text, field)
+	}
+
+	var value interface{}
+	var err error
+	switch compareType {
+	case CompareInArray, CompareNotInArray:
+		value, err = p.parseList()
+	case CompareBetween, CompareNotBetween:
+		var low, high interface{}
+		if low, err = p.parseValue(); nil != err {
+			return nil, err
+		}
+		if !p.accept("and", "&&") {
+			return nil, p.errorf(p.peek(), "expecting and of between")
+		}
+		if high, err = p.parseValue(); nil != err {
+			return nil, err
+		}
+		value = typedComparisonList([]interface{}{low, high})
+	default:
+		value, err = p.parseValue()
+	}
+	if nil != err {
+		return nil, err
+	}
+	cond := NewComparisonObject().And(compareType, field, value)
+	cond.ands[0].literal = true
+	return cond, nil
+}
+
+func (p *comparisonParser) parseList() (interface{}, error) {
+	open := p.next()
+	closing := ""
+	switch open.text {
+	case "(":
+		closing = ")"
+	case "[":
+		closing = "]"
+	default:
+		return nil, p.errorf(open, "expecting list but got %s", open.text)
+	}
+	values := []interface{}{}
+	for !p.accept(closing) {
+		if len(values) > 0 && !p.accept(",") {
+			return nil, p.errorf(p.peek(), "expecting , or %s", closing)
+		}
+		value, err := p.parseValue()
+		if nil != err {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return typedComparisonList(values), nil
+}
+
+func (p *comparisonParser) parseValue() (interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case comparisonTokenString:
+		return t.text, nil
+	case comparisonTokenNumber:
+		if n, err := strconv.ParseInt(t.text, 10, 64); nil == err {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if nil != err {
+			return nil, p.errorf(t, "invalid number %s", t.text)
+		}
+		return f, nil
+	case comparisonTokenIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, p.errorf(t, "expecting value but got %s", t.text)
+}
+
+// typedComparisonList the []string, []int64 or []float64 of same type values for comparing
+func typedComparisonList(values []interface{}) interface{} {
+	strs, ints, floats := []string{}, []int64{}, []float64{}
+	for _, v := range values {
+		switch n := v.(type) {
+		case string:
+			strs = append(strs, n)
+		case int64:
+			ints = append(ints, n)
+			floats = append(floats, float64(n))
+		case float64:
+			floats = append(floats, n)
+		}
+	}
+	switch len(values) {
+	case len(strs):
+		return strs
+	case len(ints):
+		return ints
+	case len(floats):
+		return floats
+	}
+	return values
+}
+
+// singleMeta the only condition of comparison which could be merged into the parent
+func (c *ComparisonObject) singleMeta() (comparisonMeta, bool) {
+	if c.negated || len(c.ands) != 1 || len(c.ors) > 0 || len(c.andGroups) > 0 || len(c.orGroups) > 0 || nil != c.nestedComparison {
+		return comparisonMeta{}, false
+	}
+	return c.ands[0], true
+}
+
+func (p *comparisonParser) peek() comparisonToken {
+	return p.tokens[p.pos]
+}
+
+func (p *comparisonParser) next() comparisonToken {
+	t := p.tokens[p.pos]
+	if comparisonTokenEOF != t.kind {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is any of the symbols or keywords
+func (p *comparisonParser) accept(texts ...string) bool {
+	t := p.peek()
+	if comparisonTokenIdent != t.kind && comparisonTokenSymbol != t.kind {
+		return false
+	}
+	for _, text := range texts {
+		if strings.EqualFold(t.text, text) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *comparisonParser) errorf(t comparisonToken, format string, args ...interface{}) error {
+	if comparisonTokenEOF == t.kind {
+		return fmt.Errorf("invalid comparison expression: "+format+" at end", args...)
+	}
+	return fmt.Errorf("invalid comparison expression: "+format+" at %d", append(args, t.pos)...)
+}
+
+func tokenizeComparison(expression string) ([]comparisonToken, error) {
+	tokens := []comparisonToken{}
+	rs := []rune(expression)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case '\'' == r || '"' == r:
+			sb := strings.Builder{}
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if '\\' == rs[j] && j+1 < len(rs) {
+					j++
+				}
+				sb.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("invalid comparison expression: unterminated string at %d", i)
+			}
+			tokens = append(tokens, comparisonToken{kind: comparisonTokenString, text: sb.String(), pos: i})
+			i = j + 1
+		case unicode.IsDigit(r) || ('-' == r && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for ; j < len(rs) && (unicode.IsDigit(rs[j]) || '.' == rs[j] || 'e' == rs[j] || 'E' == rs[j]); j++ {
+			}
+			tokens = append(tokens, comparisonToken{kind: comparisonTokenNumber, text: string(rs[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || '_' == r:
+			j := i + 1
+			for ; j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || '_' == rs[j] || '.' == rs[j]); j++ {
+			}
+			tokens = append(tokens, comparisonToken{kind: comparisonTokenIdent, text: string(rs[i:j]), pos: i})
+			i = j
+		default:
+			symbol := ""
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "==", "!=", "<>", "<=", ">=", "&&", "||":
+					symbol = two
+				}
+			}
+			if "" == symbol && strings.ContainsRune("()[],=!<>~", r) {
+				symbol = string(r)
+			}
+			if "" == symbol {
+				return nil, fmt.Errorf("invalid comparison expression: unexpected %c at %d", r, i)
+			}
+			tokens = append(tokens, comparisonToken{kind: comparisonTokenSymbol, text: symbol, pos: i})
+			i += len([]rune(symbol))
+		}
+	}
+	tokens = append(tokens, comparisonToken{kind: comparisonTokenEOF, text: "end", pos: len(rs)})
+	return tokens, nil
+}
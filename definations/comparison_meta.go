@@ -1,7 +1,10 @@
 package definations
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
 )
 
@@ -12,6 +15,7 @@ type CompareType int
 const (
 	CompareEquals        = CompareType(0)
 	ConpareNotEquals     = CompareType(1)
+	CompareNotEquals     = ConpareNotEquals
 	CompareLessThan      = CompareType(10)
 	CompareLessEquals    = CompareType(11)
 	CompareGreaterThan   = CompareType(12)
@@ -21,19 +25,32 @@ const (
 	CompareNotInArray    = CompareType(23)
 	CompareBetween       = CompareType(24)
 	CompareNotBetween    = CompareType(25)
+	CompareRegex         = CompareType(26)
+	CompareIncludes      = CompareType(27)
 )
 
+// FieldValueGetter the element provides the field values for comparison instead of reflection
+type FieldValueGetter interface {
+	GetFieldValue(field string) (interface{}, bool)
+}
+
 // comparisonMeta struct
 type comparisonMeta struct {
 	Comparison CompareType
 	Field      string
 	Value      interface{}
+	// literal the value is parsed from expression, the number and bool literals are compared to strings by text
+	literal bool
 }
 
-// ComparisonObject struct
+// ComparisonObject struct, the ands and and groups are all matched, then any of the ors and or groups matched,
+// the result is reversed if negated
 type ComparisonObject struct {
 	ands             []comparisonMeta
 	ors              []comparisonMeta
+	andGroups        []*ComparisonObject
+	orGroups         []*ComparisonObject
+	negated          bool
 	nestedComparison *ComparisonObject
 }
 
@@ -42,12 +59,17 @@ func NewComparisonObject() *ComparisonObject {
 	return &ComparisonObject{}
 }
 
-// And contion
+// Not new comparison negating the group
+func Not(group *ComparisonObject) *ComparisonObject {
+	return NewComparisonObject().AndGroup(group).Negate()
+}
+
+// And contion, the field could be a dotted path such as "Meta.Name"
 func (c *ComparisonObject) And(compareType CompareType, field string, value interface{}) *ComparisonObject {
 	if nil == c.ands {
 		c.ands = []comparisonMeta{}
 	}
-	c.ands = append(c.ands, comparisonMeta{compareType, field, value})
+	c.ands = append(c.ands, newComparisonMeta(compareType, field, value))
 	return c
 }
 
@@ -56,46 +78,86 @@ func (c *ComparisonObject) Or(compareType CompareType, field string, value inter
 	if nil == c.ors {
 		c.ors = []comparisonMeta{}
 	}
-	c.ors = append(c.ors, comparisonMeta{compareType, field, value})
+	c.ors = append(c.ors, newComparisonMeta(compareType, field, value))
+	return c
+}
+
+// AndGroup the group should be matched
+func (c *ComparisonObject) AndGroup(group *ComparisonObject) *ComparisonObject {
+	if nil != group {
+		c.andGroups = append(c.andGroups, group)
+	}
+	return c
+}
+
+// OrGroup the group is one of the or conditions
+func (c *ComparisonObject) OrGroup(group *ComparisonObject) *ComparisonObject {
+	if nil != group {
+		c.orGroups = append(c.orGroups, group)
+	}
 	return c
 }
 
-// Evaluate the comparison
+// Negate reverses the result of comparison
+func (c *ComparisonObject) Negate() *ComparisonObject {
+	c.negated = !c.negated
+	return c
+}
+
+// newComparisonMeta the regex pattern is compiled once, the invalid pattern is kept for Validate
+func newComparisonMeta(compareType CompareType, field string, value interface{}) comparisonMeta {
+	if pattern, ok := value.(string); ok && CompareRegex == compareType {
+		if reg, err := regexp.Compile(pattern); nil == err {
+			value = reg
+		}
+	}
+	return comparisonMeta{Comparison: compareType, Field: field, Value: value}
+}
+
+// Evaluate the comparison, the element should be a struct, map of string keys or FieldValueGetter
 func (c *ComparisonObject) Evaluate(element interface{}) bool {
-	ev := reflect.ValueOf(element)
-	var r bool = false
-	if ev.IsValid() && ev.Type().Kind() == reflect.Ptr {
-		ev = ev.Elem()
+	if _, ok := element.(FieldValueGetter); !ok {
+		ev := reflect.ValueOf(element)
+		for ev.IsValid() && (ev.Type().Kind() == reflect.Ptr || ev.Type().Kind() == reflect.Interface) {
+			ev = ev.Elem()
+		}
+		if false == ev.IsValid() || (ev.Type().Kind() != reflect.Struct && ev.Type().Kind() != reflect.Map) {
+			return false
+		}
 	}
-	if false == ev.IsValid() || ev.Type().Kind() != reflect.Struct {
-		return r
+	r := c.evaluate(element)
+	if c.negated {
+		return !r
 	}
-	if nil != c.ands {
+	return r
+}
+
+func (c *ComparisonObject) evaluate(element interface{}) bool {
+	var r bool = false
+	if len(c.ands) > 0 || len(c.andGroups) > 0 {
 		r = true
 		for _, a := range c.ands {
-			fv := ev.FieldByName(a.Field)
-			if fv.IsValid() == false {
-				r = false
-				break
-			}
-			if a.compareValue(fv) == false {
-				r = false
-				break
+			if a.evaluate(element) == false {
+				return false
 			}
 		}
-		if false == r {
-			return r
+		for _, g := range c.andGroups {
+			if g.Evaluate(element) == false {
+				return false
+			}
 		}
 	}
-	if nil != c.ors {
+	if len(c.ors) > 0 || len(c.orGroups) > 0 {
 		r = false
 		for _, o := range c.ors {
-			fv := ev.FieldByName(o.Field)
-			if fv.IsValid() && o.compareValue(fv) {
+			if o.evaluate(element) {
 				r = true
 				break
 			}
 		}
+		for i := 0; false == r && i < len(c.orGroups); i++ {
+			r = c.orGroups[i].Evaluate(element)
+		}
 		if false == r {
 			return r
 		}
@@ -106,22 +168,83 @@ func (c *ComparisonObject) Evaluate(element interface{}) bool {
 	return r
 }
 
+// evaluate the field value of element
+func (c *comparisonMeta) evaluate(element interface{}) bool {
+	fv, ok := lookupFieldValue(element, c.Field)
+	if false == ok {
+		return false
+	}
+	return c.compareValue(fv)
+}
+
+// lookupFieldValue the field value by FieldValueGetter, or the struct fields and map values of dotted path
+func lookupFieldValue(element interface{}, field string) (reflect.Value, bool) {
+	var value reflect.Value
+	if getter, ok := element.(FieldValueGetter); ok {
+		v, ok := getter.GetFieldValue(field)
+		if false == ok {
+			return value, false
+		}
+		value = reflect.ValueOf(v)
+	} else {
+		value = reflect.ValueOf(element)
+		for _, segment := range strings.Split(field, ".") {
+			for value.IsValid() && (value.Type().Kind() == reflect.Ptr || value.Type().Kind() == reflect.Interface) {
+				value = value.Elem()
+			}
+			if false == value.IsValid() {
+				return value, false
+			}
+			switch value.Type().Kind() {
+			case reflect.Struct:
+				value = value.FieldByName(segment)
+			case reflect.Map:
+				if value.Type().Key().Kind() != reflect.String {
+					return reflect.Value{}, false
+				}
+				value = value.MapIndex(reflect.ValueOf(segment).Convert(value.Type().Key()))
+			default:
+				return reflect.Value{}, false
+			}
+		}
+	}
+	for value.IsValid() && (value.Type().Kind() == reflect.Ptr || value.Type().Kind() == reflect.Interface) {
+		value = value.Elem()
+	}
+	return value, value.IsValid()
+}
+
 // compareValue with value
 func (c *comparisonMeta) compareValue(value reflect.Value) bool {
+	if c.literal && nil != c.Value && value.Type().Kind() == reflect.String {
+		if rk := reflect.TypeOf(c.Value).Kind(); isIntKind(rk) || isFloatKind(rk) || rk == reflect.Bool {
+			return compareValue(value, fmt.Sprint(c.Value), c.Comparison)
+		}
+	}
 	return compareValue(value, c.Value, c.Comparison)
 }
 
 func compareValue(value reflect.Value, right interface{}, cmp CompareType) bool {
 	var r bool
+	if nil == right {
+		return ConpareNotEquals == cmp || CompareNotInArray == cmp
+	}
+	switch cmp {
+	case CompareRegex:
+		return compareRegex(value, right)
+	case CompareIncludes:
+		return compareIncludes(value, right)
+	}
 	switch value.Type().Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		r = compareInt(cmp, value, right)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		r = compareNumber(cmp, value, right)
 		break
 	case reflect.String:
 		r = compareString(cmp, value, right)
 		break
-	case reflect.Float32, reflect.Float64:
-		r = compareFloat(cmp, value, right)
+	case reflect.Bool:
+		r = compareBool(cmp, value, right)
 		break
 	default:
 		break
@@ -129,39 +252,42 @@ func compareValue(value reflect.Value, right interface{}, cmp CompareType) bool
 	return r
 }
 
-func compareInt(cmp CompareType, value reflect.Value, right interface{}) bool {
+// compareNumber compares the int, uint and float values with each other
+func compareNumber(cmp CompareType, value reflect.Value, right interface{}) bool {
 	var r bool
-	left := value.Int()
 	rv := reflect.ValueOf(right)
 	switch cmp {
 	case CompareEquals:
-		r = isIntKind(rv.Type().Kind()) && left == rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n == 0
 		break
 	case ConpareNotEquals:
-		r = isIntKind(rv.Type().Kind()) && left != rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n != 0
 		break
 	case CompareLessThan:
-		r = isIntKind(rv.Type().Kind()) && left < rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n < 0
 		break
 	case CompareLessEquals:
-		r = isIntKind(rv.Type().Kind()) && left <= rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n <= 0
 		break
 	case CompareGreaterThan:
-		r = isIntKind(rv.Type().Kind()) && left > rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n > 0
 		break
 	case CompareGreaterEquals:
-		r = isIntKind(rv.Type().Kind()) && left >= rv.Int()
+		n, ok := compareNumbers(value, rv)
+		r = ok && n >= 0
 		break
 	case CompareContains, CompareInArray:
 		if rv.IsValid() && isArrayKind(rv.Type().Kind()) {
 			rl := rv.Len()
 			for i := 0; i < rl; i++ {
-				ev := rv.Index(i)
-				if ev.IsValid() && isIntKind(ev.Type().Kind()) {
-					if left == ev.Int() {
-						r = true
-						break
-					}
+				if n, ok := compareNumbers(value, rv.Index(i)); ok && n == 0 {
+					r = true
+					break
 				}
 			}
 		}
@@ -171,12 +297,9 @@ func compareInt(cmp CompareType, value reflect.Value, right interface{}) bool {
 			r = true
 			rl := rv.Len()
 			for i := 0; i < rl; i++ {
-				ev := rv.Index(i)
-				if ev.IsValid() && isIntKind(ev.Type().Kind()) {
-					if left == ev.Int() {
-						r = false
-						break
-					}
+				if n, ok := compareNumbers(value, rv.Index(i)); ok && n == 0 {
+					r = false
+					break
 				}
 			}
 		} else {
@@ -185,25 +308,19 @@ func compareInt(cmp CompareType, value reflect.Value, right interface{}) bool {
 		break
 	case CompareBetween:
 		if rv.IsValid() && isArrayKind(rv.Type().Kind()) && rv.Len() > 1 {
-			ev1 := rv.Index(0)
-			ev2 := rv.Index(1)
-			if ev1.IsValid() && isIntKind(ev1.Type().Kind()) && ev2.IsValid() && isIntKind(ev2.Type().Kind()) {
-				if left >= ev1.Int() && left <= ev2.Int() {
-					r = true
-				}
+			n1, ok1 := compareNumbers(value, rv.Index(0))
+			n2, ok2 := compareNumbers(value, rv.Index(1))
+			if ok1 && ok2 && n1 >= 0 && n2 <= 0 {
+				r = true
 			}
 		}
 		break
 	case CompareNotBetween:
 		if rv.IsValid() && isArrayKind(rv.Type().Kind()) && rv.Len() > 1 {
-			ev1 := rv.Index(0)
-			ev2 := rv.Index(1)
-			if ev1.IsValid() && isIntKind(ev1.Type().Kind()) && ev2.IsValid() && isIntKind(ev2.Type().Kind()) {
-				if left >= ev1.Int() && left <= ev2.Int() {
-					r = false
-				} else {
-					r = true
-				}
+			n1, ok1 := compareNumbers(value, rv.Index(0))
+			n2, ok2 := compareNumbers(value, rv.Index(1))
+			if ok1 && ok2 {
+				r = n1 < 0 || n2 > 0
 			}
 		}
 		break
@@ -213,9 +330,59 @@ func compareInt(cmp CompareType, value reflect.Value, right interface{}) bool {
 	return r
 }
 
+// compareNumbers returns -1, 0 or 1 of the numbers, the ints are compared exactly and the others as floats
+func compareNumbers(left reflect.Value, right reflect.Value) (int, bool) {
+	for right.IsValid() && right.Type().Kind() == reflect.Interface {
+		right = right.Elem()
+	}
+	if false == right.IsValid() || (false == isIntKind(right.Type().Kind()) && false == isFloatKind(right.Type().Kind())) {
+		return 0, false
+	}
+	if isSignedIntKind(left.Type().Kind()) && isSignedIntKind(right.Type().Kind()) {
+		return compareOrdered(left.Int() < right.Int(), left.Int() > right.Int()), true
+	}
+	if isUintKind(left.Type().Kind()) && isUintKind(right.Type().Kind()) {
+		return compareOrdered(left.Uint() < right.Uint(), left.Uint() > right.Uint()), true
+	}
+	lf, rf := floatOf(left), floatOf(right)
+	return compareOrdered(lf < rf, lf > rf), true
+}
+
+func compareOrdered(less bool, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+func floatOf(value reflect.Value) float64 {
+	switch {
+	case isSignedIntKind(value.Type().Kind()):
+		return float64(value.Int())
+	case isUintKind(value.Type().Kind()):
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
+
 func isIntKind(kind reflect.Kind) bool {
+	return isSignedIntKind(kind) || isUintKind(kind)
+}
+
+func isSignedIntKind(kind reflect.Kind) bool {
 	switch kind {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return true
 	}
 	return false
@@ -323,84 +490,113 @@ func compareString(cmp CompareType, value reflect.Value, right interface{}) bool
 	return r
 }
 
-func compareFloat(cmp CompareType, value reflect.Value, right interface{}) bool {
-	var r bool
-	left := value.Float()
+func compareBool(cmp CompareType, value reflect.Value, right interface{}) bool {
 	rv := reflect.ValueOf(right)
+	if rv.Type().Kind() != reflect.Bool {
+		return false
+	}
 	switch cmp {
 	case CompareEquals:
-		r = isFloatKind(rv.Type().Kind()) && left == rv.Float()
-		break
+		return value.Bool() == rv.Bool()
 	case ConpareNotEquals:
-		r = isFloatKind(rv.Type().Kind()) && left != rv.Float()
-		break
-	case CompareLessThan:
-		r = isFloatKind(rv.Type().Kind()) && left < rv.Float()
-		break
-	case CompareLessEquals:
-		r = isFloatKind(rv.Type().Kind()) && left <= rv.Float()
-		break
-	case CompareGreaterThan:
-		r = isFloatKind(rv.Type().Kind()) && left > rv.Float()
-		break
-	case CompareGreaterEquals:
-		r = isFloatKind(rv.Type().Kind()) && left >= rv.Float()
-		break
-	case CompareContains, CompareInArray:
-		if rv.IsValid() && isArrayKind(rv.Type().Kind()) {
-			for i := 0; i < rv.Len(); i++ {
-				ev := rv.Index(i)
-				if ev.IsValid() && isFloatKind(ev.Type().Kind()) {
-					if left == ev.Float() {
-						r = true
-						break
-					}
-				}
+		return value.Bool() != rv.Bool()
+	}
+	return false
+}
+
+// compareIncludes the string value includes the text, or any item of array value equals to the right
+func compareIncludes(value reflect.Value, right interface{}) bool {
+	switch {
+	case value.Type().Kind() == reflect.String:
+		return strings.Contains(value.String(), fmt.Sprint(right))
+	case isArrayKind(value.Type().Kind()):
+		for i := 0; i < value.Len(); i++ {
+			item := value.Index(i)
+			for item.IsValid() && (item.Type().Kind() == reflect.Ptr || item.Type().Kind() == reflect.Interface) {
+				item = item.Elem()
 			}
-		}
-		break
-	case CompareNotInArray:
-		if rv.IsValid() && isArrayKind(rv.Type().Kind()) {
-			r = true
-			for i := 0; i < rv.Len(); i++ {
-				ev := rv.Index(i)
-				if ev.IsValid() && isFloatKind(ev.Type().Kind()) {
-					if left == ev.Float() {
-						r = false
-						break
-					}
-				}
+			if item.IsValid() && compareValue(item, right, CompareEquals) {
+				return true
 			}
-		} else {
-			r = true
 		}
-		break
-	case CompareBetween:
-		if rv.IsValid() && isArrayKind(rv.Type().Kind()) && rv.Len() > 1 {
-			ev1 := rv.Index(0)
-			ev2 := rv.Index(1)
-			if ev1.IsValid() && isFloatKind(ev1.Type().Kind()) && ev2.IsValid() && isFloatKind(ev2.Type().Kind()) {
-				if left >= ev1.Float() && left <= ev2.Float() {
-					r = true
-				}
-			}
+	}
+	return false
+}
+
+// compareRegex matches the string value, or the text of number value
+func compareRegex(value reflect.Value, right interface{}) bool {
+	reg, ok := right.(*regexp.Regexp)
+	if false == ok {
+		return false
+	}
+	switch {
+	case value.Type().Kind() == reflect.String:
+		return reg.MatchString(value.String())
+	case isIntKind(value.Type().Kind()) || isFloatKind(value.Type().Kind()):
+		return reg.MatchString(floatOrIntText(value))
+	}
+	return false
+}
+
+func floatOrIntText(value reflect.Value) string {
+	switch {
+	case isSignedIntKind(value.Type().Kind()):
+		return fmt.Sprint(value.Int())
+	case isUintKind(value.Type().Kind()):
+		return fmt.Sprint(value.Uint())
+	}
+	return fmt.Sprint(value.Float())
+}
+
+// Validate checks the fields, comparison types and the values of array or between comparisons
+func (c *ComparisonObject) Validate() error {
+	errs := ValidationErrors{}
+	for i, a := range c.ands {
+		errs.Add(fmt.Sprintf("ands[%d]", i), a.validate())
+	}
+	for i, o := range c.ors {
+		errs.Add(fmt.Sprintf("ors[%d]", i), o.validate())
+	}
+	for i, g := range c.andGroups {
+		errs.Add(fmt.Sprintf("andGroups[%d]", i), g.Validate())
+	}
+	for i, g := range c.orGroups {
+		errs.Add(fmt.Sprintf("orGroups[%d]", i), g.Validate())
+	}
+	if nil != c.nestedComparison {
+		errs.Add("nested", c.nestedComparison.Validate())
+	}
+	return errs.Err()
+}
+
+func (c *comparisonMeta) validate() error {
+	if "" == c.Field {
+		return errors.New("comparison field is empty")
+	}
+	rv := reflect.ValueOf(c.Value)
+	switch c.Comparison {
+	case CompareEquals, ConpareNotEquals, CompareLessThan, CompareLessEquals, CompareGreaterThan, CompareGreaterEquals, CompareIncludes:
+		if !rv.IsValid() {
+			return fmt.Errorf("comparison value of field %s is nil", c.Field)
 		}
-		break
-	case CompareNotBetween:
-		if rv.IsValid() && isArrayKind(rv.Type().Kind()) && rv.Len() > 1 {
-			ev1 := rv.Index(0)
-			ev2 := rv.Index(1)
-			if ev1.IsValid() && isFloatKind(ev1.Type().Kind()) && ev2.IsValid() && isFloatKind(ev2.Type().Kind()) {
-				if left >= ev1.Float() && left <= ev2.Float() {
-					r = false
-				} else {
-					r = true
-				}
-			}
+	case CompareContains:
+		if !rv.IsValid() || (!isArrayKind(rv.Type().Kind()) && reflect.String != rv.Type().Kind()) {
+			return fmt.Errorf("comparison value of field %s should be an array or string", c.Field)
+		}
+	case CompareInArray, CompareNotInArray:
+		if !rv.IsValid() || !isArrayKind(rv.Type().Kind()) {
+			return fmt.Errorf("comparison value of field %s should be an array", c.Field)
+		}
+	case CompareBetween, CompareNotBetween:
+		if !rv.IsValid() || !isArrayKind(rv.Type().Kind()) || rv.Len() != 2 {
+			return fmt.Errorf("comparison value of field %s should be an array of 2 elements", c.Field)
+		}
+	case CompareRegex:
+		if _, ok := c.Value.(*regexp.Regexp); !ok {
+			return fmt.Errorf("comparison value of field %s should be a valid regular expression", c.Field)
 		}
-		break
 	default:
-		break
+		return fmt.Errorf("unknown comparison type %d of field %s", c.Comparison, c.Field)
 	}
-	return r
+	return nil
 }
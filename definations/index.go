@@ -1,6 +1,10 @@
 package definations
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
 	"strings"
 )
 
@@ -14,6 +18,25 @@ type TLSOptions struct {
 	VerifyClient bool   `yaml:"verifyClient"`
 }
 
+// Validate checks the certificate and key files are configured in pair and all the files are readable,
+// the disabled options are always valid
+func (n *TLSOptions) Validate() error {
+	if !n.Enabled {
+		return nil
+	}
+	errs := ValidationErrors{}
+	if ("" == n.CertFile) != ("" == n.KeyFile) {
+		errs.Add("", errors.New("certFile and keyFile should be configured together"))
+	}
+	if n.VerifyClient && "" == n.CaFile {
+		errs.Add("caFile", errors.New("required for verifying client certificates"))
+	}
+	errs.Add("certFile", checkReadableFile(n.CertFile))
+	errs.Add("keyFile", checkReadableFile(n.KeyFile))
+	errs.Add("caFile", checkReadableFile(n.CaFile))
+	return errs.Err()
+}
+
 // Proxies options about http proxy
 type Proxies struct {
 	HTTP  string `yaml:"http"`
@@ -41,6 +64,14 @@ func (n *Proxies) FetchProxyURL(endpointURL string) string {
 	return n.HTTP
 }
 
+// Validate checks the configured proxies are absolute urls of http, https or socks5 scheme
+func (n *Proxies) Validate() error {
+	errs := ValidationErrors{}
+	errs.Add("http", checkProxyURL(n.HTTP))
+	errs.Add("https", checkProxyURL(n.HTTPS))
+	return errs.Err()
+}
+
 // DBConnectorConfig db connector configuration
 type DBConnectorConfig struct {
 	Driver       string `yaml:"driver"`
@@ -50,3 +81,54 @@ type DBConnectorConfig struct {
 	TablePrefix  string `yaml:"tablePrefix"`
 	SSHTunnelDSN string `yaml:"sshTunnel"`
 }
+
+// Validate checks the driver and address
+func (n *DBConnectorConfig) Validate() error {
+	errs := ValidationErrors{}
+	if "" == n.Driver {
+		errs.Add("driver", errors.New("required"))
+	}
+	if "" == n.Address {
+		errs.Add("address", errors.New("required"))
+	}
+	return errs.Err()
+}
+
+func checkReadableFile(filePath string) error {
+	if "" == filePath {
+		return nil
+	}
+	f, err := os.Open(filePath)
+	if nil != err {
+		return fmt.Errorf("file %s is not readable:%v", filePath, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if nil != err {
+		return fmt.Errorf("file %s is not readable:%v", filePath, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filePath)
+	}
+	return nil
+}
+
+func checkProxyURL(proxyURL string) error {
+	if "" == proxyURL {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if nil != err {
+		// the parsing error is not returned as it contains the credentials of url
+		return errors.New("invalid proxy url")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("invalid proxy url %s, the scheme should be http, https or socks5", u.Redacted())
+	}
+	if "" == u.Host {
+		return fmt.Errorf("invalid proxy url %s without host", u.Redacted())
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package definations
+
+import (
+	"strings"
+)
+
+// Validatable the options could be validated
+type Validatable interface {
+	Validate() error
+}
+
+// FieldError the validation error of field
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+// Error the error message prefixed by field path
+func (e *FieldError) Error() string {
+	if "" == e.Field {
+		return e.Err.Error()
+	}
+	return e.Field + ": " + e.Err.Error()
+}
+
+// Unwrap the origin error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationErrors the aggregated validation errors
+type ValidationErrors []error
+
+// Error all the error messages joined by semicolon
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add appends the error of field, the nested ValidationErrors are flattened with the field as the path prefix,
+// the nil error is ignored
+func (e *ValidationErrors) Add(field string, err error) {
+	if nil == err {
+		return
+	}
+	switch v := err.(type) {
+	case ValidationErrors:
+		for _, item := range v {
+			e.Add(field, item)
+		}
+	case *FieldError:
+		*e = append(*e, &FieldError{Field: joinFieldPath(field, v.Field), Err: v.Err})
+	default:
+		*e = append(*e, &FieldError{Field: field, Err: err})
+	}
+}
+
+// Err nil if no errors, otherwise the ValidationErrors
+func (e ValidationErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func joinFieldPath(prefix string, field string) string {
+	if "" == prefix {
+		return field
+	}
+	if "" == field {
+		return prefix
+	}
+	if strings.HasPrefix(field, "[") {
+		return prefix + field
+	}
+	return prefix + "." + field
+}
@@ -0,0 +1,144 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/libpub/golib/httpclient"
+)
+
+// Constants of consul
+const (
+	DefaultConsulAddress       = "http://127.0.0.1:8500"
+	DefaultConsulCheckInterval = "10s"
+	DefaultConsulDeregister    = "1m"
+)
+
+// ConsulRegistry registry by consul agent http api
+type ConsulRegistry struct {
+	Address    string // consul agent address such as http://127.0.0.1:8500
+	Token      string // acl token
+	Datacenter string
+	Timeout    int // seconds
+}
+
+type consulServiceCheck struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+type consulService struct {
+	ID      string              `json:"ID"`
+	Name    string              `json:"Name,omitempty"`
+	Service string              `json:"Service,omitempty"`
+	Address string              `json:"Address"`
+	Port    int                 `json:"Port"`
+	Tags    []string            `json:"Tags,omitempty"`
+	Meta    map[string]string   `json:"Meta,omitempty"`
+	Check   *consulServiceCheck `json:"Check,omitempty"`
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service consulService `json:"Service"`
+}
+
+// NewConsulRegistry new registry of consul agent address, the default address is used if empty
+func NewConsulRegistry(address string, token string) *ConsulRegistry {
+	if "" == address {
+		address = DefaultConsulAddress
+	}
+	return &ConsulRegistry{Address: strings.TrimRight(address, "/"), Token: token}
+}
+
+// Register the instance to consul agent, the consul checks the HealthCheckURL if not empty
+// and deregisters the instance critical for DefaultConsulDeregister
+func (c *ConsulRegistry) Register(instance ServiceInstance) error {
+	service := consulService{
+		ID:      instance.ID,
+		Name:    instance.Name,
+		Address: instance.Address,
+		Port:    instance.Port,
+		Tags:    instance.Tags,
+		Meta:    instance.Meta,
+	}
+	if "" != instance.Scheme {
+		// the scheme is kept in meta for resolving
+		service.Meta = map[string]string{"scheme": instance.Scheme}
+		for k, v := range instance.Meta {
+			service.Meta[k] = v
+		}
+	}
+	if "" != instance.HealthCheckURL {
+		service.Check = &consulServiceCheck{
+			HTTP:                           instance.HealthCheckURL,
+			Interval:                       DefaultConsulCheckInterval,
+			DeregisterCriticalServiceAfter: DefaultConsulDeregister,
+		}
+	}
+	body, err := json.Marshal(service)
+	if nil != err {
+		return err
+	}
+	_, err = httpclient.HTTPQuery("PUT", c.Address+"/v1/agent/service/register", bytes.NewReader(body), c.options()...)
+	return err
+}
+
+// Deregister the instance from consul agent
+func (c *ConsulRegistry) Deregister(ID string) error {
+	_, err := httpclient.HTTPQuery("PUT", c.Address+"/v1/agent/service/deregister/"+url.PathEscape(ID), nil, c.options()...)
+	return err
+}
+
+// Resolve the passing instances of the named service
+func (c *ConsulRegistry) Resolve(name string) ([]ServiceInstance, error) {
+	query := url.Values{}
+	query.Set("passing", "true")
+	if "" != c.Datacenter {
+		query.Set("dc", c.Datacenter)
+	}
+	resp, err := httpclient.HTTPQuery("GET", c.Address+"/v1/health/service/"+url.PathEscape(name)+"?"+query.Encode(), nil, c.options()...)
+	if nil != err {
+		return nil, err
+	}
+	entries := []consulServiceEntry{}
+	if err = json.Unmarshal(resp, &entries); nil != err {
+		return nil, err
+	}
+	instances := make([]ServiceInstance, len(entries))
+	for i, entry := range entries {
+		instance := ServiceInstance{
+			ID:      entry.Service.ID,
+			Name:    entry.Service.Service,
+			Address: entry.Service.Address,
+			Port:    entry.Service.Port,
+			Tags:    entry.Service.Tags,
+			Meta:    entry.Service.Meta,
+		}
+		if "" == instance.Address {
+			// the service registered without address uses the node address
+			instance.Address = entry.Node.Address
+		}
+		if scheme := instance.Meta["scheme"]; "" != scheme {
+			instance.Scheme = scheme
+		}
+		instances[i] = instance
+	}
+	return instances, nil
+}
+
+func (c *ConsulRegistry) options() []httpclient.ClientOption {
+	options := []httpclient.ClientOption{}
+	if "" != c.Token {
+		options = append(options, httpclient.WithHTTPHeader("X-Consul-Token", c.Token))
+	}
+	if c.Timeout > 0 {
+		options = append(options, httpclient.WithTimeout(c.Timeout))
+	}
+	return options
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+)
+
+// Constants
+const (
+	DefaultResolveCacheTTL = 10 * time.Second
+)
+
+// ErrServiceNotFound no healthy instances of the service
+var ErrServiceNotFound = errors.New("service not found")
+
+// ServiceInstance the registered instance of service
+type ServiceInstance struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Address        string            `json:"address"`
+	Port           int               `json:"port"`
+	Scheme         string            `json:"scheme,omitempty"` // http by default
+	Tags           []string          `json:"tags,omitempty"`
+	Meta           map[string]string `json:"meta,omitempty"`
+	HealthCheckURL string            `json:"healthCheckUrl,omitempty"` // checked by registry if supported
+}
+
+// URL the base url of instance such as http://10.0.0.1:8080
+func (s ServiceInstance) URL() string {
+	scheme := s.Scheme
+	if "" == scheme {
+		scheme = "http"
+	}
+	return scheme + "://" + net.JoinHostPort(s.Address, strconv.Itoa(s.Port))
+}
+
+// Registry registers the running service and resolves the named services
+type Registry interface {
+	// Register the instance, the registration is kept alive until Deregister
+	Register(instance ServiceInstance) error
+	// Deregister the instance by ID
+	Deregister(ID string) error
+	// Resolve the healthy instances of the named service
+	Resolve(name string) ([]ServiceInstance, error)
+}
+
+// Resolver resolves the services by registry with caching, implements httpclient.EndpointResolver
+type Resolver struct {
+	registry Registry
+	ttl      time.Duration
+	caches   map[string]resolvedService
+	m        sync.RWMutex
+}
+
+type resolvedService struct {
+	endpoints  []string
+	resolvedAt time.Time
+}
+
+// NewResolver new resolver caching the resolved endpoints for ttl
+func NewResolver(registry Registry, ttl time.Duration) *Resolver {
+	if ttl <= 0 {
+		ttl = DefaultResolveCacheTTL
+	}
+	return &Resolver{
+		registry: registry,
+		ttl:      ttl,
+		caches:   map[string]resolvedService{},
+	}
+}
+
+// ResolveEndpoints the base urls of the named service, the expired cache is used if resolving failed
+func (r *Resolver) ResolveEndpoints(service string) ([]string, error) {
+	r.m.RLock()
+	cached, ok := r.caches[service]
+	r.m.RUnlock()
+	if ok && time.Since(cached.resolvedAt) < r.ttl {
+		return cached.endpoints, nil
+	}
+	instances, err := r.registry.Resolve(service)
+	if nil == err && len(instances) == 0 {
+		err = fmt.Errorf("%w:%s", ErrServiceNotFound, service)
+	}
+	if nil != err {
+		if ok {
+			logger.Warning.Printf("resolving service:%s failed with error:%v, using the cached endpoints", service, err)
+			return cached.endpoints, nil
+		}
+		return nil, err
+	}
+	endpoints := make([]string, len(instances))
+	for i, instance := range instances {
+		endpoints[i] = instance.URL()
+	}
+	r.m.Lock()
+	r.caches[service] = resolvedService{endpoints: endpoints, resolvedAt: time.Now()}
+	r.m.Unlock()
+	return endpoints, nil
+}
+
+// Invalidate removes the cached endpoints of service
+func (r *Resolver) Invalidate(service string) {
+	r.m.Lock()
+	delete(r.caches, service)
+	r.m.Unlock()
+}
+
+// UseWithHTTPClient resolves the service://name/path urls of httpclient by registry
+func UseWithHTTPClient(registry Registry, ttl time.Duration) *Resolver {
+	resolver := NewResolver(registry, ttl)
+	httpclient.SetEndpointResolver(resolver)
+	return resolver
+}
@@ -0,0 +1,234 @@
+package discovery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+)
+
+// Constants of etcd
+const (
+	DefaultEtcdAddress   = "http://127.0.0.1:2379"
+	DefaultEtcdKeyPrefix = "/services/"
+	DefaultEtcdLeaseTTL  = 30 // seconds
+)
+
+// EtcdRegistry registry by etcd v3 json gateway, the instances are kept as json values of keys
+// {KeyPrefix}{name}/{ID} with lease kept alive until Deregister
+type EtcdRegistry struct {
+	Address   string // etcd address such as http://127.0.0.1:2379
+	KeyPrefix string
+	LeaseTTL  int // seconds
+	Timeout   int // seconds
+	leases    map[string]*etcdRegistration
+	m         sync.Mutex
+}
+
+type etcdRegistration struct {
+	instance ServiceInstance
+	leaseID  string
+	stop     chan struct{}
+}
+
+type etcdKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []etcdKeyValue `json:"kvs"`
+}
+
+type etcdLeaseResponse struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+type etcdKeepAliveResponse struct {
+	Result etcdLeaseResponse `json:"result"`
+}
+
+// NewEtcdRegistry new registry of etcd address, the default address is used if empty
+func NewEtcdRegistry(address string) *EtcdRegistry {
+	if "" == address {
+		address = DefaultEtcdAddress
+	}
+	return &EtcdRegistry{
+		Address:   strings.TrimRight(address, "/"),
+		KeyPrefix: DefaultEtcdKeyPrefix,
+		LeaseTTL:  DefaultEtcdLeaseTTL,
+		leases:    map[string]*etcdRegistration{},
+	}
+}
+
+// Register puts the instance with a lease and keeps the lease alive, the instance is put again if the lease lost
+func (e *EtcdRegistry) Register(instance ServiceInstance) error {
+	leaseID, err := e.put(instance)
+	if nil != err {
+		return err
+	}
+	reg := &etcdRegistration{instance: instance, leaseID: leaseID, stop: make(chan struct{})}
+	e.m.Lock()
+	if nil == e.leases {
+		e.leases = map[string]*etcdRegistration{}
+	}
+	if exists := e.leases[instance.ID]; nil != exists {
+		// registered again, the old lease is left to expire
+		close(exists.stop)
+	}
+	e.leases[instance.ID] = reg
+	e.m.Unlock()
+	go e.keepAlive(reg)
+	return nil
+}
+
+// Deregister stops keeping alive and revokes the lease of instance
+func (e *EtcdRegistry) Deregister(ID string) error {
+	e.m.Lock()
+	reg := e.leases[ID]
+	delete(e.leases, ID)
+	leaseID := ""
+	if nil != reg {
+		leaseID = reg.leaseID
+		close(reg.stop)
+	}
+	e.m.Unlock()
+	if nil == reg {
+		return nil
+	}
+	return e.post("/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, &struct{}{})
+}
+
+// Resolve the instances of the named service
+func (e *EtcdRegistry) Resolve(name string) ([]ServiceInstance, error) {
+	prefix := e.keyPrefix() + name + "/"
+	resp := etcdRangeResponse{}
+	err := e.post("/v3/kv/range", map[string]interface{}{
+		"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd(prefix)),
+	}, &resp)
+	if nil != err {
+		return nil, err
+	}
+	instances := []ServiceInstance{}
+	for _, kv := range resp.Kvs {
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if nil != err {
+			return nil, err
+		}
+		instance := ServiceInstance{}
+		if err = json.Unmarshal(value, &instance); nil != err {
+			logger.Warning.Printf("resolving service:%s while parsing instance:%s failed with error:%v", name, string(value), err)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	return instances, nil
+}
+
+// put grants a lease and puts the instance with it, returns the lease id
+func (e *EtcdRegistry) put(instance ServiceInstance) (string, error) {
+	ttl := e.LeaseTTL
+	if ttl <= 0 {
+		ttl = DefaultEtcdLeaseTTL
+	}
+	lease := etcdLeaseResponse{}
+	if err := e.post("/v3/lease/grant", map[string]interface{}{"TTL": ttl}, &lease); nil != err {
+		return "", err
+	}
+	if "" == lease.ID {
+		return "", errors.New("etcd granted lease without id")
+	}
+	value, err := json.Marshal(instance)
+	if nil != err {
+		return "", err
+	}
+	err = e.post("/v3/kv/put", map[string]interface{}{
+		"key":   base64.StdEncoding.EncodeToString([]byte(e.keyPrefix() + instance.Name + "/" + instance.ID)),
+		"value": base64.StdEncoding.EncodeToString(value),
+		"lease": lease.ID,
+	}, &struct{}{})
+	if nil != err {
+		return "", err
+	}
+	return lease.ID, nil
+}
+
+func (e *EtcdRegistry) keepAlive(reg *etcdRegistration) {
+	ttl := e.LeaseTTL
+	if ttl <= 0 {
+		ttl = DefaultEtcdLeaseTTL
+	}
+	interval := time.Duration(ttl) * time.Second / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-reg.stop:
+			return
+		case <-ticker.C:
+			resp := etcdKeepAliveResponse{}
+			err := e.post("/v3/lease/keepalive", map[string]interface{}{"ID": reg.leaseID}, &resp)
+			if nil == err && leaseTTLSeconds(resp.Result.TTL) > 0 {
+				continue
+			}
+			// the lease is expired or lost, puts the instance again
+			logger.Warning.Printf("keeping alive service:%s instance:%s lease:%s failed(error:%v), registering again", reg.instance.Name, reg.instance.ID, reg.leaseID, err)
+			leaseID, err := e.put(reg.instance)
+			if nil != err {
+				logger.Error.Printf("registering service:%s instance:%s again failed with error:%v", reg.instance.Name, reg.instance.ID, err)
+				continue
+			}
+			select {
+			case <-reg.stop:
+				// deregistered while registering again
+				e.post("/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, &struct{}{})
+				return
+			default:
+			}
+			e.m.Lock()
+			reg.leaseID = leaseID
+			e.m.Unlock()
+		}
+	}
+}
+
+func (e *EtcdRegistry) post(path string, params interface{}, result interface{}) error {
+	options := []httpclient.ClientOption{}
+	if e.Timeout > 0 {
+		options = append(options, httpclient.WithTimeout(e.Timeout))
+	}
+	return httpclient.HTTPPostJSONEx(e.Address+path, params, result, options...)
+}
+
+func (e *EtcdRegistry) keyPrefix() string {
+	if "" == e.KeyPrefix {
+		return DefaultEtcdKeyPrefix
+	}
+	return e.KeyPrefix
+}
+
+// prefixRangeEnd the range end of keys with prefix
+func prefixRangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
+
+// leaseTTLSeconds the ttl text of etcd response in seconds
+func leaseTTLSeconds(ttl string) int {
+	n, _ := strconv.Atoi(ttl)
+	return n
+}
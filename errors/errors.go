@@ -0,0 +1,161 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"net"
+	"net/http"
+)
+
+// Category category of errors, which is also an error to be matched by Is
+type Category string
+
+// Categories
+const (
+	Unknown         Category = "unknown"
+	Timeout         Category = "timeout"
+	TooManyRequests Category = "too many requests"
+	Unauthorized    Category = "unauthorized"
+	Conflict        Category = "conflict"
+	Upstream5xx     Category = "upstream 5xx"
+)
+
+// Error the categorized error, the status code and body are retained if it is the failed response of http
+type Error struct {
+	Category   Category
+	Message    string
+	StatusCode int
+	Body       []byte
+	Err        error // the wrapped cause
+}
+
+// Error the category name
+func (c Category) Error() string {
+	return string(c)
+}
+
+// New error with text, same as the standard errors.New
+func New(text string) error {
+	return stderrors.New(text)
+}
+
+// Is reports whether any error in err's chain matches target, same as the standard errors.Is
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, same as the standard errors.As
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap the wrapped error of err, same as the standard errors.Unwrap
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// NewError error of category with message
+func NewError(category Category, message string) *Error {
+	return &Error{Category: category, Message: message}
+}
+
+// Wrap err as category with message, the message of err is used if message empty
+func Wrap(err error, category Category, message string) *Error {
+	if "" == message && nil != err {
+		message = err.Error()
+	}
+	return &Error{Category: category, Message: message, Err: err}
+}
+
+// FromResponse error of the failed http response, the message is the status line such as "404 Not Found"
+func FromResponse(statusCode int, status string, body []byte) *Error {
+	if "" == status {
+		status = http.StatusText(statusCode)
+	}
+	return &Error{Category: CategoryOfStatus(statusCode), Message: status, StatusCode: statusCode, Body: body}
+}
+
+// Error message
+func (e *Error) Error() string {
+	if "" == e.Message {
+		return string(e.Category)
+	}
+	return e.Message
+}
+
+// Unwrap the wrapped cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the category of e
+func (e *Error) Is(target error) bool {
+	category, ok := target.(Category)
+	return ok && category == e.Category
+}
+
+// CategoryOf the category of err, the context deadline and net timeout errors are Timeout,
+// Unknown if err is not categorized
+func CategoryOf(err error) Category {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e.Category
+	}
+	var category Category
+	if stderrors.As(err, &category) {
+		return category
+	}
+	var netErr net.Error
+	if stderrors.Is(err, context.DeadlineExceeded) || (stderrors.As(err, &netErr) && netErr.Timeout()) {
+		return Timeout
+	}
+	return Unknown
+}
+
+// CategoryOfStatus the category of http status code
+func CategoryOfStatus(statusCode int) Category {
+	switch {
+	case http.StatusRequestTimeout == statusCode || http.StatusGatewayTimeout == statusCode:
+		return Timeout
+	case http.StatusTooManyRequests == statusCode:
+		return TooManyRequests
+	case http.StatusUnauthorized == statusCode || http.StatusForbidden == statusCode:
+		return Unauthorized
+	case http.StatusConflict == statusCode:
+		return Conflict
+	case statusCode >= 500 && statusCode < 600:
+		return Upstream5xx
+	}
+	return Unknown
+}
+
+// HTTPStatus the http status code responding err, the 4xx status codes of the failed responses are passed
+// through, and the failures of upstream are responded as 502
+func HTTPStatus(err error) int {
+	var e *Error
+	if stderrors.As(err, &e) && e.StatusCode >= 400 && e.StatusCode < 500 {
+		return e.StatusCode
+	}
+	switch CategoryOf(err) {
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case TooManyRequests:
+		return http.StatusTooManyRequests
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Conflict:
+		return http.StatusConflict
+	case Upstream5xx:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// ResponseBody the retained body of the failed http response in err's chain
+func ResponseBody(err error) []byte {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e.Body
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/concurrency"
+)
+
+// Constants of event bus
+const (
+	// AllEventTypes routes every event type to the sinks
+	AllEventTypes = "*"
+)
+
+// Errors of event bus
+var (
+	ErrNoSink = errors.New("no sink routed for event type")
+)
+
+// Event the typed event emitted by producers, it is delivered to the sinks as the json envelope
+type Event struct {
+	ID      string            `json:"id"`
+	Type    string            `json:"type"`
+	Source  string            `json:"source,omitempty"`
+	Subject string            `json:"subject,omitempty"` // the entity of event such as the order id, used as the partition key by kafka sinks
+	Time    time.Time         `json:"time"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Data    json.RawMessage   `json:"data,omitempty"`
+}
+
+// Decode decodes the json data of event into the value pointed by into
+func (e *Event) Decode(into interface{}) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Data, into)
+}
+
+// TypedEvent the data of event that knows its event type, such as OrderCreated returning "order.created"
+type TypedEvent interface {
+	EventType() string
+}
+
+// Sink delivers the events to a backend
+type Sink interface {
+	// Name of sink in the delivery errors and logs
+	Name() string
+	// Deliver delivers the event, it should be safe for concurrent use
+	Deliver(ctx context.Context, event *Event) error
+}
+
+// SinkError the delivery error of sink
+type SinkError struct {
+	Sink string
+	Err  error
+}
+
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("deliver to sink %s failed with error:%v", e.Sink, e.Err)
+}
+
+// Unwrap the error of sink
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// EmitOption options of emitting event
+type EmitOption func(*Event)
+
+// WithEventID the event id instead of the generated uuid, such as the id of the outbox record
+func WithEventID(ID string) EmitOption {
+	return func(e *Event) {
+		e.ID = ID
+	}
+}
+
+// WithSubject the entity of event such as the order id
+func WithSubject(subject string) EmitOption {
+	return func(e *Event) {
+		e.Subject = subject
+	}
+}
+
+// WithEventHeader the header delivered with event such as the trace id
+func WithEventHeader(name string, value string) EmitOption {
+	return func(e *Event) {
+		if nil == e.Headers {
+			e.Headers = map[string]string{}
+		}
+		e.Headers[name] = value
+	}
+}
+
+// WithEventTime the time of event instead of now
+func WithEventTime(t time.Time) EmitOption {
+	return func(e *Event) {
+		e.Time = t
+	}
+}
+
+// Option options of bus
+type Option func(*Bus)
+
+// WithSource the source of the events emitted by bus such as the service name
+func WithSource(source string) Option {
+	return func(b *Bus) {
+		b.source = source
+	}
+}
+
+// WithIgnoreUnrouted the events without sink routed are dropped silently instead of failing with ErrNoSink
+func WithIgnoreUnrouted() Option {
+	return func(b *Bus) {
+		b.ignoreUnrouted = true
+	}
+}
+
+// Bus delivers the events emitted by producers to the sinks routed by event type, the event is fanned out
+// to all of its sinks concurrently
+type Bus struct {
+	routes         map[string][]Sink
+	source         string
+	ignoreUnrouted bool
+	m              sync.RWMutex
+}
+
+// NewBus new event bus
+func NewBus(options ...Option) *Bus {
+	b := &Bus{routes: map[string][]Sink{}}
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// Route delivers the events of type to the sinks in addition to the sinks routed before, AllEventTypes routes every type
+func (b *Bus) Route(eventType string, sinks ...Sink) {
+	b.m.Lock()
+	for _, sink := range sinks {
+		if nil != sink {
+			b.routes[eventType] = append(b.routes[eventType], sink)
+		}
+	}
+	b.m.Unlock()
+}
+
+// Sinks the sinks of event type including the ones of AllEventTypes
+func (b *Bus) Sinks(eventType string) []Sink {
+	b.m.RLock()
+	defer b.m.RUnlock()
+	sinks := append([]Sink{}, b.routes[eventType]...)
+	if AllEventTypes != eventType {
+		sinks = append(sinks, b.routes[AllEventTypes]...)
+	}
+	return sinks
+}
+
+// Emit encodes data as json and publishes the event of type, returns the event published
+func (b *Bus) Emit(ctx context.Context, eventType string, data interface{}, options ...EmitOption) (*Event, error) {
+	event := &Event{
+		Type:   eventType,
+		Source: b.source,
+	}
+	if nil != data {
+		content, err := json.Marshal(data)
+		if nil != err {
+			return nil, err
+		}
+		event.Data = content
+	}
+	for _, option := range options {
+		option(event)
+	}
+	return event, b.Publish(ctx, event)
+}
+
+// EmitEvent emits the typed event data by its event type, see Emit
+func (b *Bus) EmitEvent(ctx context.Context, data TypedEvent, options ...EmitOption) (*Event, error) {
+	return b.Emit(ctx, data.EventType(), data, options...)
+}
+
+// Publish delivers the event to its sinks concurrently, the ID, Source and Time are filled if empty,
+// the errors of sinks are returned as concurrency.Errors of *SinkError and the other sinks are not affected
+func (b *Bus) Publish(ctx context.Context, event *Event) error {
+	if "" == event.ID {
+		event.ID = utils.GenUUID()
+	}
+	if "" == event.Source {
+		event.Source = b.source
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	sinks := b.Sinks(event.Type)
+	if len(sinks) == 0 {
+		if b.ignoreUnrouted {
+			return nil
+		}
+		return fmt.Errorf("%w: %s", ErrNoSink, event.Type)
+	}
+	if len(sinks) == 1 {
+		if err := sinks[0].Deliver(ctx, event); nil != err {
+			return concurrency.Errors{&SinkError{Sink: sinks[0].Name(), Err: err}}
+		}
+		return nil
+	}
+	g, _ := concurrency.NewGroup(ctx, concurrency.WithGroupMode(concurrency.GroupCollectErrors))
+	for _, sink := range sinks {
+		sink := sink
+		g.Go(func(ctx context.Context) error {
+			if err := sink.Deliver(ctx, event); nil != err {
+				return &SinkError{Sink: sink.Name(), Err: err}
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	if nil == err {
+		return nil
+	}
+	errs := concurrency.Errors{}
+	for _, taskErr := range err.(concurrency.Errors) {
+		errs = append(errs, errors.Unwrap(taskErr))
+	}
+	return errs
+}
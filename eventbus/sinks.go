@@ -0,0 +1,178 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/queues"
+)
+
+// Headers of the events delivered by kafka and webhook sinks
+const (
+	HeaderEventID     = "Event-Id"
+	HeaderEventType   = "Event-Type"
+	HeaderEventSource = "Event-Source"
+)
+
+// funcSink the sink of deliver function
+type funcSink struct {
+	name    string
+	deliver func(ctx context.Context, event *Event) error
+}
+
+// SinkFunc sink delivering the events by fn synchronously, such as the in-process handlers that need the result
+func SinkFunc(name string, fn func(ctx context.Context, event *Event) error) Sink {
+	return &funcSink{name: name, deliver: fn}
+}
+
+// Name of sink
+func (s *funcSink) Name() string {
+	return s.name
+}
+
+// Deliver by the function
+func (s *funcSink) Deliver(ctx context.Context, event *Event) error {
+	return s.deliver(ctx, event)
+}
+
+// eventHeaders the headers of event including the id, type and source
+func eventHeaders(event *Event) map[string]string {
+	headers := make(map[string]string, len(event.Headers)+3)
+	for name, value := range event.Headers {
+		headers[name] = value
+	}
+	headers[HeaderEventID] = event.ID
+	headers[HeaderEventType] = event.Type
+	if "" != event.Source {
+		headers[HeaderEventSource] = event.Source
+	}
+	return headers
+}
+
+// KafkaSink sends the json envelope of events to topic, the events of the same subject are sent to
+// the same partition so that they are consumed in order
+type KafkaSink struct {
+	producer *kafka.Producer
+	topic    string
+}
+
+// NewKafkaSink new sink of kafka topic
+func NewKafkaSink(producer *kafka.Producer, topic string) *KafkaSink {
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+// Name of sink
+func (s *KafkaSink) Name() string {
+	return "kafka:" + s.topic
+}
+
+// Deliver sends the event and waits for the acknowledgement
+func (s *KafkaSink) Deliver(ctx context.Context, event *Event) error {
+	value, err := json.Marshal(event)
+	if nil != err {
+		return err
+	}
+	message := kafka.ProducerMessage{
+		Value:    value,
+		Headers:  eventHeaders(event),
+		Strategy: kafka.PartitionRoundRobin,
+	}
+	if "" != event.Subject {
+		message.Key = []byte(event.Subject)
+		message.Strategy = kafka.PartitionByKey
+	}
+	_, err = s.producer.SendMessageContext(ctx, s.topic, message)
+	return err
+}
+
+// WebhookSink posts the json envelope of events to url by httpclient, the event id is sent as the
+// idempotency key so that the receiver could drop the duplicates of retries
+type WebhookSink struct {
+	url     string
+	retries int
+	options []httpclient.ClientOption
+}
+
+// NewWebhookSink new sink of webhook url, the failed deliveries are retried in the background by httpclient
+// for retries times and the sink reports them succeeded, the failures are returned if retries is 0
+func NewWebhookSink(url string, retries int, options ...httpclient.ClientOption) *WebhookSink {
+	return &WebhookSink{url: url, retries: retries, options: options}
+}
+
+// Name of sink
+func (s *WebhookSink) Name() string {
+	return "webhook:" + s.url
+}
+
+// Deliver posts the event
+func (s *WebhookSink) Deliver(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if nil != err {
+		return err
+	}
+	options := append([]httpclient.ClientOption{
+		httpclient.WithHTTPHeaders(eventHeaders(event)),
+		httpclient.WithIdempotencyKey(event.ID),
+		httpclient.WithTraceContext(ctx),
+		httpclient.WithAllowEmptyResponse(),
+		httpclient.WithSuccessStatusCodes(http.StatusCreated, http.StatusAccepted),
+	}, s.options...)
+	if s.retries > 0 {
+		options = append(options, httpclient.WithRetry(s.retries))
+	}
+	_, err = httpclient.HTTPQuery(http.MethodPost, s.url, bytes.NewReader(body), options...)
+	if nil != err && s.retries > 0 {
+		logger.Warning.Printf("deliver event %s(%s) to webhook %s failed with error:%v, retrying in background", event.Type, event.ID, s.url, err)
+		return nil
+	}
+	return err
+}
+
+// LocalHandler handles the events delivered in the current process
+type LocalHandler func(event *Event)
+
+// LocalSink delivers the events to the handlers subscribed in the current process, every subscription
+// handles its events in its own goroutine in publishing order
+type LocalSink struct {
+	pubsub *queues.PubSub
+}
+
+// NewLocalSink new in-process sink
+func NewLocalSink() *LocalSink {
+	return &LocalSink{pubsub: queues.NewPubSub()}
+}
+
+// Name of sink
+func (s *LocalSink) Name() string {
+	return "local"
+}
+
+// Subscribe handles the events of type by handler, AllEventTypes subscribes every type
+func (s *LocalSink) Subscribe(eventType string, handler LocalHandler, options ...queues.SubscribeOption) (*queues.Subscription, error) {
+	return s.pubsub.Subscribe(eventType, func(msg *queues.Message) {
+		handler(msg.Payload.(*Event))
+	}, options...)
+}
+
+// Deliver buffers the event for the subscriptions of its type and AllEventTypes, it returns without waiting for the handlers
+func (s *LocalSink) Deliver(ctx context.Context, event *Event) error {
+	if _, err := s.pubsub.Publish(ctx, event.Type, event); nil != err {
+		return err
+	}
+	if AllEventTypes != event.Type {
+		if _, err := s.pubsub.Publish(ctx, AllEventTypes, event); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops delivering and waits for the handlers handling the buffered events until ctx done
+func (s *LocalSink) Close(ctx context.Context) error {
+	return s.pubsub.Close(ctx)
+}
@@ -0,0 +1,492 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/cryptoes"
+)
+
+// Constants of webhook dispatcher
+const (
+	HeaderWebhookSignature = "Webhook-Signature"
+	HeaderWebhookDelivery  = "Webhook-Delivery"
+
+	DefaultWebhookRetries  = 3
+	DefaultDeliveryHistory = 1000
+)
+
+// Statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"   // the first attempt is not finished
+	DeliveryRetrying  = "retrying"  // the failed delivery is waiting in the retry queue of httpclient
+	DeliverySucceeded = "succeeded" // the target responded 2xx
+	DeliveryFailed    = "failed"    // all the attempts failed, the delivery is in the dead-letter list
+)
+
+// Errors of webhook dispatcher
+var (
+	ErrWebhookTargetInvalid = errors.New("webhook target without id or url")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+)
+
+// WebhookTarget the url receiving the events of types
+type WebhookTarget struct {
+	ID         string
+	URL        string
+	Secret     []byte            // the payloads are signed by HMAC-SHA256 in the Webhook-Signature header if not empty
+	EventTypes []string          // the event types delivered to the target, AllEventTypes for every type
+	Headers    map[string]string // extra headers of the requests such as the authorization
+}
+
+// accepts the target receives the events of type
+func (t *WebhookTarget) accepts(eventType string) bool {
+	for _, accepted := range t.EventTypes {
+		if accepted == eventType || AllEventTypes == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery the delivery of an event to a webhook target
+type Delivery struct {
+	ID         string
+	TargetID   string
+	URL        string
+	EventID    string
+	EventType  string
+	Status     string
+	Attempts   int    // attempts sent including the retries
+	StatusCode int    // status code of the last attempt, -1 if it failed without response
+	LastError  string // error of the last failed attempt
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	payload    []byte
+}
+
+// WebhookOption options of webhook dispatcher
+type WebhookOption func(*WebhookDispatcher)
+
+// WithWebhookRetries the retries of the failed deliveries by the retry queue of httpclient, DefaultWebhookRetries by default
+func WithWebhookRetries(retries int) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.retries = retries
+	}
+}
+
+// WithWebhookTransport sends the deliveries by rt, http.DefaultTransport by default. The attempts are tracked
+// by wrapping the transport so that the pooled transports and the tls options of httpclient are not used
+func WithWebhookTransport(rt http.RoundTripper) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.transport = rt
+	}
+}
+
+// WithWebhookClientOptions the httpclient options of the deliveries such as httpclient.WithTimeout
+func WithWebhookClientOptions(options ...httpclient.ClientOption) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.options = append(d.options, options...)
+	}
+}
+
+// WithDeliveryHistory the deliveries kept for querying, the oldest finished ones are dropped beyond size,
+// the deliveries in progress and the dead letters are always kept
+func WithDeliveryHistory(size int) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.historySize = size
+	}
+}
+
+// WithDeadLetterHandler calls handler when a delivery failed after all the attempts
+func WithDeadLetterHandler(handler func(delivery Delivery)) WebhookOption {
+	return func(d *WebhookDispatcher) {
+		d.onDeadLetter = handler
+	}
+}
+
+// WebhookDispatcher delivers the events to the webhook targets registered by event type asynchronously,
+// the failed deliveries are retried by httpclient and put into the dead-letter list after all the attempts failed.
+// It is a Sink so that it could be routed by Bus
+type WebhookDispatcher struct {
+	targets      []*WebhookTarget
+	deliveries   map[string]*Delivery
+	history      []string // delivery ids in creation order
+	deadLetters  []string
+	retries      int
+	historySize  int
+	transport    http.RoundTripper
+	options      []httpclient.ClientOption
+	onDeadLetter func(delivery Delivery)
+	m            sync.Mutex
+}
+
+// NewWebhookDispatcher new webhook dispatcher
+func NewWebhookDispatcher(options ...WebhookOption) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		deliveries:  map[string]*Delivery{},
+		retries:     DefaultWebhookRetries,
+		historySize: DefaultDeliveryHistory,
+		transport:   http.DefaultTransport,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// Register adds the target or replaces the one of the same id
+func (d *WebhookDispatcher) Register(target WebhookTarget) error {
+	if "" == target.ID || "" == target.URL {
+		return ErrWebhookTargetInvalid
+	}
+	target.EventTypes = append([]string{}, target.EventTypes...)
+	d.m.Lock()
+	defer d.m.Unlock()
+	for i, t := range d.targets {
+		if t.ID == target.ID {
+			d.targets[i] = &target
+			return nil
+		}
+	}
+	d.targets = append(d.targets, &target)
+	return nil
+}
+
+// Unregister removes the target, the deliveries in progress are not affected
+func (d *WebhookDispatcher) Unregister(targetID string) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for i, t := range d.targets {
+		if t.ID == targetID {
+			d.targets = append(d.targets[:i], d.targets[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Targets the targets receiving the events of type, all the targets if eventType empty
+func (d *WebhookDispatcher) Targets(eventType string) []WebhookTarget {
+	d.m.Lock()
+	defer d.m.Unlock()
+	targets := []WebhookTarget{}
+	for _, t := range d.targets {
+		if "" == eventType || t.accepts(eventType) {
+			targets = append(targets, *t)
+		}
+	}
+	return targets
+}
+
+// Name of sink
+func (d *WebhookDispatcher) Name() string {
+	return "webhooks"
+}
+
+// Deliver dispatches the event as the sink of bus
+func (d *WebhookDispatcher) Deliver(ctx context.Context, event *Event) error {
+	_, err := d.Dispatch(ctx, event)
+	return err
+}
+
+// Dispatch submits the deliveries of event to its targets and returns them without waiting for the responses,
+// the delivery statuses could be queried by Delivery
+func (d *WebhookDispatcher) Dispatch(ctx context.Context, event *Event) ([]Delivery, error) {
+	payload, err := json.Marshal(event)
+	if nil != err {
+		return nil, err
+	}
+	targets := d.Targets(event.Type)
+	deliveries := make([]Delivery, 0, len(targets))
+	for i := range targets {
+		target := &targets[i]
+		now := time.Now()
+		delivery := &Delivery{
+			ID:        utils.GenUUID(),
+			TargetID:  target.ID,
+			URL:       target.URL,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Status:    DeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+			payload:   payload,
+		}
+		d.m.Lock()
+		d.deliveries[delivery.ID] = delivery
+		d.history = append(d.history, delivery.ID)
+		d.evict()
+		deliveries = append(deliveries, *delivery)
+		d.m.Unlock()
+		if err = d.send(delivery, target); nil != err {
+			return deliveries, err
+		}
+	}
+	return deliveries, nil
+}
+
+// Delivery the delivery of id
+func (d *WebhookDispatcher) Delivery(ID string) (Delivery, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	delivery, ok := d.deliveries[ID]
+	if !ok {
+		return Delivery{}, false
+	}
+	return *delivery, true
+}
+
+// Deliveries the deliveries of event, all the deliveries kept if eventID empty
+func (d *WebhookDispatcher) Deliveries(eventID string) []Delivery {
+	d.m.Lock()
+	defer d.m.Unlock()
+	deliveries := []Delivery{}
+	for _, ID := range d.history {
+		delivery := d.deliveries[ID]
+		if "" == eventID || delivery.EventID == eventID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+// DeadLetters the deliveries failed after all the attempts in failing order
+func (d *WebhookDispatcher) DeadLetters() []Delivery {
+	d.m.Lock()
+	defer d.m.Unlock()
+	deliveries := make([]Delivery, len(d.deadLetters))
+	for i, ID := range d.deadLetters {
+		deliveries[i] = *d.deliveries[ID]
+	}
+	return deliveries
+}
+
+// Redeliver sends the dead letter to the url of its target again with the retries
+func (d *WebhookDispatcher) Redeliver(ID string) error {
+	d.m.Lock()
+	delivery, ok := d.deliveries[ID]
+	if !ok || !d.removeDeadLetter(ID) {
+		d.m.Unlock()
+		return ErrDeliveryNotFound
+	}
+	var target *WebhookTarget
+	for _, t := range d.targets {
+		if t.ID == delivery.TargetID {
+			copied := *t
+			target = &copied
+			break
+		}
+	}
+	if nil == target {
+		// the target is unregistered, the delivery is sent to its url without signing
+		target = &WebhookTarget{ID: delivery.TargetID, URL: delivery.URL}
+	}
+	delivery.Status = DeliveryPending
+	delivery.UpdatedAt = time.Now()
+	d.m.Unlock()
+	return d.send(delivery, target)
+}
+
+// DiscardDeadLetter removes the dead letter
+func (d *WebhookDispatcher) DiscardDeadLetter(ID string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if !d.removeDeadLetter(ID) {
+		return ErrDeliveryNotFound
+	}
+	d.evict()
+	return nil
+}
+
+// send submits the delivery to the async pool of httpclient
+func (d *WebhookDispatcher) send(delivery *Delivery, target *WebhookTarget) error {
+	tracker := &deliveryTracker{dispatcher: d, ID: delivery.ID, maxAttempts: int32(d.retries + 1), next: d.transport}
+	headers := map[string]string{
+		HeaderEventID:         delivery.EventID,
+		HeaderEventType:       delivery.EventType,
+		HeaderWebhookDelivery: delivery.ID,
+	}
+	for name, value := range target.Headers {
+		headers[name] = value
+	}
+	// the options of dispatcher are applied first so that they could not replace the tracker
+	options := append([]httpclient.ClientOption{}, d.options...)
+	options = append(options,
+		httpclient.WithHTTPHeaders(headers),
+		httpclient.WithIdempotencyKey(delivery.EventID),
+		httpclient.WithSuccessStatusCodes(http.StatusCreated, http.StatusAccepted, http.StatusNoContent),
+		httpclient.WithRoundTripper(tracker),
+	)
+	if len(target.Secret) > 0 {
+		options = append(options, httpclient.WithRequestInterceptor(webhookSigner(target.Secret)))
+	}
+	if d.retries > 0 {
+		options = append(options, httpclient.WithRetry(d.retries))
+	}
+	err := httpclient.HTTPQueryAsync(http.MethodPost, target.URL, bytes.NewReader(delivery.payload), func(respBody []byte, err error) {
+		if nil != err && atomic.LoadInt32(&tracker.attempts) == 0 {
+			// failed before sending such as signing or rate limiting, it is not retried by httpclient
+			d.record(delivery.ID, 0, -1, err, true)
+		}
+	}, options...)
+	if nil != err {
+		d.record(delivery.ID, 0, -1, err, true)
+	}
+	return err
+}
+
+// record updates the delivery by the result of attempt, the delivery becomes a dead letter if exhausted
+func (d *WebhookDispatcher) record(ID string, attempt int, statusCode int, err error, exhausted bool) {
+	d.m.Lock()
+	delivery, ok := d.deliveries[ID]
+	if !ok {
+		d.m.Unlock()
+		return
+	}
+	if attempt > 0 {
+		delivery.Attempts++
+	}
+	delivery.StatusCode = statusCode
+	delivery.UpdatedAt = time.Now()
+	if nil == err {
+		delivery.Status = DeliverySucceeded
+		delivery.LastError = ""
+		d.m.Unlock()
+		return
+	}
+	delivery.LastError = err.Error()
+	if !exhausted {
+		delivery.Status = DeliveryRetrying
+		d.m.Unlock()
+		return
+	}
+	delivery.Status = DeliveryFailed
+	d.deadLetters = append(d.deadLetters, ID)
+	dead := *delivery
+	handler := d.onDeadLetter
+	d.m.Unlock()
+	logger.Error.Printf("deliver event %s(%s) to webhook %s failed after %d attempts with error:%v", dead.EventType, dead.EventID, dead.URL, dead.Attempts, err)
+	if nil != handler {
+		handler(dead)
+	}
+}
+
+// removeDeadLetter the caller should hold the lock
+func (d *WebhookDispatcher) removeDeadLetter(ID string) bool {
+	for i, deadID := range d.deadLetters {
+		if deadID == ID {
+			d.deadLetters = append(d.deadLetters[:i], d.deadLetters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// evict drops the oldest finished deliveries beyond the history size, the caller should hold the lock
+func (d *WebhookDispatcher) evict() {
+	for i := 0; len(d.history) > d.historySize && i < len(d.history); {
+		delivery := d.deliveries[d.history[i]]
+		if DeliverySucceeded == delivery.Status || (DeliveryFailed == delivery.Status && !d.isDeadLetter(delivery.ID)) {
+			delete(d.deliveries, delivery.ID)
+			d.history = append(d.history[:i], d.history[i+1:]...)
+			continue
+		}
+		i++
+	}
+}
+
+func (d *WebhookDispatcher) isDeadLetter(ID string) bool {
+	for _, deadID := range d.deadLetters {
+		if deadID == ID {
+			return true
+		}
+	}
+	return false
+}
+
+// deliveryTracker records the result of every attempt of the delivery including the retries of httpclient
+type deliveryTracker struct {
+	dispatcher  *WebhookDispatcher
+	ID          string
+	attempts    int32
+	maxAttempts int32
+	next        http.RoundTripper
+}
+
+// RoundTrip sends the request by the next transport and records the result
+func (t *deliveryTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	attempt := atomic.AddInt32(&t.attempts, 1)
+	statusCode := -1
+	failure := err
+	if nil == err {
+		statusCode = resp.StatusCode
+		if statusCode < 200 || statusCode >= 300 {
+			failure = fmt.Errorf("webhook responded status %d", statusCode)
+		}
+	}
+	t.dispatcher.record(t.ID, int(attempt), statusCode, failure, attempt >= t.maxAttempts)
+	return resp, err
+}
+
+// webhookSigner signs the request body as t={timestamp},v1={hex(hmac-sha256("{timestamp}.{body}", secret))}
+func webhookSigner(secret []byte) httpclient.RequestInterceptor {
+	return func(req *http.Request, body []byte) error {
+		req.Header.Set(HeaderWebhookSignature, SignWebhookPayload(secret, body, time.Now().Unix()))
+		return nil
+	}
+}
+
+// SignWebhookPayload the Webhook-Signature header value of payload signed at timestamp in seconds
+func SignWebhookPayload(secret []byte, payload []byte, timestamp int64) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	return "t=" + ts + ",v1=" + cryptoes.HMACSHA256Hex(append([]byte(ts+"."), payload...), secret)
+}
+
+// VerifyWebhookSignature verifies the Webhook-Signature header value of payload for the receivers,
+// the timestamp should be within maxSkew if it is positive
+func VerifyWebhookSignature(secret []byte, payload []byte, signature string, maxSkew time.Duration) error {
+	if "" == signature {
+		return cryptoes.ErrSignatureMissing
+	}
+	ts, sign := "", ""
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			ts = kv[1]
+		case "v1":
+			sign = kv[1]
+		}
+	}
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if nil != err || "" == sign {
+		return cryptoes.ErrSignatureInvalid
+	}
+	if maxSkew > 0 {
+		skew := time.Since(time.Unix(timestamp, 0))
+		if skew > maxSkew || skew < -maxSkew {
+			return cryptoes.ErrSignatureExpired
+		}
+	}
+	if !cryptoes.HMACEqual([]byte(SignWebhookPayload(secret, payload, timestamp)), []byte("t="+ts+",v1="+sign)) {
+		return cryptoes.ErrSignatureInvalid
+	}
+	return nil
+}
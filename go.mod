@@ -14,13 +14,18 @@ require (
 	github.com/graphql-go/graphql v0.8.0
 	github.com/kataras/iris v11.1.1+incompatible
 	github.com/lib/pq v1.10.7
+	github.com/linkedin/goavro/v2 v2.11.1
 	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/nats-io/nats.go v1.20.0
 	github.com/robfig/cron v1.2.0
 	github.com/segmentio/kafka-go v0.4.38
 	github.com/streadway/amqp v1.0.0
 	go.mongodb.org/mongo-driver v1.11.0
 	golang.org/x/crypto v0.2.0
+	golang.org/x/net v0.2.0
+	golang.org/x/sync v0.0.0-20220513210516-0976fa681c29
 	golang.org/x/text v0.4.0
+	google.golang.org/grpc v1.51.0
 	google.golang.org/protobuf v1.28.1
 	gopkg.in/yaml.v2 v2.4.0
 	xorm.io/xorm v1.3.2
@@ -74,7 +79,6 @@ require (
 	github.com/kataras/pio v0.0.10 // indirect
 	github.com/klauspost/compress v1.15.9 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
-	github.com/linkedin/goavro/v2 v2.11.1 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
 	github.com/microcosm-cc/bluemonday v1.0.15 // indirect
@@ -108,13 +112,14 @@ require (
 	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a // indirect
 	github.com/yudai/gojsondiff v1.0.0 // indirect
 	github.com/yudai/golcs v0.0.0-20170316035057-ecda9a501e82 // indirect
+	github.com/nats-io/nkeys v0.3.0 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
-	golang.org/x/net v0.2.0 // indirect
 	golang.org/x/oauth2 v0.0.0-20220411215720-9780585627b5 // indirect
-	golang.org/x/sync v0.0.0-20220513210516-0976fa681c29 // indirect
 	golang.org/x/sys v0.2.0 // indirect
 	golang.org/x/term v0.2.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 	moul.io/http2curl v1.0.0 // indirect
 	xorm.io/builder v0.3.11-0.20220531020008-1bd24a7dc978 // indirect
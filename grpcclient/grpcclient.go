@@ -0,0 +1,186 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Option client connection option
+type Option func(*clientOptions)
+
+type clientOptions struct {
+	tlsOptions         *definations.TLSOptions
+	proxies            *definations.Proxies
+	timeout            time.Duration
+	retry              *utils.RetryPolicy
+	retryCodes         map[codes.Code]bool
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	dialOptions        []grpc.DialOption
+}
+
+// DefaultRetryCodes the status codes retried by WithRetry if no codes specified
+var DefaultRetryCodes = []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted}
+
+// WithTLSOptions connects with tls by tlsOptions, the connection is insecure if tlsOptions disabled
+func WithTLSOptions(tlsOptions *definations.TLSOptions) Option {
+	return func(o *clientOptions) {
+		o.tlsOptions = tlsOptions
+	}
+}
+
+// WithProxies connects through the http or socks5 proxy, the https proxy is used for the tls connections
+func WithProxies(proxies *definations.Proxies) Option {
+	return func(o *clientOptions) {
+		o.proxies = proxies
+	}
+}
+
+// WithTimeout the deadline of the unary calls without deadline in context, including the retries
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry retries the unary calls failed with the status codes by policy, DefaultRetryCodes if codes empty
+func WithRetry(policy utils.RetryPolicy, retryCodes ...codes.Code) Option {
+	return func(o *clientOptions) {
+		o.retry = &policy
+		if len(retryCodes) == 0 {
+			retryCodes = DefaultRetryCodes
+		}
+		o.retryCodes = map[codes.Code]bool{}
+		for _, code := range retryCodes {
+			o.retryCodes[code] = true
+		}
+	}
+}
+
+// WithUnaryInterceptors the unary interceptors called inside the timeout, retry, metrics and tracing ones
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamInterceptors the stream interceptors called inside the metrics and tracing ones
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *clientOptions) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// WithDialOptions the extra grpc dial options
+func WithDialOptions(dialOptions ...grpc.DialOption) Option {
+	return func(o *clientOptions) {
+		o.dialOptions = append(o.dialOptions, dialOptions...)
+	}
+}
+
+// Dial creates the client connection to target, the tls and proxies options are validated here so that the
+// misconfigured connection fails on starting, the connection is established in background as grpc.Dial
+func Dial(target string, options ...Option) (*grpc.ClientConn, error) {
+	return DialContext(context.Background(), target, options...)
+}
+
+// DialContext creates the client connection to target as Dial with ctx
+func DialContext(ctx context.Context, target string, options ...Option) (*grpc.ClientConn, error) {
+	opts := clientOptions{}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	dialOptions, err := opts.grpcDialOptions()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.DialContext(ctx, target, dialOptions...)
+	if err != nil {
+		logger.Error.Printf("Dial grpc target:%s failed with error:%v", target, err)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (opts *clientOptions) grpcDialOptions() ([]grpc.DialOption, error) {
+	dialOptions := []grpc.DialOption{}
+	tlsEnabled := nil != opts.tlsOptions && opts.tlsOptions.Enabled
+	if tlsEnabled {
+		tlsConfig, err := NewTLSConfig(opts.tlsOptions)
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	if nil != opts.proxies {
+		if err := opts.proxies.Validate(); err != nil {
+			logger.Error.Printf("Invalid proxies:%v", err)
+			return nil, err
+		}
+		if opts.proxies.Valid() {
+			proxyURL := opts.proxies.HTTP
+			if tlsEnabled && "" != opts.proxies.HTTPS {
+				proxyURL = opts.proxies.HTTPS
+			}
+			if "" == proxyURL {
+				proxyURL = opts.proxies.GetProxyURL()
+			}
+			dialer, err := newProxyDialer(proxyURL)
+			if err != nil {
+				return nil, err
+			}
+			dialOptions = append(dialOptions, grpc.WithContextDialer(dialer))
+		}
+	}
+	unary := []grpc.UnaryClientInterceptor{tracingUnaryInterceptor, metricsUnaryInterceptor}
+	if opts.timeout > 0 {
+		unary = append(unary, timeoutUnaryInterceptor(opts.timeout))
+	}
+	if nil != opts.retry {
+		unary = append(unary, retryUnaryInterceptor(*opts.retry, opts.retryCodes))
+	}
+	unary = append(unary, opts.unaryInterceptors...)
+	stream := append([]grpc.StreamClientInterceptor{tracingStreamInterceptor, metricsStreamInterceptor}, opts.streamInterceptors...)
+	dialOptions = append(dialOptions, grpc.WithChainUnaryInterceptor(unary...), grpc.WithChainStreamInterceptor(stream...))
+	return append(dialOptions, opts.dialOptions...), nil
+}
+
+// NewTLSConfig client tls config of tlsOptions same as the one of httpclient
+func NewTLSConfig(tlsOptions *definations.TLSOptions) (*tls.Config, error) {
+	if err := tlsOptions.Validate(); err != nil {
+		logger.Error.Printf("Invalid tls options:%v", err)
+		return nil, err
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: tlsOptions.SkipVerify}
+	if "" != tlsOptions.CertFile || "" != tlsOptions.KeyFile {
+		certs, err := tls.LoadX509KeyPair(tlsOptions.CertFile, tlsOptions.KeyFile)
+		if err != nil {
+			logger.Error.Printf("Load tls certificates:%s and %s failed with error:%v", tlsOptions.CertFile, tlsOptions.KeyFile, err)
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certs}
+	}
+	if tlsOptions.CaFile != "" {
+		caData, err := ioutil.ReadFile(tlsOptions.CaFile)
+		if err != nil {
+			logger.Error.Printf("Load tls root CA:%s failed with error:%v", tlsOptions.CaFile, err)
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(caData)
+	}
+	return tlsConfig, nil
+}
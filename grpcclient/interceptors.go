@@ -0,0 +1,103 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/metrics"
+	"github.com/libpub/golib/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys of the ids for log correlation, the lowercase of the http headers
+const (
+	MetadataRequestID = "x-request-id"
+	MetadataTraceID   = "x-trace-id"
+)
+
+var (
+	requestsCounter  = metrics.NewCounter("grpcclient_requests_total", "Calls invoked by grpcclient", "method", "code")
+	requestsDuration = metrics.NewHistogram("grpcclient_request_duration_seconds", "Durations of calls invoked by grpcclient", nil, "method")
+)
+
+// tracingContext propagates the request id and trace id of ctx as the outgoing metadata like
+// httpclient.WithTraceContext
+func tracingContext(ctx context.Context) context.Context {
+	pairs := []string{}
+	if requestID := logger.RequestIDFromContext(ctx); "" != requestID {
+		pairs = append(pairs, MetadataRequestID, requestID)
+	}
+	if traceID := logger.TraceIDFromContext(ctx); "" != traceID {
+		pairs = append(pairs, MetadataTraceID, traceID)
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+func tracingUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(tracingContext(ctx), method, req, reply, cc, opts...)
+}
+
+func tracingStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(tracingContext(ctx), desc, cc, method, opts...)
+}
+
+func metricsUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	startTime := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	requestsDuration.Observe(time.Since(startTime).Seconds(), method)
+	requestsCounter.Inc(method, status.Code(err).String())
+	if err != nil {
+		logger.Error.Printf("grpc call %s failed with error:%v", method, err)
+	}
+	return err
+}
+
+// metricsStreamInterceptor counts the streams opened, the durations of streams are not observed
+func metricsStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	requestsCounter.Inc(method, status.Code(err).String())
+	return stream, err
+}
+
+func timeoutUnaryInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func retryUnaryInterceptor(policy utils.RetryPolicy, retryCodes map[codes.Code]bool) grpc.UnaryClientInterceptor {
+	policy.Retryable = func(err error) bool {
+		return retryCodes[status.Code(err)]
+	}
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		attemptPolicy := policy
+		attemptPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+			logger.Warning.Printf("grpc call %s failed %d times with error:%v, retrying in %v", method, attempt, err, delay)
+		}
+		err := utils.Retry(ctx, attemptPolicy, func() error {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		})
+		// the status of the last attempt is returned so that status.Code works on it
+		var retryErr *utils.RetryError
+		if errors.As(err, &retryErr) {
+			return retryErr.Err
+		}
+		if nil != err && nil != ctx.Err() {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return err
+	}
+}
@@ -0,0 +1,104 @@
+package grpcclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// newProxyDialer dialer connecting through the http proxy by CONNECT or the socks5 proxy
+func newProxyDialer(proxyURL string) (func(ctx context.Context, addr string) (net.Conn, error), error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(u, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		contextDialer, ok := socks.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks5 proxy dialer does not support context")
+		}
+		return func(ctx context.Context, addr string) (net.Conn, error) {
+			return contextDialer.DialContext(ctx, "tcp", addr)
+		}, nil
+	case "http", "https":
+		return func(ctx context.Context, addr string) (net.Conn, error) {
+			return dialHTTPConnect(ctx, u, addr)
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme:%s", u.Scheme)
+}
+
+// dialHTTPConnect tunnels to addr by the CONNECT of the http proxy
+func dialHTTPConnect(ctx context.Context, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if "" == proxyURL.Port() {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "80")
+		if "https" == proxyURL.Scheme {
+			proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "443")
+		}
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if "https" == proxyURL.Scheme {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if nil != proxyURL.User {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if http.StatusOK != resp.StatusCode {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT %s with status:%s", proxyAddr, addr, resp.Status)
+	}
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn conn reading the bytes buffered after the CONNECT response first
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
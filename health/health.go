@@ -0,0 +1,239 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/utils/maputil"
+)
+
+// Constants
+const (
+	DefaultCheckTimeout = 3 * time.Second
+)
+
+// Checker checks a component, returns nil if healthy
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc function as checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Option options of registered checker
+type Option func(*checkOptions)
+
+type checkOptions struct {
+	timeout  time.Duration
+	liveness bool
+}
+
+// WithTimeout the timeout of each check, DefaultCheckTimeout by default
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *checkOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithLiveness includes the checker into the liveness reports, the checkers are only reported by readiness
+// by default, so that the outage of dependencies such as database does not restart the service
+func WithLiveness() Option {
+	return func(o *checkOptions) {
+		o.liveness = true
+	}
+}
+
+// CheckResult result of a checker
+type CheckResult struct {
+	Name       string `json:"name"`
+	Healthy    bool   `json:"healthy"`
+	Message    string `json:"message,omitempty"`
+	DurationMS int64  `json:"durationMs"`
+}
+
+// Report results of the checkers, healthy if all checkers succeed
+type Report struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []CheckResult `json:"checks"`
+	Time    time.Time     `json:"time"`
+}
+
+type registration struct {
+	checker Checker
+	options checkOptions
+}
+
+// Aggregator runs the registered checkers
+type Aggregator struct {
+	m      sync.RWMutex
+	checks map[string]registration
+}
+
+// DefaultAggregator the aggregator which components register to
+var DefaultAggregator = NewAggregator()
+
+// NewAggregator new aggregator without checkers
+func NewAggregator() *Aggregator {
+	return &Aggregator{checks: map[string]registration{}}
+}
+
+// Register the checker by name, the registered checker of the same name is replaced
+func (a *Aggregator) Register(name string, checker Checker, options ...Option) {
+	r := registration{checker: checker, options: checkOptions{timeout: DefaultCheckTimeout}}
+	for _, option := range options {
+		option(&r.options)
+	}
+	a.m.Lock()
+	a.checks[name] = r
+	a.m.Unlock()
+}
+
+// Unregister the checker of name
+func (a *Aggregator) Unregister(name string) {
+	a.m.Lock()
+	delete(a.checks, name)
+	a.m.Unlock()
+}
+
+// Names of the registered checkers in order
+func (a *Aggregator) Names() []string {
+	a.m.RLock()
+	defer a.m.RUnlock()
+	return maputil.SortedKeys(a.checks)
+}
+
+// Liveness runs the checkers registered WithLiveness concurrently
+func (a *Aggregator) Liveness(ctx context.Context) *Report {
+	return a.run(ctx, true)
+}
+
+// Readiness runs all the checkers concurrently
+func (a *Aggregator) Readiness(ctx context.Context) *Report {
+	return a.run(ctx, false)
+}
+
+// LivenessHandler responds the liveness report as json, with status 503 if unhealthy
+func (a *Aggregator) LivenessHandler() http.Handler {
+	return reportHandler(a.Liveness)
+}
+
+// ReadinessHandler responds the readiness report as json, with status 503 if unhealthy
+func (a *Aggregator) ReadinessHandler() http.Handler {
+	return reportHandler(a.Readiness)
+}
+
+func (a *Aggregator) run(ctx context.Context, liveness bool) *Report {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	a.m.RLock()
+	names := make([]string, 0, len(a.checks))
+	checks := make([]registration, 0, len(a.checks))
+	for name, r := range a.checks {
+		if !liveness || r.options.liveness {
+			names = append(names, name)
+			checks = append(checks, r)
+		}
+	}
+	a.m.RUnlock()
+
+	report := &Report{Healthy: true, Checks: make([]CheckResult, len(checks)), Time: time.Now()}
+	wg := sync.WaitGroup{}
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, names[i], checks[i])
+		}(i)
+	}
+	wg.Wait()
+	sort.Slice(report.Checks, func(i, j int) bool {
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+	for _, result := range report.Checks {
+		if !result.Healthy {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+// runCheck waits the checker no longer than its timeout, the checker is left running if timed out
+func runCheck(ctx context.Context, name string, r registration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.options.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); nil != p {
+				done <- fmt.Errorf("checker panic: %v", p)
+			}
+		}()
+		done <- r.checker.Check(ctx)
+	}()
+	result := CheckResult{Name: name, Healthy: true}
+	select {
+	case err := <-done:
+		if nil != err {
+			result.Healthy = false
+			result.Message = err.Error()
+		}
+	case <-ctx.Done():
+		result.Healthy = false
+		result.Message = fmt.Sprintf("check timeout after %v", r.options.timeout)
+	}
+	result.DurationMS = int64(time.Since(start) / time.Millisecond)
+	return result
+}
+
+func reportHandler(run func(ctx context.Context) *Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Register the checker to DefaultAggregator
+func Register(name string, checker Checker, options ...Option) {
+	DefaultAggregator.Register(name, checker, options...)
+}
+
+// Unregister the checker of name from DefaultAggregator
+func Unregister(name string) {
+	DefaultAggregator.Unregister(name)
+}
+
+// Liveness report of DefaultAggregator
+func Liveness(ctx context.Context) *Report {
+	return DefaultAggregator.Liveness(ctx)
+}
+
+// Readiness report of DefaultAggregator
+func Readiness(ctx context.Context) *Report {
+	return DefaultAggregator.Readiness(ctx)
+}
+
+// Serve the liveness and readiness reports of DefaultAggregator on /livez and /readyz of mux,
+// http.DefaultServeMux is used if mux nil
+func Serve(mux *http.ServeMux) {
+	if nil == mux {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle("/livez", DefaultAggregator.LivenessHandler())
+	mux.Handle("/readyz", DefaultAggregator.ReadinessHandler())
+}
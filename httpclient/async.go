@@ -0,0 +1,68 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/libpub/golib/health"
+	"github.com/libpub/golib/utils/concurrency"
+)
+
+// AsyncCallback callback of asynchronous request with the response body and error
+type AsyncCallback func(respBody []byte, err error)
+
+// AsyncWorkers workers of asynchronous requests and retries, it should be configured before the first request
+var AsyncWorkers = 16
+
+var (
+	_asyncPool     *concurrency.Pool
+	_asyncPoolOnce sync.Once
+)
+
+func asyncPool() *concurrency.Pool {
+	_asyncPoolOnce.Do(func() {
+		_asyncPool = concurrency.NewPool(AsyncWorkers, concurrency.WithCapacity(AsyncWorkers*64))
+		health.Register("httpclient:async", health.CheckerFunc(CheckAsyncPool))
+	})
+	return _asyncPool
+}
+
+// HTTPQueryAsync queries in the worker pool and calls back with the result, the body is read before returning,
+// it blocks while too many requests are pending, the callback could be nil
+func HTTPQueryAsync(method string, queryURL string, body io.Reader, callback AsyncCallback, options ...ClientOption) error {
+	var bodyBuffer []byte
+	if nil != body {
+		var err error
+		if bodyBuffer, err = io.ReadAll(body); nil != err {
+			return err
+		}
+	}
+	return asyncPool().Submit(func() {
+		var reader io.Reader
+		if nil != bodyBuffer {
+			reader = bytes.NewReader(bodyBuffer)
+		}
+		respBody, err := HTTPQuery(method, queryURL, reader, options...)
+		if nil != callback {
+			callback(respBody, err)
+		}
+	})
+}
+
+// AsyncStats metrics of the asynchronous requests pool
+func AsyncStats() concurrency.PoolStats {
+	return asyncPool().Stats()
+}
+
+// CheckAsyncPool health checker of the asynchronous requests pool, fails while the pool is full
+// and the asynchronous requests are blocked
+func CheckAsyncPool(ctx context.Context) error {
+	stats := asyncPool().Stats()
+	if stats.Pending >= stats.Capacity {
+		return fmt.Errorf("asynchronous requests pool is full with %d pending requests", stats.Pending)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// httpAuth credentials of the basic or digest authentication, the digest challenge is kept so that
+// the later requests with the same option are authorized without the challenge round trip
+type httpAuth struct {
+	username  string
+	password  string
+	digest    bool
+	m         sync.Mutex
+	challenge map[string]string
+	nc        int
+}
+
+// WithBasicAuth options, authorizes the requests by the basic authentication
+func WithBasicAuth(username, password string) ClientOption {
+	auth := &httpAuth{username: username, password: password}
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.auth = auth
+	})
+}
+
+// WithDigestAuth options, authorizes the requests by the digest authentication of RFC 7616, the request
+// challenged by 401 is sent again with the response to the challenge, the challenge is reused by the later
+// requests of the same option
+func WithDigestAuth(username, password string) ClientOption {
+	auth := &httpAuth{username: username, password: password, digest: true}
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.auth = auth
+	})
+}
+
+// authorize sets the authorization header by the credentials or the known digest challenge
+func (a *httpAuth) authorize(req *http.Request) error {
+	if !a.digest {
+		req.SetBasicAuth(a.username, a.password)
+		return nil
+	}
+	a.m.Lock()
+	defer a.m.Unlock()
+	if nil == a.challenge {
+		return nil
+	}
+	a.nc++
+	authorization, err := digestAuthorization(a.challenge, a.nc, a.username, a.password, req)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	return nil
+}
+
+// challenged the request authorized by the digest challenge of the 401 response, nil if not challenged by digest
+func (a *httpAuth) challenged(req *http.Request, resp *http.Response) (*http.Request, error) {
+	if !a.digest || http.StatusUnauthorized != resp.StatusCode {
+		return nil, nil
+	}
+	challenge := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if nil == challenge {
+		return nil, nil
+	}
+	a.m.Lock()
+	a.challenge = challenge
+	a.nc = 0
+	a.m.Unlock()
+	retry := req.Clone(req.Context())
+	if nil != req.GetBody {
+		body, err := req.GetBody()
+		if nil != err {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	if err := a.authorize(retry); nil != err {
+		return nil, err
+	}
+	return retry, nil
+}
+
+// parseDigestChallenge the parameters of the digest challenge in WWW-Authenticate headers
+func parseDigestChallenge(headers []string) map[string]string {
+	for _, header := range headers {
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+			continue
+		}
+		params := map[string]string{}
+		text := header[7:]
+		for "" != text {
+			text = strings.TrimLeft(text, " ,")
+			eq := strings.IndexByte(text, '=')
+			if eq < 0 {
+				break
+			}
+			key := strings.ToLower(strings.TrimSpace(text[:eq]))
+			text = strings.TrimLeft(text[eq+1:], " ")
+			value := ""
+			if strings.HasPrefix(text, `"`) {
+				end := 1
+				for end < len(text) && '"' != text[end] {
+					if '\\' == text[end] {
+						end++
+					}
+					end++
+				}
+				if end > len(text) {
+					end = len(text)
+				}
+				value = strings.ReplaceAll(text[1:end], `\`, "")
+				text = text[end:]
+				text = strings.TrimPrefix(text, `"`)
+			} else {
+				end := strings.IndexByte(text, ',')
+				if end < 0 {
+					end = len(text)
+				}
+				value = strings.TrimSpace(text[:end])
+				text = text[end:]
+			}
+			params[key] = value
+		}
+		if "" != params["nonce"] {
+			return params
+		}
+	}
+	return nil
+}
+
+// digestAuthorization the authorization header answering the challenge, only the qop auth is supported
+func digestAuthorization(challenge map[string]string, nc int, username string, password string, req *http.Request) (string, error) {
+	algorithm := challenge["algorithm"]
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm:%s", algorithm)
+	}
+	h := func(text string) string {
+		d := newHash()
+		d.Write([]byte(text))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+	qop := ""
+	if "" != challenge["qop"] {
+		for _, q := range strings.Split(challenge["qop"], ",") {
+			if "auth" == strings.TrimSpace(q) {
+				qop = "auth"
+			}
+		}
+		if "" == qop {
+			return "", fmt.Errorf("unsupported digest qop:%s", challenge["qop"])
+		}
+	}
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); nil != err {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+	realm, nonce, uri := challenge["realm"], challenge["nonce"], req.URL.RequestURI()
+	ha1 := h(username + ":" + realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	ncText := fmt.Sprintf("%08x", nc)
+	var response string
+	if "" == qop {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ncText + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, username),
+		fmt.Sprintf(`realm="%s"`, realm),
+		fmt.Sprintf(`nonce="%s"`, nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if "" != algorithm {
+		parts = append(parts, "algorithm="+algorithm)
+	}
+	if "" != challenge["opaque"] {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, challenge["opaque"]))
+	}
+	if "" != qop {
+		parts = append(parts, "qop="+qop, "nc="+ncText, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	return "Digest " + strings.Join(parts, ", "), nil
+}
@@ -0,0 +1,317 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/libpub/golib/utils/maputil"
+)
+
+// HARCreatorName the creator name of the recorded har logs
+const HARCreatorName = "golib-httpclient"
+
+// HAR http archive 1.2 of the recorded requests, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog the log of http archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator the creator of http archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry a request and response pair
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"` // error of the failed request
+}
+
+// HARRequest the recorded request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse the recorded response, the status is 0 if the request failed
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue the header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData the request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent the response body, the binary body is encoded by base64
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings the timings of entry in milliseconds
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder records the request and response pairs as http archive
+type HARRecorder struct {
+	entries []HAREntry
+	m       sync.Mutex
+}
+
+// NewHARRecorder new har recorder
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// WithHARRecorder options, the requests and responses are recorded by recorder, the response bodies are read
+// before returned so it should not be used with streams
+func WithHARRecorder(recorder *HARRecorder) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.harRecorder = recorder
+	})
+}
+
+// WithCurlDump options, the requests are rendered as curl commands and passed to dump before sent
+func WithCurlDump(dump func(command string)) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.curlDump = dump
+	})
+}
+
+// Entries the recorded entries
+func (r *HARRecorder) Entries() []HAREntry {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]HAREntry{}, r.entries...)
+}
+
+// Reset removes the recorded entries
+func (r *HARRecorder) Reset() {
+	r.m.Lock()
+	r.entries = nil
+	r.m.Unlock()
+}
+
+// HAR the http archive of recorded entries
+func (r *HARRecorder) HAR() HAR {
+	entries := r.Entries()
+	if nil == entries {
+		entries = []HAREntry{}
+	}
+	return HAR{Log: HARLog{Version: "1.2", Creator: HARCreator{Name: HARCreatorName, Version: "1.0"}, Entries: entries}}
+}
+
+// WriteTo writes the http archive as json
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if nil != err {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// WriteFile writes the http archive into the .har file
+func (r *HARRecorder) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if nil != err {
+		return err
+	}
+	_, err = r.WriteTo(f)
+	if closeErr := f.Close(); nil == err {
+		err = closeErr
+	}
+	return err
+}
+
+func (r *HARRecorder) record(entry HAREntry) {
+	r.m.Lock()
+	r.entries = append(r.entries, entry)
+	r.m.Unlock()
+}
+
+// CurlCommand renders the request with body as an equivalent curl command
+func CurlCommand(req *http.Request, body []byte) string {
+	parts := []string{"curl", "-X", shellQuote(req.Method), shellQuote(req.URL.String())}
+	for _, name := range maputil.SortedKeys(req.Header) {
+		for _, value := range req.Header[name] {
+			parts = append(parts, "-H", shellQuote(name+": "+value))
+		}
+	}
+	if "" != req.Host && req.Host != req.URL.Host {
+		parts = append(parts, "-H", shellQuote("Host: "+req.Host))
+	}
+	if len(body) > 0 {
+		parts = append(parts, "--data-binary", shellQuote(string(body)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// debugTransport dumps the requests as curl commands and records the round trips by har recorder
+type debugTransport struct {
+	next     http.RoundTripper
+	curlDump func(command string)
+	recorder *HARRecorder
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if nil != req.Body && http.NoBody != req.Body {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if nil != err {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if nil != t.curlDump {
+		t.curlDump(CurlCommand(req, body))
+	}
+	if nil == t.recorder {
+		return t.next.RoundTrip(req)
+	}
+
+	startTime := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	waited := time.Since(startTime)
+	entry := HAREntry{StartedDateTime: startTime, Request: harRequest(req, body)}
+	var respBody []byte
+	if nil == err {
+		respBody, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+		entry.Response = harResponse(resp, respBody)
+	}
+	if nil != err {
+		entry.Comment = err.Error()
+		entry.Response = HARResponse{Cookies: []HARNameValue{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+	}
+	entry.Time = milliseconds(time.Since(startTime))
+	entry.Timings = HARTimings{Wait: milliseconds(waited), Receive: milliseconds(time.Since(startTime) - waited)}
+	t.recorder.record(entry)
+	if nil != err {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func harRequest(req *http.Request, body []byte) HARRequest {
+	r := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	query := req.URL.Query()
+	for _, name := range maputil.SortedKeys(query) {
+		for _, value := range query[name] {
+			r.QueryString = append(r.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+	if len(body) > 0 {
+		r.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return r
+}
+
+func harResponse(resp *http.Response, body []byte) HARResponse {
+	r := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     harHeaders(resp.Header),
+		Content:     HARContent{Size: len(body), MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if utf8.Valid(body) {
+		r.Content.Text = string(body)
+	} else {
+		r.Content.Text = base64.StdEncoding.EncodeToString(body)
+		r.Content.Encoding = "base64"
+	}
+	return r
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	values := []HARNameValue{}
+	for _, name := range maputil.SortedKeys(header) {
+		for _, value := range header[name] {
+			values = append(values, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return values
+}
+
+func harCookies(cookies []*http.Cookie) []HARNameValue {
+	values := make([]HARNameValue, len(cookies))
+	for i, cookie := range cookies {
+		values[i] = HARNameValue{Name: cookie.Name, Value: cookie.Value}
+	}
+	return values
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
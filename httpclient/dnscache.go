@@ -0,0 +1,222 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Constants of dns cache
+const (
+	DefaultDNSCacheTTL      = 30 * time.Second
+	DefaultDNSCacheMaxStale = 5 * time.Minute
+
+	dnsCacheLookupTimeout = 5 * time.Second
+	dnsCacheRetryInterval = time.Second
+)
+
+// ErrNoAddresses no addresses resolved for the host
+var ErrNoAddresses = errors.New("no addresses of host")
+
+// DNSLookupFunc looks up the addresses of host
+type DNSLookupFunc func(ctx context.Context, host string) ([]string, error)
+
+// DNSCache caching resolver of the dialed hosts, the expired addresses are served while refreshing in background
+// and kept being served if the refreshing failed until the max stale elapsed
+type DNSCache struct {
+	ttl      time.Duration
+	maxStale time.Duration
+	lookup   DNSLookupFunc
+	dialer   net.Dialer
+	entries  map[string]*dnsCacheEntry
+	pending  map[string]*dnsLookupCall
+	m        sync.Mutex
+}
+
+type dnsCacheEntry struct {
+	addrs      []string
+	resolvedAt time.Time
+	retryAt    time.Time // the failed refreshing is not retried before
+	refreshing bool
+	next       int
+}
+
+type dnsLookupCall struct {
+	done  chan struct{}
+	addrs []string
+	err   error
+}
+
+var (
+	defaultDNSCache *DNSCache
+	dnsCacheMutex   = sync.RWMutex{}
+)
+
+// NewDNSCache new dns cache keeping the addresses fresh for ttl and serving them stale for maxStale more
+func NewDNSCache(ttl time.Duration, maxStale time.Duration) *DNSCache {
+	if ttl <= 0 {
+		ttl = DefaultDNSCacheTTL
+	}
+	if maxStale < 0 {
+		maxStale = DefaultDNSCacheMaxStale
+	}
+	return &DNSCache{
+		ttl:      ttl,
+		maxStale: maxStale,
+		lookup:   net.DefaultResolver.LookupHost,
+		dialer:   net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		entries:  map[string]*dnsCacheEntry{},
+		pending:  map[string]*dnsLookupCall{},
+	}
+}
+
+// SetDNSCache sets the dns cache of the transports for the requests without dns cache options,
+// nil disables the caching, the pooled transports are reset
+func SetDNSCache(cache *DNSCache) {
+	dnsCacheMutex.Lock()
+	defaultDNSCache = cache
+	dnsCacheMutex.Unlock()
+	ResetTransportPool()
+}
+
+// WithDNSCache options, the hosts are dialed by the addresses resolved by cache
+func WithDNSCache(cache *DNSCache) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.dnsCache = cache
+	})
+}
+
+func (o *httpClientOption) getDNSCache() *DNSCache {
+	if nil != o.dnsCache {
+		return o.dnsCache
+	}
+	dnsCacheMutex.RLock()
+	defer dnsCacheMutex.RUnlock()
+	return defaultDNSCache
+}
+
+// SetLookupFunc replaces the lookup of system resolver
+func (c *DNSCache) SetLookupFunc(lookup DNSLookupFunc) {
+	c.m.Lock()
+	c.lookup = lookup
+	c.m.Unlock()
+}
+
+// LookupHost the cached addresses of host, looked up if not cached or stale for longer than max stale
+func (c *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, _, err := c.lookupHost(ctx, host)
+	return addrs, err
+}
+
+// Invalidate removes the cached addresses of host
+func (c *DNSCache) Invalidate(host string) {
+	c.m.Lock()
+	delete(c.entries, host)
+	c.m.Unlock()
+}
+
+// DialContext dials the resolved addresses of host in round robin until one connected, used as DialContext of transports
+func (c *DNSCache) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if nil != err {
+		return nil, err
+	}
+	addrs, start, err := c.lookupHost(ctx, host)
+	if nil != err {
+		return nil, err
+	}
+	for i := range addrs {
+		var conn net.Conn
+		conn, err = c.dialer.DialContext(ctx, network, net.JoinHostPort(addrs[(start+i)%len(addrs)], port))
+		if nil == err {
+			return conn, nil
+		}
+		if nil != ctx.Err() {
+			break
+		}
+	}
+	return nil, err
+}
+
+// lookupHost returns the addresses and the round robin start of them
+func (c *DNSCache) lookupHost(ctx context.Context, host string) ([]string, int, error) {
+	if nil != net.ParseIP(host) {
+		return []string{host}, 0, nil
+	}
+	c.m.Lock()
+	entry, ok := c.entries[host]
+	if ok {
+		age := time.Since(entry.resolvedAt)
+		if age < c.ttl+c.maxStale {
+			if age >= c.ttl && !entry.refreshing && time.Now().After(entry.retryAt) {
+				entry.refreshing = true
+				go c.refresh(host)
+			}
+			entry.next++
+			addrs, start := entry.addrs, entry.next
+			c.m.Unlock()
+			return addrs, start, nil
+		}
+	}
+	call, ok := c.pending[host]
+	if !ok {
+		call = &dnsLookupCall{done: make(chan struct{})}
+		c.pending[host] = call
+		lookup := c.lookup
+		go func() {
+			lookupCtx, cancel := context.WithTimeout(context.Background(), dnsCacheLookupTimeout)
+			defer cancel()
+			call.addrs, call.err = resolveHost(lookupCtx, lookup, host)
+			c.m.Lock()
+			delete(c.pending, host)
+			if nil == call.err {
+				c.entries[host] = &dnsCacheEntry{addrs: call.addrs, resolvedAt: time.Now()}
+			} else {
+				delete(c.entries, host)
+			}
+			c.m.Unlock()
+			close(call.done)
+		}()
+	}
+	c.m.Unlock()
+	select {
+	case <-call.done:
+		return call.addrs, 0, call.err
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+}
+
+func (c *DNSCache) refresh(host string) {
+	c.m.Lock()
+	lookup := c.lookup
+	c.m.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), dnsCacheLookupTimeout)
+	defer cancel()
+	addrs, err := resolveHost(ctx, lookup, host)
+	c.m.Lock()
+	defer c.m.Unlock()
+	entry, ok := c.entries[host]
+	if !ok {
+		return
+	}
+	entry.refreshing = false
+	if nil != err {
+		entry.retryAt = time.Now().Add(dnsCacheRetryInterval)
+		logs.Warning.Printf("refreshing dns of host:%s failed with error:%v, using the cached addresses", host, err)
+		return
+	}
+	entry.addrs = addrs
+	entry.resolvedAt = time.Now()
+}
+
+func resolveHost(ctx context.Context, lookup DNSLookupFunc, host string) ([]string, error) {
+	addrs, err := lookup(ctx, host)
+	if nil == err && len(addrs) == 0 {
+		err = fmt.Errorf("%w:%s", ErrNoAddresses, host)
+	}
+	return addrs, err
+}
@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants of service endpoints
+const (
+	ServiceURLScheme        = "service"
+	DefaultEndpointCooldown = 10 * time.Second
+)
+
+// ErrNoEndpoints no endpoints resolved for the service url
+var ErrNoEndpoints = errors.New("no endpoints of service")
+
+// EndpointResolver resolves the service name of service://name/path urls into the base urls of endpoints
+// such as http://10.0.0.1:8080
+type EndpointResolver interface {
+	ResolveEndpoints(service string) ([]string, error)
+}
+
+// EndpointResolverFunc function as EndpointResolver
+type EndpointResolverFunc func(service string) ([]string, error)
+
+// ResolveEndpoints calls the function
+func (f EndpointResolverFunc) ResolveEndpoints(service string) ([]string, error) {
+	return f(service)
+}
+
+type endpointBalancer struct {
+	next     int
+	failedAt map[string]time.Time
+}
+
+var (
+	defaultEndpointResolver EndpointResolver
+	endpointBalancers       = map[string]*endpointBalancer{}
+	endpointsMutex          = sync.Mutex{}
+)
+
+// SetEndpointResolver sets the resolver of service urls for the requests without endpoints options
+func SetEndpointResolver(resolver EndpointResolver) {
+	endpointsMutex.Lock()
+	defaultEndpointResolver = resolver
+	endpointsMutex.Unlock()
+}
+
+// WithEndpoints options, the service://name/path urls are sent to the endpoints in round robin
+func WithEndpoints(endpoints ...string) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.endpoints = append(o.endpoints, endpoints...)
+	})
+}
+
+// WithEndpointResolver options, the service://name/path urls are resolved by resolver and sent in round robin
+func WithEndpointResolver(resolver EndpointResolver) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.endpointResolver = resolver
+	})
+}
+
+// IsServiceURL if the url is service://name/path
+func IsServiceURL(queryURL string) bool {
+	return strings.HasPrefix(queryURL, ServiceURLScheme+"://")
+}
+
+// resolveServiceURL replaces the service://name of url with the picked endpoint, returns the url, service and endpoint
+func resolveServiceURL(queryURL string, opts *httpClientOption) (string, string, string, error) {
+	u, err := url.Parse(queryURL)
+	if nil != err {
+		return "", "", "", err
+	}
+	endpoints := opts.endpoints
+	if len(endpoints) == 0 {
+		resolver := opts.endpointResolver
+		if nil == resolver {
+			endpointsMutex.Lock()
+			resolver = defaultEndpointResolver
+			endpointsMutex.Unlock()
+		}
+		if nil != resolver {
+			if endpoints, err = resolver.ResolveEndpoints(u.Host); nil != err {
+				return "", "", "", err
+			}
+		}
+	}
+	if len(endpoints) == 0 {
+		return "", "", "", fmt.Errorf("%w:%s", ErrNoEndpoints, u.Host)
+	}
+	endpoint := pickEndpoint(u.Host, endpoints)
+	return strings.TrimRight(endpoint, "/") + u.RequestURI(), u.Host, endpoint, nil
+}
+
+// pickEndpoint picks the endpoints in round robin, the endpoints failed in cooldown are skipped unless all failed
+func pickEndpoint(service string, endpoints []string) string {
+	endpointsMutex.Lock()
+	defer endpointsMutex.Unlock()
+	b := endpointBalancers[service]
+	if nil == b {
+		b = &endpointBalancer{failedAt: map[string]time.Time{}}
+		endpointBalancers[service] = b
+	}
+	now := time.Now()
+	for i := 0; i < len(endpoints); i++ {
+		endpoint := endpoints[(b.next+i)%len(endpoints)]
+		if failedAt, ok := b.failedAt[endpoint]; ok {
+			if now.Sub(failedAt) < DefaultEndpointCooldown {
+				continue
+			}
+			delete(b.failedAt, endpoint)
+		}
+		b.next = (b.next + i + 1) % len(endpoints)
+		return endpoint
+	}
+	endpoint := endpoints[b.next%len(endpoints)]
+	b.next = (b.next + 1) % len(endpoints)
+	return endpoint
+}
+
+// markEndpointFailed skips the endpoint of service in cooldown
+func markEndpointFailed(service string, endpoint string) {
+	endpointsMutex.Lock()
+	if b := endpointBalancers[service]; nil != b {
+		b.failedAt[endpoint] = time.Now()
+	}
+	endpointsMutex.Unlock()
+}
@@ -0,0 +1,75 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/libpub/golib/config/results"
+	"github.com/libpub/golib/errors"
+)
+
+// envelope results.ResultObject with data kept raw for decoding into the caller's type
+type envelope struct {
+	Code       int                 `json:"code"`
+	Message    string              `json:"message"`
+	Data       json.RawMessage     `json:"data"`
+	RequestSn  interface{}         `json:"requestSn"`
+	Pagination *results.Pagination `json:"pagination"`
+}
+
+// HTTPGetEnvelope requests queryURL with params and parses the results.ResultObject envelope responded, the data
+// of envelope is decoded into data if not nil, and the failed code is returned as results.CodeError matching its
+// category by errors.Is, the envelope is also parsed from the failed http responses
+func HTTPGetEnvelope(queryURL string, params *map[string]string, data interface{}, options ...ClientOption) (*results.ResultObject, error) {
+	resp, err := HTTPGet(queryURL, params, options...)
+	return parseEnvelope(queryURL, resp, err, data)
+}
+
+// HTTPQueryEnvelope requests with method and the json encoded params as body if not nil, and parses the
+// results.ResultObject envelope responded as HTTPGetEnvelope
+func HTTPQueryEnvelope(method string, queryURL string, params interface{}, data interface{}, options ...ClientOption) (*results.ResultObject, error) {
+	var body io.Reader
+	if nil != params {
+		buf, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+		options = append(options, WithHTTPHeader("Content-Type", "application/json"))
+	}
+	resp, err := HTTPQuery(method, queryURL, body, options...)
+	return parseEnvelope(queryURL, resp, err, data)
+}
+
+func parseEnvelope(queryURL string, resp []byte, queryErr error, data interface{}) (*results.ResultObject, error) {
+	if nil != queryErr {
+		resp = errors.ResponseBody(queryErr)
+		if len(resp) == 0 {
+			return nil, queryErr
+		}
+	}
+	e := envelope{}
+	if err := json.Unmarshal(resp, &e); err != nil {
+		if nil != queryErr {
+			return nil, queryErr
+		}
+		logs.Error.Printf("Parsing envelope queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+		return nil, err
+	}
+	result := &results.ResultObject{Code: e.Code, Message: e.Message, RequestSn: e.RequestSn, Pagination: e.Pagination}
+	if err := result.Err(); nil != err {
+		return result, err
+	}
+	if nil != queryErr {
+		return result, queryErr
+	}
+	result.Data = data
+	if nil != data && len(e.Data) > 0 && "null" != string(e.Data) {
+		if err := json.Unmarshal(e.Data, data); err != nil {
+			logs.Error.Printf("Parsing envelope data queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+			return result, err
+		}
+	}
+	return result, nil
+}
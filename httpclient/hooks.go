@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryEvent the failed request scheduled to retry or of which the retries exhausted
+type RetryEvent struct {
+	Method     string
+	URL        string
+	Retries    int           // retries already executed
+	MaxRetries int           // retry times that caller expectes
+	Delay      time.Duration // backoff before the scheduled retry
+	StatusCode int           // -1 if the request failed without response
+	Err        error
+}
+
+// RequestEvent the request finished by HTTPQuery and the helpers based on it, including the retries
+type RequestEvent struct {
+	Method     string
+	URL        string
+	StatusCode int // -1 if the request failed without response
+	Retries    int // retries already executed before the request
+	Duration   time.Duration
+	Err        error
+}
+
+// RetryHook hook of retry events
+type RetryHook func(event RetryEvent)
+
+// RequestHook hook of request events
+type RequestHook func(event RequestEvent)
+
+type httpHooks struct {
+	retryScheduled  []RetryHook
+	retryExhausted  []RetryHook
+	requestFinished []RequestHook
+}
+
+var (
+	hooks      = httpHooks{}
+	hooksMutex = sync.RWMutex{}
+)
+
+// OnRetryScheduled registers hooks called when the failed requests enter the background retry queue,
+// the hooks are called synchronously so they should return quickly
+func OnRetryScheduled(hook ...RetryHook) {
+	hooksMutex.Lock()
+	hooks.retryScheduled = append(hooks.retryScheduled, hook...)
+	hooksMutex.Unlock()
+}
+
+// OnRetryExhausted registers hooks called when the request still failed after the retries of WithRetry
+func OnRetryExhausted(hook ...RetryHook) {
+	hooksMutex.Lock()
+	hooks.retryExhausted = append(hooks.retryExhausted, hook...)
+	hooksMutex.Unlock()
+}
+
+// OnRequestFinished registers hooks called when the requests finished with or without error
+func OnRequestFinished(hook ...RequestHook) {
+	hooksMutex.Lock()
+	hooks.requestFinished = append(hooks.requestFinished, hook...)
+	hooksMutex.Unlock()
+}
+
+// ClearHooks removes all hooks
+func ClearHooks() {
+	hooksMutex.Lock()
+	hooks = httpHooks{}
+	hooksMutex.Unlock()
+}
+
+func getHooks() httpHooks {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+	return hooks
+}
+
+func notifyRetry(retryHooks []RetryHook, event RetryEvent) {
+	for _, hook := range retryHooks {
+		if nil != hook {
+			hook(event)
+		}
+	}
+}
+
+func notifyRequestFinished(requestHooks []RequestHook, event RequestEvent) {
+	for _, hook := range requestHooks {
+		if nil != hook {
+			hook(event)
+		}
+	}
+}
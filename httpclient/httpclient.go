@@ -2,32 +2,54 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/errors"
 	"github.com/libpub/golib/logger"
-	"github.com/libpub/golib/queues"
+	"github.com/libpub/golib/metrics"
+	"github.com/libpub/golib/ratelimit"
+	"github.com/libpub/golib/scheduler"
 	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/cryptoes"
+	"github.com/libpub/golib/utils/timeutil"
 )
 
 // Constants
 const (
 	RetryDurationFactor = 5
+
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	// LoggerModule the logger module of httpclient, the level could be changed at runtime by logger.SetModuleLevel
+	LoggerModule = "httpclient"
 )
 
+// RetryBackoff backoff policy of the failed requests retried by WithRetry
+var RetryBackoff = utils.RetryPolicy{
+	InitialInterval: RetryDurationFactor * time.Second,
+	MaxInterval:     time.Minute,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+// RetryClock the clock of waiting for the retry backoff, the tests could replace it by timeutil.FakeClock
+// before the first retry or after StopRetries
+var RetryClock timeutil.Clock = timeutil.SystemClock
+
 type httpClientOption struct {
 	headers       map[string]string
 	tlsOptions    *definations.TLSOptions
@@ -36,8 +58,24 @@ type httpClientOption struct {
 	retries       int // retry times that already executed
 	shouldRetry   int // retry times that caller expectes
 	successStatus map[int]bool
+	interceptors  []RequestInterceptor
+	rateLimiter   ratelimit.Limiter // keyed by host of request url
+	respHeader    *http.Header
+	auth          *httpAuth
+	dnsCache      *DNSCache
+	harRecorder   *HARRecorder
+	curlDump      func(command string)
+	roundTripper  http.RoundTripper // replaces the pooled transports
+
+	maxResponseBytes int64
+
+	endpoints        []string // endpoints of service://name/path urls
+	endpointResolver EndpointResolver
 }
 
+// RequestInterceptor modifies the request before sending such as signing, body is the request body
+type RequestInterceptor func(req *http.Request, body []byte) error
+
 // ClientOption http client option
 type ClientOption interface {
 	apply(*httpClientOption)
@@ -53,12 +91,15 @@ type transportPoolManager struct {
 }
 
 var (
+	logs       = logger.NewModuleLoggers(LoggerModule)
 	transPool  = transportPoolManager{pool: map[string]*http.Transport{}}
 	bufferPool = sync.Pool{
 		New: func() interface{} {
 			return bytes.NewBuffer(make([]byte, 4096))
 		},
 	}
+	requestsCounter  = metrics.NewCounter("httpclient_requests_total", "Requests queried by httpclient", "method", "host", "status")
+	requestsDuration = metrics.NewHistogram("httpclient_request_duration_seconds", "Durations of requests queried by httpclient until the response headers received", nil, "method", "host")
 )
 
 func (fdo *funcHTTPClientOption) apply(do *httpClientOption) {
@@ -124,6 +165,22 @@ func WithHTTPHeadersEx(headers map[string]interface{}) ClientOption {
 	})
 }
 
+// WithTraceContext propagates the request id and trace id carried by ctx as headers,
+// so that the logs of the called services could be correlated by logger.WithContext
+func WithTraceContext(ctx context.Context) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+			o.headers[logger.HeaderRequestID] = requestID
+		}
+		if traceID := logger.TraceIDFromContext(ctx); traceID != "" {
+			o.headers[logger.HeaderTraceID] = traceID
+		}
+	})
+}
+
 // WithHTTPTLSOptions options
 func WithHTTPTLSOptions(tlsOptions *definations.TLSOptions) ClientOption {
 	return newFuncHTTPClientOption(func(o *httpClientOption) {
@@ -145,13 +202,29 @@ func WithTimeout(timeoutSeconds int) ClientOption {
 	})
 }
 
-// WithRetry options
+// WithRetry options, the failed requests of non-idempotent methods such as POST are retried only WithIdempotencyKey
 func WithRetry(shouldRetryTimes int) ClientOption {
 	return newFuncHTTPClientOption(func(o *httpClientOption) {
 		o.shouldRetry = shouldRetryTimes
 	})
 }
 
+// WithIdempotencyKey options, attaches the Idempotency-Key header generated for each request if key empty,
+// the retries of the failed requests keep the key, the requests of non-idempotent methods such as POST and PATCH
+// are retried by WithRetry only with the key so that the upstream could drop the duplicated side effects
+func WithIdempotencyKey(key string) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		if o.headers == nil {
+			o.headers = make(map[string]string)
+		}
+		requestKey := key
+		if "" == requestKey {
+			requestKey = utils.GenLoweruuid()
+		}
+		o.headers[HeaderIdempotencyKey] = requestKey
+	})
+}
+
 // WithSuccessStatusCodes options
 func WithSuccessStatusCodes(codes ...int) ClientOption {
 	return newFuncHTTPClientOption(func(o *httpClientOption) {
@@ -166,8 +239,70 @@ func WithSuccessStatusCodes(codes ...int) ClientOption {
 	})
 }
 
+// WithAllowEmptyResponse options, the 204 No Content responses are succeeded, it is applied by the json helpers
+// which decode the empty responses as nil results
+func WithAllowEmptyResponse() ClientOption {
+	return WithSuccessStatusCodes(http.StatusNoContent)
+}
+
+// WithRequestInterceptor options, the interceptors are called in order before sending each request
+func WithRequestInterceptor(interceptor RequestInterceptor) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.interceptors = append(o.interceptors, interceptor)
+	})
+}
+
+// WithRequestSigner options, signs the requests with the signature headers of signer
+func WithRequestSigner(signer *cryptoes.RequestSigner) ClientOption {
+	return WithRequestInterceptor(signer.SignRequest)
+}
+
+// WithResponseHeader receives the header of the response into header
+func WithResponseHeader(header *http.Header) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.respHeader = header
+	})
+}
+
+// WithRoundTripper options, the request is sent by rt instead of the pooled transports so that the tls options,
+// proxies and dns cache options are not used, the tests could substitute the transport by RoundTripperFunc
+func WithRoundTripper(rt http.RoundTripper) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.roundTripper = rt
+	})
+}
+
+// WithTransport options, the request is sent by tr instead of the pooled transports
+func WithTransport(tr *http.Transport) ClientOption {
+	if nil == tr {
+		return WithRoundTripper(nil)
+	}
+	return WithRoundTripper(tr)
+}
+
+// RoundTripperFunc function as http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls the function
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithRateLimiter options, the requests wait for the limiter keyed by the host of request url before sending,
+// and fail with ratelimit.ErrLimitExceeded if not allowed within the timeout
+func WithRateLimiter(limiter ratelimit.Limiter) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.rateLimiter = limiter
+	})
+}
+
 // HTTPGet request
 func HTTPGet(queryURL string, params *map[string]string, options ...ClientOption) ([]byte, error) {
+	return HTTPQuery("GET", withQueryParams(queryURL, params), nil, options...)
+}
+
+// withQueryParams appends the encoded params to the query of queryURL
+func withQueryParams(queryURL string, params *map[string]string) string {
 	if params != nil {
 		v := url.Values{}
 		for pk, pv := range *params {
@@ -182,29 +317,37 @@ func HTTPGet(queryURL string, params *map[string]string, options ...ClientOption
 			queryURL = queryURL + sep + urlParams
 		}
 	}
-
-	return HTTPQuery("GET", queryURL, nil, options...)
+	return queryURL
 }
 
-// HTTPGetJSON request and response as json
+// HTTPGetJSON request and response as json, the result is nil if the response is 204 No Content or empty
 func HTTPGetJSON(queryURL string, params *map[string]string, options ...ClientOption) (map[string]interface{}, error) {
+	options = append([]ClientOption{WithAllowEmptyResponse()}, options...)
 	options = append(options, WithHTTPHeader("Content-Type", "application/json"))
 
 	resp, err := HTTPGet(queryURL, params, options...)
 	if err != nil {
 		return nil, err
 	}
+	if isEmptyResponse(resp) {
+		return nil, nil
+	}
 
 	result := map[string]interface{}{}
 	err = json.Unmarshal(resp, &result)
 	if err != nil {
-		logger.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+		logs.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
+// isEmptyResponse the 204 No Content or blank response decoded as nil result by the json helpers
+func isEmptyResponse(resp []byte) bool {
+	return len(bytes.TrimSpace(resp)) == 0
+}
+
 // HTTPGetJSONList request get json value list
 func HTTPGetJSONList(queryURL string, params *map[string]interface{}, options ...ClientOption) ([]byte, error) {
 	if params != nil {
@@ -232,7 +375,7 @@ func HTTPGetJSONList(queryURL string, params *map[string]interface{}, options ..
 			queryURL = queryURL + sep + urlParams
 		}
 	}
-	logger.Trace.Printf("HTTPGetJSONList queryURL: %s", queryURL)
+	logs.Trace.Printf("HTTPGetJSONList queryURL: %s", queryURL)
 	return HTTPQuery("GET", queryURL, nil, options...)
 }
 
@@ -262,86 +405,117 @@ func HTTPURLRequestWithoutBody(method string, queryURL string, params *map[strin
 			queryURL = queryURL + sep + urlParams
 		}
 	}
-	logger.Trace.Printf("HTTPURLRequestWithoutBody queryURL: %s", queryURL)
+	logs.Trace.Printf("HTTPURLRequestWithoutBody queryURL: %s", queryURL)
 	return HTTPQuery(method, queryURL, nil, options...)
 }
 
-// HTTPPostJSON request and response as json
+// HTTPPostJSON request and response as json, the result is nil if the response is 204 No Content or empty
 func HTTPPostJSON(queryURL string, params map[string]interface{}, options ...ClientOption) (map[string]interface{}, error) {
 	body, err := json.Marshal(params)
 	if err != nil {
 		return nil, err
 	}
 
+	options = append([]ClientOption{WithAllowEmptyResponse()}, options...)
 	resp, err := HTTPQuery("POST", queryURL, bytes.NewReader(body), options...)
 	if err != nil {
 		return nil, err
 	}
+	if isEmptyResponse(resp) {
+		return nil, nil
+	}
 
 	result := map[string]interface{}{}
 	err = json.Unmarshal(resp, &result)
 	if err != nil {
-		logger.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+		logs.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
 		return nil, err
 	}
 
 	return result, nil
 }
 
-// HTTPPostJSONEx request and response as json
+// HTTPPostJSONEx request and response as json, the result is left unchanged if the response is 204 No Content or empty
 func HTTPPostJSONEx(queryURL string, params interface{}, result interface{}, options ...ClientOption) error {
 	body, err := json.Marshal(params)
 	if err != nil {
 		return err
 	}
 
+	options = append([]ClientOption{WithAllowEmptyResponse()}, options...)
 	resp, err := HTTPQuery("POST", queryURL, bytes.NewReader(body), options...)
 	if err != nil {
 		return err
 	}
+	if nil == result || isEmptyResponse(resp) {
+		return nil
+	}
 
 	err = json.Unmarshal(resp, result)
 	if err != nil {
-		logger.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+		logs.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
 		return err
 	}
 
 	return nil
 }
 
-// HTTPQuery request
-func HTTPQuery(method string, queryURL string, body io.Reader, options ...ClientOption) ([]byte, error) {
-	req, err := http.NewRequest(method, queryURL, body)
+// HTTPQueryJSON requests with the json encoded body if not nil and decodes the json response into result if
+// not nil, the 201, 202 and 204 responses are also succeeded, it is the base of the typed clients generated by openapigen
+func HTTPQueryJSON(method string, queryURL string, body interface{}, result interface{}, options ...ClientOption) error {
+	options = append([]ClientOption{WithSuccessStatusCodes(http.StatusCreated, http.StatusAccepted, http.StatusNoContent)}, options...)
+	var reader io.Reader
+	if nil != body {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(buf)
+		options = append(options, WithHTTPHeader("Content-Type", "application/json"))
+	}
+	resp, err := HTTPQuery(method, queryURL, reader, options...)
 	if err != nil {
-		logger.Error.Printf("Formatting query %s failed with error:%v", queryURL, err)
-		return nil, err
+		return err
 	}
-	opts := defaultHTTPClientJSONOptions()
-	for _, opt := range options {
-		opt.apply(&opts)
+	if nil == result || isEmptyResponse(resp) {
+		return nil
 	}
-	if opts.headers != nil {
-		for hk, hv := range opts.headers {
-			req.Header.Set(hk, hv)
-		}
+	err = json.Unmarshal(resp, result)
+	if err != nil {
+		logs.Error.Printf("Parsing result queried from url:%s response:%s failed with error:%v", queryURL, string(resp), err)
+		return err
 	}
+	return nil
+}
 
-	tr, err := transPool.get(&opts)
-	if nil != err {
-		return nil, err
-	}
-	client := http.Client{Transport: tr}
-	if opts.timeouts > 0 {
-		client.Timeout = opts.timeouts
+// HTTPQuery request
+func HTTPQuery(method string, queryURL string, body io.Reader, options ...ClientOption) ([]byte, error) {
+	opts := defaultHTTPClientJSONOptions()
+	for _, opt := range options {
+		opt.apply(&opts)
 	}
+	startTime := time.Now()
+	respBody, statusCode, err := doQuery(method, queryURL, body, options, &opts)
+	notifyRequestFinished(getHooks().requestFinished, RequestEvent{
+		Method:     method,
+		URL:        queryURL,
+		StatusCode: statusCode,
+		Retries:    opts.retries,
+		Duration:   time.Since(startTime),
+		Err:        err,
+	})
+	return respBody, err
+}
 
-	// logger.Trace.Printf("querying %s...", queryURL)
-	resp, err := client.Do(req)
+// doQuery queries by opts applied from options and returns the response body and status code
+func doQuery(method string, queryURL string, body io.Reader, options []ClientOption, opts *httpClientOption) ([]byte, int, error) {
+	req, resp, err := sendQuery(context.Background(), method, queryURL, body, opts)
 	if err != nil {
-		logger.Error.Printf("query %s failed with error:%v", queryURL, err)
-		bodyBuffer := getQueryBodyBuffer(queryURL, req.Body)
-		afterQueryFailed(-1, err, []byte(err.Error()), method, queryURL, bodyBuffer, &opts, logger.Error)
-		return nil, err
+		if nil != req {
+			bodyBuffer := getQueryBodyBuffer(queryURL, req.Body)
+			afterQueryFailed(-1, err, []byte(err.Error()), method, queryURL, bodyBuffer, opts, logger.LogLevelError)
+		}
+		return nil, -1, err
 	}
 	defer resp.Body.Close()
 
@@ -349,43 +523,166 @@ func HTTPQuery(method string, queryURL string, body io.Reader, options ...Client
 	buff.Reset()
 	_, err = io.Copy(buff, resp.Body)
 	if nil != err {
-		bufferPool.Put(buff)
+		putBuffer(buff)
 		buff = nil
-		logger.Error.Printf("Read result by queried url:%s failed with error:%v", queryURL, err)
+		logs.Error.Printf("Read result by queried url:%s failed with error:%v", queryURL, err)
+		var tooLarge *ResponseTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, resp.StatusCode, err
+		}
 		bodyBuffer := getQueryBodyBuffer(queryURL, req.Body)
-		afterQueryFailed(resp.StatusCode, err, []byte(err.Error()), method, queryURL, bodyBuffer, &opts, logger.Error)
-		return nil, err
+		afterQueryFailed(resp.StatusCode, err, []byte(err.Error()), method, queryURL, bodyBuffer, opts, logger.LogLevelError)
+		return nil, resp.StatusCode, err
 	}
 	// var respBody []byte
 	respBody := make([]byte, buff.Len())
 	copy(respBody, buff.Bytes())
-	buff.Reset()
-	bufferPool.Put(buff)
+	putBuffer(buff)
 	buff = nil
 	resp.Body = nil // force release the body so that the conn.rawInput should release the buffer grow memory leaks
 
 	if resp.StatusCode != 200 {
 		if nil != opts.successStatus && opts.successStatus[resp.StatusCode] {
-			return respBody, nil
+			return respBody, resp.StatusCode, nil
 		}
 		if resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusFound {
 			newLocation := resp.Header.Get("location")
-			logger.Info.Printf("query %s while got status:%d for location:%s", queryURL, resp.StatusCode, newLocation)
+			logs.Info.Printf("query %s while got status:%d for location:%s", queryURL, resp.StatusCode, newLocation)
 			if "" != newLocation {
-				return HTTPQuery(method, newLocation, body, options...)
+				respBody, err = HTTPQuery(method, newLocation, body, options...)
+				return respBody, resp.StatusCode, err
 			}
 		}
-		err = errors.New(resp.Status)
+		err = errors.FromResponse(resp.StatusCode, resp.Status, respBody)
 		bodyBuffer := getQueryBodyBuffer(queryURL, req.Body)
-		afterQueryFailed(resp.StatusCode, err, respBody, method, queryURL, bodyBuffer, &opts, logger.Warning)
-		return respBody, err
+		afterQueryFailed(resp.StatusCode, err, respBody, method, queryURL, bodyBuffer, opts, logger.LogLevelWarning)
+		return respBody, resp.StatusCode, err
 	}
 
 	if opts.retries > 0 {
-		logger.Info.Printf("query %s with method:%s succeed with %d retries", queryURL, method, opts.retries)
+		logs.Info.Printf("query %s with method:%s succeed with %d retries", queryURL, method, opts.retries)
+	}
+
+	return respBody, resp.StatusCode, nil
+}
+
+// sendQuery sends the request built by opts and returns the response of which the body is not read yet,
+// the request is returned with the error if it has been sent so that the failure could be retried
+func sendQuery(ctx context.Context, method string, queryURL string, body io.Reader, opts *httpClientOption) (*http.Request, *http.Response, error) {
+	var bodyBytes []byte
+	if (len(opts.interceptors) > 0 || (nil != opts.auth && opts.auth.digest)) && nil != body {
+		// interceptors need the whole body such as calculating signature, and the digest challenged request is sent again
+		var err error
+		if bodyBytes, err = ioutil.ReadAll(body); err != nil {
+			logs.Error.Printf("Reading body of query %s failed with error:%v", queryURL, err)
+			return nil, nil, err
+		}
+		body = bytes.NewReader(bodyBytes)
+	}
+	requestURL := queryURL
+	service, endpoint := "", ""
+	if IsServiceURL(queryURL) {
+		// the original service url is kept for retrying so that it is resolved again
+		var err error
+		if requestURL, service, endpoint, err = resolveServiceURL(queryURL, opts); err != nil {
+			logs.Error.Printf("Resolving query %s failed with error:%v", queryURL, err)
+			return nil, nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		logs.Error.Printf("Formatting query %s failed with error:%v", queryURL, err)
+		return nil, nil, err
+	}
+	if opts.headers != nil {
+		for hk, hv := range opts.headers {
+			req.Header.Set(hk, hv)
+		}
+	}
+	if nil != opts.auth {
+		if err = opts.auth.authorize(req); err != nil {
+			logs.Error.Printf("Authorizing query %s failed with error:%v", queryURL, err)
+			return nil, nil, err
+		}
+	}
+	for _, interceptor := range opts.interceptors {
+		if err = interceptor(req, bodyBytes); err != nil {
+			logs.Error.Printf("Intercepting query %s failed with error:%v", queryURL, err)
+			return nil, nil, err
+		}
+	}
+
+	if nil != opts.rateLimiter {
+		if err = waitRateLimit(req, opts); err != nil {
+			logs.Warning.Printf("query %s was rate limited with error:%v", requestURL, err)
+			return nil, nil, err
+		}
+	}
+
+	tr := opts.roundTripper
+	if nil == tr {
+		if tr, err = transPool.get(opts); nil != err {
+			return nil, nil, err
+		}
+	}
+	client := http.Client{Transport: tr}
+	if nil != opts.curlDump || nil != opts.harRecorder {
+		client.Transport = &debugTransport{next: tr, curlDump: opts.curlDump, recorder: opts.harRecorder}
+	}
+	if opts.timeouts > 0 {
+		client.Timeout = opts.timeouts
+	}
+
+	// logs.Trace.Printf("querying %s...", queryURL)
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	if nil == err && nil != opts.auth {
+		var retry *http.Request
+		if retry, err = opts.auth.challenged(req, resp); nil != retry {
+			resp.Body.Close()
+			req = retry
+			resp, err = client.Do(req)
+		} else if nil != err {
+			resp.Body.Close()
+		}
 	}
+	requestsDuration.Observe(time.Since(startTime).Seconds(), method, req.URL.Host)
+	if err != nil {
+		requestsCounter.Inc(method, req.URL.Host, "error")
+		if errors.Timeout == errors.CategoryOf(err) {
+			err = errors.Wrap(err, errors.Timeout, "")
+		}
+		logs.Error.Printf("query %s failed with error:%v", requestURL, err)
+		if "" != endpoint {
+			markEndpointFailed(service, endpoint)
+		}
+		return req, nil, err
+	}
+	requestsCounter.Inc(method, req.URL.Host, strconv.Itoa(resp.StatusCode))
+	if nil != opts.respHeader {
+		*opts.respHeader = resp.Header
+	}
+	if opts.maxResponseBytes > 0 {
+		if resp.ContentLength > opts.maxResponseBytes {
+			resp.Body.Close()
+			err = &ResponseTooLargeError{URL: requestURL, Limit: opts.maxResponseBytes}
+			logs.Error.Printf("query %s failed with error:%v", requestURL, err)
+			return nil, nil, err
+		}
+		resp.Body = newLimitedBody(resp.Body, requestURL, opts.maxResponseBytes)
+	}
+	return req, resp, nil
+}
 
-	return respBody, nil
+// waitRateLimit waits for the rate limiter no longer than the request timeout
+func waitRateLimit(req *http.Request, opts *httpClientOption) error {
+	ctx := context.Background()
+	if opts.timeouts > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeouts)
+		defer cancel()
+	}
+	return ratelimit.Wait(ctx, opts.rateLimiter, req.URL.Host)
 }
 
 func getQueryBodyBuffer(url string, body io.Reader) []byte {
@@ -396,118 +693,150 @@ func getQueryBodyBuffer(url string, body io.Reader) []byte {
 		buff.Reset()
 		_, err = io.Copy(buff, body)
 		if nil != err {
-			logger.Error.Output(2, fmt.Sprintf("query %s failed and read request body failed with error:%v", url, err))
+			logs.Output(logger.LogLevelError, 2, fmt.Sprintf("query %s failed and read request body failed with error:%v", url, err))
 		} else {
 			result = make([]byte, buff.Len())
 			copy(result, buff.Bytes())
 		}
-		buff.Reset()
-		bufferPool.Put(buff)
+		putBuffer(buff)
 	}
 	return result
 }
 
-func afterQueryFailed(respStatusCode int, err error, respBody []byte, method string, queryURL string, body []byte, opts *httpClientOption, failureLogger *log.Logger) {
-	failureLogger.Output(2, fmt.Sprintf("Error: query %s failed with error(code:%d):%v body:%s", queryURL, respStatusCode, err, string(respBody)))
+func afterQueryFailed(respStatusCode int, err error, respBody []byte, method string, queryURL string, body []byte, opts *httpClientOption, failureLevel logger.LogLevel) {
+	logs.Output(failureLevel, 2, fmt.Sprintf("Error: query %s failed with error(code:%d):%v body:%s", queryURL, respStatusCode, err, string(respBody)))
+	if opts.shouldRetry > 0 && !opts.retryAllowed(method) {
+		logs.Warning.Printf("query %s with method:%s is not retried without %s header", queryURL, method, HeaderIdempotencyKey)
+		return
+	}
 	if opts.shouldRetry > 0 {
+		event := RetryEvent{
+			Method:     method,
+			URL:        queryURL,
+			Retries:    opts.retries,
+			MaxRetries: opts.shouldRetry,
+			StatusCode: respStatusCode,
+			Err:        err,
+		}
 		if opts.retries >= opts.shouldRetry {
-			logger.Error.Printf("query %s failed with %d retries, skip retring", queryURL, opts.retries)
+			logs.Error.Printf("query %s failed with %d retries, skip retring", queryURL, opts.retries)
+			notifyRetry(getHooks().retryExhausted, event)
 			return
 		}
-		retryDuration := time.Second * time.Duration(RetryDurationFactor) * time.Duration(opts.retries+1)
-		now := time.Now()
-		now.Add(retryDuration)
 		re := &requestEntity{
-			method:           method,
-			url:              queryURL,
-			body:             body,
-			options:          *opts,
-			triggerTimestamp: now.Unix() + formatRetryDuration(opts.retries),
+			method:  method,
+			url:     queryURL,
+			body:    body,
+			options: opts.clone(),
 		}
-		_pendingRequestsQueue.Push(re)
-		if nil == _pendingRequestsTimer {
-			go pendingRequestsTimer()
+		// the scheduler only waits for the backoff, the retries run in the bounded async pool
+		name := "httpclient-retry-" + utils.GenSnowflakeIDString()
+		event.Delay = RetryBackoff.Backoff(opts.retries)
+		err = retryScheduler().AddOnce(name, RetryClock.Now().Add(event.Delay), func(ctx context.Context) error {
+			return asyncPool().SubmitContext(ctx, re.retry)
+		})
+		if nil != err {
+			logs.Error.Printf("schedule retrying query %s failed with error:%v", queryURL, err)
+			return
 		}
+		notifyRetry(getHooks().retryScheduled, event)
 	}
 }
 
-func formatRetryDuration(retries int) int64 {
-	if retries < 3 {
-		return RetryDurationFactor
+// retryAllowed the requests of idempotent methods or with the idempotency key could be retried
+func (o *httpClientOption) retryAllowed(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	for name, value := range o.headers {
+		if HeaderIdempotencyKey == http.CanonicalHeaderKey(name) && "" != value {
+			return true
+		}
 	}
-	return int64(RetryDurationFactor * retries)
+	return false
 }
 
-func pendingRequestsTimer() {
-	if nil != _pendingRequestsTimer {
-		return
-	}
-	_pendingRequestsTimer = time.NewTicker(1 * time.Second)
-	for nil != _pendingRequestsTimer {
-		select {
-		case tim := <-_pendingRequestsTimer.C:
-			var ok = true
-			var item interface{}
-			now := tim.Unix()
-			for ok {
-				item, ok = _pendingRequestsQueue.Pop()
-				if ok {
-					ok = checkRetryEntity(item, now)
-				}
-			}
-			break
-		}
+func retryScheduler() *scheduler.Scheduler {
+	_retrySchedulerMutex.Lock()
+	defer _retrySchedulerMutex.Unlock()
+	if nil == _retryScheduler {
+		_retryScheduler = scheduler.NewScheduler(scheduler.WithClock(RetryClock))
+		_retryScheduler.Start()
 	}
+	return _retryScheduler
 }
 
-func checkRetryEntity(item interface{}, tim int64) bool {
-	re, ok := item.(*requestEntity)
-	if false == ok {
-		logger.Error.Printf("check retry http request entity while convert the element into request entity failed")
-		return true
+// StopRetries drops the pending retries and waits for the retries being submitted until ctx done,
+// the retries of later failed requests are scheduled again
+func StopRetries(ctx context.Context) error {
+	_retrySchedulerMutex.Lock()
+	s := _retryScheduler
+	_retryScheduler = nil
+	_retrySchedulerMutex.Unlock()
+	if nil == s {
+		return nil
 	}
-	if re.triggerTimestamp <= tim {
-		// do request
-		opts := newFuncHTTPClientOption(func(o *httpClientOption) {
-			o.headers = re.options.headers
-			o.proxies = re.options.proxies
-			o.retries = re.options.retries + 1
-			o.shouldRetry = re.options.shouldRetry
-			o.timeouts = re.options.timeouts
-			o.tlsOptions = re.options.tlsOptions
-		})
-		logger.Info.Printf("retrying http request %s with method:%s ...", re.url, re.method)
-		HTTPQuery(re.method, re.url, bytes.NewReader(re.body), opts)
-		return true
+	return s.StopContext(ctx)
+}
+
+// PendingRetries count of the retries waiting for their backoff
+func PendingRetries() int {
+	_retrySchedulerMutex.Lock()
+	s := _retryScheduler
+	_retrySchedulerMutex.Unlock()
+	if nil == s {
+		return 0
 	}
-	_pendingRequestsQueue.Push(re)
-	return false
+	return len(s.Jobs())
+}
+
+func (re *requestEntity) retry() {
+	opts := newFuncHTTPClientOption(func(o *httpClientOption) {
+		*o = re.options.clone()
+		o.retries = re.options.retries + 1
+		// the caller has returned, the response header of the retry is not written back to it
+		o.respHeader = nil
+	})
+	logs.Info.Printf("retrying http request %s with method:%s ...", re.url, re.method)
+	HTTPQuery(re.method, re.url, bytes.NewReader(re.body), opts)
+}
+
+// clone the options kept for retrying, the headers, status, endpoints, tls options and proxies are copied deeply
+// so that the later changes of caller are not visible, the interceptors, endpoint resolver, auth, dns cache, debug recorders and round tripper are shared
+func (o *httpClientOption) clone() httpClientOption {
+	c := *o
+	utils.DeepCopy(&c.headers, o.headers)
+	utils.DeepCopy(&c.successStatus, o.successStatus)
+	utils.DeepCopy(&c.endpoints, o.endpoints)
+	utils.DeepCopy(&c.tlsOptions, o.tlsOptions)
+	utils.DeepCopy(&c.proxies, o.proxies)
+	c.interceptors = append([]RequestInterceptor{}, o.interceptors...)
+	return c
 }
 
 type requestEntity struct {
-	method           string
-	url              string
-	body             []byte
-	options          httpClientOption
-	triggerTimestamp int64
+	method  string
+	url     string
+	body    []byte
+	options httpClientOption
 }
 
 var (
-	_pendingRequestsQueue              = queues.NewAscOrderingQueue()
-	_pendingRequestsTimer *time.Ticker = nil
+	_retryScheduler      *scheduler.Scheduler
+	_retrySchedulerMutex sync.Mutex
 )
 
-func (r *requestEntity) GetID() string {
-	return r.url
-}
-func (r *requestEntity) GetName() string {
-	return r.url
-}
-func (r *requestEntity) OrderingValue() int64 {
-	return r.triggerTimestamp
-}
-func (r *requestEntity) DebugString() string {
-	return r.url
+// ResetTransportPool closes the idle connections of pooled transports and removes them,
+// the transports are created again by the options of later requests such as the reloaded tls or proxies
+func ResetTransportPool() {
+	transPool.mu.Lock()
+	pool := transPool.pool
+	transPool.pool = map[string]*http.Transport{}
+	transPool.mu.Unlock()
+	for _, tr := range pool {
+		tr.CloseIdleConnections()
+	}
 }
 
 func (p *transportPoolManager) get(opts *httpClientOption) (*http.Transport, error) {
@@ -523,6 +852,9 @@ func (p *transportPoolManager) get(opts *httpClientOption) (*http.Transport, err
 	if opts.proxies != nil && opts.proxies.Valid() {
 		key = key + "-" + opts.proxies.GetProxyURL()
 	}
+	if dnsCache := opts.getDNSCache(); nil != dnsCache {
+		key = fmt.Sprintf("%s-dns-%p", key, dnsCache)
+	}
 	p.mu.RLock()
 	tr, _ := p.pool[key]
 	p.mu.RUnlock()
@@ -533,12 +865,24 @@ func (p *transportPoolManager) get(opts *httpClientOption) (*http.Transport, err
 }
 
 func (p *transportPoolManager) set(key string, opts *httpClientOption) (*http.Transport, error) {
+	if opts.tlsOptions != nil {
+		if err := opts.tlsOptions.Validate(); err != nil {
+			logs.Error.Printf("Invalid tls options:%v", err)
+			return nil, err
+		}
+	}
+	if opts.proxies != nil {
+		if err := opts.proxies.Validate(); err != nil {
+			logs.Error.Printf("Invalid proxies:%v", err)
+			return nil, err
+		}
+	}
 	tlsConfig := tls.Config{InsecureSkipVerify: true}
 	if opts.tlsOptions != nil && opts.tlsOptions.Enabled {
 		if "" != opts.tlsOptions.CertFile || "" != opts.tlsOptions.KeyFile {
 			certs, err := tls.LoadX509KeyPair(opts.tlsOptions.CertFile, opts.tlsOptions.KeyFile)
 			if err != nil {
-				logger.Error.Printf("Load tls certificates:%s and %s failed with error:%v", opts.tlsOptions.CertFile, opts.tlsOptions.KeyFile, err)
+				logs.Error.Printf("Load tls certificates:%s and %s failed with error:%v", opts.tlsOptions.CertFile, opts.tlsOptions.KeyFile, err)
 				return nil, err
 			}
 			tlsConfig.Certificates = []tls.Certificate{certs}
@@ -546,7 +890,7 @@ func (p *transportPoolManager) set(key string, opts *httpClientOption) (*http.Tr
 
 		// ca, err := x509.ParseCertificate(certs.Certificate[0])
 		// if err != nil {
-		// 	logger.Error.Printf("Parse certificate faield with error:%v", err)
+		// 	logs.Error.Printf("Parse certificate faield with error:%v", err)
 		// } else {
 		// 	caPool.AddCert(ca)
 		// }
@@ -554,7 +898,7 @@ func (p *transportPoolManager) set(key string, opts *httpClientOption) (*http.Tr
 		if opts.tlsOptions.CaFile != "" {
 			caData, err := ioutil.ReadFile(opts.tlsOptions.CaFile)
 			if err != nil {
-				logger.Error.Printf("Load tls root CA:%s failed with error:%v", opts.tlsOptions.CaFile, err)
+				logs.Error.Printf("Load tls root CA:%s failed with error:%v", opts.tlsOptions.CaFile, err)
 				return nil, err
 			}
 			tlsConfig.RootCAs = x509.NewCertPool()
@@ -567,7 +911,7 @@ func (p *transportPoolManager) set(key string, opts *httpClientOption) (*http.Tr
 		// DEBUG for tls ca verify
 		// tlsConfig.ServerName = "10.248.100.227"
 		// req.Host = "10.248.100.227"
-		// logger.Info.Printf("loaded tls certificates:%s and %s", opts.tlsOptions.CertFile, opts.tlsOptions.KeyFile)
+		// logs.Info.Printf("loaded tls certificates:%s and %s", opts.tlsOptions.CertFile, opts.tlsOptions.KeyFile)
 	}
 	tr := &http.Transport{
 		TLSClientConfig: &tlsConfig,
@@ -576,10 +920,13 @@ func (p *transportPoolManager) set(key string, opts *httpClientOption) (*http.Tr
 		proxyURL, _ := url.Parse(opts.proxies.GetProxyURL())
 		tr.Proxy = http.ProxyURL(proxyURL)
 	}
+	if dnsCache := opts.getDNSCache(); nil != dnsCache {
+		tr.DialContext = dnsCache.DialContext
+	}
 
 	p.mu.Lock()
-	if logger.IsDebugEnabled() {
-		logger.Debug.Printf("put http transport by key %s", key)
+	if logs.IsDebugEnabled() {
+		logs.Debug.Printf("put http transport by key %s", key)
 	}
 	p.pool[key] = tr
 	p.mu.Unlock()
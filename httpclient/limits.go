@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// maxPooledBufferSize buffers grown larger than it are not put back to the pool so that a large response
+// does not hold the memory after returned
+const maxPooledBufferSize = 1 << 20
+
+// ResponseTooLargeError the response body exceeds the limit of WithMaxResponseBytes
+type ResponseTooLargeError struct {
+	URL   string
+	Limit int64
+}
+
+// Error the error message
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response of %s exceeds the limit of %d bytes", e.URL, e.Limit)
+}
+
+// WithMaxResponseBytes options, the reading of the response body larger than limit is aborted with
+// ResponseTooLargeError, the responses are not limited if limit is 0
+func WithMaxResponseBytes(limit int64) ClientOption {
+	return newFuncHTTPClientOption(func(o *httpClientOption) {
+		o.maxResponseBytes = limit
+	})
+}
+
+// limitedBody reads the body until the limit exceeded
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func newLimitedBody(body io.ReadCloser, queryURL string, limit int64) *limitedBody {
+	return &limitedBody{ReadCloser: body, remaining: limit, err: &ResponseTooLargeError{URL: queryURL, Limit: limit}}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, b.err
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// putBuffer puts the buffer back to the pool unless it grows too large
+func putBuffer(buff *bytes.Buffer) {
+	if buff.Cap() > maxPooledBufferSize {
+		return
+	}
+	buff.Reset()
+	bufferPool.Put(buff)
+}
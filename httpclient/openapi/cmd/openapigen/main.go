@@ -0,0 +1,45 @@
+// Command openapigen generates the typed client of an OpenAPI 3 spec built on httpclient, such as
+//
+//	//go:generate go run github.com/libpub/golib/httpclient/openapi/cmd/openapigen -spec petstore.yaml -package petstore -out client_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/libpub/golib/httpclient/openapi"
+)
+
+func main() {
+	specFile := flag.String("spec", "", "the OpenAPI 3 spec file in json or yaml")
+	packageName := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated client, $GOPACKAGE by go generate")
+	outFile := flag.String("out", "client_gen.go", "the generated source file")
+	flag.Parse()
+	if "" == *specFile || "" == *packageName {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := generate(*specFile, *packageName, *outFile); err != nil {
+		fmt.Fprintf(os.Stderr, "openapigen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func generate(specFile string, packageName string, outFile string) error {
+	data, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return err
+	}
+	spec, err := openapi.ParseSpec(data)
+	if err != nil {
+		return err
+	}
+	src, err := openapi.Generate(spec, openapi.GenerateOptions{Package: packageName, Source: filepath.Base(specFile)})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(outFile, src, 0644)
+}
@@ -0,0 +1,120 @@
+// Code generated by openapigen from petstore.yaml. DO NOT EDIT.
+
+package petstore
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/libpub/golib/httpclient"
+)
+
+// Client typed client of Petstore 1.0.0
+type Client struct {
+	BaseURL string
+	Options []httpclient.ClientOption // applied to every request before the options of the call
+}
+
+// NewClient client requesting baseURL with options
+func NewClient(baseURL string, options ...httpclient.ClientOption) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Options: options}
+}
+
+func (c *Client) options(options []httpclient.ClientOption) []httpclient.ClientOption {
+	return append(append([]httpclient.ClientOption{}, c.Options...), options...)
+}
+
+// NewPet generated from the openapi spec
+type NewPet struct {
+	Name   string `json:"name"`
+	Status Status `json:"status,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+}
+
+// PetOwner generated from the openapi spec
+type PetOwner struct {
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// Pet generated from the openapi spec
+type Pet struct {
+	ID     int64             `json:"id"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Name   string            `json:"name"`
+	Owner  *PetOwner         `json:"owner,omitempty"`
+	Status Status            `json:"status,omitempty"`
+	Tag    string            `json:"tag,omitempty"`
+}
+
+// Status generated from the openapi spec
+type Status string
+
+// Values of Status
+const (
+	StatusAvailable Status = "available"
+	StatusSold      Status = "sold"
+)
+
+// ListPetsParams query and header parameters of GET /pets
+type ListPetsParams struct {
+	// max pets returned
+	Limit   int32
+	Tags    []string
+	XTenant string
+}
+
+func (p *ListPetsParams) apply(queryURL string, options []httpclient.ClientOption) (string, []httpclient.ClientOption) {
+	query := url.Values{}
+	if 0 != p.Limit {
+		query.Set("limit", fmt.Sprint(p.Limit))
+	}
+	for _, v := range p.Tags {
+		query.Add("tags", fmt.Sprint(v))
+	}
+	if "" != p.XTenant {
+		options = append(options, httpclient.WithHTTPHeader("X-Tenant", fmt.Sprint(p.XTenant)))
+	}
+	if encoded := query.Encode(); "" != encoded {
+		queryURL += "?" + encoded
+	}
+	return queryURL, options
+}
+
+// ListPets List the pets
+func (c *Client) ListPets(params *ListPetsParams, options ...httpclient.ClientOption) ([]Pet, error) {
+	queryURL := c.BaseURL + "/pets"
+	if nil != params {
+		queryURL, options = params.apply(queryURL, options)
+	}
+	var result []Pet
+	err := httpclient.HTTPQueryJSON("GET", queryURL, nil, &result, c.options(options)...)
+	return result, err
+}
+
+// CreatePet Create a pet
+func (c *Client) CreatePet(body *NewPet, options ...httpclient.ClientOption) (*Pet, error) {
+	queryURL := c.BaseURL + "/pets"
+	result := new(Pet)
+	if err := httpclient.HTTPQueryJSON("POST", queryURL, body, result, c.options(options)...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetPet Get a pet by id
+func (c *Client) GetPet(petID int64, options ...httpclient.ClientOption) (*Pet, error) {
+	queryURL := c.BaseURL + "/pets/" + url.PathEscape(fmt.Sprint(petID))
+	result := new(Pet)
+	if err := httpclient.HTTPQueryJSON("GET", queryURL, nil, result, c.options(options)...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DeletePetsByPetID Delete a pet
+func (c *Client) DeletePetsByPetID(petID int64, options ...httpclient.ClientOption) error {
+	queryURL := c.BaseURL + "/pets/" + url.PathEscape(fmt.Sprint(petID))
+	return httpclient.HTTPQueryJSON("DELETE", queryURL, nil, nil, c.options(options)...)
+}
@@ -0,0 +1,4 @@
+// Package petstore the typed client generated from petstore.yaml as the example of openapigen
+package petstore
+
+//go:generate go run ../../cmd/openapigen -spec petstore.yaml -package petstore -out client_gen.go
@@ -0,0 +1,540 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateOptions options of the generated source
+type GenerateOptions struct {
+	Package string // package name of the generated source
+	Source  string // the spec file name mentioned in the header comment
+}
+
+// Generate the typed client source of spec, the client methods are built on httpclient.HTTPQueryJSON and
+// accept the httpclient options
+func Generate(spec *Spec, options GenerateOptions) ([]byte, error) {
+	if "" == options.Package {
+		return nil, fmt.Errorf("the package name of the generated client is required")
+	}
+	g := &generator{spec: spec, imports: map[string]bool{}, types: map[string]bool{}}
+	g.imports["github.com/libpub/golib/httpclient"] = true
+	g.imports["strings"] = true
+
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.types[goName(name)] = true
+	}
+	for _, name := range names {
+		g.namedType(goName(name), spec.Components.Schemas[name])
+	}
+	if err := g.operations(); err != nil {
+		return nil, err
+	}
+
+	out := bytes.Buffer{}
+	source := ""
+	if "" != options.Source {
+		source = " from " + options.Source
+	}
+	fmt.Fprintf(&out, "// Code generated by openapigen%s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\n", options.Package)
+	std, others := []string{}, []string{}
+	for imp := range g.imports {
+		if strings.Contains(imp, ".") {
+			others = append(others, strconv.Quote(imp))
+		} else {
+			std = append(std, strconv.Quote(imp))
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	fmt.Fprintf(&out, "import (\n%s\n\n%s\n)\n\n", strings.Join(std, "\n"), strings.Join(others, "\n"))
+	title := strings.TrimSpace(spec.Info.Title + " " + spec.Info.Version)
+	if "" == title {
+		title = "api"
+	}
+	fmt.Fprintf(&out, `// Client typed client of %s
+type Client struct {
+	BaseURL string
+	Options []httpclient.ClientOption // applied to every request before the options of the call
+}
+
+// NewClient client requesting baseURL with options
+func NewClient(baseURL string, options ...httpclient.ClientOption) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Options: options}
+}
+
+func (c *Client) options(options []httpclient.ClientOption) []httpclient.ClientOption {
+	return append(append([]httpclient.ClientOption{}, c.Options...), options...)
+}
+
+`, title)
+	out.Write(g.decls.Bytes())
+	out.Write(g.methods.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated client failed with error:%v", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	spec    *Spec
+	imports map[string]bool
+	types   map[string]bool // the declared type names
+	decls   bytes.Buffer
+	methods bytes.Buffer
+}
+
+// namedType declares the type name of schema, the inline object types of schema are declared before it
+func (g *generator) namedType(name string, schema *Schema) {
+	decl := bytes.Buffer{}
+	if len(schema.Properties) > 0 {
+		fields := g.structFields(name, schema)
+		writeComment(&decl, name, schema.Description)
+		fmt.Fprintf(&decl, "type %s struct {\n%s}\n\n", name, fields)
+		g.decls.Write(decl.Bytes())
+		return
+	}
+	typ := g.typeOf(schema, name)
+	writeComment(&decl, name, schema.Description)
+	fmt.Fprintf(&decl, "type %s %s\n\n", name, typ)
+	if "string" == schema.Type && len(schema.Enum) > 0 {
+		fmt.Fprintf(&decl, "// Values of %s\nconst (\n", name)
+		for _, v := range schema.Enum {
+			s := fmt.Sprint(v)
+			fmt.Fprintf(&decl, "%s %s = %s\n", name+goName(s), name, strconv.Quote(s))
+		}
+		decl.WriteString(")\n\n")
+	}
+	g.decls.Write(decl.Bytes())
+}
+
+func (g *generator) structFields(name string, schema *Schema) string {
+	required := map[string]bool{}
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+	props := make([]string, 0, len(schema.Properties))
+	for prop := range schema.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	fields := bytes.Buffer{}
+	for _, prop := range props {
+		s := schema.Properties[prop]
+		typ := g.typeOf(s, name+goName(prop))
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+			if g.isStruct(s) {
+				typ = "*" + typ
+			}
+		}
+		if "" != s.Description {
+			fields.WriteString("// " + oneLine(s.Description) + "\n")
+		}
+		fmt.Fprintf(&fields, "%s %s `json:%s`\n", goName(prop), typ, strconv.Quote(tag))
+	}
+	return fields.String()
+}
+
+// isStruct whether schema is declared as struct
+func (g *generator) isStruct(schema *Schema) bool {
+	if "" != schema.Ref {
+		target := g.spec.Components.Schemas[refName(schema.Ref)]
+		return nil != target && len(target.Properties) > 0
+	}
+	return len(schema.Properties) > 0
+}
+
+// typeOf the go type of schema, the inline objects are declared as hint
+func (g *generator) typeOf(schema *Schema, hint string) string {
+	if nil == schema {
+		return "interface{}"
+	}
+	if "" != schema.Ref {
+		return goName(refName(schema.Ref))
+	}
+	switch schema.Type {
+	case "integer":
+		if "int32" == schema.Format {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if "float" == schema.Format {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "string":
+		if "date-time" == schema.Format {
+			g.imports["time"] = true
+			return "time.Time"
+		}
+		return "string"
+	case "array":
+		return "[]" + g.typeOf(schema.Items, hint+"Item")
+	}
+	if len(schema.Properties) > 0 {
+		if !g.types[hint] {
+			g.types[hint] = true
+			g.namedType(hint, schema)
+		}
+		return hint
+	}
+	if additional := schema.additionalSchema(); nil != additional {
+		return "map[string]" + g.typeOf(additional, hint+"Value")
+	}
+	if "object" == schema.Type {
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+type operation struct {
+	path   string
+	method string
+	item   *PathItem
+	op     *Operation
+}
+
+func (g *generator) operations() error {
+	paths := make([]string, 0, len(g.spec.Paths))
+	for path := range g.spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	seen := map[string]string{}
+	for _, path := range paths {
+		item := g.spec.Paths[path]
+		for _, o := range []operation{
+			{path, "GET", item, item.Get}, {path, "PUT", item, item.Put}, {path, "POST", item, item.Post},
+			{path, "DELETE", item, item.Delete}, {path, "PATCH", item, item.Patch}, {path, "HEAD", item, item.Head},
+		} {
+			if nil == o.op {
+				continue
+			}
+			name := goName(o.op.OperationID)
+			if "" == name {
+				name = goName(strings.ToLower(o.method) + " " + pathParamPattern.ReplaceAllString(path, "by $1"))
+			}
+			if previous, ok := seen[name]; ok {
+				return fmt.Errorf("operation %s %s conflicts with %s by method name %s", o.method, path, previous, name)
+			}
+			seen[name] = o.method + " " + path
+			if err := g.operation(name, o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (g *generator) operation(name string, o operation) error {
+	params := []*Parameter{}
+	overridden := map[string]bool{}
+	for _, p := range o.op.Parameters {
+		if p = g.parameter(p); nil != p {
+			overridden[p.In+":"+p.Name] = true
+			params = append(params, p)
+		}
+	}
+	for _, p := range o.item.Parameters {
+		if p = g.parameter(p); nil != p && !overridden[p.In+":"+p.Name] {
+			params = append(params, p)
+		}
+	}
+	pathParams := map[string]*Parameter{}
+	optionalParams := []*Parameter{}
+	for _, p := range params {
+		switch p.In {
+		case "path":
+			pathParams[p.Name] = p
+		case "query", "header":
+			optionalParams = append(optionalParams, p)
+		}
+	}
+
+	args := []string{}
+	urlExpr := []string{}
+	last := 0
+	for _, loc := range pathParamPattern.FindAllStringSubmatchIndex(o.path, -1) {
+		paramName := o.path[loc[2]:loc[3]]
+		p, ok := pathParams[paramName]
+		if !ok {
+			return fmt.Errorf("path parameter %s of %s %s is not declared", paramName, o.method, o.path)
+		}
+		arg := argName(paramName)
+		args = append(args, arg+" "+g.typeOf(p.Schema, name+goName(paramName)))
+		urlExpr = append(urlExpr, strconv.Quote(o.path[last:loc[0]]), "url.PathEscape(fmt.Sprint("+arg+"))")
+		g.imports["fmt"] = true
+		g.imports["net/url"] = true
+		last = loc[1]
+	}
+	if last < len(o.path) {
+		urlExpr = append(urlExpr, strconv.Quote(o.path[last:]))
+	}
+
+	paramsType := ""
+	if len(optionalParams) > 0 {
+		paramsType = name + "Params"
+		g.paramsType(paramsType, o, optionalParams)
+		args = append(args, "params *"+paramsType)
+	}
+	bodyArg := "nil"
+	if body := g.requestBody(o.op.RequestBody); nil != body {
+		typ := g.typeOf(body, name+"Request")
+		if g.isStruct(body) {
+			typ = "*" + typ
+		}
+		args = append(args, "body "+typ)
+		bodyArg = "body"
+	}
+	args = append(args, "options ...httpclient.ClientOption")
+
+	resultSchema := g.responseSchema(o.op)
+	resultType, resultArg, zero := "", "nil", ""
+	if nil != resultSchema {
+		resultType = g.typeOf(resultSchema, name+"Response")
+		resultArg = "&result"
+		if g.isStruct(resultSchema) {
+			resultType = "*" + resultType
+			resultArg = "result"
+			zero = "nil"
+		}
+	}
+
+	comment := o.op.Summary
+	if "" == comment {
+		comment = o.op.Description
+	}
+	if "" == comment {
+		comment = o.method + " " + o.path
+	}
+	writeComment(&g.methods, name, comment)
+	if o.op.Deprecated {
+		g.methods.WriteString("//\n// Deprecated: the operation is deprecated by the api\n")
+	}
+	returns := "error"
+	if "" != resultType {
+		returns = "(" + resultType + ", error)"
+	}
+	fmt.Fprintf(&g.methods, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), returns)
+	fmt.Fprintf(&g.methods, "queryURL := c.BaseURL + %s\n", strings.Join(urlExpr, " + "))
+	if "" != paramsType {
+		g.methods.WriteString("if nil != params {\nqueryURL, options = params.apply(queryURL, options)\n}\n")
+	}
+	switch {
+	case "" == resultType:
+		fmt.Fprintf(&g.methods, "return httpclient.HTTPQueryJSON(%q, queryURL, %s, nil, c.options(options)...)\n}\n\n", o.method, bodyArg)
+	case "nil" == zero:
+		fmt.Fprintf(&g.methods, "result := new(%s)\n", resultType[1:])
+		fmt.Fprintf(&g.methods, "if err := httpclient.HTTPQueryJSON(%q, queryURL, %s, %s, c.options(options)...); err != nil {\nreturn nil, err\n}\nreturn result, nil\n}\n\n", o.method, bodyArg, resultArg)
+	default:
+		fmt.Fprintf(&g.methods, "var result %s\n", resultType)
+		fmt.Fprintf(&g.methods, "err := httpclient.HTTPQueryJSON(%q, queryURL, %s, %s, c.options(options)...)\nreturn result, err\n}\n\n", o.method, bodyArg, resultArg)
+	}
+	return nil
+}
+
+// paramsType declares the query and header parameters of operation and the apply method of them
+func (g *generator) paramsType(name string, o operation, params []*Parameter) {
+	g.imports["net/url"] = true
+	g.imports["fmt"] = true
+	fields := bytes.Buffer{}
+	apply := bytes.Buffer{}
+	hasQuery := false
+	for _, p := range params {
+		field := goName(p.Name)
+		typ := g.typeOf(p.Schema, name+field)
+		if "" != p.Description {
+			fields.WriteString("// " + oneLine(p.Description) + "\n")
+		}
+		fmt.Fprintf(&fields, "%s %s\n", field, typ)
+		cond := "true"
+		if !p.Required {
+			switch {
+			case strings.HasPrefix(typ, "[]") || strings.HasPrefix(typ, "map["):
+				cond = "len(p." + field + ") > 0"
+			case "string" == typ:
+				cond = `"" != p.` + field
+			case "bool" == typ:
+				cond = "p." + field
+			case strings.HasPrefix(typ, "int") || strings.HasPrefix(typ, "float"):
+				cond = "0 != p." + field
+			default:
+				cond = "nil != p." + field
+			}
+		}
+		if "query" == p.In {
+			hasQuery = true
+			if strings.HasPrefix(typ, "[]") {
+				fmt.Fprintf(&apply, "for _, v := range p.%s {\nquery.Add(%q, fmt.Sprint(v))\n}\n", field, p.Name)
+			} else {
+				fmt.Fprintf(&apply, "if %s {\nquery.Set(%q, fmt.Sprint(p.%s))\n}\n", cond, p.Name, field)
+			}
+		} else {
+			fmt.Fprintf(&apply, "if %s {\noptions = append(options, httpclient.WithHTTPHeader(%q, fmt.Sprint(p.%s)))\n}\n", cond, p.Name, field)
+		}
+	}
+	fmt.Fprintf(&g.decls, "// %s query and header parameters of %s %s\ntype %s struct {\n%s}\n\n", name, o.method, o.path, name, fields.String())
+	fmt.Fprintf(&g.decls, "func (p *%s) apply(queryURL string, options []httpclient.ClientOption) (string, []httpclient.ClientOption) {\n", name)
+	if hasQuery {
+		g.decls.WriteString("query := url.Values{}\n")
+	}
+	g.decls.Write(apply.Bytes())
+	if hasQuery {
+		g.decls.WriteString("if encoded := query.Encode(); \"\" != encoded {\nqueryURL += \"?\" + encoded\n}\n")
+	}
+	g.decls.WriteString("return queryURL, options\n}\n\n")
+}
+
+func (g *generator) parameter(p *Parameter) *Parameter {
+	if nil != p && "" != p.Ref {
+		return g.spec.Components.Parameters[refName(p.Ref)]
+	}
+	return p
+}
+
+func (g *generator) requestBody(body *RequestBody) *Schema {
+	if nil != body && "" != body.Ref {
+		body = g.spec.Components.RequestBodies[refName(body.Ref)]
+	}
+	if nil == body {
+		return nil
+	}
+	return jsonSchema(body.Content)
+}
+
+// responseSchema the json schema of the first 2xx response
+func (g *generator) responseSchema(op *Operation) *Schema {
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		resp := op.Responses[code]
+		if nil != resp && "" != resp.Ref {
+			resp = g.spec.Components.Responses[refName(resp.Ref)]
+		}
+		if nil == resp {
+			continue
+		}
+		if schema := jsonSchema(resp.Content); nil != schema {
+			return schema
+		}
+	}
+	return nil
+}
+
+func jsonSchema(content map[string]*MediaType) *Schema {
+	types := make([]string, 0, len(content))
+	for typ := range content {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		if ("application/json" == typ || strings.HasSuffix(typ, "+json")) && nil != content[typ] {
+			return content[typ].Schema
+		}
+	}
+	return nil
+}
+
+func writeComment(buf *bytes.Buffer, name string, description string) {
+	if "" == description {
+		description = "generated from the openapi spec"
+	}
+	buf.WriteString("// " + name + " " + oneLine(description) + "\n")
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var initialisms = map[string]string{
+	"id": "ID", "url": "URL", "uri": "URI", "http": "HTTP", "https": "HTTPS", "api": "API",
+	"json": "JSON", "uuid": "UUID", "ip": "IP", "sql": "SQL", "xml": "XML",
+}
+
+// goName exported go name of s such as "PetID" of "pet_id" or "petId"
+func goName(s string) string {
+	words := []string{}
+	word := []rune{}
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+	name := bytes.Buffer{}
+	for _, w := range words {
+		if initialism, ok := initialisms[strings.ToLower(w)]; ok {
+			name.WriteString(initialism)
+			continue
+		}
+		rs := []rune(strings.ToLower(w))
+		rs[0] = unicode.ToUpper(rs[0])
+		name.WriteString(string(rs))
+	}
+	result := name.String()
+	if "" != result && unicode.IsDigit([]rune(result)[0]) {
+		result = "N" + result
+	}
+	return result
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true, "defer": true,
+	"else": true, "fallthrough": true, "for": true, "func": true, "go": true, "goto": true, "if": true,
+	"import": true, "interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+	"body": true, "params": true, "options": true, "queryURL": true, "result": true, "err": true, "c": true,
+}
+
+// argName unexported argument name of path parameter
+func argName(s string) string {
+	name := []rune(goName(s))
+	for i := 0; i < len(name) && unicode.IsUpper(name[i]); i++ {
+		if i > 0 && i+1 < len(name) && unicode.IsLower(name[i+1]) {
+			break
+		}
+		name[i] = unicode.ToLower(name[i])
+	}
+	arg := string(name)
+	if goKeywords[arg] {
+		arg += "Param"
+	}
+	return arg
+}
@@ -0,0 +1,135 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Spec the subset of the OpenAPI 3 document used to generate the typed clients
+type Spec struct {
+	OpenAPI    string               `yaml:"openapi" json:"openapi"`
+	Info       Info                 `yaml:"info" json:"info"`
+	Paths      map[string]*PathItem `yaml:"paths" json:"paths"`
+	Components Components           `yaml:"components" json:"components"`
+}
+
+// Info of api
+type Info struct {
+	Title   string `yaml:"title" json:"title"`
+	Version string `yaml:"version" json:"version"`
+}
+
+// Components reusable objects referenced by $ref
+type Components struct {
+	Schemas       map[string]*Schema      `yaml:"schemas" json:"schemas"`
+	Parameters    map[string]*Parameter   `yaml:"parameters" json:"parameters"`
+	RequestBodies map[string]*RequestBody `yaml:"requestBodies" json:"requestBodies"`
+	Responses     map[string]*Response    `yaml:"responses" json:"responses"`
+}
+
+// PathItem operations of a path
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters" json:"parameters"`
+	Get        *Operation   `yaml:"get" json:"get"`
+	Put        *Operation   `yaml:"put" json:"put"`
+	Post       *Operation   `yaml:"post" json:"post"`
+	Delete     *Operation   `yaml:"delete" json:"delete"`
+	Patch      *Operation   `yaml:"patch" json:"patch"`
+	Head       *Operation   `yaml:"head" json:"head"`
+}
+
+// Operation of a method on a path
+type Operation struct {
+	OperationID string               `yaml:"operationId" json:"operationId"`
+	Summary     string               `yaml:"summary" json:"summary"`
+	Description string               `yaml:"description" json:"description"`
+	Deprecated  bool                 `yaml:"deprecated" json:"deprecated"`
+	Parameters  []*Parameter         `yaml:"parameters" json:"parameters"`
+	RequestBody *RequestBody         `yaml:"requestBody" json:"requestBody"`
+	Responses   map[string]*Response `yaml:"responses" json:"responses"`
+}
+
+// Parameter of operation in path, query or header
+type Parameter struct {
+	Ref         string  `yaml:"$ref" json:"$ref"`
+	Name        string  `yaml:"name" json:"name"`
+	In          string  `yaml:"in" json:"in"`
+	Description string  `yaml:"description" json:"description"`
+	Required    bool    `yaml:"required" json:"required"`
+	Schema      *Schema `yaml:"schema" json:"schema"`
+}
+
+// RequestBody of operation
+type RequestBody struct {
+	Ref      string                `yaml:"$ref" json:"$ref"`
+	Required bool                  `yaml:"required" json:"required"`
+	Content  map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+// Response of operation
+type Response struct {
+	Ref         string                `yaml:"$ref" json:"$ref"`
+	Description string                `yaml:"description" json:"description"`
+	Content     map[string]*MediaType `yaml:"content" json:"content"`
+}
+
+// MediaType content of request body or response
+type MediaType struct {
+	Schema *Schema `yaml:"schema" json:"schema"`
+}
+
+// Schema of data
+type Schema struct {
+	Ref                  string             `yaml:"$ref" json:"$ref"`
+	Type                 string             `yaml:"type" json:"type"`
+	Format               string             `yaml:"format" json:"format"`
+	Description          string             `yaml:"description" json:"description"`
+	Properties           map[string]*Schema `yaml:"properties" json:"properties"`
+	Required             []string           `yaml:"required" json:"required"`
+	Items                *Schema            `yaml:"items" json:"items"`
+	AdditionalProperties interface{}        `yaml:"additionalProperties" json:"additionalProperties"` // bool or schema
+	Enum                 []interface{}      `yaml:"enum" json:"enum"`
+	Nullable             bool               `yaml:"nullable" json:"nullable"`
+}
+
+// ParseSpec parses the OpenAPI 3 document in json or yaml
+func ParseSpec(data []byte) (*Spec, error) {
+	spec := &Spec{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && '{' == trimmed[0] {
+		if err := json.Unmarshal(data, spec); err != nil {
+			return nil, fmt.Errorf("parse openapi json failed with error:%v", err)
+		}
+	} else if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("parse openapi yaml failed with error:%v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported openapi version:%q, only 3.x is supported", spec.OpenAPI)
+	}
+	return spec, nil
+}
+
+// additionalSchema the schema of additionalProperties, nil if it is not a schema
+func (s *Schema) additionalSchema() *Schema {
+	switch s.AdditionalProperties.(type) {
+	case nil, bool:
+		return nil
+	}
+	data, err := yaml.Marshal(s.AdditionalProperties)
+	if err != nil {
+		return nil
+	}
+	schema := &Schema{}
+	if err = yaml.Unmarshal(data, schema); err != nil {
+		return nil
+	}
+	return schema
+}
+
+// refName the last segment of $ref such as "Pet" of "#/components/schemas/Pet"
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
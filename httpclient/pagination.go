@@ -0,0 +1,276 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/libpub/golib/errors"
+)
+
+// PaginationStyle how the pages are requested
+type PaginationStyle int
+
+// Pagination styles
+const (
+	PaginationPageSize    = PaginationStyle(0) // page=1&size=100, the page number starts from StartPage
+	PaginationOffsetLimit = PaginationStyle(1) // offset=0&limit=100
+	PaginationCursor      = PaginationStyle(2) // cursor=token, the next token is taken from the response by NextCursorPath
+	PaginationLinkHeader  = PaginationStyle(3) // the next page url is the rel="next" of the Link header
+)
+
+// Defaults of pagination
+const (
+	DefaultPaginationPageSize = 100
+	DefaultPaginationMaxPages = 1000
+)
+
+// ErrPaginationLimitExceeded the pages are more than the MaxPages or the items are more than the MaxItems of
+// PaginationSpec, the pages fetched before exceeding are still delivered
+var ErrPaginationLimitExceeded = errors.New("pagination limit exceeded")
+
+// ErrStopPagination returned by PageCallback stops fetching the following pages without error
+var ErrStopPagination = errors.New("stop pagination")
+
+// PaginationSpec how the pages are requested and parsed, the paths are dot separated keys of the json response
+// such as "data.items", the whole response is the items if ItemsPath empty
+type PaginationSpec struct {
+	Style          PaginationStyle
+	PageParam      string // "page" if empty for PaginationPageSize
+	SizeParam      string // "size" for PaginationPageSize or "limit" for PaginationOffsetLimit if empty
+	OffsetParam    string // "offset" if empty
+	CursorParam    string // "cursor" if empty
+	StartPage      int    // the first page number, 1 if 0, set -1 for the zero based page numbers
+	PageSize       int    // DefaultPaginationPageSize if 0, the pagination ends on the page fewer than PageSize
+	ItemsPath      string
+	NextCursorPath string // such as "pagination.nextCursor", the pagination ends on the empty cursor
+	TotalPath      string // optional such as "pagination.total", the pagination ends after total items fetched
+	MaxPages       int    // DefaultPaginationMaxPages if 0
+	MaxItems       int    // no limit if 0
+}
+
+// Page a fetched page
+type Page struct {
+	Number int               // counted from 1
+	Items  []json.RawMessage // the items of ItemsPath
+	Body   []byte
+	Header http.Header
+}
+
+// PageCallback handles a fetched page, ErrStopPagination stops fetching without error
+type PageCallback func(page *Page) error
+
+// HTTPGetAllPages gets all the pages of queryURL with params and appends the items into result which should be
+// a pointer of slice, ErrPaginationLimitExceeded is returned with the items fetched in limits
+func HTTPGetAllPages(queryURL string, params *map[string]string, spec PaginationSpec, result interface{}, options ...ClientOption) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("the result of pages should be a pointer of slice but got %T", result)
+	}
+	slice := rv.Elem()
+	return HTTPEachPage(queryURL, params, spec, func(page *Page) error {
+		for _, item := range page.Items {
+			elem := reflect.New(slice.Type().Elem())
+			if err := json.Unmarshal(item, elem.Interface()); err != nil {
+				logs.Error.Printf("Parsing item of page %d queried from url:%s failed with error:%v", page.Number, queryURL, err)
+				return err
+			}
+			slice = reflect.Append(slice, elem.Elem())
+		}
+		rv.Elem().Set(slice)
+		return nil
+	}, options...)
+}
+
+// HTTPEachPage gets the pages of queryURL with params one by one and calls callback with every page until
+// the pages exhausted, the callback failed or the limits of spec exceeded
+func HTTPEachPage(queryURL string, params *map[string]string, spec PaginationSpec, callback PageCallback, options ...ClientOption) error {
+	spec = normalizePaginationSpec(spec)
+	query := map[string]string{}
+	if nil != params {
+		for k, v := range *params {
+			query[k] = v
+		}
+	}
+	var header http.Header
+	options = append(options, WithResponseHeader(&header))
+	pageURL, pageParams := queryURL, &query
+	fetched, total := 0, int64(-1)
+	seen := map[string]bool{}
+	for number := 1; ; number++ {
+		if number > spec.MaxPages {
+			logs.Warning.Printf("query pages of %s exceeded max pages:%d", queryURL, spec.MaxPages)
+			return ErrPaginationLimitExceeded
+		}
+		switch spec.Style {
+		case PaginationPageSize:
+			query[spec.PageParam] = strconv.Itoa(spec.StartPage + number - 1)
+			query[spec.SizeParam] = strconv.Itoa(spec.PageSize)
+		case PaginationOffsetLimit:
+			query[spec.OffsetParam] = strconv.Itoa(fetched)
+			query[spec.SizeParam] = strconv.Itoa(spec.PageSize)
+		}
+		header = nil
+		body, err := HTTPGet(pageURL, pageParams, options...)
+		if err != nil {
+			return err
+		}
+		page := &Page{Number: number, Body: body, Header: header}
+		var doc interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err = decoder.Decode(&doc); err != nil {
+			logs.Error.Printf("Parsing page %d queried from url:%s response:%s failed with error:%v", number, pageURL, string(body), err)
+			return err
+		}
+		if page.Items, err = paginationItems(doc, spec.ItemsPath); err != nil {
+			return err
+		}
+		exceeded := false
+		if spec.MaxItems > 0 && fetched+len(page.Items) > spec.MaxItems {
+			page.Items = page.Items[:spec.MaxItems-fetched]
+			exceeded = true
+		}
+		fetched += len(page.Items)
+		if err = callback(page); err != nil {
+			if ErrStopPagination == err {
+				return nil
+			}
+			return err
+		}
+		if exceeded {
+			logs.Warning.Printf("query pages of %s exceeded max items:%d", queryURL, spec.MaxItems)
+			return ErrPaginationLimitExceeded
+		}
+		if "" != spec.TotalPath {
+			if v, ok := paginationValue(doc, spec.TotalPath).(json.Number); ok {
+				if n, err := v.Int64(); nil == err {
+					total = n
+				}
+			}
+		}
+		if len(page.Items) == 0 || (total >= 0 && int64(fetched) >= total) {
+			return nil
+		}
+		switch spec.Style {
+		case PaginationPageSize, PaginationOffsetLimit:
+			if len(page.Items) < spec.PageSize {
+				return nil
+			}
+		case PaginationCursor:
+			cursor, _ := paginationValue(doc, spec.NextCursorPath).(string)
+			if "" == cursor || seen[cursor] {
+				return nil
+			}
+			seen[cursor] = true
+			query[spec.CursorParam] = cursor
+		case PaginationLinkHeader:
+			next := nextLinkURL(header, pageURL)
+			if "" == next || seen[next] {
+				return nil
+			}
+			seen[next] = true
+			// the next link carries all the query params
+			pageURL, pageParams = next, nil
+		}
+	}
+}
+
+func normalizePaginationSpec(spec PaginationSpec) PaginationSpec {
+	if "" == spec.PageParam {
+		spec.PageParam = "page"
+	}
+	if "" == spec.SizeParam {
+		spec.SizeParam = "size"
+		if PaginationOffsetLimit == spec.Style {
+			spec.SizeParam = "limit"
+		}
+	}
+	if "" == spec.OffsetParam {
+		spec.OffsetParam = "offset"
+	}
+	if "" == spec.CursorParam {
+		spec.CursorParam = "cursor"
+	}
+	if 0 == spec.StartPage {
+		spec.StartPage = 1
+	} else if spec.StartPage < 0 {
+		spec.StartPage = 0
+	}
+	if spec.PageSize <= 0 {
+		spec.PageSize = DefaultPaginationPageSize
+	}
+	if spec.MaxPages <= 0 {
+		spec.MaxPages = DefaultPaginationMaxPages
+	}
+	return spec
+}
+
+// paginationValue the value of dot separated path in the decoded json doc
+func paginationValue(doc interface{}, path string) interface{} {
+	if "" == path {
+		return doc
+	}
+	for _, key := range strings.Split(path, ".") {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		doc = m[key]
+	}
+	return doc
+}
+
+func paginationItems(doc interface{}, path string) ([]json.RawMessage, error) {
+	value := paginationValue(doc, path)
+	if nil == value {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the items of page at path:%s is not a list", path)
+	}
+	items := make([]json.RawMessage, 0, len(list))
+	for _, item := range list {
+		buf, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, buf)
+	}
+	return items, nil
+}
+
+// nextLinkURL the rel="next" url of the Link header resolved against the page url
+func nextLinkURL(header http.Header, pageURL string) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+				if `rel="next"` != param && "rel=next" != param {
+					continue
+				}
+				base, err := url.Parse(pageURL)
+				if err != nil {
+					return ""
+				}
+				next, err := base.Parse(target[1 : len(target)-1])
+				if err != nil {
+					return ""
+				}
+				return next.String()
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/libpub/golib/errors"
+)
+
+// maxStreamErrorBody bytes of the failed stream response kept in the error
+const maxStreamErrorBody = 1 << 20
+
+// ErrStopStream returned by the stream callbacks stops reading the stream without error
+var ErrStopStream = errors.New("stop stream")
+
+// JSONElementCallback handles an element of the json array stream, ErrStopStream stops reading without error
+type JSONElementCallback func(element json.RawMessage) error
+
+// HTTPGetJSONStream gets queryURL with params and decodes the json array response incrementally, the callback
+// is called by every element so that the large list responses are not buffered entirely, the default timeout of
+// HTTPQuery is not applied to the streams, set WithTimeout for the whole stream if needed
+func HTTPGetJSONStream(queryURL string, params *map[string]string, callback JSONElementCallback, options ...ClientOption) error {
+	return HTTPQueryJSONStream(context.Background(), "GET", withQueryParams(queryURL, params), nil, callback, options...)
+}
+
+// HTTPQueryJSONStream requests with body and decodes the json array response incrementally by callback until ctx done
+func HTTPQueryJSONStream(ctx context.Context, method string, queryURL string, body io.Reader, callback JSONElementCallback, options ...ClientOption) error {
+	resp, err := openStream(ctx, method, queryURL, body, options...)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	token, err := decoder.Token()
+	if nil != err {
+		return streamError(ctx, queryURL, err)
+	}
+	if delim, ok := token.(json.Delim); !ok || '[' != delim {
+		return fmt.Errorf("the stream of %s is not a json array but starts with %v", queryURL, token)
+	}
+	for decoder.More() {
+		var element json.RawMessage
+		if err = decoder.Decode(&element); nil != err {
+			return streamError(ctx, queryURL, err)
+		}
+		if err = callback(element); nil != err {
+			if ErrStopStream == err {
+				return nil
+			}
+			return err
+		}
+	}
+	if _, err = decoder.Token(); nil != err {
+		return streamError(ctx, queryURL, err)
+	}
+	return nil
+}
+
+// openStream sends the request and returns the succeeded response of which the body is left for reading,
+// the failed response is returned as error with the body
+func openStream(ctx context.Context, method string, queryURL string, body io.Reader, options ...ClientOption) (*http.Response, error) {
+	opts := defaultHTTPClientOptions()
+	for _, opt := range options {
+		opt.apply(&opts)
+	}
+	_, resp, err := sendQuery(ctx, method, queryURL, body, &opts)
+	if nil != err {
+		return nil, err
+	}
+	if http.StatusOK == resp.StatusCode || opts.successStatus[resp.StatusCode] {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxStreamErrorBody))
+	err = errors.FromResponse(resp.StatusCode, resp.Status, respBody)
+	logs.Warning.Printf("Error: stream %s failed with error(code:%d):%v body:%s", queryURL, resp.StatusCode, err, string(respBody))
+	return nil, err
+}
+
+// streamError the error of reading stream, the context error is returned if the reading is interrupted by ctx
+func streamError(ctx context.Context, queryURL string, err error) error {
+	if nil != ctx.Err() {
+		return ctx.Err()
+	}
+	logs.Error.Printf("Reading stream of %s failed with error:%v", queryURL, err)
+	return err
+}
+
+// StreamMaxLineSize max bytes of a line read by LineIterator
+var StreamMaxLineSize = 1 << 20
+
+// LineIterator iterates the lines of a streaming response as they arrive such as the ndjson, chunked logs
+// and events, the iteration ends on the end of the response, a read error or ctx done
+//
+//	it, err := httpclient.HTTPStreamNDJSON(ctx, "GET", eventsURL, nil)
+//	if nil != err {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		var event Event
+//		if err := it.Decode(&event); nil != err {
+//			return err
+//		}
+//	}
+//	return it.Err()
+type LineIterator struct {
+	ctx       context.Context
+	queryURL  string
+	resp      *http.Response
+	scanner   *bufio.Scanner
+	skipBlank bool
+	err       error
+}
+
+// HTTPStreamLines requests with body and iterates the lines of the response, the trailing "\r" of lines are
+// trimmed, the stream is interrupted once ctx done
+func HTTPStreamLines(ctx context.Context, method string, queryURL string, body io.Reader, options ...ClientOption) (*LineIterator, error) {
+	resp, err := openStream(ctx, method, queryURL, body, options...)
+	if nil != err {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 4096), StreamMaxLineSize)
+	return &LineIterator{ctx: ctx, queryURL: queryURL, resp: resp, scanner: scanner}, nil
+}
+
+// HTTPStreamNDJSON requests with body and iterates the json objects of the application/x-ndjson response,
+// the blank lines are skipped
+func HTTPStreamNDJSON(ctx context.Context, method string, queryURL string, body io.Reader, options ...ClientOption) (*LineIterator, error) {
+	options = append([]ClientOption{WithHTTPHeader("Accept", "application/x-ndjson")}, options...)
+	it, err := HTTPStreamLines(ctx, method, queryURL, body, options...)
+	if nil != err {
+		return nil, err
+	}
+	it.skipBlank = true
+	return it, nil
+}
+
+// Next advances to the next line, false if the stream ended or failed which is told by Err
+func (it *LineIterator) Next() bool {
+	if nil != it.err {
+		return false
+	}
+	for it.scanner.Scan() {
+		if it.skipBlank && 0 == len(bytes.TrimSpace(it.scanner.Bytes())) {
+			continue
+		}
+		return true
+	}
+	if err := it.scanner.Err(); nil != err {
+		it.err = streamError(it.ctx, it.queryURL, err)
+	} else if nil != it.ctx.Err() {
+		it.err = it.ctx.Err()
+	}
+	it.Close()
+	return false
+}
+
+// Bytes of the current line, which is overwritten by the next line
+func (it *LineIterator) Bytes() []byte {
+	return bytes.TrimSuffix(it.scanner.Bytes(), []byte("\r"))
+}
+
+// Text of the current line
+func (it *LineIterator) Text() string {
+	return string(it.Bytes())
+}
+
+// Decode the current line as json into v
+func (it *LineIterator) Decode(v interface{}) error {
+	return json.Unmarshal(it.Bytes(), v)
+}
+
+// Err the error ended the iteration, nil if the stream ended normally
+func (it *LineIterator) Err() error {
+	return it.err
+}
+
+// Close the stream, it should be called if the iteration is abandoned before the end
+func (it *LineIterator) Close() error {
+	return it.resp.Body.Close()
+}
@@ -0,0 +1,286 @@
+package httpserver
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils"
+)
+
+// Middleware wraps a handler
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler by middlewares, the first middleware is the outermost
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// DefaultMiddlewares request id, recovery and request logging
+func DefaultMiddlewares() []Middleware {
+	return []Middleware{RequestID(), Logging(), Recovery()}
+}
+
+// statusRecorder records the status and the size of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if 0 == w.status {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if 0 == w.status {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijacking is not supported by the response writer")
+}
+
+// RequestID takes the request id from the X-Request-Id header or generates one, the id is responded in the
+// header and carried by the request context for logger.WithContext and the httpclient requests of the context
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(logger.HeaderRequestID)
+			if "" == requestID {
+				requestID = utils.GenLoweruuid()
+			}
+			w.Header().Set(logger.HeaderRequestID, requestID)
+			next.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), requestID)))
+		})
+	}
+}
+
+// Logging logs method, path, status, size and duration of every request with the context fields of the request
+func Logging() Middleware {
+	log := logger.Module("httpserver")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			startTime := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				status := recorder.status
+				if 0 == status {
+					status = http.StatusOK
+				}
+				log.WithContext(r.Context()).Info("request", "method", r.Method, "path", r.URL.Path, "status", status,
+					"size", recorder.size, "duration_ms", time.Since(startTime).Milliseconds(), "remote", r.RemoteAddr)
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// Recovery responds 500 on the panics of the handler instead of closing the connection, http.ErrAbortHandler
+// is repanicked so that the response is aborted
+func Recovery() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				e := recover()
+				if nil == e {
+					return
+				}
+				if e == http.ErrAbortHandler {
+					panic(e)
+				}
+				logger.WithContext(r.Context()).Error("http handler panic", "method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(e), "stack", string(debug.Stack()))
+				if 0 == recorder.status {
+					WriteError(w, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// CORSOptions cross origin resource sharing options
+type CORSOptions struct {
+	AllowOrigins     []string `yaml:"allowOrigins"` // "*" allows all the origins
+	AllowMethods     []string `yaml:"allowMethods"` // GET, POST, PUT, PATCH, DELETE, HEAD if empty
+	AllowHeaders     []string `yaml:"allowHeaders"` // the requested headers are allowed if empty
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	MaxAge           int      `yaml:"maxAge"` // seconds the preflight responses could be cached
+}
+
+// CORS responds the cross origin headers to the allowed origins and the preflight requests with 204
+func CORS(options CORSOptions) Middleware {
+	origins := map[string]bool{}
+	for _, origin := range options.AllowOrigins {
+		origins[strings.ToLower(origin)] = true
+	}
+	methods := options.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(options.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(options.ExposeHeaders, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if "" == origin {
+				next.ServeHTTP(w, r)
+				return
+			}
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			if !origins["*"] && !origins[strings.ToLower(origin)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if origins["*"] && !options.AllowCredentials {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if options.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if http.MethodOptions != r.Method || "" == r.Header.Get("Access-Control-Request-Method") {
+				if "" != exposeHeaders {
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			if "" != allowHeaders {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			} else if requested := r.Header.Get("Access-Control-Request-Headers"); "" != requested {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if options.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(options.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// gzipResponseWriter compresses the body unless the handler sets its own content encoding
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer      *gzip.Writer
+	wroteHeader bool
+	compress    bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.Header()
+	w.compress = "" == header.Get("Content-Encoding") && http.StatusNoContent != status && http.StatusNotModified != status
+	if w.compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.writer = gzipWriterPool.Get().(*gzip.Writer)
+		w.writer.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if "" == w.Header().Get("Content-Type") {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compress {
+		return w.writer.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *gzipResponseWriter) Flush() {
+	if nil != w.writer {
+		w.writer.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) close() {
+	if nil != w.writer {
+		w.writer.Close()
+		gzipWriterPool.Put(w.writer)
+		w.writer = nil
+	}
+}
+
+// Gzip compresses the responses of the requests accepting gzip encoding
+func Gzip() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) || http.MethodHead == r.Method {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(encoding)
+		if i := strings.IndexByte(encoding, ';'); i >= 0 {
+			if strings.TrimSpace(encoding[i+1:]) == "q=0" {
+				continue
+			}
+			encoding = strings.TrimSpace(encoding[:i])
+		}
+		if "gzip" == encoding {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/libpub/golib/config/results"
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/logger"
+)
+
+// DefaultMaxRequestBodySize limit of the request body read by ReadJSON
+const DefaultMaxRequestBodySize = 4 << 20
+
+// ErrorResponse body of the error responses
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteJSON responds v as json with status
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if nil != err {
+		logger.Error.Printf("marshal json response failed with error:%v", err)
+		WriteError(w, http.StatusInternalServerError, err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteError responds err as ErrorResponse json with status, the status is mapped from the category of err
+// by errors.HTTPStatus if status is 0
+func WriteError(w http.ResponseWriter, status int, err error) error {
+	if 0 == status {
+		status = errors.HTTPStatus(err)
+	}
+	message := http.StatusText(status)
+	if nil != err {
+		message = err.Error()
+	}
+	body, _ := json.Marshal(ErrorResponse{Code: status, Message: message})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// ReadJSON decodes the request body limited by DefaultMaxRequestBodySize into v, the unknown fields are rejected
+func ReadJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, DefaultMaxRequestBodySize+1))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); nil != err {
+		return fmt.Errorf("invalid json request body:%w", err)
+	}
+	return nil
+}
+
+// WriteResult responds the succeeded results.ResultObject envelope of data and pagination
+func WriteResult(w http.ResponseWriter, data interface{}, pagination *results.Pagination) error {
+	return WriteJSON(w, http.StatusOK, results.NewOKResult(data, pagination))
+}
+
+// WriteResultError responds the failed results.ResultObject envelope of err with status 200 as the envelope
+// convention, the code is results.CodeOf(err)
+func WriteResultError(w http.ResponseWriter, err error) error {
+	return WriteJSON(w, http.StatusOK, results.NewErrorResult(err))
+}
@@ -0,0 +1,241 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/logger"
+)
+
+// Defaults of server
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultShutdownTimeout   = 15 * time.Second
+)
+
+// Option server option
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	tlsOptions        *definations.TLSOptions
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	middlewares       []Middleware
+}
+
+// WithTLSOptions serves https with the certificate and key files of tlsOptions, the client certificates are
+// required and verified by the ca file if VerifyClient, plain http is served if tlsOptions disabled
+func WithTLSOptions(tlsOptions *definations.TLSOptions) Option {
+	return func(o *serverOptions) {
+		o.tlsOptions = tlsOptions
+	}
+}
+
+// WithTimeouts read, write and idle timeouts of connections, 0 means no timeout for read and write
+// and DefaultIdleTimeout for idle
+func WithTimeouts(read time.Duration, write time.Duration, idle time.Duration) Option {
+	return func(o *serverOptions) {
+		o.readTimeout = read
+		o.writeTimeout = write
+		if idle > 0 {
+			o.idleTimeout = idle
+		}
+	}
+}
+
+// WithShutdownTimeout how long the in-flight requests are waited on shutdown, DefaultShutdownTimeout by default
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *serverOptions) {
+		o.shutdownTimeout = timeout
+	}
+}
+
+// WithMiddlewares wraps the handler by middlewares, the first middleware is the outermost
+func WithMiddlewares(middlewares ...Middleware) Option {
+	return func(o *serverOptions) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// Server http server with graceful shutdown
+type Server struct {
+	*http.Server
+	shutdownTimeout time.Duration
+	tlsEnabled      bool
+	listener        net.Listener
+	m               sync.Mutex
+}
+
+// NewServer server listening on addr serving handler, the tls options are validated and loaded here so that
+// the misconfigured server fails on starting
+func NewServer(addr string, handler http.Handler, options ...Option) (*Server, error) {
+	opts := serverOptions{
+		readHeaderTimeout: DefaultReadHeaderTimeout,
+		idleTimeout:       DefaultIdleTimeout,
+		shutdownTimeout:   DefaultShutdownTimeout,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	if nil == handler {
+		handler = http.DefaultServeMux
+	}
+	s := &Server{
+		Server: &http.Server{
+			Addr:              addr,
+			Handler:           Chain(handler, opts.middlewares...),
+			ReadTimeout:       opts.readTimeout,
+			ReadHeaderTimeout: opts.readHeaderTimeout,
+			WriteTimeout:      opts.writeTimeout,
+			IdleTimeout:       opts.idleTimeout,
+		},
+		shutdownTimeout: opts.shutdownTimeout,
+	}
+	if nil != opts.tlsOptions && opts.tlsOptions.Enabled {
+		tlsConfig, err := NewTLSConfig(opts.tlsOptions)
+		if nil != err {
+			return nil, err
+		}
+		s.TLSConfig = tlsConfig
+		s.tlsEnabled = true
+	}
+	return s, nil
+}
+
+// NewTLSConfig server tls config of tlsOptions
+func NewTLSConfig(tlsOptions *definations.TLSOptions) (*tls.Config, error) {
+	if err := tlsOptions.Validate(); err != nil {
+		logger.Error.Printf("Invalid tls options:%v", err)
+		return nil, err
+	}
+	if "" == tlsOptions.CertFile || "" == tlsOptions.KeyFile {
+		return nil, fmt.Errorf("the certificate and key files are required by tls server")
+	}
+	cert, err := tls.LoadX509KeyPair(tlsOptions.CertFile, tlsOptions.KeyFile)
+	if err != nil {
+		logger.Error.Printf("Load tls certificates:%s and %s failed with error:%v", tlsOptions.CertFile, tlsOptions.KeyFile, err)
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if tlsOptions.CaFile != "" {
+		caData, err := ioutil.ReadFile(tlsOptions.CaFile)
+		if err != nil {
+			logger.Error.Printf("Load tls client CA:%s failed with error:%v", tlsOptions.CaFile, err)
+			return nil, err
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		tlsConfig.ClientCAs.AppendCertsFromPEM(caData)
+	}
+	if tlsOptions.VerifyClient {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// Listen on the address of server, the listening address is returned by ListenAddr afterwards
+func (s *Server) Listen() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if nil != s.listener {
+		return nil
+	}
+	addr := s.Addr
+	if "" == addr {
+		addr = ":http"
+		if s.tlsEnabled {
+			addr = ":https"
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if nil != err {
+		logger.Error.Printf("Listen http server on %s failed with error:%v", addr, err)
+		return err
+	}
+	if s.tlsEnabled {
+		listener = tls.NewListener(listener, s.TLSConfig)
+	}
+	s.listener = listener
+	return nil
+}
+
+// ListenAddr the listening address, nil if not listening
+func (s *Server) ListenAddr() net.Addr {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if nil == s.listener {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// ListenAndServe listens if not listening and serves until shutdown, nil is returned after shutdown
+func (s *Server) ListenAndServe() error {
+	if err := s.Listen(); nil != err {
+		return err
+	}
+	s.m.Lock()
+	listener := s.listener
+	s.m.Unlock()
+	logger.Info.Printf("http server serving on %s tls:%v", listener.Addr(), s.tlsEnabled)
+	if err := s.Serve(listener); nil != err && http.ErrServerClosed != err {
+		logger.Error.Printf("http server on %s stopped with error:%v", listener.Addr(), err)
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections and waits for the in-flight requests within the shutdown timeout
+func (s *Server) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	err := s.Server.Shutdown(ctx)
+	if nil != err {
+		logger.Warning.Printf("shutdown http server on %s failed with error:%v", s.Addr, err)
+		s.Server.Close()
+	}
+	return err
+}
+
+// Run serves until ctx done or SIGINT or SIGTERM received, and then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	if err := s.Listen(); nil != err {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	served := make(chan error, 1)
+	go func() {
+		served <- s.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	case sig := <-signals:
+		logger.Info.Printf("http server on %s shutting down by signal %v", s.ListenAddr(), sig)
+	}
+	err := s.Shutdown()
+	if serveErr := <-served; nil != serveErr {
+		return serveErr
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package locking
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+)
+
+// Constants of etcd
+const (
+	DefaultEtcdAddress   = "http://127.0.0.1:2379"
+	DefaultEtcdKeyPrefix = "/locks/"
+)
+
+// EtcdBackend locks of etcd v3 json gateway, the lock key {prefix}{key} is created by a transaction comparing
+// its create revision with 0 and bound to a lease of ttl, the revision of the transaction is the fencing token
+type EtcdBackend struct {
+	address string
+	prefix  string
+	leases  map[string]string // owner -> lease id
+	m       sync.Mutex
+}
+
+type etcdResponseHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdTxnResponse struct {
+	Header    etcdResponseHeader `json:"header"`
+	Succeeded bool               `json:"succeeded"`
+}
+
+type etcdLeaseResponse struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+type etcdKeepAliveResponse struct {
+	Result etcdLeaseResponse `json:"result"`
+}
+
+// NewEtcdBackend backend of etcd address, the defaults are used if address or prefix empty
+func NewEtcdBackend(address string, prefix string) *EtcdBackend {
+	if "" == address {
+		address = DefaultEtcdAddress
+	}
+	if "" == prefix {
+		prefix = DefaultEtcdKeyPrefix
+	}
+	return &EtcdBackend{address: strings.TrimRight(address, "/"), prefix: prefix, leases: map[string]string{}}
+}
+
+// NewEtcdLocker locker of etcd address
+func NewEtcdLocker(address string, prefix string, options ...Option) Locker {
+	return NewLocker(NewEtcdBackend(address, prefix), options...)
+}
+
+// Acquire creates the key owned by owner with a lease of ttl if not exists, the ttl is rounded up to seconds
+func (b *EtcdBackend) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (int64, bool, error) {
+	lease := etcdLeaseResponse{}
+	if err := b.post(ctx, "/v3/lease/grant", map[string]interface{}{"TTL": leaseSeconds(ttl)}, &lease); nil != err {
+		return 0, false, err
+	}
+	if "" == lease.ID {
+		return 0, false, errors.New("etcd granted lease without id")
+	}
+	lockKey := base64.StdEncoding.EncodeToString([]byte(b.prefix + key))
+	resp := etcdTxnResponse{}
+	err := b.post(ctx, "/v3/kv/txn", map[string]interface{}{
+		"compare": []map[string]interface{}{
+			{"key": lockKey, "target": "CREATE", "result": "EQUAL", "create_revision": "0"},
+		},
+		"success": []map[string]interface{}{
+			{"request_put": map[string]interface{}{
+				"key":   lockKey,
+				"value": base64.StdEncoding.EncodeToString([]byte(owner)),
+				"lease": lease.ID,
+			}},
+		},
+	}, &resp)
+	if nil != err || !resp.Succeeded {
+		b.revoke(ctx, lease.ID)
+		return 0, false, err
+	}
+	token, err := strconv.ParseInt(resp.Header.Revision, 10, 64)
+	if nil != err {
+		b.revoke(ctx, lease.ID)
+		return 0, false, err
+	}
+	b.m.Lock()
+	b.leases[owner] = lease.ID
+	b.m.Unlock()
+	return token, true, nil
+}
+
+// Refresh keeps the lease of owner alive, the ttl is the one granted
+func (b *EtcdBackend) Refresh(ctx context.Context, key string, owner string, ttl time.Duration) (bool, error) {
+	b.m.Lock()
+	leaseID, ok := b.leases[owner]
+	b.m.Unlock()
+	if !ok {
+		return false, nil
+	}
+	resp := etcdKeepAliveResponse{}
+	if err := b.post(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": leaseID}, &resp); nil != err {
+		return false, err
+	}
+	n, _ := strconv.Atoi(resp.Result.TTL)
+	if n <= 0 {
+		b.m.Lock()
+		delete(b.leases, owner)
+		b.m.Unlock()
+		return false, nil
+	}
+	return true, nil
+}
+
+// Release revokes the lease of owner which deletes the key
+func (b *EtcdBackend) Release(ctx context.Context, key string, owner string) error {
+	b.m.Lock()
+	leaseID, ok := b.leases[owner]
+	delete(b.leases, owner)
+	b.m.Unlock()
+	if !ok {
+		return nil
+	}
+	return b.revoke(ctx, leaseID)
+}
+
+func (b *EtcdBackend) revoke(ctx context.Context, leaseID string) error {
+	return b.post(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": leaseID}, &struct{}{})
+}
+
+// post the timeout is taken from the deadline of ctx
+func (b *EtcdBackend) post(ctx context.Context, path string, params interface{}, result interface{}) error {
+	options := []httpclient.ClientOption{httpclient.WithTraceContext(ctx)}
+	if deadline, ok := ctx.Deadline(); ok {
+		options = append(options, httpclient.WithTimeout(int(leaseSeconds(time.Until(deadline)))))
+	}
+	return httpclient.HTTPPostJSONEx(b.address+path, params, result, options...)
+}
+
+// leaseSeconds ttl rounded up to seconds, at least 1
+func leaseSeconds(ttl time.Duration) int64 {
+	seconds := int64(math.Ceil(ttl.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
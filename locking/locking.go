@@ -0,0 +1,256 @@
+package locking
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/scheduler"
+	"github.com/libpub/golib/utils"
+)
+
+// Constants
+const (
+	DefaultRetryInterval = 100 * time.Millisecond
+)
+
+// errors
+var (
+	ErrNotAcquired = errors.New("lock not acquired")
+	ErrLockLost    = errors.New("lock lost")
+)
+
+// Lock the acquired lock
+type Lock interface {
+	// Key of lock
+	Key() string
+	// Token fencing token increasing on each acquisition of the key, the protected resources should reject the
+	// writes with smaller tokens than the ones seen
+	Token() int64
+	// Refresh extends the ttl, ErrLockLost is returned if the lock is expired or held by others
+	Refresh(ctx context.Context) error
+	// Unlock releases the lock and stops renewal
+	Unlock(ctx context.Context) error
+	// Lost is closed once the renewal failed and the lock should be treated as not held
+	Lost() <-chan struct{}
+}
+
+// Locker acquires locks
+type Locker interface {
+	// TryLock acquires the lock of key without waiting, ErrNotAcquired is returned if it is held by others
+	TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+	// Lock acquires the lock of key, waits until acquired or ctx done
+	Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error)
+}
+
+// Backend storage of locks, the owner identifies the holder
+type Backend interface {
+	// Acquire sets the key owned by owner with ttl if not exists, returns the fencing token
+	Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (token int64, ok bool, err error)
+	// Refresh extends the ttl of key if owned by owner
+	Refresh(ctx context.Context, key string, owner string, ttl time.Duration) (bool, error)
+	// Release deletes the key if owned by owner
+	Release(ctx context.Context, key string, owner string) error
+}
+
+// Option options of locker
+type Option func(*lockerOptions)
+
+type lockerOptions struct {
+	retryInterval time.Duration
+	autoRenew     bool
+}
+
+type locker struct {
+	backend Backend
+	options lockerOptions
+}
+
+type heldLock struct {
+	locker    *locker
+	key       string
+	owner     string
+	token     int64
+	ttl       time.Duration
+	lost      chan struct{}
+	stop      chan struct{}
+	closeOnce sync.Once
+	lostOnce  sync.Once
+}
+
+// WithRetryInterval the interval of retrying acquisition by Lock, 100ms by default
+func WithRetryInterval(interval time.Duration) Option {
+	return func(o *lockerOptions) {
+		o.retryInterval = interval
+	}
+}
+
+// WithAutoRenew whether the held locks are refreshed every ttl/3 until Unlock, true by default
+func WithAutoRenew(autoRenew bool) Option {
+	return func(o *lockerOptions) {
+		o.autoRenew = autoRenew
+	}
+}
+
+// NewLocker locker of backend
+func NewLocker(backend Backend, options ...Option) Locker {
+	l := &locker{
+		backend: backend,
+		options: lockerOptions{retryInterval: DefaultRetryInterval, autoRenew: true},
+	}
+	for _, option := range options {
+		option(&l.options)
+	}
+	return l
+}
+
+// TryLock acquires the lock of key without waiting
+func (l *locker) TryLock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	if ttl <= 0 {
+		return nil, errors.New("lock ttl should be positive")
+	}
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	owner := utils.GenNanoID()
+	token, ok, err := l.backend.Acquire(ctx, key, owner, ttl)
+	if nil != err {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	h := &heldLock{
+		locker: l,
+		key:    key,
+		owner:  owner,
+		token:  token,
+		ttl:    ttl,
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	if l.options.autoRenew {
+		go h.renew()
+	}
+	return h, nil
+}
+
+// Lock acquires the lock of key, waits until acquired or ctx done
+func (l *locker) Lock(ctx context.Context, key string, ttl time.Duration) (Lock, error) {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	for {
+		lock, err := l.TryLock(ctx, key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+		timer := time.NewTimer(l.options.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (h *heldLock) Key() string {
+	return h.key
+}
+
+func (h *heldLock) Token() int64 {
+	return h.token
+}
+
+func (h *heldLock) Lost() <-chan struct{} {
+	return h.lost
+}
+
+func (h *heldLock) Refresh(ctx context.Context) error {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	ok, err := h.locker.backend.Refresh(ctx, h.key, h.owner, h.ttl)
+	if nil != err {
+		return err
+	}
+	if !ok {
+		h.markLost()
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (h *heldLock) Unlock(ctx context.Context) error {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	h.closeOnce.Do(func() {
+		close(h.stop)
+	})
+	return h.locker.backend.Release(ctx, h.key, h.owner)
+}
+
+func (h *heldLock) markLost() {
+	h.lostOnce.Do(func() {
+		close(h.lost)
+	})
+}
+
+// renew refreshes the lock every ttl/3, the lock is lost if it is not refreshed within ttl
+func (h *heldLock) renew() {
+	ticker := time.NewTicker(h.ttl / 3)
+	defer ticker.Stop()
+	refreshed := time.Now()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), h.ttl/3)
+			err := h.Refresh(ctx)
+			cancel()
+			switch {
+			case nil == err:
+				refreshed = time.Now()
+			case errors.Is(err, ErrLockLost):
+				logger.Warning.Printf("lock:%s lost while refreshing", h.key)
+				return
+			default:
+				logger.Error.Printf("refresh lock:%s failed with error:%v", h.key, err)
+				if time.Since(refreshed) >= h.ttl {
+					h.markLost()
+					return
+				}
+			}
+		}
+	}
+}
+
+// NewSchedulerLocker the scheduler locking hook by locker so that the jobs WithDistributedLock run on only
+// one of replicas
+func NewSchedulerLocker(l Locker) scheduler.Locker {
+	return schedulerLocker{locker: l}
+}
+
+type schedulerLocker struct {
+	locker Locker
+}
+
+func (s schedulerLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	lock, err := s.locker.TryLock(ctx, name, ttl)
+	if errors.Is(err, ErrNotAcquired) {
+		return nil, false, nil
+	}
+	if nil != err {
+		return nil, false, err
+	}
+	return func() {
+		if err := lock.Unlock(context.Background()); nil != err {
+			logger.Error.Printf("unlock scheduled job:%s failed with error:%v", name, err)
+		}
+	}, true, nil
+}
@@ -0,0 +1,59 @@
+package locking
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryBackend locks of the current process, for tests and single instance deployments
+type MemoryBackend struct {
+	mutex  sync.Mutex
+	locks  map[string]memoryLock
+	tokens map[string]int64
+}
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryBackend backend of the current process
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{locks: map[string]memoryLock{}, tokens: map[string]int64{}}
+}
+
+// Acquire sets the key owned by owner with ttl if not exists
+func (b *MemoryBackend) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (int64, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if l, ok := b.locks[key]; ok && time.Now().Before(l.expires) {
+		return 0, false, nil
+	}
+	b.locks[key] = memoryLock{owner: owner, expires: time.Now().Add(ttl)}
+	b.tokens[key]++
+	return b.tokens[key], true, nil
+}
+
+// Refresh extends the ttl of key if owned by owner
+func (b *MemoryBackend) Refresh(ctx context.Context, key string, owner string, ttl time.Duration) (bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	l, ok := b.locks[key]
+	if !ok || l.owner != owner || !time.Now().Before(l.expires) {
+		return false, nil
+	}
+	l.expires = time.Now().Add(ttl)
+	b.locks[key] = l
+	return true, nil
+}
+
+// Release deletes the key if owned by owner
+func (b *MemoryBackend) Release(ctx context.Context, key string, owner string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if l, ok := b.locks[key]; ok && l.owner == owner {
+		delete(b.locks, key)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package locking
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Constants of redis
+const (
+	DefaultRedisKeyPrefix = "locks:"
+)
+
+// acquireScript SET NX the lock key and increases the fencing counter on success
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisBackend locks of redis, the lock key {prefix}{key} is set with the owner as value, and the fencing
+// tokens are counted by key {prefix}{key}:fence which is never expired
+type RedisBackend struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisBackend backend of redis client, DefaultRedisKeyPrefix is used if prefix empty
+func NewRedisBackend(client redis.UniversalClient, prefix string) *RedisBackend {
+	if "" == prefix {
+		prefix = DefaultRedisKeyPrefix
+	}
+	return &RedisBackend{client: client, prefix: prefix}
+}
+
+// NewRedisLocker locker of redis client
+func NewRedisLocker(client redis.UniversalClient, prefix string, options ...Option) Locker {
+	return NewLocker(NewRedisBackend(client, prefix), options...)
+}
+
+// Acquire sets the key owned by owner with ttl if not exists
+func (b *RedisBackend) Acquire(ctx context.Context, key string, owner string, ttl time.Duration) (int64, bool, error) {
+	lockKey := b.lockKey(key)
+	token, err := acquireScript.Run(b.client, []string{lockKey, lockKey + ":fence"}, owner, milliseconds(ttl)).Int64()
+	if nil != err {
+		return 0, false, err
+	}
+	return token, token > 0, nil
+}
+
+// Refresh extends the ttl of key if owned by owner
+func (b *RedisBackend) Refresh(ctx context.Context, key string, owner string, ttl time.Duration) (bool, error) {
+	n, err := refreshScript.Run(b.client, []string{b.lockKey(key)}, owner, milliseconds(ttl)).Int64()
+	if nil != err {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Release deletes the key if owned by owner
+func (b *RedisBackend) Release(ctx context.Context, key string, owner string) error {
+	return releaseScript.Run(b.client, []string{b.lockKey(key)}, owner).Err()
+}
+
+// lockKey the hash tag keeps the lock key and its fence key in the same slot of cluster
+func (b *RedisBackend) lockKey(key string) string {
+	return b.prefix + "{" + key + "}"
+}
+
+// milliseconds of d, at least 1
+func milliseconds(d time.Duration) int64 {
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
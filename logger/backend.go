@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry a log entry passed to the backend, the entries are filtered by the levels before
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Module  string
+	PC      uintptr // program counter of the caller, 0 if unknown
+	Message string
+	Fields  []interface{} // key-value pairs
+	Stack   string        // stack trace of the caller captured by SetStackLevel or of the panic logged by RecoverAndLog
+}
+
+// Caller the file name and line of the caller such as kafkaWorker.go:120
+func (e Entry) Caller() string {
+	if e.PC == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{e.PC}).Next()
+	if frame.File == "" {
+		return ""
+	}
+	return filepath.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+}
+
+// Backend writes the log entries, so that the libraries embedding golib could emit through the host application's logger
+type Backend interface {
+	Log(entry Entry)
+}
+
+// BackendFunc adapts a function as Backend
+type BackendFunc func(entry Entry)
+
+// Log calls f(entry)
+func (f BackendFunc) Log(entry Entry) {
+	f(entry)
+}
+
+// writerBackend the default backend writing to the output set by SetOutput in the format set by SetFormat
+type writerBackend struct {
+	m sync.Mutex
+}
+
+var defaultBackend Backend = &writerBackend{}
+
+// SetBackend replaces the backend of the structured logger and the legacy loggers, nil restores the default backend
+func SetBackend(backend Backend) {
+	if nil == backend {
+		backend = defaultBackend
+	}
+	std.m.Lock()
+	std.backend = backend
+	std.m.Unlock()
+}
+
+// GetBackend the current backend
+func GetBackend() Backend {
+	std.m.RLock()
+	defer std.m.RUnlock()
+	return std.backend
+}
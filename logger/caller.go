@@ -0,0 +1,122 @@
+package logger
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constants of caller and stack
+const (
+	// StackDisabled the stack level capturing no stack traces
+	StackDisabled LogLevel = LogLevelFatal + 1
+
+	// PanicField the field key of the recovered value logged by RecoverAndLog
+	PanicField = "panic"
+
+	maxStackDepth = 32
+)
+
+// SetCallerEnabled whether the entries carry the file:line of the caller, enabled by default
+func SetCallerEnabled(enabled bool) {
+	std.m.Lock()
+	std.caller = enabled
+	std.m.Unlock()
+}
+
+// SetStackLevel captures the stack trace of the caller for the entries at or above level such as LogLevelError,
+// StackDisabled by default
+func SetStackLevel(level LogLevel) {
+	std.m.Lock()
+	std.stackLevel = level
+	std.m.Unlock()
+}
+
+// Output writes msg at level with the caller calldepth frames above as log.Logger.Output, calldepth 1 is the caller of Output.
+// The helpers logging for their callers should use it instead of the Output of the legacy loggers whose calldepth is ignored
+func Output(level LogLevel, calldepth int, msg string) {
+	if !std.enabled("", level) {
+		return
+	}
+	// write <- Output <- caller
+	std.write(level, "", msg, nil, calldepth+1)
+}
+
+// RecoverAndLog recovers the panic and logs it at ERROR level with the panic stack, it must be deferred directly
+// such as defer logger.RecoverAndLog("consumer callback panic", "topic", topic)
+func RecoverAndLog(msg string, keysAndValues ...interface{}) {
+	if r := recover(); nil != r {
+		(&StructuredLogger{}).logPanic(r, msg, keysAndValues)
+	}
+}
+
+// RecoverAndLog recovers the panic and logs it at ERROR level with the panic stack, it must be deferred directly
+func (l *StructuredLogger) RecoverAndLog(msg string, keysAndValues ...interface{}) {
+	if r := recover(); nil != r {
+		l.logPanic(r, msg, keysAndValues)
+	}
+}
+
+// logPanic logs the recovered value, the caller is the function panicked
+func (l *StructuredLogger) logPanic(r interface{}, msg string, keysAndValues []interface{}) {
+	if !std.enabled(l.module, LogLevelError) {
+		return
+	}
+	fields := append(l.fields[:len(l.fields):len(l.fields)], keysAndValues...)
+	fields = append(fields, PanicField, fmt.Sprint(r))
+	std.m.RLock()
+	withCaller := std.caller
+	std.m.RUnlock()
+	entry := Entry{
+		Time:    time.Now(),
+		Level:   LogLevelError,
+		Module:  l.module,
+		Message: msg,
+		Fields:  fields,
+		Stack:   string(debug.Stack()),
+	}
+	if withCaller {
+		entry.PC = panicCaller()
+	}
+	std.emit(entry)
+}
+
+// panicCaller the program counter of the frame calling panic, 0 if not found
+func panicCaller() uintptr {
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(1, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return frame.PC
+		}
+		if "runtime.gopanic" == frame.Function {
+			panicking = true
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+// formatStack formats the frames as debug.Stack without the goroutine header and the argument values
+func formatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+	buf := &strings.Builder{}
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		buf.WriteString(frame.Function + "()\n\t" + frame.File + ":" + strconv.Itoa(frame.Line) + "\n")
+		if !more {
+			break
+		}
+	}
+	return buf.String()
+}
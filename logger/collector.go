@@ -0,0 +1,157 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Constants of http collector
+const (
+	DefaultCollectorBatchSize     = 100
+	DefaultCollectorFlushInterval = time.Second
+	DefaultCollectorTimeout       = 5 * time.Second
+)
+
+// HTTPCollectorOption options of http collector
+type HTTPCollectorOption func(*HTTPCollector)
+
+// WithCollectorBatchSize the entries posted in one request at most
+func WithCollectorBatchSize(size int) HTTPCollectorOption {
+	return func(c *HTTPCollector) {
+		c.batchSize = size
+	}
+}
+
+// WithCollectorFlushInterval the interval of posting the buffered entries
+func WithCollectorFlushInterval(interval time.Duration) HTTPCollectorOption {
+	return func(c *HTTPCollector) {
+		c.flushInterval = interval
+	}
+}
+
+// WithCollectorHeader the header of the requests such as the authorization
+func WithCollectorHeader(name string, value string) HTTPCollectorOption {
+	return func(c *HTTPCollector) {
+		c.headers[name] = value
+	}
+}
+
+// WithCollectorClient the http client of the requests, the client with DefaultCollectorTimeout by default
+func WithCollectorClient(client *http.Client) HTTPCollectorOption {
+	return func(c *HTTPCollector) {
+		c.client = client
+	}
+}
+
+// HTTPCollector posts the entries as json lines (application/x-ndjson) to the collector url in batches asynchronously,
+// the entries are dropped while the buffer is full or the collector failed. It does not use httpclient
+// which logs by this package, the failures are written to stderr
+type HTTPCollector struct {
+	url           string
+	batchSize     int
+	flushInterval time.Duration
+	headers       map[string]string
+	client        *http.Client
+	entries       chan []byte
+	dropped       uint64
+	done          chan struct{}
+	once          sync.Once
+}
+
+// NewHTTPCollector new http collector of url
+func NewHTTPCollector(url string, options ...HTTPCollectorOption) *HTTPCollector {
+	c := &HTTPCollector{
+		url:           url,
+		batchSize:     DefaultCollectorBatchSize,
+		flushInterval: DefaultCollectorFlushInterval,
+		headers:       map[string]string{},
+		client:        &http.Client{Timeout: DefaultCollectorTimeout},
+		done:          make(chan struct{}),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.entries = make(chan []byte, DefaultAsyncBufferSize)
+	go c.run()
+	return c
+}
+
+// Log buffers the entry formatted as json
+func (c *HTTPCollector) Log(entry Entry) {
+	select {
+	case c.entries <- formatEntry(FormatJSON, entry):
+	default:
+		atomic.AddUint64(&c.dropped, 1)
+	}
+}
+
+// Dropped the count of entries dropped
+func (c *HTTPCollector) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// Close posts the buffered entries and stops the collector
+func (c *HTTPCollector) Close() error {
+	c.once.Do(func() {
+		close(c.entries)
+	})
+	<-c.done
+	return nil
+}
+
+func (c *HTTPCollector) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	batch := &bytes.Buffer{}
+	count := 0
+	for {
+		select {
+		case data, ok := <-c.entries:
+			if !ok {
+				c.post(batch, count)
+				return
+			}
+			batch.Write(data)
+			count++
+			if count >= c.batchSize {
+				c.post(batch, count)
+				count = 0
+			}
+		case <-ticker.C:
+			c.post(batch, count)
+			count = 0
+		}
+	}
+}
+
+// post sends the batch of count entries and resets it
+func (c *HTTPCollector) post(batch *bytes.Buffer, count int) {
+	if count == 0 {
+		return
+	}
+	defer batch.Reset()
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(batch.Bytes()))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		for name, value := range c.headers {
+			req.Header.Set(name, value)
+		}
+		var resp *http.Response
+		if resp, err = c.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("collector responded status %d", resp.StatusCode)
+			}
+		}
+	}
+	if err != nil {
+		atomic.AddUint64(&c.dropped, uint64(count))
+		fmt.Fprintf(os.Stderr, "post %d log entries to collector %s failed with error:%v\n", count, c.url, err)
+	}
+}
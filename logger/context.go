@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+)
+
+// Header names and field keys of the ids for log correlation
+const (
+	HeaderRequestID = "X-Request-Id"
+	HeaderTraceID   = "X-Trace-Id"
+
+	FieldRequestID = "request_id"
+	FieldTraceID   = "trace_id"
+)
+
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	traceIDContextKey
+	fieldsContextKey
+)
+
+// ContextWithRequestID returns the context carrying request id, it is attached to the entries of WithContext
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext the request id carried by ctx, empty if not set
+func RequestIDFromContext(ctx context.Context) string {
+	if nil == ctx {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// ContextWithTraceID returns the context carrying trace id, it is attached to the entries of WithContext
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey, traceID)
+}
+
+// TraceIDFromContext the trace id carried by ctx, empty if not set
+func TraceIDFromContext(ctx context.Context) string {
+	if nil == ctx {
+		return ""
+	}
+	traceID, _ := ctx.Value(traceIDContextKey).(string)
+	return traceID
+}
+
+// ContextWithFields returns the context carrying the key-value fields appended to the fields carried by ctx
+func ContextWithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	parent, _ := ctx.Value(fieldsContextKey).([]interface{})
+	fields := make([]interface{}, 0, len(parent)+len(keysAndValues))
+	fields = append(fields, parent...)
+	fields = append(fields, keysAndValues...)
+	return context.WithValue(ctx, fieldsContextKey, fields)
+}
+
+// WithContext returns the logger without module attaching the trace id, request id and fields carried by ctx to every entry
+func WithContext(ctx context.Context) *StructuredLogger {
+	return (&StructuredLogger{}).WithContext(ctx)
+}
+
+// WithContext returns a copy of the logger attaching the trace id, request id and fields carried by ctx to every entry
+func (l *StructuredLogger) WithContext(ctx context.Context) *StructuredLogger {
+	if nil == ctx {
+		return l
+	}
+	fields := []interface{}{}
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		fields = append(fields, FieldTraceID, traceID)
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fields = append(fields, FieldRequestID, requestID)
+	}
+	if ctxFields, ok := ctx.Value(fieldsContextKey).([]interface{}); ok {
+		fields = append(fields, ctxFields...)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
@@ -6,4 +6,33 @@ type Logger struct {
 	Type       string `yaml:"type"`
 	Address    string `yaml:"address"`
 	RemoteType string `yaml:"udp"`
+	// Format of the entries, text or json
+	Format string `yaml:"format"`
+	// Modules overrides the level of the modules, such as kafka: WARN
+	Modules map[string]string `yaml:"modules"`
+	// rotation of filesink type: daily, hourly or duration such as 6h
+	Rotation   string `yaml:"rotation"`
+	MaxSizeMB  int    `yaml:"maxSizeMB"`
+	MaxAgeDays int    `yaml:"maxAgeDays"`
+	MaxBackups int    `yaml:"maxBackups"`
+	Compress   bool   `yaml:"compress"`
+	// Outputs writes the levels to the outputs instead, such as ERROR: [stderr, file] and TRACE: [file],
+	// the outputs are stdout, stderr, file for the output of the type or the file paths
+	Outputs map[string][]string `yaml:"outputs"`
+	// DisableCaller omits the file:line of the caller in the entries
+	DisableCaller bool `yaml:"disableCaller"`
+	// StackLevel captures the stack traces of the entries at or above the level, such as ERROR
+	StackLevel string `yaml:"stackLevel"`
+	// Tees duplicate the entries to the collectors
+	Tees []TeeConfig `yaml:"tees"`
+}
+
+// TeeConfig struct
+type TeeConfig struct {
+	// Type of the tee, http or mq, see RegisterTeeFactory
+	Type string `yaml:"type"`
+	// Address the collector url of http or the category of mq
+	Address string `yaml:"address"`
+	// Level the minimum level duplicated, INFO by default
+	Level string `yaml:"level"`
 }
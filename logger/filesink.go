@@ -0,0 +1,245 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants of file sink
+const (
+	fileSinkTimeLayout = "20060102T150405.000"
+	compressedSuffix   = ".gz"
+)
+
+// FileSinkConfig rotation and cleanup config of file sink
+type FileSinkConfig struct {
+	Filename    string           // path of the current log file, rotated files are named as name-20060102T150405.000.ext
+	MaxBytes    int64            // rotates while the file size would exceed MaxBytes, 0 disables size based rotation
+	RotateEvery time.Duration    // rotates at every interval aligned to local midnight such as 24h or 1h, 0 disables time based rotation
+	Compress    bool             // compresses the rotated files with gzip
+	MaxAge      time.Duration    // removes the rotated files older than MaxAge, 0 keeps them
+	MaxBackups  int              // keeps at most MaxBackups rotated files, 0 keeps them all
+	Now         func() time.Time // time.Now if nil
+}
+
+// FileSink io.Writer writing to file with size and time based rotation, it could be set as output by SetOutput
+type FileSink struct {
+	config       FileSinkConfig
+	file         *os.File
+	size         int64
+	nextRotation time.Time
+	now          func() time.Time
+	m            sync.Mutex
+	cleaning     sync.WaitGroup
+	cleanMutex   sync.Mutex
+}
+
+// NewFileSink opens or creates the log file, the dir would be created if not exists
+func NewFileSink(config FileSinkConfig) (*FileSink, error) {
+	if "" == config.Filename {
+		return nil, errors.New("new log file sink with empty file name")
+	}
+	if err := os.MkdirAll(filepath.Dir(config.Filename), 0755); err != nil {
+		return nil, err
+	}
+	s := &FileSink{config: config, now: time.Now}
+	if nil != config.Now {
+		s.now = config.Now
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Write p to the current file, rotates the file before writing if needed
+func (s *FileSink) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if nil == s.file {
+		return 0, os.ErrClosed
+	}
+	if s.shouldRotate(int64(len(p))) {
+		if err := s.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// Rotate the current file immediately
+func (s *FileSink) Rotate() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if nil == s.file {
+		return os.ErrClosed
+	}
+	return s.rotate()
+}
+
+// Close the current file and waits for the compressing and cleaning of rotated files
+func (s *FileSink) Close() error {
+	s.m.Lock()
+	var err error
+	if nil != s.file {
+		err = s.file.Close()
+		s.file = nil
+	}
+	s.m.Unlock()
+	s.cleaning.Wait()
+	return err
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.config.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = stat.Size()
+	if s.config.RotateEvery > 0 {
+		s.nextRotation = nextRotationTime(s.now(), s.config.RotateEvery)
+	}
+	return nil
+}
+
+func (s *FileSink) shouldRotate(size int64) bool {
+	if s.config.MaxBytes > 0 && s.size > 0 && s.size+size > s.config.MaxBytes {
+		return true
+	}
+	return s.config.RotateEvery > 0 && !s.now().Before(s.nextRotation)
+}
+
+// rotate renames the current file with the rotating time and opens a new one
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	now := s.now()
+	ext := filepath.Ext(s.config.Filename)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(s.config.Filename, ext), now.Format(fileSinkTimeLayout), ext)
+	if err := os.Rename(s.config.Filename, rotated); err != nil {
+		// 继续写入原来的文件
+		if openErr := s.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	s.cleaning.Add(1)
+	go func() {
+		defer s.cleaning.Done()
+		s.cleanMutex.Lock()
+		defer s.cleanMutex.Unlock()
+		if s.config.Compress {
+			// 文件可能已被之前的清理删除
+			if err := compressFile(rotated); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "compress rotated log file:%s failed with error:%v\n", rotated, err)
+			}
+		}
+		s.removeExpired(now)
+	}()
+	return nil
+}
+
+// rotatedFiles the rotated files of the sink sorted by the rotating time
+func (s *FileSink) rotatedFiles() []string {
+	ext := filepath.Ext(s.config.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(s.config.Filename, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(s.config.Filename))
+	if err != nil {
+		return nil
+	}
+	files := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, compressedSuffix), ext)[len(prefix):]
+		if _, err := time.ParseInLocation(fileSinkTimeLayout, stamp, time.Local); err != nil {
+			continue
+		}
+		files = append(files, name)
+	}
+	// 时间格式保证按名称排序即按时间排序
+	sort.Strings(files)
+	return files
+}
+
+// removeExpired removes the rotated files older than MaxAge before now or beyond MaxBackups
+func (s *FileSink) removeExpired(now time.Time) {
+	if s.config.MaxAge <= 0 && s.config.MaxBackups <= 0 {
+		return
+	}
+	dir := filepath.Dir(s.config.Filename)
+	ext := filepath.Ext(s.config.Filename)
+	prefix := filepath.Base(strings.TrimSuffix(s.config.Filename, ext)) + "-"
+	files := s.rotatedFiles()
+	expires := now.Add(-s.config.MaxAge)
+	for i, name := range files {
+		remove := s.config.MaxBackups > 0 && i < len(files)-s.config.MaxBackups
+		if !remove && s.config.MaxAge > 0 {
+			stamp := strings.TrimSuffix(strings.TrimSuffix(name, compressedSuffix), ext)[len(prefix):]
+			rotatedAt, _ := time.ParseInLocation(fileSinkTimeLayout, stamp, time.Local)
+			remove = rotatedAt.Before(expires)
+		}
+		if remove {
+			os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+// compressFile compresses the file into file.gz and removes it
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+compressedSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + compressedSuffix)
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+// nextRotationTime the first time after t aligned to local midnight by interval
+func nextRotationTime(t time.Time, interval time.Duration) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if interval >= 24*time.Hour {
+		return midnight.Add(interval)
+	}
+	return midnight.Add((t.Sub(midnight)/interval + 1) * interval)
+}
@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Constants of level control
+const (
+	// LevelPath the path of the level handler served by ServeLevel
+	LevelPath = "/loglevel"
+)
+
+// ModuleLoggers the legacy style leveled loggers of module, the packages logging by the legacy loggers
+// could keep their Printf calls while the level of the module is controlled by SetModuleLevel
+type ModuleLoggers struct {
+	module  string
+	Trace   *log.Logger
+	Debug   *log.Logger
+	Info    *log.Logger
+	Warning *log.Logger
+	Error   *log.Logger
+	Fatal   *log.Logger
+}
+
+// NewModuleLoggers the legacy style leveled loggers writing the entries of module
+func NewModuleLoggers(module string) *ModuleLoggers {
+	return &ModuleLoggers{
+		module:  module,
+		Trace:   newModuleLevelLogger(module, LogLevelTrace, nil),
+		Debug:   newModuleLevelLogger(module, LogLevelDebug, nil),
+		Info:    newModuleLevelLogger(module, LogLevelInfo, nil),
+		Warning: newModuleLevelLogger(module, LogLevelWarning, nil),
+		Error:   newModuleLevelLogger(module, LogLevelError, nil),
+		Fatal:   newModuleLevelLogger(module, LogLevelFatal, nil),
+	}
+}
+
+// Module the module name of the loggers
+func (m *ModuleLoggers) Module() string {
+	return m.module
+}
+
+// Enabled whether the entries of level would be written for the module
+func (m *ModuleLoggers) Enabled(level LogLevel) bool {
+	return std.enabled(m.module, level)
+}
+
+// IsDebugEnabled whether the DEBUG entries would be written for the module
+func (m *ModuleLoggers) IsDebugEnabled() bool {
+	return std.enabled(m.module, LogLevelDebug)
+}
+
+// Output writes msg of the module at level with the caller calldepth frames above as the package Output
+func (m *ModuleLoggers) Output(level LogLevel, calldepth int, msg string) {
+	if !std.enabled(m.module, level) {
+		return
+	}
+	// write <- Output <- caller
+	std.write(level, m.module, msg, nil, calldepth+1)
+}
+
+// ErrorEvery returns the legacy logger of the module writing at most one ERROR entry per interval
+func (m *ModuleLoggers) ErrorEvery(interval time.Duration) *log.Logger {
+	return newModuleLevelLogger(m.module, LogLevelError, newRateSampler(interval))
+}
+
+// WarningEvery returns the legacy logger of the module writing at most one WARN entry per interval
+func (m *ModuleLoggers) WarningEvery(interval time.Duration) *log.Logger {
+	return newModuleLevelLogger(m.module, LogLevelWarning, newRateSampler(interval))
+}
+
+func newModuleLevelLogger(module string, level LogLevel, s sampler) *log.Logger {
+	return log.New(&levelWriter{module: module, level: level, sampler: s}, "", 0)
+}
+
+// GetModuleLevel the effective log level of module, the default level if not overridden
+func GetModuleLevel(module string) LogLevel {
+	std.m.RLock()
+	defer std.m.RUnlock()
+	if level, ok := std.moduleLevels[module]; ok {
+		return level
+	}
+	return std.level
+}
+
+// ModuleLevels the overridden log levels of the modules
+func ModuleLevels() map[string]LogLevel {
+	std.m.RLock()
+	defer std.m.RUnlock()
+	levels := make(map[string]LogLevel, len(std.moduleLevels))
+	for module, level := range std.moduleLevels {
+		levels[module] = level
+	}
+	return levels
+}
+
+// LookupLevel parses the level name such as DEBUG, INFO, WARN case insensitively, false if unknown
+func LookupLevel(name string) (LogLevel, bool) {
+	for level := LogLevelTrace; level <= LogLevelFatal; level++ {
+		if strings.EqualFold(name, level.String()) {
+			return level, true
+		}
+	}
+	if strings.EqualFold(name, "WARNING") {
+		return LogLevelWarning, true
+	}
+	return LogLevelDebug, false
+}
+
+// LevelConfig the log levels read and changed by the level handler, the module with empty level is reset to
+// the default level
+type LevelConfig struct {
+	Level   string            `json:"level,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// CurrentLevels the default level and the overridden levels of the modules
+func CurrentLevels() LevelConfig {
+	config := LevelConfig{Level: GetLevel().String(), Modules: map[string]string{}}
+	for module, level := range ModuleLevels() {
+		config.Modules[module] = level.String()
+	}
+	return config
+}
+
+// ApplyLevels changes the default level and the levels of the modules in config at runtime, nothing is changed
+// if any level name is unknown
+func ApplyLevels(config LevelConfig) error {
+	var level LogLevel
+	var ok bool
+	if "" != config.Level {
+		if level, ok = LookupLevel(config.Level); !ok {
+			return fmt.Errorf("unknown log level:%s", config.Level)
+		}
+	}
+	moduleLevels := make(map[string]LogLevel, len(config.Modules))
+	for module, name := range config.Modules {
+		if "" == name {
+			continue
+		}
+		moduleLevel, ok := LookupLevel(name)
+		if !ok {
+			return fmt.Errorf("unknown log level:%s of module:%s", name, module)
+		}
+		moduleLevels[module] = moduleLevel
+	}
+	if "" != config.Level {
+		SetLevel(level)
+	}
+	for module, name := range config.Modules {
+		if "" == name {
+			ResetModuleLevel(module)
+		} else {
+			SetModuleLevel(module, moduleLevels[module])
+		}
+	}
+	return nil
+}
+
+// LevelHandler responds the current levels as json on GET, and applies the levels of json body such as
+// {"modules":{"kafka":"DEBUG","httpclient":""}} on PUT or POST. The query parameters level and module such as
+// ?module=kafka&level=DEBUG are also accepted for the manual operations. The handler changes the logging of
+// the whole process, it should be served on the internal management port only
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			config := LevelConfig{}
+			query := r.URL.Query()
+			if module := query.Get("module"); "" != module {
+				config.Modules = map[string]string{module: query.Get("level")}
+			} else if _, ok := query["level"]; ok {
+				config.Level = query.Get("level")
+			} else if err := json.NewDecoder(r.Body).Decode(&config); nil != err {
+				http.Error(w, "invalid levels:"+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := ApplyLevels(config); nil != err {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			Info.Printf("log levels changed to %s by %s", levelsText(config), r.RemoteAddr)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CurrentLevels())
+	})
+}
+
+// ServeLevel serves the level handler on LevelPath of mux, http.DefaultServeMux is used if mux nil
+func ServeLevel(mux *http.ServeMux) {
+	if nil == mux {
+		mux = http.DefaultServeMux
+	}
+	mux.Handle(LevelPath, LevelHandler())
+}
+
+func levelsText(config LevelConfig) string {
+	parts := []string{}
+	if "" != config.Level {
+		parts = append(parts, "default:"+strings.ToUpper(config.Level))
+	}
+	for module, level := range config.Modules {
+		if "" == level {
+			level = "reset"
+		}
+		parts = append(parts, module+":"+strings.ToUpper(level))
+	}
+	return strings.Join(parts, " ")
+}
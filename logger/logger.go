@@ -2,7 +2,6 @@ package logger
 
 import (
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -21,8 +20,9 @@ type LogLevel int
 
 // Constant
 const (
-	RecordingTypeFilelog = "filelog"
-	RecordingTypeEFK     = "efk"
+	RecordingTypeFilelog  = "filelog"
+	RecordingTypeEFK      = "efk"
+	RecordingTypeFileSink = "filesink"
 
 	LogRotatorCronDaily   = "0 0 0 * * ?"
 	LogRotatorCronWeekly  = "0 0 0 ? * 1"
@@ -46,33 +46,60 @@ const (
 
 // Variables
 var (
-	Trace                 *log.Logger = log.New(os.Stdout, "[TRACE] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Debug                 *log.Logger = log.New(os.Stdout, "[DEBUG] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Info                  *log.Logger = log.New(os.Stdout, "[INFO] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Warning               *log.Logger = log.New(os.Stdout, "[WARN] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Error                 *log.Logger = log.New(io.MultiWriter(os.Stdout, os.Stderr), "[ERROR] ", log.Ldate|log.Ltime|log.Lshortfile)
-	Fatal                 *log.Logger = log.New(io.MultiWriter(os.Stdout, os.Stderr), "[FATAL] ", log.Ldate|log.Ltime|log.Lshortfile)
+	// the legacy loggers are adapters writing to the structured logger at their levels
+	Trace                 *log.Logger = newLevelLogger(LogLevelTrace)
+	Debug                 *log.Logger = newLevelLogger(LogLevelDebug)
+	Info                  *log.Logger = newLevelLogger(LogLevelInfo)
+	Warning               *log.Logger = newLevelLogger(LogLevelWarning)
+	Error                 *log.Logger = newLevelLogger(LogLevelError)
+	Fatal                 *log.Logger = newLevelLogger(LogLevelFatal)
 	LogRotatorCrontab     string      = LogRotatorCronDaily
 	LogRotatorExpiresDays int         = LogRotatorExpiresMonthly
 	baseLogFileName       string      = ""
 	rotatorTimer          *cron.Cron  = nil
 	originLogFile         *os.File    = nil
-	Level                 LogLevel    = LogLevelDebug
+	fileSink              *FileSink   = nil
 )
 
+// Level mirrors the default log level set by SetLevel for the former readers, assigning it directly does not
+// change the level of the loggers
+//
+// Deprecated: use SetLevel and GetLevel instead
+var Level LogLevel = LogLevelDebug
+
 // Init initializer
 func Init(loggerConfig *Logger) error {
+	if "" != loggerConfig.Format {
+		SetFormat(loggerConfig.Format)
+	}
+	for module, level := range loggerConfig.Modules {
+		SetModuleLevel(module, convertLogLevel(level))
+	}
+	SetCallerEnabled(!loggerConfig.DisableCaller)
+	if "" != loggerConfig.StackLevel {
+		SetStackLevel(convertLogLevel(loggerConfig.StackLevel))
+	}
+	var err error
 	if RecordingTypeFilelog == loggerConfig.Type {
-		return initFilelog(loggerConfig.Address, loggerConfig.Level)
+		err = initFilelog(loggerConfig.Address, loggerConfig.Level)
 	} else if RecordingTypeEFK == loggerConfig.Type {
-		return initEfkLogger(loggerConfig.Address, loggerConfig.Level)
+		err = initEfkLogger(loggerConfig.Address, loggerConfig.Level)
+	} else if RecordingTypeFileSink == loggerConfig.Type {
+		err = initFileSink(loggerConfig)
 	}
-	return nil
+	if nil != err {
+		return err
+	}
+	if err = initLevelOutputs(loggerConfig.Outputs); nil != err {
+		return err
+	}
+	return initTees(loggerConfig.Tees)
 }
 
-// IsDebugEnabled boolean
+// IsDebugEnabled whether the DEBUG entries without module would be written, the packages with module loggers
+// should check by ModuleLoggers.IsDebugEnabled instead
 func IsDebugEnabled() bool {
-	return Level <= LogLevelDebug
+	return GetLevel() <= LogLevelDebug
 }
 
 func convertLogLevel(logLevel string) LogLevel {
@@ -101,23 +128,8 @@ func convertLogLevel(logLevel string) LogLevel {
 	return actLogLevel
 }
 
-func selectIobufferByLevel(file *os.File, level LogLevel, limitLevel LogLevel) io.Writer {
-	if level < limitLevel {
-		return ioutil.Discard
-	} else if level < LogLevelFatal {
-		if file != nil {
-			return file
-		}
-		return os.Stdout
-	} else {
-		if file != nil {
-			return io.MultiWriter(file, os.Stderr)
-		}
-		return io.MultiWriter(os.Stdout, os.Stderr)
-	}
-}
-
-func initFilelog(logPath string, logLevel string) error {
+// resolveLogPath returns the absolute log path, the default path is ../log/<program>.log
+func resolveLogPath(logPath string) string {
 	curPath, err := filepath.Abs(filepath.Dir(os.Args[0]))
 	if err != nil {
 		curPath = ""
@@ -130,14 +142,18 @@ func initFilelog(logPath string, logLevel string) error {
 	if strings.HasPrefix(logPath, ".") {
 		logPath = path.Join(curPath, logPath)
 	}
+	return logPath
+}
 
+func initFilelog(logPath string, logLevel string) error {
+	logPath = resolveLogPath(logPath)
 	logDir, _ := path.Split(logPath)
 	if logDir != "" {
 		os.MkdirAll(logDir, 0776)
 	}
 
 	actLogLevel := convertLogLevel(logLevel)
-	Level = actLogLevel
+	SetLevel(actLogLevel)
 
 	baseLogFileName = logPath
 	file, _, err := generateLogFile(logPath)
@@ -145,18 +161,15 @@ func initFilelog(logPath string, logLevel string) error {
 		log.Fatalf("Open logger file:%s failed with error:%v", logPath, err)
 		return err
 	}
-
-	loggerFlag := log.Ldate | log.Ltime
-	if actLogLevel < LogLevelWarning {
-		loggerFlag += log.Lshortfile
+	SetOutput(file)
+	if nil != fileSink {
+		fileSink.Close()
+		fileSink = nil
 	}
-
-	Trace = log.New(selectIobufferByLevel(file, LogLevelTrace, actLogLevel), "[TRACE] ", loggerFlag)
-	Debug = log.New(selectIobufferByLevel(file, LogLevelDebug, actLogLevel), "[DEBUG] ", loggerFlag)
-	Info = log.New(selectIobufferByLevel(file, LogLevelWarning, actLogLevel), "[INFO] ", loggerFlag)
-	Warning = log.New(selectIobufferByLevel(file, LogLevelWarning, actLogLevel), "[WARN] ", loggerFlag)
-	Error = log.New(selectIobufferByLevel(file, LogLevelError, actLogLevel), "[ERROR] ", loggerFlag)
-	Fatal = log.New(selectIobufferByLevel(file, LogLevelFatal, actLogLevel), "[FATAL] ", loggerFlag)
+	if nil != originLogFile && file != originLogFile {
+		originLogFile.Close()
+	}
+	originLogFile = file
 
 	Info.Printf("logger initialized.")
 	if nil == rotatorTimer {
@@ -174,6 +187,50 @@ func initFilelog(logPath string, logLevel string) error {
 	return nil
 }
 
+func initFileSink(loggerConfig *Logger) error {
+	rotateEvery, err := parseRotation(loggerConfig.Rotation)
+	if err != nil {
+		return err
+	}
+	sink, err := NewFileSink(FileSinkConfig{
+		Filename:    resolveLogPath(loggerConfig.Address),
+		MaxBytes:    int64(loggerConfig.MaxSizeMB) * 1024 * 1024,
+		RotateEvery: rotateEvery,
+		Compress:    loggerConfig.Compress,
+		MaxAge:      time.Duration(loggerConfig.MaxAgeDays) * 24 * time.Hour,
+		MaxBackups:  loggerConfig.MaxBackups,
+	})
+	if err != nil {
+		return err
+	}
+	SetLevel(convertLogLevel(loggerConfig.Level))
+	SetOutput(sink)
+	if nil != fileSink {
+		fileSink.Close()
+	}
+	fileSink = sink
+	stopFilelog()
+	Info.Printf("logger initialized with file sink:%s", sink.config.Filename)
+	return nil
+}
+
+// parseRotation parses daily, hourly or duration such as 6h as rotating interval, empty means no time based rotation
+func parseRotation(rotation string) (time.Duration, error) {
+	switch strings.ToLower(rotation) {
+	case "":
+		return 0, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "hourly":
+		return time.Hour, nil
+	}
+	interval, err := time.ParseDuration(rotation)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log rotation:%s", rotation)
+	}
+	return interval, nil
+}
+
 func initEfkLogger(logPath string, logLevel string) error {
 	hosts := strings.Split(logPath, ":")
 	port := 80
@@ -185,6 +242,19 @@ func initEfkLogger(logPath string, logLevel string) error {
 	return nil
 }
 
+// stopFilelog stops the rotator and closes the log file of filelog which is replaced by the file sink
+func stopFilelog() {
+	if nil != rotatorTimer {
+		rotatorTimer.Stop()
+		rotatorTimer = nil
+	}
+	if nil != originLogFile {
+		originLogFile.Close()
+		originLogFile = nil
+	}
+	baseLogFileName = ""
+}
+
 func logRotator() {
 	file, endfix, err := generateLogFile(baseLogFileName)
 	if nil != err {
@@ -194,12 +264,7 @@ func logRotator() {
 	if file == originLogFile {
 		return
 	}
-	Trace.SetOutput(file)
-	Debug.SetOutput(file)
-	Info.SetOutput(file)
-	Warning.SetOutput(file)
-	Error.SetOutput(file)
-	Fatal.SetOutput(file)
+	SetOutput(file)
 	if nil != originLogFile {
 		originLogFile.Close()
 	}
@@ -0,0 +1,102 @@
+package logger
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// SuppressedField the field key of the number of entries dropped by sampling or rate limiting before the entry
+const SuppressedField = "suppressed"
+
+// sampler decides whether an entry is written and returns the number of entries dropped since the last written one
+type sampler interface {
+	allow() (bool, int)
+}
+
+// rateSampler allows at most one entry per interval
+type rateSampler struct {
+	interval   time.Duration
+	last       time.Time
+	suppressed int
+	now        func() time.Time
+	m          sync.Mutex
+}
+
+func (s *rateSampler) allow() (bool, int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	now := s.now()
+	if !s.last.IsZero() && now.Sub(s.last) < s.interval {
+		s.suppressed++
+		return false, 0
+	}
+	suppressed := s.suppressed
+	s.last = now
+	s.suppressed = 0
+	return true, suppressed
+}
+
+// countSampler allows the first entry and every n-th entry after it
+type countSampler struct {
+	n     int
+	count int
+	m     sync.Mutex
+}
+
+func (s *countSampler) allow() (bool, int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.count++
+	if s.count == 1 {
+		return true, 0
+	}
+	if s.count > s.n {
+		s.count = 1
+		return true, s.n - 1
+	}
+	return false, 0
+}
+
+func newRateSampler(interval time.Duration) sampler {
+	return &rateSampler{interval: interval, now: time.Now}
+}
+
+func newCountSampler(n int) sampler {
+	if n < 1 {
+		n = 1
+	}
+	return &countSampler{n: n}
+}
+
+// Every returns a copy of the logger writing at most one entry per interval,
+// the number of dropped entries is attached to the next written entry as field suppressed
+func (l *StructuredLogger) Every(interval time.Duration) *StructuredLogger {
+	return &StructuredLogger{module: l.module, fields: l.fields, sampler: newRateSampler(interval)}
+}
+
+// Sampled returns a copy of the logger writing the first entry and then one entry of every n entries
+func (l *StructuredLogger) Sampled(n int) *StructuredLogger {
+	return &StructuredLogger{module: l.module, fields: l.fields, sampler: newCountSampler(n)}
+}
+
+// Every returns the logger without module writing at most one entry per interval
+func Every(interval time.Duration) *StructuredLogger {
+	return (&StructuredLogger{}).Every(interval)
+}
+
+// Sampled returns the logger without module writing one entry of every n entries
+func Sampled(n int) *StructuredLogger {
+	return (&StructuredLogger{}).Sampled(n)
+}
+
+// ErrorEvery returns the legacy logger writing at most one ERROR entry per interval, so that hot error paths
+// such as reconnecting loops don't flood the logs, the returned logger should be kept and reused
+func ErrorEvery(interval time.Duration) *log.Logger {
+	return log.New(&levelWriter{level: LogLevelError, sampler: newRateSampler(interval)}, "", 0)
+}
+
+// WarningEvery returns the legacy logger writing at most one WARN entry per interval
+func WarningEvery(interval time.Duration) *log.Logger {
+	return log.New(&levelWriter{level: LogLevelWarning, sampler: newRateSampler(interval)}, "", 0)
+}
@@ -0,0 +1,63 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slog levels of TRACE and FATAL which slog does not define
+const (
+	SlogLevelTrace = slog.LevelDebug - 4
+	SlogLevelFatal = slog.LevelError + 4
+)
+
+// slogBackend emits the entries through a slog.Logger
+type slogBackend struct {
+	logger *slog.Logger
+}
+
+// NewSlogBackend the backend emitting through l, the module is added as attribute "module"
+func NewSlogBackend(l *slog.Logger) Backend {
+	if nil == l {
+		l = slog.Default()
+	}
+	return &slogBackend{logger: l}
+}
+
+// SlogLevel converts the log level into slog level
+func SlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelTrace:
+		return SlogLevelTrace
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarning:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	}
+	return SlogLevelFatal
+}
+
+// Log the entry through the handler of slog logger keeping the caller of the entry
+func (b *slogBackend) Log(entry Entry) {
+	ctx := context.Background()
+	level := SlogLevel(entry.Level)
+	handler := b.logger.Handler()
+	if !handler.Enabled(ctx, level) {
+		return
+	}
+	record := slog.NewRecord(entry.Time, level, entry.Message, entry.PC)
+	if entry.Module != "" {
+		record.AddAttrs(slog.String("module", entry.Module))
+	}
+	record.Add(entry.Fields...)
+	if entry.Stack != "" {
+		record.AddAttrs(slog.String("stack", entry.Stack))
+	}
+	handler.Handle(ctx, record)
+}
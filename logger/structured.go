@@ -0,0 +1,396 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	textTimeLayout = "2006/01/02 15:04:05"
+	jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// String name of the log level
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelTrace:
+		return "TRACE"
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarning:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	case LogLevelFatal:
+		return "FATAL"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// ParseLevel parses level names such as DEBUG, INFO, WARN, unknown names are parsed as DEBUG
+func ParseLevel(level string) LogLevel {
+	return convertLogLevel(level)
+}
+
+// StructuredLogger leveled logger with module name and key-value fields
+type StructuredLogger struct {
+	module  string
+	fields  []interface{}
+	sampler sampler
+}
+
+// loggerCore the shared outputs and levels of all structured loggers and the legacy loggers
+type loggerCore struct {
+	output       io.Writer
+	format       string
+	level        LogLevel
+	moduleLevels map[string]LogLevel
+	levelOutputs map[LogLevel]io.Writer
+	caller       bool
+	stackLevel   LogLevel
+	backend      Backend
+	tees         []teeBackend
+	m            sync.RWMutex
+}
+
+var std = &loggerCore{
+	output:       os.Stdout,
+	format:       FormatText,
+	level:        LogLevelDebug,
+	moduleLevels: map[string]LogLevel{},
+	levelOutputs: map[LogLevel]io.Writer{},
+	caller:       true,
+	stackLevel:   StackDisabled,
+	backend:      defaultBackend,
+}
+
+// Module returns the logger of module, the level of module could be overridden by SetModuleLevel
+func Module(name string) *StructuredLogger {
+	return &StructuredLogger{module: name}
+}
+
+// With returns the logger without module appending the key-value fields to every entry
+func With(keysAndValues ...interface{}) *StructuredLogger {
+	return (&StructuredLogger{}).With(keysAndValues...)
+}
+
+// SetLevel changes the default log level at runtime
+func SetLevel(level LogLevel) {
+	std.m.Lock()
+	std.level = level
+	Level = level
+	std.m.Unlock()
+}
+
+// GetLevel the default log level
+func GetLevel() LogLevel {
+	std.m.RLock()
+	defer std.m.RUnlock()
+	return std.level
+}
+
+// SetModuleLevel overrides the log level of module at runtime
+func SetModuleLevel(module string, level LogLevel) {
+	std.m.Lock()
+	std.moduleLevels[module] = level
+	std.m.Unlock()
+}
+
+// ResetModuleLevel removes the overridden log level of module
+func ResetModuleLevel(module string) {
+	std.m.Lock()
+	delete(std.moduleLevels, module)
+	std.m.Unlock()
+}
+
+// SetFormat sets the output format of the default backend as FormatText or FormatJSON
+func SetFormat(format string) {
+	format = strings.ToLower(format)
+	if format != FormatJSON {
+		format = FormatText
+	}
+	std.m.Lock()
+	std.format = format
+	std.m.Unlock()
+}
+
+// SetOutput sets the writer of the default backend, the ERROR entries are also written to stderr while writing to stdout
+func SetOutput(w io.Writer) {
+	std.m.Lock()
+	std.output = w
+	std.m.Unlock()
+}
+
+// With returns a copy of the logger appending the key-value fields to every entry
+func (l *StructuredLogger) With(keysAndValues ...interface{}) *StructuredLogger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return &StructuredLogger{module: l.module, fields: fields, sampler: l.sampler}
+}
+
+// Enabled whether the entries of level would be written
+func (l *StructuredLogger) Enabled(level LogLevel) bool {
+	return std.enabled(l.module, level)
+}
+
+// Trace writes message with key-value fields at TRACE level
+func (l *StructuredLogger) Trace(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelTrace, msg, keysAndValues)
+}
+
+// Debug writes message with key-value fields at DEBUG level
+func (l *StructuredLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelDebug, msg, keysAndValues)
+}
+
+// Info writes message with key-value fields at INFO level
+func (l *StructuredLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelInfo, msg, keysAndValues)
+}
+
+// Warn writes message with key-value fields at WARN level
+func (l *StructuredLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelWarning, msg, keysAndValues)
+}
+
+// Error writes message with key-value fields at ERROR level
+func (l *StructuredLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelError, msg, keysAndValues)
+}
+
+// Fatal writes message with key-value fields at FATAL level and exits the process
+func (l *StructuredLogger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.log(LogLevelFatal, msg, keysAndValues)
+	os.Exit(1)
+}
+
+func (l *StructuredLogger) log(level LogLevel, msg string, keysAndValues []interface{}) {
+	if !std.enabled(l.module, level) {
+		return
+	}
+	fields := l.fields
+	if len(keysAndValues) > 0 {
+		fields = append(fields[:len(fields):len(fields)], keysAndValues...)
+	}
+	if nil != l.sampler {
+		allowed, suppressed := l.sampler.allow()
+		if !allowed {
+			return
+		}
+		if suppressed > 0 {
+			fields = append(fields[:len(fields):len(fields)], SuppressedField, suppressed)
+		}
+	}
+	// write <- log <- Info <- caller
+	std.write(level, l.module, msg, fields, 3)
+}
+
+func (c *loggerCore) enabled(module string, level LogLevel) bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if module != "" {
+		if moduleLevel, ok := c.moduleLevels[module]; ok {
+			return level >= moduleLevel
+		}
+	}
+	return level >= c.level
+}
+
+// write sends the entry to the backend and tees, callerSkip is the number of stack frames to the caller
+func (c *loggerCore) write(level LogLevel, module string, msg string, fields []interface{}, callerSkip int) {
+	c.m.RLock()
+	withCaller, stackLevel := c.caller, c.stackLevel
+	c.m.RUnlock()
+	entry := Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Module:  module,
+		Message: msg,
+		Fields:  fields,
+	}
+	if withCaller || level >= stackLevel {
+		var pcs [maxStackDepth]uintptr
+		depth := 1
+		if level >= stackLevel {
+			depth = maxStackDepth
+		}
+		// runtime.Callers <- write
+		n := runtime.Callers(callerSkip+1, pcs[:depth])
+		if withCaller && n > 0 {
+			entry.PC = pcs[0]
+		}
+		if level >= stackLevel {
+			entry.Stack = formatStack(pcs[:n])
+		}
+	}
+	c.emit(entry)
+}
+
+// emit sends the entry to the backend and the tees of its level
+func (c *loggerCore) emit(entry Entry) {
+	c.m.RLock()
+	backend, tees := c.backend, c.tees
+	c.m.RUnlock()
+	backend.Log(entry)
+	for _, tee := range tees {
+		if entry.Level >= tee.level {
+			tee.backend.Log(entry)
+		}
+	}
+}
+
+// Log formats the entry as text or json and writes it to the output of its level or the output
+func (b *writerBackend) Log(entry Entry) {
+	std.m.RLock()
+	output, format := std.output, std.format
+	levelOutput, ok := std.levelOutputs[entry.Level]
+	std.m.RUnlock()
+	data := formatEntry(format, entry)
+	if ok {
+		output = levelOutput
+	} else if entry.Level >= LogLevelFatal || (entry.Level >= LogLevelError && output == os.Stdout) {
+		output = io.MultiWriter(output, os.Stderr)
+	}
+	b.m.Lock()
+	output.Write(data)
+	b.m.Unlock()
+}
+
+// formatEntry formats the entry as FormatText or FormatJSON
+func formatEntry(format string, entry Entry) []byte {
+	if format == FormatJSON {
+		return formatJSONEntry(entry.Time, entry.Level, entry.Module, entry.Caller(), entry.Message, entry.Fields, entry.Stack)
+	}
+	return formatTextEntry(entry.Time, entry.Level, entry.Module, entry.Caller(), entry.Message, entry.Fields, entry.Stack)
+}
+
+// formatTextEntry formats the entry as the legacy loggers: [INFO] 2006/01/02 15:04:05 file.go:12: message key=value,
+// the stack follows in the next lines
+func formatTextEntry(t time.Time, level LogLevel, module string, caller string, msg string, fields []interface{}, stack string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("[" + level.String() + "] ")
+	buf.WriteString(t.Format(textTimeLayout))
+	if caller != "" {
+		buf.WriteString(" " + caller + ":")
+	}
+	if module != "" {
+		buf.WriteString(" [" + module + "]")
+	}
+	buf.WriteString(" " + msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := fieldAt(fields, i)
+		buf.WriteString(" " + key + "=")
+		s := fmt.Sprint(value)
+		if strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteByte('\n')
+	if stack != "" {
+		buf.WriteString(strings.TrimSuffix(stack, "\n"))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// formatJSONEntry formats the entry as one line json object
+func formatJSONEntry(t time.Time, level LogLevel, module string, caller string, msg string, fields []interface{}, stack string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"time":`)
+	writeJSONValue(buf, t.Format(jsonTimeLayout))
+	buf.WriteString(`,"level":`)
+	writeJSONValue(buf, level.String())
+	if module != "" {
+		buf.WriteString(`,"module":`)
+		writeJSONValue(buf, module)
+	}
+	if caller != "" {
+		buf.WriteString(`,"caller":`)
+		writeJSONValue(buf, caller)
+	}
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(buf, msg)
+	for i := 0; i < len(fields); i += 2 {
+		key, value := fieldAt(fields, i)
+		buf.WriteByte(',')
+		writeJSONValue(buf, key)
+		buf.WriteByte(':')
+		writeJSONValue(buf, value)
+	}
+	if stack != "" {
+		buf.WriteString(`,"stack":`)
+		writeJSONValue(buf, stack)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// fieldAt returns the key-value pair at i, the key of odd fields is !BADKEY and the missing value is nil
+func fieldAt(fields []interface{}, i int) (string, interface{}) {
+	key, ok := fields[i].(string)
+	if !ok {
+		key = fmt.Sprintf("!BADKEY(%v)", fields[i])
+	}
+	if i+1 >= len(fields) {
+		return key, nil
+	}
+	return key, fields[i+1]
+}
+
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
+
+// levelWriter writes the lines of legacy *log.Logger to the structured core at level
+type levelWriter struct {
+	module  string
+	level   LogLevel
+	sampler sampler
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	if !std.enabled(w.module, w.level) {
+		return len(p), nil
+	}
+	var fields []interface{}
+	if nil != w.sampler {
+		allowed, suppressed := w.sampler.allow()
+		if !allowed {
+			return len(p), nil
+		}
+		if suppressed > 0 {
+			fields = []interface{}{SuppressedField, suppressed}
+		}
+	}
+	// write <- Write <- log.Logger.output <- log.Logger.Printf <- caller
+	std.write(w.level, w.module, strings.TrimSuffix(string(p), "\n"), fields, 4)
+	return len(p), nil
+}
+
+// newLevelLogger the legacy logger writing to the structured core at level
+func newLevelLogger(level LogLevel) *log.Logger {
+	return log.New(&levelWriter{level: level}, "", 0)
+}
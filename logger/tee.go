@@ -0,0 +1,249 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Writer names of the level outputs config
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file" // the output of the logger type such as the log file of filelog, stdout if not configured
+
+	TeeTypeHTTP = "http"
+
+	DefaultAsyncBufferSize = 1024
+)
+
+// TeeFactory creates the backend of tee config, see RegisterTeeFactory
+type TeeFactory func(config TeeConfig) (Backend, error)
+
+type teeBackend struct {
+	name    string
+	level   LogLevel
+	backend Backend
+}
+
+var (
+	teeFactories = map[string]TeeFactory{
+		TeeTypeHTTP: func(config TeeConfig) (Backend, error) {
+			return NewHTTPCollector(config.Address), nil
+		},
+	}
+	teeFactoriesMutex = sync.RWMutex{}
+	configTees        = []string{}
+	configOutputFiles = []*os.File{}
+	configLevels      = []LogLevel{}
+	configMutex       = sync.Mutex{}
+)
+
+// SetLevelOutput writes the entries of level to the writers instead of the output set by SetOutput,
+// such as the ERROR entries to stderr and the log file, no writers restores the output of level.
+// It applies to the default backend only
+func SetLevelOutput(level LogLevel, writers ...io.Writer) {
+	std.m.Lock()
+	defer std.m.Unlock()
+	switch len(writers) {
+	case 0:
+		delete(std.levelOutputs, level)
+	case 1:
+		std.levelOutputs[level] = writers[0]
+	default:
+		std.levelOutputs[level] = io.MultiWriter(writers...)
+	}
+}
+
+// AddTee duplicates the entries at or above level to backend after the backend set by SetBackend, the tee of the same name
+// is replaced. The backend is called in the logging goroutine, the slow backends such as the collectors should be asynchronous
+func AddTee(name string, level LogLevel, backend Backend) {
+	std.m.Lock()
+	defer std.m.Unlock()
+	tees := make([]teeBackend, 0, len(std.tees)+1)
+	for _, tee := range std.tees {
+		if tee.name != name {
+			tees = append(tees, tee)
+		}
+	}
+	std.tees = append(tees, teeBackend{name: name, level: level, backend: backend})
+}
+
+// RemoveTee removes the tee of name and returns its backend, nil if not found
+func RemoveTee(name string) Backend {
+	std.m.Lock()
+	defer std.m.Unlock()
+	for i, tee := range std.tees {
+		if tee.name == name {
+			std.tees = append(std.tees[:i:i], std.tees[i+1:]...)
+			return tee.backend
+		}
+	}
+	return nil
+}
+
+// RegisterTeeFactory registers the factory of the tees of type in config, the http type is registered by default,
+// the mq type is registered by the mq package
+func RegisterTeeFactory(teeType string, factory TeeFactory) {
+	teeFactoriesMutex.Lock()
+	teeFactories[strings.ToLower(teeType)] = factory
+	teeFactoriesMutex.Unlock()
+}
+
+// formattedBackend the backend writing the entries to the output in format
+type formattedBackend struct {
+	output io.Writer
+	format string
+	m      sync.Mutex
+}
+
+// NewWriterBackend the backend formatting the entries as FormatText or FormatJSON and writing them to w
+func NewWriterBackend(w io.Writer, format string) Backend {
+	if strings.ToLower(format) != FormatJSON {
+		format = FormatText
+	}
+	return &formattedBackend{output: w, format: format}
+}
+
+// Log writes the formatted entry
+func (b *formattedBackend) Log(entry Entry) {
+	data := formatEntry(b.format, entry)
+	b.m.Lock()
+	b.output.Write(data)
+	b.m.Unlock()
+}
+
+// AsyncBackend passes the entries to the backend in a goroutine, the entries are dropped while the buffer is full
+// so that the logging never blocks on the slow backends
+type AsyncBackend struct {
+	backend Backend
+	entries chan Entry
+	dropped uint64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewAsyncBackend the asynchronous backend of backend buffering bufferSize entries, DefaultAsyncBufferSize if not positive
+func NewAsyncBackend(backend Backend, bufferSize int) *AsyncBackend {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncBufferSize
+	}
+	b := &AsyncBackend{backend: backend, entries: make(chan Entry, bufferSize), done: make(chan struct{})}
+	go b.run()
+	return b
+}
+
+// Log buffers the entry
+func (b *AsyncBackend) Log(entry Entry) {
+	select {
+	case b.entries <- entry:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+// Dropped the count of entries dropped while the buffer was full
+func (b *AsyncBackend) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Close stops buffering and waits for the buffered entries passed to the backend, the backend is closed if it is an io.Closer
+func (b *AsyncBackend) Close() error {
+	b.once.Do(func() {
+		close(b.entries)
+	})
+	<-b.done
+	if closer, ok := b.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (b *AsyncBackend) run() {
+	defer close(b.done)
+	for entry := range b.entries {
+		b.backend.Log(entry)
+	}
+}
+
+// outputWriter writes to the output set by SetOutput at the time of writing so that it follows the log file rotation
+type outputWriter struct{}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	std.m.RLock()
+	output := std.output
+	std.m.RUnlock()
+	return output.Write(p)
+}
+
+// initLevelOutputs sets the level outputs of config, the outputs set by the former config are restored and its files are closed
+func initLevelOutputs(outputs map[string][]string) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	for _, level := range configLevels {
+		SetLevelOutput(level)
+	}
+	for _, file := range configOutputFiles {
+		file.Close()
+	}
+	configLevels = []LogLevel{}
+	configOutputFiles = []*os.File{}
+	for level, names := range outputs {
+		writers := make([]io.Writer, 0, len(names))
+		for _, name := range names {
+			switch strings.ToLower(name) {
+			case OutputStdout:
+				writers = append(writers, os.Stdout)
+			case OutputStderr:
+				writers = append(writers, os.Stderr)
+			case OutputFile:
+				writers = append(writers, outputWriter{})
+			default:
+				file, err := os.OpenFile(resolveLogPath(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+				if err != nil {
+					return fmt.Errorf("open log output:%s of level:%s failed with error:%v", name, level, err)
+				}
+				configOutputFiles = append(configOutputFiles, file)
+				writers = append(writers, file)
+			}
+		}
+		SetLevelOutput(convertLogLevel(level), writers...)
+		configLevels = append(configLevels, convertLogLevel(level))
+	}
+	return nil
+}
+
+// initTees adds the tees of config, the tees added by the former config are removed and closed
+func initTees(tees []TeeConfig) error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	for _, name := range configTees {
+		if closer, ok := RemoveTee(name).(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	configTees = []string{}
+	for i, config := range tees {
+		teeFactoriesMutex.RLock()
+		factory := teeFactories[strings.ToLower(config.Type)]
+		teeFactoriesMutex.RUnlock()
+		if nil == factory {
+			return fmt.Errorf("unknown log tee type:%s", config.Type)
+		}
+		backend, err := factory(config)
+		if err != nil {
+			return err
+		}
+		level := LogLevelInfo
+		if "" != config.Level {
+			level = convertLogLevel(config.Level)
+		}
+		name := fmt.Sprintf("config-%d-%s", i, config.Type)
+		AddTee(name, level, backend)
+		configTees = append(configTees, name)
+	}
+	return nil
+}
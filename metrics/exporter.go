@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Exporters
+const (
+	ExporterPrometheus = "prometheus"
+	ExporterStatsD     = "statsd"
+)
+
+// Config exporter of DefaultRegistry
+type Config struct {
+	Exporter string       `yaml:"exporter"` // prometheus or statsd, the metrics are not exported if empty
+	Path     string       `yaml:"path"`     // path of prometheus handler on http.DefaultServeMux, "/metrics" if empty
+	StatsD   StatsDConfig `yaml:"statsd"`
+}
+
+var (
+	exporterMutex  sync.Mutex
+	statsdPusher   *StatsDPusher
+	prometheusPath = map[string]bool{}
+)
+
+// Init the exporter of DefaultRegistry, the prometheus handler is served on http.DefaultServeMux,
+// and the statsd pusher replaces the one started by the former Init
+func Init(config Config) error {
+	exporterMutex.Lock()
+	defer exporterMutex.Unlock()
+	switch config.Exporter {
+	case "":
+		return nil
+	case ExporterPrometheus:
+		path := config.Path
+		if "" == path {
+			path = DefaultPrometheusPath
+		}
+		if !prometheusPath[path] {
+			http.DefaultServeMux.Handle(path, PrometheusHandler(DefaultRegistry))
+			prometheusPath[path] = true
+		}
+		return nil
+	case ExporterStatsD:
+		pusher, err := NewStatsDPusher(DefaultRegistry, config.StatsD)
+		if nil != err {
+			return err
+		}
+		if nil != statsdPusher {
+			statsdPusher.Stop()
+		}
+		statsdPusher = pusher
+		pusher.Start()
+		return nil
+	}
+	return fmt.Errorf("unsupported metrics exporter:%s", config.Exporter)
+}
+
+// Stop the statsd pusher started by Init, the pending metrics are pushed
+func Stop() {
+	exporterMutex.Lock()
+	pusher := statsdPusher
+	statsdPusher = nil
+	exporterMutex.Unlock()
+	if nil != pusher {
+		pusher.Stop()
+	}
+}
@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils/maputil"
+)
+
+// Types of metrics
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefaultBuckets buckets of histograms in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry metrics by name
+type Registry struct {
+	m       sync.RWMutex
+	metrics map[string]*metric
+}
+
+// DefaultRegistry the registry used by the golib packages and exporters
+var DefaultRegistry = NewRegistry()
+
+// Counter monotonically increasing value of each label values
+type Counter struct {
+	*metric
+}
+
+// Gauge value of each label values which could go up and down
+type Gauge struct {
+	*metric
+}
+
+// Histogram observations counted in buckets of each label values
+type Histogram struct {
+	*metric
+}
+
+// Sample value of a label values
+type Sample struct {
+	LabelValues []string
+	Value       float64  // value of counter or gauge
+	Count       uint64   // count of histogram observations
+	Sum         float64  // sum of histogram observations
+	Buckets     []uint64 // cumulative counts of histogram observations less or equal to the buckets
+}
+
+// Snapshot samples of a metric
+type Snapshot struct {
+	Name       string
+	Help       string
+	Type       string
+	LabelNames []string
+	Buckets    []float64
+	Samples    []Sample
+}
+
+type metric struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+	buckets    []float64
+	m          sync.Mutex
+	series     map[string]*Sample
+}
+
+// NewRegistry registry without metrics
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]*metric{}}
+}
+
+// Counter registers the counter, the registered counter of the same name is returned
+func (r *Registry) Counter(name string, help string, labelNames ...string) *Counter {
+	return &Counter{r.register(name, help, TypeCounter, labelNames, nil)}
+}
+
+// Gauge registers the gauge, the registered gauge of the same name is returned
+func (r *Registry) Gauge(name string, help string, labelNames ...string) *Gauge {
+	return &Gauge{r.register(name, help, TypeGauge, labelNames, nil)}
+}
+
+// Histogram registers the histogram with the upper bounds of buckets, DefaultBuckets is used if buckets empty,
+// the registered histogram of the same name is returned
+func (r *Registry) Histogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{r.register(name, help, TypeHistogram, labelNames, buckets)}
+}
+
+// Snapshots samples of all the metrics ordered by name and label values
+func (r *Registry) Snapshots() []Snapshot {
+	r.m.RLock()
+	metrics := make([]*metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	r.m.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name < metrics[j].name
+	})
+	snapshots := make([]Snapshot, 0, len(metrics))
+	for _, m := range metrics {
+		snapshots = append(snapshots, m.snapshot())
+	}
+	return snapshots
+}
+
+func (r *Registry) register(name string, help string, typ string, labelNames []string, buckets []float64) *metric {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.typ == typ && len(m.labelNames) == len(labelNames) {
+			return m
+		}
+		// the conflicted metric still works without being exported
+		logger.Error.Printf("register %s metric %s conflicts with the registered %s metric", typ, name, m.typ)
+		return newMetric(name, help, typ, labelNames, buckets)
+	}
+	m := newMetric(name, help, typ, labelNames, buckets)
+	r.metrics[name] = m
+	return m
+}
+
+func newMetric(name string, help string, typ string, labelNames []string, buckets []float64) *metric {
+	return &metric{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: append([]string{}, labelNames...),
+		buckets:    buckets,
+		series:     map[string]*Sample{},
+	}
+}
+
+// sample of label values with m locked, the missing label values are empty and the extra ones are ignored,
+// the label values are copied only for the new series so that updating the existing series does not allocate
+func (m *metric) sample(labelValues []string) *Sample {
+	values := labelValues
+	if len(values) != len(m.labelNames) {
+		values = make([]string, len(m.labelNames))
+		copy(values, labelValues)
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &Sample{LabelValues: append([]string{}, values...)}
+		if TypeHistogram == m.typ {
+			s.Buckets = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+func (m *metric) add(delta float64, labelValues []string) {
+	m.m.Lock()
+	m.sample(labelValues).Value += delta
+	m.m.Unlock()
+}
+
+func (m *metric) snapshot() Snapshot {
+	m.m.Lock()
+	defer m.m.Unlock()
+	snapshot := Snapshot{
+		Name:       m.name,
+		Help:       m.help,
+		Type:       m.typ,
+		LabelNames: m.labelNames,
+		Buckets:    m.buckets,
+		Samples:    make([]Sample, 0, len(m.series)),
+	}
+	for _, key := range maputil.SortedKeys(m.series) {
+		s := *m.series[key]
+		s.Buckets = append([]uint64(nil), s.Buckets...)
+		snapshot.Samples = append(snapshot.Samples, s)
+	}
+	return snapshot
+}
+
+// Inc increases the counter of label values by 1
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add increases the counter of label values by delta, the negative delta is ignored
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta > 0 {
+		c.add(delta, labelValues)
+	}
+}
+
+// Set the gauge of label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.m.Lock()
+	g.sample(labelValues).Value = value
+	g.m.Unlock()
+}
+
+// Add delta to the gauge of label values
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Observe the value of label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.m.Lock()
+	s := h.sample(labelValues)
+	s.Count++
+	s.Sum += value
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.Buckets[i]++
+		}
+	}
+	h.m.Unlock()
+}
+
+// NewCounter registers the counter to DefaultRegistry
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.Counter(name, help, labelNames...)
+}
+
+// NewGauge registers the gauge to DefaultRegistry
+func NewGauge(name string, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.Gauge(name, help, labelNames...)
+}
+
+// NewHistogram registers the histogram to DefaultRegistry
+func NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.Histogram(name, help, buckets, labelNames...)
+}
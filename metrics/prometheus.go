@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Constants of prometheus
+const (
+	DefaultPrometheusPath = "/metrics"
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// WritePrometheus writes the metrics of registry in the prometheus text exposition format
+func WritePrometheus(w io.Writer, registry *Registry) error {
+	b := bufio.NewWriter(w)
+	for _, snapshot := range registry.Snapshots() {
+		if "" != snapshot.Help {
+			b.WriteString("# HELP " + snapshot.Name + " " + escapePrometheusHelp(snapshot.Help) + "\n")
+		}
+		b.WriteString("# TYPE " + snapshot.Name + " " + snapshot.Type + "\n")
+		for _, s := range snapshot.Samples {
+			labels := prometheusLabels(snapshot.LabelNames, s.LabelValues)
+			if TypeHistogram != snapshot.Type {
+				writePrometheusSample(b, snapshot.Name, labels, s.Value)
+				continue
+			}
+			for i, bound := range snapshot.Buckets {
+				writePrometheusSample(b, snapshot.Name+"_bucket", appendPrometheusLabel(labels, "le", formatPrometheusValue(bound)), float64(s.Buckets[i]))
+			}
+			writePrometheusSample(b, snapshot.Name+"_bucket", appendPrometheusLabel(labels, "le", "+Inf"), float64(s.Count))
+			writePrometheusSample(b, snapshot.Name+"_sum", labels, s.Sum)
+			writePrometheusSample(b, snapshot.Name+"_count", labels, float64(s.Count))
+		}
+	}
+	return b.Flush()
+}
+
+// PrometheusHandler serves the metrics of registry for prometheus scraping, DefaultRegistry is used if nil
+func PrometheusHandler(registry *Registry) http.Handler {
+	if nil == registry {
+		registry = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		WritePrometheus(w, registry)
+	})
+}
+
+func writePrometheusSample(b *bufio.Writer, name string, labels string, value float64) {
+	b.WriteString(name)
+	if "" != labels {
+		b.WriteString("{" + labels + "}")
+	}
+	b.WriteString(" " + formatPrometheusValue(value) + "\n")
+}
+
+func prometheusLabels(names []string, values []string) string {
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapePrometheusLabel(values[i])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func appendPrometheusLabel(labels string, name string, value string) string {
+	if "" == labels {
+		return name + `="` + value + `"`
+	}
+	return labels + "," + name + `="` + value + `"`
+}
+
+func formatPrometheusValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var prometheusHelpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapePrometheusLabel(s string) string {
+	return prometheusLabelReplacer.Replace(s)
+}
+
+func escapePrometheusHelp(s string) string {
+	return prometheusHelpReplacer.Replace(s)
+}
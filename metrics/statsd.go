@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+)
+
+// Constants of statsd
+const (
+	DefaultStatsDInterval = 10 * time.Second
+	statsdMaxPacketSize   = 1400
+)
+
+// StatsDConfig statsd pusher config
+type StatsDConfig struct {
+	Address  string `yaml:"address"`  // host:port of udp
+	Prefix   string `yaml:"prefix"`   // prefix of metric names such as "service."
+	Interval int    `yaml:"interval"` // seconds, 10 if 0
+}
+
+// StatsDPusher pushes the metrics of registry to statsd every interval, the counters and the counts and sums
+// of histograms are pushed as the increments since the last push, the gauges as values, and the labels as
+// dogstatsd tags
+type StatsDPusher struct {
+	registry *Registry
+	conn     net.Conn
+	prefix   string
+	interval time.Duration
+	previous map[string]float64
+	m        sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewStatsDPusher pusher of registry to the statsd of config, DefaultRegistry is used if registry nil
+func NewStatsDPusher(registry *Registry, config StatsDConfig) (*StatsDPusher, error) {
+	if nil == registry {
+		registry = DefaultRegistry
+	}
+	conn, err := net.Dial("udp", config.Address)
+	if nil != err {
+		return nil, err
+	}
+	interval := time.Duration(config.Interval) * time.Second
+	if interval <= 0 {
+		interval = DefaultStatsDInterval
+	}
+	return &StatsDPusher{
+		registry: registry,
+		conn:     conn,
+		prefix:   config.Prefix,
+		interval: interval,
+		previous: map[string]float64{},
+	}, nil
+}
+
+// Start pushing every interval until Stop
+func (p *StatsDPusher) Start() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if nil != p.stop {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop(p.stop, p.done)
+}
+
+// Stop pushing, the metrics changed since the last push are pushed before stopped
+func (p *StatsDPusher) Stop() {
+	p.m.Lock()
+	stop, done := p.stop, p.done
+	p.stop = nil
+	p.m.Unlock()
+	if nil != stop {
+		close(stop)
+		<-done
+	}
+	p.Push()
+	p.conn.Close()
+}
+
+func (p *StatsDPusher) loop(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.Push()
+		}
+	}
+}
+
+// Push the metrics now, the lines are batched into packets
+func (p *StatsDPusher) Push() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	packet := bytes.Buffer{}
+	var lastErr error
+	write := func(line string) {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > statsdMaxPacketSize {
+			if _, err := p.conn.Write(packet.Bytes()); nil != err {
+				lastErr = err
+			}
+			packet.Reset()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	for _, snapshot := range p.registry.Snapshots() {
+		for _, s := range snapshot.Samples {
+			tags := statsdTags(snapshot.LabelNames, s.LabelValues)
+			switch snapshot.Type {
+			case TypeGauge:
+				write(p.line(snapshot.Name, formatPrometheusValue(s.Value), "g", tags))
+			case TypeCounter:
+				if delta := p.delta(snapshot.Name, tags, s.Value); delta > 0 {
+					write(p.line(snapshot.Name, formatPrometheusValue(delta), "c", tags))
+				}
+			case TypeHistogram:
+				if delta := p.delta(snapshot.Name+"_count", tags, float64(s.Count)); delta > 0 {
+					write(p.line(snapshot.Name+"_count", formatPrometheusValue(delta), "c", tags))
+					write(p.line(snapshot.Name+"_sum", formatPrometheusValue(p.delta(snapshot.Name+"_sum", tags, s.Sum)), "c", tags))
+				}
+			}
+		}
+	}
+	if packet.Len() > 0 {
+		if _, err := p.conn.Write(packet.Bytes()); nil != err {
+			lastErr = err
+		}
+	}
+	if nil != lastErr {
+		logger.Warning.Printf("push metrics to statsd %s failed with error:%v", p.conn.RemoteAddr(), lastErr)
+	}
+	return lastErr
+}
+
+// delta of value since the last push with m locked
+func (p *StatsDPusher) delta(name string, tags string, value float64) float64 {
+	key := name + "|" + tags
+	delta := value - p.previous[key]
+	p.previous[key] = value
+	return delta
+}
+
+func (p *StatsDPusher) line(name string, value string, typ string, tags string) string {
+	line := p.prefix + name + ":" + value + "|" + typ
+	if "" != tags {
+		line += "|#" + tags
+	}
+	return line
+}
+
+func statsdTags(names []string, values []string) string {
+	tags := make([]string, 0, len(names))
+	for i, name := range names {
+		tags = append(tags, name+":"+statsdTagReplacer.Replace(values[i]))
+	}
+	return strings.Join(tags, ",")
+}
+
+var statsdTagReplacer = strings.NewReplacer(",", "_", "|", "_", "\n", "_", "#", "_")
@@ -0,0 +1,180 @@
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// Constants of deduplication
+const (
+	DefaultProcessingTTL = 5 * time.Minute
+	DefaultRetention     = 24 * time.Hour
+)
+
+// ErrDuplicate the message is processing or processed
+var ErrDuplicate = errors.New("duplicate message")
+
+// Store keeps the keys of the messages processing or processed
+type Store interface {
+	// Reserve marks the key processing for ttl, returns false if the key is processing or processed
+	Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Complete marks the key processed for ttl
+	Complete(ctx context.Context, key string, ttl time.Duration) error
+	// Release removes the key so that the message could be processed again
+	Release(ctx context.Context, key string) error
+}
+
+// KeyFunc returns the deduplication key of message, the message is processed without deduplication if empty
+type KeyFunc func(msg mqenv.MQConsumerMessage) string
+
+// DefaultKeyFunc the MessageID, or the CorrelationID if MessageID empty
+func DefaultKeyFunc(msg mqenv.MQConsumerMessage) string {
+	if "" != msg.MessageID {
+		return msg.MessageID
+	}
+	return msg.CorrelationID
+}
+
+// Option options of deduplicator
+type Option func(*Deduplicator)
+
+// WithKeyFunc the deduplication key of message, DefaultKeyFunc by default
+func WithKeyFunc(keyFunc KeyFunc) Option {
+	return func(d *Deduplicator) {
+		d.keyFunc = keyFunc
+	}
+}
+
+// WithKeyPrefix the prefix of keys in store, the consumers processing the same messages independently
+// such as different services should use different prefixes
+func WithKeyPrefix(prefix string) Option {
+	return func(d *Deduplicator) {
+		d.prefix = prefix
+	}
+}
+
+// WithProcessingTTL the time that a message processing blocks its duplicates, it should be longer than the processing
+// and the key is released after it if the consumer crashed while processing
+func WithProcessingTTL(ttl time.Duration) Option {
+	return func(d *Deduplicator) {
+		d.processingTTL = ttl
+	}
+}
+
+// WithRetention the time that the processed keys are kept, it should be longer than the redelivery window of the broker
+func WithRetention(retention time.Duration) Option {
+	return func(d *Deduplicator) {
+		d.retention = retention
+	}
+}
+
+// Deduplicator processes every message once by the key kept in store, so that the redelivered messages do not repeat the side effects.
+// The key is reserved before processing, marked processed after succeeded and released after failed so that the redelivery is processed again
+type Deduplicator struct {
+	store         Store
+	keyFunc       KeyFunc
+	prefix        string
+	processingTTL time.Duration
+	retention     time.Duration
+}
+
+// NewDeduplicator new deduplicator by store
+func NewDeduplicator(store Store, options ...Option) *Deduplicator {
+	d := &Deduplicator{
+		store:         store,
+		keyFunc:       DefaultKeyFunc,
+		processingTTL: DefaultProcessingTTL,
+		retention:     DefaultRetention,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// Process runs fn if the message is not processing or processed, returns ErrDuplicate without running fn otherwise.
+// The key is released if fn returns an error or panics
+func (d *Deduplicator) Process(ctx context.Context, msg mqenv.MQConsumerMessage, fn func() error) error {
+	key := d.keyFunc(msg)
+	if "" == key {
+		return fn()
+	}
+	key = d.prefix + key
+	ok, err := d.store.Reserve(ctx, key, d.processingTTL)
+	if nil != err {
+		return fmt.Errorf("reserve message key:%s failed with error:%w", key, err)
+	}
+	if !ok {
+		return ErrDuplicate
+	}
+	completed := false
+	defer func() {
+		if !completed {
+			if err := d.store.Release(context.Background(), key); nil != err {
+				logger.Error.Printf("release message key:%s failed with error:%v", key, err)
+			}
+		}
+	}()
+	if err = fn(); nil != err {
+		return err
+	}
+	completed = true
+	if err = d.store.Complete(ctx, key, d.retention); nil != err {
+		logger.Error.Printf("complete message key:%s failed with error:%v", key, err)
+	}
+	return nil
+}
+
+// Wrap returns the callback skipping the duplicate messages without reply, the message is processed
+// if the store is unavailable so that no message is lost
+func (d *Deduplicator) Wrap(callback mqenv.MQConsumerCallback) mqenv.MQConsumerCallback {
+	return func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		var resp *mqenv.MQPublishMessage
+		err := d.Process(context.Background(), msg, func() error {
+			resp = callback(msg)
+			return nil
+		})
+		switch {
+		case nil == err:
+			return resp
+		case errors.Is(err, ErrDuplicate):
+			logger.Info.Printf("skipping duplicate message(%s) of queue:%s", d.keyFunc(msg), msg.Queue)
+			return nil
+		}
+		logger.Error.Printf("deduplicate message of queue:%s failed with error:%v, processing it", msg.Queue, err)
+		return callback(msg)
+	}
+}
+
+// Interceptor the consume interceptor deduplicating the messages as Wrap, see mq.UseConsumeInterceptors
+func (d *Deduplicator) Interceptor() mq.ConsumeInterceptor {
+	return func(mqCategory string, msg mqenv.MQConsumerMessage, next mqenv.MQConsumerCallback) *mqenv.MQPublishMessage {
+		return d.Wrap(next)(msg)
+	}
+}
+
+// Middleware the route middleware skipping the duplicate messages, the key is released if the handler failed
+// so that the retries of route process the message again, the store errors are returned to be retried
+func (d *Deduplicator) Middleware() mq.RouteMiddleware {
+	return func(next mq.RouteHandler) mq.RouteHandler {
+		return func(msg mqenv.MQConsumerMessage) (*mqenv.MQPublishMessage, error) {
+			var resp *mqenv.MQPublishMessage
+			err := d.Process(context.Background(), msg, func() error {
+				var err error
+				resp, err = next(msg)
+				return err
+			})
+			if errors.Is(err, ErrDuplicate) {
+				logger.Info.Printf("skipping duplicate message(%s) of queue:%s", d.keyFunc(msg), msg.Queue)
+				return nil, nil
+			}
+			return resp, err
+		}
+	}
+}
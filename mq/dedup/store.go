@@ -0,0 +1,114 @@
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Constants of stores
+const (
+	DefaultRedisKeyPrefix = "dedup:"
+
+	memorySweepInterval = time.Minute
+
+	stateProcessing = "processing"
+	stateProcessed  = "processed"
+)
+
+// MemoryStore keeps the keys in the current process with ttl, for tests and single instance deployments
+type MemoryStore struct {
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+type memoryEntry struct {
+	state   string
+	expires time.Time
+}
+
+// NewMemoryStore store of the current process
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]memoryEntry{}, lastSweep: time.Now()}
+}
+
+// Reserve marks the key processing if not exists
+func (s *MemoryStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sweep(now)
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		return false, nil
+	}
+	s.entries[key] = memoryEntry{state: stateProcessing, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Complete marks the key processed
+func (s *MemoryStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	s.mutex.Lock()
+	s.entries[key] = memoryEntry{state: stateProcessed, expires: time.Now().Add(ttl)}
+	s.mutex.Unlock()
+	return nil
+}
+
+// Release removes the key
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	delete(s.entries, key)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Len the count of keys kept including the expired ones not swept yet
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+// sweep removes the expired keys at most once every memorySweepInterval, the caller should hold the lock
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// RedisStore keeps the keys in redis as {prefix}{key} with ttl, shared by the instances of consumers
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore store of redis client, DefaultRedisKeyPrefix is used if prefix empty
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	if "" == prefix {
+		prefix = DefaultRedisKeyPrefix
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Reserve SET NX the key processing
+func (s *RedisStore) Reserve(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(s.prefix+key, stateProcessing, ttl).Result()
+}
+
+// Complete SET the key processed
+func (s *RedisStore) Complete(ctx context.Context, key string, ttl time.Duration) error {
+	return s.client.Set(s.prefix+key, stateProcessed, ttl).Err()
+}
+
+// Release DEL the key
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(s.prefix + key).Err()
+}
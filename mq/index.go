@@ -1,6 +1,7 @@
 package mq
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -10,8 +11,10 @@ import (
 	"github.com/libpub/golib/mq/kafka"
 	"github.com/libpub/golib/mq/mockmq"
 	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/mq/nats"
 	"github.com/libpub/golib/mq/pulsar"
 	"github.com/libpub/golib/mq/rabbitmq"
+	"github.com/libpub/golib/mq/redisstream"
 )
 
 // Constants
@@ -130,14 +133,32 @@ func InitMQTopic(topicCategory string, topicConfig *Config, mqDriverConfigs map[
 			hosts = strings.Join(hostParts, ",")
 		}
 		kafakCfg := kafka.Config{
-			Hosts:              hosts,
-			Partition:          topicConfig.Partition,
-			GroupID:            topicConfig.GroupID,
-			MaxPollIntervalMS:  topicConfig.MaxPollIntervalMS,
-			SaslUsername:       instCnf.User,
-			SaslPassword:       instCnf.Password,
-			MessageType:        topicConfig.MessageType,
-			UseOriginalContent: topicConfig.UseOriginalContent,
+			Hosts:                 hosts,
+			Partition:             topicConfig.Partition,
+			GroupID:               topicConfig.GroupID,
+			MaxPollIntervalMS:     topicConfig.MaxPollIntervalMS,
+			SaslUsername:          instCnf.User,
+			SaslPassword:          instCnf.Password,
+			MessageType:           topicConfig.MessageType,
+			UseOriginalContent:    topicConfig.UseOriginalContent,
+			Concurrency:           topicConfig.Concurrency,
+			MaxInFlight:           topicConfig.MaxInFlight,
+			PartitionOrdering:     topicConfig.PartitionOrdering,
+			OffsetMode:            topicConfig.OffsetMode,
+			StartOffset:           topicConfig.StartOffset,
+			StartTimestamp:        topicConfig.StartTimestamp,
+			SchemaRegistry:        topicConfig.SchemaRegistry,
+			TransactionalID:       topicConfig.TransactionalID,
+			TransactionTimeoutMS:  topicConfig.TransactionTimeoutMS,
+			ReconnectBackoffMS:    topicConfig.ReconnectBackoffMS,
+			ReconnectBackoffMaxMS: topicConfig.ReconnectBackoffMaxMS,
+			Compression:           topicConfig.Compression,
+			MaxMessageBytes:       topicConfig.MaxMessageBytes,
+		}
+		if "" != topicConfig.ValueSchema.Type {
+			kafakCfg.TopicSchemas = map[string]kafka.TopicSchema{
+				topicConfig.Topic: topicConfig.ValueSchema,
+			}
 		}
 		_, initErr = kafka.InitKafka(topicCategory, kafakCfg)
 	case mqenv.DriverTypePulsar:
@@ -150,8 +171,40 @@ func InitMQTopic(topicCategory string, topicConfig *Config, mqDriverConfigs map[
 			pulsarCfg.Topic = topicConfig.Queue
 		}
 		_, initErr = pulsar.InitPulsarMQ(topicCategory, &instCnf, pulsarCfg)
+	case mqenv.DriverTypeNats:
+		natsCfg := &nats.Config{
+			ConnConfigName: topicConfig.Instance,
+			Subject:        topicConfig.Topic,
+			QueueGroup:     topicConfig.GroupID,
+			MessageType:    topicConfig.MessageType,
+			JetStream:      topicConfig.JetStream,
+			Stream:         topicConfig.Stream,
+			Durable:        topicConfig.DurableName,
+			AckWaitSeconds: topicConfig.AckWaitSeconds,
+			MaxDeliver:     topicConfig.MaxDeliver,
+		}
+		if "" == natsCfg.Subject && "" != topicConfig.Queue {
+			natsCfg.Subject = topicConfig.Queue
+		}
+		_, initErr = nats.InitNatsMQ(topicCategory, &instCnf, natsCfg)
+	case mqenv.DriverTypeRedisStream:
+		streamCfg := &redisstream.Config{
+			ConnConfigName:   topicConfig.Instance,
+			Stream:           topicConfig.Topic,
+			Group:            topicConfig.GroupID,
+			MessageType:      topicConfig.MessageType,
+			MaxLen:           topicConfig.MaxLen,
+			BatchSize:        topicConfig.BatchSize,
+			ClaimIdleSeconds: topicConfig.ClaimIdleSeconds,
+		}
+		if "" == streamCfg.Stream && "" != topicConfig.Queue {
+			streamCfg.Stream = topicConfig.Queue
+		}
+		_, initErr = redisstream.InitRedisStreamMQ(topicCategory, &instCnf, streamCfg)
 	case mqenv.DriverTypeMock:
-		mockCfg := mockmq.Config{}
+		mockCfg := mockmq.Config{
+			Topic: topicConfig.Topic,
+		}
 		_, initErr = mockmq.InitMockMQ(topicCategory, &instCnf, &mockCfg)
 	default:
 		initErr = fmt.Errorf("initialize mq:%s with unknown driver:%s", topicCategory, instCnf.Driver)
@@ -269,106 +322,110 @@ func GetPulsar(name string) (*pulsar.PulsarMQ, error) {
 	return pulsar.GetPulsarMQ(name)
 }
 
-// ConsumeMQ consume
-func ConsumeMQ(mqCategory string, consumeProxy *mqenv.MQConsumerProxy) error {
-	var err error
-	mqConfig := GetMQConfig(mqCategory)
-	if nil == mqConfig {
-		return fmt.Errorf("consume MQ with invalid category:%s", mqCategory)
-	}
-	mqCategoryDriversMutex.RLock()
-	mqDriver := mqCategoryDrivers[mqCategory]
-	mqCategoryDriversMutex.RUnlock()
+// GetNats get nats instance
+func GetNats(name string) (*nats.NatsMQ, error) {
+	return nats.GetNatsMQ(name)
+}
+
+// GetRedisStream get redis stream instance
+func GetRedisStream(name string) (*redisstream.RedisStreamMQ, error) {
+	return redisstream.GetRedisStreamMQ(name)
+}
+
+// GetMockMQ get mock mq instance for controlling the deliveries in tests
+func GetMockMQ(name string) (*mockmq.MockMQ, error) {
+	return mockmq.GetMockMQ(name)
+}
+
+// GetMQDriver returns the produce/consume driver of mq category
+func GetMQDriver(mqCategory string) (mqenv.MQDriver, error) {
+	mqDriver := getMQCategoryDriverType(mqCategory)
 	switch mqDriver {
 	case mqenv.DriverTypeAMQP:
-		if mqConfig.RPCEnabled {
-			rpcInst := rabbitmq.GetRPCRabbitMQWithoutConnectedChecking(mqCategory)
-			if nil == rpcInst {
-				return fmt.Errorf("no RPC rabbitmq instance by %s found", mqCategory)
-			}
-			pxy := rabbitmq.GenerateRabbitMQConsumerProxy(consumeProxy, mqConfig.Exchange.Name)
-			rpcInst.Consume <- pxy
-		} else {
-			inst, err := rabbitmq.GetRabbitMQ(mqCategory)
-			if nil != err {
-				return err
-			}
-			pxy := rabbitmq.GenerateRabbitMQConsumerProxy(consumeProxy, mqConfig.Exchange.Name)
-			inst.Consume <- pxy
+		inst, err := rabbitmq.GetRabbitMQ(mqCategory)
+		if nil != err {
+			return nil, err
 		}
+		return inst, nil
 	case mqenv.DriverTypeKafka:
 		inst, err := kafka.GetKafka(mqCategory)
 		if nil != err {
-			return err
+			return nil, err
 		}
-		inst.Subscribe(consumeProxy.Queue, consumeProxy)
+		return inst, nil
 	case mqenv.DriverTypePulsar:
 		inst, err := pulsar.GetPulsarMQ(mqCategory)
 		if nil != err {
-			return err
+			return nil, err
+		}
+		return inst, nil
+	case mqenv.DriverTypeNats:
+		inst, err := nats.GetNatsMQ(mqCategory)
+		if nil != err {
+			return nil, err
+		}
+		return inst, nil
+	case mqenv.DriverTypeRedisStream:
+		inst, err := redisstream.GetRedisStreamMQ(mqCategory)
+		if nil != err {
+			return nil, err
 		}
-		inst.Consume <- consumeProxy
+		return inst, nil
 	case mqenv.DriverTypeMock:
 		inst, err := mockmq.GetMockMQ(mqCategory)
 		if nil != err {
-			return err
+			return nil, err
 		}
-		inst.Subscribe(consumeProxy.Queue, consumeProxy)
+		return inst, nil
 	default:
-		logger.Error.Printf("Consume MQ with category:%s failed, unknwon driver:%s", mqCategory, mqDriver)
-		err = fmt.Errorf("invalid mq %s driver", mqCategory)
+		return nil, fmt.Errorf("invalid mq %s driver:%s", mqCategory, mqDriver)
+	}
+}
+
+// ConsumeMQ consume
+func ConsumeMQ(mqCategory string, consumeProxy *mqenv.MQConsumerProxy) error {
+	mqConfig := GetMQConfig(mqCategory)
+	if nil == mqConfig {
+		return fmt.Errorf("consume MQ with invalid category:%s", mqCategory)
 	}
-	return err
+	consumeProxy = interceptConsumeProxy(mqCategory, mqConfig, consumeProxy)
+	if mqConfig.RPCEnabled && DriverTypeAMQP == getMQCategoryDriverType(mqCategory) {
+		rpcInst := rabbitmq.GetRPCRabbitMQWithoutConnectedChecking(mqCategory)
+		if nil == rpcInst {
+			return fmt.Errorf("no RPC rabbitmq instance by %s found", mqCategory)
+		}
+		return rpcInst.ConsumeMessage(consumeProxy)
+	}
+	inst, err := GetMQDriver(mqCategory)
+	if nil != err {
+		logger.Error.Printf("Consume MQ with category:%s failed with error:%v", mqCategory, err)
+		return err
+	}
+	return inst.ConsumeMessage(consumeProxy)
 }
 
 // PublishMQ publish
 func PublishMQ(mqCategory string, publishMsg *mqenv.MQPublishMessage) error {
-	var err error
 	mqConfig := GetMQConfig(mqCategory)
 	if nil == mqConfig {
 		return fmt.Errorf("publish MQ with invalid category:%s", mqCategory)
 	}
-	mqCategoryDriversMutex.RLock()
-	mqDriver := mqCategoryDrivers[mqCategory]
-	mqCategoryDriversMutex.RUnlock()
-	switch mqDriver {
-	case mqenv.DriverTypeAMQP:
-		if mqConfig.RPCEnabled {
-			rpcInst := rabbitmq.GetRPCRabbitMQWithConsumers(mqCategory)
-			if nil == rpcInst {
-				return fmt.Errorf("no RPC rabbitmq instance by %s found or there is no backend consumers ready", mqCategory)
-			}
-			rpcInst.Publish <- publishMsg
-		} else {
-			inst, err := rabbitmq.GetRabbitMQ(mqCategory)
-			if nil != err {
-				return err
-			}
-			inst.Publish <- publishMsg
-		}
-	case mqenv.DriverTypeKafka:
-		inst, err := kafka.GetKafka(mqCategory)
-		if nil != err {
-			return err
-		}
-		inst.Send(publishMsg.Exchange, publishMsg, false)
-	case mqenv.DriverTypePulsar:
-		inst, err := pulsar.GetPulsarMQ(mqCategory)
-		if nil != err {
-			return err
+	var inst mqenv.MQDriver
+	if mqConfig.RPCEnabled && DriverTypeAMQP == getMQCategoryDriverType(mqCategory) {
+		rpcInst := rabbitmq.GetRPCRabbitMQWithConsumers(mqCategory)
+		if nil == rpcInst {
+			return fmt.Errorf("no RPC rabbitmq instance by %s found or there is no backend consumers ready", mqCategory)
 		}
-		inst.Publish <- publishMsg
-	case mqenv.DriverTypeMock:
-		inst, err := mockmq.GetMockMQ(mqCategory)
+		inst = rpcInst
+	} else {
+		var err error
+		inst, err = GetMQDriver(mqCategory)
 		if nil != err {
+			logger.Error.Printf("Publish MQ with category:%s failed with error:%v", mqCategory, err)
 			return err
 		}
-		inst.Send(mqConfig.Topic, publishMsg, false)
-	default:
-		logger.Error.Printf("Publish MQ with category:%s failed, unknwon driver:%s", mqCategory, mqDriver)
-		err = fmt.Errorf("invalid mq %s driver", mqCategory)
 	}
-	return err
+	return interceptPublish(mqCategory, mqConfig, publishMsg, inst.PublishMessage)
 }
 
 // QueryMQ publishes a message and waiting the response
@@ -377,37 +434,54 @@ func QueryMQ(mqCategory string, pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMe
 	if nil == mqConfig {
 		return nil, fmt.Errorf("query RPC MQ with invalid category:%s", mqCategory)
 	}
+	inst, err := GetMQDriver(mqCategory)
+	if nil != err {
+		return nil, err
+	}
+	var resp *mqenv.MQConsumerMessage
+	err = interceptPublish(mqCategory, mqConfig, pm, func(m *mqenv.MQPublishMessage) error {
+		var queryErr error
+		resp, queryErr = inst.QueryMessage(m)
+		return queryErr
+	})
+	return resp, err
+}
+
+// PingMQ verifies the connectivity to the brokers of mq category
+func PingMQ(ctx context.Context, mqCategory string) error {
+	checker, err := getMQHealthChecker(mqCategory)
+	if nil != err {
+		return err
+	}
+	return checker.Ping(ctx)
+}
+
+// HealthCheckMQ checks the brokers and subscriptions of mq category, the status could be serialized as json for readiness endpoints
+func HealthCheckMQ(ctx context.Context, mqCategory string) (*mqenv.MQHealthStatus, error) {
+	checker, err := getMQHealthChecker(mqCategory)
+	if nil != err {
+		return nil, err
+	}
+	return checker.HealthCheck(ctx), nil
+}
+
+func getMQHealthChecker(mqCategory string) (mqenv.MQHealthChecker, error) {
+	inst, err := GetMQDriver(mqCategory)
+	if nil != err {
+		return nil, err
+	}
+	checker, ok := inst.(mqenv.MQHealthChecker)
+	if !ok {
+		return nil, fmt.Errorf("mq %s driver:%s does not support health checks", mqCategory, inst.DriverType())
+	}
+	return checker, nil
+}
+
+func getMQCategoryDriverType(mqCategory string) string {
 	mqCategoryDriversMutex.RLock()
 	mqDriver := mqCategoryDrivers[mqCategory]
 	mqCategoryDriversMutex.RUnlock()
-	switch mqDriver {
-	case mqenv.DriverTypeAMQP:
-		inst, err := rabbitmq.GetRabbitMQ(mqCategory)
-		if nil != err {
-			return nil, err
-		}
-		return inst.QueryRPC(pm)
-	case mqenv.DriverTypeKafka:
-		inst, err := kafka.GetKafka(mqCategory)
-		if nil != err {
-			return nil, err
-		}
-		return inst.Send(pm.Exchange, pm, true)
-	case mqenv.DriverTypePulsar:
-		inst, err := pulsar.GetPulsarMQ(mqCategory)
-		if nil != err {
-			return nil, err
-		}
-		return inst.QueryRPC(pm)
-	case mqenv.DriverTypeMock:
-		inst, err := mockmq.GetMockMQ(mqCategory)
-		if nil != err {
-			return nil, err
-		}
-		return inst.Send(mqConfig.Topic, pm, true)
-	default:
-		return nil, fmt.Errorf("query RPC MQ not supported driver:%s", mqDriver)
-	}
+	return mqDriver
 }
 
 // QueryMQRPC publishes a message and waiting the response
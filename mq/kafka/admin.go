@@ -0,0 +1,307 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	k "github.com/segmentio/kafka-go"
+)
+
+// Constants
+const (
+	DefaultAdminTimeout = 30 * time.Second // 管理请求未设置context 超时时的默认值
+)
+
+// TopicSpec 创建topic 的参数.
+type TopicSpec struct {
+	Name              string            `yaml:"name" json:"name"`
+	Partitions        int               `yaml:"partitions" json:"partitions"`               // 分区数，<=0 时为1
+	ReplicationFactor int               `yaml:"replicationFactor" json:"replicationFactor"` // 副本数，<=0 时为1
+	RetentionMS       int64             `yaml:"retentionMs" json:"retentionMs"`             // 消息保留时间(毫秒)，0 使用broker 默认值，-1 永久保留
+	RetentionBytes    int64             `yaml:"retentionBytes" json:"retentionBytes"`       // 每个分区保留的最大字节数，0 使用broker 默认值
+	Configs           map[string]string `yaml:"configs" json:"configs"`                     // 其它topic 级别配置，如cleanup.policy
+}
+
+// TopicInfo topic 描述信息.
+type TopicInfo struct {
+	Name              string `json:"name"`
+	Partitions        int    `json:"partitions"`
+	ReplicationFactor int    `json:"replicationFactor"`
+	Internal          bool   `json:"internal"`
+}
+
+// PartitionLag 消费者组在某个分区上的消费进度.
+type PartitionLag struct {
+	Topic           string `json:"topic"`
+	Partition       int    `json:"partition"`
+	CommittedOffset int64  `json:"committedOffset"` // -1 表示还没有提交过
+	LatestOffset    int64  `json:"latestOffset"`
+	Lag             int64  `json:"lag"`
+}
+
+// ConsumerGroupInfo 消费者组描述信息.
+type ConsumerGroupInfo struct {
+	GroupID  string         `json:"groupId"`
+	State    string         `json:"state"`
+	Members  []string       `json:"members"`
+	Lags     []PartitionLag `json:"lags"`
+	TotalLag int64          `json:"totalLag"`
+}
+
+// Admin kafka 管理客户端，用于topic 的创建、删除和查询以及消费者组的描述.
+type Admin struct {
+	Base
+	Brokers []string
+	client  *k.Client
+}
+
+// NewAdmin 返回一个管理客户端，hosts 格式如"localhost:9092,localhost:9093".
+func NewAdmin(hosts string) *Admin {
+	a := &Admin{}
+	a.Config = make(map[string]interface{})
+	a.Brokers = strings.Split(hosts, ",")
+	return a
+}
+
+// Admin 使用worker 的连接配置返回管理客户端.
+func (worker *KafkaWorker) Admin() *Admin {
+	a := &Admin{}
+	a.Config = make(map[string]interface{})
+	for key, value := range worker.Producer.Config {
+		a.Config[key] = value
+	}
+	a.Brokers = worker.Producer.Brokers
+	return a
+}
+
+// getClient 延迟创建kafka-go 客户端.
+func (a *Admin) getClient() *k.Client {
+	if a.client == nil {
+		transport := &k.Transport{
+			SASL: a.saslMechanism(),
+		}
+		a.client = &k.Client{
+			Addr:      k.TCP(a.Brokers...),
+			Timeout:   DefaultAdminTimeout,
+			Transport: transport,
+		}
+	}
+	return a.client
+}
+
+// CreateTopic 创建topic，topic 已存在时返回kafka.TopicAlreadyExists 错误.
+func (a *Admin) CreateTopic(ctx context.Context, spec TopicSpec) error {
+	if spec.Name == "" {
+		return errors.New("create kafka topic with empty name")
+	}
+	if spec.Partitions <= 0 {
+		spec.Partitions = 1
+	}
+	if spec.ReplicationFactor <= 0 {
+		spec.ReplicationFactor = 1
+	}
+	topicConfig := k.TopicConfig{
+		Topic:             spec.Name,
+		NumPartitions:     spec.Partitions,
+		ReplicationFactor: spec.ReplicationFactor,
+		ConfigEntries:     []k.ConfigEntry{},
+	}
+	if spec.RetentionMS != 0 {
+		topicConfig.ConfigEntries = append(topicConfig.ConfigEntries, k.ConfigEntry{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(spec.RetentionMS, 10)})
+	}
+	if spec.RetentionBytes != 0 {
+		topicConfig.ConfigEntries = append(topicConfig.ConfigEntries, k.ConfigEntry{ConfigName: "retention.bytes", ConfigValue: strconv.FormatInt(spec.RetentionBytes, 10)})
+	}
+	for name, value := range spec.Configs {
+		topicConfig.ConfigEntries = append(topicConfig.ConfigEntries, k.ConfigEntry{ConfigName: name, ConfigValue: value})
+	}
+	resp, err := a.getClient().CreateTopics(ctx, &k.CreateTopicsRequest{
+		Topics: []k.TopicConfig{topicConfig},
+	})
+	if err != nil {
+		return err
+	}
+	return resp.Errors[spec.Name]
+}
+
+// EnsureTopic 创建topic，topic 已存在时不返回错误.
+func (a *Admin) EnsureTopic(ctx context.Context, spec TopicSpec) error {
+	err := a.CreateTopic(ctx, spec)
+	if errors.Is(err, k.TopicAlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+// DeleteTopic 删除topic.
+func (a *Admin) DeleteTopic(ctx context.Context, topics ...string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+	resp, err := a.getClient().DeleteTopics(ctx, &k.DeleteTopicsRequest{
+		Topics: topics,
+	})
+	if err != nil {
+		return err
+	}
+	failures := []string{}
+	for _, topic := range topics {
+		if topicErr := resp.Errors[topic]; topicErr != nil {
+			failures = append(failures, fmt.Sprintf("%s:%v", topic, topicErr))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("delete kafka topics failed with errors:%s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// ListTopics 返回集群中所有topic，includeInternal 为false 时忽略__consumer_offsets 这类内部topic.
+func (a *Admin) ListTopics(ctx context.Context, includeInternal bool) ([]TopicInfo, error) {
+	resp, err := a.getClient().Metadata(ctx, &k.MetadataRequest{})
+	if err != nil {
+		return nil, err
+	}
+	results := []TopicInfo{}
+	for _, t := range resp.Topics {
+		if t.Internal && !includeInternal {
+			continue
+		}
+		info := TopicInfo{
+			Name:       t.Name,
+			Partitions: len(t.Partitions),
+			Internal:   t.Internal,
+		}
+		if len(t.Partitions) > 0 {
+			info.ReplicationFactor = len(t.Partitions[0].Replicas)
+		}
+		results = append(results, info)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+// DescribeConsumerGroup 返回消费者组的状态、成员和每个分区的消费延迟.
+// topics 为空时使用组内成员当前分配到的topic，组内没有活跃成员时需要显式指定topics 才能计算延迟.
+func (a *Admin) DescribeConsumerGroup(ctx context.Context, groupID string, topics ...string) (*ConsumerGroupInfo, error) {
+	client := a.getClient()
+	groupsResp, err := client.DescribeGroups(ctx, &k.DescribeGroupsRequest{
+		GroupIDs: []string{groupID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := &ConsumerGroupInfo{
+		GroupID: groupID,
+		Members: []string{},
+		Lags:    []PartitionLag{},
+	}
+	topicSet := map[string]bool{}
+	for _, topic := range topics {
+		topicSet[topic] = true
+	}
+	for _, group := range groupsResp.Groups {
+		if group.GroupID != groupID {
+			continue
+		}
+		if group.Error != nil {
+			return nil, group.Error
+		}
+		info.State = group.GroupState
+		for _, member := range group.Members {
+			info.Members = append(info.Members, member.ClientID+"@"+member.ClientHost)
+			if len(topics) == 0 {
+				for _, assigned := range member.MemberAssignments.Topics {
+					topicSet[assigned.Topic] = true
+				}
+			}
+		}
+	}
+	if len(topicSet) == 0 {
+		return info, nil
+	}
+
+	topicNames := make([]string, 0, len(topicSet))
+	for topic := range topicSet {
+		topicNames = append(topicNames, topic)
+	}
+	sort.Strings(topicNames)
+	metaResp, err := client.Metadata(ctx, &k.MetadataRequest{Topics: topicNames})
+	if err != nil {
+		return nil, err
+	}
+	partitions := map[string][]int{}
+	offsetRequests := map[string][]k.OffsetRequest{}
+	for _, t := range metaResp.Topics {
+		if t.Error != nil {
+			return nil, fmt.Errorf("describe kafka topic:%s failed with error:%w", t.Name, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions[t.Name] = append(partitions[t.Name], p.ID)
+			offsetRequests[t.Name] = append(offsetRequests[t.Name], k.LastOffsetOf(p.ID))
+		}
+	}
+
+	committedResp, err := client.OffsetFetch(ctx, &k.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  partitions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if committedResp.Error != nil {
+		return nil, committedResp.Error
+	}
+	latestResp, err := client.ListOffsets(ctx, &k.ListOffsetsRequest{
+		Topics: offsetRequests,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	latestOffsets := map[string]map[int]int64{}
+	for topic, offsets := range latestResp.Topics {
+		latestOffsets[topic] = map[int]int64{}
+		for _, po := range offsets {
+			latestOffsets[topic][po.Partition] = po.LastOffset
+		}
+	}
+	for _, topic := range topicNames {
+		for _, committed := range committedResp.Topics[topic] {
+			lag := PartitionLag{
+				Topic:           topic,
+				Partition:       committed.Partition,
+				CommittedOffset: committed.CommittedOffset,
+				LatestOffset:    latestOffsets[topic][committed.Partition],
+			}
+			lag.Lag = calculateLag(lag.CommittedOffset, lag.LatestOffset)
+			info.TotalLag += lag.Lag
+			info.Lags = append(info.Lags, lag)
+		}
+	}
+	sort.Slice(info.Lags, func(i, j int) bool {
+		if info.Lags[i].Topic == info.Lags[j].Topic {
+			return info.Lags[i].Partition < info.Lags[j].Partition
+		}
+		return info.Lags[i].Topic < info.Lags[j].Topic
+	})
+	return info, nil
+}
+
+// calculateLag 计算消费延迟，没有提交过偏移量时视为落后全部消息.
+func calculateLag(committed int64, latest int64) int64 {
+	if committed < 0 {
+		committed = 0
+	}
+	if latest < committed {
+		return 0
+	}
+	return latest - committed
+}
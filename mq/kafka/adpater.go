@@ -2,14 +2,21 @@ package kafka
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/libpub/golib/health"
 	"github.com/libpub/golib/mq/mqenv"
 	"github.com/libpub/golib/utils"
+	"golang.org/x/sync/singleflight"
 )
 
-// kafkaInstances kafka 实例.
-var kafkaInstances = map[string]*KafkaWorker{}
+var (
+	kafkaInstances      = map[string]*KafkaWorker{} // kafkaInstances kafka 实例.
+	kafkaInstancesMutex = sync.RWMutex{}
+	kafkaInitGroup      singleflight.Group // 同一个连接名的并发初始化只执行一次
+)
 
 // Config kafkav2 配置参数.
 type Config struct {
@@ -31,17 +38,44 @@ type Config struct {
 	SaslUsername       string
 	SaslPassword       string
 	UseOriginalContent bool `yaml:"useOriginalContent" json:"useOriginalContent"`
+	// 消费并发配置，Concurrency 大于1 时启用协程池处理消息
+	Concurrency       int  `yaml:"concurrency" json:"concurrency"`
+	MaxInFlight       int  `yaml:"maxInFlight" json:"maxInFlight"`
+	PartitionOrdering bool `yaml:"partitionOrdering" json:"partitionOrdering"`
+	// 消费起始位置: earliest/latest/explicit/timestamp，默认latest
+	// explicit 使用StartOffset，timestamp 使用StartTimestamp(毫秒)，两者都只消费Partition 指定的分区
+	OffsetMode     string `yaml:"offsetMode" json:"offsetMode"`
+	StartOffset    int64  `yaml:"startOffset" json:"startOffset"`
+	StartTimestamp int64  `yaml:"startTimestamp" json:"startTimestamp"`
+	// schema 注册中心配置，TopicSchemas 中的topic 按schema 序列化消息体而不是封装KafkaPacket
+	SchemaRegistry SchemaRegistryConfig   `yaml:"schemaRegistry" json:"schemaRegistry"`
+	TopicSchemas   map[string]TopicSchema `yaml:"topicSchemas" json:"topicSchemas"`
+	// 事务生产者配置，TransactionalID 不为空时启用事务，消费者使用read_committed 隔离级别
+	TransactionalID      string `yaml:"transactionalId" json:"transactionalId"`
+	TransactionTimeoutMS int    `yaml:"transactionTimeoutMs" json:"transactionTimeoutMs"`
+	// 断线重连等待时间，从ReconnectBackoffMS 开始逐次加倍，最长ReconnectBackoffMaxMS
+	ReconnectBackoffMS    int `yaml:"reconnectBackoffMs" json:"reconnectBackoffMs"`
+	ReconnectBackoffMaxMS int `yaml:"reconnectBackoffMaxMs" json:"reconnectBackoffMaxMs"`
+	// 生产者消息压缩方式: none/gzip/snappy/lz4/zstd，默认不压缩
+	Compression string `yaml:"compression" json:"compression"`
+	// 消息体最大字节数，超过时拆分为分片发送，消费者收齐后重新组装，0 表示不拆分
+	MaxMessageBytes int `yaml:"maxMessageBytes" json:"maxMessageBytes"`
+	// 外部偏移量存储，配置后消费者不加入消费者组，从存储的偏移量恢复消费
+	OffsetStore OffsetStore `yaml:"-" json:"-"`
 }
 
-// InstStats .
+// InstStats 生产者或消费者的累计统计信息.
 type InstStats struct {
 	Bytes         int64  `json:"bytes"`
 	Dials         int64  `json:"connections"`
 	Topic         string `json:"topic"`
 	Messages      int64  `json:"messages"`
+	Requests      int64  `json:"requests"` // 消费者为fetch 次数，生产者为write 次数
 	Rebalances    int64  `json:"rebalances"`
 	Errors        int64  `json:"errors"`
 	Timeouts      int64  `json:"timeouts"`
+	Retries       int64  `json:"retries"`
+	Lag           int64  `json:"lag"` // 消费者读取到的最新一批消息的延迟
 	ClientID      string `json:"clientID"`
 	QueueLength   int64  `json:"queueLength"`
 	QueueCapacity int64  `json:"queueCapacity"`
@@ -49,93 +83,197 @@ type InstStats struct {
 
 // Stats struct
 type Stats struct {
-	Consumer InstStats `json:"consumer"`
-	Producer InstStats `json:"producer"`
+	Consumer InstStats      `json:"consumer"`
+	Producer InstStats      `json:"producer"`
+	Lags     []PartitionLag `json:"lags"`     // 每个分区的消费延迟，由CollectStats 计算
+	TotalLag int64          `json:"totalLag"` // 所有分区消费延迟之和
+	Time     time.Time      `json:"time"`
 }
 
-// InitKafka 初始化kafka.
+// InitKafka 初始化kafka，同名实例已存在时直接返回.
+// 多个协程并发初始化同一个连接名时只会创建一个实例，其它调用等待并返回同一个实例(使用先到达的配置).
 func InitKafka(mqConnName string, config Config) (*KafkaWorker, error) {
-	instance, ok := kafkaInstances[mqConnName]
-	if !ok {
-		if config.PrivateTopic == "" {
-			config.PrivateTopic = "rpc-" + utils.GenUUID()
-		} else {
-			config.PrivateTopic += "-" + utils.GenUUID()[:10]
+	if instance := lookupKafka(mqConnName); instance != nil {
+		return instance, nil
+	}
+	v, err, _ := kafkaInitGroup.Do(mqConnName, func() (interface{}, error) {
+		if instance := lookupKafka(mqConnName); instance != nil {
+			return instance, nil
 		}
-		if config.MessageType == "fanout" {
-			config.GroupID = utils.GenUUID()
-			config.PrivateTopic = ""
+		instance, err := newKafkaWorkerWithConfig(config)
+		if err != nil {
+			return nil, err
 		}
-		instance = NewKafkaWorker(config.Hosts, config.Partition, config.PrivateTopic, config.GroupID)
-		instance.UseOriginalContent = config.UseOriginalContent
-		// if config.KerberosServiceName != "" && config.KerberosKeytab != "" && config.KerberosPrincipal != "" {
-		// 	instance.Producer.ConfigKerberosServiceName(config.KerberosServiceName)
-		// 	instance.Producer.ConfigKerberosKeyTab(config.KerberosKeytab)
-		// 	instance.Producer.ConfigKerberosPrincipal(config.KerberosPrincipal)
-		// 	instance.Producer.ConfigSecurityProtocol("sasl_plaintext")
-
-		// 	instance.Consumer.ConfigKerberosServiceName(config.KerberosServiceName)
-		// 	instance.Consumer.ConfigKerberosKeyTab(config.KerberosKeytab)
-		// 	instance.Consumer.ConfigKerberosPrincipal(config.KerberosPrincipal)
-		// 	instance.Consumer.ConfigSecurityProtocol("sasl_plaintext")
-		// }
-		if config.SaslUsername != "" && config.SaslPassword != "" {
-			instance.Producer.ConfigSaslUserName(config.SaslUsername)
-			instance.Producer.ConfigSaslPassword(config.SaslPassword)
-			instance.Producer.ConfigSecurityProtocol("sasl_plaintext")
+		kafkaInstancesMutex.Lock()
+		kafkaInstances[mqConnName] = instance
+		kafkaInstancesMutex.Unlock()
+		health.Register("kafka:"+mqConnName, instance)
+		return instance, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*KafkaWorker), nil
+}
 
-			instance.Consumer.ConfigSaslUserName(config.SaslUsername)
-			instance.Consumer.ConfigSaslPassword(config.SaslPassword)
-			instance.Consumer.ConfigSecurityProtocol("sasl_plaintext")
+// newKafkaWorkerWithConfig 按配置创建worker.
+func newKafkaWorkerWithConfig(config Config) (*KafkaWorker, error) {
+	if config.PrivateTopic == "" {
+		config.PrivateTopic = "rpc-" + utils.GenUUID()
+	} else {
+		config.PrivateTopic += "-" + utils.GenUUID()[:10]
+	}
+	if config.MessageType == "fanout" {
+		config.GroupID = utils.GenUUID()
+		config.PrivateTopic = ""
+	}
+	instance := NewKafkaWorker(config.Hosts, config.Partition, config.PrivateTopic, config.GroupID)
+	instance.UseOriginalContent = config.UseOriginalContent
+	// if config.KerberosServiceName != "" && config.KerberosKeytab != "" && config.KerberosPrincipal != "" {
+	// 	instance.Producer.ConfigKerberosServiceName(config.KerberosServiceName)
+	// 	instance.Producer.ConfigKerberosKeyTab(config.KerberosKeytab)
+	// 	instance.Producer.ConfigKerberosPrincipal(config.KerberosPrincipal)
+	// 	instance.Producer.ConfigSecurityProtocol("sasl_plaintext")
+
+	// 	instance.Consumer.ConfigKerberosServiceName(config.KerberosServiceName)
+	// 	instance.Consumer.ConfigKerberosKeyTab(config.KerberosKeytab)
+	// 	instance.Consumer.ConfigKerberosPrincipal(config.KerberosPrincipal)
+	// 	instance.Consumer.ConfigSecurityProtocol("sasl_plaintext")
+	// }
+	if config.SaslUsername != "" && config.SaslPassword != "" {
+		instance.Producer.ConfigSaslUserName(config.SaslUsername)
+		instance.Producer.ConfigSaslPassword(config.SaslPassword)
+		instance.Producer.ConfigSecurityProtocol("sasl_plaintext")
+
+		instance.Consumer.ConfigSaslUserName(config.SaslUsername)
+		instance.Consumer.ConfigSaslPassword(config.SaslPassword)
+		instance.Consumer.ConfigSecurityProtocol("sasl_plaintext")
+	}
+	if config.MaxPollIntervalMS > 0 {
+		instance.Consumer.ConfigMaxPollIntervalMS(config.MaxPollIntervalMS)
+	}
+	switch config.OffsetMode {
+	case OffsetModeExplicit:
+		instance.Consumer.ConfigStartOffset(config.StartOffset)
+	case OffsetModeTimestamp:
+		instance.Consumer.ConfigStartTime(time.Unix(0, config.StartTimestamp*int64(time.Millisecond)))
+	case "":
+	default:
+		instance.Consumer.ConfigOffsetMode(config.OffsetMode)
+	}
+	if config.Concurrency > 1 {
+		instance.Consumer.ConfigConcurrency(config.Concurrency)
+		instance.Consumer.ConfigMaxInFlight(config.MaxInFlight)
+		instance.Consumer.ConfigPartitionOrdering(config.PartitionOrdering)
+	}
+	if config.ReconnectBackoffMS > 0 {
+		instance.Producer.ConfigReconnectInterval(config.ReconnectBackoffMS)
+		instance.Consumer.ConfigReconnectInterval(config.ReconnectBackoffMS)
+	}
+	if config.ReconnectBackoffMaxMS > 0 {
+		instance.Producer.ConfigReconnectBackoffMax(config.ReconnectBackoffMaxMS)
+		instance.Consumer.ConfigReconnectBackoffMax(config.ReconnectBackoffMaxMS)
+	}
+	if config.OffsetStore != nil {
+		instance.Consumer.ConfigOffsetStore(config.OffsetStore)
+	}
+	if config.MaxMessageBytes > 0 {
+		instance.Producer.ConfigMaxMessageBytes(config.MaxMessageBytes)
+	}
+	if config.Compression != "" {
+		if err := instance.Producer.ConfigCompression(config.Compression); err != nil {
+			return nil, fmt.Errorf("kafka compression %s invalid: %v", config.Compression, err)
 		}
-		if config.MaxPollIntervalMS > 0 {
-			instance.Consumer.ConfigMaxPollIntervalMS(config.MaxPollIntervalMS)
+	}
+	if config.TransactionalID != "" {
+		instance.Transaction = NewTransactionalProducer(config.Hosts, config.TransactionalID)
+		if config.TransactionTimeoutMS > 0 {
+			instance.Transaction.ConfigTransactionTimeout(time.Duration(config.TransactionTimeoutMS) * time.Millisecond)
+		}
+		if config.SaslUsername != "" && config.SaslPassword != "" {
+			instance.Transaction.ConfigSaslUserName(config.SaslUsername)
+			instance.Transaction.ConfigSaslPassword(config.SaslPassword)
+		}
+		instance.Consumer.ConfigReadCommitted(true)
+	}
+	if len(config.TopicSchemas) > 0 {
+		if config.SchemaRegistry.URL == "" {
+			return nil, fmt.Errorf("kafka topic schemas configured without schema registry url")
+		}
+		registry := NewSchemaRegistryClient(config.SchemaRegistry)
+		for topic, topicSchema := range config.TopicSchemas {
+			serializer, err := NewSchemaSerializer(registry, topicSchema)
+			if err != nil {
+				return nil, err
+			}
+			instance.SetTopicSerializer(topic, serializer)
 		}
-		kafkaInstances[mqConnName] = instance
-		return instance, nil
 	}
 	return instance, nil
+}
 
+// lookupKafka 查找已初始化的实例，不存在时返回nil.
+func lookupKafka(mqConnName string) *KafkaWorker {
+	kafkaInstancesMutex.RLock()
+	instance := kafkaInstances[mqConnName]
+	kafkaInstancesMutex.RUnlock()
+	return instance
 }
 
 // GetKafka 获取kafka.
 func GetKafka(mqConnName string) (*KafkaWorker, error) {
-	instance, ok := kafkaInstances[mqConnName]
-	if ok {
+	if instance := lookupKafka(mqConnName); instance != nil {
 		return instance, nil
 	}
 	return nil, fmt.Errorf("Kafka instance by %s not found", mqConnName)
 }
 
-// 停止kafka
+// GetAllKafkaNames 返回所有已初始化的kafka 实例名称.
+func GetAllKafkaNames() []string {
+	kafkaInstancesMutex.RLock()
+	names := make([]string, 0, len(kafkaInstances))
+	for name := range kafkaInstances {
+		names = append(names, name)
+	}
+	kafkaInstancesMutex.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// StopKafka 关闭kafka 实例并从实例列表中移除.
 func StopKafka(mqConnName string) error {
+	kafkaInstancesMutex.Lock()
 	instance, ok := kafkaInstances[mqConnName]
 	if ok {
-		instance.Consumer.StopConsumer()
 		delete(kafkaInstances, mqConnName)
 	}
-	return fmt.Errorf("Kafka instance by %s not found", mqConnName)
-
+	kafkaInstancesMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("Kafka instance by %s not found", mqConnName)
+	}
+	health.Unregister("kafka:" + mqConnName)
+	return instance.Close(DefaultCloseTimeout)
 }
 
 // ConvertKafkaPacketToMQConsumerMessage 把接收到的kafkaPacket 数据转换成MQConsumerMessage.
 func ConvertKafkaPacketToMQConsumerMessage(packet *KafkaPacket) mqenv.MQConsumerMessage {
 	consumerMessage := mqenv.MQConsumerMessage{
-		Driver:        mqenv.DriverTypeKafka,
-		Queue:         packet.SendTo,
-		CorrelationID: packet.CorrelationId,
-		ConsumerTag:   packet.ConsumerTag,
-		ReplyTo:       packet.ReplyTo,
-		MessageID:     packet.MessageId,
-		AppID:         packet.AppId,
-		UserID:        packet.UserId,
-		ContentType:   packet.ContentType,
-		Exchange:      packet.Exchange,
-		RoutingKey:    packet.RoutingKey,
-		Timestamp:     time.Unix(int64(packet.Timestamp), 0),
-		Body:          packet.Body,
-		Headers:       map[string]string{},
-		BindData:      &packet,
+		Driver:          mqenv.DriverTypeKafka,
+		Queue:           packet.SendTo,
+		CorrelationID:   packet.CorrelationId,
+		ConsumerTag:     packet.ConsumerTag,
+		ReplyTo:         packet.ReplyTo,
+		MessageID:       packet.MessageId,
+		AppID:           packet.AppId,
+		UserID:          packet.UserId,
+		ContentType:     packet.ContentType,
+		ContentEncoding: packet.ContentEncoding,
+		Exchange:        packet.Exchange,
+		RoutingKey:      packet.RoutingKey,
+		Timestamp:       time.Unix(int64(packet.Timestamp), 0),
+		Body:            packet.Body,
+		Headers:         map[string]string{},
+		BindData:        &packet,
 	}
 	if nil != packet.Headers {
 		for _, h := range packet.Headers {
@@ -1,14 +1,25 @@
 package kafka
 
 import (
+	"time"
+
+	"github.com/libpub/golib/logger"
 	k "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
 )
 
+// LoggerModule kafka 的日志模块名，可通过 logger.SetModuleLevel 在运行时调整日志级别
+const LoggerModule = "kafka"
+
+var logs = logger.NewModuleLoggers(LoggerModule)
+
 // Base .
 type Base struct {
 	Partition          int                                   // partition 分区
 	Config             map[string]interface{}                // kafka 的配置字典
 	CompletionCallback func(messages []k.Message, err error) // 发送状态通知函数
+	ErrorCallback      ErrorCallback                         // 读写失败通知函数
 }
 
 // ConfigServers 配置连接的服务器,如"localhost:9092,localhost:9093".
@@ -76,3 +87,28 @@ func (b *Base) ConfigHeartbeatInterval(interval int) {
 func (b *Base) SetCompletionCallback(callback func(messages []k.Message, err error)) {
 	b.CompletionCallback = callback
 }
+
+// saslMechanism 按配置返回sasl 认证方式，未配置时返回nil.
+func (b *Base) saslMechanism() sasl.Mechanism {
+	if b.Config["sasl.username"] == nil || b.Config["sasl.password"] == nil {
+		return nil
+	}
+	return plain.Mechanism{
+		Username: b.Config["sasl.username"].(string),
+		Password: b.Config["sasl.password"].(string),
+	}
+}
+
+// dialer 返回带sasl 认证的dialer，未配置认证时返回nil 使用kafka-go 默认dialer.
+func (b *Base) dialer() *k.Dialer {
+	mechanism := b.saslMechanism()
+	if mechanism == nil {
+		return nil
+	}
+	logs.Debug.Println("using sasl ")
+	return &k.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+	}
+}
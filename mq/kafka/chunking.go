@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/utils"
+	k "github.com/segmentio/kafka-go"
+)
+
+// 分片消息的header
+const (
+	HeaderChunkID    = "x-chunk-id"    // 同一条消息的分片使用相同的id
+	HeaderChunkIndex = "x-chunk-index" // 分片序号，从0开始
+	HeaderChunkCount = "x-chunk-count" // 分片总数
+
+	DefaultChunkTimeout = time.Minute // 未收齐的分片最长保留时间
+)
+
+// ConfigMaxMessageBytes 配置消息体的最大字节数，超过时拆分为多条分片消息发送，由消费者收齐后重新组装，0 表示不拆分.
+// 同一条消息的分片写入同一个分区，Key 为空或轮询分区时按分片id 选择分区.
+// 应小于broker 的message.max.bytes 并为header 留出余量.
+func (p *Producer) ConfigMaxMessageBytes(n int) {
+	p.Config["message.max.bytes"] = n
+}
+
+// maxMessageBytes 返回消息体的最大字节数，0 表示不拆分.
+func (p *Producer) maxMessageBytes() int {
+	n, _ := p.Config["message.max.bytes"].(int)
+	return n
+}
+
+// splitChunks 把消息体超过maxBytes 的消息拆分为分片消息.
+func (m ProducerMessage) splitChunks(maxBytes int) []ProducerMessage {
+	if maxBytes <= 0 || len(m.Value) <= maxBytes {
+		return []ProducerMessage{m}
+	}
+	id := utils.GenUUID()
+	count := (len(m.Value) + maxBytes - 1) / maxBytes
+	chunks := make([]ProducerMessage, count)
+	for i := range chunks {
+		chunk := m
+		end := (i + 1) * maxBytes
+		if end > len(m.Value) {
+			end = len(m.Value)
+		}
+		chunk.Value = m.Value[i*maxBytes : end]
+		chunk.Headers = make(map[string]string, len(m.Headers)+3)
+		for name, value := range m.Headers {
+			chunk.Headers[name] = value
+		}
+		chunk.Headers[HeaderChunkID] = id
+		chunk.Headers[HeaderChunkIndex] = strconv.Itoa(i)
+		chunk.Headers[HeaderChunkCount] = strconv.Itoa(count)
+		chunks[i] = chunk
+	}
+	return chunks
+}
+
+// headerValue 返回消息中名为name 的header，不存在时返回nil.
+func headerValue(headers []k.Header, name string) []byte {
+	for _, h := range headers {
+		if h.Key == name {
+			return h.Value
+		}
+	}
+	return nil
+}
+
+// chunkAssembler 按分片id 组装分片消息.
+type chunkAssembler struct {
+	pending map[string]*chunkedMessage
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+// chunkedMessage 正在组装的消息.
+type chunkedMessage struct {
+	chunks    [][]byte
+	received  int
+	firstSeen time.Time
+}
+
+func newChunkAssembler(timeout time.Duration) *chunkAssembler {
+	return &chunkAssembler{
+		pending: make(map[string]*chunkedMessage),
+		timeout: timeout,
+	}
+}
+
+// add 返回组装完成的消息体，分片未收齐时ok 为false，不是分片的消息原样返回.
+func (a *chunkAssembler) add(m k.Message) ([]byte, bool) {
+	id := headerValue(m.Headers, HeaderChunkID)
+	if id == nil {
+		return m.Value, true
+	}
+	index, err := strconv.Atoi(string(headerValue(m.Headers, HeaderChunkIndex)))
+	count, err2 := strconv.Atoi(string(headerValue(m.Headers, HeaderChunkCount)))
+	if err != nil || err2 != nil || index < 0 || index >= count {
+		logs.Error.Printf("dropping kafka topic:%s partition:%d offset:%d with invalid chunk headers", m.Topic, m.Partition, m.Offset)
+		return nil, false
+	}
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expire(now)
+	pending, ok := a.pending[string(id)]
+	if !ok {
+		pending = &chunkedMessage{chunks: make([][]byte, count), firstSeen: now}
+		a.pending[string(id)] = pending
+	}
+	if len(pending.chunks) != count || nil != pending.chunks[index] {
+		// 重复消费的分片
+		return nil, false
+	}
+	pending.chunks[index] = m.Value
+	pending.received++
+	if pending.received < count {
+		return nil, false
+	}
+	delete(a.pending, string(id))
+	return bytes.Join(pending.chunks, nil), true
+}
+
+// expire 丢弃超时未收齐的消息，调用方需持有mu.
+func (a *chunkAssembler) expire(now time.Time) {
+	for id, pending := range a.pending {
+		if now.Sub(pending.firstSeen) > a.timeout {
+			logs.Warning.Printf("dropping chunked kafka message:%s with %d of %d chunks received in %v", id, pending.received, len(pending.chunks), a.timeout)
+			delete(a.pending, id)
+		}
+	}
+}
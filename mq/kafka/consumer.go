@@ -3,27 +3,42 @@ package kafka
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/libpub/golib/logger"
 	"github.com/libpub/golib/utils"
 	k "github.com/segmentio/kafka-go"
-	"github.com/segmentio/kafka-go/sasl/plain"
 )
 
 // CallBack .回调函数
 type CallBack func([]byte)
 
+// 消费起始位置模式
+const (
+	OffsetModeLatest    = "latest"    // 从最新的消息开始消费(默认)
+	OffsetModeEarliest  = "earliest"  // 从最早的消息开始消费
+	OffsetModeExplicit  = "explicit"  // 从指定的偏移量开始消费
+	OffsetModeTimestamp = "timestamp" // 从指定时间之后的第一条消息开始消费
+)
+
 // Consumer 消费者.
 type Consumer struct {
 	Base
 	Readers map[string]*k.Reader // 每一个topic 一个reader
+	// 使用外部偏移量存储的topic 每个分区一个reader，Readers 中为第一个分区的reader
+	partitionReaders map[string][]*k.Reader
 	// Params     map[string]string    // 配置参数
-	running    map[string]bool // 用于设置reader 是否要关闭连接
-	cancels    map[string]context.CancelFunc
-	Brokers    []string         // kafka 的节点
-	OffsetDict map[string]int64 // 记录偏移量，避免在连接断开重连时候重复处理信息
+	running      map[string]bool // 用于设置reader 是否要关闭连接
+	cancels      map[string]context.CancelFunc
+	done         map[string]chan struct{} // 每个topic 的消费协程退出后关闭
+	Brokers      []string                 // kafka 的节点
+	OffsetDict   map[string]map[int]int64 // 按topic 和分区记录偏移量，避免在连接断开重连时候重复处理信息
+	groupIDs     map[string]string        // 每个topic 实际使用的消费者组
+	privateTopic string                   // worker 的私有topic，总是从最新位置消费
+	chunks       *chunkAssembler          // 组装分片消息
+	mu           sync.Mutex               // 保护Readers/partitionReaders/OffsetDict/groupIDs/running/cancels/done
 }
 
 // ConfigGroupID 配置group id.
@@ -36,13 +51,158 @@ func (c *Consumer) ConfigMaxPollIntervalMS(interval int) {
 	c.Config["max.poll.interval.ms"] = interval
 }
 
-// StopConsumer 停止消费.
+// ConfigOffsetMode 配置没有已提交偏移量时的消费起始位置，可选earliest/latest.
+func (c *Consumer) ConfigOffsetMode(mode string) {
+	c.Config["offset.mode"] = mode
+}
+
+// ConfigStartOffset 配置从指定的偏移量开始消费.
+// 指定偏移量只能作用于单个分区，因此会使用ConfigPartition 配置的分区，并且不加入消费者组、不提交偏移量，适用于回放和补数据.
+// 起始位置对worker 的私有topic 不生效，私有topic 总是从最新位置消费.
+func (c *Consumer) ConfigStartOffset(offset int64) {
+	c.Config["offset.mode"] = OffsetModeExplicit
+	c.Config["offset.value"] = offset
+}
+
+// ConfigStartTime 配置从指定时间开始消费(SetOffsetAt)，分区与消费者组的限制与ConfigStartOffset 相同.
+func (c *Consumer) ConfigStartTime(t time.Time) {
+	c.Config["offset.mode"] = OffsetModeTimestamp
+	c.Config["offset.time"] = t
+}
+
+// offsetMode 返回配置的消费起始位置模式.
+func (c *Consumer) offsetMode() string {
+	mode, _ := c.Config["offset.mode"].(string)
+	if mode == "" {
+		return OffsetModeLatest
+	}
+	return strings.ToLower(mode)
+}
+
+// topicOffsetMode 返回topic 的消费起始位置模式，私有topic 只用于接收请求的回复，总是使用latest.
+func (c *Consumer) topicOffsetMode(topic string) string {
+	if topic != "" && topic == c.privateTopic {
+		return OffsetModeLatest
+	}
+	return c.offsetMode()
+}
+
+// applyStartOffset 对不使用消费者组的reader 设置起始位置.
+func (c *Consumer) applyStartOffset(topic string, reader *k.Reader) error {
+	switch c.topicOffsetMode(topic) {
+	case OffsetModeExplicit:
+		offset, _ := c.Config["offset.value"].(int64)
+		return reader.SetOffset(offset)
+	case OffsetModeTimestamp:
+		t, _ := c.Config["offset.time"].(time.Time)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return reader.SetOffsetAt(ctx, t)
+	}
+	return nil
+}
+
+// ConfigConcurrency 配置每个topic 处理消息的协程数量，默认为1 即串行处理.
+func (c *Consumer) ConfigConcurrency(workers int) {
+	c.Config["consumer.concurrency"] = workers
+}
+
+// ConfigMaxInFlight 配置每个topic 最多同时在处理(含排队)的消息数量，默认为协程数量的2倍.
+func (c *Consumer) ConfigMaxInFlight(maxInFlight int) {
+	c.Config["consumer.max.inflight"] = maxInFlight
+}
+
+// ConfigPartitionOrdering 配置并发处理时是否保证同一分区内的消息按顺序处理.
+func (c *Consumer) ConfigPartitionOrdering(ordered bool) {
+	c.Config["consumer.partition.ordering"] = ordered
+}
+
+// ConfigReadCommitted 配置只读取已提交事务中的消息(read_committed)，未提交或已回滚的事务消息不可见.
+func (c *Consumer) ConfigReadCommitted(readCommitted bool) {
+	c.Config["isolation.read.committed"] = readCommitted
+}
+
+// readCommitted 返回是否使用read_committed 隔离级别.
+func (c *Consumer) readCommitted() bool {
+	readCommitted, _ := c.Config["isolation.read.committed"].(bool)
+	return readCommitted
+}
+
+// newDispatcher 按并发配置创建消息分发器，未配置并发时返回nil.
+func (c *Consumer) newDispatcher(callback CallBack) *messageDispatcher {
+	workers, _ := c.Config["consumer.concurrency"].(int)
+	maxInFlight, _ := c.Config["consumer.max.inflight"].(int)
+	ordered, _ := c.Config["consumer.partition.ordering"].(bool)
+	if maxInFlight <= 0 {
+		maxInFlight = workers * 2
+	}
+	return newMessageDispatcher(workers, maxInFlight, ordered, callback)
+}
+
+// StopConsumer 停止消费，不等待正在处理的消息.
 func (c *Consumer) StopConsumer() {
+	c.mu.Lock()
 	for k := range c.running {
-		logger.Info.Printf("stop consumer %s", k)
+		logs.Info.Printf("stop consumer %s", k)
 		c.running[k] = false
-		cancel := c.cancels[k]
-		cancel()
+		if cancel := c.cancels[k]; cancel != nil {
+			cancel()
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Close 停止消费并等待正在处理的消息完成，随后关闭reader 并提交偏移量.
+// timeout 内没有全部完成时返回错误，未完成的协程会在处理结束后自行关闭reader.
+func (c *Consumer) Close(timeout time.Duration) error {
+	c.StopConsumer()
+	c.mu.Lock()
+	waits := make(map[string]chan struct{}, len(c.done))
+	for topic, done := range c.done {
+		waits[topic] = done
+	}
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	pending := []string{}
+	for topic, done := range waits {
+		if !waitClosed(done, time.Until(deadline)) {
+			pending = append(pending, topic)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("close kafka consumer timeout after %v while topics:%s still processing", timeout, strings.Join(pending, ","))
+	}
+
+	c.mu.Lock()
+	for topic := range waits {
+		delete(c.running, topic)
+		delete(c.cancels, topic)
+		delete(c.done, topic)
+		delete(c.Readers, topic)
+		delete(c.partitionReaders, topic)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// waitClosed 在timeout 内等待done 关闭.
+func waitClosed(done chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-done:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
 	}
 }
 
@@ -51,16 +211,157 @@ func (c *Consumer) StopConsumer() {
 // @param topic 订阅的topic
 // @param callback ,处理接收到的信息，入参是 接收到的[]byte
 func (c *Consumer) Receive(topic string, callback CallBack) error {
-	if _, ok := c.Readers[topic]; ok {
+	c.mu.Lock()
+	_, ok := c.Readers[topic]
+	c.mu.Unlock()
+	if ok {
 		return errors.New("The topic is already subscribed")
 	}
-	logger.Debug.Printf("group_id:%s\n", c.Config["group.id"])
-	logger.Debug.Printf("%+v", c.Config)
+	if store := c.offsetStore(topic); store != nil {
+		return c.receiveWithOffsetStore(topic, store, callback)
+	}
+	config, err := c.readerConfig(topic)
+	if err != nil {
+		return err
+	}
+
+	reader := k.NewReader(config)
+	if err = c.applyStartOffset(topic, reader); err != nil {
+		reader.Close()
+		logs.Error.Printf("set kafka topic:%s start offset with mode:%s failed with error:%v", topic, c.topicOffsetMode(topic), err)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.mu.Lock()
+	c.Readers[topic] = reader
+	c.groupIDs[topic] = config.GroupID
+	c.running[topic] = true
+	c.cancels[topic] = cancel
+	c.done[topic] = done
+	c.OffsetDict[topic] = make(map[int]int64)
+	c.mu.Unlock()
+	dispatcher := c.newDispatcher(callback)
+	go func() {
+		defer close(done)
+		// reader 关闭时会提交已读取消息的偏移量，重连后关闭的是新的reader
+		defer func() {
+			reader.Close()
+		}()
+		if nil != dispatcher {
+			// 先于reader 关闭，等待在途消息处理完成
+			defer dispatcher.stop()
+		}
+		failures := 0
+		for ctx.Err() == nil {
+			m, err := reader.ReadMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				failures++
+				if !c.handleReadError(ctx, topic, err, failures) {
+					break
+				}
+				if failures%DefaultReconnectFailures == 0 {
+					reader = c.reconnectReader(topic, config, reader)
+				}
+				continue
+			}
+			failures = 0
+			consumedCounter.Inc(topic)
+			if c.markOffset(topic, m.Partition, m.Offset) {
+				value, complete := c.chunks.add(m)
+				if !complete {
+					continue
+				}
+				m.Value = value
+				if nil != dispatcher {
+					dispatcher.dispatch(m)
+				} else {
+					invokeConsumerCallback(callback, m.Value)
+				}
+			} else {
+				logs.Error.Printf("skipping kafka topic:%s partition:%d offset:%d because of offset", topic, m.Partition, m.Offset)
+			}
+
+		}
+
+	}()
+	return nil
+}
+
+// handleReadError 通知读取错误并按连续失败次数等待，不可恢复的错误或停止消费时返回false.
+func (c *Consumer) handleReadError(ctx context.Context, topic string, err error, failures int) bool {
+	fatal := IsFatalError(err)
+	c.notifyError(ErrorEvent{Topic: topic, Err: err, Fatal: fatal, Failures: failures})
+	if fatal {
+		logs.Error.Printf("stop consuming kafka topic:%s because of fatal error:%v", topic, err)
+		return false
+	}
+	return sleepContext(ctx, c.reconnectBackoff(failures))
+}
+
+// markOffset 记录分区最后处理的偏移量，偏移量不大于已记录的值时返回false.
+// 消费者组的reader 会交替读取多个分区的消息，偏移量只在同一分区内递增，所以需要按topic 和分区分别记录.
+func (c *Consumer) markOffset(topic string, partition int, offset int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if offset <= c.lastOffset(topic, partition) {
+		return false
+	}
+	offsets, ok := c.OffsetDict[topic]
+	if !ok {
+		offsets = make(map[int]int64)
+		c.OffsetDict[topic] = offsets
+	}
+	offsets[partition] = offset
+	return true
+}
+
+// lastOffset 分区最后处理的偏移量，没有处理过时返回-1，调用者需要持有c.mu.
+func (c *Consumer) lastOffset(topic string, partition int) int64 {
+	if offset, ok := c.OffsetDict[topic][partition]; ok {
+		return offset
+	}
+	return -1
+}
+
+// reconnectReader 关闭reader 并重新创建，不使用消费者组的reader 从最后处理的消息之后开始消费.
+func (c *Consumer) reconnectReader(topic string, config k.ReaderConfig, reader *k.Reader) *k.Reader {
+	logs.Warning.Printf("reconnecting kafka reader of topic:%s", topic)
+	reader.Close()
+	newReader := k.NewReader(config)
+	if config.GroupID == "" {
+		c.mu.Lock()
+		lastOffset := c.lastOffset(topic, config.Partition)
+		c.mu.Unlock()
+		var err error
+		if lastOffset >= 0 {
+			err = newReader.SetOffset(lastOffset + 1)
+		} else {
+			err = c.applyStartOffset(topic, newReader)
+		}
+		if err != nil {
+			logs.Error.Printf("set kafka topic:%s offset while reconnecting failed with error:%v", topic, err)
+		}
+	}
+	c.mu.Lock()
+	c.Readers[topic] = newReader
+	c.mu.Unlock()
+	return newReader
+}
+
+// readerConfig 按消费者配置生成topic 的reader 配置.
+func (c *Consumer) readerConfig(topic string) (k.ReaderConfig, error) {
+	logs.Debug.Printf("group_id:%s\n", c.Config["group.id"])
+	logs.Debug.Printf("%+v", c.Config)
 	groupID := c.Config["group.id"].(string)
 	if groupID == "" {
 		groupID = topic + "-" + utils.GenUUID()
 	}
-	logger.Debug.Println(groupID)
+	logs.Debug.Println(groupID)
 	config := k.ReaderConfig{
 		Brokers:        c.Brokers,
 		GroupID:        groupID,
@@ -69,9 +370,22 @@ func (c *Consumer) Receive(topic string, callback CallBack) error {
 		MaxBytes:       10e6, // 10MB
 		StartOffset:    k.LastOffset,
 		CommitInterval: 1 * time.Second,
-		ErrorLogger:    logger.Error,
+		ErrorLogger:    readerErrorLogger,
 		ReadBackoffMax: 200 * time.Millisecond,
 	}
+	offsetMode := c.topicOffsetMode(topic)
+	switch offsetMode {
+	case OffsetModeEarliest:
+		config.StartOffset = k.FirstOffset
+	case OffsetModeExplicit, OffsetModeTimestamp:
+		// kafka-go 只允许不带消费者组的reader 设置偏移量
+		config.GroupID = ""
+		config.Partition = c.Partition
+		config.CommitInterval = 0
+	case OffsetModeLatest:
+	default:
+		return config, fmt.Errorf("unknown kafka offset mode:%s", offsetMode)
+	}
 	if v, ok := c.Config["heartbeat.interval.ms"]; ok {
 		config.HeartbeatInterval = time.Duration(v.(int)) * time.Millisecond
 	}
@@ -81,65 +395,115 @@ func (c *Consumer) Receive(topic string, callback CallBack) error {
 	// if v, ok := c.Config["reconnect.backoff.ms"];ok{
 	// 	config.ReadBackoffMax
 	// }
-	if c.Config["sasl.username"] != nil && c.Config["sasl.password"] != nil {
-		logger.Debug.Println("using sasl ")
-		mechanism := plain.Mechanism{
-			Username: c.Config["sasl.username"].(string),
-			Password: c.Config["sasl.password"].(string),
-		}
-		dialer := &k.Dialer{
-			Timeout:       10 * time.Second,
-			DualStack:     true,
-			SASLMechanism: mechanism,
-		}
+	if c.readCommitted() {
+		config.IsolationLevel = k.ReadCommitted
+	}
+	if dialer := c.dialer(); dialer != nil {
 		config.Dialer = dialer
+	}
+	return config, nil
+}
+
+// TransactionalHandler 处理一条消息并在事务中提交它的偏移量，返回错误表示事务已回滚.
+type TransactionalHandler func(groupID string, m k.Message) error
 
+// ReceiveTransactional 订阅topic，reader 不提交偏移量，由handler 在事务中提交.
+// handler 返回错误时关闭reader 后重新加入消费者组，从最后一次提交的偏移量开始重新消费.
+// 消息在同一个协程中按顺序处理，不使用并发配置.
+func (c *Consumer) ReceiveTransactional(topic string, handler TransactionalHandler) error {
+	c.mu.Lock()
+	_, ok := c.Readers[topic]
+	c.mu.Unlock()
+	if ok {
+		return errors.New("The topic is already subscribed")
+	}
+	config, err := c.readerConfig(topic)
+	if err != nil {
+		return err
 	}
+	if config.GroupID == "" {
+		return ErrTransactionNeedsGroup
+	}
+	config.CommitInterval = 0
 
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
 	reader := k.NewReader(config)
-
+	c.mu.Lock()
 	c.Readers[topic] = reader
+	c.groupIDs[topic] = config.GroupID
 	c.running[topic] = true
-	c.OffsetDict[topic] = -1
+	c.cancels[topic] = cancel
+	c.done[topic] = done
+	c.mu.Unlock()
 	go func() {
-		defer reader.Close()
-		for c.running[topic] {
-			ctx, cancel := context.WithCancel(context.Background())
-			c.cancels[topic] = cancel
-			m, err := reader.ReadMessage(ctx)
-			if err != nil {
-				logger.Error.Println(err)
+		defer close(done)
+		failures := 0
+		for {
+			for ctx.Err() == nil {
+				m, err := reader.FetchMessage(ctx)
+				if err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+					failures++
+					if !c.handleReadError(ctx, topic, err, failures) {
+						reader.Close()
+						return
+					}
+					if failures%DefaultReconnectFailures == 0 {
+						// 重新加入消费者组
+						break
+					}
+					continue
+				}
+				failures = 0
+				consumedCounter.Inc(topic)
+				if err = invokeTransactionalHandler(handler, config.GroupID, m); err != nil {
+					logs.Error.Printf("process kafka topic:%s partition:%d offset:%d in transaction failed with error:%v, rewinding to last committed offset", m.Topic, m.Partition, m.Offset, err)
+					break
+				}
 			}
-			if m.Offset > c.OffsetDict[topic] {
-				c.OffsetDict[topic] = m.Offset
-				func() {
-					defer func() {
-						if err := recover(); err != nil {
-							logger.Error.Println(err)
-						}
-					}()
-					callback(m.Value)
-				}()
-			} else {
-				logger.Error.Println("skipping because of offset")
+			reader.Close()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
 			}
-
+			reader = k.NewReader(config)
+			c.mu.Lock()
+			c.Readers[topic] = reader
+			c.mu.Unlock()
 		}
-
 	}()
 	return nil
 }
 
+// invokeTransactionalHandler 执行事务处理函数，回调中的panic 视为处理失败.
+func invokeTransactionalHandler(handler TransactionalHandler, groupID string, m k.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logs.Error.Println(r)
+			err = fmt.Errorf("transactional handler panic: %v", r)
+		}
+	}()
+	return handler(groupID, m)
+}
+
 // NewConsumer 实例化返回消费者.
 func NewConsumer(hosts string, groupID string) *Consumer {
 
 	c := &Consumer{}
 	c.Config = make(map[string]interface{})
 	c.Readers = make(map[string]*k.Reader)
+	c.partitionReaders = make(map[string][]*k.Reader)
 	// c.Params = make(map[string]string)
 	c.running = make(map[string]bool)
 	c.cancels = make(map[string]context.CancelFunc)
-	c.OffsetDict = make(map[string]int64)
+	c.done = make(map[string]chan struct{})
+	c.OffsetDict = make(map[string]map[int]int64)
+	c.groupIDs = make(map[string]string)
+	c.chunks = newChunkAssembler(DefaultChunkTimeout)
 	c.ConfigGroupID(groupID)
 	c.Brokers = strings.Split(hosts, ",")
 
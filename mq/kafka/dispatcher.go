@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils/concurrency"
+	k "github.com/segmentio/kafka-go"
+)
+
+// messageDispatcher 把读取到的消息分发给协程池处理.
+// partitionOrdered 为true 时同一分区的消息总是由同一个协程处理，保证分区内顺序；
+// 协程池容量限制同时处理中(含排队)的消息数量，超出时读取协程阻塞，形成背压.
+type messageDispatcher struct {
+	callback         CallBack
+	partitionOrdered bool
+	pool             *concurrency.Pool
+}
+
+// newMessageDispatcher 创建分发器，workers 小于2 时返回nil，调用方应直接串行处理.
+func newMessageDispatcher(workers int, maxInFlight int, partitionOrdered bool, callback CallBack) *messageDispatcher {
+	if workers < 2 {
+		return nil
+	}
+	options := []concurrency.PoolOption{concurrency.WithCapacity(maxInFlight)}
+	if partitionOrdered {
+		options = append(options, concurrency.WithKeyedQueues())
+	}
+	return &messageDispatcher{
+		callback:         callback,
+		partitionOrdered: partitionOrdered,
+		pool:             concurrency.NewPool(workers, options...),
+	}
+}
+
+// dispatch 分发一条消息，在途消息达到上限时阻塞.
+func (d *messageDispatcher) dispatch(m k.Message) {
+	task := func() {
+		invokeConsumerCallback(d.callback, m.Value)
+	}
+	var err error
+	if d.partitionOrdered {
+		err = d.pool.SubmitKey(m.Partition, task)
+	} else {
+		err = d.pool.Submit(task)
+	}
+	if err != nil {
+		logs.Error.Printf("dispatch kafka topic:%s partition:%d offset:%d failed with error:%v", m.Topic, m.Partition, m.Offset, err)
+	}
+}
+
+// stop 停止分发并等待所有已分发的消息处理完成.
+func (d *messageDispatcher) stop() {
+	d.pool.Stop()
+}
+
+// pending 返回在途消息数量.
+func (d *messageDispatcher) pending() int {
+	return d.pool.Stats().Pending
+}
+
+// invokeConsumerCallback 执行回调，捕获回调中的panic 避免处理协程退出.
+func invokeConsumerCallback(callback CallBack, value []byte) {
+	defer logger.Module(LoggerModule).RecoverAndLog("kafka consumer callback panic")
+	callback(value)
+}
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/libpub/golib/mq/mqenv"
+	k "github.com/segmentio/kafka-go"
+)
+
+// 消费者组的稳定状态，其他状态(如PreparingRebalance)下消费者暂时不会收到消息
+const (
+	GroupStateStable = "Stable"
+)
+
+// Ping 通过元数据请求检查broker 是否可以连接.
+func (worker *KafkaWorker) Ping(ctx context.Context) error {
+	resp, err := worker.Admin().getClient().Metadata(ctx, &k.MetadataRequest{Topics: []string{}})
+	if err != nil {
+		return err
+	}
+	if len(resp.Brokers) == 0 {
+		return errors.New("no kafka brokers available")
+	}
+	return nil
+}
+
+// HealthCheck 检查broker 连接、已订阅和已发送topic 的分区leader，以及消费者组的状态和成员.
+func (worker *KafkaWorker) HealthCheck(ctx context.Context) *mqenv.MQHealthStatus {
+	groupIDs := map[string]string{}
+	topicSet := map[string]bool{}
+	worker.Consumer.mu.Lock()
+	for topic, groupID := range worker.Consumer.groupIDs {
+		topicSet[topic] = true
+		if groupID != "" && topic != worker.PrivateTopic {
+			groupIDs[topic] = groupID
+		}
+	}
+	worker.Consumer.mu.Unlock()
+	worker.Producer.mu.Lock()
+	for topic := range worker.Producer.Writer {
+		topicSet[topic] = true
+	}
+	worker.Producer.mu.Unlock()
+	topics := make([]string, 0, len(topicSet))
+	for topic := range topicSet {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	status := mqenv.NewMQHealthStatus(worker.DriverType())
+	client := worker.Admin().getClient()
+	metaResp, err := client.Metadata(ctx, &k.MetadataRequest{Topics: topics})
+	if err != nil {
+		status.AddCheck("brokers", false, err.Error())
+		return status
+	}
+	var groupsResp *k.DescribeGroupsResponse
+	var groupsErr error
+	if len(groupIDs) > 0 {
+		groupsResp, groupsErr = client.DescribeGroups(ctx, &k.DescribeGroupsRequest{GroupIDs: uniqueGroupIDs(groupIDs)})
+	}
+	checkMetadataHealth(status, metaResp, topics)
+	checkGroupsHealth(status, groupsResp, groupsErr, groupIDs)
+	return status
+}
+
+// Check 实现health.Checker，HealthCheck 有失败的检查项时返回错误.
+func (worker *KafkaWorker) Check(ctx context.Context) error {
+	status := worker.HealthCheck(ctx)
+	if status.Healthy {
+		return nil
+	}
+	failures := []string{}
+	for _, check := range status.Checks {
+		if !check.Healthy {
+			failures = append(failures, check.Name+":"+check.Message)
+		}
+	}
+	return errors.New(strings.Join(failures, ", "))
+}
+
+// checkMetadataHealth 检查broker 数量和每个topic 的分区leader.
+func checkMetadataHealth(status *mqenv.MQHealthStatus, resp *k.MetadataResponse, topics []string) {
+	if len(resp.Brokers) == 0 {
+		status.AddCheck("brokers", false, "no kafka brokers available")
+	} else {
+		status.AddCheck("brokers", true, fmt.Sprintf("%d brokers available", len(resp.Brokers)))
+	}
+	metaTopics := make(map[string]k.Topic, len(resp.Topics))
+	for _, t := range resp.Topics {
+		metaTopics[t.Name] = t
+	}
+	for _, topic := range topics {
+		name := "topic:" + topic
+		t, ok := metaTopics[topic]
+		switch {
+		case !ok:
+			status.AddCheck(name, false, "topic not found")
+			continue
+		case t.Error != nil:
+			status.AddCheck(name, false, t.Error.Error())
+			continue
+		case len(t.Partitions) == 0:
+			status.AddCheck(name, false, "topic without partitions")
+			continue
+		}
+		leaderless := []int{}
+		for _, p := range t.Partitions {
+			// 分区没有leader 时kafka-go 返回的Leader 没有host
+			if p.Error != nil || p.Leader.Host == "" {
+				leaderless = append(leaderless, p.ID)
+			}
+		}
+		if len(leaderless) > 0 {
+			sort.Ints(leaderless)
+			status.AddCheck(name, false, fmt.Sprintf("partitions %v without leader", leaderless))
+		} else {
+			status.AddCheck(name, true, fmt.Sprintf("%d partitions with leader", len(t.Partitions)))
+		}
+	}
+}
+
+// checkGroupsHealth 检查每个订阅topic 的消费者组处于稳定状态，并且有成员分配到该topic.
+func checkGroupsHealth(status *mqenv.MQHealthStatus, resp *k.DescribeGroupsResponse, err error, groupIDs map[string]string) {
+	topics := make([]string, 0, len(groupIDs))
+	for topic := range groupIDs {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	groups := map[string]k.DescribeGroupsResponseGroup{}
+	if resp != nil {
+		for _, group := range resp.Groups {
+			groups[group.GroupID] = group
+		}
+	}
+	for _, topic := range topics {
+		groupID := groupIDs[topic]
+		name := "group:" + groupID + "/" + topic
+		group, ok := groups[groupID]
+		switch {
+		case err != nil:
+			status.AddCheck(name, false, err.Error())
+			continue
+		case !ok:
+			status.AddCheck(name, false, "consumer group not found")
+			continue
+		case group.Error != nil:
+			status.AddCheck(name, false, group.Error.Error())
+			continue
+		case group.GroupState != GroupStateStable:
+			status.AddCheck(name, false, "consumer group state "+group.GroupState)
+			continue
+		}
+		members := 0
+		for _, member := range group.Members {
+			for _, assigned := range member.MemberAssignments.Topics {
+				if assigned.Topic == topic && len(assigned.Partitions) > 0 {
+					members++
+					break
+				}
+			}
+		}
+		if members == 0 {
+			status.AddCheck(name, false, "no members assigned to topic")
+		} else {
+			status.AddCheck(name, true, fmt.Sprintf("%d members assigned to topic", members))
+		}
+	}
+}
+
+// uniqueGroupIDs 返回去重排序后的消费者组.
+func uniqueGroupIDs(groupIDs map[string]string) []string {
+	set := map[string]bool{}
+	results := []string{}
+	for _, groupID := range groupIDs {
+		if !set[groupID] {
+			set[groupID] = true
+			results = append(results, groupID)
+		}
+	}
+	sort.Strings(results)
+	return results
+}
@@ -2,8 +2,10 @@ package kafka
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	proto "github.com/golang/protobuf/proto"
@@ -12,6 +14,11 @@ import (
 	"github.com/libpub/golib/utils"
 )
 
+//go:generate protoc --go_out=. --go_opt=paths=source_relative kafkapacket.proto
+
+// KafkaPacketVersion 当前发送的KafkaPacket 封装版本，版本演进规则见kafkapacket.proto.
+const KafkaPacketVersion = 1
+
 // Worker 订阅topic 后处理收到信息的回调函数.
 type Worker func(*KafkaPacket) []byte
 
@@ -21,53 +28,43 @@ type KafkaWorker struct {
 	Consumer            *Consumer                         // 消费者
 	consumerRegisters   map[string]*mqenv.MQConsumerProxy // 已经订阅的topic
 	methodRegisters     map[string]*mqenv.MQConsumerProxy // 处理函数字典
+	registersMutex      sync.RWMutex                      // 保护consumerRegisters 和methodRegisters
+	subscribeMutex      sync.Mutex                        // 串行执行Subscribe
 	PrivateTopic        string                            // 私有topic，用于发出信息后收到回复
 	waitResponseMessage map[string]chan *KafkaPacket      //发出信息后，会以消息id为key 保存在字典中，值是通道。通过通道来接收信息
 	availableChannels   []chan *KafkaPacket               // 可用于接收的通道切片
+	waitResponseMutex   sync.Mutex                        // 保护waitResponseMessage 和availableChannels
 	openTopicChannel    map[string]string                 // 记录已经打开的topic通道
+	openTopicMutex      sync.Mutex                        // 保护openTopicChannel
 	ContentType         string                            //序列化类型，如json
 	ContentEncoding     string                            // 编码格式
 	GroupID             string                            //组id，会包含在 kafkapacket 数据包中
 	MsgType             string                            // 消息类型
-	Stats               Stats                             // 统计信息
+	stats               Stats                             // 统计信息
+	statsMutex          sync.Mutex                        // 保护stats
+	statsReporterStop   chan struct{}                     // 停止定时统计回调
 	UseOriginalContent  bool                              // 是否使用原始的方式序列化(使用json 序列化，而不是protobuf)
-}
-
-// newChannel 返回一个新的 字节数组通道.
-func (worker *KafkaWorker) newChannel() chan *KafkaPacket {
-	c := make(chan *KafkaPacket)
-	return c
-}
-
-// obtainChannel 获取一个通道.
-func (worker *KafkaWorker) obtainChannel() chan *KafkaPacket {
-	channelLength := len(worker.availableChannels)
-	if channelLength == 0 {
-		return worker.newChannel()
-	}
-	c := worker.availableChannels[channelLength-1]
-	// 从可用的数组从移除
-	worker.availableChannels = worker.availableChannels[:channelLength-1]
-	return c
-}
-
-// recycleChannel 回收用过的旧通道.
-func (worker *KafkaWorker) recycleChannel(c chan *KafkaPacket) {
-	worker.availableChannels = append(worker.availableChannels, c)
+	serializers         map[string]SchemaSerializer       // 按topic 配置的schema 序列化器，这些topic 上的消息不封装KafkaPacket
+	serializersMutex    sync.RWMutex                      // 保护serializers
+	Transaction         *TransactionalProducer            // 事务生产者，未配置transactional id 时为nil
+	retryStop           chan struct{}                     // 关闭时通知等待重试的回调
+	retryStopOnce       sync.Once
 }
 
 // sendWorker 对发送的操作做额外的操作.
 func (worker *KafkaWorker) sendWorker(topic string, message []byte) error {
+	worker.openTopicMutex.Lock()
 	_, ok := worker.openTopicChannel[topic]
+	worker.openTopicMutex.Unlock()
 	if !ok {
 		err := worker.sendOpenChannel(topic)
 		if err != nil {
 			return err
 		}
+		worker.openTopicMutex.Lock()
 		worker.openTopicChannel[topic] = "1"
+		worker.openTopicMutex.Unlock()
 	}
-	worker.Stats.Producer.Bytes += int64(len(message))
-	worker.Stats.Producer.Messages++
 	err := worker.Producer.Send(topic, message)
 	return err
 }
@@ -78,7 +75,7 @@ func (worker *KafkaWorker) sendOpenChannel(topic string) error {
 	for i := 0; i < 20; i++ {
 		err := worker.Producer.Send(topic, registerValue)
 		if nil != err {
-			logger.Error.Println(err)
+			logs.Error.Println(err)
 			// 因为开启kafka 自动创建topic后，第一次向private_topic 发送信息会报错
 			// return err
 		}
@@ -92,21 +89,44 @@ func (worker *KafkaWorker) registerPrivateTopic() {
 	if worker.PrivateTopic == "" {
 		return
 	}
-	_, ok := worker.consumerRegisters[worker.PrivateTopic]
-	if !ok {
+	if _, ok := worker.getConsumerProxy(worker.PrivateTopic); !ok {
 		worker.sendOpenChannel(worker.PrivateTopic)
 		// PrivateTopic 收到的信息是发出信息后的回复，会在onMessage被拦截用chan 返回给发送方
 		// 所以这里传的回调函数不做任何处理
 		time.Sleep(100 * time.Millisecond)
 		proxy := &mqenv.MQConsumerProxy{}
-		worker.Subscribe(worker.PrivateTopic, proxy)
+		if err := worker.Subscribe(worker.PrivateTopic, proxy); err != nil {
+			logs.Error.Printf("register kafka private topic:%s failed with error:%v", worker.PrivateTopic, err)
+		}
 	}
 }
 
-// Send 发送信息.
+// Send 发送信息，needReply 为true 时等待回复，超时时间取publishMsg.TimeoutSeconds.
 func (worker *KafkaWorker) Send(topic string, publishMsg *mqenv.MQPublishMessage, needReply bool) (*mqenv.MQConsumerMessage, error) {
-
+	if needReply {
+		timeout := DefaultRequestTimeout
+		if publishMsg.TimeoutSeconds > 0 {
+			timeout = time.Duration(publishMsg.TimeoutSeconds) * time.Second
+		}
+		return worker.RequestMessage(topic, publishMsg, timeout)
+	}
+	if serializer := worker.getTopicSerializer(topic); nil != serializer {
+		sendBytes, err := serializer.Serialize(topic, publishMsg.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, worker.Producer.Send(topic, sendBytes)
+	}
 	worker.registerPrivateTopic()
+	sendBytes, err := worker.marshalPacket(worker.newPublishPacket(topic, publishMsg))
+	if err != nil {
+		return nil, err
+	}
+	return nil, worker.sendWorker(topic, sendBytes)
+}
+
+// newPublishPacket 把发布的信息封装成KafkaPacket.
+func (worker *KafkaWorker) newPublishPacket(topic string, publishMsg *mqenv.MQPublishMessage) *KafkaPacket {
 	headers := make([]*KafkaPacket_Header, 0)
 	for k, v := range publishMsg.Headers {
 		h := &KafkaPacket_Header{
@@ -119,13 +139,18 @@ func (worker *KafkaWorker) Send(topic string, publishMsg *mqenv.MQPublishMessage
 	if worker.PrivateTopic == "" {
 		replyTo = publishMsg.ReplyTo
 	}
-	p := &KafkaPacket{
+	contentEncoding := publishMsg.ContentEncoding
+	if contentEncoding == "" {
+		contentEncoding = worker.ContentEncoding
+	}
+	return &KafkaPacket{
 		ContentType:     publishMsg.ContentType,
-		ContentEncoding: worker.ContentEncoding,
+		ContentEncoding: contentEncoding,
 		SendTo:          topic,
 		GroupId:         worker.GroupID,
 		CorrelationId:   publishMsg.CorrelationID,
 		ReplyTo:         replyTo,
+		MessageId:       publishMsg.MessageID,
 		Timestamp:       uint64(utils.CurrentMillisecond()),
 		Type:            worker.MsgType,
 		UserId:          publishMsg.UserID,
@@ -137,7 +162,12 @@ func (worker *KafkaWorker) Send(topic string, publishMsg *mqenv.MQPublishMessage
 		RoutingKey:      publishMsg.RoutingKey,
 		ConsumerTag:     publishMsg.RoutingKey,
 		Exchange:        publishMsg.Exchange,
+		Version:         KafkaPacketVersion,
 	}
+}
+
+// marshalPacket 按UseOriginalContent 选择json 或protobuf 序列化.
+func (worker *KafkaWorker) marshalPacket(p *KafkaPacket) ([]byte, error) {
 	var sendBytes []byte
 	var err error
 	if worker.UseOriginalContent {
@@ -145,26 +175,11 @@ func (worker *KafkaWorker) Send(topic string, publishMsg *mqenv.MQPublishMessage
 	} else {
 		sendBytes, err = proto.Marshal(p)
 	}
-
 	if err != nil {
-		logger.Error.Println(err)
+		logs.Error.Println(err)
 		return nil, err
 	}
-	// 注册通道，等待回复
-	if needReply {
-		ch := worker.obtainChannel()
-		worker.waitResponseMessage[p.CorrelationId] = ch
-		worker.sendWorker(topic, sendBytes)
-		responsePacket := <-ch
-		// 回收通道
-		worker.recycleChannel(ch)
-
-		consumerMessage := ConvertKafkaPacketToMQConsumerMessage(responsePacket)
-		return &consumerMessage, nil
-	}
-	worker.sendWorker(topic, sendBytes)
-	return nil, nil
-
+	return sendBytes, nil
 }
 
 // reply 服务端收到信息处理完后进行回复.
@@ -178,9 +193,13 @@ func (worker *KafkaWorker) reply(topic string, message *mqenv.MQPublishMessage,
 		}
 		headers = append(headers, h)
 	}
+	contentEncoding := message.ContentEncoding
+	if contentEncoding == "" {
+		contentEncoding = worker.ContentEncoding
+	}
 	p := &KafkaPacket{
 		ContentType:     message.ContentType,
-		ContentEncoding: worker.ContentEncoding,
+		ContentEncoding: contentEncoding,
 		SendTo:          topic,
 		GroupId:         worker.GroupID,
 		CorrelationId:   msgID,
@@ -196,20 +215,14 @@ func (worker *KafkaWorker) reply(topic string, message *mqenv.MQPublishMessage,
 		RoutingKey:      message.ReplyTo,
 		ConsumerTag:     message.ReplyTo,
 		Exchange:        topic,
+		Version:         KafkaPacketVersion,
 	}
-	var sendBytes []byte
-	var err error
-	if worker.UseOriginalContent {
-		sendBytes, err = json.Marshal(p)
-	} else {
-		sendBytes, err = proto.Marshal(p)
-	}
-
+	sendBytes, err := worker.marshalPacket(p)
 	if err != nil {
-		logger.Error.Println(err)
+		return
 	}
 	worker.sendWorker(topic, sendBytes)
-	// logger.Debug.Println("reply " + utils.HumanByteText(message.Body))
+	// logs.Debug.Println("reply " + utils.HumanByteText(message.Body))
 
 }
 
@@ -218,36 +231,33 @@ func (worker *KafkaWorker) onMessage(packet *KafkaPacket) {
 	// 收到信息有两种情况
 	// 1 发出信息后收到回复,在waitResponseMessage 有通道，把信息发送过去就可以了
 	// 2 订阅topic 后收到的回复
-	// logger.Debug.Println("onMessage body=" + utils.HumanByteText(packet.Body))
-	ch, ok := worker.waitResponseMessage[packet.CorrelationId]
+	// logs.Debug.Println("onMessage body=" + utils.HumanByteText(packet.Body))
+	ch, ok := worker.takeResponseWaiter(packet.CorrelationId)
 	if ok {
-		delete(worker.waitResponseMessage, packet.CorrelationId)
 		ch <- packet
 	} else {
 		//consumerProxy, isExits := worker.consumerRegisters[packet.SendTo]
 		// 先从包含具体方法的字典(methodRegisters)查找处理函数
 		//如果找不到就从订阅topic的字典(consumerRegisters)查找
 		key := fmt.Sprintf("%s-%s", packet.SendTo, packet.RoutingKey)
+		worker.registersMutex.RLock()
 		consumerProxy, isExits := worker.methodRegisters[key]
 		if !isExits {
 			consumerProxy, isExits = worker.consumerRegisters[packet.SendTo]
 		}
+		worker.registersMutex.RUnlock()
 		if isExits {
 			func() {
-				defer func() {
-					if err := recover(); err != nil {
-						logger.Error.Println(err)
-					}
-				}()
+				defer logger.Module(LoggerModule).RecoverAndLog("kafka consumer callback panic", "topic", packet.SendTo, "routingKey", packet.RoutingKey)
 				consumerMessage := ConvertKafkaPacketToMQConsumerMessage(packet)
 				if consumerProxy.Callback != nil {
 					result := consumerProxy.Callback(consumerMessage)
 					// if result != nil {
-					// 	logger.Debug.Println("onMessage result=" + utils.HumanByteText(result.Body))
-					// 	logger.Debug.Println("onMessage packet.ReplyTo=" + packet.ReplyTo)
+					// 	logs.Debug.Println("onMessage result=" + utils.HumanByteText(result.Body))
+					// 	logs.Debug.Println("onMessage packet.ReplyTo=" + packet.ReplyTo)
 					// }
 					if result != nil && packet.ReplyTo != "" {
-						// logger.Debug.Println("onMessage begin to reply")
+						// logs.Debug.Println("onMessage begin to reply")
 						worker.reply(packet.ReplyTo, result, packet.CorrelationId)
 					}
 				}
@@ -261,47 +271,113 @@ func (worker *KafkaWorker) onMessage(packet *KafkaPacket) {
 func (worker *KafkaWorker) bindToOnMessage(data []byte) {
 	// 私有topic不一定存在，所以worker 会发送信息来创建。
 	// 收到创建的信息忽略掉
-	logger.Debug.Println("bindToOnMessage: " + utils.HumanByteText(data))
+	logs.Debug.Println("bindToOnMessage: " + utils.HumanByteText(data))
 	if strings.Contains(string(data), "_register_private") {
 		return
 	}
-	worker.Stats.Consumer.Bytes += int64(len(data))
-	worker.Stats.Consumer.Messages++
+	p, err := worker.unmarshalPacket(data)
+	if err != nil {
+		logs.Error.Println(err)
+	} else {
+		worker.extractRoutingKey(p)
+		worker.onMessage(p)
+	}
+
+}
+
+// unmarshalPacket 按UseOriginalContent 选择json 或protobuf 反序列化.
+func (worker *KafkaWorker) unmarshalPacket(data []byte) (*KafkaPacket, error) {
 	p := &KafkaPacket{}
 	var err error
 	if worker.UseOriginalContent {
 		err = json.Unmarshal(data, p)
-
 	} else {
 		err = proto.Unmarshal(data, p)
 	}
 	if err != nil {
-		logger.Error.Println(err)
+		return nil, err
+	}
+	if p.Version > KafkaPacketVersion {
+		// 新版本增加的字段在解码时被忽略
+		logs.Debug.Printf("kafka packet version %d is newer than %d", p.Version, KafkaPacketVersion)
+	}
+	return p, nil
+}
+
+// bindToOnSchemaMessage 返回绑定schema 序列化topic 的回调，解码后的消息体按普通消息处理.
+func (worker *KafkaWorker) bindToOnSchemaMessage(topic string, serializer SchemaSerializer) CallBack {
+	return func(data []byte) {
+		body, err := serializer.Deserialize(topic, data)
+		if err != nil {
+			logs.Error.Printf("deserialize kafka message of topic:%s failed with error:%v", topic, err)
+			return
+		}
+		worker.onMessage(&KafkaPacket{
+			ContentType: serializer.ContentType(),
+			SendTo:      topic,
+			Timestamp:   uint64(utils.CurrentMillisecond()),
+			Body:        body,
+			Exchange:    topic,
+		})
+	}
+}
+
+// SetTopicSerializer 设置topic 的schema 序列化器，serializer 为nil 时恢复为KafkaPacket 封装，需要在订阅topic 之前设置.
+func (worker *KafkaWorker) SetTopicSerializer(topic string, serializer SchemaSerializer) {
+	worker.serializersMutex.Lock()
+	if nil == serializer {
+		delete(worker.serializers, topic)
 	} else {
-		worker.extractRoutingKey(p)
-		worker.onMessage(p)
+		worker.serializers[topic] = serializer
 	}
+	worker.serializersMutex.Unlock()
+}
 
+func (worker *KafkaWorker) getTopicSerializer(topic string) SchemaSerializer {
+	worker.serializersMutex.RLock()
+	serializer := worker.serializers[topic]
+	worker.serializersMutex.RUnlock()
+	return serializer
 }
 
 // Subscribe 订阅topic.
 func (worker *KafkaWorker) Subscribe(topic string, consumeProxy *mqenv.MQConsumerProxy) error {
-
-	_, ok := worker.consumerRegisters[topic]
+	worker.subscribeMutex.Lock()
+	defer worker.subscribeMutex.Unlock()
+	_, ok := worker.getConsumerProxy(topic)
 	if !ok {
-		logger.Info.Println("Subscribe subscribing topic " + topic)
-		worker.Consumer.Receive(topic, worker.bindToOnMessage)
-		worker.consumerRegisters[topic] = consumeProxy
-
+		logs.Info.Println("Subscribe subscribing topic " + topic)
+		var err error
+		if serializer := worker.getTopicSerializer(topic); nil != serializer {
+			err = worker.Consumer.Receive(topic, worker.bindToOnSchemaMessage(topic, serializer))
+		} else {
+			err = worker.Consumer.Receive(topic, worker.bindToOnMessage)
+		}
+		if err != nil {
+			logs.Error.Printf("subscribe kafka topic:%s failed with error:%v", topic, err)
+			return err
+		}
 	}
 	key := fmt.Sprintf("%s-%s", consumeProxy.Queue, consumeProxy.ConsumerTag)
-	_, ok = worker.methodRegisters[key]
+	worker.registersMutex.Lock()
 	if !ok {
+		worker.consumerRegisters[topic] = consumeProxy
+	}
+	if _, exists := worker.methodRegisters[key]; !exists {
 		worker.methodRegisters[key] = consumeProxy
 	}
+	worker.registersMutex.Unlock()
 	return nil
 }
 
+// getConsumerProxy 返回topic 订阅时注册的consumer proxy.
+func (worker *KafkaWorker) getConsumerProxy(topic string) (*mqenv.MQConsumerProxy, bool) {
+	worker.registersMutex.RLock()
+	consumerProxy, ok := worker.consumerRegisters[topic]
+	worker.registersMutex.RUnlock()
+	return consumerProxy, ok
+}
+
 // extractRoutingKey 尝试从body 里面提取routing key.
 func (worker *KafkaWorker) extractRoutingKey(packet *KafkaPacket) error {
 
@@ -314,7 +390,7 @@ func (worker *KafkaWorker) extractRoutingKey(packet *KafkaPacket) error {
 	}
 	err := json.Unmarshal(packet.Body, &params)
 	if nil != err {
-		// logger.Error.Println(err)
+		// logs.Error.Println(err)
 		return err
 	}
 	if params.Method != "" {
@@ -328,19 +404,79 @@ func (worker *KafkaWorker) extractRoutingKey(packet *KafkaPacket) error {
 	return fmt.Errorf("cannot found method value")
 }
 
+// Close 优雅关闭worker：停止消费并在timeout 内等待正在处理的回调完成，
+// 提交偏移量并关闭reader，最后把生产者缓冲中的消息(包括回调产生的回复)发送完并关闭writer.
+// 等待回调超时时返回错误并保留生产者，仍在处理的回调可以继续发送回复，可以稍后再次调用Close.
+func (worker *KafkaWorker) Close(timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultCloseTimeout
+	}
+	worker.StopStatsReporter()
+	worker.retryStopOnce.Do(func() {
+		close(worker.retryStop)
+	})
+	if err := worker.Consumer.Close(timeout); err != nil {
+		logs.Error.Printf("close kafka consumer failed with error:%v, keeping producer open for processing callbacks", err)
+		return err
+	}
+	if nil != worker.Transaction {
+		if err := worker.Transaction.Close(); err != nil {
+			logs.Error.Printf("abort kafka transaction on close failed with error:%v", err)
+		}
+	}
+	producerErr := worker.Producer.Close()
+	worker.openTopicMutex.Lock()
+	worker.openTopicChannel = make(map[string]string)
+	worker.openTopicMutex.Unlock()
+	return producerErr
+}
+
 // NewKafkaWorker 实例化一个kafka worker.
 func NewKafkaWorker(hosts string, partition int, privateTopic, groupID string) *KafkaWorker {
 	worker := &KafkaWorker{}
 	worker.Producer = NewProducer(hosts, partition)
 	worker.Consumer = NewConsumer(hosts, groupID)
 	worker.PrivateTopic = privateTopic
+	worker.Consumer.privateTopic = privateTopic
 	worker.waitResponseMessage = make(map[string]chan *KafkaPacket)
 	worker.availableChannels = []chan *KafkaPacket{}
 	worker.consumerRegisters = make(map[string]*mqenv.MQConsumerProxy)
 	worker.methodRegisters = make(map[string]*mqenv.MQConsumerProxy)
 	worker.openTopicChannel = make(map[string]string)
-	worker.Stats.Consumer = InstStats{}
-	worker.Stats.Producer = InstStats{}
+	worker.stats.Consumer = InstStats{}
+	worker.stats.Producer = InstStats{}
+	worker.serializers = make(map[string]SchemaSerializer)
+	worker.retryStop = make(chan struct{})
 
 	return worker
 }
+
+// DriverType 返回mqenv.DriverTypeKafka.
+func (worker *KafkaWorker) DriverType() string {
+	return mqenv.DriverTypeKafka
+}
+
+// PublishMessage 发送信息到pm.Exchange 指定的topic，不等待回复.
+func (worker *KafkaWorker) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to kafka")
+	}
+	_, err := worker.Send(pm.Exchange, pm, false)
+	return err
+}
+
+// ConsumeMessage 订阅consumeProxy.Queue 指定的topic.
+func (worker *KafkaWorker) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume kafka with nil consumer proxy")
+	}
+	return worker.Subscribe(consumeProxy.Queue, consumeProxy)
+}
+
+// QueryMessage 发送信息到pm.Exchange 指定的topic 并等待回复.
+func (worker *KafkaWorker) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	if nil == pm {
+		return nil, errors.New("query kafka with nil message")
+	}
+	return worker.Send(pm.Exchange, pm, true)
+}
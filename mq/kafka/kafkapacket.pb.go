@@ -1,13 +1,21 @@
+// KafkaPacket 是golib 通过kafka 收发消息时使用的封装格式，非go 服务按此定义编解码即可与golib 互通.
+// 重新生成kafkapacket.pb.go:
+//   protoc --go_out=. --go_opt=paths=source_relative kafkapacket.proto
+//
+// 兼容性规则:
+//   1. 不修改已有字段的编号、类型和名称，不复用已删除字段的编号，删除的字段加入reserved
+//   2. 只增加新的字段，新字段缺省值必须与旧版本的行为一致，旧版本解码时会忽略未知字段
+//   3. 字段含义或封装方式不兼容地变化时增加version，接收方按version 区分处理
+
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.25.0
-// 	protoc        v3.12.1
+// 	protoc-gen-go v1.28.1
+// 	protoc        (unknown)
 // source: kafkapacket.proto
 
 package kafka
 
 import (
-	proto "github.com/golang/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -21,10 +29,6 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// This is a compile-time assertion that a sufficiently up-to-date version
-// of the legacy proto package is being used.
-const _ = proto.ProtoPackageIsVersion4
-
 type KafkaPacket struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -49,6 +53,7 @@ type KafkaPacket struct {
 	RoutingKey      string                `protobuf:"bytes,16,opt,name=routingKey,proto3" json:"routingKey,omitempty"` // application use - delivery request
 	ConsumerTag     string                `protobuf:"bytes,17,opt,name=consumerTag,proto3" json:"consumerTag,omitempty"`
 	Exchange        string                `protobuf:"bytes,18,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	Version         uint32                `protobuf:"varint,19,opt,name=version,proto3" json:"version,omitempty"` // packet framing version, 0 for packets of the producers before versioning
 }
 
 func (x *KafkaPacket) Reset() {
@@ -209,6 +214,13 @@ func (x *KafkaPacket) GetExchange() string {
 	return ""
 }
 
+func (x *KafkaPacket) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
 type KafkaPacket_Header struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -269,8 +281,8 @@ var File_kafkapacket_proto protoreflect.FileDescriptor
 var file_kafkapacket_proto_rawDesc = []byte{
 	0x0a, 0x11, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x70, 0x72,
 	0x6f, 0x74, 0x6f, 0x12, 0x19, 0x78, 0x68, 0x68, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
-	0x6f, 0x6c, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0xfc,
-	0x04, 0x0a, 0x0b, 0x4b, 0x61, 0x66, 0x6b, 0x61, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x20,
+	0x6f, 0x6c, 0x2e, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0x96,
+	0x05, 0x0a, 0x0b, 0x4b, 0x61, 0x66, 0x6b, 0x61, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x20,
 	0x0a, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65,
 	0x12, 0x28, 0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x45, 0x6e, 0x63, 0x6f, 0x64,
@@ -306,11 +318,15 @@ var file_kafkapacket_proto_rawDesc = []byte{
 	0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x65, 0x72, 0x54, 0x61, 0x67, 0x18, 0x11, 0x20, 0x01, 0x28, 0x09,
 	0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x65, 0x72, 0x54, 0x61, 0x67, 0x12, 0x1a, 0x0a,
 	0x08, 0x65, 0x78, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x08, 0x65, 0x78, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x1a, 0x32, 0x0a, 0x06, 0x48, 0x65, 0x61,
-	0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x09, 0x5a,
-	0x07, 0x2e, 0x3b, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x08, 0x65, 0x78, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x13, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x1a, 0x32, 0x0a, 0x06, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x22, 0x5a, 0x20, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c, 0x69, 0x62, 0x70, 0x75, 0x62, 0x2f, 0x67, 0x6f, 0x6c,
+	0x69, 0x62, 0x2f, 0x6d, 0x71, 0x2f, 0x6b, 0x61, 0x66, 0x6b, 0x61, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
 }
 
 var (
@@ -0,0 +1,10 @@
+package kafka
+
+import "github.com/libpub/golib/metrics"
+
+// kafka 的内部指标，通过metrics.Init 选择的导出方式导出.
+var (
+	producedCounter = metrics.NewCounter("kafka_produced_messages_total", "Messages produced to kafka", "topic")
+	consumedCounter = metrics.NewCounter("kafka_consumed_messages_total", "Messages consumed from kafka", "topic")
+	errorsCounter   = metrics.NewCounter("kafka_errors_total", "Kafka read or write failures", "topic", "producer")
+)
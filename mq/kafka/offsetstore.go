@@ -0,0 +1,245 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	k "github.com/segmentio/kafka-go"
+)
+
+// ErrOffsetStoreNeedsGroup 使用外部偏移量存储时需要配置固定的消费者组，偏移量按消费者组保存.
+var ErrOffsetStoreNeedsGroup = errors.New("kafka offset store requires consumer group")
+
+// OffsetStore 外部偏移量存储，如redis、数据库，作为向broker 提交偏移量的替代.
+// 保存的偏移量为分区下一条要消费的消息的偏移量.
+type OffsetStore interface {
+	// LoadOffset 返回消费者组在分区上保存的偏移量，没有保存过时ok 为false
+	LoadOffset(ctx context.Context, groupID string, topic string, partition int) (offset int64, ok bool, err error)
+	// SaveOffset 保存消费者组在分区上的偏移量
+	SaveOffset(ctx context.Context, groupID string, topic string, partition int, offset int64) error
+}
+
+// MemoryOffsetStore 进程内的偏移量存储，进程重启后丢失，可用于测试或只需要在重新订阅时恢复位置的场景.
+type MemoryOffsetStore struct {
+	offsets map[string]int64
+	mu      sync.RWMutex
+}
+
+// NewMemoryOffsetStore 返回进程内的偏移量存储.
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{offsets: make(map[string]int64)}
+}
+
+// LoadOffset 实现OffsetStore.
+func (s *MemoryOffsetStore) LoadOffset(ctx context.Context, groupID string, topic string, partition int) (int64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	offset, ok := s.offsets[committedOffsetKey(groupID, topic, partition)]
+	return offset, ok, nil
+}
+
+// SaveOffset 实现OffsetStore.
+func (s *MemoryOffsetStore) SaveOffset(ctx context.Context, groupID string, topic string, partition int, offset int64) error {
+	s.mu.Lock()
+	s.offsets[committedOffsetKey(groupID, topic, partition)] = offset
+	s.mu.Unlock()
+	return nil
+}
+
+func committedOffsetKey(groupID string, topic string, partition int) string {
+	return fmt.Sprintf("%s/%s/%d", groupID, topic, partition)
+}
+
+// ConfigOffsetStore 配置外部偏移量存储，配置后Receive 不加入消费者组，每个分区一个reader，
+// 从存储的偏移量开始消费，没有存储的偏移量时按ConfigOffsetMode 的起始位置消费.
+// 每条消息的回调返回后保存偏移量，重启后从最后处理的消息之后继续消费，回调和保存之间进程退出时会重复处理一条消息.
+// 同一分区的消息按顺序处理，不使用并发配置；订阅之后新增的分区不会被消费.
+// 私有topic 不使用外部偏移量存储.
+func (c *Consumer) ConfigOffsetStore(store OffsetStore) {
+	c.Config["offset.store"] = store
+}
+
+// offsetStore 返回topic 使用的外部偏移量存储，未配置时返回nil.
+func (c *Consumer) offsetStore(topic string) OffsetStore {
+	if topic != "" && topic == c.privateTopic {
+		return nil
+	}
+	store, _ := c.Config["offset.store"].(OffsetStore)
+	return store
+}
+
+// lookupPartitions 返回topic 的分区列表.
+func (c *Consumer) lookupPartitions(ctx context.Context, topic string) ([]int, error) {
+	dialer := c.dialer()
+	if dialer == nil {
+		dialer = k.DefaultDialer
+	}
+	var lastErr error
+	for _, broker := range c.Brokers {
+		partitions, err := dialer.LookupPartitions(ctx, "tcp", broker, topic)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ids := make([]int, 0, len(partitions))
+		for _, partition := range partitions {
+			ids = append(ids, partition.ID)
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("kafka topic:%s has no partitions", topic)
+		}
+		sort.Ints(ids)
+		return ids, nil
+	}
+	return nil, lastErr
+}
+
+// partitionReaderOffset 把分区reader 设置到存储的偏移量，没有存储时按起始位置模式设置，返回下一条要消费的偏移量，未知时为-1.
+func (c *Consumer) partitionReaderOffset(ctx context.Context, store OffsetStore, groupID string, topic string, partition int, reader *k.Reader) (int64, error) {
+	offset, ok, err := store.LoadOffset(ctx, groupID, topic, partition)
+	if err != nil {
+		return -1, fmt.Errorf("load kafka offset of topic:%s partition:%d failed with error:%v", topic, partition, err)
+	}
+	if ok {
+		return offset, reader.SetOffset(offset)
+	}
+	switch c.topicOffsetMode(topic) {
+	case OffsetModeEarliest:
+		return -1, reader.SetOffset(k.FirstOffset)
+	case OffsetModeLatest:
+		return -1, reader.SetOffset(k.LastOffset)
+	}
+	return -1, c.applyStartOffset(topic, reader)
+}
+
+// receiveWithOffsetStore 每个分区一个不加入消费者组的reader，处理消息后把偏移量保存到store.
+func (c *Consumer) receiveWithOffsetStore(topic string, store OffsetStore, callback CallBack) error {
+	groupID, _ := c.Config["group.id"].(string)
+	if groupID == "" {
+		return ErrOffsetStoreNeedsGroup
+	}
+	config, err := c.readerConfig(topic)
+	if err != nil {
+		return err
+	}
+	config.GroupID = ""
+	config.CommitInterval = 0
+
+	lookupCtx, lookupCancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer lookupCancel()
+	partitions, err := c.lookupPartitions(lookupCtx, topic)
+	if err != nil {
+		logs.Error.Printf("lookup kafka topic:%s partitions failed with error:%v", topic, err)
+		return err
+	}
+	configs := make([]k.ReaderConfig, len(partitions))
+	readers := make([]*k.Reader, len(partitions))
+	offsets := make([]int64, len(partitions))
+	for i, partition := range partitions {
+		configs[i] = config
+		configs[i].Partition = partition
+		readers[i] = k.NewReader(configs[i])
+		offsets[i], err = c.partitionReaderOffset(lookupCtx, store, groupID, topic, partition, readers[i])
+		if err != nil {
+			for _, reader := range readers[:i+1] {
+				reader.Close()
+			}
+			logs.Error.Printf("set kafka topic:%s partition:%d start offset failed with error:%v", topic, partition, err)
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.mu.Lock()
+	if _, ok := c.Readers[topic]; ok {
+		c.mu.Unlock()
+		cancel()
+		for _, reader := range readers {
+			reader.Close()
+		}
+		return errors.New("The topic is already subscribed")
+	}
+	c.Readers[topic] = readers[0]
+	c.partitionReaders[topic] = readers
+	c.groupIDs[topic] = groupID
+	c.running[topic] = true
+	c.cancels[topic] = cancel
+	c.done[topic] = done
+	c.mu.Unlock()
+
+	wg := sync.WaitGroup{}
+	for i := range partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.consumePartition(ctx, store, groupID, configs[i], i, readers[i], offsets[i], callback)
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return nil
+}
+
+// consumePartition 按顺序处理分区的消息并保存偏移量，next 为下一条要消费的偏移量，未知时为-1.
+func (c *Consumer) consumePartition(ctx context.Context, store OffsetStore, groupID string, config k.ReaderConfig, idx int, reader *k.Reader, next int64, callback CallBack) {
+	topic := config.Topic
+	defer func() {
+		reader.Close()
+	}()
+	failures := 0
+	for ctx.Err() == nil {
+		m, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			failures++
+			if !c.handleReadError(ctx, topic, err, failures) {
+				break
+			}
+			if failures%DefaultReconnectFailures == 0 {
+				logs.Warning.Printf("reconnecting kafka reader of topic:%s partition:%d", topic, config.Partition)
+				reader.Close()
+				reader = k.NewReader(config)
+				if next >= 0 {
+					err = reader.SetOffset(next)
+				} else {
+					_, err = c.partitionReaderOffset(ctx, store, groupID, topic, config.Partition, reader)
+				}
+				if err != nil {
+					logs.Error.Printf("set kafka topic:%s partition:%d offset while reconnecting failed with error:%v", topic, config.Partition, err)
+				}
+				c.mu.Lock()
+				if readers := c.partitionReaders[topic]; idx < len(readers) {
+					readers[idx] = reader
+					c.Readers[topic] = readers[0]
+				}
+				c.mu.Unlock()
+			}
+			continue
+		}
+		failures = 0
+		consumedCounter.Inc(topic)
+		next = m.Offset + 1
+		value, complete := c.chunks.add(m)
+		if !complete {
+			// 分片未收齐时不保存偏移量，恢复消费时重新读取分片
+			continue
+		}
+		invokeConsumerCallback(callback, value)
+		// 消费停止时仍然保存已处理消息的偏移量
+		saveCtx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+		err = store.SaveOffset(saveCtx, groupID, topic, m.Partition, next)
+		cancel()
+		if err != nil {
+			logs.Error.Printf("save kafka offset:%d of topic:%s partition:%d failed with error:%v", next, topic, m.Partition, err)
+			c.notifyError(ErrorEvent{Topic: topic, Err: err, Failures: 1})
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"errors"
+	"sort"
+
+	k "github.com/segmentio/kafka-go"
+)
+
+// PartitionStrategy 消息选择分区的方式.
+type PartitionStrategy int
+
+// 分区方式
+const (
+	PartitionByKey      = PartitionStrategy(0) // 按Key 的hash 选择分区，相同Key 的消息写入同一分区保持顺序，Key 为空时轮询
+	PartitionRoundRobin = PartitionStrategy(1) // 轮询分区
+	PartitionExplicit   = PartitionStrategy(2) // 写入Partition 指定的分区
+)
+
+// writer 的balancer 通过消息的Partition 区分分区方式，不小于0 时为指定的分区
+const (
+	balanceByKey      = -1
+	balanceRoundRobin = -2
+)
+
+// ErrInvalidPartition 指定的分区不合法.
+var ErrInvalidPartition = errors.New("invalid kafka partition")
+
+// ProducerMessage 按分区方式发送的消息.
+type ProducerMessage struct {
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string // 写入kafka 消息的header
+	Strategy  PartitionStrategy
+	Partition int // Strategy 为PartitionExplicit 时写入的分区
+}
+
+// KeyedMessage 返回按key 的hash 选择分区的消息.
+func KeyedMessage(key []byte, value []byte) ProducerMessage {
+	return ProducerMessage{Key: key, Value: value, Strategy: PartitionByKey}
+}
+
+// PartitionMessage 返回写入指定分区的消息.
+func PartitionMessage(partition int, value []byte) ProducerMessage {
+	return ProducerMessage{Value: value, Strategy: PartitionExplicit, Partition: partition}
+}
+
+// kafkaMessage 转换为writer 发送的消息，分区方式记录在Partition 中由partitionBalancer 处理.
+func (m ProducerMessage) kafkaMessage() (k.Message, error) {
+	msg := k.Message{Key: m.Key, Value: m.Value}
+	switch m.Strategy {
+	case PartitionExplicit:
+		if m.Partition < 0 {
+			return msg, ErrInvalidPartition
+		}
+		msg.Partition = m.Partition
+	case PartitionRoundRobin:
+		msg.Partition = balanceRoundRobin
+	default:
+		msg.Partition = balanceByKey
+	}
+	if len(m.Headers) > 0 {
+		names := make([]string, 0, len(m.Headers))
+		for name := range m.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		msg.Headers = make([]k.Header, len(names))
+		for i, name := range names {
+			msg.Headers[i] = k.Header{Key: name, Value: []byte(m.Headers[name])}
+		}
+	}
+	return msg, nil
+}
+
+// partitionBalancer 按消息的分区方式选择分区.
+// 指定的分区不存在时仍然写入该分区，由broker 返回的错误通过CompletionCallback 通知.
+type partitionBalancer struct {
+	hash       k.Hash
+	roundRobin k.RoundRobin
+}
+
+// Balance 实现k.Balancer，Key 为空或轮询分区的分片消息按分片id 选择分区，保证同一条消息的分片在同一个分区.
+func (b *partitionBalancer) Balance(msg k.Message, partitions ...int) int {
+	if msg.Partition >= 0 {
+		return msg.Partition
+	}
+	if msg.Partition == balanceRoundRobin || len(msg.Key) == 0 {
+		if id := headerValue(msg.Headers, HeaderChunkID); id != nil {
+			return b.hash.Balance(k.Message{Key: id}, partitions...)
+		}
+	}
+	if msg.Partition == balanceRoundRobin {
+		return b.roundRobin.Balance(msg, partitions...)
+	}
+	return b.hash.Balance(msg, partitions...)
+}
@@ -2,62 +2,266 @@ package kafka
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/libpub/golib/logger"
 	k "github.com/segmentio/kafka-go"
-	"github.com/segmentio/kafka-go/sasl/plain"
 )
 
 // Producer 生产者.
 type Producer struct {
 	Base
-	Brokers []string // kafka 的节点
-	Writer  map[string]*k.Writer
+	Brokers  []string // kafka 的节点
+	Writer   map[string]*k.Writer
+	failures map[string]int // 每个topic 连续发送失败的次数
+	mu       sync.Mutex     // 保护Writer、failures、client 和partitions
+
+	client     *k.Client         // 同步发送使用的客户端
+	balancer   partitionBalancer // 同步发送时选择分区
+	partitions map[string][]int  // 同步发送时topic 的分区缓存
+}
+
+// ProduceResult 同步发送的消息写入的位置.
+type ProduceResult struct {
+	Topic     string
+	Partition int
+	Offset    int64
 }
 
-// Send 发送一条消息.
+// Send 发送一条消息，轮询分区.
 func (p *Producer) Send(topic string, value []byte) error {
-	logger.Debug.Printf("send %s %s", topic, value)
+	return p.SendMessage(topic, ProducerMessage{Value: value, Strategy: PartitionRoundRobin})
+}
+
+// SendMessage 按消息的分区方式发送一条消息.
+func (p *Producer) SendMessage(topic string, message ProducerMessage) error {
+	logs.Debug.Printf("send %s %s", topic, message.Value)
+	msgs, err := p.kafkaMessages(message)
+	if err != nil {
+		return err
+	}
+	err = p.writer(topic).WriteMessages(context.Background(), msgs...)
+	return categorizeError(err)
+}
+
+// kafkaMessages 转换为writer 发送的消息，消息体超过ConfigMaxMessageBytes 时拆分为分片消息.
+func (p *Producer) kafkaMessages(message ProducerMessage) ([]k.Message, error) {
+	chunks := message.splitChunks(p.maxMessageBytes())
+	msgs := make([]k.Message, len(chunks))
+	for i, chunk := range chunks {
+		msg, err := chunk.kafkaMessage()
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = msg
+	}
+	return msgs, nil
+}
+
+// SendContext 同步发送一条消息，轮询分区，返回消息写入的分区和偏移量.
+func (p *Producer) SendContext(ctx context.Context, topic string, value []byte) (ProduceResult, error) {
+	return p.SendMessageContext(ctx, topic, ProducerMessage{Value: value, Strategy: PartitionRoundRobin})
+}
+
+// SendMessageContext 按消息的分区方式同步发送一条消息，等待所有同步副本确认后返回消息写入的分区和偏移量，
+// 拆分为分片发送时为最后一个分片的偏移量.
+// 等待时间由ctx 限制，ctx 没有设置超时时最长等待DefaultAdminTimeout.
+// ctx 超时后消息仍可能已经写入.
+func (p *Producer) SendMessageContext(ctx context.Context, topic string, message ProducerMessage) (ProduceResult, error) {
+	logs.Debug.Printf("send %s %s", topic, message.Value)
+	result := ProduceResult{Topic: topic, Partition: -1, Offset: -1}
+	msgs, err := p.kafkaMessages(message)
+	if err != nil {
+		return result, err
+	}
+	partitions, err := p.topicPartitions(ctx, topic)
+	if err == nil {
+		// 同一条消息的分片选择相同的分区
+		result.Partition = p.balancer.Balance(msgs[0], partitions...)
+		for _, msg := range msgs {
+			var resp *k.ProduceResponse
+			resp, err = p.getClient().Produce(ctx, &k.ProduceRequest{
+				Topic:        topic,
+				Partition:    result.Partition,
+				RequiredAcks: k.RequireAll,
+				Compression:  p.compression(),
+				Records: k.NewRecordReader(k.Record{
+					Time:    time.Now(),
+					Key:     k.NewBytes(msg.Key),
+					Value:   k.NewBytes(msg.Value),
+					Headers: msg.Headers,
+				}),
+			})
+			if err == nil && resp.Error != nil {
+				err = resp.Error
+			}
+			if err != nil {
+				break
+			}
+			result.Offset = resp.BaseOffset
+		}
+	}
+	p.mu.Lock()
+	if err == nil {
+		delete(p.failures, topic)
+		p.mu.Unlock()
+		producedCounter.Add(float64(len(msgs)), topic)
+		return result, nil
+	}
+	// 分区或leader 可能已变化，下次发送时重新查询
+	delete(p.partitions, topic)
+	p.failures[topic]++
+	failures := p.failures[topic]
+	p.mu.Unlock()
+	p.notifyError(ErrorEvent{Topic: topic, Err: err, Fatal: IsFatalError(err), Failures: failures, Producer: true})
+	return result, categorizeError(err)
+}
+
+// getClient 返回同步发送使用的客户端.
+func (p *Producer) getClient() *k.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client == nil {
+		p.client = &k.Client{
+			Addr:    k.TCP(p.Brokers...),
+			Timeout: DefaultAdminTimeout,
+			Transport: &k.Transport{
+				SASL: p.saslMechanism(),
+			},
+		}
+	}
+	return p.client
+}
+
+// topicPartitions 返回topic 的分区列表，首次使用时从元数据查询并缓存.
+func (p *Producer) topicPartitions(ctx context.Context, topic string) ([]int, error) {
+	p.mu.Lock()
+	partitions, ok := p.partitions[topic]
+	p.mu.Unlock()
+	if ok {
+		return partitions, nil
+	}
+	resp, err := p.getClient().Metadata(ctx, &k.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Topics) == 0 {
+		return nil, fmt.Errorf("kafka topic:%s not found", topic)
+	}
+	if resp.Topics[0].Error != nil {
+		return nil, resp.Topics[0].Error
+	}
+	partitions = make([]int, 0, len(resp.Topics[0].Partitions))
+	for _, partition := range resp.Topics[0].Partitions {
+		partitions = append(partitions, partition.ID)
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("kafka topic:%s has no partitions", topic)
+	}
+	sort.Ints(partitions)
+	p.mu.Lock()
+	p.partitions[topic] = partitions
+	p.mu.Unlock()
+	return partitions, nil
+}
+
+// compression 返回配置的压缩方式.
+func (p *Producer) compression() k.Compression {
+	compression, _ := p.Config["compression.codec"].(k.Compression)
+	return compression
+}
+
+// writer 返回topic 的writer，不存在时创建.
+func (p *Producer) writer(topic string) *k.Writer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	writer, ok := p.Writer[topic]
 	if !ok {
 		config := k.WriterConfig{
 			Brokers:      p.Brokers,
 			Topic:        topic,
-			Balancer:     &k.Hash{},
+			Balancer:     &partitionBalancer{},
 			Async:        true,
 			BatchTimeout: 10 * time.Millisecond,
 		}
-		// logger.Trace.Printf("new writer %s", topic)
-		if p.Config["sasl.username"] != nil && p.Config["sasl.password"] != nil {
-			logger.Debug.Println("using sasl ")
-			mechanism := plain.Mechanism{
-				Username: p.Config["sasl.username"].(string),
-				Password: p.Config["sasl.password"].(string),
-			}
-			dialer := &k.Dialer{
-				Timeout:       10 * time.Second,
-				DualStack:     true,
-				SASLMechanism: mechanism,
-			}
+		// logs.Trace.Printf("new writer %s", topic)
+		if dialer := p.dialer(); dialer != nil {
 			config.Dialer = dialer
-
 		}
 		writer = k.NewWriter(config)
-		if p.CompletionCallback != nil {
-			writer.Completion = p.CompletionCallback
-		}
+		writer.WriteBackoffMin, writer.WriteBackoffMax = p.reconnectBackoffRange()
+		writer.Completion = p.completion(topic, writer)
+		writer.Compression = p.compression()
 
 		p.Writer[topic] = writer
 	}
-	err := writer.WriteMessages(context.Background(),
-		k.Message{
-			Value: value,
-		},
-	)
+	return writer
+}
 
-	return err
+// ConfigCompression 配置消息的压缩方式，可以使用none、gzip、snappy、lz4、zstd，对之后创建的writer 生效.
+func (p *Producer) ConfigCompression(codec string) error {
+	var compression k.Compression
+	if err := compression.UnmarshalText([]byte(strings.ToLower(strings.TrimSpace(codec)))); err != nil {
+		return err
+	}
+	p.Config["compression.codec"] = compression
+	return nil
+}
+
+// completion 返回writer 的发送结果回调，记录连续失败次数并通知ErrorCallback.
+// 连续失败DefaultReconnectFailures 次后丢弃writer，下次发送时重新创建.
+func (p *Producer) completion(topic string, writer *k.Writer) func(messages []k.Message, err error) {
+	return func(messages []k.Message, err error) {
+		if p.CompletionCallback != nil {
+			p.CompletionCallback(messages, err)
+		}
+		if err == nil {
+			producedCounter.Add(float64(len(messages)), topic)
+		}
+		p.mu.Lock()
+		if err == nil {
+			delete(p.failures, topic)
+			p.mu.Unlock()
+			return
+		}
+		p.failures[topic]++
+		failures := p.failures[topic]
+		fatal := IsFatalError(err)
+		reconnect := !fatal && failures%DefaultReconnectFailures == 0 && p.Writer[topic] == writer
+		if reconnect {
+			delete(p.Writer, topic)
+		}
+		p.mu.Unlock()
+		p.notifyError(ErrorEvent{Topic: topic, Err: err, Fatal: fatal, Failures: failures, Producer: true})
+		if reconnect {
+			logs.Warning.Printf("reconnecting kafka writer of topic:%s", topic)
+			// 在回调中关闭writer 会等待回调自身返回，所以异步关闭
+			go writer.Close()
+		}
+	}
+}
+
+// Close 关闭所有writer，异步模式下会等待缓冲中的消息发送完成.
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	writers := p.Writer
+	p.Writer = make(map[string]*k.Writer)
+	client := p.client
+	p.mu.Unlock()
+	if client != nil {
+		client.Transport.(*k.Transport).CloseIdleConnections()
+	}
+	var lastErr error
+	for topic, writer := range writers {
+		if err := writer.Close(); err != nil {
+			logs.Error.Printf("close kafka writer for topic:%s failed with error:%v", topic, err)
+			lastErr = err
+		}
+	}
+	return lastErr
 }
 
 // NewProducer 返回一个生产者.
@@ -65,6 +269,8 @@ func NewProducer(hosts string, partition int) *Producer {
 	p := &Producer{}
 	p.Config = make(map[string]interface{})
 	p.Writer = make(map[string]*k.Writer)
+	p.failures = make(map[string]int)
+	p.partitions = make(map[string][]int)
 	p.Brokers = strings.Split(hosts, ",")
 	p.ConfigPartition(partition)
 	p.CompletionCallback = nil
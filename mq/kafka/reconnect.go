@@ -0,0 +1,171 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/libpub/golib/errors"
+	k "github.com/segmentio/kafka-go"
+)
+
+// 断线重连的默认配置
+const (
+	DefaultReconnectBackoff    = 100 * time.Millisecond // 第一次失败后的等待时间
+	DefaultReconnectBackoffMax = 10 * time.Second       // 最长等待时间
+	DefaultReconnectFailures   = 3                      // 连续失败多少次后重建reader/writer
+
+	errorLogInterval = 5 * time.Second // broker 不可用时读写错误日志的最小间隔
+)
+
+// 限制频率的错误日志，避免broker 不可用时刷屏
+var (
+	readerErrorLogger  = logs.ErrorEvery(errorLogInterval)
+	failureErrorLogger = logs.WarningEvery(errorLogInterval)
+)
+
+// ErrorEvent 读写kafka 失败的事件.
+type ErrorEvent struct {
+	Topic    string
+	Err      error
+	Fatal    bool // 不可恢复的错误(如认证、授权失败)，consumer 停止消费该topic
+	Failures int  // 连续失败的次数，成功后重新计数
+	Producer bool // 是否是生产者发送失败
+}
+
+// ErrorCallback 读写kafka 失败时的回调，可用于在broker 持续不可用时告警.
+type ErrorCallback func(event ErrorEvent)
+
+// fatalErrors 重试也不会成功的错误.
+var fatalErrors = []k.Error{
+	k.InvalidTopic,
+	k.InvalidRequiredAcks,
+	k.TopicAuthorizationFailed,
+	k.GroupAuthorizationFailed,
+	k.ClusterAuthorizationFailed,
+	k.UnsupportedSASLMechanism,
+	k.IllegalSASLState,
+	k.UnsupportedVersion,
+	k.TransactionalIDAuthorizationFailed,
+	k.BrokerAuthorizationFailed,
+	k.SASLAuthenticationFailed,
+	k.DelegationTokenAuthorizationFailed,
+}
+
+// errorCategories kafka 错误对应的错误分类.
+var errorCategories = map[k.Error]errors.Category{
+	k.RequestTimedOut:                    errors.Timeout,
+	k.ThrottlingQuotaExceeded:            errors.TooManyRequests,
+	k.TopicAuthorizationFailed:           errors.Unauthorized,
+	k.GroupAuthorizationFailed:           errors.Unauthorized,
+	k.ClusterAuthorizationFailed:         errors.Unauthorized,
+	k.TransactionalIDAuthorizationFailed: errors.Unauthorized,
+	k.BrokerAuthorizationFailed:          errors.Unauthorized,
+	k.SASLAuthenticationFailed:           errors.Unauthorized,
+	k.DelegationTokenAuthorizationFailed: errors.Unauthorized,
+	k.TopicAlreadyExists:                 errors.Conflict,
+	k.ConcurrentTransactions:             errors.Conflict,
+	k.InvalidProducerEpoch:               errors.Conflict,
+	k.ProducerFenced:                     errors.Conflict,
+	k.LeaderNotAvailable:                 errors.Upstream5xx,
+	k.NotLeaderForPartition:              errors.Upstream5xx,
+	k.BrokerNotAvailable:                 errors.Upstream5xx,
+	k.GroupCoordinatorNotAvailable:       errors.Upstream5xx,
+	k.NotEnoughReplicas:                  errors.Upstream5xx,
+	k.KafkaStorageError:                  errors.Upstream5xx,
+}
+
+// categorizeError 按错误分类包装kafka 错误，包装后仍可以用errors.As 取得原始的kafka 错误.
+func categorizeError(err error) error {
+	var kafkaErr k.Error
+	if nil == err || !errors.As(err, &kafkaErr) {
+		return err
+	}
+	if category, ok := errorCategories[kafkaErr]; ok {
+		return errors.Wrap(err, category, "")
+	}
+	return err
+}
+
+// IsFatalError 返回错误是否不可恢复，网络错误和其他kafka 错误都视为可以重试.
+func IsFatalError(err error) bool {
+	var kafkaErr k.Error
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+	for _, fatal := range fatalErrors {
+		if kafkaErr == fatal {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigReconnectBackoffMax 配置断线重连的最长等待时间，单位是毫秒，等待时间从ConfigReconnectInterval 开始逐次加倍.
+func (b *Base) ConfigReconnectBackoffMax(interval int) {
+	b.Config["reconnect.backoff.max.ms"] = interval
+}
+
+// SetErrorCallback 读写失败通知回调
+func (b *Base) SetErrorCallback(callback ErrorCallback) {
+	b.ErrorCallback = callback
+}
+
+// reconnectBackoff 返回第failures 次连续失败后的等待时间.
+func (b *Base) reconnectBackoff(failures int) time.Duration {
+	backoff, backoffMax := b.reconnectBackoffRange()
+	for i := 1; i < failures && backoff < backoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > backoffMax {
+		backoff = backoffMax
+	}
+	return backoff
+}
+
+// reconnectBackoffRange 返回配置的最短和最长等待时间.
+func (b *Base) reconnectBackoffRange() (time.Duration, time.Duration) {
+	backoff, backoffMax := DefaultReconnectBackoff, DefaultReconnectBackoffMax
+	if v, ok := b.Config["reconnect.backoff.ms"].(int); ok && v > 0 {
+		backoff = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := b.Config["reconnect.backoff.max.ms"].(int); ok && v > 0 {
+		backoffMax = time.Duration(v) * time.Millisecond
+	}
+	if backoffMax < backoff {
+		backoffMax = backoff
+	}
+	return backoff, backoffMax
+}
+
+// notifyError 记录错误日志并回调ErrorCallback.
+func (b *Base) notifyError(event ErrorEvent) {
+	event.Err = categorizeError(event.Err)
+	errorsCounter.Inc(event.Topic, strconv.FormatBool(event.Producer))
+	if event.Fatal {
+		logs.Error.Printf("kafka topic:%s failed with fatal error:%v", event.Topic, event.Err)
+	} else {
+		failureErrorLogger.Printf("kafka topic:%s failed %d times with error:%v", event.Topic, event.Failures, event.Err)
+	}
+	if b.ErrorCallback != nil {
+		b.ErrorCallback(event)
+	}
+}
+
+// sleepContext 等待d，ctx 取消时返回false.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetErrorCallback 设置生产者和消费者的读写失败通知回调.
+func (worker *KafkaWorker) SetErrorCallback(callback ErrorCallback) {
+	worker.Producer.SetErrorCallback(callback)
+	worker.Consumer.SetErrorCallback(callback)
+}
@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// 重试消息的header
+const (
+	HeaderRetryAttempt   = "x-retry-attempt"    // 已经失败的次数
+	HeaderRetryNotBefore = "x-retry-not-before" // 最早重新处理的时间，unix 毫秒
+	HeaderRetryError     = "x-retry-error"      // 最后一次处理失败的错误
+	HeaderOriginalTopic  = "x-original-topic"   // 消息最初所在的topic
+
+	DeadLetterTopicSuffix = "-dlq"
+)
+
+// DefaultRetryDelays 默认的重试间隔，对应topic-retry-5s/-1m/-10m.
+var DefaultRetryDelays = []time.Duration{5 * time.Second, time.Minute, 10 * time.Minute}
+
+// RetryHandler 处理消息，返回错误(或panic)时消息转发到下一级重试topic.
+type RetryHandler func(message mqenv.MQConsumerMessage) error
+
+// RetryPolicy 重试策略，Delays 为每一级重试的间隔，全部失败后转发到死信topic.
+type RetryPolicy struct {
+	Delays          []time.Duration
+	DeadLetterTopic string // 默认为topic 加-dlq 后缀
+}
+
+// delays 返回重试间隔，未配置时使用DefaultRetryDelays.
+func (p RetryPolicy) delays() []time.Duration {
+	if len(p.Delays) == 0 {
+		return DefaultRetryDelays
+	}
+	return p.Delays
+}
+
+// RetryTopic 返回第attempt 次重试(从1开始)的topic，如orders-retry-5s.
+func (p RetryPolicy) RetryTopic(topic string, attempt int) string {
+	return fmt.Sprintf("%s-retry-%s", topic, formatRetryDelay(p.delays()[attempt-1]))
+}
+
+// DeadLetterTopicOf 返回死信topic.
+func (p RetryPolicy) DeadLetterTopicOf(topic string) string {
+	if p.DeadLetterTopic != "" {
+		return p.DeadLetterTopic
+	}
+	return topic + DeadLetterTopicSuffix
+}
+
+// formatRetryDelay 把重试间隔格式化为topic 后缀，如5s、1m、2h.
+func formatRetryDelay(delay time.Duration) string {
+	switch {
+	case delay >= time.Hour && delay%time.Hour == 0:
+		return fmt.Sprintf("%dh", delay/time.Hour)
+	case delay >= time.Minute && delay%time.Minute == 0:
+		return fmt.Sprintf("%dm", delay/time.Minute)
+	case delay >= time.Second && delay%time.Second == 0:
+		return fmt.Sprintf("%ds", delay/time.Second)
+	}
+	return fmt.Sprintf("%dms", delay/time.Millisecond)
+}
+
+// SubscribeWithRetry 订阅topic 以及它的各级重试topic，handler 处理失败的消息依次转发到重试topic，
+// 到达重试时间后再次调用handler，全部重试失败后转发到死信topic(死信topic 不会被订阅).
+// 同一个重试topic 中的消息间隔相同，因此按顺序等待到期即可，等待期间关闭worker 时消息重新发回该重试topic.
+func (worker *KafkaWorker) SubscribeWithRetry(topic string, handler RetryHandler, policy RetryPolicy) error {
+	if nil == handler {
+		return fmt.Errorf("subscribe kafka topic:%s with nil retry handler", topic)
+	}
+	if err := worker.Subscribe(topic, &mqenv.MQConsumerProxy{
+		Queue:       topic,
+		ConsumerTag: topic,
+		Callback:    worker.retryCallback(topic, 0, handler, policy),
+	}); err != nil {
+		return err
+	}
+	for attempt := 1; attempt <= len(policy.delays()); attempt++ {
+		retryTopic := policy.RetryTopic(topic, attempt)
+		if err := worker.Subscribe(retryTopic, &mqenv.MQConsumerProxy{
+			Queue:       retryTopic,
+			ConsumerTag: retryTopic,
+			Callback:    worker.retryCallback(topic, attempt, handler, policy),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryCallback 返回第attempt 级(0 为原topic)的消费回调.
+func (worker *KafkaWorker) retryCallback(topic string, attempt int, handler RetryHandler, policy RetryPolicy) mqenv.MQConsumerCallback {
+	return func(message mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		if attempt > 0 && !worker.waitRetryDue(message) {
+			// worker 关闭，消息发回当前重试topic 等待下次启动后处理
+			worker.publishRetry(message.Queue, newRetryPublishMessage(message))
+			return nil
+		}
+		if err := invokeRetryHandler(handler, message); err != nil {
+			destination, pm := nextRetryMessage(topic, attempt, message, err, policy, time.Now())
+			if destination == policy.DeadLetterTopicOf(topic) {
+				logs.Error.Printf("process kafka message:%s of topic:%s failed after %d attempts with error:%v, sending to dead letter topic:%s", message.MessageID, topic, attempt+1, err, destination)
+			} else {
+				logs.Warning.Printf("process kafka message:%s of topic:%s failed with error:%v, retrying by topic:%s", message.MessageID, topic, err, destination)
+			}
+			worker.publishRetry(destination, pm)
+		}
+		return nil
+	}
+}
+
+// invokeRetryHandler 执行处理函数，panic 视为处理失败.
+func invokeRetryHandler(handler RetryHandler, message mqenv.MQConsumerMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("retry handler panic: %v", r)
+		}
+	}()
+	return handler(message)
+}
+
+// nextRetryMessage 返回第attempt 级处理失败的消息要转发的topic 和消息.
+func nextRetryMessage(topic string, attempt int, message mqenv.MQConsumerMessage, err error, policy RetryPolicy, now time.Time) (string, *mqenv.MQPublishMessage) {
+	pm := newRetryPublishMessage(message)
+	pm.Headers[HeaderRetryAttempt] = strconv.Itoa(attempt + 1)
+	pm.Headers[HeaderRetryError] = err.Error()
+	pm.Headers[HeaderOriginalTopic] = topic
+	delays := policy.delays()
+	if attempt >= len(delays) {
+		delete(pm.Headers, HeaderRetryNotBefore)
+		return policy.DeadLetterTopicOf(topic), pm
+	}
+	pm.Headers[HeaderRetryNotBefore] = strconv.FormatInt(now.Add(delays[attempt]).UnixNano()/int64(time.Millisecond), 10)
+	return policy.RetryTopic(topic, attempt+1), pm
+}
+
+// newRetryPublishMessage 按收到的消息生成重新发送的消息.
+func newRetryPublishMessage(message mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+	headers := make(map[string]string, len(message.Headers)+4)
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	return &mqenv.MQPublishMessage{
+		Body:          message.Body,
+		RoutingKey:    message.RoutingKey,
+		CorrelationID: message.CorrelationID,
+		MessageID:     message.MessageID,
+		AppID:         message.AppID,
+		UserID:        message.UserID,
+		ContentType:   message.ContentType,
+		Headers:       headers,
+	}
+}
+
+// publishRetry 发送重试或死信消息.
+func (worker *KafkaWorker) publishRetry(topic string, pm *mqenv.MQPublishMessage) {
+	if _, err := worker.Send(topic, pm, false); err != nil {
+		logs.Error.Printf("send kafka message:%s to retry topic:%s failed with error:%v", pm.MessageID, topic, err)
+	}
+}
+
+// waitRetryDue 等待到消息的重试时间，worker 关闭时返回false.
+func (worker *KafkaWorker) waitRetryDue(message mqenv.MQConsumerMessage) bool {
+	notBefore, _ := strconv.ParseInt(message.Headers[HeaderRetryNotBefore], 10, 64)
+	wait := time.Until(time.Unix(0, notBefore*int64(time.Millisecond)))
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-worker.retryStop:
+		return false
+	}
+}
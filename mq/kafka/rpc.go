@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/utils"
+)
+
+// Constants
+const (
+	DefaultRequestTimeout = 30 * time.Second // Request 未指定超时时间时的默认值
+	DefaultCloseTimeout   = 30 * time.Second // Close 等待在途消息处理完成的默认时间
+)
+
+// Errors
+var (
+	ErrRequestTimeout  = errors.NewError(errors.Timeout, "kafka request timeout")
+	ErrNoPrivateTopic  = errors.New("kafka request requires a private reply topic, fanout workers could not wait replies")
+	ErrDuplicatedRPCID = errors.New("kafka request correlation id is already waiting for reply")
+)
+
+// Request 向topic 发送payload 并在私有topic 上等待回复.
+// @title Request
+// @param topic 请求发送到的topic
+// @param payload 请求内容
+// @param timeout 等待回复的超时时间，<=0 时使用DefaultRequestTimeout
+func (worker *KafkaWorker) Request(topic string, payload []byte, timeout time.Duration) (*mqenv.MQConsumerMessage, error) {
+	publishMsg := &mqenv.MQPublishMessage{
+		Body: payload,
+	}
+	return worker.RequestMessage(topic, publishMsg, timeout)
+}
+
+// RequestMessage 发送完整的发布信息并等待回复，CorrelationID 为空时自动生成.
+func (worker *KafkaWorker) RequestMessage(topic string, publishMsg *mqenv.MQPublishMessage, timeout time.Duration) (*mqenv.MQConsumerMessage, error) {
+	if worker.PrivateTopic == "" {
+		return nil, ErrNoPrivateTopic
+	}
+	if nil != worker.getTopicSerializer(topic) {
+		return nil, ErrSchemaRequestNotSupported
+	}
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	if publishMsg.CorrelationID == "" {
+		publishMsg.CorrelationID = utils.GenLoweruuid()
+	}
+	worker.registerPrivateTopic()
+	p := worker.newPublishPacket(topic, publishMsg)
+	sendBytes, err := worker.marshalPacket(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// 注册通道，等待回复
+	ch, err := worker.registerResponseWaiter(p.CorrelationId)
+	if err != nil {
+		return nil, err
+	}
+	err = worker.sendWorker(topic, sendBytes)
+	if err != nil {
+		worker.cancelResponseWaiter(p.CorrelationId)
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case responsePacket := <-ch:
+		// 回收通道
+		worker.recycleChannel(ch)
+		consumerMessage := ConvertKafkaPacketToMQConsumerMessage(responsePacket)
+		return &consumerMessage, nil
+	case <-timer.C:
+		// 超时后通道可能还会收到迟到的回复，所以不回收
+		worker.cancelResponseWaiter(p.CorrelationId)
+		publishMsg.OnClosed()
+		return nil, fmt.Errorf("%w: topic:%s correlation id:%s after %v", ErrRequestTimeout, topic, p.CorrelationId, timeout)
+	}
+}
+
+// PendingRequests 返回正在等待回复的请求数量.
+func (worker *KafkaWorker) PendingRequests() int {
+	worker.waitResponseMutex.Lock()
+	n := len(worker.waitResponseMessage)
+	worker.waitResponseMutex.Unlock()
+	return n
+}
+
+// registerResponseWaiter 以correlationID 为key 注册等待回复的通道.
+func (worker *KafkaWorker) registerResponseWaiter(correlationID string) (chan *KafkaPacket, error) {
+	worker.waitResponseMutex.Lock()
+	defer worker.waitResponseMutex.Unlock()
+	if _, ok := worker.waitResponseMessage[correlationID]; ok {
+		return nil, ErrDuplicatedRPCID
+	}
+	ch := worker.obtainChannel()
+	worker.waitResponseMessage[correlationID] = ch
+	return ch, nil
+}
+
+// takeResponseWaiter 取出并移除correlationID 对应的等待通道.
+func (worker *KafkaWorker) takeResponseWaiter(correlationID string) (chan *KafkaPacket, bool) {
+	if correlationID == "" {
+		return nil, false
+	}
+	worker.waitResponseMutex.Lock()
+	ch, ok := worker.waitResponseMessage[correlationID]
+	if ok {
+		delete(worker.waitResponseMessage, correlationID)
+	}
+	worker.waitResponseMutex.Unlock()
+	return ch, ok
+}
+
+// cancelResponseWaiter 移除correlationID 对应的等待通道.
+func (worker *KafkaWorker) cancelResponseWaiter(correlationID string) {
+	worker.waitResponseMutex.Lock()
+	delete(worker.waitResponseMessage, correlationID)
+	worker.waitResponseMutex.Unlock()
+}
+
+// obtainChannel 获取一个通道，调用方需持有waitResponseMutex.
+func (worker *KafkaWorker) obtainChannel() chan *KafkaPacket {
+	channelLength := len(worker.availableChannels)
+	if channelLength == 0 {
+		// 带一个缓冲，回复到达时即使请求方已超时也不会阻塞消费协程
+		return make(chan *KafkaPacket, 1)
+	}
+	c := worker.availableChannels[channelLength-1]
+	// 从可用的数组从移除
+	worker.availableChannels = worker.availableChannels[:channelLength-1]
+	return c
+}
+
+// recycleChannel 回收用过的旧通道.
+func (worker *KafkaWorker) recycleChannel(c chan *KafkaPacket) {
+	worker.waitResponseMutex.Lock()
+	worker.availableChannels = append(worker.availableChannels, c)
+	worker.waitResponseMutex.Unlock()
+}
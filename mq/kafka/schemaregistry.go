@@ -0,0 +1,182 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/libpub/golib/httpclient"
+)
+
+// Constants
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+
+	schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+	schemaMagicByte           = byte(0)
+	schemaFrameHeaderSize     = 5
+)
+
+// Errors
+var (
+	ErrInvalidSchemaFrame = errors.New("kafka message is not framed by schema registry wire format")
+)
+
+// SchemaRegistryConfig Confluent Schema Registry 连接配置.
+type SchemaRegistryConfig struct {
+	URL            string `yaml:"url" json:"url"`
+	Username       string `yaml:"username" json:"username"`
+	Password       string `yaml:"password" json:"password"`
+	TimeoutSeconds int    `yaml:"timeoutSeconds" json:"timeoutSeconds"`
+}
+
+// Schema 注册中心中的schema.
+type Schema struct {
+	ID         int    `json:"id"`
+	Subject    string `json:"subject,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	SchemaType string `json:"schemaType,omitempty"`
+	Schema     string `json:"schema"`
+}
+
+// SchemaRegistryClient Confluent Schema Registry 客户端，按id 和subject+schema 在本地缓存查询结果.
+type SchemaRegistryClient struct {
+	config       SchemaRegistryConfig
+	schemasByID  map[int]*Schema
+	idsBySchema  map[string]int
+	cacheMutex   sync.RWMutex
+	httpOptions  []httpclient.ClientOption
+	registryBase string
+}
+
+// NewSchemaRegistryClient 返回注册中心客户端.
+func NewSchemaRegistryClient(config SchemaRegistryConfig) *SchemaRegistryClient {
+	c := &SchemaRegistryClient{
+		config:       config,
+		schemasByID:  map[int]*Schema{},
+		idsBySchema:  map[string]int{},
+		registryBase: strings.TrimRight(config.URL, "/"),
+	}
+	headers := map[string]string{
+		"Content-Type": schemaRegistryContentType,
+		"Accept":       schemaRegistryContentType,
+	}
+	if "" != config.Username {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(config.Username+":"+config.Password))
+	}
+	c.httpOptions = append(c.httpOptions, httpclient.WithHTTPHeaders(headers))
+	if config.TimeoutSeconds > 0 {
+		c.httpOptions = append(c.httpOptions, httpclient.WithTimeout(config.TimeoutSeconds))
+	}
+	return c
+}
+
+// GetSchemaByID 按id 查询schema.
+func (c *SchemaRegistryClient) GetSchemaByID(id int) (*Schema, error) {
+	c.cacheMutex.RLock()
+	schema, ok := c.schemasByID[id]
+	c.cacheMutex.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	resp, err := httpclient.HTTPQuery("GET", fmt.Sprintf("%s/schemas/ids/%d", c.registryBase, id), nil, c.httpOptions...)
+	if nil != err {
+		return nil, fmt.Errorf("query schema by id:%d failed with error:%w response:%s", id, err, string(resp))
+	}
+	schema = &Schema{}
+	err = json.Unmarshal(resp, schema)
+	if nil != err {
+		return nil, err
+	}
+	schema.ID = id
+	if "" == schema.SchemaType {
+		schema.SchemaType = SchemaTypeAvro
+	}
+	c.cacheMutex.Lock()
+	c.schemasByID[id] = schema
+	c.cacheMutex.Unlock()
+	return schema, nil
+}
+
+// Register 在subject 下注册schema 并返回id，schema 已存在时返回已有的id.
+func (c *SchemaRegistryClient) Register(subject string, schemaType string, schema string) (int, error) {
+	cacheKey := subject + "\n" + schemaType + "\n" + schema
+	c.cacheMutex.RLock()
+	id, ok := c.idsBySchema[cacheKey]
+	c.cacheMutex.RUnlock()
+	if ok {
+		return id, nil
+	}
+	params := map[string]interface{}{
+		"schema": schema,
+	}
+	if "" != schemaType && SchemaTypeAvro != schemaType {
+		params["schemaType"] = schemaType
+	}
+	body, err := json.Marshal(params)
+	if nil != err {
+		return 0, err
+	}
+	resp, err := httpclient.HTTPQuery("POST", fmt.Sprintf("%s/subjects/%s/versions", c.registryBase, url.PathEscape(subject)), bytes.NewReader(body), c.httpOptions...)
+	if nil != err {
+		return 0, fmt.Errorf("register schema for subject:%s failed with error:%w response:%s", subject, err, string(resp))
+	}
+	result := Schema{}
+	err = json.Unmarshal(resp, &result)
+	if nil != err {
+		return 0, err
+	}
+	c.cacheMutex.Lock()
+	c.idsBySchema[cacheKey] = result.ID
+	c.schemasByID[result.ID] = &Schema{ID: result.ID, Subject: subject, SchemaType: schemaType, Schema: schema}
+	c.cacheMutex.Unlock()
+	return result.ID, nil
+}
+
+// GetLatestSchema 查询subject 下最新版本的schema.
+func (c *SchemaRegistryClient) GetLatestSchema(subject string) (*Schema, error) {
+	resp, err := httpclient.HTTPQuery("GET", fmt.Sprintf("%s/subjects/%s/versions/latest", c.registryBase, url.PathEscape(subject)), nil, c.httpOptions...)
+	if nil != err {
+		return nil, fmt.Errorf("query latest schema of subject:%s failed with error:%w response:%s", subject, err, string(resp))
+	}
+	schema := &Schema{}
+	err = json.Unmarshal(resp, schema)
+	if nil != err {
+		return nil, err
+	}
+	if "" == schema.SchemaType {
+		schema.SchemaType = SchemaTypeAvro
+	}
+	c.cacheMutex.Lock()
+	c.schemasByID[schema.ID] = schema
+	c.cacheMutex.Unlock()
+	return schema, nil
+}
+
+// TopicValueSubject 按TopicNameStrategy 返回topic 消息体的subject.
+func TopicValueSubject(topic string) string {
+	return topic + "-value"
+}
+
+// FrameSchemaPayload 按注册中心的格式封装消息: 魔数0 + 4字节大端schema id + 内容.
+func FrameSchemaPayload(schemaID int, payload []byte) []byte {
+	data := make([]byte, schemaFrameHeaderSize, schemaFrameHeaderSize+len(payload))
+	data[0] = schemaMagicByte
+	binary.BigEndian.PutUint32(data[1:schemaFrameHeaderSize], uint32(schemaID))
+	return append(data, payload...)
+}
+
+// ParseSchemaFrame 解析注册中心格式的消息，返回schema id 和内容.
+func ParseSchemaFrame(data []byte) (int, []byte, error) {
+	if len(data) < schemaFrameHeaderSize || schemaMagicByte != data[0] {
+		return 0, nil, ErrInvalidSchemaFrame
+	}
+	return int(binary.BigEndian.Uint32(data[1:schemaFrameHeaderSize])), data[schemaFrameHeaderSize:], nil
+}
@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Constants
+const (
+	SerializerTypeAvro     = "avro"
+	SerializerTypeProtobuf = "protobuf"
+
+	ContentTypeAvroJSON = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Errors
+var (
+	ErrSchemaRequestNotSupported = errors.New("kafka request is not supported on topics with schema serializer")
+)
+
+// TopicSchema topic 使用的schema 序列化配置.
+type TopicSchema struct {
+	Type   string `yaml:"type" json:"type"`     // avro 或protobuf
+	Schema string `yaml:"schema" json:"schema"` // avro schema json 或proto 定义，为空时使用注册中心subject 下的最新版本
+}
+
+// SchemaSerializer 按注册中心的schema 序列化和反序列化消息体.
+type SchemaSerializer interface {
+	// Serialize 把发布的消息体编码为带schema id 的数据
+	Serialize(topic string, payload []byte) ([]byte, error)
+	// Deserialize 把带schema id 的数据解码为消息体
+	Deserialize(topic string, data []byte) ([]byte, error)
+	// ContentType 解码后消息体的类型
+	ContentType() string
+}
+
+// NewSchemaSerializer 按TopicSchema 的类型返回序列化器.
+func NewSchemaSerializer(registry *SchemaRegistryClient, topicSchema TopicSchema) (SchemaSerializer, error) {
+	switch strings.ToLower(topicSchema.Type) {
+	case SerializerTypeAvro:
+		return NewAvroSerializer(registry, topicSchema.Schema), nil
+	case SerializerTypeProtobuf:
+		return NewProtobufSerializer(registry, topicSchema.Schema), nil
+	default:
+		return nil, fmt.Errorf("unsupported kafka schema serializer type:%s", topicSchema.Type)
+	}
+}
+
+// writerSchema 发布消息时使用的schema id，schema 为空时使用最新版本.
+type writerSchema struct {
+	registry   *SchemaRegistryClient
+	schemaType string
+	schema     string
+	ids        map[string]int
+	m          sync.RWMutex
+}
+
+func (w *writerSchema) schemaID(topic string) (int, string, error) {
+	subject := TopicValueSubject(topic)
+	w.m.RLock()
+	id, ok := w.ids[subject]
+	w.m.RUnlock()
+	if ok {
+		schema, err := w.registry.GetSchemaByID(id)
+		if nil != err {
+			return 0, "", err
+		}
+		return id, schema.Schema, nil
+	}
+	schema := w.schema
+	if "" == schema {
+		latest, err := w.registry.GetLatestSchema(subject)
+		if nil != err {
+			return 0, "", err
+		}
+		id = latest.ID
+		schema = latest.Schema
+	} else {
+		var err error
+		id, err = w.registry.Register(subject, w.schemaType, schema)
+		if nil != err {
+			return 0, "", err
+		}
+	}
+	w.m.Lock()
+	w.ids[subject] = id
+	w.m.Unlock()
+	return id, schema, nil
+}
+
+// AvroSerializer 发布时消息体为avro json 文本，按schema 编码为avro 二进制；消费时解码回json 文本.
+type AvroSerializer struct {
+	writer writerSchema
+	codecs map[int]*goavro.Codec
+	m      sync.RWMutex
+}
+
+// NewAvroSerializer 返回avro 序列化器，schema 为空时使用注册中心subject 下的最新版本.
+func NewAvroSerializer(registry *SchemaRegistryClient, schema string) *AvroSerializer {
+	return &AvroSerializer{
+		writer: writerSchema{
+			registry:   registry,
+			schemaType: SchemaTypeAvro,
+			schema:     schema,
+			ids:        map[string]int{},
+		},
+		codecs: map[int]*goavro.Codec{},
+	}
+}
+
+// Serialize 编码avro json 文本.
+func (s *AvroSerializer) Serialize(topic string, payload []byte) ([]byte, error) {
+	id, schema, err := s.writer.schemaID(topic)
+	if nil != err {
+		return nil, err
+	}
+	codec, err := s.codec(id, schema)
+	if nil != err {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromTextual(payload)
+	if nil != err {
+		return nil, fmt.Errorf("decode avro textual payload for topic:%s failed with error:%v", topic, err)
+	}
+	binaryData, err := codec.BinaryFromNative(nil, native)
+	if nil != err {
+		return nil, err
+	}
+	return FrameSchemaPayload(id, binaryData), nil
+}
+
+// Deserialize 解码为avro json 文本.
+func (s *AvroSerializer) Deserialize(topic string, data []byte) ([]byte, error) {
+	id, payload, err := ParseSchemaFrame(data)
+	if nil != err {
+		return nil, err
+	}
+	schema, err := s.writer.registry.GetSchemaByID(id)
+	if nil != err {
+		return nil, err
+	}
+	codec, err := s.codec(id, schema.Schema)
+	if nil != err {
+		return nil, err
+	}
+	native, _, err := codec.NativeFromBinary(payload)
+	if nil != err {
+		return nil, fmt.Errorf("decode avro binary payload of topic:%s with schema id:%d failed with error:%v", topic, id, err)
+	}
+	return codec.TextualFromNative(nil, native)
+}
+
+// ContentType 返回application/json.
+func (s *AvroSerializer) ContentType() string {
+	return ContentTypeAvroJSON
+}
+
+func (s *AvroSerializer) codec(id int, schema string) (*goavro.Codec, error) {
+	s.m.RLock()
+	codec, ok := s.codecs[id]
+	s.m.RUnlock()
+	if ok {
+		return codec, nil
+	}
+	codec, err := goavro.NewCodec(schema)
+	if nil != err {
+		return nil, fmt.Errorf("parse avro schema id:%d failed with error:%v", id, err)
+	}
+	s.m.Lock()
+	s.codecs[id] = codec
+	s.m.Unlock()
+	return codec, nil
+}
+
+// ProtobufSerializer 发布时消息体为protobuf 编码后的数据，封装schema id 和消息索引；消费时去掉封装.
+// 只支持proto 定义中的第一个message.
+type ProtobufSerializer struct {
+	writer writerSchema
+}
+
+// NewProtobufSerializer 返回protobuf 序列化器，schema 为proto 定义，为空时使用注册中心subject 下的最新版本.
+func NewProtobufSerializer(registry *SchemaRegistryClient, schema string) *ProtobufSerializer {
+	return &ProtobufSerializer{
+		writer: writerSchema{
+			registry:   registry,
+			schemaType: SchemaTypeProtobuf,
+			schema:     schema,
+			ids:        map[string]int{},
+		},
+	}
+}
+
+// Serialize 封装protobuf 数据.
+func (s *ProtobufSerializer) Serialize(topic string, payload []byte) ([]byte, error) {
+	id, _, err := s.writer.schemaID(topic)
+	if nil != err {
+		return nil, err
+	}
+	// 消息索引[0] 按约定简写为一个0
+	data := make([]byte, 0, len(payload)+1)
+	data = append(data, 0)
+	data = append(data, payload...)
+	return FrameSchemaPayload(id, data), nil
+}
+
+// Deserialize 去掉schema id 和消息索引，返回protobuf 数据.
+func (s *ProtobufSerializer) Deserialize(topic string, data []byte) ([]byte, error) {
+	_, payload, err := ParseSchemaFrame(data)
+	if nil != err {
+		return nil, err
+	}
+	return skipMessageIndexes(payload)
+}
+
+// ContentType 返回application/x-protobuf.
+func (s *ProtobufSerializer) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+// skipMessageIndexes 跳过zigzag varint 编码的消息索引数组.
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 {
+		return nil, ErrInvalidSchemaFrame
+	}
+	data = data[n:]
+	for i := int64(0); i < count; i++ {
+		_, n = binary.Varint(data)
+		if n <= 0 {
+			return nil, ErrInvalidSchemaFrame
+		}
+		data = data[n:]
+	}
+	return data, nil
+}
@@ -0,0 +1,179 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	k "github.com/segmentio/kafka-go"
+)
+
+// StatsCallback 定时统计回调函数.
+type StatsCallback func(stats Stats)
+
+// Stats 汇总所有reader/writer 的统计信息并返回快照.
+// kafka-go 每次读取统计信息后会清零计数，这里累加到worker 的统计中，所以返回的是自创建以来的累计值.
+// 分区消费延迟只有调用CollectStats 后才会更新.
+func (worker *KafkaWorker) Stats() Stats {
+	worker.statsMutex.Lock()
+	defer worker.statsMutex.Unlock()
+	worker.accumulateStats()
+	return worker.snapshotStats()
+}
+
+// CollectStats 汇总统计信息，并通过管理接口计算已订阅topic 在每个分区上的消费延迟.
+func (worker *KafkaWorker) CollectStats(ctx context.Context) (Stats, error) {
+	lags := []PartitionLag{}
+	var totalLag int64
+	var lastErr error
+	admin := worker.Admin()
+	worker.Consumer.mu.Lock()
+	groupIDs := make(map[string]string, len(worker.Consumer.groupIDs))
+	for topic, groupID := range worker.Consumer.groupIDs {
+		groupIDs[topic] = groupID
+	}
+	worker.Consumer.mu.Unlock()
+	for topic, groupID := range groupIDs {
+		if groupID == "" || topic == worker.PrivateTopic {
+			continue
+		}
+		info, err := admin.DescribeConsumerGroup(ctx, groupID, topic)
+		if err != nil {
+			logs.Warning.Printf("collect kafka consumer lag for topic:%s group:%s failed with error:%v", topic, groupID, err)
+			lastErr = err
+			continue
+		}
+		lags = append(lags, info.Lags...)
+		totalLag += info.TotalLag
+	}
+	sort.Slice(lags, func(i, j int) bool {
+		if lags[i].Topic == lags[j].Topic {
+			return lags[i].Partition < lags[j].Partition
+		}
+		return lags[i].Topic < lags[j].Topic
+	})
+
+	worker.statsMutex.Lock()
+	defer worker.statsMutex.Unlock()
+	worker.accumulateStats()
+	worker.stats.Lags = lags
+	worker.stats.TotalLag = totalLag
+	return worker.snapshotStats(), lastErr
+}
+
+// StartStatsReporter 按interval 定时收集统计信息(含分区消费延迟)并回调，重复调用会替换之前的定时任务.
+func (worker *KafkaWorker) StartStatsReporter(interval time.Duration, callback StatsCallback) {
+	if interval <= 0 || callback == nil {
+		return
+	}
+	worker.StopStatsReporter()
+	stop := make(chan struct{})
+	worker.statsMutex.Lock()
+	worker.statsReporterStop = stop
+	worker.statsMutex.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				stats, _ := worker.CollectStats(ctx)
+				cancel()
+				func() {
+					defer logger.Module(LoggerModule).RecoverAndLog("kafka stats callback panic")
+					callback(stats)
+				}()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopStatsReporter 停止定时统计回调.
+func (worker *KafkaWorker) StopStatsReporter() {
+	worker.statsMutex.Lock()
+	if worker.statsReporterStop != nil {
+		close(worker.statsReporterStop)
+		worker.statsReporterStop = nil
+	}
+	worker.statsMutex.Unlock()
+}
+
+// accumulateStats 把reader/writer 的增量统计累加到worker，调用方需持有statsMutex.
+func (worker *KafkaWorker) accumulateStats() {
+	consumer := &worker.stats.Consumer
+	consumer.QueueLength = 0
+	consumer.QueueCapacity = 0
+	consumer.Lag = 0
+	consumerTopics := []string{}
+	worker.Consumer.mu.Lock()
+	readers := make(map[string][]*k.Reader, len(worker.Consumer.Readers))
+	for topic, reader := range worker.Consumer.Readers {
+		if partitionReaders, ok := worker.Consumer.partitionReaders[topic]; ok {
+			readers[topic] = append([]*k.Reader{}, partitionReaders...)
+		} else {
+			readers[topic] = []*k.Reader{reader}
+		}
+	}
+	worker.Consumer.mu.Unlock()
+	for topic, topicReaders := range readers {
+		for _, reader := range topicReaders {
+			rs := reader.Stats()
+			consumer.Bytes += rs.Bytes
+			consumer.Dials += rs.Dials
+			consumer.Messages += rs.Messages
+			consumer.Requests += rs.Fetches
+			consumer.Rebalances += rs.Rebalances
+			consumer.Errors += rs.Errors
+			consumer.Timeouts += rs.Timeouts
+			consumer.QueueLength += rs.QueueLength
+			consumer.QueueCapacity += rs.QueueCapacity
+			if rs.Lag > 0 {
+				consumer.Lag += rs.Lag
+			}
+			consumer.ClientID = rs.ClientID
+		}
+		consumerTopics = append(consumerTopics, topic)
+	}
+	sort.Strings(consumerTopics)
+	consumer.Topic = strings.Join(consumerTopics, ",")
+
+	producer := &worker.stats.Producer
+	producer.QueueLength = 0
+	producer.QueueCapacity = 0
+	producerTopics := []string{}
+	worker.Producer.mu.Lock()
+	writers := make(map[string]*k.Writer, len(worker.Producer.Writer))
+	for topic, writer := range worker.Producer.Writer {
+		writers[topic] = writer
+	}
+	worker.Producer.mu.Unlock()
+	for topic, writer := range writers {
+		ws := writer.Stats()
+		producer.Bytes += ws.Bytes
+		producer.Dials += ws.Dials
+		producer.Messages += ws.Messages
+		producer.Requests += ws.Writes
+		producer.Rebalances += ws.Rebalances
+		producer.Errors += ws.Errors
+		producer.Retries += ws.Retries
+		producer.QueueLength += ws.QueueLength
+		producer.QueueCapacity += ws.QueueCapacity
+		producer.ClientID = ws.ClientID
+		producerTopics = append(producerTopics, topic)
+	}
+	sort.Strings(producerTopics)
+	producer.Topic = strings.Join(producerTopics, ",")
+	worker.stats.Time = time.Now()
+}
+
+// snapshotStats 复制一份统计信息，调用方需持有statsMutex.
+func (worker *KafkaWorker) snapshotStats() Stats {
+	snapshot := worker.stats
+	snapshot.Lags = append([]PartitionLag{}, worker.stats.Lags...)
+	return snapshot
+}
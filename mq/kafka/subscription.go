@@ -0,0 +1,178 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	k "github.com/segmentio/kafka-go"
+)
+
+// Constants
+const (
+	DefaultTopicRefreshInterval = 30 * time.Second // 按正则订阅时查询新建topic 的间隔
+)
+
+// TopicCallBack 订阅多个topic 时的回调函数，入参是消息所属的topic 和接收到的[]byte.
+type TopicCallBack func(topic string, value []byte)
+
+// ConfigTopicRefreshInterval 配置按正则订阅时查询新建topic 的间隔，单位是毫秒.
+func (c *Consumer) ConfigTopicRefreshInterval(interval int) {
+	c.Config["topic.metadata.refresh.interval.ms"] = interval
+}
+
+// topicRefreshInterval 返回查询新建topic 的间隔.
+func (c *Consumer) topicRefreshInterval() time.Duration {
+	if v, ok := c.Config["topic.metadata.refresh.interval.ms"].(int); ok && v > 0 {
+		return time.Duration(v) * time.Millisecond
+	}
+	return DefaultTopicRefreshInterval
+}
+
+// ReceiveTopics 订阅多个topic，所有topic 的消息都由callback 处理.
+// 任一topic 已被订阅时不订阅任何topic 并返回错误.
+func (c *Consumer) ReceiveTopics(topics []string, callback TopicCallBack) error {
+	c.mu.Lock()
+	for _, topic := range topics {
+		if _, ok := c.Readers[topic]; ok {
+			c.mu.Unlock()
+			return errors.New("The topic " + topic + " is already subscribed")
+		}
+	}
+	c.mu.Unlock()
+	for _, topic := range topics {
+		if err := c.Receive(topic, topicCallBack(topic, callback)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReceivePattern 订阅名称匹配正则表达式的所有topic，所有topic 的消息都由callback 处理.
+// 每隔ConfigTopicRefreshInterval 配置的间隔查询一次元数据，订阅新建的匹配topic.
+// 以"__"开头的内部topic 和worker 的私有topic 不会被订阅.
+func (c *Consumer) ReceivePattern(pattern string, callback TopicCallBack) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	key := "pattern:" + pattern
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.mu.Lock()
+	if _, ok := c.running[key]; ok {
+		c.mu.Unlock()
+		cancel()
+		return errors.New("The pattern is already subscribed")
+	}
+	c.running[key] = true
+	c.cancels[key] = cancel
+	c.done[key] = done
+	c.mu.Unlock()
+
+	subscribed := map[string]bool{}
+	if err = c.subscribeMatchedTopics(ctx, re, subscribed, callback); err != nil {
+		logs.Error.Printf("subscribe kafka topics matching:%s failed with error:%v", pattern, err)
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.topicRefreshInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.subscribeMatchedTopics(ctx, re, subscribed, callback); err != nil {
+					logs.Error.Printf("refresh kafka topics matching:%s failed with error:%v", pattern, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// subscribeMatchedTopics 订阅匹配re 且不在subscribed 中的topic.
+func (c *Consumer) subscribeMatchedTopics(ctx context.Context, re *regexp.Regexp, subscribed map[string]bool, callback TopicCallBack) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, DefaultAdminTimeout)
+	defer cancel()
+	topics, err := c.listTopics(lookupCtx)
+	if err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if subscribed[topic] || strings.HasPrefix(topic, "__") || topic == c.privateTopic || !re.MatchString(topic) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		subscribed[topic] = true
+		if err = c.Receive(topic, topicCallBack(topic, callback)); err != nil {
+			logs.Warning.Printf("subscribe kafka topic:%s matching:%s failed with error:%v", topic, re.String(), err)
+			continue
+		}
+		logs.Info.Printf("subscribed kafka topic:%s matching:%s", topic, re.String())
+		if ctx.Err() != nil {
+			// 订阅时消费已停止
+			c.stopTopic(topic)
+		}
+	}
+	return nil
+}
+
+// listTopics 返回集群中的所有topic.
+func (c *Consumer) listTopics(ctx context.Context) ([]string, error) {
+	dialer := c.dialer()
+	if dialer == nil {
+		dialer = k.DefaultDialer
+	}
+	var lastErr error
+	for _, broker := range c.Brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		partitions, err := conn.ReadPartitions()
+		conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		names := map[string]bool{}
+		for _, partition := range partitions {
+			names[partition.Topic] = true
+		}
+		topics := make([]string, 0, len(names))
+		for topic := range names {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		return topics, nil
+	}
+	return nil, lastErr
+}
+
+// stopTopic 停止消费topic，不等待正在处理的消息.
+func (c *Consumer) stopTopic(topic string) {
+	c.mu.Lock()
+	c.running[topic] = false
+	if cancel := c.cancels[topic]; cancel != nil {
+		cancel()
+	}
+	c.mu.Unlock()
+}
+
+// topicCallBack 把TopicCallBack 转换为topic 的CallBack.
+func topicCallBack(topic string, callback TopicCallBack) CallBack {
+	return func(value []byte) {
+		callback(topic, value)
+	}
+}
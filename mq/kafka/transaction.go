@@ -0,0 +1,693 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/utils"
+	k "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+)
+
+// Constants
+const (
+	DefaultTransactionTimeout = 60 * time.Second // 事务超时，超时未提交的事务会被协调者回滚
+
+	noTransactionalProducerID = -1
+	produceAPIVersion         = 3 // 支持record batch v2 和transactional id 的最低版本
+)
+
+// Errors
+var (
+	ErrNoTransactionalID     = errors.New("kafka transactional id is not configured")
+	ErrTransactionNotBegun   = errors.New("kafka transaction is not begun")
+	ErrTransactionInProgress = errors.New("kafka transaction is already in progress")
+	ErrTransactionNeedsGroup = errors.New("kafka transactional consuming requires consumer group")
+	errMalformedProduceResp  = errors.New("malformed kafka produce response")
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli) // record batch v2 使用crc32c
+
+// TopicPartitionOffset 在事务中提交的消费偏移量，Offset 为下一条要消费的消息的偏移量.
+type TopicPartitionOffset struct {
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// TransactionalProducer 事务生产者，同一个transactional.id 同时只能有一个进行中的事务.
+// 事务中发送的消息和提交的消费偏移量在CommitTxn 后一起生效，AbortTxn 后一起丢弃，
+// 配合read_committed 的消费者可以实现consume-transform-produce 的exactly-once.
+type TransactionalProducer struct {
+	Base
+	Brokers         []string
+	TransactionalID string
+	client          *k.Client
+	balancer        k.Hash
+	producerID      int
+	producerEpoch   int
+	needsInit       bool                      // 发送失败后需要重新初始化producer id，提升epoch 以丢弃未确认的消息
+	inTxn           bool                      // 是否有进行中的事务
+	partitions      map[string][]int          // topic 的分区缓存
+	leaders         map[string]map[int]string // 每个分区leader 的地址
+	sequences       map[string]map[int]int    // 每个分区下一条消息的序列号
+	txnPartitions   map[string]map[int]bool
+	txnGroups       map[string]bool
+	conns           map[string]*leaderConn // 事务中到分区leader 的连接，事务结束时关闭
+	transport       k.RoundTripper
+	mu              sync.Mutex
+}
+
+// leaderConn 到分区leader 的连接，同一事务中的produce 请求复用，避免每个批次重新建立连接和sasl 认证.
+type leaderConn struct {
+	conn          *k.Conn
+	raw           net.Conn
+	reader        *bufio.Reader
+	correlationID int32
+}
+
+// NewTransactionalProducer 返回事务生产者，hosts 格式如"localhost:9092,localhost:9093".
+func NewTransactionalProducer(hosts string, transactionalID string) *TransactionalProducer {
+	p := &TransactionalProducer{}
+	p.Config = make(map[string]interface{})
+	p.Brokers = strings.Split(hosts, ",")
+	p.TransactionalID = transactionalID
+	p.producerID = noTransactionalProducerID
+	p.partitions = make(map[string][]int)
+	p.leaders = make(map[string]map[int]string)
+	p.sequences = make(map[string]map[int]int)
+	p.conns = make(map[string]*leaderConn)
+	return p
+}
+
+// ConfigTransport 配置与broker 通信的transport，未配置时使用kafka-go 默认的transport.
+func (p *TransactionalProducer) ConfigTransport(transport k.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transport = transport
+	p.client = nil
+}
+
+// ConfigTransactionTimeout 配置事务超时.
+func (p *TransactionalProducer) ConfigTransactionTimeout(timeout time.Duration) {
+	p.Config["transaction.timeout.ms"] = int(timeout / time.Millisecond)
+}
+
+// getClient 延迟创建kafka-go 客户端.
+func (p *TransactionalProducer) getClient() *k.Client {
+	if p.client == nil {
+		transport := p.transport
+		if nil == transport {
+			transport = &k.Transport{
+				SASL: p.saslMechanism(),
+			}
+		}
+		p.client = &k.Client{
+			Addr:      k.TCP(p.Brokers...),
+			Timeout:   DefaultAdminTimeout,
+			Transport: transport,
+		}
+	}
+	return p.client
+}
+
+// InitTransactions 向事务协调者申请producer id，同一transactional.id 的旧producer 会被隔离，
+// 它未完成的事务会被回滚. BeginTxn 在需要时会自动调用.
+func (p *TransactionalProducer) InitTransactions(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.initTransactions(ctx)
+}
+
+func (p *TransactionalProducer) initTransactions(ctx context.Context) error {
+	if p.TransactionalID == "" {
+		return ErrNoTransactionalID
+	}
+	timeoutMS, _ := p.Config["transaction.timeout.ms"].(int)
+	if timeoutMS <= 0 {
+		timeoutMS = int(DefaultTransactionTimeout / time.Millisecond)
+	}
+	resp, err := p.getClient().InitProducerID(ctx, &k.InitProducerIDRequest{
+		TransactionalID:      p.TransactionalID,
+		TransactionTimeoutMs: timeoutMS,
+	})
+	if err == nil {
+		err = resp.Error
+	}
+	if err != nil {
+		return fmt.Errorf("init kafka transactional producer:%s failed with error:%v", p.TransactionalID, err)
+	}
+	p.producerID = resp.Producer.ProducerID
+	p.producerEpoch = resp.Producer.ProducerEpoch
+	p.sequences = make(map[string]map[int]int)
+	p.needsInit = false
+	p.inTxn = false
+	p.closeConns()
+	logs.Info.Printf("kafka transactional producer:%s initialized with producer id:%d epoch:%d", p.TransactionalID, p.producerID, p.producerEpoch)
+	return nil
+}
+
+// InTransaction 返回是否有进行中的事务.
+func (p *TransactionalProducer) InTransaction() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inTxn
+}
+
+// BeginTxn 开启事务.
+func (p *TransactionalProducer) BeginTxn(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inTxn {
+		return ErrTransactionInProgress
+	}
+	if p.producerID == noTransactionalProducerID || p.needsInit {
+		if err := p.initTransactions(ctx); err != nil {
+			return err
+		}
+	}
+	p.inTxn = true
+	p.txnPartitions = make(map[string]map[int]bool)
+	p.txnGroups = make(map[string]bool)
+	return nil
+}
+
+// Send 在当前事务中发送一条消息，key 为空时轮询分区，否则按key 的hash 选择分区.
+func (p *TransactionalProducer) Send(ctx context.Context, topic string, key []byte, value []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.inTxn {
+		return ErrTransactionNotBegun
+	}
+	partitions, err := p.topicPartitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+	partition := p.balancer.Balance(k.Message{Key: key}, partitions...)
+	if err = p.addPartitionToTxn(ctx, topic, partition); err != nil {
+		return err
+	}
+	if nil == p.sequences[topic] {
+		p.sequences[topic] = make(map[int]int)
+	}
+	sequence := p.sequences[topic][partition]
+	records := encodeTransactionalBatch(p.producerID, p.producerEpoch, sequence, key, value, time.Now())
+	if err = p.produce(ctx, p.leaders[topic][partition], topic, partition, records); err != nil {
+		// 不确定消息是否已写入，序列号已不可靠，下一个事务开始前重新初始化
+		p.needsInit = true
+		// leader 可能已切换，重新查询元数据
+		delete(p.partitions, topic)
+		delete(p.leaders, topic)
+		return fmt.Errorf("send kafka transactional message to topic:%s partition:%d failed with error:%v", topic, partition, err)
+	}
+	p.sequences[topic][partition] = sequence + 1
+	return nil
+}
+
+// topicPartitions 返回topic 的分区列表，首次使用时从元数据查询并缓存.
+func (p *TransactionalProducer) topicPartitions(ctx context.Context, topic string) ([]int, error) {
+	if partitions, ok := p.partitions[topic]; ok {
+		return partitions, nil
+	}
+	resp, err := p.getClient().Metadata(ctx, &k.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Topics) == 0 {
+		return nil, fmt.Errorf("kafka topic:%s not found", topic)
+	}
+	if resp.Topics[0].Error != nil {
+		return nil, resp.Topics[0].Error
+	}
+	partitions := make([]int, 0, len(resp.Topics[0].Partitions))
+	leaders := make(map[int]string, len(resp.Topics[0].Partitions))
+	for _, partition := range resp.Topics[0].Partitions {
+		partitions = append(partitions, partition.ID)
+		leaders[partition.ID] = net.JoinHostPort(partition.Leader.Host, strconv.Itoa(partition.Leader.Port))
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("kafka topic:%s has no partitions", topic)
+	}
+	p.partitions[topic] = partitions
+	p.leaders[topic] = leaders
+	return partitions, nil
+}
+
+// produce 把事务消息批次发送到分区leader.
+// kafka-go 编码的消息批次producer id 和序列号固定为-1，不能用于事务，因此自行编码produce 请求，
+// 连接仍由kafka-go 的Dialer 建立以复用sasl 认证.
+func (p *TransactionalProducer) produce(ctx context.Context, leader string, topic string, partition int, records []byte) error {
+	lc, err := p.leaderConn(ctx, leader)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(DefaultAdminTimeout)
+	}
+	lc.raw.SetDeadline(deadline)
+	lc.correlationID++
+	request := encodeProduceRequest(lc.correlationID, p.TransactionalID, topic, partition, DefaultAdminTimeout, records)
+	if _, err = lc.raw.Write(request); err == nil {
+		err = readProduceResponse(lc.reader, lc.correlationID)
+	}
+	if err != nil {
+		// 连接上可能残留未读完的响应，不再复用
+		p.closeConn(leader)
+	}
+	return err
+}
+
+// leaderConn 返回到leader 的连接，事务中首次使用时建立.
+func (p *TransactionalProducer) leaderConn(ctx context.Context, leader string) (*leaderConn, error) {
+	if lc, ok := p.conns[leader]; ok {
+		return lc, nil
+	}
+	var raw net.Conn
+	dialer := &k.Dialer{
+		Timeout:       DefaultAdminTimeout,
+		SASLMechanism: p.saslMechanism(),
+		DialFunc: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+			raw = conn
+			return conn, err
+		},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", leader)
+	if err != nil {
+		return nil, err
+	}
+	lc := &leaderConn{conn: conn, raw: raw, reader: bufio.NewReader(raw)}
+	p.conns[leader] = lc
+	return lc, nil
+}
+
+// closeConn 关闭到leader 的连接.
+func (p *TransactionalProducer) closeConn(leader string) {
+	if lc, ok := p.conns[leader]; ok {
+		lc.conn.Close()
+		delete(p.conns, leader)
+	}
+}
+
+// closeConns 关闭事务中建立的所有连接.
+func (p *TransactionalProducer) closeConns() {
+	for leader := range p.conns {
+		p.closeConn(leader)
+	}
+}
+
+// addPartitionToTxn 把分区加入当前事务，已加入的分区直接返回.
+func (p *TransactionalProducer) addPartitionToTxn(ctx context.Context, topic string, partition int) error {
+	if p.txnPartitions[topic][partition] {
+		return nil
+	}
+	resp, err := p.getClient().AddPartitionsToTxn(ctx, &k.AddPartitionsToTxnRequest{
+		TransactionalID: p.TransactionalID,
+		ProducerID:      p.producerID,
+		ProducerEpoch:   p.producerEpoch,
+		Topics: map[string][]k.AddPartitionToTxn{
+			topic: {{Partition: partition}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("add kafka topic:%s partition:%d to transaction failed with error:%v", topic, partition, err)
+	}
+	for _, result := range resp.Topics[topic] {
+		if result.Error != nil {
+			return fmt.Errorf("add kafka topic:%s partition:%d to transaction failed with error:%v", topic, partition, result.Error)
+		}
+	}
+	if nil == p.txnPartitions[topic] {
+		p.txnPartitions[topic] = make(map[int]bool)
+	}
+	p.txnPartitions[topic][partition] = true
+	return nil
+}
+
+// SendOffsetsToTxn 在当前事务中提交消费者组的消费偏移量，事务提交后偏移量才生效.
+func (p *TransactionalProducer) SendOffsetsToTxn(ctx context.Context, groupID string, offsets []TopicPartitionOffset) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.inTxn {
+		return ErrTransactionNotBegun
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+	client := p.getClient()
+	if !p.txnGroups[groupID] {
+		resp, err := client.AddOffsetsToTxn(ctx, &k.AddOffsetsToTxnRequest{
+			TransactionalID: p.TransactionalID,
+			ProducerID:      p.producerID,
+			ProducerEpoch:   p.producerEpoch,
+			GroupID:         groupID,
+		})
+		if err == nil {
+			err = resp.Error
+		}
+		if err != nil {
+			return fmt.Errorf("add kafka consumer group:%s offsets to transaction failed with error:%v", groupID, err)
+		}
+		p.txnGroups[groupID] = true
+	}
+	topics := make(map[string][]k.TxnOffsetCommit)
+	for _, offset := range offsets {
+		topics[offset.Topic] = append(topics[offset.Topic], k.TxnOffsetCommit{
+			Partition: offset.Partition,
+			Offset:    offset.Offset,
+		})
+	}
+	resp, err := client.TxnOffsetCommit(ctx, &k.TxnOffsetCommitRequest{
+		TransactionalID: p.TransactionalID,
+		GroupID:         groupID,
+		ProducerID:      p.producerID,
+		ProducerEpoch:   p.producerEpoch,
+		GenerationID:    -1,
+		Topics:          topics,
+	})
+	if err != nil {
+		return fmt.Errorf("commit kafka consumer group:%s offsets in transaction failed with error:%v", groupID, err)
+	}
+	for topic, partitions := range resp.Topics {
+		for _, result := range partitions {
+			if result.Error != nil {
+				return fmt.Errorf("commit kafka consumer group:%s topic:%s partition:%d offset in transaction failed with error:%v", groupID, topic, result.Partition, result.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// CommitTxn 提交当前事务.
+func (p *TransactionalProducer) CommitTxn(ctx context.Context) error {
+	return p.endTxn(ctx, true)
+}
+
+// AbortTxn 回滚当前事务，事务中发送的消息对read_committed 的消费者不可见.
+func (p *TransactionalProducer) AbortTxn(ctx context.Context) error {
+	return p.endTxn(ctx, false)
+}
+
+func (p *TransactionalProducer) endTxn(ctx context.Context, committed bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.inTxn {
+		return ErrTransactionNotBegun
+	}
+	p.inTxn = false
+	p.closeConns()
+	if len(p.txnPartitions) == 0 && len(p.txnGroups) == 0 {
+		// 事务中没有任何操作时协调者没有事务状态，不需要发送EndTxn
+		return nil
+	}
+	resp, err := p.getClient().EndTxn(ctx, &k.EndTxnRequest{
+		TransactionalID: p.TransactionalID,
+		ProducerID:      p.producerID,
+		ProducerEpoch:   p.producerEpoch,
+		Committed:       committed,
+	})
+	if err == nil {
+		err = resp.Error
+	}
+	if err != nil {
+		// 事务状态未知，重新初始化时协调者会回滚未完成的事务
+		p.needsInit = true
+		return fmt.Errorf("end kafka transaction:%s with commit:%v failed with error:%v", p.TransactionalID, committed, err)
+	}
+	return nil
+}
+
+// Close 回滚进行中的事务.
+func (p *TransactionalProducer) Close() error {
+	if !p.InTransaction() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer cancel()
+	return p.AbortTxn(ctx)
+}
+
+// encodeTransactionalBatch 按v2 格式编码只包含一条消息的事务消息批次，返回值包含4字节长度前缀.
+func encodeTransactionalBatch(producerID int, producerEpoch int, sequence int, key []byte, value []byte, t time.Time) []byte {
+	record := make([]byte, 0, len(key)+len(value)+16)
+	record = append(record, 0)              // attributes
+	record = binary.AppendVarint(record, 0) // timestamp delta
+	record = binary.AppendVarint(record, 0) // offset delta
+	record = appendVarBytes(record, key)    // key
+	record = appendVarBytes(record, value)  // value
+	record = binary.AppendVarint(record, 0) // headers
+	timestamp := t.UnixNano() / int64(time.Millisecond)
+
+	// crc 覆盖attributes 到批次结尾
+	body := make([]byte, 0, 40+len(record)+binary.MaxVarintLen64)
+	body = binary.BigEndian.AppendUint16(body, uint16(protocol.Transactional))
+	body = binary.BigEndian.AppendUint32(body, 0) // last offset delta
+	body = binary.BigEndian.AppendUint64(body, uint64(timestamp))
+	body = binary.BigEndian.AppendUint64(body, uint64(timestamp))
+	body = binary.BigEndian.AppendUint64(body, uint64(int64(producerID)))
+	body = binary.BigEndian.AppendUint16(body, uint16(int16(producerEpoch)))
+	body = binary.BigEndian.AppendUint32(body, uint32(int32(sequence)))
+	body = binary.BigEndian.AppendUint32(body, 1) // record count
+	body = binary.AppendVarint(body, int64(len(record)))
+	body = append(body, record...)
+
+	batchLength := 4 + 1 + 4 + len(body) // partition leader epoch + magic + crc + body
+	batch := make([]byte, 0, 4+8+4+batchLength)
+	batch = binary.BigEndian.AppendUint32(batch, uint32(8+4+batchLength))
+	batch = binary.BigEndian.AppendUint64(batch, 0) // base offset
+	batch = binary.BigEndian.AppendUint32(batch, uint32(batchLength))
+	batch = binary.BigEndian.AppendUint32(batch, 0xffffffff) // partition leader epoch
+	batch = append(batch, 2)                                 // magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(body, crc32cTable))
+	return append(batch, body...)
+}
+
+// encodeProduceRequest 编码只包含一个分区的produce v3 请求，records 为带长度前缀的消息批次.
+func encodeProduceRequest(correlationID int32, transactionalID string, topic string, partition int, timeout time.Duration, records []byte) []byte {
+	body := make([]byte, 0, 32+len(k.DefaultClientID)+len(transactionalID)+len(topic)+len(records))
+	body = binary.BigEndian.AppendUint16(body, uint16(protocol.Produce))
+	body = binary.BigEndian.AppendUint16(body, produceAPIVersion)
+	body = binary.BigEndian.AppendUint32(body, uint32(correlationID))
+	body = appendString(body, k.DefaultClientID)
+	body = appendString(body, transactionalID)
+	body = binary.BigEndian.AppendUint16(body, 0xffff) // acks=-1，等待所有同步副本确认
+	body = binary.BigEndian.AppendUint32(body, uint32(timeout/time.Millisecond))
+	body = binary.BigEndian.AppendUint32(body, 1) // topic 数量
+	body = appendString(body, topic)
+	body = binary.BigEndian.AppendUint32(body, 1) // 分区数量
+	body = binary.BigEndian.AppendUint32(body, uint32(partition))
+	body = append(body, records...)
+
+	request := make([]byte, 0, 4+len(body))
+	request = binary.BigEndian.AppendUint32(request, uint32(len(body)))
+	return append(request, body...)
+}
+
+// readProduceResponse 读取produce v3 响应，返回分区的写入错误.
+func readProduceResponse(r io.Reader, correlationID int32) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	// correlation id(4) topic 数量(4) topic 名称(2+n) 分区数量(4) 分区(4) 错误码(2)
+	if len(data) < 10 || int32(binary.BigEndian.Uint32(data)) != correlationID || binary.BigEndian.Uint32(data[4:]) != 1 {
+		return errMalformedProduceResp
+	}
+	offset := 10 + int(int16(binary.BigEndian.Uint16(data[8:])))
+	if offset < 10 || len(data) < offset+10 || binary.BigEndian.Uint32(data[offset:]) != 1 {
+		return errMalformedProduceResp
+	}
+	if code := int16(binary.BigEndian.Uint16(data[offset+8:])); code != 0 {
+		return k.Error(code)
+	}
+	return nil
+}
+
+// appendString 追加int16 长度前缀的字符串，空字符串编码为null.
+func appendString(data []byte, value string) []byte {
+	if value == "" {
+		return binary.BigEndian.AppendUint16(data, 0xffff)
+	}
+	data = binary.BigEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, value...)
+}
+
+// appendVarBytes 追加varint 长度前缀的字节数组，nil 编码为-1.
+func appendVarBytes(data []byte, value []byte) []byte {
+	if value == nil {
+		return binary.AppendVarint(data, -1)
+	}
+	data = binary.AppendVarint(data, int64(len(value)))
+	return append(data, value...)
+}
+
+// TransformHandler consume-transform-produce 的处理函数，通过txn.Send 发送的消息会加入当前事务.
+type TransformHandler func(txn *KafkaTransaction, message mqenv.MQConsumerMessage) error
+
+// KafkaTransaction 由worker.BeginTxn 开启的事务，只有通过它发送的消息和提交的偏移量属于这个事务，
+// worker.Send 等非事务发送不受影响. Commit 或Abort 后不能再使用.
+type KafkaTransaction struct {
+	worker *KafkaWorker
+	done   bool
+	mu     sync.Mutex
+}
+
+// BeginTxn 开启事务，返回事务句柄.
+// 同一个worker 同时只能有一个进行中的事务，因此事务中的消息处理需要串行.
+func (worker *KafkaWorker) BeginTxn() (*KafkaTransaction, error) {
+	if nil == worker.Transaction {
+		return nil, ErrNoTransactionalID
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer cancel()
+	if err := worker.Transaction.BeginTxn(ctx); err != nil {
+		return nil, err
+	}
+	return &KafkaTransaction{worker: worker}, nil
+}
+
+// Send 在事务中发送消息，消息按topic 配置的schema 或KafkaPacket 序列化.
+func (txn *KafkaTransaction) Send(topic string, publishMsg *mqenv.MQPublishMessage) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.done {
+		return ErrTransactionNotBegun
+	}
+	worker := txn.worker
+	var sendBytes []byte
+	var err error
+	if serializer := worker.getTopicSerializer(topic); nil != serializer {
+		sendBytes, err = serializer.Serialize(topic, publishMsg.Body)
+	} else {
+		sendBytes, err = worker.marshalPacket(worker.newPublishPacket(topic, publishMsg))
+	}
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer cancel()
+	return worker.Transaction.Send(ctx, topic, nil, sendBytes)
+}
+
+// SendOffsets 在事务中提交消费者组的消费偏移量.
+func (txn *KafkaTransaction) SendOffsets(groupID string, offsets []TopicPartitionOffset) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.done {
+		return ErrTransactionNotBegun
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer cancel()
+	return txn.worker.Transaction.SendOffsetsToTxn(ctx, groupID, offsets)
+}
+
+// Commit 提交事务.
+func (txn *KafkaTransaction) Commit() error {
+	return txn.end(true)
+}
+
+// Abort 回滚事务.
+func (txn *KafkaTransaction) Abort() error {
+	return txn.end(false)
+}
+
+func (txn *KafkaTransaction) end(committed bool) error {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	if txn.done {
+		return ErrTransactionNotBegun
+	}
+	txn.done = true
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAdminTimeout)
+	defer cancel()
+	if committed {
+		return txn.worker.Transaction.CommitTxn(ctx)
+	}
+	return txn.worker.Transaction.AbortTxn(ctx)
+}
+
+// Done 返回事务是否已经提交或回滚.
+func (txn *KafkaTransaction) Done() bool {
+	txn.mu.Lock()
+	defer txn.mu.Unlock()
+	return txn.done
+}
+
+// SubscribeTransactional 订阅topic 并在事务中逐条处理消息(consume-transform-produce)：
+// 每条消息开启一个事务，handler 中通过txn.Send 发送的消息和这条消息的消费偏移量一起提交，实现exactly-once.
+// handler 返回错误或提交失败时回滚事务，并从最后提交的偏移量重新消费.
+func (worker *KafkaWorker) SubscribeTransactional(topic string, handler TransformHandler) error {
+	if nil == worker.Transaction {
+		return ErrNoTransactionalID
+	}
+	return worker.Consumer.ReceiveTransactional(topic, func(groupID string, m k.Message) (err error) {
+		txn, err := worker.BeginTxn()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("transform handler panic: %v", r)
+			}
+			if err != nil && !txn.Done() {
+				if abortErr := txn.Abort(); abortErr != nil {
+					logs.Error.Printf("abort kafka transaction failed with error:%v", abortErr)
+				}
+			}
+		}()
+		if message, ok := worker.decodeTransactionalMessage(topic, m.Value); ok {
+			if err = handler(txn, message); err != nil {
+				return err
+			}
+		}
+		err = txn.SendOffsets(groupID, []TopicPartitionOffset{{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset + 1}})
+		if err != nil {
+			return err
+		}
+		return txn.Commit()
+	})
+}
+
+// decodeTransactionalMessage 解码事务消费的消息，无法解码的消息只提交偏移量.
+func (worker *KafkaWorker) decodeTransactionalMessage(topic string, data []byte) (mqenv.MQConsumerMessage, bool) {
+	if serializer := worker.getTopicSerializer(topic); nil != serializer {
+		body, err := serializer.Deserialize(topic, data)
+		if err != nil {
+			logs.Error.Printf("deserialize kafka message of topic:%s failed with error:%v", topic, err)
+			return mqenv.MQConsumerMessage{}, false
+		}
+		return ConvertKafkaPacketToMQConsumerMessage(&KafkaPacket{
+			ContentType: serializer.ContentType(),
+			SendTo:      topic,
+			Timestamp:   uint64(utils.CurrentMillisecond()),
+			Body:        body,
+			Exchange:    topic,
+		}), true
+	}
+	if strings.Contains(string(data), "_register_private") {
+		return mqenv.MQConsumerMessage{}, false
+	}
+	packet, err := worker.unmarshalPacket(data)
+	if err != nil {
+		logs.Error.Printf("unmarshal kafka message of topic:%s failed with error:%v", topic, err)
+		return mqenv.MQConsumerMessage{}, false
+	}
+	worker.extractRoutingKey(packet)
+	return ConvertKafkaPacketToMQConsumerMessage(packet), true
+}
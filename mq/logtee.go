@@ -0,0 +1,102 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// Constants of log tee
+const (
+	LogTeeTypeMQ = "mq"
+
+	// LogTeeSuspendInterval the log tee drops the entries for the interval after publishing failed,
+	// so that the errors logged by the failed publishing are not published again and again
+	LogTeeSuspendInterval = 10 * time.Second
+)
+
+func init() {
+	logger.RegisterTeeFactory(LogTeeTypeMQ, func(config logger.TeeConfig) (logger.Backend, error) {
+		return NewLogTee(config.Address), nil
+	})
+}
+
+// logTeeEntry the json message of log entry
+type logTeeEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Module  string                 `json:"module,omitempty"`
+	Caller  string                 `json:"caller,omitempty"`
+	Message string                 `json:"msg"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	Stack   string                 `json:"stack,omitempty"`
+}
+
+// logTee publishes the log entries to the configured topic of mq category
+type logTee struct {
+	category       string
+	suspendedUntil time.Time
+	m              sync.Mutex
+}
+
+// NewLogTee the asynchronous logger backend publishing the entries as json messages by Publish of mq category,
+// it could be added by logger.AddTee or configured in the tees of logger as type mq with the category as address.
+// The entries below WARN should not be teed to the drivers logging the publishing at INFO or DEBUG level
+func NewLogTee(mqCategory string) *logger.AsyncBackend {
+	return logger.NewAsyncBackend(&logTee{category: mqCategory}, 0)
+}
+
+// Log publishes the entry
+func (t *logTee) Log(entry logger.Entry) {
+	now := time.Now()
+	t.m.Lock()
+	suspended := now.Before(t.suspendedUntil)
+	t.m.Unlock()
+	if suspended {
+		return
+	}
+	msg := logTeeEntry{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Module:  entry.Module,
+		Caller:  entry.Caller(),
+		Message: entry.Message,
+		Stack:   entry.Stack,
+	}
+	if len(entry.Fields) > 0 {
+		msg.Fields = make(map[string]interface{}, len(entry.Fields)/2)
+		for i := 0; i < len(entry.Fields); i += 2 {
+			key := fmt.Sprint(entry.Fields[i])
+			var value interface{}
+			if i+1 < len(entry.Fields) {
+				value = entry.Fields[i+1]
+			}
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			msg.Fields[key] = value
+		}
+	}
+	body, err := json.Marshal(msg)
+	if nil != err {
+		// the fields could not be marshaled such as channels are formatted as text
+		for key, value := range msg.Fields {
+			msg.Fields[key] = fmt.Sprint(value)
+		}
+		body, err = json.Marshal(msg)
+	}
+	if nil == err {
+		err = Publish(t.category, mqenv.MQPublishMessage{Body: body, ContentType: mqenv.ContentTypeJSON})
+	}
+	if nil != err {
+		t.m.Lock()
+		t.suspendedUntil = time.Now().Add(LogTeeSuspendInterval)
+		t.m.Unlock()
+		fmt.Fprintf(os.Stderr, "publish log entry to MQ category:%s failed with error:%v, suspended for %v\n", t.category, err, LogTeeSuspendInterval)
+	}
+}
@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"sync"
+
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// PublishHandler publishes the message
+type PublishHandler func(pm *mqenv.MQPublishMessage) error
+
+// PublishInterceptor intercepts the message before publishing, such as injecting tracing headers or compressing the body,
+// it should call next to continue publishing or return an error to abort
+type PublishInterceptor func(mqCategory string, pm *mqenv.MQPublishMessage, next PublishHandler) error
+
+// ConsumeInterceptor intercepts the consumed message before the consumer callback, such as extracting tracing context,
+// validating or decompressing the body, it should call next to continue consuming or return nil to drop the message
+type ConsumeInterceptor func(mqCategory string, msg mqenv.MQConsumerMessage, next mqenv.MQConsumerCallback) *mqenv.MQPublishMessage
+
+// interceptorChain interceptors registered on a connection instance
+type interceptorChain struct {
+	publishes []PublishInterceptor
+	consumes  []ConsumeInterceptor
+}
+
+var (
+	mqInterceptors      = map[string]*interceptorChain{}
+	mqInterceptorsMutex = sync.RWMutex{}
+)
+
+// UsePublishInterceptors registers publish interceptors on connection instance,
+// they are applied to messages published or queried by all categories of the instance in registration order
+func UsePublishInterceptors(instanceName string, interceptors ...PublishInterceptor) {
+	mqInterceptorsMutex.Lock()
+	chain := ensureInterceptorChain(instanceName)
+	for _, interceptor := range interceptors {
+		if nil != interceptor {
+			chain.publishes = append(chain.publishes, interceptor)
+		}
+	}
+	mqInterceptorsMutex.Unlock()
+}
+
+// UseConsumeInterceptors registers consume interceptors on connection instance,
+// they are applied to messages consumed by all categories of the instance in registration order,
+// consumers subscribed before registration are not affected
+func UseConsumeInterceptors(instanceName string, interceptors ...ConsumeInterceptor) {
+	mqInterceptorsMutex.Lock()
+	chain := ensureInterceptorChain(instanceName)
+	for _, interceptor := range interceptors {
+		if nil != interceptor {
+			chain.consumes = append(chain.consumes, interceptor)
+		}
+	}
+	mqInterceptorsMutex.Unlock()
+}
+
+// ClearInterceptors removes all interceptors of connection instance
+func ClearInterceptors(instanceName string) {
+	mqInterceptorsMutex.Lock()
+	delete(mqInterceptors, instanceName)
+	mqInterceptorsMutex.Unlock()
+}
+
+// ensureInterceptorChain caller should hold the lock
+func ensureInterceptorChain(instanceName string) *interceptorChain {
+	chain, ok := mqInterceptors[instanceName]
+	if !ok {
+		chain = &interceptorChain{}
+		mqInterceptors[instanceName] = chain
+	}
+	return chain
+}
+
+// getInterceptorChain returns a copy of interceptors for the instance of mq config
+func getInterceptorChain(mqConfig *Config) interceptorChain {
+	result := interceptorChain{}
+	if nil == mqConfig {
+		return result
+	}
+	mqInterceptorsMutex.RLock()
+	chain, ok := mqInterceptors[mqConfig.Instance]
+	if ok {
+		result.publishes = append(result.publishes, chain.publishes...)
+		result.consumes = append(result.consumes, chain.consumes...)
+	}
+	mqInterceptorsMutex.RUnlock()
+	return result
+}
+
+// interceptPublish runs the publish interceptors and finally the handler
+func interceptPublish(mqCategory string, mqConfig *Config, pm *mqenv.MQPublishMessage, handler PublishHandler) error {
+	interceptors := getInterceptorChain(mqConfig).publishes
+	if len(interceptors) == 0 {
+		return handler(pm)
+	}
+	var next func(i int) PublishHandler
+	next = func(i int) PublishHandler {
+		if i >= len(interceptors) {
+			return handler
+		}
+		return func(m *mqenv.MQPublishMessage) error {
+			return interceptors[i](mqCategory, m, next(i+1))
+		}
+	}
+	return next(0)(pm)
+}
+
+// interceptConsumeProxy returns a copy of consumeProxy whose callback runs the consume interceptors first
+func interceptConsumeProxy(mqCategory string, mqConfig *Config, consumeProxy *mqenv.MQConsumerProxy) *mqenv.MQConsumerProxy {
+	interceptors := getInterceptorChain(mqConfig).consumes
+	if len(interceptors) == 0 || nil == consumeProxy || nil == consumeProxy.Callback {
+		return consumeProxy
+	}
+	callback := consumeProxy.Callback
+	var next func(i int) mqenv.MQConsumerCallback
+	next = func(i int) mqenv.MQConsumerCallback {
+		if i >= len(interceptors) {
+			return callback
+		}
+		return func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			return interceptors[i](mqCategory, msg, next(i+1))
+		}
+	}
+	pxy := *consumeProxy
+	pxy.Callback = next(0)
+	return &pxy
+}
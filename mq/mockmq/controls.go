@@ -0,0 +1,124 @@
+package mockmq
+
+import (
+	"sync"
+
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// mockControls 测试用的投递控制及故障注入
+type mockControls struct {
+	m               sync.Mutex
+	held            bool
+	pending         []mockPendingMessage
+	published       []mqenv.MQPublishMessage
+	publishErr      error
+	publishErrTimes int
+	dropTimes       int
+}
+
+type mockPendingMessage struct {
+	topic string
+	msg   mqenv.MQPublishMessage
+}
+
+// FailPublish 之后times次发布返回err, times小于1时一直失败直到Reset
+func (worker *MockMQ) FailPublish(err error, times int) {
+	worker.controls.m.Lock()
+	worker.controls.publishErr = err
+	worker.controls.publishErrTimes = times
+	worker.controls.m.Unlock()
+}
+
+// DropDeliveries 之后times次发布成功但消息不投递, 模拟消息丢失
+func (worker *MockMQ) DropDeliveries(times int) {
+	worker.controls.m.Lock()
+	worker.controls.dropTimes = times
+	worker.controls.m.Unlock()
+}
+
+// HoldDeliveries 暂停投递, 之后发布的消息在Deliver或ReleaseDeliveries时才投递
+func (worker *MockMQ) HoldDeliveries() {
+	worker.controls.m.Lock()
+	worker.controls.held = true
+	worker.controls.m.Unlock()
+}
+
+// Deliver 按发布顺序投递n条暂停的消息, n小于1时全部投递, 返回投递的条数
+func (worker *MockMQ) Deliver(n int) int {
+	worker.controls.m.Lock()
+	if n < 1 || n > len(worker.controls.pending) {
+		n = len(worker.controls.pending)
+	}
+	delivering := worker.controls.pending[:n]
+	worker.controls.pending = append([]mockPendingMessage{}, worker.controls.pending[n:]...)
+	worker.controls.m.Unlock()
+	for _, p := range delivering {
+		mockMQ.publish(p.topic, p.msg)
+	}
+	return len(delivering)
+}
+
+// ReleaseDeliveries 投递全部暂停的消息并恢复即时投递
+func (worker *MockMQ) ReleaseDeliveries() int {
+	worker.controls.m.Lock()
+	worker.controls.held = false
+	worker.controls.m.Unlock()
+	return worker.Deliver(0)
+}
+
+// Pending 暂停中未投递的消息数
+func (worker *MockMQ) Pending() int {
+	worker.controls.m.Lock()
+	defer worker.controls.m.Unlock()
+	return len(worker.controls.pending)
+}
+
+// Published 已发布成功的消息, 包括暂停及丢弃的消息
+func (worker *MockMQ) Published() []mqenv.MQPublishMessage {
+	worker.controls.m.Lock()
+	defer worker.controls.m.Unlock()
+	return append([]mqenv.MQPublishMessage{}, worker.controls.published...)
+}
+
+// Reset 清除故障注入, 丢弃暂停的消息及发布记录并恢复即时投递
+func (worker *MockMQ) Reset() {
+	worker.controls.m.Lock()
+	worker.controls.held = false
+	worker.controls.pending = nil
+	worker.controls.published = nil
+	worker.controls.publishErr = nil
+	worker.controls.publishErrTimes = 0
+	worker.controls.dropTimes = 0
+	worker.controls.m.Unlock()
+}
+
+// publish 按注入的故障及投递控制发布消息
+func (c *mockControls) publish(topic string, pm *mqenv.MQPublishMessage) error {
+	c.m.Lock()
+	if nil != c.publishErr {
+		err := c.publishErr
+		if c.publishErrTimes > 0 {
+			c.publishErrTimes--
+			if 0 == c.publishErrTimes {
+				c.publishErr = nil
+			}
+		}
+		c.m.Unlock()
+		return err
+	}
+	c.published = append(c.published, *pm)
+	if c.dropTimes > 0 {
+		c.dropTimes--
+		c.m.Unlock()
+		return nil
+	}
+	if c.held {
+		c.pending = append(c.pending, mockPendingMessage{topic: topic, msg: *pm})
+		c.m.Unlock()
+		return nil
+	}
+	c.m.Unlock()
+	mockMQ.publish(topic, *pm)
+	return nil
+}
@@ -1,8 +1,11 @@
 package mockmq
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,6 +20,7 @@ type Config struct {
 	Partition    int
 	PrivateTopic string
 	GroupID      string
+	Topic        string // 发布信息默认使用的topic
 }
 
 type mockQueue struct {
@@ -36,14 +40,16 @@ var mockMQ = mockQueueManager{
 	m:           sync.RWMutex{},
 }
 
-// MockMQ for test when programing
+// MockMQ for test when programing, deliveries could be held and failures injected by the controls
 type MockMQ struct {
 	Name                    string
 	consumerRegisters       map[string]*mqenv.MQConsumerProxy // 处理函数字典
 	waitingResponseMessages map[string]chan mqenv.MQConsumerMessage
 	waitingResponseTopic    string
+	topic                   string
 	m1                      sync.RWMutex
 	m2                      sync.RWMutex
+	controls                mockControls
 }
 
 // InitMockMQ init
@@ -51,9 +57,14 @@ func InitMockMQ(mqConnName string, connCfg *mqenv.MQConnectorConfig, mqCfg *Conf
 	var err error
 	instance, ok := mockInstances[mqConnName]
 	if false == ok {
+		topic := ""
+		if nil != mqCfg {
+			topic = mqCfg.Topic
+		}
 		instance = &MockMQ{
 			Name:              mqConnName,
 			consumerRegisters: map[string]*mqenv.MQConsumerProxy{},
+			topic:             topic,
 			m1:                sync.RWMutex{},
 			m2:                sync.RWMutex{},
 		}
@@ -90,7 +101,14 @@ func (worker *MockMQ) Send(topic string, pm *mqenv.MQPublishMessage, withReply b
 	if withReply {
 		waiter = worker.prepareRepliablePubilshMessage(pm)
 	}
-	mockMQ.publish(topic, *pm)
+	if err := worker.controls.publish(topic, pm); nil != err {
+		if nil != waiter {
+			worker.m2.Lock()
+			delete(worker.waitingResponseMessages, pm.CorrelationID)
+			worker.m2.Unlock()
+		}
+		return nil, err
+	}
 	if nil != waiter {
 		var timer *time.Timer
 		var resp *mqenv.MQConsumerMessage
@@ -244,3 +262,62 @@ func (qm *mockQueueManager) subscribe(topic string, callback mqenv.MQConsumerCal
 
 	q.subscribe(callback)
 }
+
+// DriverType 返回mqenv.DriverTypeMock.
+func (worker *MockMQ) DriverType() string {
+	return mqenv.DriverTypeMock
+}
+
+// Ping mock mq 总是可用.
+func (worker *MockMQ) Ping(ctx context.Context) error {
+	return nil
+}
+
+// HealthCheck 返回已订阅的topic，mock mq 总是健康的.
+func (worker *MockMQ) HealthCheck(ctx context.Context) *mqenv.MQHealthStatus {
+	status := mqenv.NewMQHealthStatus(worker.DriverType())
+	worker.m1.RLock()
+	topics := make([]string, 0, len(worker.consumerRegisters))
+	for topic := range worker.consumerRegisters {
+		topics = append(topics, topic)
+	}
+	worker.m1.RUnlock()
+	sort.Strings(topics)
+	for _, topic := range topics {
+		status.AddCheck("topic:"+topic, true, "")
+	}
+	return status
+}
+
+// PublishMessage 发送信息到初始化时配置的topic，未配置时使用pm.Exchange.
+func (worker *MockMQ) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to mock mq")
+	}
+	_, err := worker.Send(worker.publishTopic(pm), pm, false)
+	return err
+}
+
+// ConsumeMessage 订阅consumeProxy.Queue 指定的topic.
+func (worker *MockMQ) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume mock mq with nil consumer proxy")
+	}
+	worker.Subscribe(consumeProxy.Queue, consumeProxy)
+	return nil
+}
+
+// QueryMessage 发送信息并等待回复.
+func (worker *MockMQ) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	if nil == pm {
+		return nil, errors.New("query mock mq with nil message")
+	}
+	return worker.Send(worker.publishTopic(pm), pm, true)
+}
+
+func (worker *MockMQ) publishTopic(pm *mqenv.MQPublishMessage) string {
+	if "" != worker.topic {
+		return worker.topic
+	}
+	return pm.Exchange
+}
@@ -0,0 +1,223 @@
+package mqenv
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Content types and encodings that the message bodies are (de)serialized by
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeText     = "text/plain; charset=utf-8"
+	ContentTypeBinary   = "application/octet-stream"
+
+	ContentEncodingIdentity = "identity"
+	ContentEncodingGzip     = "gzip"
+	ContentEncodingDeflate  = "deflate"
+)
+
+// Errors of content negotiation
+var (
+	ErrUnsupportedContentType     = errors.New("unsupported message content type")
+	ErrUnsupportedContentEncoding = errors.New("unsupported message content encoding")
+)
+
+// DecodedBody the body decompressed by ContentEncoding
+func (m *MQConsumerMessage) DecodedBody() ([]byte, error) {
+	return decodeContent(m.ContentEncoding, m.Body)
+}
+
+// AsJSON decodes the json body into the value pointed by into
+func (m *MQConsumerMessage) AsJSON(into interface{}) error {
+	body, err := m.DecodedBody()
+	if nil != err {
+		return err
+	}
+	return json.Unmarshal(body, into)
+}
+
+// AsProto decodes the protobuf body into message
+func (m *MQConsumerMessage) AsProto(into proto.Message) error {
+	body, err := m.DecodedBody()
+	if nil != err {
+		return err
+	}
+	return proto.Unmarshal(body, into)
+}
+
+// Decode decodes the body into the value pointed by into depends on ContentType:
+//   - protobuf content into proto.Message
+//   - json content, or the content without type, as json
+//   - any content into *[]byte or *string as it is
+func (m *MQConsumerMessage) Decode(into interface{}) error {
+	body, err := m.DecodedBody()
+	if nil != err {
+		return err
+	}
+	switch v := into.(type) {
+	case *[]byte:
+		*v = body
+		return nil
+	case *string:
+		*v = string(body)
+		return nil
+	}
+	if isProtobufContent(m.ContentType) {
+		message, ok := into.(proto.Message)
+		if !ok {
+			return fmt.Errorf("%w: %s into %T", ErrUnsupportedContentType, m.ContentType, into)
+		}
+		return proto.Unmarshal(body, message)
+	}
+	if "" == m.ContentType || isJSONContent(m.ContentType) {
+		return json.Unmarshal(body, into)
+	}
+	return fmt.Errorf("%w: %s into %T", ErrUnsupportedContentType, m.ContentType, into)
+}
+
+// SetJSON sets the body by the json of value and ContentType as json
+func (m *MQPublishMessage) SetJSON(value interface{}) error {
+	body, err := json.Marshal(value)
+	if nil != err {
+		return err
+	}
+	m.ContentType = ContentTypeJSON
+	return m.setBody(body)
+}
+
+// SetProto sets the body by the protobuf of message and ContentType as protobuf
+func (m *MQPublishMessage) SetProto(message proto.Message) error {
+	body, err := proto.Marshal(message)
+	if nil != err {
+		return err
+	}
+	m.ContentType = ContentTypeProtobuf
+	return m.setBody(body)
+}
+
+// Encode sets the body by value serialized depends on ContentType, the ContentType would be detected by the type of value
+// if not specified: protobuf for proto.Message, binary for []byte, text for string and json for the others.
+// The []byte value is taken as the serialized content. The body is compressed by ContentEncoding
+func (m *MQPublishMessage) Encode(value interface{}) error {
+	if "" == m.ContentType {
+		switch value.(type) {
+		case proto.Message:
+			m.ContentType = ContentTypeProtobuf
+		case []byte:
+			m.ContentType = ContentTypeBinary
+		case string:
+			m.ContentType = ContentTypeText
+		default:
+			m.ContentType = ContentTypeJSON
+		}
+	}
+	if v, ok := value.([]byte); ok {
+		return m.setBody(v)
+	}
+	switch {
+	case isProtobufContent(m.ContentType):
+		message, ok := value.(proto.Message)
+		if !ok {
+			return fmt.Errorf("%w: %T as %s", ErrUnsupportedContentType, value, m.ContentType)
+		}
+		body, err := proto.Marshal(message)
+		if nil != err {
+			return err
+		}
+		return m.setBody(body)
+	case isJSONContent(m.ContentType):
+		body, err := json.Marshal(value)
+		if nil != err {
+			return err
+		}
+		return m.setBody(body)
+	}
+	if v, ok := value.(string); ok {
+		return m.setBody([]byte(v))
+	}
+	return fmt.Errorf("%w: %T as %s", ErrUnsupportedContentType, value, m.ContentType)
+}
+
+func (m *MQPublishMessage) setBody(body []byte) error {
+	encoded, err := encodeContent(m.ContentEncoding, body)
+	if nil != err {
+		return err
+	}
+	m.Body = encoded
+	return nil
+}
+
+// mediaType returns the lower case media type without parameters
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		mt = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return strings.ToLower(mt)
+}
+
+// isJSONContent application/json or the types with +json suffix such as application/problem+json
+func isJSONContent(contentType string) bool {
+	mt := mediaType(contentType)
+	return "application/json" == mt || "text/json" == mt || strings.HasSuffix(mt, "+json")
+}
+
+func isProtobufContent(contentType string) bool {
+	switch mediaType(contentType) {
+	case "application/x-protobuf", "application/protobuf", "application/vnd.google.protobuf":
+		return true
+	}
+	return false
+}
+
+func decodeContent(encoding string, body []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", ContentEncodingIdentity:
+		return body, nil
+	case ContentEncodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+		if nil != err {
+			return nil, err
+		}
+	case ContentEncodingDeflate:
+		reader = flate.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func encodeContent(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", ContentEncodingIdentity:
+		return body, nil
+	case ContentEncodingGzip:
+		writer = gzip.NewWriter(&buf)
+	case ContentEncodingDeflate:
+		writer, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, encoding)
+	}
+	if _, err := writer.Write(body); nil != err {
+		return nil, err
+	}
+	if err := writer.Close(); nil != err {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
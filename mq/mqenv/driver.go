@@ -0,0 +1,55 @@
+package mqenv
+
+import "context"
+
+// MQDriver produce/consume interface implemented by every mq backend, so that
+// applications could switch brokers by the connection driver config without code changes
+type MQDriver interface {
+	// DriverType returns the driver type name such as DriverTypeAMQP
+	DriverType() string
+	// PublishMessage publishes a message without waiting for the response
+	PublishMessage(pm *MQPublishMessage) error
+	// ConsumeMessage subscribes the queue or topic of consumeProxy
+	ConsumeMessage(consumeProxy *MQConsumerProxy) error
+	// QueryMessage publishes a message and waits for the response
+	QueryMessage(pm *MQPublishMessage) (*MQConsumerMessage, error)
+}
+
+// MQHealthChecker implemented by the drivers supporting health checks for readiness endpoints
+type MQHealthChecker interface {
+	// Ping verifies the connectivity to the brokers
+	Ping(ctx context.Context) error
+	// HealthCheck checks the brokers, topics and subscriptions and returns the structured status
+	HealthCheck(ctx context.Context) *MQHealthStatus
+}
+
+// MQHealthStatus health status of a mq driver
+type MQHealthStatus struct {
+	Driver  string          `json:"driver"`
+	Healthy bool            `json:"healthy"`
+	Checks  []MQHealthCheck `json:"checks"`
+}
+
+// MQHealthCheck result of one item checked
+type MQHealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewMQHealthStatus new healthy status of driver
+func NewMQHealthStatus(driver string) *MQHealthStatus {
+	return &MQHealthStatus{
+		Driver:  driver,
+		Healthy: true,
+		Checks:  []MQHealthCheck{},
+	}
+}
+
+// AddCheck appends the result of checked item, the status turns unhealthy if any item failed
+func (s *MQHealthStatus) AddCheck(name string, healthy bool, message string) {
+	s.Checks = append(s.Checks, MQHealthCheck{Name: name, Healthy: healthy, Message: message})
+	if !healthy {
+		s.Healthy = false
+	}
+}
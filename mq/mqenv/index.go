@@ -10,6 +10,9 @@ const (
 	DriverTypeKafka  = "kafka"
 	DriverTypePulsar = "pulsar"
 	DriverTypeMock   = "mock"
+	DriverTypeNats   = "nats"
+
+	DriverTypeRedisStream = "redisstream"
 
 	MQTypeConsumer  = 1
 	MQTypePublisher = 2
@@ -49,21 +52,22 @@ type MQConnectorConfig struct {
 
 // MQConsumerMessage consumer message
 type MQConsumerMessage struct {
-	Driver        string            `json:"driver"`
-	Queue         string            `json:"queue"`
-	CorrelationID string            `json:"correlationId"`
-	ConsumerTag   string            `json:"consumerTag"`
-	ReplyTo       string            `json:"replyTo"`
-	MessageID     string            `json:"messageId"`
-	AppID         string            `json:"appId"`
-	UserID        string            `json:"userId"`
-	ContentType   string            `json:"contentType"`
-	Exchange      string            `json:"exchange"`
-	RoutingKey    string            `json:"routingKey"`
-	Timestamp     time.Time         `json:"-"`
-	Body          []byte            `json:"body"`
-	Headers       map[string]string `json:"headers"`
-	BindData      interface{}       `json:"-"`
+	Driver          string            `json:"driver"`
+	Queue           string            `json:"queue"`
+	CorrelationID   string            `json:"correlationId"`
+	ConsumerTag     string            `json:"consumerTag"`
+	ReplyTo         string            `json:"replyTo"`
+	MessageID       string            `json:"messageId"`
+	AppID           string            `json:"appId"`
+	UserID          string            `json:"userId"`
+	ContentType     string            `json:"contentType"`
+	ContentEncoding string            `json:"contentEncoding"`
+	Exchange        string            `json:"exchange"`
+	RoutingKey      string            `json:"routingKey"`
+	Timestamp       time.Time         `json:"-"`
+	Body            []byte            `json:"body"`
+	Headers         map[string]string `json:"headers"`
+	BindData        interface{}       `json:"-"`
 }
 
 // MQPublishMessage publish message
@@ -77,6 +81,7 @@ type MQPublishMessage struct {
 	AppID            string                 `json:"appId"`
 	UserID           string                 `json:"userId"`
 	ContentType      string                 `json:"contentType"`
+	ContentEncoding  string                 `json:"contentEncoding"`
 	PublishStatus    chan MQEvent           `json:"-"`
 	EventLabel       string                 `json:"eventLabel"`
 	Headers          map[string]string      `json:"headers"`
@@ -154,21 +159,22 @@ func NewMQResponseMessage(body []byte, cm *MQConsumerMessage) *MQPublishMessage
 // NewConsumerMessageFromPublishMessage new consumer message from publish message
 func NewConsumerMessageFromPublishMessage(pm *MQPublishMessage) MQConsumerMessage {
 	msg := MQConsumerMessage{
-		Driver:        DriverTypeMock,
-		Queue:         "",
-		CorrelationID: pm.CorrelationID,
-		ConsumerTag:   "",
-		ReplyTo:       pm.ReplyTo,
-		MessageID:     pm.MessageID,
-		AppID:         pm.AppID,
-		UserID:        pm.UserID,
-		ContentType:   pm.ContentType,
-		Exchange:      pm.Exchange,
-		RoutingKey:    pm.RoutingKey,
-		Timestamp:     time.Now(),
-		Body:          pm.Body,
-		Headers:       pm.Headers,
-		BindData:      nil,
+		Driver:          DriverTypeMock,
+		Queue:           "",
+		CorrelationID:   pm.CorrelationID,
+		ConsumerTag:     "",
+		ReplyTo:         pm.ReplyTo,
+		MessageID:       pm.MessageID,
+		AppID:           pm.AppID,
+		UserID:          pm.UserID,
+		ContentType:     pm.ContentType,
+		ContentEncoding: pm.ContentEncoding,
+		Exchange:        pm.Exchange,
+		RoutingKey:      pm.RoutingKey,
+		Timestamp:       time.Now(),
+		Body:            pm.Body,
+		Headers:         pm.Headers,
+		BindData:        nil,
 	}
 	return msg
 }
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/kafka"
 	"github.com/libpub/golib/yamlutils"
 )
 
@@ -37,6 +38,35 @@ type Config struct {
 	//fanout:广播,订阅同一个topic，但是消费者组会使用uuid，所有组都会收到信息
 	MessageType        string `yaml:"messageType" json:"messageType"`
 	UseOriginalContent bool   `yaml:"useOriginalContent" json:"useOriginalContent"`
+	Concurrency        int    `yaml:"concurrency" json:"concurrency"`
+	MaxInFlight        int    `yaml:"maxInFlight" json:"maxInFlight"`
+	PartitionOrdering  bool   `yaml:"partitionOrdering" json:"partitionOrdering"`
+	OffsetMode         string `yaml:"offsetMode" json:"offsetMode"`
+	StartOffset        int64  `yaml:"startOffset" json:"startOffset"`
+	StartTimestamp     int64  `yaml:"startTimestamp" json:"startTimestamp"`
+	// schema 注册中心配置，ValueSchema.Type 不为空时topic 消息体按schema 序列化
+	SchemaRegistry kafka.SchemaRegistryConfig `yaml:"schemaRegistry" json:"schemaRegistry"`
+	ValueSchema    kafka.TopicSchema          `yaml:"valueSchema" json:"valueSchema"`
+	// 事务生产者配置，TransactionalID 不为空时启用事务
+	TransactionalID      string `yaml:"transactionalId" json:"transactionalId"`
+	TransactionTimeoutMS int    `yaml:"transactionTimeoutMs" json:"transactionTimeoutMs"`
+	// kafka 断线重连等待时间(毫秒)，逐次加倍直到ReconnectBackoffMaxMS
+	ReconnectBackoffMS    int `yaml:"reconnectBackoffMs" json:"reconnectBackoffMs"`
+	ReconnectBackoffMaxMS int `yaml:"reconnectBackoffMaxMs" json:"reconnectBackoffMaxMs"`
+	// kafka 生产者消息压缩方式: none/gzip/snappy/lz4/zstd
+	Compression string `yaml:"compression" json:"compression"`
+	// kafka 消息体最大字节数，超过时拆分为分片发送
+	MaxMessageBytes int `yaml:"maxMessageBytes" json:"maxMessageBytes"`
+	// NATS parameters, Topic is used as subject and GroupID as queue group
+	JetStream      bool   `yaml:"jetStream" json:"jetStream"`
+	Stream         string `yaml:"stream" json:"stream"`
+	DurableName    string `yaml:"durableName" json:"durableName"`
+	AckWaitSeconds int    `yaml:"ackWaitSeconds" json:"ackWaitSeconds"`
+	MaxDeliver     int    `yaml:"maxDeliver" json:"maxDeliver"`
+	// Redis Streams parameters, Topic is used as stream key and GroupID as consumer group
+	MaxLen           int64 `yaml:"maxLen" json:"maxLen"`
+	BatchSize        int64 `yaml:"batchSize" json:"batchSize"`
+	ClaimIdleSeconds int   `yaml:"claimIdleSeconds" json:"claimIdleSeconds"`
 }
 
 // RoutesEnv struct
@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"sync"
+
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/nats-io/nats.go"
+)
+
+// Constants
+const (
+	HeaderCorrelationID   = "CorrelationId"
+	HeaderReplyTo         = "ReplyTo"
+	HeaderMessageID       = "MessageId"
+	HeaderAppID           = "AppId"
+	HeaderUserID          = "UserId"
+	HeaderContentType     = "ContentType"
+	HeaderContentEncoding = "ContentEncoding"
+
+	DefaultQueryTimeoutSeconds = 30
+)
+
+// Config NATS MQ configuration
+type Config struct {
+	ConnConfigName string
+	Subject        string // default subject for publishing and consuming
+	QueueGroup     string // queue group name, consumers in same group share messages
+	// 消息类型:
+	//direct:组播,订阅同一个subject的消费者使用相同的queue group，一条消息只会被组内一个消费者接收
+	//fanout:广播,不使用queue group，所有消费者都会收到信息
+	MessageType string `yaml:"messageType" json:"messageType"`
+	// JetStream parameters
+	JetStream      bool   // publish and consume with JetStream persistence
+	Stream         string // JetStream stream name, created with Subject if not exists
+	Durable        string // JetStream durable consumer name
+	AckWaitSeconds int    // JetStream redelivery duration if message not acked
+	MaxDeliver     int    // JetStream max delivery times of a message
+}
+
+// NatsMQ instance
+type NatsMQ struct {
+	Name           string
+	config         *Config
+	connConfig     *mqenv.MQConnectorConfig
+	conn           *nats.Conn
+	js             nats.JetStreamContext
+	streamReady    bool
+	streamSubjects map[string]bool // subjects published before, true if captured by a stream
+	subscriptions  map[string]*nats.Subscription
+	m              sync.RWMutex
+	streamMutex    sync.Mutex
+}
+
+// Equals check if equals
+func (me *Config) Equals(to *Config) bool {
+	return (me.ConnConfigName == to.ConnConfigName &&
+		me.Subject == to.Subject &&
+		me.QueueGroup == to.QueueGroup &&
+		me.MessageType == to.MessageType &&
+		me.JetStream == to.JetStream &&
+		me.Stream == to.Stream &&
+		me.Durable == to.Durable &&
+		me.AckWaitSeconds == to.AckWaitSeconds &&
+		me.MaxDeliver == to.MaxDeliver)
+}
+
+// IsBroadcast check if the configure is fanout
+func (me *Config) IsBroadcast() bool {
+	return "fanout" == me.MessageType
+}
@@ -0,0 +1,521 @@
+package nats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/utils"
+	"github.com/nats-io/nats.go"
+)
+
+// Variables
+var (
+	natsInsts     = map[string]*NatsMQ{}
+	natsInstMutex = sync.RWMutex{}
+)
+
+// InitNatsMQ init
+func InitNatsMQ(mqConnName string, connCfg *mqenv.MQConnectorConfig, natsCfg *Config) (*NatsMQ, error) {
+	natsInstMutex.Lock()
+	defer natsInstMutex.Unlock()
+	natsInst, ok := natsInsts[mqConnName]
+	if ok && !natsInst.config.Equals(natsCfg) {
+		natsInst.Close()
+		ok = false
+	}
+	if !ok {
+		natsInst = NewNatsMQ(mqConnName, connCfg, natsCfg)
+		logger.Info.Printf("Initializing nats instance:%s", natsInst.Name)
+		err := natsInst.init()
+		if nil != err {
+			return nil, err
+		}
+		natsInsts[mqConnName] = natsInst
+	}
+	return natsInst, nil
+}
+
+// GetNatsMQ get
+func GetNatsMQ(name string) (*NatsMQ, error) {
+	natsInstMutex.RLock()
+	natsInst, ok := natsInsts[name]
+	natsInstMutex.RUnlock()
+	if ok {
+		return natsInst, nil
+	}
+	return nil, fmt.Errorf("NatsMQ instance by %s not found", name)
+}
+
+// NewNatsMQ with parameters
+func NewNatsMQ(mqConnName string, connCfg *mqenv.MQConnectorConfig, natsCfg *Config) *NatsMQ {
+	r := &NatsMQ{
+		Name:           mqConnName,
+		config:         natsCfg,
+		connConfig:     connCfg,
+		subscriptions:  map[string]*nats.Subscription{},
+		streamSubjects: map[string]bool{},
+		m:              sync.RWMutex{},
+	}
+	return r
+}
+
+// init connects the nats servers, the connection would be retried in background if servers not ready
+func (r *NatsMQ) init() error {
+	if mqenv.DriverTypeNats != r.connConfig.Driver {
+		logger.Error.Printf("Initialize nats connection by configure:%s failed, the configure driver:%s does not fit.", r.Name, r.connConfig.Driver)
+		return errors.New("Invalid driver for nats")
+	}
+	servers := r.formatServers()
+	options := []nats.Option{
+		nats.Name(r.Name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(mqenv.MQReconnectSeconds * time.Second),
+		nats.DisconnectErrHandler(func(c *nats.Conn, err error) {
+			if nil != err {
+				logger.Warning.Printf("NatsMQ %s disconnected with error:%v", r.Name, err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			logger.Info.Printf("NatsMQ %s reconnected to %s", r.Name, c.ConnectedUrl())
+		}),
+		nats.ErrorHandler(func(c *nats.Conn, sub *nats.Subscription, err error) {
+			logger.Error.Printf("NatsMQ %s got async error:%v", r.Name, err)
+		}),
+	}
+	if "" != r.connConfig.User {
+		options = append(options, nats.UserInfo(r.connConfig.User, r.connConfig.Password))
+	} else if "" != r.connConfig.Password {
+		options = append(options, nats.Token(r.connConfig.Password))
+	}
+	if r.connConfig.Timeout > 0 {
+		options = append(options, nats.Timeout(time.Duration(r.connConfig.Timeout)*time.Second))
+	}
+	if r.connConfig.Heartbeat > 0 {
+		options = append(options, nats.PingInterval(time.Duration(r.connConfig.Heartbeat)*time.Second))
+	}
+	conn, err := nats.Connect(servers, options...)
+	if nil != err {
+		logger.Error.Printf("Connecting nats %s with %s failed with error:%v", r.Name, servers, err)
+		return err
+	}
+	r.conn = conn
+	if r.config.JetStream {
+		r.js, err = conn.JetStream()
+		if nil != err {
+			logger.Error.Printf("Initialize nats %s JetStream context failed with error:%v", r.Name, err)
+			conn.Close()
+			return err
+		}
+	}
+	logger.Info.Printf("Connecting nats %s with %s succeed", r.Name, servers)
+	return nil
+}
+
+// formatServers format nats server urls with hosts and port
+func (r *NatsMQ) formatServers() string {
+	cnf := r.connConfig
+	hosts := strings.Split(cnf.Host, ",")
+	servers := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if "" == host {
+			continue
+		}
+		if !strings.Contains(host, "://") {
+			if !strings.Contains(host, ":") && cnf.Port > 0 {
+				host = fmt.Sprintf("%s:%d", host, cnf.Port)
+			}
+			host = "nats://" + host
+		}
+		servers = append(servers, host)
+	}
+	if len(servers) == 0 {
+		return nats.DefaultURL
+	}
+	return strings.Join(servers, ",")
+}
+
+// Close drains the subscriptions and closes the connection
+func (r *NatsMQ) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.subscriptions = map[string]*nats.Subscription{}
+	if nil == r.conn {
+		return nil
+	}
+	logger.Info.Printf("NatsMQ connection:%s closing", r.Name)
+	err := r.conn.Drain()
+	if nil != err {
+		r.conn.Close()
+	}
+	r.conn = nil
+	r.js = nil
+	logger.Info.Printf("NatsMQ connection:%s closing finished", r.Name)
+	return err
+}
+
+// IsConnected returns true if the connection is connected
+func (r *NatsMQ) IsConnected() bool {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return nil != r.conn && r.conn.IsConnected()
+}
+
+// DriverType returns mqenv.DriverTypeNats
+func (r *NatsMQ) DriverType() string {
+	return mqenv.DriverTypeNats
+}
+
+// PublishMessage publishes the message to pm.RoutingKey or the configured subject
+func (r *NatsMQ) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to nats")
+	}
+	err := r.publish(pm)
+	if nil != pm.PublishStatus {
+		status := mqenv.MQEvent{
+			Code:    mqenv.MQEventCodeOk,
+			Label:   pm.EventLabel,
+			Message: "Publish success",
+		}
+		if nil != err {
+			status.Code = mqenv.MQEventCodeFailed
+			status.Message = err.Error()
+		}
+		pm.PublishStatus <- status
+	}
+	return err
+}
+
+// ConsumeMessage subscribes consumeProxy.Queue or the configured subject
+func (r *NatsMQ) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume nats with nil consumer proxy")
+	}
+	subject := consumeProxy.Queue
+	if "" == subject {
+		subject = r.config.Subject
+	}
+	key := subject + "-" + consumeProxy.ConsumerTag
+	r.m.Lock()
+	defer r.m.Unlock()
+	if nil == r.conn {
+		return fmt.Errorf("NatsMQ %s connection closed", r.Name)
+	}
+	if _, ok := r.subscriptions[key]; ok {
+		return nil
+	}
+	queueGroup := r.queueGroup()
+	handler := func(msg *nats.Msg) {
+		r.handleConsumeCallback(msg, consumeProxy)
+	}
+	var sub *nats.Subscription
+	var err error
+	if r.config.JetStream {
+		err = r.ensureStream()
+		if nil != err {
+			return err
+		}
+		sub, err = r.js.QueueSubscribe(subject, queueGroup, handler, r.subscribeOptions()...)
+	} else {
+		sub, err = r.conn.QueueSubscribe(subject, queueGroup, handler)
+	}
+	if nil != err {
+		logger.Error.Printf("NatsMQ %s subscribe subject:%s failed with error:%v", r.Name, subject, err)
+		return err
+	}
+	r.subscriptions[key] = sub
+	logger.Info.Printf("Now consuming mq(%s) with subject:%s queue group:%s ...", r.Name, subject, queueGroup)
+	if nil != consumeProxy.Ready {
+		select {
+		case consumeProxy.Ready <- true:
+		default:
+		}
+	}
+	return nil
+}
+
+// QueryMessage publishes a message and waiting the response on a temporary inbox
+func (r *NatsMQ) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	if nil == pm {
+		return nil, errors.New("query nats with nil message")
+	}
+	r.m.RLock()
+	conn := r.conn
+	r.m.RUnlock()
+	if nil == conn {
+		return nil, fmt.Errorf("NatsMQ %s connection closed", r.Name)
+	}
+	inbox := nats.NewInbox()
+	sub, err := conn.SubscribeSync(inbox)
+	if nil != err {
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+	if "" == pm.CorrelationID {
+		pm.CorrelationID = utils.GenLoweruuid()
+	}
+	pm.ReplyTo = inbox
+	err = r.PublishMessage(pm)
+	if nil != err {
+		return nil, err
+	}
+	timeoutSeconds := pm.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = DefaultQueryTimeoutSeconds
+	}
+	msg, err := sub.NextMsg(time.Duration(timeoutSeconds) * time.Second)
+	if nil != err {
+		pm.OnClosed()
+		if errors.Is(err, nats.ErrTimeout) {
+			return nil, fmt.Errorf("Query timeout")
+		}
+		return nil, err
+	}
+	resp := r.generateConsumerMessage(msg, "")
+	if logger.IsDebugEnabled() {
+		logger.Trace.Printf("NatsMQ %s Got response %s", r.Name, utils.HumanByteText(resp.Body))
+	}
+	return &resp, nil
+}
+
+func (r *NatsMQ) publish(pm *mqenv.MQPublishMessage) error {
+	subject := pm.RoutingKey
+	if "" == subject {
+		subject = r.config.Subject
+	}
+	if "" == subject {
+		return fmt.Errorf("NatsMQ %s publish message without subject", r.Name)
+	}
+	r.m.RLock()
+	conn := r.conn
+	js := r.js
+	r.m.RUnlock()
+	if nil == conn {
+		return fmt.Errorf("NatsMQ %s connection closed", r.Name)
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = pm.Body
+	prepareMessageHeader(msg.Header, pm)
+	if logger.IsDebugEnabled() {
+		logger.Trace.Printf("NatsMQ %s publishing message(%s) to %s with %dB body (%s)", r.Name, pm.CorrelationID, subject, len(pm.Body), utils.HumanByteText(pm.Body))
+	}
+	// replies to the temporary inbox are always published by core nats
+	if nil != js && !strings.HasPrefix(subject, nats.InboxPrefix) {
+		err := r.ensureStream()
+		if nil != err {
+			return err
+		}
+		inStream, err := r.isStreamSubject(js, subject)
+		if nil != err {
+			logger.Error.Printf("NatsMQ %s lookup stream of subject:%s failed with error:%v", r.Name, subject, err)
+			return err
+		}
+		if !inStream {
+			// subjects not captured by any stream would never be acked by JetStream, publish them by core nats
+			return conn.PublishMsg(msg)
+		}
+		opts := []nats.PubOpt{}
+		if "" != pm.MessageID {
+			opts = append(opts, nats.MsgId(pm.MessageID))
+		}
+		_, err = js.PublishMsg(msg, opts...)
+		if nil != err {
+			logger.Error.Printf("NatsMQ %s publishing message to %s failed with error:%v", r.Name, subject, err)
+		}
+		return err
+	}
+	return conn.PublishMsg(msg)
+}
+
+// ensureStream creates the JetStream stream of configured subject if not exists
+func (r *NatsMQ) ensureStream() error {
+	r.streamMutex.Lock()
+	defer r.streamMutex.Unlock()
+	if r.streamReady || "" == r.config.Stream {
+		return nil
+	}
+	_, err := r.js.StreamInfo(r.config.Stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		subjects := []string{}
+		if "" != r.config.Subject {
+			subjects = append(subjects, r.config.Subject)
+		}
+		_, err = r.js.AddStream(&nats.StreamConfig{
+			Name:     r.config.Stream,
+			Subjects: subjects,
+		})
+	}
+	if nil != err {
+		logger.Error.Printf("NatsMQ %s ensure stream:%s failed with error:%v", r.Name, r.config.Stream, err)
+		return err
+	}
+	r.streamReady = true
+	return nil
+}
+
+// isStreamSubject checks if the subject is captured by a JetStream stream, the lookup result is cached
+func (r *NatsMQ) isStreamSubject(js nats.JetStreamContext, subject string) (bool, error) {
+	if "" != r.config.Subject && subjectMatches(r.config.Subject, subject) {
+		return true, nil
+	}
+	r.streamMutex.Lock()
+	defer r.streamMutex.Unlock()
+	if inStream, ok := r.streamSubjects[subject]; ok {
+		return inStream, nil
+	}
+	_, err := js.StreamNameBySubject(subject)
+	if nil != err && !errors.Is(err, nats.ErrNoMatchingStream) {
+		return false, err
+	}
+	r.streamSubjects[subject] = nil == err
+	return nil == err, nil
+}
+
+// subjectMatches checks if the subject matches the pattern which may contain '*' and '>' wildcards
+func subjectMatches(pattern string, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if ">" == token {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if "*" != token && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+func (r *NatsMQ) queueGroup() string {
+	if r.config.IsBroadcast() {
+		return ""
+	}
+	if "" != r.config.QueueGroup {
+		return r.config.QueueGroup
+	}
+	return r.Name
+}
+
+func (r *NatsMQ) subscribeOptions() []nats.SubOpt {
+	opts := []nats.SubOpt{nats.ManualAck()}
+	if "" != r.config.Stream {
+		opts = append(opts, nats.BindStream(r.config.Stream))
+	}
+	if "" != r.config.Durable {
+		opts = append(opts, nats.Durable(r.config.Durable))
+	}
+	if r.config.AckWaitSeconds > 0 {
+		opts = append(opts, nats.AckWait(time.Duration(r.config.AckWaitSeconds)*time.Second))
+	}
+	if r.config.MaxDeliver > 0 {
+		opts = append(opts, nats.MaxDeliver(r.config.MaxDeliver))
+	}
+	return opts
+}
+
+// handleConsumeCallback invokes the callback and publishes the response if the message requires reply.
+// JetStream messages are acked after callback returns, or immediately while AutoAck, and would be
+// redelivered by nak if the callback panics
+func (r *NatsMQ) handleConsumeCallback(msg *nats.Msg, consumeProxy *mqenv.MQConsumerProxy) {
+	isJetStream := r.config.JetStream && "" != msg.Reply && strings.HasPrefix(msg.Reply, "$JS.ACK.")
+	if isJetStream && consumeProxy.AutoAck {
+		msg.Ack()
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error.Printf("NatsMQ %s consume subject:%s callback panic:%v", r.Name, msg.Subject, err)
+			if isJetStream && !consumeProxy.AutoAck {
+				msg.Nak()
+			}
+		}
+	}()
+	m := r.generateConsumerMessage(msg, consumeProxy.ConsumerTag)
+	if !isJetStream && "" == m.ReplyTo {
+		m.ReplyTo = msg.Reply
+	}
+	if logger.IsDebugEnabled() {
+		logger.Debug.Printf("NatsMQ %s subject:%s received msg(%s) %s", r.Name, m.Queue, m.CorrelationID, utils.HumanByteText(m.Body))
+	}
+	var resp *mqenv.MQPublishMessage
+	if nil != consumeProxy.Callback {
+		resp = consumeProxy.Callback(m)
+	}
+	if nil != resp && "" != m.ReplyTo {
+		resp.RoutingKey = m.ReplyTo
+		if "" == resp.CorrelationID {
+			resp.CorrelationID = m.CorrelationID
+		}
+		err := r.publish(resp)
+		if nil != err {
+			logger.Error.Printf("NatsMQ %s reply message(%s) to %s failed with error:%v", r.Name, m.CorrelationID, m.ReplyTo, err)
+		}
+	}
+	if isJetStream && !consumeProxy.AutoAck {
+		msg.Ack()
+	}
+}
+
+func (r *NatsMQ) generateConsumerMessage(msg *nats.Msg, consumerTag string) mqenv.MQConsumerMessage {
+	m := mqenv.MQConsumerMessage{
+		Driver:      mqenv.DriverTypeNats,
+		Queue:       msg.Subject,
+		ConsumerTag: consumerTag,
+		RoutingKey:  msg.Subject,
+		Timestamp:   time.Now(),
+		Body:        msg.Data,
+		Headers:     map[string]string{},
+		BindData:    msg,
+	}
+	for k := range msg.Header {
+		m.Headers[k] = msg.Header.Get(k)
+	}
+	m.CorrelationID = m.Headers[HeaderCorrelationID]
+	m.ReplyTo = m.Headers[HeaderReplyTo]
+	m.MessageID = m.Headers[HeaderMessageID]
+	m.AppID = m.Headers[HeaderAppID]
+	m.UserID = m.Headers[HeaderUserID]
+	m.ContentType = m.Headers[HeaderContentType]
+	m.ContentEncoding = m.Headers[HeaderContentEncoding]
+	if meta, err := msg.Metadata(); nil == err {
+		m.Timestamp = meta.Timestamp
+		m.Exchange = meta.Stream
+	}
+	return m
+}
+
+func prepareMessageHeader(header nats.Header, pm *mqenv.MQPublishMessage) {
+	for k, v := range pm.Headers {
+		header.Set(k, v)
+	}
+	if "" != pm.AppID {
+		header.Set(HeaderAppID, pm.AppID)
+	}
+	if "" != pm.UserID {
+		header.Set(HeaderUserID, pm.UserID)
+	}
+	if "" != pm.MessageID {
+		header.Set(HeaderMessageID, pm.MessageID)
+	}
+	if "" != pm.CorrelationID {
+		header.Set(HeaderCorrelationID, pm.CorrelationID)
+	}
+	if "" != pm.ReplyTo {
+		header.Set(HeaderReplyTo, pm.ReplyTo)
+	}
+	if "" != pm.ContentType {
+		header.Set(HeaderContentType, pm.ContentType)
+	}
+	if "" != pm.ContentEncoding {
+		header.Set(HeaderContentEncoding, pm.ContentEncoding)
+	}
+}
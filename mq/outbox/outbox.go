@@ -0,0 +1,374 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/netutils/dboptions"
+	"github.com/libpub/golib/scheduler"
+)
+
+// Constants of outbox
+const (
+	DefaultTableName       = "mq_outbox"
+	DefaultBatchSize       = 100
+	DefaultMaxAttempts     = 10
+	DefaultRetryInterval   = time.Second
+	DefaultMaxRetryBackoff = 5 * time.Minute
+
+	StatusPending = 0 // waiting for relaying
+	StatusSent    = 1 // published by the relay
+	StatusFailed  = 2 // given up after the max attempts
+)
+
+// Errors of outbox
+var (
+	ErrUnsupportedDriver = errors.New("outbox table creation not supported by the database driver")
+)
+
+// Message a message written into the outbox table and published by the relay after the transaction committed
+type Message struct {
+	ID        int64
+	Topic     string
+	Key       string
+	Headers   map[string]string
+	Payload   []byte
+	Status    int
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// Execer executes the statements in the caller's transaction, *sql.Tx, *sql.DB and *sql.Conn implement it
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Publisher publishes the relayed messages
+type Publisher interface {
+	Publish(ctx context.Context, msg *Message) error
+}
+
+// PublisherFunc function as Publisher
+type PublisherFunc func(ctx context.Context, msg *Message) error
+
+// Publish implements Publisher
+func (f PublisherFunc) Publish(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// KafkaPublisher publishes the messages by kafka producer and waits for the acknowledgement,
+// the messages with key are partitioned by key and the others round robin
+func KafkaPublisher(producer *kafka.Producer) Publisher {
+	return PublisherFunc(func(ctx context.Context, msg *Message) error {
+		message := kafka.ProducerMessage{
+			Key:      []byte(msg.Key),
+			Value:    msg.Payload,
+			Headers:  msg.Headers,
+			Strategy: kafka.PartitionByKey,
+		}
+		if "" == msg.Key {
+			message.Key = nil
+			message.Strategy = kafka.PartitionRoundRobin
+		}
+		_, err := producer.SendMessageContext(ctx, msg.Topic, message)
+		return err
+	})
+}
+
+// Option options of outbox
+type Option func(*Outbox)
+
+// WithTableName the outbox table name, DefaultTableName by default
+func WithTableName(name string) Option {
+	return func(o *Outbox) {
+		o.table = name
+	}
+}
+
+// WithBatchSize the max messages relayed by one query
+func WithBatchSize(size int) Option {
+	return func(o *Outbox) {
+		o.batchSize = size
+	}
+}
+
+// WithRetryPolicy the message is marked failed after maxAttempts publishing failures, and retried after the interval
+// doubling every attempt up to DefaultMaxRetryBackoff
+func WithRetryPolicy(interval time.Duration, maxAttempts int) Option {
+	return func(o *Outbox) {
+		o.retryInterval = interval
+		o.maxAttempts = maxAttempts
+	}
+}
+
+// Outbox transactional outbox, the messages are written into the outbox table in the caller's transaction
+// and published by the relay after committed, so that they are published if and only if the transaction committed.
+// The messages are published at least once, the consumers should be idempotent.
+// The relay should run on only one instance at the same time, such as scheduled with scheduler.WithDistributedLock,
+// otherwise the messages may be published more than once
+type Outbox struct {
+	db            *sql.DB
+	driverName    string
+	table         string
+	batchSize     int
+	maxAttempts   int
+	retryInterval time.Duration
+}
+
+// NewOutbox new outbox on db, the driverName such as dboptions.DriverPostgres decides the sql dialect
+func NewOutbox(db *sql.DB, driverName string, options ...Option) *Outbox {
+	o := &Outbox{
+		db:            db,
+		driverName:    driverName,
+		table:         DefaultTableName,
+		batchSize:     DefaultBatchSize,
+		maxAttempts:   DefaultMaxAttempts,
+		retryInterval: DefaultRetryInterval,
+	}
+	for _, option := range options {
+		option(o)
+	}
+	if o.batchSize <= 0 {
+		o.batchSize = DefaultBatchSize
+	}
+	if o.maxAttempts <= 0 {
+		o.maxAttempts = DefaultMaxAttempts
+	}
+	if o.retryInterval <= 0 {
+		o.retryInterval = DefaultRetryInterval
+	}
+	return o
+}
+
+// CreateTable creates the outbox table if not exists
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	var id, blob, index string
+	statements := []string{}
+	switch o.driverName {
+	case dboptions.DriverSQLite:
+		id, blob = "INTEGER PRIMARY KEY AUTOINCREMENT", "BLOB"
+		statements = append(statements, "CREATE INDEX IF NOT EXISTS "+o.table+"_pending ON "+o.table+" (status, next_attempt_at)")
+	case dboptions.DriverMySQL:
+		// mysql 不支持CREATE INDEX IF NOT EXISTS，索引随表创建
+		id, blob, index = "BIGINT AUTO_INCREMENT PRIMARY KEY", "LONGBLOB", ", INDEX "+o.table+"_pending (status, next_attempt_at)"
+	case dboptions.DriverPostgres:
+		id, blob = "BIGSERIAL PRIMARY KEY", "BYTEA"
+		statements = append(statements, "CREATE INDEX IF NOT EXISTS "+o.table+"_pending ON "+o.table+" (status, next_attempt_at)")
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedDriver, o.driverName)
+	}
+	statements = append([]string{
+		"CREATE TABLE IF NOT EXISTS " + o.table + " (" +
+			"id " + id + ", " +
+			"topic VARCHAR(255) NOT NULL, " +
+			"msg_key VARCHAR(255) NOT NULL, " +
+			"headers TEXT, " +
+			"payload " + blob + ", " +
+			"status INT NOT NULL, " +
+			"attempts INT NOT NULL, " +
+			"last_error TEXT, " +
+			"created_at BIGINT NOT NULL, " +
+			"next_attempt_at BIGINT NOT NULL, " +
+			"sent_at BIGINT NOT NULL" + index + ")",
+	}, statements...)
+	for _, statement := range statements {
+		if _, err := o.db.ExecContext(ctx, statement); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add writes the messages into the outbox table by tx, they are relayed only if the transaction committed
+func (o *Outbox) Add(ctx context.Context, tx Execer, msgs ...*Message) error {
+	query := o.rebind("INSERT INTO " + o.table + " (topic, msg_key, headers, payload, status, attempts, last_error, created_at, next_attempt_at, sent_at) VALUES (?, ?, ?, ?, ?, 0, '', ?, ?, 0)")
+	now := time.Now()
+	for _, msg := range msgs {
+		if nil == msg || "" == msg.Topic {
+			return errors.New("outbox message without topic")
+		}
+		headers := ""
+		if len(msg.Headers) > 0 {
+			content, err := json.Marshal(msg.Headers)
+			if nil != err {
+				return err
+			}
+			headers = string(content)
+		}
+		createdAt := msg.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		ms := createdAt.UnixNano() / int64(time.Millisecond)
+		if _, err := tx.ExecContext(ctx, query, msg.Topic, msg.Key, headers, msg.Payload, StatusPending, ms, ms); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Relay publishes the pending messages in batches until none due, returns the count of messages published.
+// The messages with the same topic and key are published in order, the following ones wait while an earlier one
+// is waiting for retry
+func (o *Outbox) Relay(ctx context.Context, publisher Publisher) (int, error) {
+	total := 0
+	for {
+		msgs, err := o.pending(ctx)
+		if nil != err {
+			return total, err
+		}
+		sent := 0
+		blocked := map[string]bool{}
+		for _, msg := range msgs {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+			orderKey := msg.Topic + "\x00" + msg.Key
+			if "" != msg.Key && blocked[orderKey] {
+				continue
+			}
+			if err = publisher.Publish(ctx, msg); nil != err {
+				blocked[orderKey] = true
+				if err = o.markFailed(ctx, msg, err); nil != err {
+					return total, err
+				}
+				continue
+			}
+			if err = o.markSent(ctx, msg); nil != err {
+				return total, err
+			}
+			sent++
+		}
+		total += sent
+		// 失败的消息推迟了下次发送时间，后续批次不会重复查询到
+		if len(msgs) < o.batchSize {
+			return total, nil
+		}
+	}
+}
+
+// Schedule relays the messages by the scheduler every interval
+func (o *Outbox) Schedule(s *scheduler.Scheduler, name string, interval time.Duration, publisher Publisher, options ...scheduler.JobOption) error {
+	return s.AddInterval(name, interval, 0, func(ctx context.Context) error {
+		_, err := o.Relay(ctx, publisher)
+		return err
+	}, options...)
+}
+
+// Pending the count of messages waiting for relaying
+func (o *Outbox) Pending(ctx context.Context) (int64, error) {
+	var count int64
+	err := o.db.QueryRowContext(ctx, o.rebind("SELECT COUNT(*) FROM "+o.table+" WHERE status = ?"), StatusPending).Scan(&count)
+	return count, err
+}
+
+// Failed the messages given up after the max attempts
+func (o *Outbox) Failed(ctx context.Context, limit int) ([]*Message, error) {
+	return o.query(ctx, o.rebind("SELECT id, topic, msg_key, headers, payload, status, attempts, last_error, created_at FROM "+o.table+" WHERE status = ? ORDER BY id LIMIT "+strconv.Itoa(limit)), StatusFailed)
+}
+
+// Retry resets the failed message to be relayed again
+func (o *Outbox) Retry(ctx context.Context, id int64) error {
+	_, err := o.db.ExecContext(ctx, o.rebind("UPDATE "+o.table+" SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?"), StatusPending, o.millis(time.Now()), id, StatusFailed)
+	return err
+}
+
+// Purge deletes the sent messages created before the time, returns the count of deleted
+func (o *Outbox) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := o.db.ExecContext(ctx, o.rebind("DELETE FROM "+o.table+" WHERE status = ? AND created_at < ?"), StatusSent, o.millis(before))
+	if nil != err {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// pending the due messages of which no earlier message with the same topic and key is waiting for retry
+func (o *Outbox) pending(ctx context.Context) ([]*Message, error) {
+	now := o.millis(time.Now())
+	return o.query(ctx, o.rebind("SELECT id, topic, msg_key, headers, payload, status, attempts, last_error, created_at FROM "+o.table+" m "+
+		"WHERE status = ? AND next_attempt_at <= ? AND NOT EXISTS (SELECT 1 FROM "+o.table+" e "+
+		"WHERE e.topic = m.topic AND e.msg_key = m.msg_key AND e.msg_key <> '' AND e.status = ? AND e.id < m.id AND e.next_attempt_at > ?) "+
+		"ORDER BY id LIMIT "+strconv.Itoa(o.batchSize)), StatusPending, now, StatusPending, now)
+}
+
+func (o *Outbox) query(ctx context.Context, query string, args ...interface{}) ([]*Message, error) {
+	rows, err := o.db.QueryContext(ctx, query, args...)
+	if nil != err {
+		return nil, err
+	}
+	defer rows.Close()
+	msgs := []*Message{}
+	for rows.Next() {
+		msg := &Message{}
+		var headers, lastError sql.NullString
+		var createdAt int64
+		if err = rows.Scan(&msg.ID, &msg.Topic, &msg.Key, &headers, &msg.Payload, &msg.Status, &msg.Attempts, &lastError, &createdAt); nil != err {
+			return nil, err
+		}
+		if "" != headers.String {
+			if err = json.Unmarshal([]byte(headers.String), &msg.Headers); nil != err {
+				logger.Error.Printf("outbox message:%d with invalid headers:%s", msg.ID, headers.String)
+			}
+		}
+		msg.LastError = lastError.String
+		msg.CreatedAt = time.Unix(0, createdAt*int64(time.Millisecond))
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+func (o *Outbox) markSent(ctx context.Context, msg *Message) error {
+	_, err := o.db.ExecContext(ctx, o.rebind("UPDATE "+o.table+" SET status = ?, attempts = ?, sent_at = ? WHERE id = ?"), StatusSent, msg.Attempts+1, o.millis(time.Now()), msg.ID)
+	return err
+}
+
+func (o *Outbox) markFailed(ctx context.Context, msg *Message, cause error) error {
+	attempts := msg.Attempts + 1
+	status := StatusPending
+	backoff := o.retryInterval
+	for i := 1; i < attempts && backoff < DefaultMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > DefaultMaxRetryBackoff {
+		backoff = DefaultMaxRetryBackoff
+	}
+	if attempts >= o.maxAttempts {
+		status = StatusFailed
+		logger.Error.Printf("outbox publishing message:%d to topic:%s failed with error:%v, given up after %d attempts", msg.ID, msg.Topic, cause, attempts)
+	} else {
+		logger.Warning.Printf("outbox publishing message:%d to topic:%s failed with error:%v, retry after %v", msg.ID, msg.Topic, cause, backoff)
+	}
+	_, err := o.db.ExecContext(ctx, o.rebind("UPDATE "+o.table+" SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?"), status, attempts, cause.Error(), o.millis(time.Now().Add(backoff)), msg.ID)
+	return err
+}
+
+func (o *Outbox) millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// rebind replaces the ? placeholders by the placeholders of driver
+func (o *Outbox) rebind(query string) string {
+	if dboptions.DriverPostgres != o.driverName {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if '?' == c {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
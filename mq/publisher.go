@@ -0,0 +1,207 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/mq/nats"
+	"github.com/libpub/golib/mq/pulsar"
+)
+
+// Content types negotiated while the message content type not specified
+const (
+	ContentTypeJSON   = mqenv.ContentTypeJSON
+	ContentTypeText   = mqenv.ContentTypeText
+	ContentTypeBinary = mqenv.ContentTypeBinary
+
+	HeaderContentType     = "Content-Type"
+	HeaderContentEncoding = "Content-Encoding"
+	HeaderCorrelationID   = "Correlation-Id"
+	HeaderReplyTo         = "Reply-To"
+	HeaderMessageID       = "Message-Id"
+	HeaderAppID           = "App-Id"
+	HeaderUserID          = "User-Id"
+)
+
+// driverReservedHeaders the header or property names that drivers carry the message fields with,
+// they are copied into the consumer message headers by these drivers as well
+var driverReservedHeaders = map[string][]string{
+	mqenv.DriverTypeNats:   {nats.HeaderCorrelationID, nats.HeaderReplyTo, nats.HeaderMessageID, nats.HeaderAppID, nats.HeaderUserID, nats.HeaderContentType, nats.HeaderContentEncoding},
+	mqenv.DriverTypePulsar: {pulsar.PropertyCorrelationID, pulsar.PropertyReplyTo, pulsar.PropertyMessageID, pulsar.PropertyAppID, pulsar.PropertyUserID, pulsar.PropertyContentType, pulsar.PropertyContentEncoding, "RoutingKey"},
+}
+
+// Publish publishes a message by the driver of mq category, the destination is resolved by routes config if not specified:
+//   - RoutingKey matches a name in routingKeys config would be replaced by the configured routing key
+//   - Exchange of kafka message defaults to the configured topic
+//   - ContentType defaults to the Content-Type header or detected by body
+//   - Content-Encoding, Correlation-Id, Reply-To, Message-Id, App-Id and User-Id headers are moved into the message fields,
+//     which every driver carries natively
+func Publish(mqCategory string, publishMsg mqenv.MQPublishMessage) error {
+	mqConfig := GetMQConfig(mqCategory)
+	if nil == mqConfig {
+		return fmt.Errorf("publish MQ with invalid category:%s", mqCategory)
+	}
+	routePublishMessage(getMQCategoryDriverType(mqCategory), mqConfig, &publishMsg)
+	return PublishMQ(mqCategory, &publishMsg)
+}
+
+// Query publishes a message by the driver of mq category and waiting the response, the destination is resolved as Publish
+func Query(mqCategory string, publishMsg mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	mqConfig := GetMQConfig(mqCategory)
+	if nil == mqConfig {
+		return nil, fmt.Errorf("query MQ with invalid category:%s", mqCategory)
+	}
+	routePublishMessage(getMQCategoryDriverType(mqCategory), mqConfig, &publishMsg)
+	return QueryMQ(mqCategory, &publishMsg)
+}
+
+// Subscribe consumes queue or topic by the driver of mq category, the queue defaults to the configured queue or topic,
+// the ContentType of consumer message would be filled by the Content-Type header or detected by body if not specified,
+// the headers of the message fields are mapped as Publish and the driver reserved headers are removed
+func Subscribe(mqCategory string, queue string, handler mqenv.MQConsumerCallback) error {
+	mqConfig := GetMQConfig(mqCategory)
+	if nil == mqConfig {
+		return fmt.Errorf("subscribe MQ with invalid category:%s", mqCategory)
+	}
+	if nil == handler {
+		return fmt.Errorf("subscribe MQ category:%s with nil handler", mqCategory)
+	}
+	mqDriver := getMQCategoryDriverType(mqCategory)
+	if "" == queue {
+		queue = defaultSubscribeQueue(mqDriver, mqConfig)
+	}
+	consumeProxy := &mqenv.MQConsumerProxy{
+		Queue:       queue,
+		ConsumerTag: queue,
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			mapConsumerHeaders(mqDriver, &msg)
+			if "" == msg.ContentType {
+				msg.ContentType = negotiateContentType(msg.Headers, msg.Body)
+			}
+			return handler(msg)
+		},
+	}
+	return ConsumeMQ(mqCategory, consumeProxy)
+}
+
+func routePublishMessage(mqDriver string, mqConfig *Config, publishMsg *mqenv.MQPublishMessage) {
+	if "" != publishMsg.RoutingKey && nil != mqConfig.RoutingKeys {
+		if routingKey, ok := mqConfig.RoutingKeys[publishMsg.RoutingKey]; ok {
+			publishMsg.RoutingKey = routingKey
+		}
+	}
+	switch mqDriver {
+	case mqenv.DriverTypeKafka:
+		if "" == publishMsg.Exchange {
+			publishMsg.Exchange = mqConfig.Topic
+		}
+	case mqenv.DriverTypeAMQP:
+	default:
+		if "" == publishMsg.RoutingKey {
+			publishMsg.RoutingKey = mqConfig.Topic
+		}
+	}
+	mapPublishHeaders(publishMsg)
+	if "" == publishMsg.ContentType {
+		publishMsg.ContentType = negotiateContentType(publishMsg.Headers, publishMsg.Body)
+	}
+}
+
+// messageFieldOfHeader returns the message field that the header name maps to, the name is matched case-insensitively
+// with or without the dash, so both the generic header name Correlation-Id and the driver property name CorrelationId match
+func messageFieldOfHeader(name string, contentType, contentEncoding, correlationID, replyTo, messageID, appID, userID *string) *string {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "contenttype":
+		return contentType
+	case "contentencoding":
+		return contentEncoding
+	case "correlationid":
+		return correlationID
+	case "replyto":
+		return replyTo
+	case "messageid":
+		return messageID
+	case "appid":
+		return appID
+	case "userid":
+		return userID
+	}
+	return nil
+}
+
+// mapPublishHeaders moves the headers of message fields into the fields not specified
+func mapPublishHeaders(publishMsg *mqenv.MQPublishMessage) {
+	if len(publishMsg.Headers) == 0 {
+		return
+	}
+	headers := make(map[string]string, len(publishMsg.Headers))
+	for k, v := range publishMsg.Headers {
+		field := messageFieldOfHeader(k, &publishMsg.ContentType, &publishMsg.ContentEncoding, &publishMsg.CorrelationID, &publishMsg.ReplyTo, &publishMsg.MessageID, &publishMsg.AppID, &publishMsg.UserID)
+		if nil == field {
+			headers[k] = v
+		} else if "" == *field {
+			*field = v
+		}
+	}
+	// 复制一份，避免修改调用方的headers
+	publishMsg.Headers = headers
+}
+
+// mapConsumerHeaders fills the fields not specified by the headers of message fields, and removes the
+// reserved headers of the driver so that handlers get the same headers whichever driver the message comes from
+func mapConsumerHeaders(mqDriver string, msg *mqenv.MQConsumerMessage) {
+	if len(msg.Headers) == 0 {
+		return
+	}
+	headers := make(map[string]string, len(msg.Headers))
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	for _, name := range driverReservedHeaders[mqDriver] {
+		delete(headers, name)
+	}
+	for k, v := range headers {
+		field := messageFieldOfHeader(k, &msg.ContentType, &msg.ContentEncoding, &msg.CorrelationID, &msg.ReplyTo, &msg.MessageID, &msg.AppID, &msg.UserID)
+		if nil != field {
+			if "" == *field {
+				*field = v
+			}
+			delete(headers, k)
+		}
+	}
+	msg.Headers = headers
+}
+
+func defaultSubscribeQueue(mqDriver string, mqConfig *Config) string {
+	switch mqDriver {
+	case mqenv.DriverTypeAMQP:
+		return mqConfig.Queue
+	default:
+		if "" != mqConfig.Topic {
+			return mqConfig.Topic
+		}
+		return mqConfig.Queue
+	}
+}
+
+// negotiateContentType returns the Content-Type header, or detects by body
+func negotiateContentType(headers map[string]string, body []byte) string {
+	for k, v := range headers {
+		if "" != v && strings.EqualFold(k, HeaderContentType) {
+			return v
+		}
+	}
+	if len(body) == 0 {
+		return ""
+	}
+	if json.Valid(body) {
+		return ContentTypeJSON
+	}
+	if utf8.Valid(body) {
+		return ContentTypeText
+	}
+	return ContentTypeBinary
+}
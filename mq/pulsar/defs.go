@@ -10,12 +10,13 @@ import (
 
 // Constants
 const (
-	PropertyCorrelationID = "CorrelationId"
-	PropertyReplyTo       = "ReplyTo"
-	PropertyMessageID     = "MessageId"
-	PropertyAppID         = "AppId"
-	PropertyUserID        = "UserId"
-	PropertyContentType   = "ContentType"
+	PropertyCorrelationID   = "CorrelationId"
+	PropertyReplyTo         = "ReplyTo"
+	PropertyMessageID       = "MessageId"
+	PropertyAppID           = "AppId"
+	PropertyUserID          = "UserId"
+	PropertyContentType     = "ContentType"
+	PropertyContentEncoding = "ContentEncoding"
 )
 
 // Config Pulsar MQ configuration
@@ -71,38 +71,38 @@ func (l *pulsarLogWrapper) entry() pulsarlog.Entry {
 
 func (l *pulsarLogWrapper) Debug(args ...interface{}) {
 	if l.enableDebugLogger {
-		logger.Debug.Output(3, fmt.Sprint(args...))
+		logger.Output(logger.LogLevelDebug, 3, fmt.Sprint(args...))
 	}
 }
 
 func (l *pulsarLogWrapper) Info(args ...interface{}) {
-	logger.Info.Output(3, fmt.Sprint(args...))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprint(args...))
 }
 
 func (l *pulsarLogWrapper) Warn(args ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprint(args...))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprint(args...))
 }
 
 func (l *pulsarLogWrapper) Error(args ...interface{}) {
-	logger.Error.Output(3, fmt.Sprint(args...))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprint(args...))
 }
 
 func (l *pulsarLogWrapper) Debugf(format string, args ...interface{}) {
 	if l.enableDebugLogger {
-		logger.Debug.Output(3, fmt.Sprintf(format, args...))
+		logger.Output(logger.LogLevelDebug, 3, fmt.Sprintf(format, args...))
 	}
 }
 
 func (l *pulsarLogWrapper) Infof(format string, args ...interface{}) {
-	logger.Info.Output(3, fmt.Sprintf(format, args...))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprintf(format, args...))
 }
 
 func (l *pulsarLogWrapper) Warnf(format string, args ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprintf(format, args...))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprintf(format, args...))
 }
 
 func (l *pulsarLogWrapper) Errorf(format string, args ...interface{}) {
-	logger.Error.Output(3, fmt.Sprintf(format, args...))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprintf(format, args...))
 }
 
 func (l pulsarLogEntry) WithFields(fs pulsarlog.Fields) pulsarlog.Entry {
@@ -150,36 +150,36 @@ func (l pulsarLogEntry) fieldsContent() string {
 
 func (l pulsarLogEntry) Debug(args ...interface{}) {
 	if l.enableDebugLogger {
-		logger.Debug.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
+		logger.Output(logger.LogLevelDebug, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
 	}
 }
 
 func (l pulsarLogEntry) Info(args ...interface{}) {
-	logger.Info.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
 }
 
 func (l pulsarLogEntry) Warn(args ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
 }
 
 func (l pulsarLogEntry) Error(args ...interface{}) {
-	logger.Error.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprint(args...)))
 }
 
 func (l pulsarLogEntry) Debugf(format string, args ...interface{}) {
 	if l.enableDebugLogger {
-		logger.Debug.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
+		logger.Output(logger.LogLevelDebug, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
 	}
 }
 
 func (l pulsarLogEntry) Infof(format string, args ...interface{}) {
-	logger.Info.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
 }
 
 func (l pulsarLogEntry) Warnf(format string, args ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
 }
 
 func (l pulsarLogEntry) Errorf(format string, args ...interface{}) {
-	logger.Error.Output(3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprint(l.fieldsContent(), " ", fmt.Sprintf(format, args...)))
 }
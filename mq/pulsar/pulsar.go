@@ -543,6 +543,9 @@ func preparePublishMessageProperties(properties map[string]string, pm *mqenv.MQP
 	if "" != pm.ContentType {
 		properties[PropertyContentType] = pm.ContentType
 	}
+	if "" != pm.ContentEncoding {
+		properties[PropertyContentEncoding] = pm.ContentEncoding
+	}
 	return properties
 }
 
@@ -664,6 +667,7 @@ func (r *PulsarMQ) handleConsumeCallback(consumer pulsar.Consumer, msg pulsar.Me
 			m.AppID, _ = properties[PropertyAppID]
 			m.UserID, _ = properties[PropertyUserID]
 			m.ContentType, _ = properties[PropertyContentType]
+			m.ContentEncoding, _ = properties[PropertyContentEncoding]
 			m.RoutingKey, _ = properties["RoutingKey"]
 		}
 
@@ -827,3 +831,31 @@ func (r *PulsarMQ) generatePublishMessageByDelivery(msg *mqenv.MQConsumerMessage
 	}
 	return pub
 }
+
+// DriverType returns mqenv.DriverTypePulsar
+func (r *PulsarMQ) DriverType() string {
+	return mqenv.DriverTypePulsar
+}
+
+// PublishMessage puts the message into publishing channel
+func (r *PulsarMQ) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to pulsar")
+	}
+	r.Publish <- pm
+	return nil
+}
+
+// ConsumeMessage subscribes the topic of consumeProxy
+func (r *PulsarMQ) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume pulsar with nil consumer proxy")
+	}
+	r.Consume <- consumeProxy
+	return nil
+}
+
+// QueryMessage publishes a message and waiting the response
+func (r *PulsarMQ) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	return r.QueryRPC(pm)
+}
@@ -655,7 +655,7 @@ func (r *RabbitMQ) publish(pm *mqenv.MQPublishMessage) error {
 		amqp.Publishing{
 			Headers:         headers,
 			ContentType:     pm.ContentType,
-			ContentEncoding: "",
+			ContentEncoding: pm.ContentEncoding,
 			Body:            pm.Body,
 			CorrelationId:   pm.CorrelationID,
 			ReplyTo:         pm.ReplyTo,
@@ -1095,21 +1095,22 @@ func GenerateRabbitMQConsumerProxy(consumeProxy *mqenv.MQConsumerProxy, exchange
 
 func generateMQResponseMessage(d *amqp.Delivery, exchangeName string) *mqenv.MQConsumerMessage {
 	msg := &mqenv.MQConsumerMessage{
-		Driver:        mqenv.DriverTypeAMQP,
-		Queue:         d.RoutingKey,
-		CorrelationID: d.CorrelationId,
-		ConsumerTag:   d.ConsumerTag,
-		ReplyTo:       d.ReplyTo,
-		MessageID:     d.MessageId,
-		AppID:         d.AppId,
-		UserID:        d.UserId,
-		ContentType:   d.ContentType,
-		Exchange:      d.Exchange,
-		RoutingKey:    d.RoutingKey,
-		Timestamp:     d.Timestamp,
-		Body:          d.Body,
-		Headers:       map[string]string{},
-		BindData:      d,
+		Driver:          mqenv.DriverTypeAMQP,
+		Queue:           d.RoutingKey,
+		CorrelationID:   d.CorrelationId,
+		ConsumerTag:     d.ConsumerTag,
+		ReplyTo:         d.ReplyTo,
+		MessageID:       d.MessageId,
+		AppID:           d.AppId,
+		UserID:          d.UserId,
+		ContentType:     d.ContentType,
+		ContentEncoding: d.ContentEncoding,
+		Exchange:        d.Exchange,
+		RoutingKey:      d.RoutingKey,
+		Timestamp:       d.Timestamp,
+		Body:            d.Body,
+		Headers:         map[string]string{},
+		BindData:        d,
 	}
 	if "" == msg.Exchange {
 		msg.Exchange = exchangeName
@@ -1119,3 +1120,35 @@ func generateMQResponseMessage(d *amqp.Delivery, exchangeName string) *mqenv.MQC
 	}
 	return msg
 }
+
+// DriverType returns mqenv.DriverTypeAMQP
+func (r *RabbitMQ) DriverType() string {
+	return mqenv.DriverTypeAMQP
+}
+
+// PublishMessage puts the message into publishing channel
+func (r *RabbitMQ) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to rabbitmq")
+	}
+	r.Publish <- pm
+	return nil
+}
+
+// ConsumeMessage subscribes the queue of consumeProxy
+func (r *RabbitMQ) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume rabbitmq with nil consumer proxy")
+	}
+	exchangeName := ""
+	if nil != r.Config {
+		exchangeName = r.Config.ExchangeName
+	}
+	r.Consume <- GenerateRabbitMQConsumerProxy(consumeProxy, exchangeName)
+	return nil
+}
+
+// QueryMessage publishes a message and waiting the response
+func (r *RabbitMQ) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	return r.QueryRPC(pm)
+}
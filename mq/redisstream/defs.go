@@ -0,0 +1,77 @@
+package redisstream
+
+import (
+	"sync"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// Constants
+const (
+	FieldBody            = "body"
+	FieldCorrelationID   = "CorrelationId"
+	FieldReplyTo         = "ReplyTo"
+	FieldMessageID       = "MessageId"
+	FieldAppID           = "AppId"
+	FieldUserID          = "UserId"
+	FieldContentType     = "ContentType"
+	FieldContentEncoding = "ContentEncoding"
+	FieldHeaderPrefix    = "h:"
+
+	DefaultBlockSeconds        = 5
+	DefaultClaimIdleSeconds    = 60
+	DefaultBatchSize           = 10
+	DefaultQueryTimeoutSeconds = 30
+	DefaultMaxDeliveries       = 10
+)
+
+// Config Redis Streams MQ configuration
+type Config struct {
+	ConnConfigName string
+	Stream         string // default stream key for publishing and consuming
+	Group          string // consumer group name, defaults to the instance name
+	Consumer       string // consumer name in the group, defaults to hostname
+	// 消息类型:
+	//direct:组播,订阅同一个stream的消费者使用相同的消费者组，一条消息只会被组内一个消费者接收
+	//fanout:广播,每个实例使用独立的消费者组，所有实例都会收到信息
+	MessageType      string `yaml:"messageType" json:"messageType"`
+	MaxLen           int64  // approximate max length of stream trimmed on XADD, 0 means no trimming
+	BatchSize        int64  // max messages read by each XREADGROUP
+	ClaimIdleSeconds int    // pending messages idle longer than this would be claimed by this consumer
+	MaxDeliveries    int64  // pending messages delivered more than this would be moved to DeadLetterStream, defaults to DefaultMaxDeliveries
+	DeadLetterStream string // stream key receives the messages exceeded MaxDeliveries, empty means dropping them
+}
+
+// RedisStreamMQ instance
+type RedisStreamMQ struct {
+	Name       string
+	config     *Config
+	connConfig *mqenv.MQConnectorConfig
+	client     redis.UniversalClient
+	group      string
+	consumer   string
+	consumers  map[string]chan struct{}
+	streams    map[string]bool // streams that the consumer group created on
+	wg         sync.WaitGroup
+	m          sync.RWMutex
+}
+
+// Equals check if equals
+func (me *Config) Equals(to *Config) bool {
+	return (me.ConnConfigName == to.ConnConfigName &&
+		me.Stream == to.Stream &&
+		me.Group == to.Group &&
+		me.Consumer == to.Consumer &&
+		me.MessageType == to.MessageType &&
+		me.MaxLen == to.MaxLen &&
+		me.BatchSize == to.BatchSize &&
+		me.ClaimIdleSeconds == to.ClaimIdleSeconds &&
+		me.MaxDeliveries == to.MaxDeliveries &&
+		me.DeadLetterStream == to.DeadLetterStream)
+}
+
+// IsBroadcast check if the configure is fanout
+func (me *Config) IsBroadcast() bool {
+	return "fanout" == me.MessageType
+}
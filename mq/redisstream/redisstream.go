@@ -0,0 +1,572 @@
+package redisstream
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/utils"
+)
+
+// Variables
+var (
+	redisStreamInsts     = map[string]*RedisStreamMQ{}
+	redisStreamInstMutex = sync.RWMutex{}
+)
+
+// InitRedisStreamMQ init
+func InitRedisStreamMQ(mqConnName string, connCfg *mqenv.MQConnectorConfig, streamCfg *Config) (*RedisStreamMQ, error) {
+	redisStreamInstMutex.Lock()
+	defer redisStreamInstMutex.Unlock()
+	inst, ok := redisStreamInsts[mqConnName]
+	if ok && !inst.config.Equals(streamCfg) {
+		inst.Close()
+		ok = false
+	}
+	if !ok {
+		inst = NewRedisStreamMQ(mqConnName, connCfg, streamCfg)
+		logger.Info.Printf("Initializing redis stream instance:%s", inst.Name)
+		err := inst.init()
+		if nil != err {
+			return nil, err
+		}
+		redisStreamInsts[mqConnName] = inst
+	}
+	return inst, nil
+}
+
+// GetRedisStreamMQ get
+func GetRedisStreamMQ(name string) (*RedisStreamMQ, error) {
+	redisStreamInstMutex.RLock()
+	inst, ok := redisStreamInsts[name]
+	redisStreamInstMutex.RUnlock()
+	if ok {
+		return inst, nil
+	}
+	return nil, fmt.Errorf("RedisStreamMQ instance by %s not found", name)
+}
+
+// NewRedisStreamMQ with parameters
+func NewRedisStreamMQ(mqConnName string, connCfg *mqenv.MQConnectorConfig, streamCfg *Config) *RedisStreamMQ {
+	r := &RedisStreamMQ{
+		Name:       mqConnName,
+		config:     streamCfg,
+		connConfig: connCfg,
+		group:      streamCfg.Group,
+		consumer:   streamCfg.Consumer,
+		consumers:  map[string]chan struct{}{},
+		streams:    map[string]bool{},
+		m:          sync.RWMutex{},
+	}
+	if "" == r.consumer {
+		hostName, err := os.Hostname()
+		if nil != err {
+			logger.Error.Printf("RedisStreamMQ %s initialize while get hostname failed with error:%v", r.Name, err)
+			hostName = utils.RandomString(8)
+		}
+		r.consumer = fmt.Sprintf("%s-%d", hostName, os.Getpid())
+	}
+	if streamCfg.IsBroadcast() {
+		r.group = fmt.Sprintf("%s-%s", r.Name, utils.GenLoweruuid())
+	} else if "" == r.group {
+		r.group = r.Name
+	}
+	return r
+}
+
+// init creates the redis client, a failed ping is only logged as go-redis reconnects on demand
+func (r *RedisStreamMQ) init() error {
+	if mqenv.DriverTypeRedisStream != r.connConfig.Driver {
+		logger.Error.Printf("Initialize redis stream connection by configure:%s failed, the configure driver:%s does not fit.", r.Name, r.connConfig.Driver)
+		return errors.New("Invalid driver for redis stream")
+	}
+	cnf := r.connConfig
+	addrs := r.formatAddrs()
+	if len(addrs) > 1 {
+		r.client = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:       addrs,
+			Password:    cnf.Password,
+			DialTimeout: r.dialTimeout(),
+		})
+	} else {
+		db := 0
+		if "" != cnf.Path {
+			index, err := strconv.Atoi(strings.Trim(cnf.Path, "/"))
+			if nil != err {
+				return fmt.Errorf("invalid redis db index:%s", cnf.Path)
+			}
+			db = index
+		}
+		r.client = redis.NewClient(&redis.Options{
+			Addr:        addrs[0],
+			Password:    cnf.Password,
+			DB:          db,
+			DialTimeout: r.dialTimeout(),
+		})
+	}
+	_, err := r.client.Ping().Result()
+	if nil != err {
+		logger.Warning.Printf("Connecting redis stream %s with %v failed with error:%v, would retry while using", r.Name, addrs, err)
+	} else {
+		logger.Info.Printf("Connecting redis stream %s with %v succeed", r.Name, addrs)
+	}
+	return nil
+}
+
+func (r *RedisStreamMQ) formatAddrs() []string {
+	cnf := r.connConfig
+	addrs := []string{}
+	for _, host := range strings.Split(cnf.Host, ",") {
+		host = strings.TrimSpace(host)
+		if "" == host {
+			continue
+		}
+		if !strings.Contains(host, ":") && cnf.Port > 0 {
+			host = fmt.Sprintf("%s:%d", host, cnf.Port)
+		}
+		addrs = append(addrs, host)
+	}
+	if len(addrs) == 0 {
+		addrs = append(addrs, "localhost:6379")
+	}
+	return addrs
+}
+
+func (r *RedisStreamMQ) dialTimeout() time.Duration {
+	if r.connConfig.Timeout > 0 {
+		return time.Duration(r.connConfig.Timeout) * time.Second
+	}
+	return 5 * time.Second
+}
+
+// Close stops all consumers and closes the client, the per instance consumer groups of fanout mode are destroyed
+func (r *RedisStreamMQ) Close() error {
+	r.m.Lock()
+	for key, stop := range r.consumers {
+		close(stop)
+		delete(r.consumers, key)
+	}
+	streams := r.streams
+	r.streams = map[string]bool{}
+	r.m.Unlock()
+	r.wg.Wait()
+	if nil == r.client {
+		return nil
+	}
+	if r.config.IsBroadcast() {
+		for stream := range streams {
+			err := r.client.XGroupDestroy(stream, r.group).Err()
+			if nil != err {
+				logger.Error.Printf("RedisStreamMQ %s destroy group:%s on stream:%s failed with error:%v", r.Name, r.group, stream, err)
+			}
+		}
+	}
+	logger.Info.Printf("RedisStreamMQ connection:%s closing", r.Name)
+	return r.client.Close()
+}
+
+// DriverType returns mqenv.DriverTypeRedisStream
+func (r *RedisStreamMQ) DriverType() string {
+	return mqenv.DriverTypeRedisStream
+}
+
+// PublishMessage appends the message to stream pm.RoutingKey or the configured stream
+func (r *RedisStreamMQ) PublishMessage(pm *mqenv.MQPublishMessage) error {
+	if nil == pm {
+		return errors.New("publish nil message to redis stream")
+	}
+	_, err := r.publish(pm)
+	if nil != pm.PublishStatus {
+		status := mqenv.MQEvent{
+			Code:    mqenv.MQEventCodeOk,
+			Label:   pm.EventLabel,
+			Message: "Publish success",
+		}
+		if nil != err {
+			status.Code = mqenv.MQEventCodeFailed
+			status.Message = err.Error()
+		}
+		pm.PublishStatus <- status
+	}
+	return err
+}
+
+// ConsumeMessage reads stream consumeProxy.Queue or the configured stream with consumer group
+func (r *RedisStreamMQ) ConsumeMessage(consumeProxy *mqenv.MQConsumerProxy) error {
+	if nil == consumeProxy {
+		return errors.New("consume redis stream with nil consumer proxy")
+	}
+	stream := consumeProxy.Queue
+	if "" == stream {
+		stream = r.config.Stream
+	}
+	if "" == stream {
+		return fmt.Errorf("RedisStreamMQ %s consume without stream", r.Name)
+	}
+	startID := "0"
+	if r.config.IsBroadcast() {
+		startID = "$"
+	}
+	err := r.client.XGroupCreateMkStream(stream, r.group, startID).Err()
+	if nil != err && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		logger.Error.Printf("RedisStreamMQ %s create group:%s on stream:%s failed with error:%v", r.Name, r.group, stream, err)
+		return err
+	}
+	key := stream + "-" + consumeProxy.ConsumerTag
+	r.m.Lock()
+	r.streams[stream] = true
+	if _, ok := r.consumers[key]; ok {
+		r.m.Unlock()
+		return nil
+	}
+	stop := make(chan struct{})
+	r.consumers[key] = stop
+	r.wg.Add(1)
+	r.m.Unlock()
+	go r.handleConsumes(stream, consumeProxy, stop)
+	logger.Info.Printf("Now consuming mq(%s) with stream:%s group:%s consumer:%s ...", r.Name, stream, r.group, r.consumer)
+	if nil != consumeProxy.Ready {
+		select {
+		case consumeProxy.Ready <- true:
+		default:
+		}
+	}
+	return nil
+}
+
+// QueryMessage publishes a message and waiting the response on a temporary reply stream
+func (r *RedisStreamMQ) QueryMessage(pm *mqenv.MQPublishMessage) (*mqenv.MQConsumerMessage, error) {
+	if nil == pm {
+		return nil, errors.New("query redis stream with nil message")
+	}
+	if "" == pm.CorrelationID {
+		pm.CorrelationID = utils.GenLoweruuid()
+	}
+	timeoutSeconds := pm.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = DefaultQueryTimeoutSeconds
+	}
+	replyStream := "rpc:" + r.Name + ":" + pm.CorrelationID
+	pm.ReplyTo = replyStream
+	defer r.client.Del(replyStream)
+	err := r.PublishMessage(pm)
+	if nil != err {
+		return nil, err
+	}
+	streams, err := r.client.XRead(&redis.XReadArgs{
+		Streams: []string{replyStream, "0"},
+		Count:   1,
+		Block:   time.Duration(timeoutSeconds) * time.Second,
+	}).Result()
+	if nil != err || len(streams) == 0 || len(streams[0].Messages) == 0 {
+		pm.OnClosed()
+		if nil == err || redis.Nil == err {
+			return nil, fmt.Errorf("Query timeout")
+		}
+		return nil, err
+	}
+	resp := r.generateConsumerMessage(replyStream, streams[0].Messages[0], "")
+	if logger.IsDebugEnabled() {
+		logger.Trace.Printf("RedisStreamMQ %s Got response %s", r.Name, utils.HumanByteText(resp.Body))
+	}
+	return &resp, nil
+}
+
+// Pending returns the number of delivered but not acked messages of stream in the consumer group
+func (r *RedisStreamMQ) Pending(stream string) (int64, error) {
+	if "" == stream {
+		stream = r.config.Stream
+	}
+	pending, err := r.client.XPending(stream, r.group).Result()
+	if nil != err {
+		return 0, err
+	}
+	return pending.Count, nil
+}
+
+func (r *RedisStreamMQ) publish(pm *mqenv.MQPublishMessage) (string, error) {
+	stream := pm.RoutingKey
+	if "" == stream {
+		stream = r.config.Stream
+	}
+	if "" == stream {
+		return "", fmt.Errorf("RedisStreamMQ %s publish message without stream", r.Name)
+	}
+	args := &redis.XAddArgs{
+		Stream:       stream,
+		MaxLenApprox: r.config.MaxLen,
+		Values:       prepareMessageFields(pm),
+	}
+	if logger.IsDebugEnabled() {
+		logger.Trace.Printf("RedisStreamMQ %s publishing message(%s) to %s with %dB body (%s)", r.Name, pm.CorrelationID, stream, len(pm.Body), utils.HumanByteText(pm.Body))
+	}
+	id, err := r.client.XAdd(args).Result()
+	if nil != err {
+		logger.Error.Printf("RedisStreamMQ %s publishing message to %s failed with error:%v", r.Name, stream, err)
+		return "", err
+	}
+	return id, nil
+}
+
+// handleConsumes claims pending messages of dead consumers and reads new messages until stopped
+func (r *RedisStreamMQ) handleConsumes(stream string, consumeProxy *mqenv.MQConsumerProxy, stop chan struct{}) {
+	defer r.wg.Done()
+	claimIdle := time.Duration(r.config.ClaimIdleSeconds) * time.Second
+	if claimIdle <= 0 {
+		claimIdle = DefaultClaimIdleSeconds * time.Second
+	}
+	batchSize := r.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	lastClaimed := time.Time{}
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if time.Since(lastClaimed) >= claimIdle {
+			lastClaimed = time.Now()
+			for _, msg := range r.claimPendings(stream, claimIdle, batchSize) {
+				r.handleConsumeCallback(stream, msg, consumeProxy)
+			}
+		}
+		streams, err := r.client.XReadGroup(&redis.XReadGroupArgs{
+			Group:    r.group,
+			Consumer: r.consumer,
+			Streams:  []string{stream, ">"},
+			Count:    batchSize,
+			Block:    DefaultBlockSeconds * time.Second,
+			NoAck:    consumeProxy.AutoAck,
+		}).Result()
+		if nil != err {
+			if redis.Nil != err {
+				logger.Error.Printf("RedisStreamMQ %s consuming stream:%s failed with error:%v", r.Name, stream, err)
+				select {
+				case <-stop:
+					return
+				case <-time.After(mqenv.MQReconnectSeconds * time.Second):
+				}
+			}
+			continue
+		}
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				r.handleConsumeCallback(stream, msg, consumeProxy)
+			}
+		}
+	}
+}
+
+// claimPendings takes over the messages that were delivered to other consumers but not acked for idle duration,
+// messages delivered more than MaxDeliveries times are moved to the dead letter stream instead
+func (r *RedisStreamMQ) claimPendings(stream string, idle time.Duration, count int64) []redis.XMessage {
+	pendings, err := r.client.XPendingExt(&redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  r.group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if nil != err || len(pendings) == 0 {
+		return nil
+	}
+	maxDeliveries := r.config.MaxDeliveries
+	if maxDeliveries <= 0 {
+		maxDeliveries = DefaultMaxDeliveries
+	}
+	ids, deadIDs := splitPendings(pendings, idle, maxDeliveries)
+	for _, id := range deadIDs {
+		r.deadLetter(stream, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	msgs, err := r.client.XClaim(&redis.XClaimArgs{
+		Stream:   stream,
+		Group:    r.group,
+		Consumer: r.consumer,
+		MinIdle:  idle,
+		Messages: ids,
+	}).Result()
+	if nil != err {
+		logger.Error.Printf("RedisStreamMQ %s claim pending messages of stream:%s failed with error:%v", r.Name, stream, err)
+		return nil
+	}
+	if len(msgs) > 0 {
+		logger.Info.Printf("RedisStreamMQ %s claimed %d pending messages of stream:%s", r.Name, len(msgs), stream)
+	}
+	return msgs
+}
+
+// splitPendings returns the idle pending ids to claim and the ids exceeded maxDeliveries
+func splitPendings(pendings []redis.XPendingExt, idle time.Duration, maxDeliveries int64) ([]string, []string) {
+	ids := []string{}
+	deadIDs := []string{}
+	for _, p := range pendings {
+		if p.Idle < idle {
+			continue
+		}
+		if p.RetryCount >= maxDeliveries {
+			deadIDs = append(deadIDs, p.Id)
+		} else {
+			ids = append(ids, p.Id)
+		}
+	}
+	return ids, deadIDs
+}
+
+// deadLetter appends the pending message to the dead letter stream if configured and acks it
+func (r *RedisStreamMQ) deadLetter(stream string, id string) {
+	if "" != r.config.DeadLetterStream {
+		msgs, err := r.client.XRange(stream, id, id).Result()
+		if nil != err {
+			logger.Error.Printf("RedisStreamMQ %s read dead letter message:%s of stream:%s failed with error:%v", r.Name, id, stream, err)
+			return
+		}
+		for _, msg := range msgs {
+			err = r.client.XAdd(&redis.XAddArgs{
+				Stream:       r.config.DeadLetterStream,
+				MaxLenApprox: r.config.MaxLen,
+				Values:       msg.Values,
+			}).Err()
+			if nil != err {
+				logger.Error.Printf("RedisStreamMQ %s move message:%s of stream:%s to dead letter stream:%s failed with error:%v", r.Name, id, stream, r.config.DeadLetterStream, err)
+				return
+			}
+		}
+		logger.Warning.Printf("RedisStreamMQ %s moved message:%s of stream:%s to dead letter stream:%s after max deliveries", r.Name, id, stream, r.config.DeadLetterStream)
+	} else {
+		logger.Warning.Printf("RedisStreamMQ %s dropped message:%s of stream:%s after max deliveries", r.Name, id, stream)
+	}
+	err := r.client.XAck(stream, r.group, id).Err()
+	if nil != err {
+		logger.Error.Printf("RedisStreamMQ %s ack dead letter message:%s of stream:%s failed with error:%v", r.Name, id, stream, err)
+	}
+}
+
+// handleConsumeCallback invokes the callback and acks the message after callback returns,
+// the message keeps pending and would be claimed again if the callback panics, until it exceeds MaxDeliveries
+func (r *RedisStreamMQ) handleConsumeCallback(stream string, msg redis.XMessage, consumeProxy *mqenv.MQConsumerProxy) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Error.Printf("RedisStreamMQ %s consume stream:%s message:%s callback panic:%v", r.Name, stream, msg.ID, err)
+		}
+	}()
+	m := r.generateConsumerMessage(stream, msg, consumeProxy.ConsumerTag)
+	if logger.IsDebugEnabled() {
+		logger.Debug.Printf("RedisStreamMQ %s stream:%s received msg(%s) %s", r.Name, stream, m.CorrelationID, utils.HumanByteText(m.Body))
+	}
+	var resp *mqenv.MQPublishMessage
+	if nil != consumeProxy.Callback {
+		resp = consumeProxy.Callback(m)
+	}
+	if nil != resp && "" != m.ReplyTo {
+		resp.RoutingKey = m.ReplyTo
+		if "" == resp.CorrelationID {
+			resp.CorrelationID = m.CorrelationID
+		}
+		_, err := r.publish(resp)
+		if nil != err {
+			logger.Error.Printf("RedisStreamMQ %s reply message(%s) to %s failed with error:%v", r.Name, m.CorrelationID, m.ReplyTo, err)
+		} else {
+			r.client.Expire(m.ReplyTo, DefaultQueryTimeoutSeconds*time.Second)
+		}
+	}
+	if !consumeProxy.AutoAck {
+		err := r.client.XAck(stream, r.group, msg.ID).Err()
+		if nil != err {
+			logger.Error.Printf("RedisStreamMQ %s ack stream:%s message:%s failed with error:%v", r.Name, stream, msg.ID, err)
+		}
+	}
+}
+
+func (r *RedisStreamMQ) generateConsumerMessage(stream string, msg redis.XMessage, consumerTag string) mqenv.MQConsumerMessage {
+	m := mqenv.MQConsumerMessage{
+		Driver:      mqenv.DriverTypeRedisStream,
+		Queue:       stream,
+		ConsumerTag: consumerTag,
+		Exchange:    stream,
+		RoutingKey:  stream,
+		Timestamp:   parseMessageTime(msg.ID),
+		Headers:     map[string]string{},
+		BindData:    msg,
+	}
+	for k, v := range msg.Values {
+		value := utils.ToString(v)
+		switch k {
+		case FieldBody:
+			m.Body = []byte(value)
+		case FieldCorrelationID:
+			m.CorrelationID = value
+		case FieldReplyTo:
+			m.ReplyTo = value
+		case FieldMessageID:
+			m.MessageID = value
+		case FieldAppID:
+			m.AppID = value
+		case FieldUserID:
+			m.UserID = value
+		case FieldContentType:
+			m.ContentType = value
+		case FieldContentEncoding:
+			m.ContentEncoding = value
+		default:
+			if strings.HasPrefix(k, FieldHeaderPrefix) {
+				m.Headers[k[len(FieldHeaderPrefix):]] = value
+			}
+		}
+	}
+	if "" == m.MessageID {
+		m.MessageID = msg.ID
+	}
+	return m
+}
+
+func prepareMessageFields(pm *mqenv.MQPublishMessage) map[string]interface{} {
+	fields := map[string]interface{}{
+		FieldBody: pm.Body,
+	}
+	for k, v := range pm.Headers {
+		fields[FieldHeaderPrefix+k] = v
+	}
+	if "" != pm.AppID {
+		fields[FieldAppID] = pm.AppID
+	}
+	if "" != pm.UserID {
+		fields[FieldUserID] = pm.UserID
+	}
+	if "" != pm.MessageID {
+		fields[FieldMessageID] = pm.MessageID
+	}
+	if "" != pm.CorrelationID {
+		fields[FieldCorrelationID] = pm.CorrelationID
+	}
+	if "" != pm.ReplyTo {
+		fields[FieldReplyTo] = pm.ReplyTo
+	}
+	if "" != pm.ContentType {
+		fields[FieldContentType] = pm.ContentType
+	}
+	if "" != pm.ContentEncoding {
+		fields[FieldContentEncoding] = pm.ContentEncoding
+	}
+	return fields
+}
+
+// parseMessageTime parses the milliseconds part of stream entry id
+func parseMessageTime(id string) time.Time {
+	ms, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if nil != err {
+		return time.Now()
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
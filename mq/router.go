@@ -0,0 +1,307 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/mqenv"
+)
+
+// Constants of router
+const (
+	DefaultRouteRetryInterval    = 100 * time.Millisecond
+	DefaultRouteMaxRetryInterval = 10 * time.Second
+)
+
+// Errors of router
+var (
+	ErrRouterStarted = errors.New("mq router already started")
+)
+
+// RouteHandler handles the message dispatched to the route, the returned publish message is sent as the reply
+// if the message needs reply, an error or panic makes the message retried by the retry policy of the route
+type RouteHandler func(msg mqenv.MQConsumerMessage) (*mqenv.MQPublishMessage, error)
+
+// RouteMiddleware wraps the handler of route, such as logging, metrics or decoding
+type RouteMiddleware func(next RouteHandler) RouteHandler
+
+// RouteErrorHandler handles the message failed after all the attempts of the route
+type RouteErrorHandler func(route *Route, msg mqenv.MQConsumerMessage, err error)
+
+// RouteRetryPolicy retries the failed message in the consuming goroutine, the interval doubles every attempt up to MaxInterval
+type RouteRetryPolicy struct {
+	MaxAttempts int           // attempts including the first handling, no retry if less than 2
+	Interval    time.Duration // interval before the first retry, defaults to DefaultRouteRetryInterval
+	MaxInterval time.Duration // defaults to DefaultRouteMaxRetryInterval
+}
+
+// RouteOption options of route
+type RouteOption func(*Route)
+
+// WithRouteKey dispatches the messages of which the routing key matches pattern to the route,
+// the pattern is matched as the amqp topic exchange: words are separated by dot, * matches exactly one word
+// and # matches zero or more words, an empty pattern matches all the messages
+func WithRouteKey(pattern string) RouteOption {
+	return func(r *Route) {
+		r.RoutingKey = pattern
+	}
+}
+
+// WithRouteConcurrency limits the messages handled by the route at the same time, the consuming waits while the limit reached
+func WithRouteConcurrency(concurrency int) RouteOption {
+	return func(r *Route) {
+		r.Concurrency = concurrency
+	}
+}
+
+// WithRouteRetry sets the retry policy of route
+func WithRouteRetry(policy RouteRetryPolicy) RouteOption {
+	return func(r *Route) {
+		r.Retry = policy
+	}
+}
+
+// WithRouteMiddlewares appends the middlewares applied after the router middlewares
+func WithRouteMiddlewares(middlewares ...RouteMiddleware) RouteOption {
+	return func(r *Route) {
+		r.middlewares = append(r.middlewares, middlewares...)
+	}
+}
+
+// Route handles the messages of queue or topic by mq category
+type Route struct {
+	Category    string
+	Queue       string
+	RoutingKey  string
+	Concurrency int
+	Retry       RouteRetryPolicy
+	handler     RouteHandler
+	middlewares []RouteMiddleware
+	slots       chan struct{}
+}
+
+// Router dispatches the messages consumed from multiple queues or topics to the routes like a http mux,
+// every queue of category is subscribed once by Start and its messages are dispatched to the first route
+// registered whose routing key matches
+type Router struct {
+	routes       []*Route
+	middlewares  []RouteMiddleware
+	errorHandler RouteErrorHandler
+	started      bool
+	m            sync.Mutex
+}
+
+// NewRouter new router
+func NewRouter() *Router {
+	return &Router{routes: []*Route{}}
+}
+
+// Use appends the middlewares applied to all routes in registration order, the first one is the outermost
+func (r *Router) Use(middlewares ...RouteMiddleware) {
+	r.m.Lock()
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.m.Unlock()
+}
+
+// OnError sets the handler of the messages failed after all the attempts, they are logged and dropped by default
+func (r *Router) OnError(handler RouteErrorHandler) {
+	r.m.Lock()
+	r.errorHandler = handler
+	r.m.Unlock()
+}
+
+// Handle registers the handler of queue by mq category, the queue defaults to the configured queue or topic as Subscribe
+func (r *Router) Handle(mqCategory string, queue string, handler RouteHandler, options ...RouteOption) (*Route, error) {
+	mqConfig := GetMQConfig(mqCategory)
+	if nil == mqConfig {
+		return nil, fmt.Errorf("route MQ with invalid category:%s", mqCategory)
+	}
+	if nil == handler {
+		return nil, fmt.Errorf("route MQ category:%s with nil handler", mqCategory)
+	}
+	if "" == queue {
+		queue = defaultSubscribeQueue(getMQCategoryDriverType(mqCategory), mqConfig)
+	}
+	route := &Route{
+		Category: mqCategory,
+		Queue:    queue,
+		handler:  handler,
+	}
+	for _, option := range options {
+		option(route)
+	}
+	if route.Concurrency > 0 {
+		route.slots = make(chan struct{}, route.Concurrency)
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.started {
+		return nil, ErrRouterStarted
+	}
+	r.routes = append(r.routes, route)
+	return route, nil
+}
+
+// HandleFunc registers the handler without reply, see Handle
+func (r *Router) HandleFunc(mqCategory string, queue string, handler func(msg mqenv.MQConsumerMessage) error, options ...RouteOption) (*Route, error) {
+	if nil == handler {
+		return r.Handle(mqCategory, queue, nil, options...)
+	}
+	return r.Handle(mqCategory, queue, func(msg mqenv.MQConsumerMessage) (*mqenv.MQPublishMessage, error) {
+		return nil, handler(msg)
+	}, options...)
+}
+
+// Routes the registered routes
+func (r *Router) Routes() []*Route {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]*Route{}, r.routes...)
+}
+
+// Start subscribes every queue of the routes, the routes could not be registered after started
+func (r *Router) Start() error {
+	r.m.Lock()
+	if r.started {
+		r.m.Unlock()
+		return ErrRouterStarted
+	}
+	r.started = true
+	groups := map[string][]*Route{}
+	keys := []string{}
+	for _, route := range r.routes {
+		key := route.Category + "\x00" + route.Queue
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], route)
+	}
+	r.m.Unlock()
+
+	for _, key := range keys {
+		routes := groups[key]
+		for _, route := range routes {
+			route.handler = r.wrap(route)
+		}
+		if err := Subscribe(routes[0].Category, routes[0].Queue, r.dispatcher(routes)); err != nil {
+			logger.Error.Printf("router subscribe MQ category:%s queue:%s failed with error:%v", routes[0].Category, routes[0].Queue, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// wrap applies the router and route middlewares to the handler
+func (r *Router) wrap(route *Route) RouteHandler {
+	r.m.Lock()
+	middlewares := append(append([]RouteMiddleware{}, r.middlewares...), route.middlewares...)
+	r.m.Unlock()
+	handler := route.handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// dispatcher returns the callback of queue dispatching the messages to the routes
+func (r *Router) dispatcher(routes []*Route) mqenv.MQConsumerCallback {
+	return func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		for _, route := range routes {
+			if matchRoutingKey(route.RoutingKey, msg.RoutingKey) {
+				return r.handle(route, msg)
+			}
+		}
+		logger.Warning.Printf("router dropping message(%s) of MQ category:%s queue:%s with routing key:%s that no route matches", msg.MessageID, routes[0].Category, routes[0].Queue, msg.RoutingKey)
+		return nil
+	}
+}
+
+// handle handles the message by route and retries it by the retry policy
+func (r *Router) handle(route *Route, msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+	if nil != route.slots {
+		route.slots <- struct{}{}
+		defer func() {
+			<-route.slots
+		}()
+	}
+	interval := route.Retry.Interval
+	if interval <= 0 {
+		interval = DefaultRouteRetryInterval
+	}
+	maxInterval := route.Retry.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultRouteMaxRetryInterval
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var resp *mqenv.MQPublishMessage
+		resp, err = invokeRouteHandler(route.handler, msg)
+		if nil == err {
+			return resp
+		}
+		if attempt >= route.Retry.MaxAttempts {
+			break
+		}
+		logger.Warning.Printf("router handling message(%s) of MQ category:%s queue:%s failed with error:%v, retry after %v", msg.MessageID, route.Category, route.Queue, err, interval)
+		time.Sleep(interval)
+		interval *= 2
+		if interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+	r.m.Lock()
+	errorHandler := r.errorHandler
+	r.m.Unlock()
+	if nil != errorHandler {
+		errorHandler(route, msg, err)
+	} else {
+		logger.Error.Printf("router handling message(%s) of MQ category:%s queue:%s failed with error:%v, dropped", msg.MessageID, route.Category, route.Queue, err)
+	}
+	return nil
+}
+
+func invokeRouteHandler(handler RouteHandler, msg mqenv.MQConsumerMessage) (resp *mqenv.MQPublishMessage, err error) {
+	defer func() {
+		if r := recover(); nil != r {
+			err = fmt.Errorf("route handler panic:%v", r)
+		}
+	}()
+	return handler(msg)
+}
+
+// matchRoutingKey matches the routing key by the pattern of amqp topic exchange
+func matchRoutingKey(pattern string, routingKey string) bool {
+	if "" == pattern || "#" == pattern {
+		return true
+	}
+	return matchRoutingWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+func matchRoutingWords(patterns []string, words []string) bool {
+	for len(patterns) > 0 {
+		switch patterns[0] {
+		case "#":
+			for i := 0; i <= len(words); i++ {
+				if matchRoutingWords(patterns[1:], words[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(words) == 0 {
+				return false
+			}
+		default:
+			if len(words) == 0 || patterns[0] != words[0] {
+				return false
+			}
+		}
+		patterns = patterns[1:]
+		words = words[1:]
+	}
+	return len(words) == 0
+}
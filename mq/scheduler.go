@@ -0,0 +1,347 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/queues"
+	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/concurrency"
+)
+
+// Constants of delayed publishing
+const (
+	DefaultDelayedRetryInterval = 5 * time.Second
+	DefaultDelayedMaxAttempts   = 10
+	// DefaultDelayedDeliveryWorkers the due messages are published by the workers so that a slow publishing
+	// does not hold the scheduling, the scheduling waits while all the workers are busy
+	DefaultDelayedDeliveryWorkers = 4
+
+	delayedMessageFileSuffix = ".json"
+)
+
+// DelayedMessage a message scheduled to be published by category at DeliverAt
+type DelayedMessage struct {
+	ID        string                 `json:"id"`
+	Category  string                 `json:"category"`
+	DeliverAt int64                  `json:"deliverAt"` // unix timestamp in milliseconds
+	Attempts  int                    `json:"attempts"`
+	Message   mqenv.MQPublishMessage `json:"message"`
+}
+
+// GetID of the delayed message
+func (m *DelayedMessage) GetID() string {
+	return m.ID
+}
+
+// GetName the category of the delayed message
+func (m *DelayedMessage) GetName() string {
+	return m.Category
+}
+
+// OrderingValue the delivering time of the delayed message
+func (m *DelayedMessage) OrderingValue() int64 {
+	return m.DeliverAt
+}
+
+// DebugString of the delayed message
+func (m *DelayedMessage) DebugString() string {
+	return fmt.Sprintf("DelayedMessage{ID:%s Category:%s DeliverAt:%d Attempts:%d}", m.ID, m.Category, m.DeliverAt, m.Attempts)
+}
+
+// deliverTime the delivering time of the delayed message
+func (m *DelayedMessage) deliverTime() time.Time {
+	return time.Unix(0, m.DeliverAt*int64(time.Millisecond))
+}
+
+// DelayedMessageStore persists the delayed messages so that they are delivered after the process restarted
+type DelayedMessageStore interface {
+	// Save the delayed message, it is called again with the same ID while the delivering is retried
+	Save(msg *DelayedMessage) error
+	// Remove the delayed message after it is delivered or canceled
+	Remove(ID string) error
+	// LoadAll the delayed messages not delivered
+	LoadAll() ([]*DelayedMessage, error)
+}
+
+// FileDelayedMessageStore stores each delayed message as a json file in Dir
+type FileDelayedMessageStore struct {
+	Dir string
+}
+
+// NewFileDelayedMessageStore new file store of delayed messages, the dir would be created if not exists
+func NewFileDelayedMessageStore(dir string) (*FileDelayedMessageStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileDelayedMessageStore{Dir: dir}, nil
+}
+
+// Save the delayed message into file named by its ID
+func (s *FileDelayedMessageStore) Save(msg *DelayedMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免进程退出时留下不完整的文件
+	tmpFile := s.filePath(msg.ID) + ".tmp"
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.filePath(msg.ID))
+}
+
+// Remove the file of the delayed message
+func (s *FileDelayedMessageStore) Remove(ID string) error {
+	err := os.Remove(s.filePath(ID))
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// LoadAll the delayed messages from files in Dir
+func (s *FileDelayedMessageStore) LoadAll() ([]*DelayedMessage, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []*DelayedMessage{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), delayedMessageFileSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		msg := &DelayedMessage{}
+		if err = json.Unmarshal(data, msg); err != nil {
+			logger.Error.Printf("load delayed message from file:%s failed with error:%v", entry.Name(), err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (s *FileDelayedMessageStore) filePath(ID string) string {
+	return filepath.Join(s.Dir, ID+delayedMessageFileSuffix)
+}
+
+// messageScheduler schedules the delayed messages ordered by delivering time in a single goroutine and
+// publishes the due messages by the bounded workers
+type messageScheduler struct {
+	queue         *queues.OrderedQueue
+	workers       *concurrency.Pool
+	store         DelayedMessageStore
+	retryInterval time.Duration
+	maxAttempts   int
+	wakeup        chan struct{}
+	startOnce     sync.Once
+	m             sync.RWMutex
+}
+
+var delayedScheduler = newMessageScheduler()
+
+func newMessageScheduler() *messageScheduler {
+	return &messageScheduler{
+		queue:         queues.NewAscOrderingQueue(),
+		retryInterval: DefaultDelayedRetryInterval,
+		maxAttempts:   DefaultDelayedMaxAttempts,
+		wakeup:        make(chan struct{}, 1),
+	}
+}
+
+// PublishDelayed publishes the message by category as Publish after delay, the returned ID could be used to cancel it
+func PublishDelayed(mqCategory string, publishMsg mqenv.MQPublishMessage, delay time.Duration) (string, error) {
+	return PublishAt(mqCategory, publishMsg, time.Now().Add(delay))
+}
+
+// PublishAt publishes the message by category as Publish at the specified time,
+// the message is published immediately if the time has passed
+func PublishAt(mqCategory string, publishMsg mqenv.MQPublishMessage, t time.Time) (string, error) {
+	if nil == GetMQConfig(mqCategory) {
+		return "", fmt.Errorf("publish delayed MQ with invalid category:%s", mqCategory)
+	}
+	publishMsg.PublishStatus = nil
+	publishMsg.Response = nil
+	msg := &DelayedMessage{
+		ID:        utils.GenUUID(),
+		Category:  mqCategory,
+		DeliverAt: (t.UnixNano() + int64(time.Millisecond) - 1) / int64(time.Millisecond), // 向上取整，不早于指定时间发布
+		Message:   publishMsg,
+	}
+	if err := delayedScheduler.schedule(msg); err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// CancelDelayed cancels the delayed message not delivered yet
+func CancelDelayed(ID string) bool {
+	return delayedScheduler.cancel(ID)
+}
+
+// PendingDelayedMessages the number of delayed messages not delivered yet
+func PendingDelayedMessages() int {
+	return delayedScheduler.queue.GetSize()
+}
+
+// SetDelayedMessageStore sets the store persisting delayed messages and schedules the messages loaded from it,
+// the messages expired while the process stopped are published immediately
+func SetDelayedMessageStore(store DelayedMessageStore) error {
+	return delayedScheduler.setStore(store)
+}
+
+// SetDelayedRetryPolicy sets the interval and max attempts of republishing while publishing delayed message failed
+func SetDelayedRetryPolicy(interval time.Duration, maxAttempts int) {
+	delayedScheduler.m.Lock()
+	if interval > 0 {
+		delayedScheduler.retryInterval = interval
+	}
+	if maxAttempts > 0 {
+		delayedScheduler.maxAttempts = maxAttempts
+	}
+	delayedScheduler.m.Unlock()
+}
+
+func (s *messageScheduler) getStore() DelayedMessageStore {
+	s.m.RLock()
+	store := s.store
+	s.m.RUnlock()
+	return store
+}
+
+func (s *messageScheduler) setStore(store DelayedMessageStore) error {
+	s.m.Lock()
+	s.store = store
+	s.m.Unlock()
+	if nil == store {
+		return nil
+	}
+	msgs, err := store.LoadAll()
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if _, ok := s.queue.GetElement(msg.ID); !ok {
+			s.queue.Push(msg)
+		}
+	}
+	s.notify()
+	return nil
+}
+
+func (s *messageScheduler) schedule(msg *DelayedMessage) error {
+	if store := s.getStore(); nil != store {
+		if err := store.Save(msg); err != nil {
+			return err
+		}
+	}
+	s.queue.Push(msg)
+	s.notify()
+	return nil
+}
+
+func (s *messageScheduler) cancel(ID string) bool {
+	e, ok := s.queue.GetElement(ID)
+	if !ok || !s.queue.Remove(e.(queues.IElement)) {
+		return false
+	}
+	if store := s.getStore(); nil != store {
+		if err := store.Remove(ID); err != nil {
+			logger.Error.Printf("remove canceled delayed message:%s from store failed with error:%v", ID, err)
+		}
+	}
+	return true
+}
+
+// notify starts the scheduling goroutine on first use and wakes it up to recalculate the next delivering time
+func (s *messageScheduler) notify() {
+	s.startOnce.Do(func() {
+		s.workers = concurrency.NewPool(DefaultDelayedDeliveryWorkers)
+		go s.run()
+	})
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *messageScheduler) run() {
+	for {
+		wait := time.Hour
+		if first, ok := s.queue.First(); ok {
+			wait = time.Until(first.(*DelayedMessage).deliverTime())
+		}
+		if wait <= 0 {
+			s.deliverDue()
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.wakeup:
+		}
+		timer.Stop()
+	}
+}
+
+// deliverDue hands the first message over to the workers if it is due
+func (s *messageScheduler) deliverDue() {
+	item, ok := s.queue.Pop()
+	if !ok {
+		return
+	}
+	msg := item.(*DelayedMessage)
+	if time.Now().Before(msg.deliverTime()) {
+		// 取出前首个消息已被取消
+		s.queue.Push(msg)
+		return
+	}
+	if err := s.workers.Submit(func() { s.deliver(msg) }); err != nil {
+		logger.Error.Printf("submit delayed message:%s failed with error:%v, deliver it directly", msg.ID, err)
+		s.deliver(msg)
+	}
+}
+
+// deliver publishes the message, it is scheduled again after the retry interval if failed
+func (s *messageScheduler) deliver(msg *DelayedMessage) {
+	err := Publish(msg.Category, msg.Message)
+	if nil == err {
+		if store := s.getStore(); nil != store {
+			if err = store.Remove(msg.ID); err != nil {
+				logger.Error.Printf("remove delivered delayed message:%s from store failed with error:%v", msg.ID, err)
+			}
+		}
+		return
+	}
+	s.m.RLock()
+	retryInterval, maxAttempts := s.retryInterval, s.maxAttempts
+	s.m.RUnlock()
+	msg.Attempts++
+	if msg.Attempts >= maxAttempts {
+		logger.Error.Printf("publish delayed message:%s by category:%s failed with error:%v, dropped after %d attempts", msg.ID, msg.Category, err, msg.Attempts)
+		if store := s.getStore(); nil != store {
+			store.Remove(msg.ID)
+		}
+		return
+	}
+	logger.Warning.Printf("publish delayed message:%s by category:%s failed with error:%v, retry after %v", msg.ID, msg.Category, err, retryInterval)
+	msg.DeliverAt = time.Now().Add(retryInterval).UnixNano() / int64(time.Millisecond)
+	if store := s.getStore(); nil != store {
+		if err = store.Save(msg); err != nil {
+			logger.Error.Printf("save retrying delayed message:%s failed with error:%v", msg.ID, err)
+		}
+	}
+	s.queue.Push(msg)
+	s.notify()
+}
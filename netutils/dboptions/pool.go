@@ -0,0 +1,279 @@
+package dboptions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libpub/golib/health"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils/maputil"
+	"golang.org/x/sync/singleflight"
+)
+
+// Constants of connection pool
+const (
+	DefaultPingTimeout = 500 * time.Millisecond
+)
+
+var (
+	dbPools      = map[string]*DBPool{}
+	dbPoolsMutex = sync.RWMutex{}
+	dbInitGroup  singleflight.Group // concurrent initializations of the same name run once
+)
+
+// DBPool *sql.DB with the pool sizing of options and health checks
+type DBPool struct {
+	Name     string
+	DB       *sql.DB
+	Options  *DBConnectionPoolOptions
+	connData DBConnectionData
+	healthy  int32
+	stop     chan struct{}
+	m        sync.Mutex
+}
+
+// InitDBPool opens the named pool with options and pings it, returns the existing pool of the name directly
+func InitDBPool(name string, opts *DBConnectionPoolOptions) (*DBPool, error) {
+	if pool := GetDBPool(name); nil != pool {
+		return pool, nil
+	}
+	v, err, _ := dbInitGroup.Do(name, func() (interface{}, error) {
+		if pool := GetDBPool(name); nil != pool {
+			return pool, nil
+		}
+		pool, err := NewDBPool(name, opts)
+		if nil != err {
+			return nil, err
+		}
+		dbPoolsMutex.Lock()
+		dbPools[name] = pool
+		dbPoolsMutex.Unlock()
+		health.Register("db:"+name, health.CheckerFunc(pool.Ping))
+		return pool, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return v.(*DBPool), nil
+}
+
+// GetDBPool the pool of name, nil if not initialized
+func GetDBPool(name string) *DBPool {
+	dbPoolsMutex.RLock()
+	defer dbPoolsMutex.RUnlock()
+	return dbPools[name]
+}
+
+// CloseDBPool closes and removes the pool of name
+func CloseDBPool(name string) error {
+	dbPoolsMutex.Lock()
+	pool := dbPools[name]
+	delete(dbPools, name)
+	dbPoolsMutex.Unlock()
+	if nil == pool {
+		return nil
+	}
+	health.Unregister("db:" + name)
+	return pool.close()
+}
+
+// CloseAllDBPools closes all the pools, returns the first error
+func CloseAllDBPools() error {
+	dbPoolsMutex.Lock()
+	pools := dbPools
+	dbPools = map[string]*DBPool{}
+	dbPoolsMutex.Unlock()
+	var result error
+	for name, pool := range pools {
+		health.Unregister("db:" + name)
+		if err := pool.close(); nil != err && nil == result {
+			result = err
+		}
+	}
+	return result
+}
+
+// PingAllDBPools pings all the pools, returns the errors by name
+func PingAllDBPools(ctx context.Context) map[string]error {
+	dbPoolsMutex.RLock()
+	names := maputil.SortedKeys(dbPools)
+	dbPoolsMutex.RUnlock()
+	results := map[string]error{}
+	for _, name := range names {
+		if pool := GetDBPool(name); nil != pool {
+			results[name] = pool.Ping(ctx)
+		}
+	}
+	return results
+}
+
+// NewDBPool opens the pool without registering it, the pool should be closed by Close.
+// The hosts of multiple hosts dsn are tried in order (or randomly if LoadBalance) until one connected if Failover,
+// except oracle whose client fails over by the descriptor
+func NewDBPool(name string, opts *DBConnectionPoolOptions) (*DBPool, error) {
+	if nil == opts {
+		return nil, errors.New("new database pool with nil options")
+	}
+	if !opts.Failover || len(opts.Hosts) <= 1 || EngineOracle == opts.Engine {
+		return newDBPool(name, opts)
+	}
+	var lastErr error
+	for _, host := range opts.HostCandidates() {
+		pool, err := newDBPool(name, opts.ForHost(host))
+		if nil == err {
+			return pool, nil
+		}
+		logger.Warning.Printf("database pool:%s connecting host:%s failed with error:%v, trying next host", name, host, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func newDBPool(name string, opts *DBConnectionPoolOptions) (*DBPool, error) {
+	connData, err := opts.GetConnectionData()
+	if nil != err {
+		opts.Cleanup()
+		return nil, err
+	}
+	db, err := sql.Open(connData.Driver, connData.ConnString)
+	if nil != err {
+		opts.Cleanup()
+		return nil, fmt.Errorf("Open database:%s failed with error:%v", connData.ConnDescription, err)
+	}
+	if opts.MaxTotalConnections > 0 {
+		db.SetMaxOpenConns(opts.MaxTotalConnections)
+	}
+	if opts.MaxIdleConnections > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConnections)
+	}
+	if opts.MaxLifetime > 0 {
+		db.SetConnMaxLifetime(time.Duration(opts.MaxLifetime) * time.Second)
+	}
+	if opts.MaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(time.Duration(opts.MaxIdleTime) * time.Second)
+	}
+	pool := &DBPool{Name: name, DB: db, Options: opts, connData: connData}
+	if err = pool.Ping(context.Background()); nil == err {
+		err = pool.checkSessionAttrs()
+	}
+	if nil != err {
+		db.Close()
+		opts.Cleanup()
+		return nil, fmt.Errorf("Ping database:%s failed with error:%v", connData.ConnDescription, err)
+	}
+	return pool, nil
+}
+
+// checkSessionAttrs checks the postgres server is writable or read only as TargetSessionAttrs
+func (p *DBPool) checkSessionAttrs() error {
+	attrs := p.Options.TargetSessionAttrs
+	if "" == attrs || "any" == attrs {
+		return nil
+	}
+	readOnly := ""
+	if err := p.DB.QueryRow("SHOW transaction_read_only").Scan(&readOnly); nil != err {
+		return err
+	}
+	switch attrs {
+	case "read-write", "primary":
+		if "off" != readOnly {
+			return errors.New("server is read only")
+		}
+	case "read-only", "standby":
+		if "on" != readOnly {
+			return errors.New("server is not read only")
+		}
+	}
+	return nil
+}
+
+// Ping checks the connection within MaxWaitTime milliseconds of options
+func (p *DBPool) Ping(ctx context.Context) error {
+	timeout := DefaultPingTimeout
+	if p.Options.MaxWaitTime > 0 {
+		timeout = time.Duration(p.Options.MaxWaitTime) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := p.DB.PingContext(ctx)
+	if nil == err {
+		atomic.StoreInt32(&p.healthy, 1)
+	} else {
+		atomic.StoreInt32(&p.healthy, 0)
+	}
+	return err
+}
+
+// Healthy the result of the last ping
+func (p *DBPool) Healthy() bool {
+	return atomic.LoadInt32(&p.healthy) == 1
+}
+
+// Stats the pool statistics of *sql.DB
+func (p *DBPool) Stats() sql.DBStats {
+	return p.DB.Stats()
+}
+
+// Description the redacted connection description for logging
+func (p *DBPool) Description() string {
+	return p.connData.ConnDescription
+}
+
+// StartHealthCheck pings the database at every interval until the pool closed, the state changes are logged
+func (p *DBPool) StartHealthCheck(interval time.Duration) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if nil != p.stop || interval <= 0 {
+		return
+	}
+	p.stop = make(chan struct{})
+	go p.healthCheckLoop(interval, p.stop)
+}
+
+func (p *DBPool) healthCheckLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			wasHealthy := p.Healthy()
+			if err := p.Ping(context.Background()); nil != err {
+				if wasHealthy {
+					logger.Error.Printf("database pool:%s %s became unhealthy with error:%v", p.Name, p.Description(), err)
+				}
+			} else if !wasHealthy {
+				logger.Info.Printf("database pool:%s %s recovered", p.Name, p.Description())
+			}
+		}
+	}
+}
+
+// Close removes the pool from registry if registered, waits for the started queries and closes the database
+func (p *DBPool) Close() error {
+	dbPoolsMutex.Lock()
+	if dbPools[p.Name] == p {
+		delete(dbPools, p.Name)
+	}
+	dbPoolsMutex.Unlock()
+	return p.close()
+}
+
+func (p *DBPool) close() error {
+	p.m.Lock()
+	if nil != p.stop {
+		close(p.stop)
+		p.stop = nil
+	}
+	p.m.Unlock()
+	atomic.StoreInt32(&p.healthy, 0)
+	err := p.DB.Close()
+	p.Options.Cleanup()
+	return err
+}
@@ -0,0 +1,313 @@
+package dboptions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/queues"
+)
+
+// Constants of watchdog
+const (
+	DefaultWatchInterval = 10 * time.Second
+)
+
+// DBState the health state of a watched database pool
+type DBState struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	Failures  int       `json:"failures"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// DBStateEvent the state change of a watched database pool
+type DBStateEvent struct {
+	Name        string
+	Healthy     bool
+	Error       error
+	Reconnected bool
+	Time        time.Time
+}
+
+// DBStateHandler handles the state change events of watchdog
+type DBStateHandler func(event DBStateEvent)
+
+// DBWatchdog pings the named pools of registry periodically, emits events on state change
+// and reopens the pool after ReconnectAfter consecutive failures, the pools replaced by reconnecting
+// should be got by GetDBPool again instead of holding the old one
+type DBWatchdog struct {
+	Interval       time.Duration
+	ReconnectAfter int
+	queue          *queues.OrderedQueue
+	targets        map[string]*dbWatchTarget
+	handlers       []DBStateHandler
+	wakeup         chan struct{}
+	stop           chan struct{}
+	m              sync.RWMutex
+}
+
+type dbWatchTarget struct {
+	name      string
+	nextCheck int64 // unix milliseconds
+	removed   bool
+	state     DBState
+}
+
+// NewDBWatchdog new watchdog checking every interval, reconnecting is disabled if reconnectAfter <= 0
+func NewDBWatchdog(interval time.Duration, reconnectAfter int) *DBWatchdog {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	return &DBWatchdog{
+		Interval:       interval,
+		ReconnectAfter: reconnectAfter,
+		queue:          queues.NewAscOrderingQueue(),
+		targets:        map[string]*dbWatchTarget{},
+		wakeup:         make(chan struct{}, 1),
+	}
+}
+
+// OnStateChange adds the handler of state change events, the handlers are called in the watchdog goroutine
+func (w *DBWatchdog) OnStateChange(handler DBStateHandler) {
+	if nil == handler {
+		return
+	}
+	w.m.Lock()
+	w.handlers = append(w.handlers, handler)
+	w.m.Unlock()
+}
+
+// Watch adds the named pools to watch, the pools are checked immediately
+func (w *DBWatchdog) Watch(names ...string) {
+	now := time.Now()
+	w.m.Lock()
+	for _, name := range names {
+		if _, ok := w.targets[name]; ok {
+			continue
+		}
+		target := &dbWatchTarget{name: name, nextCheck: now.UnixMilli(), state: DBState{Name: name}}
+		w.targets[name] = target
+		w.queue.Push(target)
+	}
+	w.m.Unlock()
+	w.notify()
+}
+
+// Unwatch removes the named pool from watching
+func (w *DBWatchdog) Unwatch(name string) {
+	w.m.Lock()
+	if target, ok := w.targets[name]; ok {
+		target.removed = true
+		delete(w.targets, name)
+	}
+	w.m.Unlock()
+}
+
+// Start runs the watchdog goroutine until Stop
+func (w *DBWatchdog) Start() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if nil != w.stop {
+		return
+	}
+	w.stop = make(chan struct{})
+	go w.run(w.stop)
+}
+
+// Stop stops the watchdog goroutine
+func (w *DBWatchdog) Stop() {
+	w.m.Lock()
+	if nil != w.stop {
+		close(w.stop)
+		w.stop = nil
+	}
+	w.m.Unlock()
+}
+
+// States the last checked states of watched pools ordered by name
+func (w *DBWatchdog) States() []DBState {
+	w.m.RLock()
+	states := make([]DBState, 0, len(w.targets))
+	for _, target := range w.targets {
+		states = append(states, target.state)
+	}
+	w.m.RUnlock()
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// State the last checked state of the named pool
+func (w *DBWatchdog) State(name string) (DBState, bool) {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	target, ok := w.targets[name]
+	if !ok {
+		return DBState{}, false
+	}
+	return target.state, true
+}
+
+// Ready if all the watched pools are healthy
+func (w *DBWatchdog) Ready() bool {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	for _, target := range w.targets {
+		if !target.state.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeHTTP responses the states as json for readiness endpoint, the status is 503 if not ready
+func (w *DBWatchdog) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	if !w.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	body, _ := json.Marshal(w.States())
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	rw.Write(body)
+}
+
+func (w *DBWatchdog) notify() {
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (w *DBWatchdog) run(stop chan struct{}) {
+	for {
+		wait := time.Hour
+		if first, ok := w.queue.First(); ok {
+			wait = time.Until(time.UnixMilli(first.(*dbWatchTarget).nextCheck))
+		}
+		if wait <= 0 {
+			w.checkDue()
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-w.wakeup:
+		}
+		timer.Stop()
+	}
+}
+
+// checkDue checks the first pool and schedules its next checking
+func (w *DBWatchdog) checkDue() {
+	item, ok := w.queue.Pop()
+	if !ok {
+		return
+	}
+	target := item.(*dbWatchTarget)
+	w.m.RLock()
+	removed := target.removed
+	w.m.RUnlock()
+	if removed {
+		return
+	}
+	w.check(target)
+	target.nextCheck = time.Now().Add(w.Interval).UnixMilli()
+	w.queue.Push(target)
+}
+
+func (w *DBWatchdog) check(target *dbWatchTarget) {
+	err := fmt.Errorf("database pool:%s not initialized", target.name)
+	pool := GetDBPool(target.name)
+	if nil != pool {
+		err = pool.Ping(context.Background())
+	}
+	reconnected := false
+	if nil != pool && nil != err && w.ReconnectAfter > 0 && target.state.Failures+1 >= w.ReconnectAfter {
+		if _, rerr := reopenDBPool(pool); nil != rerr {
+			logger.Error.Printf("database pool:%s reconnecting failed with error:%v", target.name, rerr)
+		} else {
+			logger.Info.Printf("database pool:%s reconnected", target.name)
+			err = nil
+			reconnected = true
+		}
+	}
+
+	w.m.Lock()
+	wasHealthy := target.state.Healthy
+	firstCheck := target.state.CheckedAt.IsZero()
+	target.state.CheckedAt = time.Now()
+	if nil == err {
+		target.state.Healthy = true
+		target.state.Error = ""
+		target.state.Failures = 0
+	} else {
+		target.state.Healthy = false
+		target.state.Error = err.Error()
+		target.state.Failures++
+	}
+	handlers := w.handlers
+	w.m.Unlock()
+
+	if wasHealthy == (nil == err) && !firstCheck && !reconnected {
+		return
+	}
+	if nil == err {
+		logger.Info.Printf("database pool:%s is healthy", target.name)
+	} else {
+		logger.Error.Printf("database pool:%s is unhealthy with error:%v", target.name, err)
+	}
+	event := DBStateEvent{Name: target.name, Healthy: nil == err, Error: err, Reconnected: reconnected, Time: target.state.CheckedAt}
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
+
+// reopenDBPool opens a new pool with the options of the registered pool and replaces it,
+// all the hosts are tried again if Failover
+func reopenDBPool(old *DBPool) (*DBPool, error) {
+	opts := old.Options.ForHost(DBHost{Host: old.Options.Host, Port: old.Options.Port})
+	pool, err := NewDBPool(old.Name, opts)
+	if nil != err {
+		return nil, err
+	}
+	dbPoolsMutex.Lock()
+	if dbPools[old.Name] != old {
+		dbPoolsMutex.Unlock()
+		pool.close()
+		return nil, fmt.Errorf("database pool:%s was replaced or closed while reconnecting", old.Name)
+	}
+	dbPools[old.Name] = pool
+	dbPoolsMutex.Unlock()
+	old.close()
+	return pool, nil
+}
+
+// GetID queue element id
+func (t *dbWatchTarget) GetID() string {
+	return t.name
+}
+
+// GetName queue element name
+func (t *dbWatchTarget) GetName() string {
+	return t.name
+}
+
+// OrderingValue queue element ordering value
+func (t *dbWatchTarget) OrderingValue() int64 {
+	return t.nextCheck
+}
+
+// DebugString queue element debug string
+func (t *dbWatchTarget) DebugString() string {
+	return fmt.Sprintf("database watch target %s next check:%d", t.name, t.nextCheck)
+}
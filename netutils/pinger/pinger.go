@@ -3,8 +3,8 @@ package pinger
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"net"
+	"strconv"
 	"time"
 
 	"github.com/libpub/golib/logger"
@@ -92,7 +92,7 @@ func CheckSum(data []byte) uint16 {
 
 // Connectable by IP and port
 func Connectable(ip string, port int) bool {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
 	conn, err := net.DialTimeout("tcp", addr, PingTimeout*time.Second)
 	if nil != err {
 		logger.Warning.Printf("try connect %s failed with error:%v", addr, err)
@@ -0,0 +1,199 @@
+package redisoptions
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/utils/maputil"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	redisClients      = map[string]*RedisClient{}
+	redisClientsMutex = sync.RWMutex{}
+	redisInitGroup    singleflight.Group // concurrent initializations of the same name run once
+)
+
+// RedisClient named redis.UniversalClient with health checks
+type RedisClient struct {
+	Name    string
+	Client  redis.UniversalClient
+	Options *RedisOptions
+	healthy int32
+	stop    chan struct{}
+	m       sync.Mutex
+}
+
+// InitRedisClient creates the named client with options and pings it, returns the existing client of the name directly
+func InitRedisClient(name string, opts *RedisOptions) (*RedisClient, error) {
+	if client := GetRedisClient(name); nil != client {
+		return client, nil
+	}
+	v, err, _ := redisInitGroup.Do(name, func() (interface{}, error) {
+		if client := GetRedisClient(name); nil != client {
+			return client, nil
+		}
+		client, err := NewRedisClient(name, opts)
+		if nil != err {
+			return nil, err
+		}
+		redisClientsMutex.Lock()
+		redisClients[name] = client
+		redisClientsMutex.Unlock()
+		return client, nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return v.(*RedisClient), nil
+}
+
+// GetRedisClient the client of name, nil if not initialized
+func GetRedisClient(name string) *RedisClient {
+	redisClientsMutex.RLock()
+	defer redisClientsMutex.RUnlock()
+	return redisClients[name]
+}
+
+// CloseRedisClient closes and removes the client of name
+func CloseRedisClient(name string) error {
+	redisClientsMutex.Lock()
+	client := redisClients[name]
+	delete(redisClients, name)
+	redisClientsMutex.Unlock()
+	if nil == client {
+		return nil
+	}
+	return client.close()
+}
+
+// CloseAllRedisClients closes all the clients, returns the first error
+func CloseAllRedisClients() error {
+	redisClientsMutex.Lock()
+	clients := redisClients
+	redisClients = map[string]*RedisClient{}
+	redisClientsMutex.Unlock()
+	var result error
+	for _, client := range clients {
+		if err := client.close(); nil != err && nil == result {
+			result = err
+		}
+	}
+	return result
+}
+
+// PingAllRedisClients pings all the clients, returns the errors by name
+func PingAllRedisClients() map[string]error {
+	redisClientsMutex.RLock()
+	names := maputil.SortedKeys(redisClients)
+	redisClientsMutex.RUnlock()
+	results := map[string]error{}
+	for _, name := range names {
+		if client := GetRedisClient(name); nil != client {
+			results[name] = client.Ping()
+		}
+	}
+	return results
+}
+
+// NewRedisClient creates the client without registering it and pings it, the client should be closed by Close
+func NewRedisClient(name string, opts *RedisOptions) (*RedisClient, error) {
+	if nil == opts {
+		return nil, errors.New("new redis client with nil options")
+	}
+	client, err := opts.NewClient()
+	if nil != err {
+		return nil, err
+	}
+	c := &RedisClient{Name: name, Client: client, Options: opts}
+	if err = c.Ping(); nil != err {
+		client.Close()
+		return nil, fmt.Errorf("Ping redis:%s failed with error:%v", opts.Redacted(), err)
+	}
+	logger.Info.Printf("initialized redis client %s %s", name, opts.Redacted())
+	return c, nil
+}
+
+// Ping checks the connection
+func (c *RedisClient) Ping() error {
+	err := c.Client.Ping().Err()
+	if nil == err {
+		atomic.StoreInt32(&c.healthy, 1)
+	} else {
+		atomic.StoreInt32(&c.healthy, 0)
+	}
+	return err
+}
+
+// Healthy the result of the last ping
+func (c *RedisClient) Healthy() bool {
+	return atomic.LoadInt32(&c.healthy) == 1
+}
+
+// PoolStats the connection pool statistics
+func (c *RedisClient) PoolStats() *redis.PoolStats {
+	switch client := c.Client.(type) {
+	case *redis.Client:
+		return client.PoolStats()
+	case *redis.ClusterClient:
+		return client.PoolStats()
+	}
+	return &redis.PoolStats{}
+}
+
+// StartHealthCheck pings the redis at every interval until the client closed, the state changes are logged
+func (c *RedisClient) StartHealthCheck(interval time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if nil != c.stop || interval <= 0 {
+		return
+	}
+	c.stop = make(chan struct{})
+	go c.healthCheckLoop(interval, c.stop)
+}
+
+func (c *RedisClient) healthCheckLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			wasHealthy := c.Healthy()
+			if err := c.Ping(); nil != err {
+				if wasHealthy {
+					logger.Error.Printf("redis client:%s %s became unhealthy with error:%v", c.Name, c.Options.Redacted(), err)
+				}
+			} else if !wasHealthy {
+				logger.Info.Printf("redis client:%s %s recovered", c.Name, c.Options.Redacted())
+			}
+		}
+	}
+}
+
+// Close removes the client from registry if registered and closes it
+func (c *RedisClient) Close() error {
+	redisClientsMutex.Lock()
+	if redisClients[c.Name] == c {
+		delete(redisClients, c.Name)
+	}
+	redisClientsMutex.Unlock()
+	return c.close()
+}
+
+func (c *RedisClient) close() error {
+	c.m.Lock()
+	if nil != c.stop {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.m.Unlock()
+	atomic.StoreInt32(&c.healthy, 0)
+	return c.Client.Close()
+}
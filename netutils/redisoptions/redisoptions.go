@@ -0,0 +1,382 @@
+package redisoptions
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/caching/cachingenv"
+	"github.com/libpub/golib/definations"
+)
+
+// Constants
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel"
+	ModeCluster    = "cluster"
+
+	DefaultRedisPort    = 6379
+	DefaultSentinelPort = 26379
+
+	redactedMask = "****"
+)
+
+// Errors
+var (
+	ErrInvalidRedisURL  = errors.New("invalid redis url")
+	ErrInvalidRedisMode = errors.New("invalid redis mode")
+	ErrNoMasterName     = errors.New("redis sentinel mode requires master name")
+)
+
+// RedisOptions options of redis standalone, sentinel or cluster client, the timeouts are in milliseconds
+type RedisOptions struct {
+	Mode           string                 `yaml:"mode"`
+	Addrs          []string               `yaml:"addrs"`
+	MasterName     string                 `yaml:"masterName"`
+	Password       string                 `yaml:"password"`
+	DB             int                    `yaml:"db"`
+	PoolSize       int                    `yaml:"poolSize"`
+	MinIdleConns   int                    `yaml:"minIdleConns"`
+	MaxRetries     int                    `yaml:"maxRetries"`
+	DialTimeout    int                    `yaml:"dialTimeout"`
+	ReadTimeout    int                    `yaml:"readTimeout"`
+	WriteTimeout   int                    `yaml:"writeTimeout"`
+	PoolTimeout    int                    `yaml:"poolTimeout"`
+	IdleTimeout    int                    `yaml:"idleTimeout"`
+	ReadOnly       bool                   `yaml:"readOnly"`
+	RouteByLatency bool                   `yaml:"routeByLatency"`
+	TLS            definations.TLSOptions `yaml:"tls"`
+}
+
+// NewRedisOptionsWithURL parses the redis url:
+//
+//	redis://[:password@]host[:port][/db][?options]
+//	rediss://... the same as redis:// with tls enabled
+//	redis-sentinel://[:password@]host1[:port],host2[:port][/db]?master=name
+//	redis-cluster://[:password@]host1[:port],host2[:port]
+//
+// the options are pool_size, min_idle_conns, max_retries, dial_timeout, read_timeout, write_timeout,
+// pool_timeout and idle_timeout in milliseconds or duration text like 3s, read_only, route_by_latency,
+// skip_verify, master and mode
+func NewRedisOptionsWithURL(redisURL string) (*RedisOptions, error) {
+	// url.Parse does not accept multiple hosts with ipv6, the hosts are split out before parsing
+	hosts := ""
+	if pos := strings.Index(redisURL, "://"); pos > 0 {
+		authority := redisURL[pos+3:]
+		if end := strings.IndexAny(authority, "/?"); end >= 0 {
+			authority = authority[:end]
+		}
+		hosts = authority[strings.LastIndex(authority, "@")+1:]
+		redisURL = redisURL[:pos+3] + authority[:len(authority)-len(hosts)] + "localhost" + redisURL[pos+3+len(authority):]
+	}
+	u, err := url.Parse(redisURL)
+	if nil != err {
+		return nil, fmt.Errorf("%w:%v", ErrInvalidRedisURL, err)
+	}
+	o := &RedisOptions{}
+	defaultPort := DefaultRedisPort
+	switch u.Scheme {
+	case "redis":
+		o.Mode = ModeStandalone
+	case "rediss":
+		o.Mode = ModeStandalone
+		o.TLS.Enabled = true
+	case "redis-sentinel", "sentinel":
+		o.Mode = ModeSentinel
+		defaultPort = DefaultSentinelPort
+	case "redis-cluster", "cluster":
+		o.Mode = ModeCluster
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme:%s", ErrInvalidRedisURL, u.Scheme)
+	}
+	if nil != u.User {
+		if password, ok := u.User.Password(); ok {
+			o.Password = password
+		} else {
+			// redis://password@host
+			o.Password = u.User.Username()
+		}
+	}
+	for _, host := range strings.Split(hosts, ",") {
+		if host = strings.TrimSpace(host); "" != host {
+			o.Addrs = append(o.Addrs, formatAddr(host, defaultPort))
+		}
+	}
+	if len(o.Addrs) == 0 {
+		return nil, fmt.Errorf("%w: no host", ErrInvalidRedisURL)
+	}
+	if db := strings.Trim(u.Path, "/"); "" != db {
+		if o.DB, err = strconv.Atoi(db); nil != err {
+			return nil, fmt.Errorf("%w: invalid db:%s", ErrInvalidRedisURL, db)
+		}
+	}
+	if err = o.parseQuery(u.Query()); nil != err {
+		return nil, err
+	}
+	return o, o.Validate()
+}
+
+// NewRedisOptionsWithCacheConfig the options of cache connector config
+func NewRedisOptionsWithCacheConfig(conf cachingenv.CacheConnectorConfig) *RedisOptions {
+	o := &RedisOptions{
+		Mode:     ModeStandalone,
+		Password: conf.Password,
+		DB:       conf.Index,
+	}
+	if conf.ClusterMode {
+		o.Mode = ModeCluster
+	}
+	for _, host := range strings.Split(conf.Host, ",") {
+		if host = strings.TrimSpace(host); "" == host {
+			continue
+		}
+		if conf.Port > 0 {
+			o.Addrs = append(o.Addrs, formatAddr(host, conf.Port))
+		} else {
+			o.Addrs = append(o.Addrs, formatAddr(host, DefaultRedisPort))
+		}
+	}
+	return o
+}
+
+func (o *RedisOptions) parseQuery(query url.Values) error {
+	for key, values := range query {
+		if len(values) == 0 {
+			continue
+		}
+		value := values[len(values)-1]
+		var err error
+		switch key {
+		case "master", "master_name":
+			o.MasterName = value
+		case "mode":
+			o.Mode = value
+		case "db":
+			o.DB, err = strconv.Atoi(value)
+		case "pool_size":
+			o.PoolSize, err = strconv.Atoi(value)
+		case "min_idle_conns":
+			o.MinIdleConns, err = strconv.Atoi(value)
+		case "max_retries":
+			o.MaxRetries, err = strconv.Atoi(value)
+		case "dial_timeout":
+			o.DialTimeout, err = parseMillis(value)
+		case "read_timeout":
+			o.ReadTimeout, err = parseMillis(value)
+		case "write_timeout":
+			o.WriteTimeout, err = parseMillis(value)
+		case "pool_timeout":
+			o.PoolTimeout, err = parseMillis(value)
+		case "idle_timeout":
+			o.IdleTimeout, err = parseMillis(value)
+		case "read_only":
+			o.ReadOnly, err = strconv.ParseBool(value)
+		case "route_by_latency":
+			o.RouteByLatency, err = strconv.ParseBool(value)
+		case "skip_verify":
+			o.TLS.SkipVerify, err = strconv.ParseBool(value)
+		case "tls":
+			o.TLS.Enabled, err = strconv.ParseBool(value)
+		}
+		if nil != err {
+			return fmt.Errorf("%w: invalid option %s=%s", ErrInvalidRedisURL, key, value)
+		}
+	}
+	return nil
+}
+
+// Validate checks the mode, addresses, master name and tls options
+func (o *RedisOptions) Validate() error {
+	switch o.Mode {
+	case "", ModeStandalone, ModeCluster:
+	case ModeSentinel:
+		if "" == o.MasterName {
+			return ErrNoMasterName
+		}
+	default:
+		return fmt.Errorf("%w:%s", ErrInvalidRedisMode, o.Mode)
+	}
+	if len(o.Addrs) == 0 {
+		return errors.New("redis options without address")
+	}
+	if err := o.TLS.Validate(); nil != err {
+		return fmt.Errorf("redis tls options are invalid:%v", err)
+	}
+	return nil
+}
+
+// TLSConfig the tls config of TLS options, nil if not enabled
+func (o *RedisOptions) TLSConfig() (*tls.Config, error) {
+	if !o.TLS.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.TLS.SkipVerify}
+	if "" != o.TLS.CertFile || "" != o.TLS.KeyFile {
+		certs, err := tls.LoadX509KeyPair(o.TLS.CertFile, o.TLS.KeyFile)
+		if nil != err {
+			return nil, fmt.Errorf("load tls certificates:%s and %s failed with error:%v", o.TLS.CertFile, o.TLS.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certs}
+	}
+	if "" != o.TLS.CaFile {
+		caData, err := ioutil.ReadFile(o.TLS.CaFile)
+		if nil != err {
+			return nil, fmt.Errorf("load tls root CA:%s failed with error:%v", o.TLS.CaFile, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(caData)
+	}
+	if len(o.Addrs) > 0 && ModeStandalone == o.mode() {
+		if host, _, err := net.SplitHostPort(o.Addrs[0]); nil == err {
+			tlsConfig.ServerName = host
+		}
+	}
+	return tlsConfig, nil
+}
+
+// NewClient new redis client of the mode, the standalone mode with multiple addresses uses the first one
+func (o *RedisOptions) NewClient() (redis.UniversalClient, error) {
+	if err := o.Validate(); nil != err {
+		return nil, err
+	}
+	tlsConfig, err := o.TLSConfig()
+	if nil != err {
+		return nil, err
+	}
+	switch o.mode() {
+	case ModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    o.MasterName,
+			SentinelAddrs: o.Addrs,
+			Password:      o.Password,
+			DB:            o.DB,
+			MaxRetries:    o.MaxRetries,
+			DialTimeout:   millis(o.DialTimeout),
+			ReadTimeout:   millis(o.ReadTimeout),
+			WriteTimeout:  millis(o.WriteTimeout),
+			PoolSize:      o.PoolSize,
+			MinIdleConns:  o.MinIdleConns,
+			PoolTimeout:   millis(o.PoolTimeout),
+			IdleTimeout:   millis(o.IdleTimeout),
+			TLSConfig:     tlsConfig,
+		}), nil
+	case ModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:          o.Addrs,
+			Password:       o.Password,
+			ReadOnly:       o.ReadOnly,
+			RouteByLatency: o.RouteByLatency,
+			MaxRetries:     o.MaxRetries,
+			DialTimeout:    millis(o.DialTimeout),
+			ReadTimeout:    millis(o.ReadTimeout),
+			WriteTimeout:   millis(o.WriteTimeout),
+			PoolSize:       o.PoolSize,
+			MinIdleConns:   o.MinIdleConns,
+			PoolTimeout:    millis(o.PoolTimeout),
+			IdleTimeout:    millis(o.IdleTimeout),
+			TLSConfig:      tlsConfig,
+		}), nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:         o.Addrs[0],
+		Password:     o.Password,
+		DB:           o.DB,
+		MaxRetries:   o.MaxRetries,
+		DialTimeout:  millis(o.DialTimeout),
+		ReadTimeout:  millis(o.ReadTimeout),
+		WriteTimeout: millis(o.WriteTimeout),
+		PoolSize:     o.PoolSize,
+		MinIdleConns: o.MinIdleConns,
+		PoolTimeout:  millis(o.PoolTimeout),
+		IdleTimeout:  millis(o.IdleTimeout),
+		TLSConfig:    tlsConfig,
+	}), nil
+}
+
+// Redacted the url of options with password masked for logging
+func (o *RedisOptions) Redacted() string {
+	scheme := "redis"
+	switch o.mode() {
+	case ModeSentinel:
+		scheme = "redis-sentinel"
+	case ModeCluster:
+		scheme = "redis-cluster"
+	default:
+		if o.TLS.Enabled {
+			scheme = "rediss"
+		}
+	}
+	text := scheme + "://"
+	if "" != o.Password {
+		text += ":" + redactedMask + "@"
+	}
+	text += strings.Join(o.Addrs, ",")
+	if ModeCluster != o.mode() {
+		text += "/" + strconv.Itoa(o.DB)
+	}
+	params := map[string]string{}
+	if "" != o.MasterName {
+		params["master"] = o.MasterName
+	}
+	if o.PoolSize > 0 {
+		params["pool_size"] = strconv.Itoa(o.PoolSize)
+	}
+	if len(params) > 0 {
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + "=" + url.QueryEscape(params[k])
+		}
+		text += "?" + strings.Join(pairs, "&")
+	}
+	return text
+}
+
+// String the redacted url
+func (o *RedisOptions) String() string {
+	return o.Redacted()
+}
+
+func (o *RedisOptions) mode() string {
+	if "" == o.Mode {
+		return ModeStandalone
+	}
+	return o.Mode
+}
+
+// formatAddr host:port with default port, the ipv6 host should be bracketed
+func formatAddr(host string, defaultPort int) string {
+	if _, _, err := net.SplitHostPort(host); nil == err {
+		return host
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(defaultPort))
+}
+
+// parseMillis parses milliseconds or duration text like 3s
+func parseMillis(value string) (int, error) {
+	if n, err := strconv.Atoi(value); nil == err {
+		return n, nil
+	}
+	d, err := time.ParseDuration(value)
+	if nil != err {
+		return 0, err
+	}
+	return int(d / time.Millisecond), nil
+}
+
+func millis(n int) time.Duration {
+	return time.Duration(n) * time.Millisecond
+}
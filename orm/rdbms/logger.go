@@ -14,32 +14,32 @@ type ormLogger struct {
 }
 
 func (l *ormLogger) Debug(v ...interface{}) {
-	// logger.Debug.Output(3, fmt.Sprint(v...))
+	// logger.Output(logger.LogLevelDebug, 3, fmt.Sprint(v...))
 }
 
 func (l *ormLogger) Debugf(format string, v ...interface{}) {
-	// logger.Debug.Output(3, fmt.Sprintf(format, v...))
+	// logger.Output(logger.LogLevelDebug, 3, fmt.Sprintf(format, v...))
 }
 func (l *ormLogger) Error(v ...interface{}) {
-	logger.Error.Output(3, fmt.Sprint(v...))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprint(v...))
 }
 func (l *ormLogger) Errorf(format string, v ...interface{}) {
-	logger.Error.Output(3, fmt.Sprintf(format, v...))
+	logger.Output(logger.LogLevelError, 3, fmt.Sprintf(format, v...))
 }
 func (l *ormLogger) Info(v ...interface{}) {
-	logger.Info.Output(3, fmt.Sprint(v...))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprint(v...))
 }
 func (l *ormLogger) Infof(format string, v ...interface{}) {
 	if strings.HasPrefix(format, "PING DATABASE") {
 		return
 	}
-	logger.Info.Output(3, fmt.Sprintf(format, v...))
+	logger.Output(logger.LogLevelInfo, 3, fmt.Sprintf(format, v...))
 }
 func (l *ormLogger) Warn(v ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprint(v...))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprint(v...))
 }
 func (l *ormLogger) Warnf(format string, v ...interface{}) {
-	logger.Warning.Output(3, fmt.Sprintf(format, v...))
+	logger.Output(logger.LogLevelWarning, 3, fmt.Sprintf(format, v...))
 }
 
 func (l *ormLogger) Level() log.LogLevel {
@@ -60,7 +60,7 @@ func (l *ormLogger) IsShowSQL() bool {
 }
 
 func getSysLogLevel() log.LogLevel {
-	switch logger.Level {
+	switch logger.GetLevel() {
 	case logger.LogLevelDebug, logger.LogLevelTrace:
 		return log.LOG_INFO
 	case logger.LogLevelInfo:
@@ -0,0 +1,100 @@
+package queues
+
+import (
+	"sync"
+	"time"
+)
+
+// Constants of expiration
+const (
+	DefaultSweepInterval = time.Second
+)
+
+// IExpirable element expiring at the time, the zero time never expires
+type IExpirable interface {
+	ExpireAt() time.Time
+}
+
+// ExpiredCallback handles the element evicted by sweeper
+type ExpiredCallback func(element IElement)
+
+// ExpirySweeper evicts the expired elements implementing IExpirable from queue periodically,
+// the elements not implementing IExpirable are kept
+type ExpirySweeper struct {
+	Interval  time.Duration
+	queue     IQueue
+	onExpired ExpiredCallback
+	stop      chan struct{}
+	m         sync.Mutex
+}
+
+// NewExpirySweeper new sweeper of queue sweeping every interval, onExpired is called with the evicted elements if not nil
+func NewExpirySweeper(queue IQueue, interval time.Duration, onExpired ExpiredCallback) *ExpirySweeper {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	return &ExpirySweeper{
+		Interval:  interval,
+		queue:     queue,
+		onExpired: onExpired,
+	}
+}
+
+// IsExpired if the element implements IExpirable and expired at now
+func IsExpired(element IElement, now time.Time) bool {
+	expirable, ok := element.(IExpirable)
+	if !ok {
+		return false
+	}
+	expireAt := expirable.ExpireAt()
+	return !expireAt.IsZero() && !expireAt.After(now)
+}
+
+// Sweep evicts the expired elements immediately and returns the count of them
+func (s *ExpirySweeper) Sweep() int {
+	now := time.Now()
+	n := 0
+	for _, e := range s.queue.Elements() {
+		if IsExpired(e, now) && s.queue.Remove(e) {
+			n++
+			if nil != s.onExpired {
+				s.onExpired(e)
+			}
+		}
+	}
+	return n
+}
+
+// Start runs the sweeping goroutine until Stop
+func (s *ExpirySweeper) Start() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if nil != s.stop {
+		return
+	}
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+}
+
+// Stop stops the sweeping goroutine
+func (s *ExpirySweeper) Stop() {
+	s.m.Lock()
+	if nil != s.stop {
+		close(s.stop)
+		s.stop = nil
+	}
+	s.m.Unlock()
+}
+
+func (s *ExpirySweeper) run(stop chan struct{}) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
@@ -30,6 +30,7 @@ func (q *FIFOQueue) Push(item IElement) bool {
 		q.queue = append(q.queue, item)
 	}
 	q.m.Unlock()
+	pushedCounter.Inc(metricsFIFOQueue)
 	return true
 }
 
@@ -43,6 +44,7 @@ func (q *FIFOQueue) Pop() (interface{}, bool) {
 	item := q.queue[0]
 	q.queue = append([]IElement{}, q.queue[1:]...)
 	q.m.Unlock()
+	poppedCounter.Inc(metricsFIFOQueue)
 	return item, true
 }
 
@@ -64,6 +66,7 @@ func (q *FIFOQueue) PopMany(maxResults int) ([]interface{}, int) {
 	}
 	q.queue = append([]IElement{}, q.queue[maxLen:]...)
 	q.m.Unlock()
+	poppedCounter.Add(float64(maxLen), metricsFIFOQueue)
 	return items, maxLen
 }
 
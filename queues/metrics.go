@@ -0,0 +1,16 @@
+package queues
+
+import "github.com/libpub/golib/metrics"
+
+// Queue labels of metrics
+const (
+	metricsFIFOQueue    = "fifo"
+	metricsOrderedQueue = "ordered"
+	metricsRingQueue    = "ring"
+)
+
+var (
+	pushedCounter  = metrics.NewCounter("queues_pushed_total", "Elements pushed into queues", "queue")
+	poppedCounter  = metrics.NewCounter("queues_popped_total", "Elements popped from queues", "queue")
+	droppedCounter = metrics.NewCounter("queues_dropped_total", "Elements overwritten or rejected by full queues", "queue")
+)
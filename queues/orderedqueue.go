@@ -1,6 +1,7 @@
 package queues
 
 import (
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,9 +23,10 @@ func UnixTimestampToTime(secs int64) time.Time {
 	return time.Unix(secs, 0)
 }
 
-// OrderedQueue queue
+// OrderedQueue queue, the elements are identified by element.GetID() uniquely
 type OrderedQueue struct {
 	queue    []IElement
+	index    map[string]IElement // elements by id
 	ordering OrderingMode
 	m        sync.RWMutex
 }
@@ -33,6 +35,7 @@ type OrderedQueue struct {
 func NewAscOrderingQueue() *OrderedQueue {
 	return &OrderedQueue{
 		queue:    []IElement{},
+		index:    map[string]IElement{},
 		ordering: OrderingAsc,
 		m:        sync.RWMutex{},
 	}
@@ -42,17 +45,23 @@ func NewAscOrderingQueue() *OrderedQueue {
 func NewDescOrderingQueue() *OrderedQueue {
 	return &OrderedQueue{
 		queue:    []IElement{},
+		index:    map[string]IElement{},
 		ordering: OrderingDesc,
 		m:        sync.RWMutex{},
 	}
 }
 
-// Add element depending on ordered queue ordering mode
+// Add element depending on ordered queue ordering mode, the element of the same id in queue is replaced
 func (q *OrderedQueue) Add(item IElement) *OrderedQueue {
 	q.m.Lock()
+	if existing, ok := q.index[item.GetID()]; ok {
+		q.removeAt(q.findElementIndex(existing))
+	}
 	ql := len(q.queue)
 	q.queue = pushItemToOrderedQueue(&q.queue, ql, item, q.ordering)
+	q.index[item.GetID()] = item
 	q.m.Unlock()
+	pushedCounter.Inc(metricsOrderedQueue)
 	return q
 }
 
@@ -71,7 +80,9 @@ func (q *OrderedQueue) Pop() (interface{}, bool) {
 	}
 	item := q.queue[0]
 	q.queue = append([]IElement{}, q.queue[1:]...)
+	delete(q.index, item.GetID())
 	q.m.Unlock()
+	poppedCounter.Inc(metricsOrderedQueue)
 	return item, true
 }
 
@@ -90,9 +101,11 @@ func (q *OrderedQueue) PopMany(maxResults int) ([]interface{}, int) {
 	items := make([]interface{}, maxLen)
 	for i := 0; i < maxLen; i++ {
 		items[i] = q.queue[i]
+		delete(q.index, q.queue[i].GetID())
 	}
 	q.queue = append([]IElement{}, q.queue[maxLen:]...)
 	q.m.Unlock()
+	poppedCounter.Add(float64(maxLen), metricsOrderedQueue)
 	return items, maxLen
 }
 
@@ -110,18 +123,26 @@ func (q *OrderedQueue) First() (interface{}, bool) {
 
 // Remove an element from queue identified by element.GetID()
 func (q *OrderedQueue) Remove(item IElement) bool {
-	// fmt.Printf("Removing element %s finding...\n", item.GetID())
 	q.m.Lock()
-	idx := q.findElementIndex(item)
-	if 0 > idx {
+	existing, ok := q.index[item.GetID()]
+	if !ok {
 		q.m.Unlock()
 		return false
 	}
-	q.queue = append(q.queue[0:idx], q.queue[idx+1:]...)
+	q.removeAt(q.findElementIndex(existing))
 	q.m.Unlock()
 	return true
 }
 
+// removeAt removes the element at idx and its index, caller should hold the lock
+func (q *OrderedQueue) removeAt(idx int) {
+	if 0 > idx {
+		return
+	}
+	delete(q.index, q.queue[idx].GetID())
+	q.queue = append(q.queue[0:idx], q.queue[idx+1:]...)
+}
+
 // Elements of all queue
 func (q *OrderedQueue) Elements() []IElement {
 	q.m.RLock()
@@ -132,20 +153,13 @@ func (q *OrderedQueue) Elements() []IElement {
 
 // GetOne an element from queue identified by element.GetID()
 func (q *OrderedQueue) GetOne(item IElement) (interface{}, bool) {
-	// fmt.Printf("Removing element %s finding...\n", item.GetID())
 	q.m.RLock()
-	idx := q.findElementIndex(item)
+	existing, ok := q.index[item.GetID()]
 	q.m.RUnlock()
-	if 0 > idx {
+	if !ok {
 		return item, false
 	}
-	return item, true
-	// for _, e := range q.queue {
-	// 	if e.GetID() == item.GetID() {
-	// 		return item, true
-	// 	}
-	// }
-	// return nil, false
+	return existing, true
 }
 
 // FindElements by compaire condition
@@ -164,33 +178,27 @@ func (q *OrderedQueue) FindElements(cmp *definations.ComparisonObject) []IElemen
 	return elements
 }
 
+// findElementIndex searches the element among the elements of the same ordering value by binary searching,
+// the whole queue is scanned if the ordering value of element changed after added
 func (q *OrderedQueue) findElementIndex(item IElement) int {
 	l := len(q.queue)
-	if 0 >= l {
-		return -1
-	}
-	idx := findOrderedQueueInsertingIndex(&q.queue, l, item, q.ordering)
-	cursor := idx
-	max := idx + 2
-	min := idx - 2
-	if max > l {
-		max = l
-	}
-	if -1 > min {
-		min = -1
-	}
-	for cursor < max {
-		if item.GetID() == q.queue[cursor].GetID() {
-			return cursor
+	value := item.OrderingValue()
+	idx := sort.Search(l, func(i int) bool {
+		if OrderingDesc == q.ordering {
+			return q.queue[i].OrderingValue() <= value
+		}
+		return q.queue[i].OrderingValue() >= value
+	})
+	ID := item.GetID()
+	for ; idx < l && q.queue[idx].OrderingValue() == value; idx++ {
+		if ID == q.queue[idx].GetID() {
+			return idx
 		}
-		cursor++
 	}
-	cursor = idx - 1
-	for cursor > min {
-		if item.GetID() == q.queue[cursor].GetID() {
-			return cursor
+	for i, e := range q.queue {
+		if ID == e.GetID() {
+			return i
 		}
-		cursor--
 	}
 	return -1
 }
@@ -198,14 +206,12 @@ func (q *OrderedQueue) findElementIndex(item IElement) int {
 // GetElement get element by id
 func (q *OrderedQueue) GetElement(ID string) (interface{}, bool) {
 	q.m.RLock()
-	for _, e := range q.queue {
-		if e.GetID() == ID {
-			q.m.RUnlock()
-			return e, true
-		}
-	}
+	e, ok := q.index[ID]
 	q.m.RUnlock()
-	return nil, false
+	if !ok {
+		return nil, false
+	}
+	return e, true
 }
 
 // Dump element in queue
@@ -228,11 +234,13 @@ func (q *OrderedQueue) CutBefore(idx int) []IElement {
 	if len(q.queue) >= idx {
 		cuts := q.queue
 		q.queue = []IElement{}
+		q.index = map[string]IElement{}
 		q.m.Unlock()
 		return cuts
 	}
 	cuts := q.queue[:idx]
 	q.queue = q.queue[idx:]
+	q.unindex(cuts)
 	q.m.Unlock()
 	return cuts
 }
@@ -243,6 +251,7 @@ func (q *OrderedQueue) CutAfter(idx int) []IElement {
 	if 0 > idx {
 		cuts := q.queue
 		q.queue = []IElement{}
+		q.index = map[string]IElement{}
 		q.m.Unlock()
 		return cuts
 	} else if len(q.queue) >= idx {
@@ -251,10 +260,18 @@ func (q *OrderedQueue) CutAfter(idx int) []IElement {
 	}
 	cuts := q.queue[idx+1:]
 	q.queue = q.queue[:idx+1]
+	q.unindex(cuts)
 	q.m.Unlock()
 	return cuts
 }
 
+// unindex removes the index of cut elements, caller should hold the lock
+func (q *OrderedQueue) unindex(cuts []IElement) {
+	for _, e := range cuts {
+		delete(q.index, e.GetID())
+	}
+}
+
 // GetSize of queue
 func (q *OrderedQueue) GetSize() int {
 	q.m.RLock()
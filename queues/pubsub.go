@@ -0,0 +1,318 @@
+package queues
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libpub/golib/logger"
+)
+
+// Constants of pub/sub
+const (
+	DefaultSubscriberBufferSize = 1024
+
+	// LoggerModule the logger module of queues, the level could be changed at runtime by logger.SetModuleLevel
+	LoggerModule = "queues"
+)
+
+var logs = logger.NewModuleLoggers(LoggerModule)
+
+// SlowSubscriberPolicy how the publishing handles the subscriber of which the buffer is full
+type SlowSubscriberPolicy int
+
+// Slow subscriber policies
+const (
+	SlowSubscriberDropNewest  = SlowSubscriberPolicy(0) // the published message is dropped for the subscriber
+	SlowSubscriberDropOldest  = SlowSubscriberPolicy(1) // the oldest buffered message is overwritten
+	SlowSubscriberBlock       = SlowSubscriberPolicy(2) // the publisher waits until the buffer has room
+	SlowSubscriberUnsubscribe = SlowSubscriberPolicy(3) // the subscriber is unsubscribed
+)
+
+// ErrPubSubClosed the pub/sub is closed
+var ErrPubSubClosed = errors.New("pub/sub closed")
+
+// Message published message
+type Message struct {
+	ID          string
+	Topic       string
+	Payload     interface{}
+	PublishedAt time.Time
+}
+
+// GetID id of message
+func (m *Message) GetID() string {
+	return m.ID
+}
+
+// GetName topic of message
+func (m *Message) GetName() string {
+	return m.Topic
+}
+
+// OrderingValue published time in nanoseconds
+func (m *Message) OrderingValue() int64 {
+	return m.PublishedAt.UnixNano()
+}
+
+// DebugString text
+func (m *Message) DebugString() string {
+	return fmt.Sprintf("%s#%s:%v", m.Topic, m.ID, m.Payload)
+}
+
+// MessageHandler handles the messages of subscription in the subscription goroutine
+type MessageHandler func(msg *Message)
+
+// SubscribeOption options of subscription
+type SubscribeOption func(*Subscription)
+
+// WithBufferSize the capacity of the buffered messages of subscription
+func WithBufferSize(size int) SubscribeOption {
+	return func(s *Subscription) {
+		s.bufferSize = size
+	}
+}
+
+// WithSlowSubscriberPolicy how the publishing handles the full buffer of subscription
+func WithSlowSubscriberPolicy(policy SlowSubscriberPolicy) SubscribeOption {
+	return func(s *Subscription) {
+		s.policy = policy
+	}
+}
+
+// PubSub in-process publish and subscribe by topics, every subscription buffers the messages in its ring queue
+// and handles them in its own goroutine in publishing order
+type PubSub struct {
+	topics map[string][]*Subscription
+	closed bool
+	seq    uint64
+	wg     sync.WaitGroup
+	m      sync.RWMutex
+}
+
+// Subscription subscriber of topic
+type Subscription struct {
+	Topic      string
+	pubsub     *PubSub
+	handler    MessageHandler
+	queue      *RingQueue
+	bufferSize int
+	policy     SlowSubscriberPolicy
+	dropped    uint64
+	ready      chan struct{}
+	space      chan struct{}
+	done       chan struct{}
+	once       sync.Once
+}
+
+// NewPubSub new pub/sub
+func NewPubSub() *PubSub {
+	return &PubSub{topics: map[string][]*Subscription{}}
+}
+
+// Subscribe handles the messages published to topic by handler
+func (p *PubSub) Subscribe(topic string, handler MessageHandler, options ...SubscribeOption) (*Subscription, error) {
+	s := &Subscription{
+		Topic:      topic,
+		pubsub:     p,
+		handler:    handler,
+		bufferSize: DefaultSubscriberBufferSize,
+		ready:      make(chan struct{}, 1),
+		space:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	mode := RingRejectNewest
+	if SlowSubscriberDropOldest == s.policy {
+		mode = RingOverwriteOldest
+	}
+	s.queue = NewRingQueue(s.bufferSize, mode)
+
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.closed {
+		return nil, ErrPubSubClosed
+	}
+	p.topics[topic] = append(p.topics[topic], s)
+	p.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Publish the payload to the subscribers of topic and returns the count of subscribers that buffered the message,
+// the publishing waits for the subscribers of SlowSubscriberBlock policy until ctx done
+func (p *PubSub) Publish(ctx context.Context, topic string, payload interface{}) (int, error) {
+	p.m.RLock()
+	if p.closed {
+		p.m.RUnlock()
+		return 0, ErrPubSubClosed
+	}
+	subscriptions := append([]*Subscription{}, p.topics[topic]...)
+	p.m.RUnlock()
+	msg := &Message{
+		ID:          strconv.FormatUint(atomic.AddUint64(&p.seq, 1), 10),
+		Topic:       topic,
+		Payload:     payload,
+		PublishedAt: time.Now(),
+	}
+	n := 0
+	for _, s := range subscriptions {
+		ok, err := s.push(ctx, msg)
+		if nil != err {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Topics the topics having subscribers
+func (p *PubSub) Topics() []string {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	topics := make([]string, 0, len(p.topics))
+	for topic := range p.topics {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// Close stops publishing and waits for the subscriptions handling their buffered messages until ctx done
+func (p *PubSub) Close(ctx context.Context) error {
+	p.m.Lock()
+	p.closed = true
+	topics := p.topics
+	p.topics = map[string][]*Subscription{}
+	p.m.Unlock()
+	for _, subscriptions := range topics {
+		for _, s := range subscriptions {
+			s.stop()
+		}
+	}
+	finished := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unsubscribe removes the subscription, the buffered messages are still handled
+func (s *Subscription) Unsubscribe() {
+	p := s.pubsub
+	p.m.Lock()
+	subscriptions := p.topics[s.Topic]
+	for i, subscription := range subscriptions {
+		if s == subscription {
+			subscriptions = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+	if len(subscriptions) == 0 {
+		delete(p.topics, s.Topic)
+	} else {
+		p.topics[s.Topic] = subscriptions
+	}
+	p.m.Unlock()
+	s.stop()
+}
+
+// Pending count of the buffered messages
+func (s *Subscription) Pending() int {
+	return s.queue.GetSize()
+}
+
+// Dropped count of the messages dropped by the slow subscriber policy
+func (s *Subscription) Dropped() uint64 {
+	if SlowSubscriberDropOldest == s.policy {
+		return s.queue.Dropped()
+	}
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) push(ctx context.Context, msg *Message) (bool, error) {
+	for {
+		select {
+		case <-s.done:
+			return false, nil
+		default:
+		}
+		if s.queue.Push(msg) {
+			notify(s.ready)
+			return true, nil
+		}
+		switch s.policy {
+		case SlowSubscriberBlock:
+			select {
+			case <-s.space:
+			case <-s.done:
+				return false, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		case SlowSubscriberUnsubscribe:
+			atomic.AddUint64(&s.dropped, 1)
+			logs.Warning.Printf("unsubscribing the slow subscriber of topic:%s with %d pending messages", s.Topic, s.Pending())
+			s.Unsubscribe()
+			return false, nil
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+			return false, nil
+		}
+	}
+}
+
+func (s *Subscription) stop() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *Subscription) run() {
+	defer s.pubsub.wg.Done()
+	for {
+		item, ok := s.queue.Pop()
+		if !ok {
+			select {
+			case <-s.ready:
+				continue
+			case <-s.done:
+				if s.queue.GetSize() > 0 {
+					continue
+				}
+				return
+			}
+		}
+		notify(s.space)
+		s.handle(item.(*Message))
+	}
+}
+
+func (s *Subscription) handle(msg *Message) {
+	defer func() {
+		if r := recover(); nil != r {
+			logs.Error.Printf("handling message %s of topic:%s panic:%v", msg.ID, s.Topic, r)
+		}
+	}()
+	s.handler(msg)
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,267 @@
+package queues
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/libpub/golib/definations"
+)
+
+// RingOverflowMode how the full ring queue handles the pushed element
+type RingOverflowMode int
+
+// Ring overflow modes
+const (
+	RingOverwriteOldest = RingOverflowMode(0)
+	RingRejectNewest    = RingOverflowMode(1)
+)
+
+// RingQueue fixed capacity queue of which the buffer is allocated once, the full queue overwrites the
+// oldest element or rejects the pushed one by the overflow mode
+type RingQueue struct {
+	buffer  []IElement
+	head    int
+	size    int
+	mode    RingOverflowMode
+	dropped uint64
+	m       sync.RWMutex
+}
+
+// NewRingQueue new ring queue of capacity
+func NewRingQueue(capacity int, mode RingOverflowMode) *RingQueue {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &RingQueue{
+		buffer: make([]IElement, capacity),
+		mode:   mode,
+	}
+}
+
+// Push item, false if the queue is full in RingRejectNewest mode
+func (q *RingQueue) Push(item IElement) bool {
+	q.m.Lock()
+	capacity := len(q.buffer)
+	if q.size == capacity {
+		q.dropped++
+		if RingRejectNewest == q.mode {
+			q.m.Unlock()
+			droppedCounter.Inc(metricsRingQueue)
+			return false
+		}
+		q.buffer[q.head] = item
+		q.head = (q.head + 1) % capacity
+		q.m.Unlock()
+		droppedCounter.Inc(metricsRingQueue)
+		pushedCounter.Inc(metricsRingQueue)
+		return true
+	}
+	q.buffer[(q.head+q.size)%capacity] = item
+	q.size++
+	q.m.Unlock()
+	pushedCounter.Inc(metricsRingQueue)
+	return true
+}
+
+// Pop first item
+func (q *RingQueue) Pop() (interface{}, bool) {
+	q.m.Lock()
+	if 0 == q.size {
+		q.m.Unlock()
+		return nil, false
+	}
+	item := q.popHead()
+	q.m.Unlock()
+	poppedCounter.Inc(metricsRingQueue)
+	return item, true
+}
+
+// PopMany head elements from queue limited by maxResults, the element would be deleted from queue
+func (q *RingQueue) PopMany(maxResults int) ([]interface{}, int) {
+	q.m.Lock()
+	maxLen := q.size
+	if 0 >= maxLen || 0 >= maxResults {
+		q.m.Unlock()
+		return nil, 0
+	}
+	if maxLen > maxResults {
+		maxLen = maxResults
+	}
+	items := make([]interface{}, maxLen)
+	for i := 0; i < maxLen; i++ {
+		items[i] = q.popHead()
+	}
+	q.m.Unlock()
+	poppedCounter.Add(float64(maxLen), metricsRingQueue)
+	return items, maxLen
+}
+
+// popHead caller should hold the lock and make sure the queue is not empty
+func (q *RingQueue) popHead() IElement {
+	item := q.buffer[q.head]
+	q.buffer[q.head] = nil
+	q.head = (q.head + 1) % len(q.buffer)
+	q.size--
+	return item
+}
+
+// First item without pop
+func (q *RingQueue) First() (interface{}, bool) {
+	q.m.RLock()
+	defer q.m.RUnlock()
+	if 0 == q.size {
+		return nil, false
+	}
+	return q.buffer[q.head], true
+}
+
+// Remove an element from queue identified by element.GetID()
+func (q *RingQueue) Remove(item IElement) bool {
+	q.m.Lock()
+	defer q.m.Unlock()
+	idx := q.indexOf(item.GetID())
+	if 0 > idx {
+		return false
+	}
+	capacity := len(q.buffer)
+	for i := idx; i < q.size-1; i++ {
+		q.buffer[(q.head+i)%capacity] = q.buffer[(q.head+i+1)%capacity]
+	}
+	q.buffer[(q.head+q.size-1)%capacity] = nil
+	q.size--
+	return true
+}
+
+// indexOf the element position from head, caller should hold the lock
+func (q *RingQueue) indexOf(ID string) int {
+	for i := 0; i < q.size; i++ {
+		if ID == q.at(i).GetID() {
+			return i
+		}
+	}
+	return -1
+}
+
+// at the element of position from head, caller should hold the lock
+func (q *RingQueue) at(i int) IElement {
+	return q.buffer[(q.head+i)%len(q.buffer)]
+}
+
+// Elements of all queue
+func (q *RingQueue) Elements() []IElement {
+	q.m.RLock()
+	elements := make([]IElement, q.size)
+	for i := range elements {
+		elements[i] = q.at(i)
+	}
+	q.m.RUnlock()
+	return elements
+}
+
+// FindElements by compaire condition
+func (q *RingQueue) FindElements(cmp *definations.ComparisonObject) []IElement {
+	elements := []IElement{}
+	if nil == cmp {
+		return elements
+	}
+	q.m.RLock()
+	for i := 0; i < q.size; i++ {
+		if e := q.at(i); cmp.Evaluate(e) {
+			elements = append(elements, e)
+		}
+	}
+	q.m.RUnlock()
+	return elements
+}
+
+// Dump element in queue
+func (q *RingQueue) Dump() string {
+	result := []string{}
+	q.m.RLock()
+	for i := 0; i < q.size; i++ {
+		result = append(result, q.at(i).DebugString())
+	}
+	q.m.RUnlock()
+	return strings.Join(result, " ")
+}
+
+// GetOne an element from queue identified by element.GetID()
+func (q *RingQueue) GetOne(item IElement) (interface{}, bool) {
+	q.m.RLock()
+	defer q.m.RUnlock()
+	idx := q.indexOf(item.GetID())
+	if 0 > idx {
+		return item, false
+	}
+	return q.at(idx), true
+}
+
+// GetElement get element by id
+func (q *RingQueue) GetElement(ID string) (interface{}, bool) {
+	q.m.RLock()
+	defer q.m.RUnlock()
+	idx := q.indexOf(ID)
+	if 0 > idx {
+		return nil, false
+	}
+	return q.at(idx), true
+}
+
+// CutBefore cut elements out before index
+func (q *RingQueue) CutBefore(idx int) []IElement {
+	if 0 >= idx {
+		return []IElement{}
+	}
+	q.m.Lock()
+	if idx > q.size {
+		idx = q.size
+	}
+	cuts := make([]IElement, idx)
+	for i := range cuts {
+		cuts[i] = q.popHead()
+	}
+	q.m.Unlock()
+	return cuts
+}
+
+// CutAfter cut elements out after index
+func (q *RingQueue) CutAfter(idx int) []IElement {
+	q.m.Lock()
+	if 0 > idx {
+		idx = -1
+	}
+	if idx+1 >= q.size {
+		q.m.Unlock()
+		return []IElement{}
+	}
+	cuts := make([]IElement, q.size-idx-1)
+	for i := range cuts {
+		pos := (q.head + idx + 1 + i) % len(q.buffer)
+		cuts[i] = q.buffer[pos]
+		q.buffer[pos] = nil
+	}
+	q.size = idx + 1
+	q.m.Unlock()
+	return cuts
+}
+
+// GetSize of queue
+func (q *RingQueue) GetSize() int {
+	q.m.RLock()
+	n := q.size
+	q.m.RUnlock()
+	return n
+}
+
+// Capacity of queue
+func (q *RingQueue) Capacity() int {
+	return len(q.buffer)
+}
+
+// Dropped count of the elements overwritten or rejected
+func (q *RingQueue) Dropped() uint64 {
+	q.m.RLock()
+	n := q.dropped
+	q.m.RUnlock()
+	return n
+}
@@ -0,0 +1,116 @@
+package queues
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SnapshotFormat encoding of queue snapshots
+type SnapshotFormat string
+
+// Snapshot formats
+const (
+	SnapshotJSON = SnapshotFormat("json")
+	SnapshotGob  = SnapshotFormat("gob")
+)
+
+// ErrUnknownSnapshotFormat the snapshot format is neither json nor gob
+var ErrUnknownSnapshotFormat = errors.New("unknown snapshot format")
+
+// ElementFactory creates the empty element that the snapshotted element decoded into, it should be a pointer
+type ElementFactory func() IElement
+
+// Marshal snapshots the elements in queue order
+func (q *FIFOQueue) Marshal(format SnapshotFormat) ([]byte, error) {
+	return marshalElements(q.Elements(), format)
+}
+
+// Unmarshal restores the snapshotted elements created by factory, the elements in queue are replaced
+func (q *FIFOQueue) Unmarshal(data []byte, format SnapshotFormat, factory ElementFactory) error {
+	elements, err := unmarshalElements(data, format, factory)
+	if nil != err {
+		return err
+	}
+	q.m.Lock()
+	q.queue = elements
+	q.m.Unlock()
+	return nil
+}
+
+// Marshal snapshots the elements in queue order
+func (q *OrderedQueue) Marshal(format SnapshotFormat) ([]byte, error) {
+	return marshalElements(q.Elements(), format)
+}
+
+// Unmarshal restores the snapshotted elements created by factory, the elements in queue are replaced and
+// ordered by the ordering mode of queue
+func (q *OrderedQueue) Unmarshal(data []byte, format SnapshotFormat, factory ElementFactory) error {
+	elements, err := unmarshalElements(data, format, factory)
+	if nil != err {
+		return err
+	}
+	q.m.Lock()
+	q.queue = []IElement{}
+	q.index = map[string]IElement{}
+	q.m.Unlock()
+	for _, e := range elements {
+		q.Add(e)
+	}
+	return nil
+}
+
+func marshalElements(elements []IElement, format SnapshotFormat) ([]byte, error) {
+	switch format {
+	case SnapshotJSON:
+		return json.Marshal(elements)
+	case SnapshotGob:
+		buf := bytes.Buffer{}
+		encoder := gob.NewEncoder(&buf)
+		if err := encoder.Encode(len(elements)); nil != err {
+			return nil, err
+		}
+		for _, e := range elements {
+			if err := encoder.Encode(e); nil != err {
+				return nil, fmt.Errorf("encoding element %s failed with error:%w", e.GetID(), err)
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w:%s", ErrUnknownSnapshotFormat, format)
+}
+
+func unmarshalElements(data []byte, format SnapshotFormat, factory ElementFactory) ([]IElement, error) {
+	switch format {
+	case SnapshotJSON:
+		raws := []json.RawMessage{}
+		if err := json.Unmarshal(data, &raws); nil != err {
+			return nil, err
+		}
+		elements := make([]IElement, len(raws))
+		for i, raw := range raws {
+			elements[i] = factory()
+			if err := json.Unmarshal(raw, elements[i]); nil != err {
+				return nil, fmt.Errorf("decoding element %d failed with error:%w", i, err)
+			}
+		}
+		return elements, nil
+	case SnapshotGob:
+		decoder := gob.NewDecoder(bytes.NewReader(data))
+		n := 0
+		if err := decoder.Decode(&n); nil != err {
+			return nil, err
+		}
+		elements := make([]IElement, n)
+		for i := range elements {
+			elements[i] = factory()
+			if err := decoder.Decode(elements[i]); nil != err {
+				return nil, fmt.Errorf("decoding element %d failed with error:%w", i, err)
+			}
+		}
+		return elements, nil
+	}
+	return nil, fmt.Errorf("%w:%s", ErrUnknownSnapshotFormat, format)
+}
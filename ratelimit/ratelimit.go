@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/libpub/golib/errors"
+)
+
+// errors
+var (
+	ErrLimitExceeded = errors.NewError(errors.TooManyRequests, "rate limit exceeded")
+)
+
+// Limiter limits the rate of events of each key
+type Limiter interface {
+	// Take consumes an event of key if allowed, otherwise returns the duration to wait before taking again
+	Take(ctx context.Context, key string) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Config limits Limit events every Interval of each key
+type Config struct {
+	Limit    int
+	Interval time.Duration
+	Burst    int              // capacity of token bucket, Limit if 0
+	Now      func() time.Time // time.Now if nil
+}
+
+// Allow takes an event of key without waiting, the errors of limiter are treated as not allowed
+func Allow(ctx context.Context, l Limiter, key string) bool {
+	ok, _, err := l.Take(ctx, key)
+	return ok && nil == err
+}
+
+// Wait takes an event of key, waits until allowed or ctx done, ErrLimitExceeded is returned without waiting
+// if the event would not be allowed before the deadline of ctx
+func Wait(ctx context.Context, l Limiter, key string) error {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	for {
+		ok, retryAfter, err := l.Take(ctx, key)
+		if nil != err || ok {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < retryAfter {
+			return ErrLimitExceeded
+		}
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Config) now() time.Time {
+	if nil != c.Now {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// keyedStates the states of keys in memory, the keys idle longer than ttl are removed by the sweeping on access
+type keyedStates struct {
+	m         sync.Mutex
+	states    map[string]*keyState
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+type keyState struct {
+	value    float64 // tokens of bucket, or count of the current window
+	previous float64 // count of the previous window
+	at       time.Time
+	window   int64
+}
+
+func newKeyedStates(ttl time.Duration) *keyedStates {
+	return &keyedStates{states: map[string]*keyState{}, ttl: ttl}
+}
+
+// get the state of key with m locked, the new state is created by init
+func (s *keyedStates) get(key string, now time.Time, init func() *keyState) *keyState {
+	if now.Sub(s.lastSweep) >= s.ttl {
+		for k, state := range s.states {
+			if now.Sub(state.at) >= s.ttl {
+				delete(s.states, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	state, ok := s.states[key]
+	if !ok {
+		state = init()
+		s.states[key] = state
+	}
+	return state
+}
+
+// Len count of the keys in memory
+func (s *keyedStates) Len() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.states)
+}
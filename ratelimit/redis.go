@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Constants of redis
+const (
+	DefaultRedisKeyPrefix = "ratelimit:"
+)
+
+// slidingWindowScript counts an event in the current window KEYS[1] if the count of previous window KEYS[2]
+// weighted by ARGV[2] added by the current count is less than limit ARGV[1], returns {allowed, previous, current}
+var slidingWindowScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+if previous * tonumber(ARGV[2]) + current >= tonumber(ARGV[1]) then
+	return {0, previous, current}
+end
+current = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return {1, previous, current}`)
+
+// RedisSlidingWindow distributed sliding window limiter, the count of each window is kept by key
+// {prefix}{key}:{window} expiring after two windows, so that the limit is shared by the instances
+type RedisSlidingWindow struct {
+	client redis.UniversalClient
+	prefix string
+	config Config
+}
+
+// NewRedisSlidingWindow sliding window limiter of redis client, DefaultRedisKeyPrefix is used if prefix empty
+func NewRedisSlidingWindow(client redis.UniversalClient, prefix string, config Config) *RedisSlidingWindow {
+	if "" == prefix {
+		prefix = DefaultRedisKeyPrefix
+	}
+	return &RedisSlidingWindow{client: client, prefix: prefix, config: config}
+}
+
+// Take counts an event of key if the weighted count is less than Limit
+func (w *RedisSlidingWindow) Take(ctx context.Context, key string) (bool, time.Duration, error) {
+	if w.config.Limit <= 0 || w.config.Interval <= 0 {
+		return false, 0, ErrLimitExceeded
+	}
+	window, elapsed := windowOf(w.config.now(), w.config.Interval)
+	// the hash tag keeps the windows of key in the same slot of cluster
+	windowKey := w.prefix + "{" + key + "}:"
+	weight := 1 - float64(elapsed)/float64(w.config.Interval)
+	result, err := slidingWindowScript.Run(w.client,
+		[]string{windowKey + strconv.FormatInt(window, 10), windowKey + strconv.FormatInt(window-1, 10)},
+		w.config.Limit, strconv.FormatFloat(weight, 'f', 6, 64), milliseconds(2*w.config.Interval)).Result()
+	if nil != err {
+		return false, 0, err
+	}
+	values, _ := result.([]interface{})
+	if len(values) != 3 {
+		return false, 0, fmt.Errorf("unexpected reply of rate limit script:%v", result)
+	}
+	allowed, _ := values[0].(int64)
+	if 1 == allowed {
+		return true, 0, nil
+	}
+	previous, _ := values[1].(int64)
+	current, _ := values[2].(int64)
+	_, retryAfter := slidingWindowAllows(w.config.Limit, w.config.Interval, float64(previous), float64(current), elapsed)
+	return false, retryAfter, nil
+}
+
+// milliseconds of d, at least 1
+func milliseconds(d time.Duration) int64 {
+	ms := int64(d / time.Millisecond)
+	if ms < 1 {
+		ms = 1
+	}
+	return ms
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// SlidingWindow limiter of sliding windows in memory, the count of the previous window is weighted by its
+// overlap with the sliding window ending now, the keys without events in two windows are removed
+type SlidingWindow struct {
+	config Config
+	*keyedStates
+}
+
+// NewSlidingWindow sliding window limiter of config, Burst is ignored
+func NewSlidingWindow(config Config) *SlidingWindow {
+	return &SlidingWindow{config: config, keyedStates: newKeyedStates(2 * config.Interval)}
+}
+
+// Take counts an event of key if the weighted count is less than Limit
+func (w *SlidingWindow) Take(ctx context.Context, key string) (bool, time.Duration, error) {
+	if w.config.Limit <= 0 || w.config.Interval <= 0 {
+		return false, 0, ErrLimitExceeded
+	}
+	now := w.config.now()
+	window, elapsed := windowOf(now, w.config.Interval)
+	w.m.Lock()
+	defer w.m.Unlock()
+	state := w.get(key, now, func() *keyState {
+		return &keyState{window: window}
+	})
+	switch {
+	case window == state.window+1:
+		state.previous, state.value = state.value, 0
+	case window != state.window:
+		state.previous, state.value = 0, 0
+	}
+	state.window = window
+	state.at = now
+	if ok, retryAfter := slidingWindowAllows(w.config.Limit, w.config.Interval, state.previous, state.value, elapsed); !ok {
+		return false, retryAfter, nil
+	}
+	state.value++
+	return true, 0, nil
+}
+
+// windowOf the index of the fixed window containing t and the duration elapsed in it
+func windowOf(t time.Time, interval time.Duration) (int64, time.Duration) {
+	nanos := t.UnixNano()
+	return nanos / int64(interval), time.Duration(nanos % int64(interval))
+}
+
+// slidingWindowAllows whether an event is allowed by the counts of the previous and current windows,
+// otherwise the duration until the weighted count decreased below limit
+func slidingWindowAllows(limit int, interval time.Duration, previous float64, current float64, elapsed time.Duration) (bool, time.Duration) {
+	weight := 1 - float64(elapsed)/float64(interval)
+	if previous*weight+current < float64(limit) {
+		return true, 0
+	}
+	if current < float64(limit) {
+		// previous*(1-(elapsed+d)/interval) < limit-current
+		d := float64(interval)*(1-(float64(limit)-current)/previous) - float64(elapsed)
+		return false, time.Duration(d) + 1
+	}
+	// the current window becomes the previous one, current*(1-d'/interval) < limit
+	d := float64(interval-elapsed) + float64(interval)*(1-float64(limit)/current)
+	return false, time.Duration(d) + 1
+}
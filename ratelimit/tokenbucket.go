@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// TokenBucket limiter of token buckets in memory, each key has Burst tokens at most refilled by Limit every
+// Interval, the buckets refilled fully are removed
+type TokenBucket struct {
+	config Config
+	rate   float64 // tokens per nanosecond
+	*keyedStates
+}
+
+// NewTokenBucket token bucket limiter of config
+func NewTokenBucket(config Config) *TokenBucket {
+	if config.Burst <= 0 {
+		config.Burst = config.Limit
+	}
+	b := &TokenBucket{config: config}
+	if config.Limit > 0 && config.Interval > 0 {
+		b.rate = float64(config.Limit) / float64(config.Interval)
+	}
+	ttl := config.Interval
+	if b.rate > 0 {
+		ttl = time.Duration(float64(config.Burst) / b.rate)
+	}
+	b.keyedStates = newKeyedStates(ttl)
+	return b
+}
+
+// Take consumes a token of key
+func (b *TokenBucket) Take(ctx context.Context, key string) (bool, time.Duration, error) {
+	if b.rate <= 0 {
+		return false, 0, ErrLimitExceeded
+	}
+	now := b.config.now()
+	b.m.Lock()
+	defer b.m.Unlock()
+	state := b.get(key, now, func() *keyState {
+		return &keyState{value: float64(b.config.Burst), at: now}
+	})
+	if elapsed := now.Sub(state.at); elapsed > 0 {
+		state.value += float64(elapsed) * b.rate
+		if state.value > float64(b.config.Burst) {
+			state.value = float64(b.config.Burst)
+		}
+	}
+	state.at = now
+	if state.value >= 1 {
+		state.value--
+		return true, 0, nil
+	}
+	return false, time.Duration((1-state.value)/b.rate) + 1, nil
+}
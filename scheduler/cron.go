@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Schedule decides the next run time of job
+type Schedule interface {
+	// Next run time after t, the zero time means no more runs
+	Next(t time.Time) time.Time
+}
+
+// ScheduleFunc function as Schedule
+type ScheduleFunc func(t time.Time) time.Time
+
+// Next run time after t
+func (f ScheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+	jitter   time.Duration
+}
+
+type onceSchedule struct {
+	at    time.Time
+	fired int32
+}
+
+var (
+	_random      = rand.New(rand.NewSource(time.Now().UnixNano()))
+	_randomMutex = sync.Mutex{}
+)
+
+// ParseCron parse cron expression by robfig/cron as the rotation of logger does, 5 fields "minute hour dom month dow"
+// are parsed as standard spec and 6 fields with leading second, the descriptors such as @daily and "@every 1m30s"
+// are supported, the times are calculated in the location of given time
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	var schedule cron.Schedule
+	var err error
+	if 5 == len(strings.Fields(expr)) {
+		schedule, err = cron.ParseStandard(expr)
+	} else {
+		schedule, err = cron.Parse(expr)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+	}
+	return schedule, nil
+}
+
+// MustParseCron parse cron expression, panics if invalid
+func MustParseCron(expr string) Schedule {
+	s, err := ParseCron(expr)
+	if nil != err {
+		panic(err)
+	}
+	return s
+}
+
+// Every schedule of fixed interval, each run is delayed randomly in [0, jitter) more to spread the runs
+func Every(interval time.Duration, jitter time.Duration) (Schedule, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %v", interval)
+	}
+	if jitter < 0 {
+		return nil, fmt.Errorf("invalid jitter %v", jitter)
+	}
+	return &intervalSchedule{interval: interval, jitter: jitter}, nil
+}
+
+// Next run time after t
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	next := t.Add(s.interval)
+	if s.jitter > 0 {
+		_randomMutex.Lock()
+		next = next.Add(time.Duration(_random.Int63n(int64(s.jitter))))
+		_randomMutex.Unlock()
+	}
+	return next
+}
+
+// At schedule of running once at t, it runs immediately if t is passed, the schedule should not be shared by jobs
+func At(t time.Time) Schedule {
+	return &onceSchedule{at: t}
+}
+
+// Next run time at first, then zero
+func (s *onceSchedule) Next(t time.Time) time.Time {
+	if !atomic.CompareAndSwapInt32(&s.fired, 0, 1) {
+		return time.Time{}
+	}
+	if s.at.Before(t) {
+		return t
+	}
+	return s.at
+}
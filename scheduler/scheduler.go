@@ -0,0 +1,458 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/queues"
+	"github.com/libpub/golib/utils/timeutil"
+)
+
+// errors
+var (
+	ErrJobExists   = errors.New("job already exists")
+	ErrNoNextRun   = errors.New("job has no next run")
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// Job the scheduled function, the ctx is canceled when the job is removed or the scheduler stopped
+type Job func(ctx context.Context) error
+
+// Locker distributed locking hook so that the job runs on only one of instances, TryLock returns false
+// if the lock is held by others, the unlock is called after the job finished
+type Locker interface {
+	TryLock(ctx context.Context, name string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+// Option options of scheduler
+type Option func(*Scheduler)
+
+// JobOption options of job
+type JobOption func(*jobEntry)
+
+// JobInfo status of job
+type JobInfo struct {
+	Name      string
+	Next      time.Time
+	LastRun   time.Time
+	LastError error
+	Runs      int64
+	Skips     int64
+	Running   bool
+}
+
+// Scheduler runs the jobs by their schedules, the pending runs are ordered in the ascending ordered queue
+// and the jobs run in their own goroutines with panics recovered
+type Scheduler struct {
+	queue    *queues.OrderedQueue
+	jobs     map[string]*jobEntry
+	mutex    sync.Mutex
+	location *time.Location
+	clock    timeutil.Clock
+	locker   Locker
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wake     chan struct{}
+	done     chan struct{}
+	started  bool
+	wg       sync.WaitGroup
+	seq      uint64
+}
+
+type jobEntry struct {
+	id           string
+	name         string
+	schedule     Schedule
+	job          Job
+	timeout      time.Duration
+	lockTTL      time.Duration
+	allowOverlap bool
+	next         time.Time
+	ctx          context.Context
+	cancel       context.CancelFunc
+	running      int32
+	retired      int32 // no more runs, the ctx is canceled once the running ones finished
+
+	mutex     sync.Mutex
+	lastRun   time.Time
+	lastError error
+	runs      int64
+	skips     int64
+}
+
+// WithLocation the location of cron schedules, time.Local by default
+func WithLocation(location *time.Location) Option {
+	return func(s *Scheduler) {
+		s.location = location
+	}
+}
+
+// WithClock the clock of the schedules and timers, timeutil.SystemClock by default, the tests could run
+// the jobs by advancing timeutil.FakeClock
+func WithClock(clock timeutil.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithLocker the distributed locking hook for the jobs WithDistributedLock
+func WithLocker(locker Locker) Option {
+	return func(s *Scheduler) {
+		s.locker = locker
+	}
+}
+
+// WithJobTimeout the job ctx is timed out after timeout
+func WithJobTimeout(timeout time.Duration) JobOption {
+	return func(e *jobEntry) {
+		e.timeout = timeout
+	}
+}
+
+// WithDistributedLock the job runs only if the lock of job name is acquired by the scheduler Locker
+func WithDistributedLock(ttl time.Duration) JobOption {
+	return func(e *jobEntry) {
+		e.lockTTL = ttl
+	}
+}
+
+// WithOverlap the job runs even though the last run is not finished, the overlapped runs are skipped by default
+func WithOverlap() JobOption {
+	return func(e *jobEntry) {
+		e.allowOverlap = true
+	}
+}
+
+// NewScheduler scheduler, it should be started by Start
+func NewScheduler(options ...Option) *Scheduler {
+	s := &Scheduler{
+		queue:    queues.NewAscOrderingQueue(),
+		jobs:     map[string]*jobEntry{},
+		location: time.Local,
+		clock:    timeutil.SystemClock,
+		wake:     make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// AddCron adds job run by cron expression, see ParseCron
+func (s *Scheduler) AddCron(name string, expr string, job Job, options ...JobOption) error {
+	schedule, err := ParseCron(expr)
+	if nil != err {
+		return err
+	}
+	return s.Add(name, schedule, job, options...)
+}
+
+// AddInterval adds job run every interval, each run is delayed randomly in [0, jitter) more
+func (s *Scheduler) AddInterval(name string, interval time.Duration, jitter time.Duration, job Job, options ...JobOption) error {
+	schedule, err := Every(interval, jitter)
+	if nil != err {
+		return err
+	}
+	return s.Add(name, schedule, job, options...)
+}
+
+// AddOnce adds job run once at t, the job is removed after running
+func (s *Scheduler) AddOnce(name string, t time.Time, job Job, options ...JobOption) error {
+	return s.Add(name, At(t), job, options...)
+}
+
+// Add adds job run by schedule, the name should be unique
+func (s *Scheduler) Add(name string, schedule Schedule, job Job, options ...JobOption) error {
+	if nil == schedule || nil == job {
+		return errors.New("schedule and job should not be empty")
+	}
+	e := &jobEntry{name: name, schedule: schedule, job: job}
+	for _, option := range options {
+		option(e)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	e.next = schedule.Next(s.now())
+	if e.next.IsZero() {
+		return fmt.Errorf("%w: %s", ErrNoNextRun, name)
+	}
+	s.seq++
+	e.id = name + "#" + strconv.FormatUint(s.seq, 10)
+	e.ctx, e.cancel = context.WithCancel(s.ctx)
+	s.jobs[name] = e
+	s.queue.Push(e)
+	s.notify()
+	return nil
+}
+
+// Remove removes job and cancels its running ctx, it does not wait for the run already dispatched which may
+// still finish, the job should check its ctx to stop early
+func (s *Scheduler) Remove(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+	delete(s.jobs, name)
+	s.queue.Remove(e)
+	e.cancel()
+	return true
+}
+
+// Trigger runs job immediately regardless of its schedule
+func (s *Scheduler) Trigger(name string) error {
+	s.mutex.Lock()
+	e, ok := s.jobs[name]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	s.execute(e)
+	return nil
+}
+
+// Jobs status of jobs
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	infos := make([]JobInfo, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		e.mutex.Lock()
+		infos = append(infos, JobInfo{
+			Name:      e.name,
+			Next:      e.next,
+			LastRun:   e.lastRun,
+			LastError: e.lastError,
+			Runs:      e.runs,
+			Skips:     e.skips,
+			Running:   atomic.LoadInt32(&e.running) > 0,
+		})
+		e.mutex.Unlock()
+	}
+	return infos
+}
+
+// Job status of job
+func (s *Scheduler) Job(name string) (JobInfo, bool) {
+	for _, info := range s.Jobs() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return JobInfo{}, false
+}
+
+// Start starts scheduling
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.started || nil != s.ctx.Err() {
+		return
+	}
+	s.started = true
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+// Stop stops scheduling, cancels the running jobs ctx and waits for them finished
+func (s *Scheduler) Stop() {
+	s.StopContext(context.Background())
+}
+
+// StopContext stops scheduling, cancels the running jobs ctx and waits for them finished until ctx done
+func (s *Scheduler) StopContext(ctx context.Context) error {
+	s.mutex.Lock()
+	s.cancel()
+	done := s.done
+	s.mutex.Unlock()
+	if nil != done {
+		<-done
+	}
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().In(s.location)
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	timer := s.clock.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		wait := s.dispatchDue()
+		if !timer.Stop() {
+			select {
+			case <-timer.C():
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C():
+		}
+	}
+}
+
+// dispatchDue runs the due jobs and returns the duration until the next run
+func (s *Scheduler) dispatchDue() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		item, ok := s.queue.First()
+		if !ok {
+			return time.Hour
+		}
+		e := item.(*jobEntry)
+		now := s.now()
+		if e.next.After(now) {
+			return e.next.Sub(now)
+		}
+		s.queue.Pop()
+		if s.jobs[e.name] != e {
+			// removed while the queue could not find it among the entries of the same time
+			continue
+		}
+		s.execute(e)
+		// the next run is calculated from the scheduled time so that the slow dispatching does not drift
+		base := e.next
+		if base.Before(now.Add(-time.Second)) {
+			// skip the missed runs
+			base = now
+		}
+		e.next = e.schedule.Next(base)
+		if e.next.IsZero() {
+			delete(s.jobs, e.name)
+			e.retire()
+			continue
+		}
+		s.queue.Push(e)
+	}
+}
+
+// execute runs job in a new goroutine
+func (s *Scheduler) execute(e *jobEntry) {
+	if nil != e.ctx.Err() {
+		// removed or the scheduler stopped
+		return
+	}
+	if !e.allowOverlap && !atomic.CompareAndSwapInt32(&e.running, 0, 1) {
+		e.mutex.Lock()
+		e.skips++
+		e.mutex.Unlock()
+		logger.Warning.Printf("scheduled job %s skipped since the last run is not finished", e.name)
+		return
+	}
+	if e.allowOverlap {
+		atomic.AddInt32(&e.running, 1)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			if 0 == atomic.AddInt32(&e.running, -1) && 1 == atomic.LoadInt32(&e.retired) {
+				e.cancel()
+			}
+		}()
+		ctx := e.ctx
+		if e.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, e.timeout)
+			defer cancel()
+		}
+		if e.lockTTL > 0 && nil != s.locker {
+			unlock, ok, err := s.locker.TryLock(ctx, e.name, e.lockTTL)
+			if nil != err || !ok {
+				if nil != err {
+					logger.Error.Printf("lock scheduled job %s failed with error:%v", e.name, err)
+				}
+				e.mutex.Lock()
+				e.skips++
+				e.mutex.Unlock()
+				return
+			}
+			if nil != unlock {
+				defer unlock()
+			}
+		}
+		started := s.clock.Now()
+		err := invokeJob(ctx, e)
+		e.mutex.Lock()
+		e.lastRun = started
+		e.lastError = err
+		e.runs++
+		e.mutex.Unlock()
+		if nil != err {
+			logger.Error.Printf("scheduled job %s failed with error:%v", e.name, err)
+		}
+	}()
+}
+
+// retire cancels the ctx of job without next run, the running job keeps its ctx until finished
+func (e *jobEntry) retire() {
+	atomic.StoreInt32(&e.retired, 1)
+	if 0 == atomic.LoadInt32(&e.running) {
+		e.cancel()
+	}
+}
+
+// invokeJob runs job with panic recovered as error
+func invokeJob(ctx context.Context, e *jobEntry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduled job %s panic: %v", e.name, r)
+		}
+	}()
+	return e.job(ctx)
+}
+
+// GetID queue element id
+func (e *jobEntry) GetID() string {
+	return e.id
+}
+
+// GetName queue element name
+func (e *jobEntry) GetName() string {
+	return e.name
+}
+
+// OrderingValue the next run time in milliseconds
+func (e *jobEntry) OrderingValue() int64 {
+	return e.next.UnixNano() / int64(time.Millisecond)
+}
+
+// DebugString debug string
+func (e *jobEntry) DebugString() string {
+	return fmt.Sprintf("%s next:%s", e.name, e.next.Format(time.RFC3339))
+}
@@ -0,0 +1,169 @@
+package testingutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// maxDiffLines lines of differences reported by AssertDeepEquals
+const maxDiffLines = 20
+
+// AssertError asserts err not nil
+func AssertError(t *testing.T, err error, valueName string) bool {
+	if nil != err {
+		return true
+	}
+	t.Fatalf("validate %s expected an error but got nil", valueName)
+	return false
+}
+
+// AssertErrorIs asserts err matches target by errors.Is
+func AssertErrorIs(t *testing.T, err error, target error, valueName string) bool {
+	if errors.Is(err, target) {
+		return true
+	}
+	t.Fatalf("validate %s error:%v is not expected:%v", valueName, err, target)
+	return false
+}
+
+// AssertDeepEquals asserts by reflect.DeepEqual, the differences of slices and maps are reported by elements
+func AssertDeepEquals(t *testing.T, expected interface{}, value interface{}, valueName string) bool {
+	if reflect.DeepEqual(expected, value) {
+		return true
+	}
+	t.Fatalf("validate values %s not deep equals expected:\n%s", valueName, strings.Join(diffValues(expected, value), "\n"))
+	return false
+}
+
+// AssertJSONEqual asserts the json documents are semantically equal, ignoring the key orders and spaces
+func AssertJSONEqual(t *testing.T, expected string, value string, valueName string) bool {
+	var expectedDoc, valueDoc interface{}
+	if err := json.Unmarshal([]byte(expected), &expectedDoc); nil != err {
+		t.Fatalf("validate %s with invalid expected json:%v", valueName, err)
+		return false
+	}
+	if err := json.Unmarshal([]byte(value), &valueDoc); nil != err {
+		t.Fatalf("validate %s with invalid json:%v value:%s", valueName, err, value)
+		return false
+	}
+	if reflect.DeepEqual(expectedDoc, valueDoc) {
+		return true
+	}
+	t.Fatalf("validate json %s not equals expected:\n%s", valueName, strings.Join(diffValues(expectedDoc, valueDoc), "\n"))
+	return false
+}
+
+// AssertEventually asserts condition becomes true within timeout, the condition is polled by interval
+func AssertEventually(t *testing.T, condition func() bool, timeout time.Duration, interval time.Duration, valueName string) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if condition() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+	t.Fatalf("validate %s not satisfied within %v", valueName, timeout)
+	return false
+}
+
+// AssertPanics asserts fn panics
+func AssertPanics(t *testing.T, fn func(), valueName string) (ok bool) {
+	defer func() {
+		if r := recover(); nil != r {
+			ok = true
+			return
+		}
+		t.Fatalf("validate %s expected a panic", valueName)
+	}()
+	fn()
+	return false
+}
+
+// diffValues lines describing the differences, the slices and maps are compared by elements
+func diffValues(expected interface{}, value interface{}) []string {
+	lines := []string{}
+	diffValue(&lines, "", reflect.ValueOf(expected), reflect.ValueOf(value))
+	if len(lines) > maxDiffLines {
+		more := len(lines) - maxDiffLines
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("... %d more differences", more))
+	}
+	return lines
+}
+
+func diffValue(lines *[]string, path string, expected reflect.Value, value reflect.Value) {
+	if expected.IsValid() && value.IsValid() && expected.Kind() == value.Kind() && expected.Type() == value.Type() {
+		switch expected.Kind() {
+		case reflect.Interface:
+			if !expected.IsNil() && !value.IsNil() {
+				diffValue(lines, path, expected.Elem(), value.Elem())
+				return
+			}
+		case reflect.Slice, reflect.Array:
+			size := expected.Len()
+			if value.Len() > size {
+				size = value.Len()
+			}
+			for i := 0; i < size; i++ {
+				elemPath := fmt.Sprintf("%s[%d]", path, i)
+				if i >= value.Len() {
+					*lines = append(*lines, fmt.Sprintf("%s missing, expected:%+v", elemPath, expected.Index(i)))
+				} else if i >= expected.Len() {
+					*lines = append(*lines, fmt.Sprintf("%s unexpected:%+v", elemPath, value.Index(i)))
+				} else {
+					diffValue(lines, elemPath, expected.Index(i), value.Index(i))
+				}
+			}
+			return
+		case reflect.Map:
+			keys := map[string]reflect.Value{}
+			for _, key := range append(expected.MapKeys(), value.MapKeys()...) {
+				keys[fmt.Sprint(key.Interface())] = key
+			}
+			names := make([]string, 0, len(keys))
+			for name := range keys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				elemPath := fmt.Sprintf("%s[%s]", path, name)
+				e, v := expected.MapIndex(keys[name]), value.MapIndex(keys[name])
+				if !v.IsValid() {
+					*lines = append(*lines, fmt.Sprintf("%s missing, expected:%+v", elemPath, e))
+				} else if !e.IsValid() {
+					*lines = append(*lines, fmt.Sprintf("%s unexpected:%+v", elemPath, v))
+				} else {
+					diffValue(lines, elemPath, e, v)
+				}
+			}
+			return
+		}
+		if reflect.DeepEqual(expected.Interface(), value.Interface()) {
+			return
+		}
+	}
+	if "" == path {
+		path = "value"
+	}
+	*lines = append(*lines, fmt.Sprintf("%s:%+v not equals expected:%+v", path, describeValue(value), describeValue(expected)))
+}
+
+// describeValue the value to be reported, the strings are quoted to be distinguished from numbers
+func describeValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if reflect.String == v.Kind() {
+		return strconv.Quote(v.String())
+	}
+	return v.Interface()
+}
@@ -0,0 +1,101 @@
+package testingutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// GoldenDir directory of the golden files relative to the package under test
+var GoldenDir = "testdata"
+
+// goldenDiffContext bytes around the first difference reported
+const goldenDiffContext = 32
+
+var updateGolden = flag.Bool("update", false, "update the golden files by the actual outputs")
+
+// GoldenPath path of the golden file by name
+func GoldenPath(name string) string {
+	return filepath.Join(GoldenDir, name)
+}
+
+// Golden reads the golden file by name
+func Golden(t *testing.T, name string) []byte {
+	content, err := os.ReadFile(GoldenPath(name))
+	if nil != err {
+		t.Fatalf("read golden file %s failed with error:%v, run the test with -update to create it", name, err)
+	}
+	return content
+}
+
+// AssertGolden asserts actual equals the golden file byte by byte, the golden file is written by actual
+// if the test runs with -update
+func AssertGolden(t *testing.T, name string, actual []byte) bool {
+	if *updateGolden {
+		path := GoldenPath(name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+			t.Fatalf("create golden directory of %s failed with error:%v", name, err)
+			return false
+		}
+		if err := os.WriteFile(path, actual, 0644); nil != err {
+			t.Fatalf("update golden file %s failed with error:%v", name, err)
+			return false
+		}
+		return true
+	}
+	expected := Golden(t, name)
+	if bytes.Equal(expected, actual) {
+		return true
+	}
+	t.Fatalf("validate golden file %s not equals actual output, %s", name, diffBytes(expected, actual))
+	return false
+}
+
+// AssertGoldenJSON asserts the json document actual equals the golden file after normalized by sorted keys
+// and indents, so that the key orders and spaces are ignored
+func AssertGoldenJSON(t *testing.T, name string, actual []byte) bool {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(actual))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); nil != err {
+		t.Fatalf("validate golden file %s with invalid json:%v value:%s", name, err, actual)
+		return false
+	}
+	normalized, err := json.MarshalIndent(doc, "", "  ")
+	if nil != err {
+		t.Fatalf("normalize json of golden file %s failed with error:%v", name, err)
+		return false
+	}
+	return AssertGolden(t, name, append(normalized, '\n'))
+}
+
+// diffBytes describes the first difference of the contents by offset, line, column and the bytes around
+func diffBytes(expected []byte, actual []byte) string {
+	offset := 0
+	for offset < len(expected) && offset < len(actual) && expected[offset] == actual[offset] {
+		offset++
+	}
+	line := bytes.Count(expected[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(expected[:offset], '\n')
+	start := offset - goldenDiffContext
+	if start < 0 {
+		start = 0
+	}
+	return fmt.Sprintf("first difference at byte %d (line %d column %d) of sizes expected:%d actual:%d\nexpected: %q\nactual:   %q",
+		offset, line, column, len(expected), len(actual), window(expected, start, offset), window(actual, start, offset))
+}
+
+func window(content []byte, start int, offset int) []byte {
+	end := offset + goldenDiffContext
+	if end > len(content) {
+		end = len(content)
+	}
+	if start > end {
+		start = end
+	}
+	return content[start:end]
+}
@@ -0,0 +1,244 @@
+package testingutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// MockFault fault injected by the stubbed route
+type MockFault int
+
+// Faults
+const (
+	MockFaultNone    = MockFault(0)
+	MockFaultReset   = MockFault(1) // the connection is closed without response
+	MockFaultTimeout = MockFault(2) // no response until the client gives up
+)
+
+// MockServer http server of the stubbed routes recording the received requests, the requests not matched are
+// responded with 404
+type MockServer struct {
+	*httptest.Server
+	t        *testing.T
+	m        sync.Mutex
+	routes   []*MockRoute
+	requests []RecordedRequest
+}
+
+// MockRoute stubbed route of method and path, the path ending with "*" matches the paths of the prefix
+type MockRoute struct {
+	method  string
+	path    string
+	status  int
+	header  http.Header
+	body    []byte
+	latency time.Duration
+	fault   MockFault
+	times   int
+	handler http.HandlerFunc
+	calls   int
+}
+
+// RecordedRequest request received by MockServer
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// NewMockServer starts the mock server closed on the end of t
+func NewMockServer(t *testing.T) *MockServer {
+	s := &MockServer{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// On stubs the route of method and path responding 200 without body by default, any method matches if method
+// empty, the routes are matched in the stubbed order
+func (s *MockServer) On(method string, path string) *MockRoute {
+	r := &MockRoute{method: strings.ToUpper(method), path: path, status: http.StatusOK, header: http.Header{}}
+	s.m.Lock()
+	s.routes = append(s.routes, r)
+	s.m.Unlock()
+	return r
+}
+
+// Respond with status and body
+func (r *MockRoute) Respond(status int, body string) *MockRoute {
+	r.status = status
+	r.body = []byte(body)
+	return r
+}
+
+// RespondJSON with status and the json of v
+func (r *MockRoute) RespondJSON(status int, v interface{}) *MockRoute {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.status = status
+	r.body = body
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// WithHeader responds the header
+func (r *MockRoute) WithHeader(name string, value string) *MockRoute {
+	r.header.Add(name, value)
+	return r
+}
+
+// WithLatency delays the response, the delay ends early if the client gives up
+func (r *MockRoute) WithLatency(latency time.Duration) *MockRoute {
+	r.latency = latency
+	return r
+}
+
+// WithFault injects fault instead of responding
+func (r *MockRoute) WithFault(fault MockFault) *MockRoute {
+	r.fault = fault
+	return r
+}
+
+// Times the route is matched for n times only, then the following routes are matched, unlimited if 0
+func (r *MockRoute) Times(n int) *MockRoute {
+	r.times = n
+	return r
+}
+
+// HandleFunc serves the matched requests by handler instead of the canned response
+func (r *MockRoute) HandleFunc(handler http.HandlerFunc) *MockRoute {
+	r.handler = handler
+	return r
+}
+
+func (r *MockRoute) match(method string, path string) bool {
+	if "" != r.method && r.method != method {
+		return false
+	}
+	if r.times > 0 && r.calls >= r.times {
+		return false
+	}
+	if strings.HasSuffix(r.path, "*") {
+		return strings.HasPrefix(path, r.path[:len(r.path)-1])
+	}
+	return r.path == path
+}
+
+func (s *MockServer) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	s.m.Lock()
+	s.requests = append(s.requests, RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.Query(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+	var route *MockRoute
+	for _, r := range s.routes {
+		if r.match(req.Method, req.URL.Path) {
+			route = r
+			r.calls++
+			break
+		}
+	}
+	s.m.Unlock()
+	if nil == route {
+		http.NotFound(w, req)
+		return
+	}
+	if route.latency > 0 {
+		timer := time.NewTimer(route.latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+	switch route.fault {
+	case MockFaultReset:
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); nil == err {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	case MockFaultTimeout:
+		<-req.Context().Done()
+		return
+	}
+	if nil != route.handler {
+		route.handler(w, req)
+		return
+	}
+	for name, values := range route.header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(route.status)
+	w.Write(route.body)
+}
+
+// Requests recorded in the received order
+func (s *MockServer) Requests() []RecordedRequest {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]RecordedRequest{}, s.requests...)
+}
+
+// LastRequest the last recorded request of method and path matched as MockServer.On, nil if not requested
+func (s *MockServer) LastRequest(method string, path string) *RecordedRequest {
+	requests := s.Requests()
+	pattern := &MockRoute{method: strings.ToUpper(method), path: path}
+	for i := len(requests) - 1; i >= 0; i-- {
+		if pattern.match(requests[i].Method, requests[i].Path) {
+			return &requests[i]
+		}
+	}
+	return nil
+}
+
+// AssertCalled asserts the requests of method and path matched as MockServer.On are received times
+func (s *MockServer) AssertCalled(method string, path string, times int) bool {
+	pattern := &MockRoute{method: strings.ToUpper(method), path: path}
+	calls := 0
+	for _, req := range s.Requests() {
+		if pattern.match(req.Method, req.Path) {
+			calls++
+		}
+	}
+	if calls == times {
+		return true
+	}
+	s.t.Fatalf("validate %s %s called %d times not equals expected:%d", method, path, calls, times)
+	return false
+}
+
+// AssertAllCalled asserts all the stubbed routes are matched, and the ones of Times are matched as many times
+func (s *MockServer) AssertAllCalled() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, r := range s.routes {
+		if 0 == r.calls || (r.times > 0 && r.calls < r.times) {
+			s.t.Fatalf("validate stubbed route %s %s called %d times", r.method, r.path, r.calls)
+			return false
+		}
+	}
+	return true
+}
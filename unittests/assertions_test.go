@@ -0,0 +1,33 @@
+package unittests
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestTestingutilAssertions(t *testing.T) {
+	err := fmt.Errorf("query failed:%w", errors.NewError(errors.Timeout, "deadline"))
+	testingutil.AssertError(t, err, "wrapped error")
+	testingutil.AssertErrorIs(t, err, errors.Timeout, "error category")
+
+	testingutil.AssertDeepEquals(t, []string{"a", "b"}, []string{"a", "b"}, "slices")
+	testingutil.AssertDeepEquals(t, map[string][]int{"x": {1, 2}}, map[string][]int{"x": {1, 2}}, "maps")
+	testingutil.AssertJSONEqual(t, `{"name":"alice","tags":["a","b"],"age":3}`, "{\n  \"age\": 3.0, \"tags\": [\"a\", \"b\"],\n  \"name\": \"alice\"\n}", "json documents")
+
+	var ready int32
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		atomic.StoreInt32(&ready, 1)
+	}()
+	testingutil.AssertEventually(t, func() bool { return 1 == atomic.LoadInt32(&ready) }, time.Second, 5*time.Millisecond, "ready flag")
+
+	testingutil.AssertPanics(t, func() {
+		var m map[string]int
+		m["boom"] = 1
+	}, "write nil map")
+}
@@ -0,0 +1,221 @@
+package unittests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/caching"
+	"github.com/libpub/golib/caching/cachingenv"
+	"github.com/libpub/golib/caching/redisadapter"
+	"github.com/libpub/golib/testingutil"
+)
+
+// fakeRedisCacheConn a client connection of the fake redis cache, replies and pushed messages are written
+// by different goroutines
+type fakeRedisCacheConn struct {
+	conn net.Conn
+	m    sync.Mutex
+}
+
+func (c *fakeRedisCacheConn) write(v interface{}) {
+	c.m.Lock()
+	c.conn.Write([]byte(fakeRESP(v)))
+	c.m.Unlock()
+}
+
+// fakeRedisCache serves GET, SET, DEL, PUBLISH and SUBSCRIBE in memory
+type fakeRedisCache struct {
+	listener    net.Listener
+	m           sync.Mutex
+	values      map[string]string
+	subscribers map[string][]*fakeRedisCacheConn
+	conns       []*fakeRedisCacheConn
+}
+
+func newFakeRedisCache(t *testing.T) *fakeRedisCache {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	s := &fakeRedisCache{listener: l, values: map[string]string{}, subscribers: map[string][]*fakeRedisCacheConn{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			c := &fakeRedisCacheConn{conn: conn}
+			s.m.Lock()
+			s.conns = append(s.conns, c)
+			s.m.Unlock()
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		s.m.Lock()
+		for _, c := range s.conns {
+			c.conn.Close()
+		}
+		s.m.Unlock()
+	})
+	return s
+}
+
+func (s *fakeRedisCache) serve(c *fakeRedisCacheConn) {
+	defer c.conn.Close()
+	r := bufio.NewReader(c.conn)
+	subscribed := false
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := []string{}
+		for i := 0; i < n; i++ {
+			header, err := r.ReadString('\n')
+			if nil != err {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); nil != err {
+				return
+			}
+			args = append(args, string(buf[:size]))
+		}
+		s.m.Lock()
+		var reply interface{}
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			if subscribed {
+				reply = []interface{}{"pong", ""}
+			} else {
+				reply = "PONG"
+			}
+		case "GET":
+			if value, ok := s.values[args[1]]; ok {
+				reply = value
+			}
+		case "SET":
+			s.values[args[1]] = args[2]
+			reply = true
+		case "DEL":
+			_, ok := s.values[args[1]]
+			delete(s.values, args[1])
+			reply = int64(0)
+			if ok {
+				reply = int64(1)
+			}
+		case "SUBSCRIBE":
+			subscribed = true
+			for i, channel := range args[1:] {
+				s.subscribers[channel] = append(s.subscribers[channel], c)
+				if i < len(args)-2 {
+					c.write([]interface{}{"subscribe", channel, int64(i + 1)})
+				} else {
+					reply = []interface{}{"subscribe", channel, int64(i + 1)}
+				}
+			}
+		case "PUBLISH":
+			subscribers := s.subscribers[args[1]]
+			for _, sub := range subscribers {
+				sub.write([]interface{}{"message", args[1], args[2]})
+			}
+			reply = int64(len(subscribers))
+		default:
+			reply = true
+		}
+		s.m.Unlock()
+		c.write(reply)
+	}
+}
+
+func (s *fakeRedisCache) set(key string, value string) {
+	s.m.Lock()
+	s.values[key] = value
+	s.m.Unlock()
+}
+
+func (s *fakeRedisCache) subscriptions(channel string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return len(s.subscribers[channel])
+}
+
+func newFakeTieredCache(t *testing.T, s *fakeRedisCache, name string, maxEntries int) *caching.TieredCache {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	session, err := redisadapter.NewRedisSession(name, cachingenv.CacheConnectorConfig{Host: "127.0.0.1", Port: addr.Port})
+	testingutil.AssertNil(t, err, name+" session")
+	c := caching.NewTieredCache(session, cachingenv.LocalCacheConfig{MaxEntries: maxEntries})
+	t.Cleanup(func() {
+		c.Close()
+		session.Client.Close()
+	})
+	return c
+}
+
+func assertTieredCacheValue(t *testing.T, c *caching.TieredCache, key string, expected string, name string) {
+	value, err := c.Get(key)
+	testingutil.AssertNil(t, err, name+" error")
+	testingutil.AssertEquals(t, expected, string(value), name)
+}
+
+func TestTieredCacheInvalidation(t *testing.T) {
+	s := newFakeRedisCache(t)
+	a := newFakeTieredCache(t, s, "tiered-a", 10)
+	b := newFakeTieredCache(t, s, "tiered-b", 10)
+	waitFor(t, 5*time.Second, func() bool { return s.subscriptions(caching.DefaultInvalidationChannel) == 2 }, "subscriptions")
+
+	s.set("config", "v1")
+	assertTieredCacheValue(t, a, "config", "v1", "writer get from redis")
+	assertTieredCacheValue(t, b, "config", "v1", "reader get from redis")
+
+	// the local copies are served without reading redis
+	s.set("config", "changed behind the cache")
+	assertTieredCacheValue(t, a, "config", "v1", "local copy of writer")
+	assertTieredCacheValue(t, b, "config", "v1", "local copy of reader")
+
+	testingutil.AssertTrue(t, a.Set("config", []byte("v2"), time.Minute), "set v2")
+	assertTieredCacheValue(t, a, "config", "v2", "writer sees its own write")
+	waitFor(t, 5*time.Second, func() bool {
+		value, _ := b.Get("config")
+		return "v2" == string(value)
+	}, "reader invalidated by set")
+
+	s.set("config", "v3")
+	b.Invalidate("config")
+	assertTieredCacheValue(t, b, "config", "v3", "invalidated locally")
+	waitFor(t, 5*time.Second, func() bool {
+		value, _ := a.Get("config")
+		return "v3" == string(value)
+	}, "writer invalidated by reader")
+
+	testingutil.AssertTrue(t, a.Delete("config"), "delete")
+	waitFor(t, 5*time.Second, func() bool {
+		_, err := b.Get("config")
+		return nil != err
+	}, "reader invalidated by delete")
+}
+
+func TestTieredCacheEviction(t *testing.T) {
+	s := newFakeRedisCache(t)
+	c := newFakeTieredCache(t, s, "tiered-lru", 2)
+	for _, key := range []string{"a", "b", "c"} {
+		testingutil.AssertTrue(t, c.Set(key, []byte(key+"1"), time.Minute), "set "+key)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		s.set(key, key+"2")
+	}
+	// the least recently used entry is evicted when the local cache is full
+	assertTieredCacheValue(t, c, "a", "a2", "evicted entry read from redis")
+	assertTieredCacheValue(t, c, "c", "c1", "recent entry kept locally")
+	assertTieredCacheValue(t, c, "b", "b2", "entry evicted by reloading")
+}
@@ -0,0 +1,117 @@
+package unittests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/queues"
+	"github.com/libpub/golib/testingutil"
+)
+
+type testComparisonMeta struct {
+	Zone string
+	Tags []string
+}
+
+type testComparisonUser struct {
+	Name   string
+	Age    int
+	Score  float64
+	Level  uint8
+	VIP    bool
+	Status string
+	Meta   *testComparisonMeta
+}
+
+// testComparisonGetter element provides the field values by getter
+type testComparisonGetter struct {
+	values map[string]interface{}
+}
+
+func (g *testComparisonGetter) GetFieldValue(field string) (interface{}, bool) {
+	v, ok := g.values[field]
+	return v, ok
+}
+
+func TestComparisonGroups(t *testing.T) {
+	user := &testComparisonUser{Name: "alice", Age: 20, Score: 88.5, Level: 3, Status: "active", Meta: &testComparisonMeta{Zone: "cn-east", Tags: []string{"vip", "beta"}}}
+
+	cmp := definations.NewComparisonObject().
+		And(definations.CompareEquals, "Status", "active").
+		AndGroup(definations.NewComparisonObject().Or(definations.CompareGreaterEquals, "Age", 18).Or(definations.CompareEquals, "VIP", true))
+	testingutil.AssertTrue(t, cmp.Evaluate(user), "and with or group")
+	testingutil.AssertFalse(t, definations.Not(cmp).Evaluate(user), "not group")
+	testingutil.AssertFalse(t, definations.NewComparisonObject().And(definations.CompareEquals, "Status", "disabled").OrGroup(cmp).Evaluate(user), "ands all matched before ors")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().Or(definations.CompareEquals, "Status", "disabled").OrGroup(cmp).Evaluate(user), "or group")
+
+	// numbers of different kinds, dotted paths, regex and includes
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareGreaterThan, "Score", 88).Evaluate(user), "float field with int value")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareBetween, "Level", []int{1, 5}).Evaluate(user), "uint field between")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareEquals, "Meta.Zone", "cn-east").Evaluate(user), "dotted path")
+	testingutil.AssertFalse(t, definations.NewComparisonObject().And(definations.CompareEquals, "Meta.Missing", "x").Evaluate(user), "missing field")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareRegex, "Name", "^al").Evaluate(user), "regex")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareIncludes, "Meta.Tags", "vip").Evaluate(user), "slice includes")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareIncludes, "Name", "lic").Evaluate(user), "string includes")
+	testingutil.AssertNotNil(t, definations.NewComparisonObject().And(definations.CompareRegex, "Name", "(").Validate(), "invalid regex")
+
+	// map and getter elements
+	record := map[string]interface{}{"name": "bob", "age": 17, "labels": map[string]string{"team": "infra"}}
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareLessThan, "age", 18).And(definations.CompareEquals, "labels.team", "infra").Evaluate(record), "map element")
+	getter := &testComparisonGetter{values: map[string]interface{}{"name": "carol", "age": int64(30)}}
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareInArray, "age", []int{30, 40}).Evaluate(getter), "getter element")
+	testingutil.AssertFalse(t, definations.NewComparisonObject().And(definations.CompareEquals, "missing", "x").Evaluate(getter), "getter missing field")
+	testingutil.AssertFalse(t, definations.Not(definations.NewComparisonObject().And(definations.CompareEquals, "Name", "x")).Evaluate(1), "invalid element")
+
+	// nil values and numbers against string fields keep the legacy results
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareNotEquals, "Name", nil).Evaluate(user), "not equals nil")
+	testingutil.AssertTrue(t, definations.NewComparisonObject().And(definations.CompareNotInArray, "Name", nil).Evaluate(user), "not in nil")
+	testingutil.AssertFalse(t, definations.NewComparisonObject().And(definations.CompareEquals, "Name", nil).Evaluate(user), "equals nil")
+	testingutil.AssertFalse(t, definations.NewComparisonObject().And(definations.CompareNotEquals, "Status", 200).Evaluate(user), "string field with number value")
+}
+
+func TestParseComparison(t *testing.T) {
+	users := []*testComparisonUser{
+		{Name: "alice", Age: 20, Score: 88.5, Status: "active", Meta: &testComparisonMeta{Zone: "cn-east", Tags: []string{"vip"}}},
+		{Name: "bob", Age: 16, Score: 60, Status: "pending", VIP: true, Meta: &testComparisonMeta{Zone: "cn-west"}},
+		{Name: "test-carol", Age: 30, Score: 70, Status: "active"},
+		{Name: "dave", Age: 45, Score: 95, Status: "disabled"},
+	}
+	cases := map[string][]string{
+		"Status in ('active', 'pending') and (Age >= 18 or VIP = true) and not Name ~ '^test'": {"alice", "bob"},
+		"Age between 18 and 40":                              {"alice", "test-carol"},
+		"Age not between 18 and 40 && Score > 90.5":          {"dave"},
+		"Status != \"active\" || Meta.Tags contains 'vip'":   {"alice", "bob", "dave"},
+		"!(Status = 'active') and Status not in ['pending']": {"dave"},
+		"Meta.Zone matches 'east$' or Name contains 'car'":   {"alice", "test-carol"},
+		"Score <= 70 AND Score >= 60.0":                      {"bob", "test-carol"},
+		"Meta.Zone = 'cn-west' or Status != 200":             {"alice", "bob", "test-carol", "dave"},
+	}
+	for expression, expected := range cases {
+		cmp, err := definations.ParseComparison(expression)
+		testingutil.AssertNil(t, err, "ParseComparison "+expression)
+		names := []string{}
+		for _, user := range users {
+			if cmp.Evaluate(user) {
+				names = append(names, user.Name)
+			}
+		}
+		testingutil.AssertEquals(t, strings.Join(expected, ","), strings.Join(names, ","), expression)
+	}
+
+	for _, expression := range []string{"", "Age >", "Age = 1 and", "(Age = 1", "Age in 1", "Name ~ '('", "Age & 1", "Name = 'abc", "Age between 1 or 2"} {
+		cmp, err := definations.ParseComparison(expression)
+		testingutil.AssertNotNil(t, err, "ParseComparison invalid "+expression)
+		testingutil.AssertTrue(t, nil == cmp, "ParseComparison invalid result "+expression)
+	}
+
+	queue := queues.NewFIFOQueue()
+	for _, e := range []*demoElement{{val: "3", ordering: 3}, {val: "5", ordering: 5}, {val: "12", ordering: 12}} {
+		queue.Push(e)
+	}
+	cmp, err := definations.ParseComparison("ordering in (3, 12) and val ~ '^1'")
+	testingutil.AssertNil(t, err, "ParseComparison unexported fields")
+	results := queue.FindElements(cmp)
+	testingutil.AssertEquals(t, 1, len(results), "FindElements by expression")
+	testingutil.AssertEquals(t, "12", results[0].GetID(), "FindElements by expression result")
+}
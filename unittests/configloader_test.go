@@ -0,0 +1,105 @@
+package unittests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/config"
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/netutils/dboptions"
+	"github.com/libpub/golib/testingutil"
+)
+
+type testAppConfig struct {
+	Name    string                                        `yaml:"name" json:"name" validate:"required"`
+	Port    int                                           `yaml:"port" json:"port" default:"8080"`
+	Timeout time.Duration                                 `yaml:"timeout" json:"timeout"`
+	Hosts   []string                                      `yaml:"hosts" json:"hosts"`
+	TLS     definations.TLSOptions                        `yaml:"tls" json:"tls"`
+	Proxies *definations.Proxies                          `yaml:"proxies" json:"proxies"`
+	Kafka   kafka.Config                                  `yaml:"kafka" json:"kafka"`
+	DBs     map[string]*dboptions.DBConnectionPoolOptions `yaml:"db" json:"db"`
+	Secret  string                                        `yaml:"secret" env:"-"`
+	Level   string                                        `yaml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+func TestConfigLoader(t *testing.T) {
+	dir := t.TempDir()
+	baseFile := filepath.Join(dir, "app.yaml")
+	os.WriteFile(baseFile, []byte(`
+name: billing
+hosts: [a, b]
+tls:
+  enabled: true
+  certFile: base.crt
+kafka:
+  hosts: kafka1:9092
+  maxInFlight: 4
+db:
+  main:
+    dsn: "postgres://u:p@db1:5432/app"
+    maxtotalconnections: 8
+secret: from-file
+`), 0644)
+	overlayFile := filepath.Join(dir, "local.app.json")
+	os.WriteFile(overlayFile, []byte(`{"tls": {"certFile": "local.crt"}, "kafka": {"GroupID": "g1"}}`), 0644)
+
+	envs := map[string]string{
+		"APP_PORT":                  "9090",
+		"APP_TIMEOUT":               "3s",
+		"APP_HOSTS":                 "x, y,z",
+		"APP_TLS_SKIP_VERIFY":       "true",
+		"APP_PROXIES_HTTP":          "http://proxy:3128",
+		"APP_KAFKA_MAX_IN_FLIGHT":   "16",
+		"APP_KAFKA_HOSTS":           "kafka2:9092",
+		"APP_DB_MAIN_MAX_WAIT_TIME": "300",
+		"APP_SECRET":                "from-env",
+		"APP_LOG_LEVEL":             "debug",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := envs[name]
+		return v, ok
+	}
+	cfg := testAppConfig{}
+	err := config.Load(&cfg, config.WithFiles(baseFile), config.WithOptionalFiles(overlayFile, filepath.Join(dir, "missing.yaml")),
+		config.WithEnvPrefix("app"), config.WithEnvLookup(lookup))
+	testingutil.AssertNil(t, err, "config.Load error")
+	testingutil.AssertEquals(t, "billing", cfg.Name, "name from yaml")
+	testingutil.AssertEquals(t, 9090, cfg.Port, "port from env")
+	testingutil.AssertEquals(t, 3*time.Second, cfg.Timeout, "duration from env")
+	testingutil.AssertEquals(t, 3, len(cfg.Hosts), "string slice from env")
+	testingutil.AssertEquals(t, "y", cfg.Hosts[1], "string slice item from env")
+	testingutil.AssertTrue(t, cfg.TLS.Enabled, "tls enabled from yaml")
+	testingutil.AssertEquals(t, "local.crt", cfg.TLS.CertFile, "tls cert file overlaid by json")
+	testingutil.AssertTrue(t, cfg.TLS.SkipVerify, "tls skip verify from env")
+	testingutil.AssertTrue(t, nil != cfg.Proxies, "nil pointer set by env")
+	testingutil.AssertEquals(t, "http://proxy:3128", cfg.Proxies.HTTP, "proxies from env")
+	testingutil.AssertEquals(t, "kafka2:9092", cfg.Kafka.Hosts, "kafka hosts from env")
+	testingutil.AssertEquals(t, 16, cfg.Kafka.MaxInFlight, "kafka max in flight from env")
+	testingutil.AssertEquals(t, "g1", cfg.Kafka.GroupID, "kafka group from json")
+	testingutil.AssertEquals(t, 8, cfg.DBs["main"].MaxTotalConnections, "db option from yaml")
+	testingutil.AssertEquals(t, 300, cfg.DBs["main"].MaxWaitTime, "db option of map from env")
+	testingutil.AssertEquals(t, "from-file", cfg.Secret, "env skipped field")
+	testingutil.AssertEquals(t, "debug", cfg.Level, "env tag field")
+
+	// defaults and validation
+	cfg = testAppConfig{}
+	emptyFile := filepath.Join(dir, "empty.yaml")
+	os.WriteFile(emptyFile, []byte("tls: {}\n"), 0644)
+	err = config.Load(&cfg, config.WithFiles(emptyFile))
+	testingutil.AssertNotNil(t, err, "validate required name")
+	testingutil.AssertEquals(t, 8080, cfg.Port, "default port")
+	testingutil.AssertEquals(t, "info", cfg.Level, "default level")
+	testingutil.AssertTrue(t, nil == cfg.Proxies, "nil pointer kept without env")
+
+	err = config.Load(&cfg, config.WithFiles(filepath.Join(dir, "missing.yaml")))
+	testingutil.AssertNotNil(t, err, "required file missing")
+	err = config.Load(&cfg, config.WithEnvPrefix("APP"), config.WithEnvLookup(func(name string) (string, bool) {
+		return "x", "APP_PORT" == name
+	}))
+	testingutil.AssertNotNil(t, err, "invalid env value")
+	testingutil.AssertNotNil(t, config.Load(cfg), "load into non pointer")
+}
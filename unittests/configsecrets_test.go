@@ -0,0 +1,189 @@
+package unittests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/config"
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/testingutil"
+)
+
+type testSecretConfig struct {
+	URL       string                 `yaml:"url"`
+	Password  string                 `yaml:"password"`
+	TLS       definations.TLSOptions `yaml:"tls"`
+	SASL      map[string]string      `yaml:"sasl"`
+	APIKeys   []string               `yaml:"apiKeys"`
+	VaultKV1  string                 `yaml:"vaultKv1"`
+	AWSSecret string                 `yaml:"awsSecret"`
+}
+
+func TestConfigSecrets(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "tls.key")
+	os.WriteFile(keyFile, []byte("/etc/tls/real.key\n"), 0600)
+	os.Setenv("TEST_CONFIG_DB_PASSWORD", "db-secret")
+	defer os.Unsetenv("TEST_CONFIG_DB_PASSWORD")
+
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "vault-token" != r.Header.Get("X-Vault-Token") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/kafka":
+			w.Write([]byte(`{"data":{"data":{"username":"kafka-user","password":"kafka-secret"},"metadata":{"version":1}}}`))
+		case "/v1/kv/legacy":
+			w.Write([]byte(`{"data":{"token":"legacy-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if "secretsmanager.GetSecretValue" != r.Header.Get("X-Amz-Target") || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || "prod/api" != req["SecretId"] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"Name":"prod/api","SecretString":"{\"key\":\"aws-api-key\"}"}`))
+	}))
+	defer aws.Close()
+
+	file := filepath.Join(dir, "app.yaml")
+	os.WriteFile(file, []byte(`
+url: http://example.com
+password: env:TEST_CONFIG_DB_PASSWORD
+tls:
+  keyFile: file:`+keyFile+`
+sasl:
+  username: vault:secret/data/kafka#username
+  password: vault:secret/data/kafka#password
+apiKeys: [plain, "aws-sm:prod/api#key"]
+vaultKv1: vault:kv/legacy#token
+awsSecret: aws-sm:prod/api
+`), 0644)
+
+	awsResolver := &config.AWSSecretsManagerResolver{Region: "us-east-1", AccessKeyID: "AKID", SecretAccessKey: "secret", Endpoint: aws.URL}
+	cfg := testSecretConfig{}
+	err := config.Load(&cfg, config.WithFiles(file),
+		config.WithSecretResolver(config.SecretSchemeVault, config.NewVaultSecretResolver(vault.URL, "vault-token")),
+		config.WithSecretResolver(config.SecretSchemeAWSSM, awsResolver))
+	testingutil.AssertNil(t, err, "Load with secrets error")
+	testingutil.AssertEquals(t, "http://example.com", cfg.URL, "value without registered scheme")
+	testingutil.AssertEquals(t, "db-secret", cfg.Password, "env secret")
+	testingutil.AssertEquals(t, "/etc/tls/real.key", cfg.TLS.KeyFile, "file secret")
+	testingutil.AssertEquals(t, "kafka-user", cfg.SASL["username"], "vault kv2 secret username")
+	testingutil.AssertEquals(t, "kafka-secret", cfg.SASL["password"], "vault kv2 secret password")
+	testingutil.AssertEquals(t, "legacy-token", cfg.VaultKV1, "vault kv1 secret")
+	testingutil.AssertEquals(t, "plain", cfg.APIKeys[0], "plain slice item")
+	testingutil.AssertEquals(t, "aws-api-key", cfg.APIKeys[1], "aws secret json key")
+	testingutil.AssertEquals(t, `{"key":"aws-api-key"}`, cfg.AWSSecret, "aws whole secret")
+
+	cfg = testSecretConfig{}
+	err = config.Load(&cfg, config.WithFiles(file), config.WithoutSecrets())
+	testingutil.AssertNil(t, err, "Load without secrets error")
+	testingutil.AssertEquals(t, "env:TEST_CONFIG_DB_PASSWORD", cfg.Password, "secret reference kept without secrets")
+
+	// the missing environment variable fails loading without exposing secrets
+	os.Unsetenv("TEST_CONFIG_DB_PASSWORD")
+	err = config.Load(&testSecretConfig{}, config.WithFiles(file))
+	testingutil.AssertNotNil(t, err, "Load with missing env secret")
+	testingutil.AssertFalse(t, strings.Contains(err.Error(), "db-secret"), "error without secret")
+
+	config.RegisterSecretResolver("test", config.SecretResolverFunc(func(ref string) (string, error) {
+		return strings.ToUpper(ref), nil
+	}))
+	defer config.RegisterSecretResolver("test", nil)
+	value, err := config.ResolveSecret("test:abc")
+	testingutil.AssertNil(t, err, "ResolveSecret error")
+	testingutil.AssertEquals(t, "ABC", value, "ResolveSecret by registered resolver")
+}
+
+// verifyAWSSignatureV4 verifies the signature of request as the aws api does, returns the credential scope if valid
+func verifyAWSSignatureV4(r *http.Request, body []byte, secretKey string) (string, bool) {
+	var credential, signedHeaders, signature string
+	for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ", ") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return "", false
+		}
+		switch kv[0] {
+		case "Credential":
+			credential = kv[1]
+		case "SignedHeaders":
+			signedHeaders = kv[1]
+		case "Signature":
+			signature = kv[1]
+		}
+	}
+	scope := strings.SplitN(credential, "/", 2)
+	if len(scope) != 2 {
+		return "", false
+	}
+	canonicalHeaders := ""
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if "host" == name {
+			value = r.Host
+		}
+		canonicalHeaders += name + ":" + strings.TrimSpace(value) + "\n"
+	}
+	bodyHash := sha256.Sum256(body)
+	requestHash := sha256.Sum256([]byte(r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" + canonicalHeaders + "\n" + signedHeaders + "\n" + hex.EncodeToString(bodyHash[:])))
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope[1] + "\n" + hex.EncodeToString(requestHash[:])
+	key := []byte("AWS4" + secretKey)
+	for _, item := range strings.Split(scope[1], "/") {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(item))
+		key = mac.Sum(nil)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	return scope[1], hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+func TestAWSSecretsManagerSignature(t *testing.T) {
+	scopes := make(chan string, 1)
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		scope, ok := verifyAWSSignatureV4(r, body, "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+		if !ok || "session-token" != r.Header.Get("X-Amz-Security-Token") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		scopes <- scope + " " + r.Header.Get("X-Amz-Date")
+		w.Write([]byte(`{"Name":"prod/api","SecretString":"{\"key\":\"aws-api-key\"}"}`))
+	}))
+	defer aws.Close()
+
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	resolver := &config.AWSSecretsManagerResolver{
+		Region:          "us-east-1",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "session-token",
+		Endpoint:        aws.URL + "/",
+		Now:             func() time.Time { return now },
+	}
+	value, err := resolver.ResolveSecret("prod/api#key")
+	testingutil.AssertNil(t, err, "ResolveSecret error")
+	testingutil.AssertEquals(t, "aws-api-key", value, "aws secret json key")
+	testingutil.AssertEquals(t, "20150830/us-east-1/secretsmanager/aws4_request 20150830T123600Z", <-scopes, "credential scope and date")
+
+	resolver.SecretAccessKey = "wrong"
+	_, err = resolver.ResolveSecret("prod/api#key")
+	testingutil.AssertNotNil(t, err, "ResolveSecret with wrong secret key")
+}
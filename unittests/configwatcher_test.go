@@ -0,0 +1,67 @@
+package unittests
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/config"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+type testReloadConfig struct {
+	Name   string            `yaml:"name" validate:"required"`
+	Kafka  kafka.Config      `yaml:"kafka"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+func TestConfigWatcher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	os.WriteFile(file, []byte("name: app\nkafka:\n  hosts: k1:9092\nlabels:\n  zone: a\n"), 0644)
+	cfg := &testReloadConfig{}
+	watcher, err := config.NewWatcher(cfg, config.WithFiles(file))
+	testingutil.AssertNil(t, err, "NewWatcher error")
+	testingutil.AssertEquals(t, "k1:9092", watcher.Current().(*testReloadConfig).Kafka.Hosts, "initial kafka hosts")
+
+	changes := map[string][]interface{}{}
+	m := sync.Mutex{}
+	record := func(section string, oldValue, newValue interface{}) {
+		m.Lock()
+		changes[section] = []interface{}{oldValue, newValue}
+		m.Unlock()
+	}
+	watcher.OnChange("kafka", record)
+	watcher.OnChange("labels.zone", record)
+	watcher.OnChange("name", record)
+	watcher.Start(10 * time.Millisecond)
+	defer watcher.Stop()
+
+	// the modification time may not change within the same second on some file systems, the size changes
+	os.WriteFile(file, []byte("name: app\nkafka:\n  hosts: k2:9092,k3:9092\nlabels:\n  zone: b\n"), 0644)
+	time.Sleep(100 * time.Millisecond)
+	m.Lock()
+	testingutil.AssertEquals(t, 2, len(changes), "changed sections")
+	kafkaChange := changes["kafka"]
+	zoneChange := changes["labels.zone"]
+	m.Unlock()
+	testingutil.AssertEquals(t, "k1:9092", kafkaChange[0].(kafka.Config).Hosts, "old kafka section")
+	testingutil.AssertEquals(t, "k2:9092,k3:9092", kafkaChange[1].(kafka.Config).Hosts, "new kafka section")
+	testingutil.AssertEquals(t, "b", zoneChange[1], "new map section value")
+	testingutil.AssertEquals(t, "k1:9092", cfg.Kafka.Hosts, "loaded configuration not modified")
+	testingutil.AssertEquals(t, "k2:9092,k3:9092", watcher.Current().(*testReloadConfig).Kafka.Hosts, "current kafka hosts")
+
+	// invalid configuration is not applied
+	os.WriteFile(file, []byte("kafka:\n  hosts: k4:9092\n"), 0644)
+	time.Sleep(100 * time.Millisecond)
+	testingutil.AssertEquals(t, "k2:9092,k3:9092", watcher.Current().(*testReloadConfig).Kafka.Hosts, "invalid configuration kept current")
+	testingutil.AssertNotNil(t, watcher.Reload(), "Reload invalid configuration")
+
+	v, ok := config.SectionValue(watcher.Current(), "Kafka.Hosts")
+	testingutil.AssertTrue(t, ok, "SectionValue by field name")
+	testingutil.AssertEquals(t, "k2:9092,k3:9092", v, "SectionValue")
+	_, ok = config.SectionValue(watcher.Current(), "missing")
+	testingutil.AssertFalse(t, ok, "SectionValue missing")
+}
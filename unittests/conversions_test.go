@@ -0,0 +1,127 @@
+package unittests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+)
+
+type testConversionLevel uint8
+
+func TestConvertNumbers(t *testing.T) {
+	level := testConversionLevel(3)
+	n := 42
+	for _, c := range []struct {
+		val      interface{}
+		expected int64
+	}{
+		{nil, 0}, {"", 0}, {"1,234", 1234}, {" -1,234,567 ", -1234567}, {"3.7", 3}, {"0x1f", 31}, {"010", 10},
+		{"1_000", 1000}, {true, 1}, {uint64(7), 7}, {float32(2.5), 2}, {level, 3}, {&n, 42},
+		{json.Number("12"), 12}, {time.Second, int64(time.Second)},
+	} {
+		v, err := utils.ToInt64E(c.val)
+		testingutil.AssertNil(t, err, fmt.Sprintf("ToInt64E(%#v) error", c.val))
+		testingutil.AssertEquals(t, c.expected, v, fmt.Sprintf("ToInt64E(%#v)", c.val))
+	}
+	for _, val := range []interface{}{"abc", "1,23", uint64(1 << 63), []int{1}, struct{}{}} {
+		_, err := utils.ToInt64E(val)
+		testingutil.AssertTrue(t, errors.Is(err, utils.ErrInvalidConversion), fmt.Sprintf("ToInt64E(%#v) invalid", val))
+	}
+	testingutil.AssertEquals(t, int64(0), utils.AsInt64([]int{1}), "AsInt64 invalid without panic")
+	testingutil.AssertEquals(t, 1234, utils.AsInt("1,234"), "AsInt")
+	testingutil.AssertEquals(t, 0, utils.ToInt("1,234"), "ToInt legacy")
+
+	u, err := utils.ToUint64E("18446744073709551615")
+	testingutil.AssertNil(t, err, "ToUint64E max error")
+	testingutil.AssertEquals(t, uint64(18446744073709551615), u, "ToUint64E max")
+	_, err = utils.ToUint64E(-1)
+	testingutil.AssertNotNil(t, err, "ToUint64E negative")
+
+	f, err := utils.ToFloat64E("1,234.5")
+	testingutil.AssertNil(t, err, "ToFloat64E error")
+	testingutil.AssertEquals(t, 1234.5, f, "ToFloat64E grouped")
+	testingutil.AssertEquals(t, 2.5, utils.AsFloat64(float32(2.5)), "AsFloat64 float32")
+	testingutil.AssertEquals(t, 1234.5, utils.AsFloat64("1,234.5"), "AsFloat64 grouped")
+	testingutil.AssertEquals(t, 0.0, utils.ToDouble("1,234.5"), "ToDouble legacy")
+	testingutil.AssertEquals(t, 31, utils.MustInt("0x1f"), "MustInt")
+	defer func() {
+		testingutil.AssertNotNil(t, recover(), "MustInt64 panics")
+	}()
+	utils.MustInt64("not a number")
+}
+
+func TestConvertBoolTimeDuration(t *testing.T) {
+	for text, expected := range map[string]bool{"yes": true, "ON": true, "enabled": true, "1": true, "2.5": true, "no": false, "Off": false, "": false, "0": false, "f": false} {
+		b, err := utils.ToBoolE(text)
+		testingutil.AssertNil(t, err, "ToBoolE error of "+text)
+		testingutil.AssertEquals(t, expected, b, "ToBoolE "+text)
+	}
+	_, err := utils.ToBoolE("maybe")
+	testingutil.AssertNotNil(t, err, "ToBoolE invalid")
+	testingutil.AssertTrue(t, utils.AsBool(1), "AsBool int")
+	testingutil.AssertFalse(t, utils.AsBool("maybe"), "AsBool invalid")
+	testingutil.AssertFalse(t, utils.ToBoolean("yes"), "ToBoolean legacy")
+
+	expected := time.Date(2024, 3, 5, 10, 20, 30, 0, time.Local)
+	for _, val := range []interface{}{
+		expected, &expected, expected.Unix(), expected.UnixMilli(), fmt.Sprint(expected.Unix()), float64(expected.Unix()),
+		"2024-03-05 10:20:30", "2024-03-05T10:20:30", "2024/03/05 10:20:30", "20240305102030", expected.Format(time.RFC3339),
+	} {
+		v, err := utils.ToTimeE(val)
+		testingutil.AssertNil(t, err, fmt.Sprintf("ToTimeE(%#v) error", val))
+		testingutil.AssertTrue(t, expected.Equal(v), fmt.Sprintf("ToTimeE(%#v) got %v", val, v))
+	}
+	day, err := utils.ToTimeE("20240305")
+	testingutil.AssertNil(t, err, "ToTimeE yyyyMMdd error")
+	testingutil.AssertEquals(t, 5, day.Day(), "ToTimeE yyyyMMdd")
+	_, err = utils.ToTimeE("yesterday")
+	testingutil.AssertNotNil(t, err, "ToTimeE invalid")
+	testingutil.AssertTrue(t, utils.ToTime("invalid").IsZero(), "ToTime invalid")
+
+	for val, expected := range map[interface{}]time.Duration{
+		"1m30s": 90 * time.Second, "2d12h": 60 * time.Hour, "1d": 24 * time.Hour, "1500": 1500 * time.Millisecond,
+		1500: 1500 * time.Millisecond, 2.5: 2500 * time.Microsecond, time.Minute: time.Minute, "": 0,
+	} {
+		d, err := utils.ToDurationE(val)
+		testingutil.AssertNil(t, err, fmt.Sprintf("ToDurationE(%#v) error", val))
+		testingutil.AssertEquals(t, expected, d, fmt.Sprintf("ToDurationE(%#v)", val))
+	}
+	_, err = utils.ToDurationE("1x")
+	testingutil.AssertTrue(t, errors.Is(err, utils.ErrInvalidConversion), "ToDurationE invalid")
+	testingutil.AssertEquals(t, 3*time.Second, utils.MustDuration("3s"), "MustDuration")
+}
+
+func TestConvertSlicesMaps(t *testing.T) {
+	strs, err := utils.ToStringSliceE("a, b,c")
+	testingutil.AssertNil(t, err, "ToStringSliceE error")
+	testingutil.AssertEquals(t, "[a b c]", fmt.Sprint(strs), "ToStringSliceE comma separated")
+	testingutil.AssertEquals(t, "[1 x true]", fmt.Sprint(utils.ToStringSlice([]interface{}{1, "x", true})), "ToStringSlice items")
+	ints, err := utils.ToInt64SliceE(`[1, "2", 3.0]`)
+	testingutil.AssertNil(t, err, "ToInt64SliceE json error")
+	testingutil.AssertEquals(t, "[1 2 3]", fmt.Sprint(ints), "ToInt64SliceE json")
+	testingutil.AssertEquals(t, "[7]", fmt.Sprint(utils.ToInt64Slice(7)), "ToInt64Slice single")
+	_, err = utils.ToInt64SliceE([]string{"1", "x"})
+	testingutil.AssertNotNil(t, err, "ToInt64SliceE invalid item")
+	floats, err := utils.ToFloat64SliceE([]int{1, 2})
+	testingutil.AssertNil(t, err, "ToFloat64SliceE error")
+	testingutil.AssertEquals(t, 2.0, floats[1], "ToFloat64SliceE")
+
+	m, err := utils.ToStringMapE(`{"name":"a","count":2}`)
+	testingutil.AssertNil(t, err, "ToStringMapE json error")
+	testingutil.AssertEquals(t, int64(2), utils.AsInt64(m["count"]), "ToStringMapE json number")
+	ms, err := utils.ToStringMapStringE(map[interface{}]interface{}{"a": 1, 2: true})
+	testingutil.AssertNil(t, err, "ToStringMapStringE error")
+	testingutil.AssertEquals(t, "1", ms["a"], "ToStringMapStringE value")
+	testingutil.AssertEquals(t, "true", ms["2"], "ToStringMapStringE key")
+	sm := utils.ToStringMap(struct {
+		Name string `json:"name"`
+	}{Name: "n"})
+	testingutil.AssertEquals(t, "n", sm["name"], "ToStringMap struct")
+	_, err = utils.ToStringMapE(1)
+	testingutil.AssertNotNil(t, err, "ToStringMapE invalid")
+}
@@ -0,0 +1,74 @@
+package unittests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/netutils/dboptions"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestDBWatchdog(t *testing.T) {
+	opts := dboptions.NewDBConnectionPoolOptionsWithDSN("file://" + filepath.Join(t.TempDir(), "watchdog.db"))
+	pool, err := dboptions.InitDBPool("watchpool", opts)
+	testingutil.AssertNil(t, err, "InitDBPool")
+	defer dboptions.CloseDBPool("watchpool")
+
+	events := []dboptions.DBStateEvent{}
+	eventsMutex := sync.Mutex{}
+	watchdog := dboptions.NewDBWatchdog(20*time.Millisecond, 2)
+	watchdog.OnStateChange(func(event dboptions.DBStateEvent) {
+		eventsMutex.Lock()
+		events = append(events, event)
+		eventsMutex.Unlock()
+	})
+	watchdog.Watch("watchpool", "missingpool")
+	watchdog.Start()
+	defer watchdog.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	state, ok := watchdog.State("watchpool")
+	testingutil.AssertTrue(t, ok, "State of watchpool")
+	testingutil.AssertTrue(t, state.Healthy, "watchpool healthy")
+	state, _ = watchdog.State("missingpool")
+	testingutil.AssertFalse(t, state.Healthy, "missingpool unhealthy")
+	testingutil.AssertFalse(t, watchdog.Ready(), "Ready with missing pool")
+	rec := httptest.NewRecorder()
+	watchdog.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	testingutil.AssertEquals(t, http.StatusServiceUnavailable, rec.Code, "readiness status not ready")
+
+	watchdog.Unwatch("missingpool")
+	testingutil.AssertTrue(t, watchdog.Ready(), "Ready after unwatch missing pool")
+	testingutil.AssertEquals(t, 1, len(watchdog.States()), "States after unwatch")
+	rec = httptest.NewRecorder()
+	watchdog.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	testingutil.AssertEquals(t, http.StatusOK, rec.Code, "readiness status ready")
+
+	// broken pool is reopened after 2 consecutive failures
+	pool.DB.Close()
+	time.Sleep(150 * time.Millisecond)
+	reopened := dboptions.GetDBPool("watchpool")
+	testingutil.AssertTrue(t, nil != reopened && reopened != pool, "pool reopened by watchdog")
+	testingutil.AssertTrue(t, reopened.Healthy(), "reopened pool healthy")
+	state, _ = watchdog.State("watchpool")
+	testingutil.AssertTrue(t, state.Healthy, "watchpool healthy after reconnected")
+
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+	reconnected := false
+	unhealthy := false
+	for _, event := range events {
+		if "watchpool" == event.Name && event.Reconnected {
+			reconnected = true
+		}
+		if "watchpool" == event.Name && !event.Healthy {
+			unhealthy = true
+		}
+	}
+	testingutil.AssertTrue(t, unhealthy, "unhealthy event emitted")
+	testingutil.AssertTrue(t, reconnected, "reconnected event emitted")
+}
@@ -0,0 +1,197 @@
+package unittests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/dedup"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestDeduplicatorWrap(t *testing.T) {
+	mqCategory := "testing-dedup"
+	mq.InitMockMQTopic(mqCategory, "testing.dedup")
+
+	store := dedup.NewMemoryStore()
+	d := dedup.NewDeduplicator(store, dedup.WithKeyPrefix("orders:"))
+	processed := []string{}
+	err := mq.Subscribe(mqCategory, "", d.Wrap(func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		if "panic" == string(msg.Body) {
+			panic("boom")
+		}
+		processed = append(processed, msg.MessageID+":"+string(msg.Body))
+		return nil
+	}))
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	publish := func(messageID string, body string) {
+		testingutil.AssertNil(t, mq.Publish(mqCategory, mqenv.MQPublishMessage{MessageID: messageID, Body: []byte(body)}), "publish "+messageID)
+	}
+	publish("m1", "first")
+	publish("m1", "redelivered")
+	publish("m2", "second")
+	publish("", "without id")
+	publish("", "without id")
+	testingutil.AssertPanics(t, func() { publish("m3", "panic") }, "panic processing")
+	publish("m3", "after panic")
+	testingutil.AssertEquals(t, "m1:first,m2:second,:without id,:without id,m3:after panic", strings.Join(processed, ","), "processed messages")
+	testingutil.AssertEquals(t, 3, store.Len(), "kept keys")
+}
+
+func TestDeduplicatorRouteMiddleware(t *testing.T) {
+	mqCategory := "testing-dedup-route"
+	mq.InitMockMQTopic(mqCategory, "testing.dedup.route")
+
+	d := dedup.NewDeduplicator(dedup.NewMemoryStore(), dedup.WithKeyFunc(func(msg mqenv.MQConsumerMessage) string {
+		return msg.Headers["X-Event-Id"]
+	}))
+	attempts := 0
+	router := mq.NewRouter()
+	router.Use(d.Middleware())
+	_, err := router.HandleFunc(mqCategory, "", func(msg mqenv.MQConsumerMessage) error {
+		attempts++
+		if 1 == attempts {
+			return errors.New("temporary")
+		}
+		return nil
+	}, mq.WithRouteRetry(mq.RouteRetryPolicy{MaxAttempts: 2, Interval: time.Millisecond}))
+	testingutil.AssertNil(t, err, "handle func")
+	testingutil.AssertNil(t, router.Start(), "router start")
+
+	for i := 0; i < 2; i++ {
+		err = mq.Publish(mqCategory, mqenv.MQPublishMessage{Body: []byte("event"), Headers: map[string]string{"X-Event-Id": "e1"}})
+		testingutil.AssertNil(t, err, "publish event")
+	}
+	testingutil.AssertEquals(t, 2, attempts, "retried once and redelivery skipped")
+}
+
+func TestDeduplicatorRedisStore(t *testing.T) {
+	addr := serveFakeRedisKeys(t)
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: 0})
+	defer client.Close()
+	d := dedup.NewDeduplicator(dedup.NewRedisStore(client, ""), dedup.WithProcessingTTL(time.Second))
+	ctx := context.Background()
+	msg := mqenv.MQConsumerMessage{CorrelationID: "c1"}
+
+	runs := 0
+	run := func() error {
+		runs++
+		return nil
+	}
+	testingutil.AssertNil(t, d.Process(ctx, msg, run), "first processing")
+	testingutil.AssertErrorIs(t, d.Process(ctx, msg, run), dedup.ErrDuplicate, "duplicate processing")
+	testingutil.AssertEquals(t, 1, runs, "runs")
+
+	msg.CorrelationID = "c2"
+	failure := errors.New("failed")
+	testingutil.AssertErrorIs(t, d.Process(ctx, msg, func() error { return failure }), failure, "failed processing")
+	testingutil.AssertNil(t, d.Process(ctx, msg, run), "processing after failure")
+	testingutil.AssertEquals(t, 2, runs, "runs after failure")
+
+	client.Close()
+	testingutil.AssertNotNil(t, d.Process(ctx, mqenv.MQConsumerMessage{MessageID: "m1"}, run), "store unavailable")
+}
+
+// serveFakeRedisKeys serves SET with NX and PX options and DEL in memory
+func serveFakeRedisKeys(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	values := map[string]string{}
+	expires := map[string]time.Time{}
+	m := sync.Mutex{}
+	handle := func(args []string) string {
+		m.Lock()
+		defer m.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			key := args[1]
+			if expire, ok := expires[key]; ok && !time.Now().Before(expire) {
+				delete(values, key)
+			}
+			delete(expires, key)
+			nx := false
+			ttl := time.Duration(0)
+			for i := 3; i < len(args); i++ {
+				switch strings.ToUpper(args[i]) {
+				case "NX":
+					nx = true
+				case "PX", "EX":
+					n, _ := strconv.Atoi(args[i+1])
+					ttl = time.Duration(n) * time.Millisecond
+					if "EX" == strings.ToUpper(args[i]) {
+						ttl = time.Duration(n) * time.Second
+					}
+					i++
+				}
+			}
+			if _, ok := values[key]; ok && nx {
+				return "$-1\r\n"
+			}
+			values[key] = args[2]
+			if ttl > 0 {
+				expires[key] = time.Now().Add(ttl)
+			}
+			return "+OK\r\n"
+		case "DEL":
+			n := 0
+			for _, key := range args[1:] {
+				if _, ok := values[key]; ok {
+					n++
+				}
+				delete(values, key)
+				delete(expires, key)
+			}
+			return fmt.Sprintf(":%d\r\n", n)
+		}
+		return "+OK\r\n"
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					args := []string{}
+					for i := 0; i < n; i++ {
+						header, err := r.ReadString('\n')
+						if nil != err {
+							return
+						}
+						size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+						buf := make([]byte, size+2)
+						if _, err := io.ReadFull(r, buf); nil != err {
+							return
+						}
+						args = append(args, string(buf[:size]))
+					}
+					conn.Write([]byte(handle(args)))
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String()
+}
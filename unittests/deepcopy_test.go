@@ -0,0 +1,175 @@
+package unittests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+)
+
+type testCopyNode struct {
+	Name string
+	Next *testCopyNode
+}
+
+// testCopyCounter copies the unexported fields by DeepCopy
+type testCopyCounter struct {
+	count int
+}
+
+func (c *testCopyCounter) DeepCopy() *testCopyCounter {
+	return &testCopyCounter{count: c.count}
+}
+
+type testCopyOptions struct {
+	Name      string
+	Timeout   time.Duration
+	Created   time.Time
+	Headers   map[string]string
+	Tags      []string
+	TLS       *definations.TLSOptions
+	Extra     interface{}
+	Callback  func() string
+	headers   map[string]string
+	Node      *testCopyNode
+	Counter   testCopyCounter
+	m         sync.Mutex
+	Endpoints map[string]*definations.Proxies
+}
+
+func TestDeepCopy(t *testing.T) {
+	node := &testCopyNode{Name: "a"}
+	node.Next = node
+	src := &testCopyOptions{
+		Name:      "src",
+		Timeout:   time.Second,
+		Created:   time.Now(),
+		Headers:   map[string]string{"X-A": "1"},
+		Tags:      []string{"a", "b"},
+		TLS:       &definations.TLSOptions{Enabled: true, CertFile: "a.crt"},
+		Extra:     map[string]interface{}{"list": []int{1, 2}},
+		Callback:  func() string { return "called" },
+		headers:   map[string]string{"private": "1"},
+		Node:      node,
+		Counter:   testCopyCounter{count: 3},
+		Endpoints: map[string]*definations.Proxies{"main": {HTTP: "http://proxy:3128"}},
+	}
+	src.m.Lock()
+	defer src.m.Unlock()
+
+	dst := testCopyOptions{}
+	testingutil.AssertNil(t, utils.DeepCopy(&dst, src), "DeepCopy error")
+	src.Headers["X-A"] = "changed"
+	src.Tags[0] = "changed"
+	src.TLS.CertFile = "changed.crt"
+	src.Extra.(map[string]interface{})["list"].([]int)[0] = 100
+	src.headers["private"] = "changed"
+	src.Node.Name = "changed"
+	src.Endpoints["main"].HTTP = "changed"
+
+	testingutil.AssertEquals(t, "src", dst.Name, "DeepCopy name")
+	testingutil.AssertEquals(t, time.Second, dst.Timeout, "DeepCopy duration")
+	testingutil.AssertTrue(t, dst.Created == src.Created, "DeepCopy time kept as it is")
+	testingutil.AssertEquals(t, "1", dst.Headers["X-A"], "DeepCopy map")
+	testingutil.AssertEquals(t, "a", dst.Tags[0], "DeepCopy slice")
+	testingutil.AssertEquals(t, "a.crt", dst.TLS.CertFile, "DeepCopy pointer")
+	testingutil.AssertEquals(t, 1, dst.Extra.(map[string]interface{})["list"].([]int)[0], "DeepCopy interface")
+	testingutil.AssertEquals(t, "called", dst.Callback(), "DeepCopy func shared")
+	testingutil.AssertTrue(t, nil == dst.headers, "DeepCopy unexported fields skipped")
+	testingutil.AssertEquals(t, "a", dst.Node.Name, "DeepCopy pointer of pointer")
+	testingutil.AssertTrue(t, dst.Node == dst.Node.Next, "DeepCopy cycle")
+	testingutil.AssertEquals(t, 3, dst.Counter.count, "DeepCopy by DeepCopy method")
+	testingutil.AssertEquals(t, "http://proxy:3128", dst.Endpoints["main"].HTTP, "DeepCopy map of pointers")
+	// the unexported mutex is not copied in locked state
+	testingutil.AssertTrue(t, dst.m.TryLock(), "DeepCopy mutex reset")
+
+	var headers map[string]string
+	testingutil.AssertNil(t, utils.DeepCopy(&headers, map[string]string{"a": "b"}), "DeepCopy map value error")
+	testingutil.AssertEquals(t, "b", headers["a"], "DeepCopy map value")
+	var tlsOptions *definations.TLSOptions
+	testingutil.AssertNil(t, utils.DeepCopy(&tlsOptions, tlsOptions), "DeepCopy nil pointer error")
+	testingutil.AssertTrue(t, nil == tlsOptions, "DeepCopy nil pointer")
+	testingutil.AssertNotNil(t, utils.DeepCopy(&dst, "text"), "DeepCopy mismatched types")
+	testingutil.AssertNotNil(t, utils.DeepCopy(dst.Name, src), "DeepCopy into non pointer")
+}
+
+type testMergeServer struct {
+	Host string
+	Port int
+	TLS  *definations.TLSOptions
+}
+
+type testMergeConfig struct {
+	Name     string
+	Debug    bool
+	Server   testMergeServer
+	Hosts    []string
+	Labels   map[string]string
+	Servers  map[string]testMergeServer
+	Extends  map[string]interface{}
+	Timeout  time.Duration
+	internal string
+}
+
+func TestMergeStructs(t *testing.T) {
+	defaults := testMergeConfig{
+		Name:    "default",
+		Debug:   true,
+		Server:  testMergeServer{Host: "0.0.0.0", Port: 8080, TLS: &definations.TLSOptions{CaFile: "ca.crt"}},
+		Hosts:   []string{"a"},
+		Labels:  map[string]string{"zone": "cn", "env": "dev"},
+		Servers: map[string]testMergeServer{"main": {Host: "main", Port: 80}, "backup": {Host: "backup"}},
+		Extends: map[string]interface{}{"kafka": map[string]interface{}{"hosts": "k1", "acks": 1}},
+		Timeout: time.Second,
+	}
+
+	cfg := testMergeConfig{
+		Name:     "app",
+		Server:   testMergeServer{Port: 9090, TLS: &definations.TLSOptions{Enabled: true}},
+		Hosts:    []string{"b"},
+		Labels:   map[string]string{"env": "prod"},
+		Servers:  map[string]testMergeServer{"main": {Port: 443}},
+		Extends:  map[string]interface{}{"kafka": map[string]interface{}{"hosts": "k2"}},
+		internal: "kept",
+	}
+	testingutil.AssertNil(t, utils.MergeStructs(&cfg, &defaults, utils.MergeKeepExisting), "MergeStructs keep existing error")
+	testingutil.AssertEquals(t, "app", cfg.Name, "keep existing name")
+	testingutil.AssertTrue(t, cfg.Debug, "default debug filled")
+	testingutil.AssertEquals(t, "0.0.0.0", cfg.Server.Host, "nested struct filled")
+	testingutil.AssertEquals(t, 9090, cfg.Server.Port, "nested struct kept")
+	testingutil.AssertTrue(t, cfg.Server.TLS.Enabled, "nested pointer kept")
+	testingutil.AssertEquals(t, "ca.crt", cfg.Server.TLS.CaFile, "nested pointer filled")
+	testingutil.AssertEquals(t, "b", cfg.Hosts[0], "slice kept")
+	testingutil.AssertEquals(t, 1, len(cfg.Hosts), "slice not appended")
+	testingutil.AssertEquals(t, "prod", cfg.Labels["env"], "map value kept")
+	testingutil.AssertEquals(t, "cn", cfg.Labels["zone"], "map key filled")
+	testingutil.AssertEquals(t, "main", cfg.Servers["main"].Host, "map struct value merged")
+	testingutil.AssertEquals(t, 443, cfg.Servers["main"].Port, "map struct value kept")
+	testingutil.AssertEquals(t, "backup", cfg.Servers["backup"].Host, "map struct value filled")
+	kafka := cfg.Extends["kafka"].(map[string]interface{})
+	testingutil.AssertEquals(t, "k2", kafka["hosts"], "nested map value kept")
+	testingutil.AssertEquals(t, 1, kafka["acks"], "nested map value filled")
+	testingutil.AssertEquals(t, time.Second, cfg.Timeout, "duration filled")
+	testingutil.AssertEquals(t, "kept", cfg.internal, "unexported field untouched")
+	// the merged values are copied
+	defaults.Server.TLS.CaFile = "changed"
+	defaults.Labels["zone"] = "changed"
+	testingutil.AssertEquals(t, "ca.crt", cfg.Server.TLS.CaFile, "merged pointer field copied")
+	testingutil.AssertEquals(t, "cn", cfg.Labels["zone"], "merged map value copied")
+
+	override := testMergeConfig{Name: "override", Server: testMergeServer{Host: "127.0.0.1"}, Hosts: []string{"c"}, Labels: map[string]string{"env": "test"}}
+	testingutil.AssertNil(t, utils.MergeStructs(&cfg, override, utils.MergeOverride|utils.MergeAppendSlices), "MergeStructs override error")
+	testingutil.AssertEquals(t, "override", cfg.Name, "override name")
+	testingutil.AssertEquals(t, "127.0.0.1", cfg.Server.Host, "override nested")
+	testingutil.AssertEquals(t, 9090, cfg.Server.Port, "zero value not overriding")
+	testingutil.AssertTrue(t, cfg.Debug, "false not overriding")
+	testingutil.AssertEquals(t, "b,c", cfg.Hosts[0]+","+cfg.Hosts[1], "slices appended")
+	testingutil.AssertEquals(t, "test", cfg.Labels["env"], "override map value")
+
+	testingutil.AssertNil(t, utils.MergeStructs(&cfg, &testMergeConfig{Hosts: []string{"d"}}, utils.MergeOverride), "MergeStructs replace slices error")
+	testingutil.AssertEquals(t, 1, len(cfg.Hosts), "slices replaced")
+	testingutil.AssertNotNil(t, utils.MergeStructs(&cfg, &testMergeServer{}, utils.MergeOverride), "MergeStructs mismatched types")
+}
@@ -0,0 +1,196 @@
+package unittests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/discovery"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+// newFakeConsul serves the agent register/deregister and health service apis of consul
+func newFakeConsul() *httptest.Server {
+	services := map[string]map[string]interface{}{}
+	m := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		switch {
+		case "/v1/agent/service/register" == r.URL.Path:
+			service := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&service)
+			service["Service"] = service["Name"]
+			services[service["ID"].(string)] = service
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			delete(services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+			name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+			entries := []interface{}{}
+			for _, service := range services {
+				if name == service["Name"] {
+					entries = append(entries, map[string]interface{}{"Node": map[string]string{"Address": "127.0.0.1"}, "Service": service})
+				}
+			}
+			json.NewEncoder(w).Encode(entries)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// newFakeEtcd serves the lease and kv apis of etcd v3 json gateway
+func newFakeEtcd() *httptest.Server {
+	kvs := map[string]string{}
+	leases := map[string]string{} // key -> lease
+	nextLease := 100
+	m := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		decode := func(name string) string {
+			v, _ := base64.StdEncoding.DecodeString(fmt.Sprint(req[name]))
+			return string(v)
+		}
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			nextLease++
+			json.NewEncoder(w).Encode(map[string]string{"ID": fmt.Sprint(nextLease), "TTL": fmt.Sprint(req["TTL"])})
+		case "/v3/lease/keepalive":
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]string{"ID": fmt.Sprint(req["ID"]), "TTL": "30"}})
+		case "/v3/lease/revoke":
+			for k, lease := range leases {
+				if lease == fmt.Sprint(req["ID"]) {
+					delete(kvs, k)
+					delete(leases, k)
+				}
+			}
+			w.Write([]byte("{}"))
+		case "/v3/kv/put":
+			kvs[decode("key")] = fmt.Sprint(req["value"])
+			leases[decode("key")] = fmt.Sprint(req["lease"])
+			w.Write([]byte("{}"))
+		case "/v3/kv/range":
+			start, end := decode("key"), decode("range_end")
+			result := []map[string]string{}
+			for k, v := range kvs {
+				if k >= start && k < end {
+					result = append(result, map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(k)), "value": v})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"kvs": result})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func newNamedBackend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + ":" + r.URL.RequestURI()))
+	}))
+}
+
+func serviceInstanceOf(server *httptest.Server, name string, ID string) discovery.ServiceInstance {
+	instance := discovery.ServiceInstance{ID: ID, Name: name}
+	fmt.Sscanf(strings.TrimPrefix(server.URL, "http://127.0.0.1:"), "%d", &instance.Port)
+	instance.Address = "127.0.0.1"
+	return instance
+}
+
+func TestDiscoveryRegistries(t *testing.T) {
+	backend1 := newNamedBackend("b1")
+	defer backend1.Close()
+	backend2 := newNamedBackend("b2")
+	defer backend2.Close()
+
+	consul := newFakeConsul()
+	defer consul.Close()
+	etcd := newFakeEtcd()
+	defer etcd.Close()
+	registries := map[string]discovery.Registry{
+		"consul": discovery.NewConsulRegistry(consul.URL, "token"),
+		"etcd":   discovery.NewEtcdRegistry(etcd.URL),
+	}
+	for kind, registry := range registries {
+		testingutil.AssertNil(t, registry.Register(serviceInstanceOf(backend1, "billing", "billing-1")), kind+" register billing-1")
+		testingutil.AssertNil(t, registry.Register(serviceInstanceOf(backend2, "billing", "billing-2")), kind+" register billing-2")
+		testingutil.AssertNil(t, registry.Register(discovery.ServiceInstance{ID: "orders-1", Name: "orders", Address: "10.0.0.1", Port: 80}), kind+" register orders-1")
+
+		instances, err := registry.Resolve("billing")
+		testingutil.AssertNil(t, err, kind+" resolve billing error")
+		testingutil.AssertEquals(t, 2, len(instances), kind+" resolve billing instances")
+
+		resolver := discovery.NewResolver(registry, time.Minute)
+		endpoints, err := resolver.ResolveEndpoints("billing")
+		testingutil.AssertNil(t, err, kind+" ResolveEndpoints error")
+		testingutil.AssertEquals(t, 2, len(endpoints), kind+" ResolveEndpoints")
+
+		responses := map[string]bool{}
+		for i := 0; i < 4; i++ {
+			resp, err := httpclient.HTTPQuery("GET", "service://billing/api/v1?id=1", nil, httpclient.WithEndpointResolver(resolver))
+			testingutil.AssertNil(t, err, kind+" query service url error")
+			responses[string(resp)] = true
+		}
+		testingutil.AssertEquals(t, 2, len(responses), kind+" service url load balanced")
+		testingutil.AssertTrue(t, responses["b1:/api/v1?id=1"] && responses["b2:/api/v1?id=1"], fmt.Sprintf("%s service url responses %v", kind, responses))
+
+		testingutil.AssertNil(t, registry.Deregister("billing-2"), kind+" deregister billing-2")
+		instances, _ = registry.Resolve("billing")
+		testingutil.AssertEquals(t, 1, len(instances), kind+" resolve after deregister")
+		testingutil.AssertEquals(t, "billing-1", instances[0].ID, kind+" resolved instance after deregister")
+		endpoints, _ = resolver.ResolveEndpoints("billing")
+		testingutil.AssertEquals(t, 2, len(endpoints), kind+" cached endpoints")
+		resolver.Invalidate("billing")
+		endpoints, _ = resolver.ResolveEndpoints("billing")
+		testingutil.AssertEquals(t, 1, len(endpoints), kind+" endpoints after invalidate")
+
+		_, err = resolver.ResolveEndpoints("missing")
+		testingutil.AssertNotNil(t, err, kind+" resolve missing service")
+		registry.Deregister("billing-1")
+		registry.Deregister("orders-1")
+	}
+}
+
+func TestHTTPClientEndpoints(t *testing.T) {
+	backend := newNamedBackend("static")
+	defer backend.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	// the failed endpoint is skipped in cooldown
+	for i := 0; i < 4; i++ {
+		resp, err := httpclient.HTTPQuery("GET", "service://static/ping", nil, httpclient.WithEndpoints(downURL, backend.URL))
+		if 0 == i && nil != err {
+			continue
+		}
+		testingutil.AssertNil(t, err, "query static endpoints error")
+		testingutil.AssertEquals(t, "static:/ping", string(resp), "query static endpoints")
+	}
+
+	httpclient.ResetTransportPool()
+	resp, err := httpclient.HTTPQuery("GET", "service://static/ping", nil, httpclient.WithEndpoints(backend.URL))
+	testingutil.AssertNil(t, err, "query after ResetTransportPool error")
+	testingutil.AssertEquals(t, "static:/ping", string(resp), "query after ResetTransportPool")
+
+	_, err = httpclient.HTTPQuery("GET", "service://nothing/ping", nil)
+	testingutil.AssertNotNil(t, err, "service url without endpoints")
+
+	httpclient.SetEndpointResolver(httpclient.EndpointResolverFunc(func(service string) ([]string, error) {
+		return []string{backend.URL + "/"}, nil
+	}))
+	defer httpclient.SetEndpointResolver(nil)
+	resp, err = httpclient.HTTPQuery("GET", "service://any", nil)
+	testingutil.AssertNil(t, err, "query by default resolver error")
+	testingutil.AssertEquals(t, "static:/", string(resp), "query by default resolver")
+}
@@ -1,10 +1,13 @@
 package unittests
 
 import (
+	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/libpub/golib/testingutil"
 	"github.com/libpub/golib/utils/cryptoes"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCryptoTextsAES(t *testing.T) {
@@ -22,3 +25,218 @@ func TestCryptoTextsAES(t *testing.T) {
 	testingutil.AssertNil(t, err, "cryptoes.AESDecryptCBC error")
 	testingutil.AssertEquals(t, txt, string(decodedBytes), "cryptoes.AESDecryptCBC result")
 }
+
+func TestCryptoTextsAESGCM(t *testing.T) {
+	txt := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz01234567890"
+	key := "ABCDEFGHIJKLMNOPQRSTUVWXYZ012345"
+	aad := []byte("header")
+	encoded, err := cryptoes.AESEncryptGCM([]byte(txt), key, aad)
+	testingutil.AssertNil(t, err, "cryptoes.AESEncryptGCM error")
+	encodedAgain, err := cryptoes.AESEncryptGCM([]byte(txt), key, aad)
+	testingutil.AssertNil(t, err, "cryptoes.AESEncryptGCM again error")
+	testingutil.AssertNotEquals(t, encoded, encodedAgain, "cryptoes.AESEncryptGCM random nonce")
+	decodedBytes, err := cryptoes.AESDecryptGCM(encoded, key, aad)
+	testingutil.AssertNil(t, err, "cryptoes.AESDecryptGCM error")
+	testingutil.AssertEquals(t, txt, string(decodedBytes), "cryptoes.AESDecryptGCM result")
+
+	_, err = cryptoes.AESDecryptGCM(encoded, key, []byte("other"))
+	testingutil.AssertNotNil(t, err, "cryptoes.AESDecryptGCM with other aad")
+	crypted, _ := cryptoes.Base64Decode(encoded)
+	crypted[len(crypted)-1] ^= 0xff
+	_, err = cryptoes.AESDecryptGCM(cryptoes.Base64Encode(crypted), key, aad)
+	testingutil.AssertNotNil(t, err, "cryptoes.AESDecryptGCM tampered")
+	_, err = cryptoes.AesDecryptGCM([]byte("short"), []byte(key), nil)
+	testingutil.AssertEquals(t, cryptoes.ErrAESGCMCiphertextTooShort, err, "cryptoes.AesDecryptGCM too short")
+}
+
+func TestCryptoRSA(t *testing.T) {
+	privKey, err := cryptoes.GenerateRSAKey(2048)
+	testingutil.AssertNil(t, err, "cryptoes.GenerateRSAKey error")
+	txt := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz01234567890")
+
+	crypted, err := cryptoes.RSAEncryptOAEP(txt, &privKey.PublicKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSAEncryptOAEP error")
+	decrypted, err := cryptoes.RSADecryptOAEP(crypted, privKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSADecryptOAEP error")
+	testingutil.AssertEquals(t, string(txt), string(decrypted), "cryptoes.RSADecryptOAEP result")
+
+	signature, err := cryptoes.RSASignPSS(txt, privKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSASignPSS error")
+	testingutil.AssertNil(t, cryptoes.RSAVerifyPSS(txt, signature, &privKey.PublicKey), "cryptoes.RSAVerifyPSS")
+	testingutil.AssertNotNil(t, cryptoes.RSAVerifyPSS([]byte("tampered"), signature, &privKey.PublicKey), "cryptoes.RSAVerifyPSS tampered")
+
+	signature, err = cryptoes.RSASignPKCS1v15(txt, privKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSASignPKCS1v15 error")
+	testingutil.AssertNil(t, cryptoes.RSAVerifyPKCS1v15(txt, signature, &privKey.PublicKey), "cryptoes.RSAVerifyPKCS1v15")
+	testingutil.AssertNotNil(t, cryptoes.RSAVerifyPKCS1v15([]byte("tampered"), signature, &privKey.PublicKey), "cryptoes.RSAVerifyPKCS1v15 tampered")
+
+	pemBytes := cryptoes.RSAPrivateKeyToPEM(privKey)
+	parsedPriv, err := cryptoes.ParseRSAPrivateKeyPEM(pemBytes)
+	testingutil.AssertNil(t, err, "cryptoes.ParseRSAPrivateKeyPEM pkcs1 error")
+	testingutil.AssertTrue(t, privKey.Equal(parsedPriv), "cryptoes.ParseRSAPrivateKeyPEM pkcs1 result")
+	pemBytes, err = cryptoes.RSAPrivateKeyToPKCS8PEM(privKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSAPrivateKeyToPKCS8PEM error")
+	parsedPriv, err = cryptoes.ParseRSAPrivateKeyPEM(pemBytes)
+	testingutil.AssertNil(t, err, "cryptoes.ParseRSAPrivateKeyPEM pkcs8 error")
+	testingutil.AssertTrue(t, privKey.Equal(parsedPriv), "cryptoes.ParseRSAPrivateKeyPEM pkcs8 result")
+
+	pemBytes, err = cryptoes.RSAPublicKeyToPEM(&privKey.PublicKey)
+	testingutil.AssertNil(t, err, "cryptoes.RSAPublicKeyToPEM error")
+	parsedPub, err := cryptoes.ParseRSAPublicKeyPEM(pemBytes)
+	testingutil.AssertNil(t, err, "cryptoes.ParseRSAPublicKeyPEM error")
+	testingutil.AssertTrue(t, privKey.PublicKey.Equal(parsedPub), "cryptoes.ParseRSAPublicKeyPEM result")
+
+	_, err = cryptoes.ParseRSAPublicKeyPEM([]byte("invalid"))
+	testingutil.AssertEquals(t, cryptoes.ErrInvalidPEM, err, "cryptoes.ParseRSAPublicKeyPEM invalid")
+}
+
+func TestCryptoPassword(t *testing.T) {
+	opts := cryptoes.DefaultPasswordHashOptions
+	opts.Argon2Memory = 1024
+	hash, err := cryptoes.HashPasswordWithOptions("secret", opts)
+	testingutil.AssertNil(t, err, "cryptoes.HashPasswordWithOptions argon2id error")
+	testingutil.AssertTrue(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=4$"), "cryptoes.HashPasswordWithOptions argon2id format")
+	ok, err := cryptoes.VerifyPassword("secret", hash)
+	testingutil.AssertNil(t, err, "cryptoes.VerifyPassword argon2id error")
+	testingutil.AssertTrue(t, ok, "cryptoes.VerifyPassword argon2id")
+	ok, err = cryptoes.VerifyPassword("wrong", hash)
+	testingutil.AssertNil(t, err, "cryptoes.VerifyPassword argon2id wrong error")
+	testingutil.AssertFalse(t, ok, "cryptoes.VerifyPassword argon2id wrong")
+	testingutil.AssertFalse(t, cryptoes.PasswordNeedsRehash(hash, opts), "cryptoes.PasswordNeedsRehash same options")
+	testingutil.AssertTrue(t, cryptoes.PasswordNeedsRehash(hash, cryptoes.DefaultPasswordHashOptions), "cryptoes.PasswordNeedsRehash weaker memory")
+
+	opts.Algorithm = cryptoes.PasswordAlgorithmBcrypt
+	opts.BcryptCost = bcrypt.MinCost
+	hash, err = cryptoes.HashPasswordWithOptions("secret", opts)
+	testingutil.AssertNil(t, err, "cryptoes.HashPasswordWithOptions bcrypt error")
+	ok, err = cryptoes.VerifyPassword("secret", hash)
+	testingutil.AssertNil(t, err, "cryptoes.VerifyPassword bcrypt error")
+	testingutil.AssertTrue(t, ok, "cryptoes.VerifyPassword bcrypt")
+	ok, err = cryptoes.VerifyPassword("wrong", hash)
+	testingutil.AssertNil(t, err, "cryptoes.VerifyPassword bcrypt wrong error")
+	testingutil.AssertFalse(t, ok, "cryptoes.VerifyPassword bcrypt wrong")
+	testingutil.AssertFalse(t, cryptoes.PasswordNeedsRehash(hash, opts), "cryptoes.PasswordNeedsRehash bcrypt same cost")
+	testingutil.AssertTrue(t, cryptoes.PasswordNeedsRehash(hash, cryptoes.DefaultPasswordHashOptions), "cryptoes.PasswordNeedsRehash bcrypt to argon2id")
+
+	_, err = cryptoes.VerifyPassword("secret", "5ebe2294ecd0e0f08eab7690d2a6ee69")
+	testingutil.AssertEquals(t, cryptoes.ErrUnsupportedPasswordHash, err, "cryptoes.VerifyPassword md5")
+	_, err = cryptoes.VerifyPassword("secret", "$argon2id$v=19$m=1024$salt")
+	testingutil.AssertEquals(t, cryptoes.ErrInvalidPasswordHash, err, "cryptoes.VerifyPassword malformed")
+}
+
+func TestCryptoKeyDerivation(t *testing.T) {
+	// RFC 7914 PBKDF2-HMAC-SHA256 test vector
+	key := cryptoes.DerivePBKDF2([]byte("passwd"), []byte("salt"), 1, 64)
+	testingutil.AssertEquals(t, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key), "cryptoes.DerivePBKDF2")
+	// RFC 7914 scrypt test vector
+	key, err := cryptoes.DeriveScrypt([]byte("password"), []byte("NaCl"), 1024, 8, 16, 64)
+	testingutil.AssertNil(t, err, "cryptoes.DeriveScrypt error")
+	testingutil.AssertEquals(t, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b3731622eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640", hex.EncodeToString(key), "cryptoes.DeriveScrypt")
+	// RFC 5869 test case 1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	key, err = cryptoes.DeriveHKDF(ikm, salt, info, 42)
+	testingutil.AssertNil(t, err, "cryptoes.DeriveHKDF error")
+	testingutil.AssertEquals(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865", hex.EncodeToString(key), "cryptoes.DeriveHKDF")
+}
+
+func TestCryptoEnvelope(t *testing.T) {
+	masterKey, err := cryptoes.GenerateRandomBytes(32)
+	testingutil.AssertNil(t, err, "cryptoes.GenerateRandomBytes error")
+	wrapper := cryptoes.NewMasterKeyWrapper(masterKey)
+	envelope, err := cryptoes.EnvelopeEncrypt([]byte("secret"), []byte("config"), wrapper)
+	testingutil.AssertNil(t, err, "cryptoes.EnvelopeEncrypt error")
+	plaintext, err := cryptoes.EnvelopeDecrypt(envelope, []byte("config"), wrapper)
+	testingutil.AssertNil(t, err, "cryptoes.EnvelopeDecrypt error")
+	testingutil.AssertEquals(t, "secret", string(plaintext), "cryptoes.EnvelopeDecrypt result")
+	_, err = cryptoes.EnvelopeDecrypt(envelope, []byte("config"), cryptoes.NewMasterKeyWrapper(make([]byte, 32)))
+	testingutil.AssertNotNil(t, err, "cryptoes.EnvelopeDecrypt by other master key")
+
+	kmsCalls := 0
+	kms := cryptoes.KeyWrapperFuncs{
+		Wrap: func(dataKey []byte) ([]byte, error) {
+			kmsCalls++
+			return wrapper.WrapKey(dataKey)
+		},
+		Unwrap: func(wrappedKey []byte) ([]byte, error) {
+			kmsCalls++
+			return wrapper.UnwrapKey(wrappedKey)
+		},
+	}
+	encrypted, err := cryptoes.EnvelopeEncryptString([]byte("password"), kms)
+	testingutil.AssertNil(t, err, "cryptoes.EnvelopeEncryptString error")
+	plaintext, err = cryptoes.EnvelopeDecryptString(encrypted, kms)
+	testingutil.AssertNil(t, err, "cryptoes.EnvelopeDecryptString error")
+	testingutil.AssertEquals(t, "password", string(plaintext), "cryptoes.EnvelopeDecryptString result")
+	testingutil.AssertEquals(t, 2, kmsCalls, "kms callbacks")
+}
+
+func TestCryptoSM3SM4(t *testing.T) {
+	// GB/T 32905-2016 examples
+	sum := cryptoes.SM3Sum([]byte("abc"))
+	testingutil.AssertEquals(t, "66c7f0f462eeedd9d1f2d46bdc10e4e24167c4875cf2f7a2297da02b8f4ba8e0", hex.EncodeToString(sum[:]), "cryptoes.SM3Sum abc")
+	h := cryptoes.NewSM3()
+	for i := 0; i < 16; i++ {
+		h.Write([]byte("abcd"))
+	}
+	testingutil.AssertEquals(t, "debe9ff92275b8a138604889c18e5a4d6fdb70e5387e5765293dcba39c0c5732", hex.EncodeToString(h.Sum(nil)), "cryptoes.NewSM3 abcd*16")
+
+	// GB/T 32907-2016 example
+	key, _ := hex.DecodeString("0123456789abcdeffedcba9876543210")
+	block, err := cryptoes.NewSM4Cipher(key)
+	testingutil.AssertNil(t, err, "cryptoes.NewSM4Cipher error")
+	dst := make([]byte, cryptoes.SM4BlockSize)
+	block.Encrypt(dst, key)
+	testingutil.AssertEquals(t, "681edf34d206965e86b3e94f536e4246", hex.EncodeToString(dst), "cryptoes.NewSM4Cipher encrypt")
+	block.Decrypt(dst, dst)
+	testingutil.AssertEquals(t, hex.EncodeToString(key), hex.EncodeToString(dst), "cryptoes.NewSM4Cipher decrypt")
+	_, err = cryptoes.NewSM4Cipher([]byte("short"))
+	testingutil.AssertEquals(t, cryptoes.SM4KeySizeError(5), err, "cryptoes.NewSM4Cipher short key")
+
+	// the same as openssl enc -sm4-cbc
+	txt := "sm4 plain text data"
+	encoded, err := cryptoes.SM4EncryptCBC([]byte(txt), string(key), string(make([]byte, 16)))
+	testingutil.AssertNil(t, err, "cryptoes.SM4EncryptCBC error")
+	testingutil.AssertEquals(t, "cxhdQa60MxumY+cnYmrT+yik+lQP5o21sbFwDxzhKoo=", encoded, "cryptoes.SM4EncryptCBC result")
+	decoded, err := cryptoes.SM4DecryptCBC(encoded, string(key), string(make([]byte, 16)))
+	testingutil.AssertNil(t, err, "cryptoes.SM4DecryptCBC error")
+	testingutil.AssertEquals(t, txt, string(decoded), "cryptoes.SM4DecryptCBC result")
+
+	crypted, err := cryptoes.SM4EncryptGCM([]byte(txt), key, []byte("aad"))
+	testingutil.AssertNil(t, err, "cryptoes.SM4EncryptGCM error")
+	decoded, err = cryptoes.SM4DecryptGCM(crypted, key, []byte("aad"))
+	testingutil.AssertNil(t, err, "cryptoes.SM4DecryptGCM error")
+	testingutil.AssertEquals(t, txt, string(decoded), "cryptoes.SM4DecryptGCM result")
+}
+
+func TestCryptoSM2(t *testing.T) {
+	// the key and signature generated by openssl
+	d, _ := hex.DecodeString("9c734b213261f8b5b5bab31ee4dde4939727ed79247d6f9e6dae77f581d74ad0")
+	priv, err := cryptoes.NewSM2PrivateKey(d)
+	testingutil.AssertNil(t, err, "cryptoes.NewSM2PrivateKey error")
+	testingutil.AssertEquals(t, "044fc9249aaf0786e000ce445f85dc5f819bff2f2b1939f3964d1169e2a0ce04d00c933d87065515d521ae039d77cb7f9fa887694ddc2455902760a24342658117",
+		hex.EncodeToString(priv.SM2PublicKey.Bytes()), "cryptoes.NewSM2PrivateKey public key")
+	msg := []byte("hello sm2")
+	opensslSig, _ := hex.DecodeString("304402200466ea8c99d645db7f659d96ce7c15284e020fd3a8d28a1ca1113caa03f3062f02202ac53cd9aa9ec47feba4f6bbd6a1adada84025cf20e3fd334285357b50e27f6a")
+	testingutil.AssertTrue(t, cryptoes.SM2Verify(&priv.SM2PublicKey, msg, nil, opensslSig), "cryptoes.SM2Verify openssl signature")
+
+	priv, err = cryptoes.GenerateSM2Key()
+	testingutil.AssertNil(t, err, "cryptoes.GenerateSM2Key error")
+	pub, err := cryptoes.ParseSM2PublicKey(priv.SM2PublicKey.Bytes())
+	testingutil.AssertNil(t, err, "cryptoes.ParseSM2PublicKey error")
+	signature, err := cryptoes.SM2Sign(priv, msg, []byte("alice@example.com"))
+	testingutil.AssertNil(t, err, "cryptoes.SM2Sign error")
+	testingutil.AssertTrue(t, cryptoes.SM2Verify(pub, msg, []byte("alice@example.com"), signature), "cryptoes.SM2Verify")
+	testingutil.AssertFalse(t, cryptoes.SM2Verify(pub, msg, nil, signature), "cryptoes.SM2Verify other uid")
+	testingutil.AssertFalse(t, cryptoes.SM2Verify(pub, []byte("tampered"), []byte("alice@example.com"), signature), "cryptoes.SM2Verify tampered")
+
+	crypted, err := cryptoes.SM2Encrypt(pub, msg)
+	testingutil.AssertNil(t, err, "cryptoes.SM2Encrypt error")
+	decrypted, err := cryptoes.SM2Decrypt(priv, crypted)
+	testingutil.AssertNil(t, err, "cryptoes.SM2Decrypt error")
+	testingutil.AssertEquals(t, string(msg), string(decrypted), "cryptoes.SM2Decrypt result")
+	crypted[len(crypted)-1] ^= 0xff
+	_, err = cryptoes.SM2Decrypt(priv, crypted)
+	testingutil.AssertEquals(t, cryptoes.ErrSM2DecryptFailed, err, "cryptoes.SM2Decrypt tampered")
+}
@@ -0,0 +1,61 @@
+package unittests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libpub/golib/config/results"
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/httpserver"
+	"github.com/libpub/golib/testingutil"
+)
+
+type envelopeUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestResultEnvelope(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteResult(w, []envelopeUser{{ID: 1, Name: "alice"}}, &results.Pagination{Page: 1, PageSize: 10, Total: 1})
+	})
+	mux.HandleFunc("/conflict", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteResultError(w, errors.NewError(errors.Conflict, "user exists"))
+	})
+	mux.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteResultError(w, results.ErrorOf(results.NotLogin, "login required"))
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteJSON(w, http.StatusServiceUnavailable, results.ResultObject{Code: results.ServiceUnavailable, Message: "maintaining"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	users := []envelopeUser{}
+	result, err := httpclient.HTTPGetEnvelope(server.URL+"/users", nil, &users)
+	testingutil.AssertNil(t, err, "HTTPGetEnvelope")
+	testingutil.AssertEquals(t, results.OK, result.Code, "code")
+	testingutil.AssertEquals(t, int64(1), result.Pagination.Total, "pagination total")
+	testingutil.AssertEquals(t, 1, len(users), "decoded data")
+	testingutil.AssertEquals(t, "alice", users[0].Name, "decoded user")
+
+	result, err = httpclient.HTTPGetEnvelope(server.URL+"/conflict", nil, nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Conflict), "conflict category")
+	testingutil.AssertEquals(t, results.Conflict, result.Code, "conflict code")
+	testingutil.AssertEquals(t, "code:409 message:user exists", err.Error(), "code error message")
+
+	_, err = httpclient.HTTPQueryEnvelope("POST", server.URL+"/custom", map[string]string{"name": "bob"}, nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Unauthorized), "not login is unauthorized")
+	testingutil.AssertEquals(t, results.NotLogin, results.CodeOf(err), "code of error")
+
+	result, err = httpclient.HTTPGetEnvelope(server.URL+"/unavailable", nil, nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Upstream5xx), "envelope of failed http response")
+	testingutil.AssertEquals(t, "maintaining", result.Message, "message of failed http response")
+
+	results.RegisterCodeCategory(7001, errors.Conflict)
+	testingutil.AssertTrue(t, errors.Is(results.ErrorOf(7001, ""), errors.Conflict), "registered category")
+	testingutil.AssertNil(t, results.ErrorOf(results.OK, "OK"), "OK code")
+}
@@ -0,0 +1,102 @@
+package unittests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+func TestErrorCategories(t *testing.T) {
+	cases := []struct {
+		statusCode int
+		category   errors.Category
+		httpStatus int
+	}{
+		{http.StatusRequestTimeout, errors.Timeout, http.StatusRequestTimeout},
+		{http.StatusGatewayTimeout, errors.Timeout, http.StatusGatewayTimeout},
+		{http.StatusTooManyRequests, errors.TooManyRequests, http.StatusTooManyRequests},
+		{http.StatusUnauthorized, errors.Unauthorized, http.StatusUnauthorized},
+		{http.StatusForbidden, errors.Unauthorized, http.StatusForbidden},
+		{http.StatusConflict, errors.Conflict, http.StatusConflict},
+		{http.StatusInternalServerError, errors.Upstream5xx, http.StatusBadGateway},
+		{http.StatusServiceUnavailable, errors.Upstream5xx, http.StatusBadGateway},
+		{http.StatusNotFound, errors.Unknown, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		name := fmt.Sprintf("status %d", c.statusCode)
+		err := fmt.Errorf("query failed:%w", errors.FromResponse(c.statusCode, "", []byte("body")))
+		testingutil.AssertEquals(t, c.category, errors.CategoryOf(err), name+" category")
+		testingutil.AssertTrue(t, errors.Is(err, c.category), name+" is category")
+		testingutil.AssertEquals(t, c.httpStatus, errors.HTTPStatus(err), name+" http status")
+		testingutil.AssertEquals(t, "body", string(errors.ResponseBody(err)), name+" body")
+	}
+	testingutil.AssertFalse(t, errors.Is(errors.FromResponse(http.StatusConflict, "", nil), errors.Timeout), "is other category")
+
+	cause := context.DeadlineExceeded
+	testingutil.AssertEquals(t, errors.Timeout, errors.CategoryOf(cause), "deadline exceeded")
+	wrapped := errors.Wrap(cause, errors.Conflict, "")
+	testingutil.AssertEquals(t, cause.Error(), wrapped.Error(), "wrapped message")
+	testingutil.AssertTrue(t, errors.Is(wrapped, cause), "is wrapped cause")
+	testingutil.AssertEquals(t, errors.Conflict, errors.CategoryOf(wrapped), "category of wrapper")
+	testingutil.AssertEquals(t, errors.Unknown, errors.CategoryOf(errors.New("plain")), "plain error")
+	testingutil.AssertEquals(t, http.StatusInternalServerError, errors.HTTPStatus(errors.New("plain")), "plain http status")
+	testingutil.AssertEquals(t, errors.TooManyRequests, errors.CategoryOf(errors.TooManyRequests), "category as error")
+}
+
+func TestHTTPQueryErrorCategories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			<-r.Context().Done()
+		case "/busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"retry":"later"}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := httpclient.HTTPQuery("GET", server.URL+"/busy", nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.TooManyRequests), "too many requests")
+	testingutil.AssertEquals(t, "429 Too Many Requests", err.Error(), "status line message")
+	testingutil.AssertEquals(t, `{"retry":"later"}`, string(errors.ResponseBody(err)), "retained body")
+	testingutil.AssertEquals(t, `{"retry":"later"}`, string(resp), "response body")
+
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/down", nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Upstream5xx), "upstream 5xx")
+
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/slow", nil, httpclient.WithTimeout(1))
+	testingutil.AssertTrue(t, errors.Is(err, errors.Timeout), "timeout")
+}
+
+func TestKafkaErrorCategories(t *testing.T) {
+	testingutil.AssertTrue(t, errors.Is(kafka.ErrRequestTimeout, errors.Timeout), "request timeout")
+
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("orders")
+	broker.setProduceError("orders", k.TopicAuthorizationFailed)
+	events := make(chan kafka.ErrorEvent, 4)
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+	p.SetErrorCallback(func(event kafka.ErrorEvent) {
+		events <- event
+	})
+	testingutil.AssertNil(t, p.Send("orders", []byte("v")), "async send")
+	select {
+	case event := <-events:
+		testingutil.AssertTrue(t, errors.Is(event.Err, errors.Unauthorized), "unauthorized")
+		testingutil.AssertTrue(t, kafka.IsFatalError(event.Err), "kafka error kept")
+	case <-time.After(10 * time.Second):
+		t.Fatalf("error event not received")
+	}
+}
@@ -0,0 +1,124 @@
+package unittests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/eventbus"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+type orderCreatedEvent struct {
+	OrderID string `json:"orderId"`
+	Amount  int    `json:"amount"`
+}
+
+func (e orderCreatedEvent) EventType() string {
+	return "order.created"
+}
+
+func TestEventBusFanOut(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	broker.createTopics("events")
+	producer := kafka.NewProducer(broker.addr(), 0)
+	defer producer.Close()
+
+	webhooks := make(chan *eventbus.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		event := &eventbus.Event{}
+		json.Unmarshal(body, event)
+		if r.Header.Get("Idempotency-Key") != event.ID || r.Header.Get(eventbus.HeaderEventType) != event.Type {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		webhooks <- event
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	local := eventbus.NewLocalSink()
+	defer local.Close(context.Background())
+	locals := make(chan *eventbus.Event, 2)
+	_, err := local.Subscribe("order.created", func(event *eventbus.Event) { locals <- event })
+	testingutil.AssertNil(t, err, "subscribe order.created")
+	_, err = local.Subscribe(eventbus.AllEventTypes, func(event *eventbus.Event) { locals <- event })
+	testingutil.AssertNil(t, err, "subscribe all")
+
+	bus := eventbus.NewBus(eventbus.WithSource("orders-service"))
+	bus.Route("order.created", eventbus.NewKafkaSink(producer, "events"), eventbus.NewWebhookSink(server.URL, 0))
+	bus.Route(eventbus.AllEventTypes, local)
+	testingutil.AssertEquals(t, 3, len(bus.Sinks("order.created")), "sinks of order.created")
+
+	event, err := bus.EmitEvent(context.Background(), orderCreatedEvent{OrderID: "o1", Amount: 100}, eventbus.WithSubject("o1"), eventbus.WithEventHeader("Trace-Id", "t1"))
+	testingutil.AssertNil(t, err, "emit")
+	testingutil.AssertNotEquals(t, "", event.ID, "event id")
+
+	records := broker.records("events")
+	testingutil.AssertEquals(t, 1, len(records), "kafka records")
+	testingutil.AssertEquals(t, "o1", string(records[0].Key), "record key")
+	sent := &eventbus.Event{}
+	testingutil.AssertNil(t, json.Unmarshal(records[0].Value, sent), "decode record")
+	testingutil.AssertEquals(t, event.ID, sent.ID, "record event id")
+	testingutil.AssertEquals(t, "orders-service", sent.Source, "record event source")
+	testingutil.AssertEquals(t, "t1", sent.Headers["Trace-Id"], "record event header")
+
+	received := <-webhooks
+	data := orderCreatedEvent{}
+	testingutil.AssertNil(t, received.Decode(&data), "decode webhook data")
+	testingutil.AssertEquals(t, "o1", data.OrderID, "webhook order id")
+	testingutil.AssertEquals(t, 100, data.Amount, "webhook amount")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-locals:
+			testingutil.AssertEquals(t, event.ID, e.ID, "local event id")
+		case <-time.After(time.Second):
+			t.Fatalf("local event %d not delivered", i)
+		}
+	}
+
+	// the events of other types reach the sinks of all types only
+	_, err = bus.Emit(context.Background(), "order.paid", map[string]string{"orderId": "o1"})
+	testingutil.AssertNil(t, err, "emit order.paid")
+	select {
+	case e := <-locals:
+		testingutil.AssertEquals(t, "order.paid", e.Type, "local event type")
+	case <-time.After(time.Second):
+		t.Fatal("order.paid not delivered")
+	}
+	testingutil.AssertEquals(t, 1, len(broker.records("events")), "kafka records after order.paid")
+}
+
+func TestEventBusSinkErrors(t *testing.T) {
+	failure := errors.New("sink unavailable")
+	delivered := int32(0)
+	bus := eventbus.NewBus()
+	bus.Route("user.deleted",
+		eventbus.SinkFunc("failing", func(ctx context.Context, event *eventbus.Event) error { return failure }),
+		eventbus.SinkFunc("counting", func(ctx context.Context, event *eventbus.Event) error {
+			atomic.AddInt32(&delivered, 1)
+			return nil
+		}))
+
+	_, err := bus.Emit(context.Background(), "user.deleted", nil)
+	testingutil.AssertNotNil(t, err, "emit with failing sink")
+	testingutil.AssertErrorIs(t, err, failure, "sink error unwrapped")
+	var sinkErr *eventbus.SinkError
+	testingutil.AssertTrue(t, errors.As(err, &sinkErr), "sink error type")
+	testingutil.AssertEquals(t, "failing", sinkErr.Sink, "failed sink")
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&delivered), "other sink delivered")
+
+	_, err = bus.Emit(context.Background(), "user.created", nil)
+	testingutil.AssertErrorIs(t, err, eventbus.ErrNoSink, "unrouted event")
+	_, err = eventbus.NewBus(eventbus.WithIgnoreUnrouted()).Emit(context.Background(), "user.created", nil)
+	testingutil.AssertNil(t, err, "ignored unrouted event")
+}
@@ -0,0 +1,56 @@
+package unittests
+
+import (
+	"encoding/json"
+	"testing"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/libpub/golib/config/results"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestGoldenKafkaPacket(t *testing.T) {
+	packet := &kafka.KafkaPacket{
+		ContentType:   "application/json",
+		SendTo:        "orders",
+		CorrelationId: "correlation-1",
+		MessageId:     "message-1",
+		Timestamp:     1700000000000,
+		StatusCode:    200,
+		Headers:       []*kafka.KafkaPacket_Header{{Name: "X-Trace-Id", Value: "trace-1"}},
+		Body:          []byte(`{"orderId":42}`),
+	}
+	content, err := proto.Marshal(packet)
+	testingutil.AssertNil(t, err, "marshal protobuf")
+	testingutil.AssertGolden(t, "kafkapacket.pb.golden", content)
+
+	content, err = json.Marshal(packet)
+	testingutil.AssertNil(t, err, "marshal json")
+	testingutil.AssertGoldenJSON(t, "kafkapacket.json.golden", content)
+}
+
+func TestGoldenResultEnvelope(t *testing.T) {
+	result := results.NewOKResult([]string{"a", "b"}, &results.Pagination{Page: 2, PageSize: 2, Total: 5, NextCursor: "c3"})
+	content, err := json.Marshal(result)
+	testingutil.AssertNil(t, err, "marshal envelope")
+	testingutil.AssertGoldenJSON(t, "envelope.json.golden", content)
+	testingutil.AssertJSONEqual(t, string(testingutil.Golden(t, "envelope.json.golden")), string(content), "golden read back")
+}
+
+func TestKafkaPacketVersionCompatibility(t *testing.T) {
+	// 增加version 之前编码的数据版本为0
+	old := &kafka.KafkaPacket{}
+	testingutil.AssertNil(t, proto.Unmarshal(testingutil.Golden(t, "kafkapacket.pb.golden"), old), "unmarshal old packet")
+	testingutil.AssertEquals(t, uint32(0), old.GetVersion(), "old packet version")
+	testingutil.AssertEquals(t, "orders", old.GetSendTo(), "old packet send to")
+
+	// 新版本增加的字段在旧版本解码时被忽略
+	content, err := proto.Marshal(&kafka.KafkaPacket{SendTo: "orders", Version: kafka.KafkaPacketVersion + 1})
+	testingutil.AssertNil(t, err, "marshal newer packet")
+	content = append(content, 0xa2, 0x01, 0x03, 'n', 'e', 'w') // field 20, bytes "new"
+	newer := &kafka.KafkaPacket{}
+	testingutil.AssertNil(t, proto.Unmarshal(content, newer), "unmarshal newer packet")
+	testingutil.AssertEquals(t, uint32(kafka.KafkaPacketVersion+1), newer.GetVersion(), "newer packet version")
+	testingutil.AssertEquals(t, "orders", newer.GetSendTo(), "newer packet send to")
+}
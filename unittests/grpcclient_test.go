@@ -0,0 +1,163 @@
+package unittests
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/grpcclient"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/metrics"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeHealthServer fails the first failures checks with Unavailable, blocks the "slow" service until the
+// call done, and records the request ids received
+type fakeHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	failures   int32
+	calls      int32
+	requestIDs chan string
+}
+
+func (s *fakeHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(grpcclient.MetadataRequestID)) > 0 {
+		s.requestIDs <- md.Get(grpcclient.MetadataRequestID)[0]
+	}
+	if "slow" == req.Service {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if atomic.AddInt32(&s.calls, 1) <= atomic.LoadInt32(&s.failures) {
+		return nil, status.Error(codes.Unavailable, "warming up")
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func serveFakeHealth(t *testing.T, health *fakeHealthServer, options ...grpc.ServerOption) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testingutil.AssertNil(t, err, "listen grpc")
+	server := grpc.NewServer(options...)
+	healthpb.RegisterHealthServer(server, health)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestGRPCClientInterceptors(t *testing.T) {
+	health := &fakeHealthServer{failures: 2, requestIDs: make(chan string, 10)}
+	addr := serveFakeHealth(t, health)
+	policy := utils.RetryPolicy{MaxAttempts: 5, InitialInterval: time.Millisecond}
+	conn, err := grpcclient.Dial(addr, grpcclient.WithRetry(policy), grpcclient.WithTimeout(200*time.Millisecond))
+	testingutil.AssertNil(t, err, "Dial")
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := logger.ContextWithRequestID(context.Background(), "req-grpc")
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	testingutil.AssertNil(t, err, "Check retried")
+	testingutil.AssertEquals(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "serving")
+	testingutil.AssertEquals(t, int32(3), atomic.LoadInt32(&health.calls), "attempts")
+	testingutil.AssertEquals(t, "req-grpc", <-health.requestIDs, "request id propagated")
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "slow"})
+	testingutil.AssertEquals(t, codes.DeadlineExceeded, status.Code(err), "default timeout")
+
+	atomic.StoreInt32(&health.calls, 0)
+	atomic.StoreInt32(&health.failures, 10)
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	testingutil.AssertEquals(t, codes.Unavailable, status.Code(err), "status of the last attempt")
+	testingutil.AssertEquals(t, int32(5), atomic.LoadInt32(&health.calls), "max attempts")
+
+	counted := map[string]float64{}
+	for _, snapshot := range metrics.DefaultRegistry.Snapshots() {
+		if "grpcclient_requests_total" == snapshot.Name {
+			for _, s := range snapshot.Samples {
+				if "/grpc.health.v1.Health/Check" == s.LabelValues[0] {
+					counted[s.LabelValues[1]] = s.Value
+				}
+			}
+		}
+	}
+	testingutil.AssertTrue(t, counted["OK"] >= 1, "succeeded calls counted")
+	testingutil.AssertTrue(t, counted["Unavailable"] >= 1, "failed calls counted")
+}
+
+func TestGRPCClientTLSThroughProxy(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+	testingutil.AssertNil(t, err, "server credentials")
+	addr := serveFakeHealth(t, &fakeHealthServer{requestIDs: make(chan string, 10)}, grpc.Creds(creds))
+	proxyAddr, connects := serveConnectProxy(t)
+
+	_, err = grpcclient.Dial(addr, grpcclient.WithTLSOptions(&definations.TLSOptions{Enabled: true, CertFile: certFile}))
+	testingutil.AssertNotNil(t, err, "key file required")
+
+	conn, err := grpcclient.Dial(addr,
+		grpcclient.WithTLSOptions(&definations.TLSOptions{Enabled: true, CaFile: certFile}),
+		grpcclient.WithProxies(&definations.Proxies{HTTPS: "http://" + proxyAddr}))
+	testingutil.AssertNil(t, err, "Dial with tls and proxy")
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	testingutil.AssertNil(t, err, "Check over tls through proxy")
+	testingutil.AssertEquals(t, healthpb.HealthCheckResponse_SERVING, resp.Status, "serving")
+	testingutil.AssertEquals(t, addr, <-connects, "tunneled by CONNECT")
+}
+
+// serveConnectProxy http proxy tunneling the CONNECT requests, the tunneled addresses are sent to the channel
+func serveConnectProxy(t *testing.T) (string, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testingutil.AssertNil(t, err, "listen proxy")
+	t.Cleanup(func() { listener.Close() })
+	connects := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				req, err := http.ReadRequest(reader)
+				if err != nil || http.MethodConnect != req.Method {
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+					return
+				}
+				defer upstream.Close()
+				connects <- req.Host
+				conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+				wg := sync.WaitGroup{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					io.Copy(upstream, reader)
+					upstream.(*net.TCPConn).CloseWrite()
+				}()
+				io.Copy(conn, upstream)
+				wg.Wait()
+			}()
+		}
+	}()
+	return listener.Addr().String(), connects
+}
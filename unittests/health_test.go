@@ -0,0 +1,96 @@
+package unittests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/health"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/netutils/dboptions"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHealthAggregator(t *testing.T) {
+	a := health.NewAggregator()
+	release := make(chan struct{})
+	defer close(release)
+	a.Register("self", health.CheckerFunc(func(ctx context.Context) error { return nil }), health.WithLiveness())
+	a.Register("db", health.CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+	a.Register("slow", health.CheckerFunc(func(ctx context.Context) error {
+		<-release
+		return nil
+	}), health.WithTimeout(20*time.Millisecond))
+	a.Register("panic", health.CheckerFunc(func(ctx context.Context) error { panic("checker panic") }))
+	testingutil.AssertEquals(t, "[db panic self slow]", fmt.Sprint(a.Names()), "names")
+
+	live := a.Liveness(context.Background())
+	testingutil.AssertTrue(t, live.Healthy, "liveness healthy")
+	testingutil.AssertEquals(t, 1, len(live.Checks), "liveness checks")
+
+	ready := a.Readiness(context.Background())
+	testingutil.AssertFalse(t, ready.Healthy, "readiness unhealthy")
+	results := map[string]health.CheckResult{}
+	for _, result := range ready.Checks {
+		results[result.Name] = result
+	}
+	testingutil.AssertEquals(t, "connection refused", results["db"].Message, "failed check")
+	testingutil.AssertEquals(t, "check timeout after 20ms", results["slow"].Message, "timed out check")
+	testingutil.AssertEquals(t, "checker panic: checker panic", results["panic"].Message, "panic check")
+	testingutil.AssertTrue(t, results["self"].Healthy, "healthy check")
+
+	server := httptest.NewServer(a.ReadinessHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	testingutil.AssertNil(t, err, "get readiness")
+	defer resp.Body.Close()
+	testingutil.AssertEquals(t, http.StatusServiceUnavailable, resp.StatusCode, "unhealthy status code")
+	report := health.Report{}
+	testingutil.AssertNil(t, json.NewDecoder(resp.Body).Decode(&report), "decode report")
+	testingutil.AssertEquals(t, 4, len(report.Checks), "reported checks")
+
+	a.Unregister("db")
+	a.Unregister("slow")
+	a.Unregister("panic")
+	testingutil.AssertTrue(t, a.Readiness(context.Background()).Healthy, "healthy after unregistered")
+}
+
+func TestHealthComponentsRegistered(t *testing.T) {
+	opts := dboptions.NewDBConnectionPoolOptionsWithDSN("file://" + filepath.Join(t.TempDir(), "health.db"))
+	_, err := dboptions.InitDBPool("healthpool", opts)
+	testingutil.AssertNil(t, err, "InitDBPool")
+	testingutil.AssertTrue(t, containsString(health.DefaultAggregator.Names(), "db:healthpool"), "db pool registered")
+
+	broker := newFakeKafkaBroker(t, 1)
+	_, err = kafka.InitKafka("healthkafka", kafka.Config{Hosts: broker.addr()})
+	testingutil.AssertNil(t, err, "InitKafka")
+	testingutil.AssertTrue(t, containsString(health.DefaultAggregator.Names(), "kafka:healthkafka"), "kafka registered")
+
+	report := health.Readiness(context.Background())
+	for _, result := range report.Checks {
+		if "db:healthpool" == result.Name || "kafka:healthkafka" == result.Name {
+			testingutil.AssertTrue(t, result.Healthy, result.Name+" healthy "+result.Message)
+		}
+	}
+
+	testingutil.AssertNil(t, dboptions.CloseDBPool("healthpool"), "CloseDBPool")
+	testingutil.AssertNil(t, kafka.StopKafka("healthkafka"), "StopKafka")
+	names := health.DefaultAggregator.Names()
+	testingutil.AssertFalse(t, containsString(names, "db:healthpool"), "db pool unregistered")
+	testingutil.AssertFalse(t, containsString(names, "kafka:healthkafka"), "kafka unregistered")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package unittests
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+var digestParamRegex = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|([^,\s]*))`)
+
+func md5Hex(text string) string {
+	sum := md5.Sum([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyDigest verifies the digest authorization of qop auth as a device api does
+func verifyDigest(r *http.Request, username string, password string, realm string, nonce string) bool {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		return false
+	}
+	params := map[string]string{}
+	for _, m := range digestParamRegex.FindAllStringSubmatch(authorization[7:], -1) {
+		params[m[1]] = m[2] + m[3]
+	}
+	ha1 := md5Hex(username + ":" + realm + ":" + password)
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	expected := md5Hex(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2)
+	return username == params["username"] && nonce == params["nonce"] && "opaque-1" == params["opaque"] && r.URL.RequestURI() == params["uri"] && expected == params["response"]
+}
+
+func TestHTTPQueryBasicAuth(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/device").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || "admin" != user || "p:ss" != pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	body, err := httpclient.HTTPQuery("GET", server.URL+"/device", nil, httpclient.WithBasicAuth("admin", "p:ss"))
+	testingutil.AssertNil(t, err, "query with basic auth")
+	testingutil.AssertEquals(t, "ok", string(body), "authorized")
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/device", nil, httpclient.WithBasicAuth("admin", "wrong"))
+	testingutil.AssertError(t, err, "wrong password")
+}
+
+func TestHTTPQueryDigestAuth(t *testing.T) {
+	var challenges int32
+	server := testingutil.NewMockServer(t)
+	server.On("", "/ISAPI/*").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !verifyDigest(r, "admin", "secret", "device", "nonce-1") {
+			atomic.AddInt32(&challenges, 1)
+			w.Header().Add("WWW-Authenticate", `Basic realm="device"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="device", qop="auth,auth-int", nonce="nonce-1", opaque="opaque-1", algorithm=MD5`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s:%s", r.Method, body)
+	})
+	auth := httpclient.WithDigestAuth("admin", "secret")
+	body, err := httpclient.HTTPQuery("PUT", server.URL+"/ISAPI/System/time?format=json", strings.NewReader("payload"), auth)
+	testingutil.AssertNil(t, err, "query with digest auth")
+	testingutil.AssertEquals(t, "PUT:payload", string(body), "body sent again after challenged")
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&challenges), "challenged once")
+
+	body, err = httpclient.HTTPQuery("GET", server.URL+"/ISAPI/System/status", nil, auth)
+	testingutil.AssertNil(t, err, "query with known challenge")
+	testingutil.AssertEquals(t, "GET:", string(body), "authorized")
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&challenges), "challenge reused")
+	testingutil.AssertEquals(t, 3, len(server.Requests()), "no round trip for known challenge")
+
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/ISAPI/System/status", nil, httpclient.WithDigestAuth("admin", "wrong"))
+	testingutil.AssertError(t, err, "wrong password")
+}
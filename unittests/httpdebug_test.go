@@ -0,0 +1,53 @@
+package unittests
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHTTPDebugCurlAndHAR(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("POST", "/items").WithHeader("Content-Type", "application/json").Respond(http.StatusOK, `{"id":1}`)
+
+	commands := []string{}
+	recorder := httpclient.NewHARRecorder()
+	body, err := httpclient.HTTPQuery("POST", server.URL+"/items?tag=a", strings.NewReader(`{"name":"it's"}`),
+		httpclient.WithHTTPHeader("Content-Type", "application/json"),
+		httpclient.WithCurlDump(func(command string) { commands = append(commands, command) }),
+		httpclient.WithHARRecorder(recorder))
+	testingutil.AssertNil(t, err, "query with debug options")
+	testingutil.AssertEquals(t, `{"id":1}`, string(body), "response body kept")
+	testingutil.AssertEquals(t, 1, len(commands), "curl commands dumped")
+	testingutil.AssertEquals(t, `curl -X 'POST' '`+server.URL+`/items?tag=a' -H 'Content-Type: application/json' --data-binary '{"name":"it'\''s"}'`, commands[0], "curl command")
+
+	entries := recorder.Entries()
+	testingutil.AssertEquals(t, 1, len(entries), "recorded entries")
+	testingutil.AssertEquals(t, "POST", entries[0].Request.Method, "request method")
+	testingutil.AssertEquals(t, `{"name":"it's"}`, entries[0].Request.PostData.Text, "request body")
+	testingutil.AssertEquals(t, "tag", entries[0].Request.QueryString[0].Name, "query string")
+	testingutil.AssertEquals(t, 200, entries[0].Response.Status, "response status")
+	testingutil.AssertEquals(t, "OK", entries[0].Response.StatusText, "response status text")
+	testingutil.AssertEquals(t, `{"id":1}`, entries[0].Response.Content.Text, "response content")
+
+	_, err = httpclient.HTTPQuery("GET", "http://127.0.0.1:1/unreachable", nil, httpclient.WithHARRecorder(recorder))
+	testingutil.AssertNotNil(t, err, "unreachable")
+	entries = recorder.Entries()
+	testingutil.AssertEquals(t, 2, len(entries), "failed request recorded")
+	testingutil.AssertTrue(t, "" != entries[1].Comment, "error recorded")
+
+	filename := filepath.Join(t.TempDir(), "requests.har")
+	testingutil.AssertNil(t, recorder.WriteFile(filename), "WriteFile")
+	data, err := os.ReadFile(filename)
+	testingutil.AssertNil(t, err, "read har")
+	har := httpclient.HAR{}
+	testingutil.AssertNil(t, json.Unmarshal(data, &har), "decode har")
+	testingutil.AssertEquals(t, "1.2", har.Log.Version, "har version")
+	testingutil.AssertEquals(t, 2, len(har.Log.Entries), "har entries")
+}
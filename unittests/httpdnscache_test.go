@@ -0,0 +1,68 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHTTPDNSCache(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/dns").Respond(http.StatusOK, "resolved")
+	serverURL, _ := url.Parse(server.URL)
+
+	lookups := int32(0)
+	failing := int32(0)
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		atomic.AddInt32(&lookups, 1)
+		if 1 == atomic.LoadInt32(&failing) {
+			return nil, errors.New("resolver down")
+		}
+		if "svc.test" != host {
+			return nil, nil
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+
+	cache := httpclient.NewDNSCache(time.Hour, 0)
+	cache.SetLookupFunc(lookup)
+	queryURL := "http://svc.test:" + serverURL.Port() + "/dns"
+	for i := 0; i < 3; i++ {
+		body, err := httpclient.HTTPQuery("GET", queryURL, nil, httpclient.WithDNSCache(cache))
+		testingutil.AssertNil(t, err, "query by cached dns")
+		testingutil.AssertEquals(t, "resolved", string(body), "body")
+	}
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&lookups), "looked up once")
+	_, err := cache.LookupHost(context.Background(), "unknown.test")
+	testingutil.AssertErrorIs(t, err, httpclient.ErrNoAddresses, "no addresses")
+
+	atomic.StoreInt32(&lookups, 0)
+	stale := httpclient.NewDNSCache(time.Millisecond, time.Hour)
+	stale.SetLookupFunc(lookup)
+	_, err = stale.LookupHost(context.Background(), "svc.test")
+	testingutil.AssertNil(t, err, "first lookup")
+	atomic.StoreInt32(&failing, 1)
+	time.Sleep(5 * time.Millisecond)
+	addrs, err := stale.LookupHost(context.Background(), "svc.test")
+	testingutil.AssertNil(t, err, "stale served while resolver down")
+	testingutil.AssertEquals(t, "127.0.0.1", strings.Join(addrs, ","), "stale addresses")
+	testingutil.AssertEventually(t, func() bool { return atomic.LoadInt32(&lookups) == 2 }, time.Second, time.Millisecond, "refreshed in background")
+
+	expired := httpclient.NewDNSCache(time.Millisecond, time.Millisecond)
+	atomic.StoreInt32(&failing, 0)
+	expired.SetLookupFunc(lookup)
+	_, err = expired.LookupHost(context.Background(), "svc.test")
+	testingutil.AssertNil(t, err, "first lookup")
+	atomic.StoreInt32(&failing, 1)
+	time.Sleep(5 * time.Millisecond)
+	_, err = expired.LookupHost(context.Background(), "svc.test")
+	testingutil.AssertNotNil(t, err, "failed after max stale")
+}
@@ -0,0 +1,49 @@
+package unittests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHTTPQueryMaxResponseBytes(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/small").Respond(http.StatusOK, "tiny")
+	server.On("GET", "/sized").Respond(http.StatusOK, strings.Repeat("x", 4096))
+	server.On("GET", "/chunked").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 64; i++ {
+			w.Write([]byte(strings.Repeat("y", 1024)))
+			w.(http.Flusher).Flush()
+		}
+	})
+	server.On("GET", "/list").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[1,2,3"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat(",4", 1024) + "]"))
+	})
+	limit := httpclient.WithMaxResponseBytes(1024)
+
+	body, err := httpclient.HTTPQuery("GET", server.URL+"/small", nil, limit)
+	testingutil.AssertNil(t, err, "within limit")
+	testingutil.AssertEquals(t, "tiny", string(body), "small body")
+
+	var tooLarge *httpclient.ResponseTooLargeError
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/sized", nil, limit)
+	testingutil.AssertTrue(t, errors.As(err, &tooLarge), "rejected by content length")
+	testingutil.AssertEquals(t, int64(1024), tooLarge.Limit, "limit of error")
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/chunked", nil, limit)
+	testingutil.AssertTrue(t, errors.As(err, &tooLarge), "aborted while reading")
+
+	count := 0
+	err = httpclient.HTTPGetJSONStream(server.URL+"/list", nil, func(element json.RawMessage) error {
+		count++
+		return nil
+	}, limit)
+	testingutil.AssertTrue(t, errors.As(err, &tooLarge), "stream aborted")
+	testingutil.AssertTrue(t, count >= 3 && count < 1027, "elements before aborted")
+}
@@ -2,13 +2,22 @@ package unittests
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/libpub/golib/definations"
 	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/httpclient/openapi/examples/petstore"
 	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/timeutil"
 )
 
 func TestHTTPQueryWithRetry(t *testing.T) {
@@ -19,6 +28,162 @@ func TestHTTPQueryWithRetry(t *testing.T) {
 	testingutil.AssertEquals(t, 0, len(resp), "httpclient.HTTPQuery response")
 }
 
+func TestHTTPQueryRetriesScheduled(t *testing.T) {
+	// drops the retries of former tests scheduled with the default backoff
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	backoff := httpclient.RetryBackoff
+	httpclient.RetryBackoff = utils.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+	defer func() {
+		httpclient.RetryBackoff = backoff
+	}()
+
+	_, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithRetry(2))
+	testingutil.AssertNotNil(t, err, "failed query error")
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&hits) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	testingutil.AssertEquals(t, int32(3), atomic.LoadInt32(&hits), "query and retries")
+	for httpclient.PendingRetries() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	testingutil.AssertEquals(t, 0, httpclient.PendingRetries(), "finished retries removed")
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries error")
+}
+
+func TestHTTPQueryRetryHooks(t *testing.T) {
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	backoff := httpclient.RetryBackoff
+	httpclient.RetryBackoff = utils.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+	defer func() {
+		httpclient.RetryBackoff = backoff
+	}()
+	defer httpclient.ClearHooks()
+
+	scheduled := make(chan httpclient.RetryEvent, 10)
+	exhausted := make(chan httpclient.RetryEvent, 10)
+	finished := make(chan httpclient.RequestEvent, 10)
+	httpclient.OnRetryScheduled(func(event httpclient.RetryEvent) { scheduled <- event })
+	httpclient.OnRetryExhausted(func(event httpclient.RetryEvent) { exhausted <- event })
+	httpclient.OnRequestFinished(func(event httpclient.RequestEvent) {
+		if server.URL == event.URL {
+			finished <- event
+		}
+	})
+
+	_, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithRetry(2))
+	testingutil.AssertNotNil(t, err, "failed query error")
+	for i := 0; i < 2; i++ {
+		event := <-scheduled
+		testingutil.AssertEquals(t, i, event.Retries, "retries of scheduled event")
+		testingutil.AssertEquals(t, 2, event.MaxRetries, "max retries")
+		testingutil.AssertEquals(t, http.StatusServiceUnavailable, event.StatusCode, "status of scheduled event")
+		testingutil.AssertTrue(t, event.Delay > 0, "backoff delay")
+	}
+	event := <-exhausted
+	testingutil.AssertEquals(t, 2, event.Retries, "retries of exhausted event")
+	testingutil.AssertNotNil(t, event.Err, "error of exhausted event")
+	for i := 0; i < 3; i++ {
+		request := <-finished
+		testingutil.AssertEquals(t, i, request.Retries, "retries of finished request")
+		testingutil.AssertEquals(t, http.StatusServiceUnavailable, request.StatusCode, "status of finished request")
+	}
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries error")
+}
+
+func TestHTTPQueryIdempotencyKey(t *testing.T) {
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	keys := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys <- r.Header.Get(httpclient.HeaderIdempotencyKey)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	backoff := httpclient.RetryBackoff
+	httpclient.RetryBackoff = utils.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+	defer func() {
+		httpclient.RetryBackoff = backoff
+	}()
+
+	_, err := httpclient.HTTPQuery("POST", server.URL, bytes.NewReader([]byte("order")), httpclient.WithRetry(2))
+	testingutil.AssertNotNil(t, err, "failed post")
+	testingutil.AssertEquals(t, "", <-keys, "no key by default")
+	testingutil.AssertEquals(t, 0, httpclient.PendingRetries(), "post without key not retried")
+
+	_, err = httpclient.HTTPQuery("POST", server.URL, bytes.NewReader([]byte("order")), httpclient.WithRetry(2), httpclient.WithIdempotencyKey(""))
+	testingutil.AssertNotNil(t, err, "failed post with key")
+	key := <-keys
+	testingutil.AssertTrue(t, "" != key, "generated key")
+	testingutil.AssertEquals(t, key, <-keys, "key of the first retry")
+	testingutil.AssertEquals(t, key, <-keys, "key of the second retry")
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries error")
+}
+
+func TestHTTPQueryWithRoundTripper(t *testing.T) {
+	requested := []string{}
+	rt := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.Method+" "+req.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"id":7,"name":"kitty"}`)),
+			Request:    req,
+		}, nil
+	})
+	body, err := httpclient.HTTPQuery("GET", "http://pets.invalid/pets/7", nil, httpclient.WithRoundTripper(rt))
+	testingutil.AssertNil(t, err, "query by round tripper")
+	testingutil.AssertEquals(t, `{"id":7,"name":"kitty"}`, string(body), "faked response")
+
+	client := petstore.NewClient("http://pets.invalid", httpclient.WithRoundTripper(rt))
+	pet, err := client.GetPet(7)
+	testingutil.AssertNil(t, err, "client with round tripper")
+	testingutil.AssertEquals(t, "kitty", pet.Name, "pet name")
+	testingutil.AssertEquals(t, "GET http://pets.invalid/pets/7,GET http://pets.invalid/pets/7", strings.Join(requested, ","), "requests sent by round tripper")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("by transport"))
+	}))
+	defer server.Close()
+	body, err = httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithTransport(&http.Transport{}))
+	testingutil.AssertNil(t, err, "query by transport")
+	testingutil.AssertEquals(t, "by transport", string(body), "response by transport")
+}
+
+func TestHTTPQueryJSONEmptyResponses(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/no-content").Respond(http.StatusNoContent, "")
+	server.On("POST", "/no-content").Respond(http.StatusNoContent, "")
+	server.On("POST", "/blank").Respond(http.StatusOK, " \n")
+
+	result, err := httpclient.HTTPGetJSON(server.URL+"/no-content", nil)
+	testingutil.AssertNil(t, err, "get 204")
+	testingutil.AssertTrue(t, nil == result, "nil result of 204")
+	result, err = httpclient.HTTPPostJSON(server.URL+"/no-content", map[string]interface{}{"name": "empty"})
+	testingutil.AssertNil(t, err, "post 204")
+	testingutil.AssertTrue(t, nil == result, "nil result of post 204")
+	typed := struct{ Name string }{Name: "unchanged"}
+	err = httpclient.HTTPPostJSONEx(server.URL+"/blank", map[string]interface{}{}, &typed)
+	testingutil.AssertNil(t, err, "post blank body")
+	testingutil.AssertEquals(t, "unchanged", typed.Name, "result unchanged by blank body")
+
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/no-content", nil)
+	testingutil.AssertNotNil(t, err, "204 failed without allowed")
+	body, err := httpclient.HTTPQuery("GET", server.URL+"/no-content", nil, httpclient.WithAllowEmptyResponse())
+	testingutil.AssertNil(t, err, "204 allowed")
+	testingutil.AssertEquals(t, 0, len(body), "empty body")
+}
+
 func TestHTTPQueryKubernetesAPI(t *testing.T) {
 	url := "https://127.0.0.1:6443"
 	api := "/api/v1/namespaces/dev/pods/a113-0.0.8-68f9fddff-gp9lb-noexists"
@@ -46,3 +211,62 @@ func TestHTTPQueryKubernetesAPI(t *testing.T) {
 		fmt.Printf("api:%s response:%+v", api, string(resp))
 	}
 }
+
+func TestHTTPQueryRetryFakeClock(t *testing.T) {
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	clock := timeutil.NewFakeClock(time.Now())
+	retryClock := httpclient.RetryClock
+	httpclient.RetryClock = clock
+	defer func() {
+		httpclient.StopRetries(context.Background())
+		httpclient.RetryClock = retryClock
+	}()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// the default backoff of seconds is waited by advancing the fake clock
+	_, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithRetry(1))
+	testingutil.AssertNotNil(t, err, "failed query error")
+	testingutil.AssertEquals(t, 1, httpclient.PendingRetries(), "pending retry")
+	time.Sleep(20 * time.Millisecond)
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&hits), "retry waiting for backoff")
+	testingutil.AssertEventually(t, func() bool {
+		clock.Advance(httpclient.RetryBackoff.InitialInterval)
+		return atomic.LoadInt32(&hits) == 2
+	}, 2*time.Second, 5*time.Millisecond, "retried by fake clock")
+	testingutil.AssertEventually(t, func() bool {
+		return httpclient.PendingRetries() == 0
+	}, time.Second, 5*time.Millisecond, "finished retry removed")
+}
+
+func TestHTTPQueryRetryKeepsSuccessStatus(t *testing.T) {
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	backoff := httpclient.RetryBackoff
+	httpclient.RetryBackoff = utils.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+	defer func() {
+		httpclient.RetryBackoff = backoff
+	}()
+
+	_, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithAllowEmptyResponse(), httpclient.WithRetry(3))
+	testingutil.AssertNotNil(t, err, "failed query error")
+	testingutil.AssertEventually(t, func() bool {
+		return atomic.LoadInt32(&hits) == 2 && httpclient.PendingRetries() == 0
+	}, 2*time.Second, 5*time.Millisecond, "retried once")
+	// the 204 of the retry succeeds with the allowed status, so that it is not retried again
+	time.Sleep(50 * time.Millisecond)
+	testingutil.AssertEquals(t, int32(2), atomic.LoadInt32(&hits), "no more retries after 204")
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries error")
+}
@@ -0,0 +1,174 @@
+package unittests
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/httpserver"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHTTPServerMiddlewares(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteJSON(w, http.StatusOK, map[string]string{"requestId": logger.RequestIDFromContext(r.Context())})
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("handler panic")
+	})
+	mux.HandleFunc("/conflict", func(w http.ResponseWriter, r *http.Request) {
+		httpserver.WriteError(w, 0, errors.NewError(errors.Conflict, "version mismatched"))
+	})
+	middlewares := append(httpserver.DefaultMiddlewares(), httpserver.CORS(httpserver.CORSOptions{AllowOrigins: []string{"https://app.example.com"}, MaxAge: 60}), httpserver.Gzip())
+	server := httptest.NewServer(httpserver.Chain(mux, middlewares...))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/hello", nil)
+	req.Header.Set(logger.HeaderRequestID, "req-42")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	testingutil.AssertNil(t, err, "get hello")
+	defer resp.Body.Close()
+	testingutil.AssertEquals(t, "req-42", resp.Header.Get(logger.HeaderRequestID), "request id header")
+	testingutil.AssertEquals(t, "gzip", resp.Header.Get("Content-Encoding"), "content encoding")
+	reader, err := gzip.NewReader(resp.Body)
+	testingutil.AssertNil(t, err, "gzip reader")
+	body, _ := io.ReadAll(reader)
+	testingutil.AssertEquals(t, `{"requestId":"req-42"}`, string(body), "body carrying request id")
+
+	resp, err = http.Get(server.URL + "/panic")
+	testingutil.AssertNil(t, err, "get panic")
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	testingutil.AssertEquals(t, http.StatusInternalServerError, resp.StatusCode, "recovered status")
+	testingutil.AssertEquals(t, `{"code":500,"message":"internal server error"}`, string(body), "recovered body")
+	testingutil.AssertTrue(t, "" != resp.Header.Get(logger.HeaderRequestID), "generated request id")
+
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/conflict", nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Conflict), "conflict responded by category")
+
+	req, _ = http.NewRequest("OPTIONS", server.URL+"/hello", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "X-Token")
+	resp, err = http.DefaultClient.Do(req)
+	testingutil.AssertNil(t, err, "preflight")
+	resp.Body.Close()
+	testingutil.AssertEquals(t, http.StatusNoContent, resp.StatusCode, "preflight status")
+	testingutil.AssertEquals(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"), "allowed origin")
+	testingutil.AssertEquals(t, "X-Token", resp.Header.Get("Access-Control-Allow-Headers"), "allowed headers")
+	testingutil.AssertEquals(t, "60", resp.Header.Get("Access-Control-Max-Age"), "max age")
+
+	req, _ = http.NewRequest("GET", server.URL+"/hello", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp, err = http.DefaultClient.Do(req)
+	testingutil.AssertNil(t, err, "disallowed origin")
+	resp.Body.Close()
+	testingutil.AssertEquals(t, "", resp.Header.Get("Access-Control-Allow-Origin"), "disallowed origin header")
+}
+
+func TestHTTPServerGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+	server, err := httpserver.NewServer("127.0.0.1:0", handler, httpserver.WithShutdownTimeout(5*time.Second))
+	testingutil.AssertNil(t, err, "NewServer")
+	testingutil.AssertNil(t, server.Listen(), "Listen")
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.Run(ctx)
+	}()
+
+	responded := make(chan string, 1)
+	go func() {
+		body, err := httpclient.HTTPQuery("GET", "http://"+server.ListenAddr().String()+"/", nil)
+		if nil != err {
+			responded <- err.Error()
+			return
+		}
+		responded <- string(body)
+	}()
+	<-started
+	cancel()
+	select {
+	case err := <-stopped:
+		t.Fatalf("server stopped before the in-flight request finished:%v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	testingutil.AssertEquals(t, "done", <-responded, "in-flight request finished")
+	testingutil.AssertNil(t, <-stopped, "Run returned after shutdown")
+	_, err = net.Dial("tcp", server.ListenAddr().String())
+	testingutil.AssertNotNil(t, err, "listener closed")
+}
+
+func TestHTTPServerTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+	_, err := httpserver.NewServer("127.0.0.1:0", nil, httpserver.WithTLSOptions(&definations.TLSOptions{Enabled: true, CertFile: certFile}))
+	testingutil.AssertNotNil(t, err, "key file required")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Scheme + r.Proto))
+	})
+	server, err := httpserver.NewServer("127.0.0.1:0", handler, httpserver.WithTLSOptions(&definations.TLSOptions{Enabled: true, CertFile: certFile, KeyFile: keyFile}))
+	testingutil.AssertNil(t, err, "NewServer with tls")
+	testingutil.AssertNil(t, server.Listen(), "Listen")
+	go server.ListenAndServe()
+	defer server.Shutdown()
+
+	body, err := httpclient.HTTPQuery("GET", "https://"+server.ListenAddr().String()+"/", nil,
+		httpclient.WithHTTPTLSOptions(&definations.TLSOptions{Enabled: true, CaFile: certFile}))
+	testingutil.AssertNil(t, err, "query over tls")
+	testingutil.AssertTrue(t, strings.HasPrefix(string(body), "HTTP/"), "served over tls")
+}
+
+// writeTestCertificate writes a self-signed certificate of 127.0.0.1 and its key
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testingutil.AssertNil(t, err, "generate key")
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	testingutil.AssertNil(t, err, "create certificate")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	testingutil.AssertNil(t, err, "marshal key")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	testingutil.AssertNil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600), "write certificate")
+	testingutil.AssertNil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600), "write key")
+	return certFile, keyFile
+}
@@ -0,0 +1,112 @@
+package unittests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestHTTPGetJSONStream(t *testing.T) {
+	release := make(chan struct{})
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/items").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":0},`))
+		w.(http.Flusher).Flush()
+		// the rest is written after the first element consumed by the client
+		<-release
+		for i := 1; i < 1000; i++ {
+			fmt.Fprintf(w, `{"id":%d,"name":"item-%d"}`, i, i)
+			if i < 999 {
+				w.Write([]byte(","))
+			}
+		}
+		w.Write([]byte("]"))
+	})
+	server.On("GET", "/object").RespondJSON(http.StatusOK, map[string]int{"id": 1})
+	server.On("GET", "/missing").Respond(http.StatusNotFound, "no such list")
+
+	count, sum := 0, 0
+	err := httpclient.HTTPGetJSONStream(server.URL+"/items", &map[string]string{"size": "1000"}, func(element json.RawMessage) error {
+		var item struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(element, &item); nil != err {
+			return err
+		}
+		if 0 == count {
+			close(release)
+		}
+		count++
+		sum += item.ID
+		return nil
+	})
+	testingutil.AssertNil(t, err, "HTTPGetJSONStream")
+	testingutil.AssertEquals(t, 1000, count, "elements")
+	testingutil.AssertEquals(t, 999*1000/2, sum, "sum of ids")
+	testingutil.AssertEquals(t, "1000", server.LastRequest("GET", "/items").Query.Get("size"), "params")
+
+	count = 0
+	err = httpclient.HTTPGetJSONStream(server.URL+"/items", nil, func(element json.RawMessage) error {
+		count++
+		if 3 == count {
+			return httpclient.ErrStopStream
+		}
+		return nil
+	})
+	testingutil.AssertNil(t, err, "stopped stream")
+	testingutil.AssertEquals(t, 3, count, "elements before stopped")
+
+	err = httpclient.HTTPGetJSONStream(server.URL+"/object", nil, func(element json.RawMessage) error { return nil })
+	testingutil.AssertError(t, err, "not an array")
+	err = httpclient.HTTPGetJSONStream(server.URL+"/missing", nil, func(element json.RawMessage) error { return nil })
+	testingutil.AssertEquals(t, http.StatusNotFound, errors.HTTPStatus(err), "failed status")
+	testingutil.AssertEquals(t, "no such list", string(errors.ResponseBody(err)), "failed body")
+}
+
+func TestHTTPStreamLines(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/events").HandleFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"type\":\"start\",\"id\":1}\n\n{\"type\":\"die\",\"id\":2}\r\n"))
+		w.(http.Flusher).Flush()
+		// follows until the client gives up
+		<-r.Context().Done()
+	})
+	server.On("GET", "/logs").Respond(http.StatusOK, "line 1\n\nline 3")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := httpclient.HTTPStreamNDJSON(ctx, "GET", server.URL+"/events", nil)
+	testingutil.AssertNil(t, err, "HTTPStreamNDJSON")
+	defer it.Close()
+	types := []string{}
+	for it.Next() {
+		var event struct {
+			Type string `json:"type"`
+			ID   int    `json:"id"`
+		}
+		testingutil.AssertNil(t, it.Decode(&event), "decode event")
+		types = append(types, event.Type)
+		if 2 == event.ID {
+			cancel()
+		}
+	}
+	testingutil.AssertDeepEquals(t, []string{"start", "die"}, types, "events arrived")
+	testingutil.AssertErrorIs(t, it.Err(), context.Canceled, "interrupted by context")
+	testingutil.AssertEquals(t, "application/x-ndjson", server.LastRequest("GET", "/events").Header.Get("Accept"), "accept header")
+
+	it, err = httpclient.HTTPStreamLines(context.Background(), "GET", server.URL+"/logs", nil)
+	testingutil.AssertNil(t, err, "HTTPStreamLines")
+	lines := []string{}
+	for it.Next() {
+		lines = append(lines, it.Text())
+	}
+	testingutil.AssertNil(t, it.Err(), "ended normally")
+	testingutil.AssertDeepEquals(t, []string{"line 1", "", "line 3"}, lines, "lines")
+}
@@ -0,0 +1,94 @@
+package unittests
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+)
+
+func TestSnowflake(t *testing.T) {
+	_, err := utils.NewSnowflake(utils.SnowflakeMaxNode + 1)
+	testingutil.AssertNotNil(t, err, "NewSnowflake node out of range")
+	s, err := utils.NewSnowflake(5)
+	testingutil.AssertNil(t, err, "NewSnowflake error")
+
+	ids := make([]int64, 10000)
+	for i := range ids {
+		ids[i] = s.Generate()
+	}
+	for i := 1; i < len(ids); i++ {
+		testingutil.AssertTrue(t, ids[i] > ids[i-1], "Snowflake ids increasing")
+	}
+	parsed, err := utils.ParseSnowflake(ids[0])
+	testingutil.AssertNil(t, err, "ParseSnowflake error")
+	testingutil.AssertEquals(t, int64(5), parsed.Node, "ParseSnowflake node")
+	testingutil.AssertTrue(t, time.Since(parsed.Time) < time.Minute, "ParseSnowflake time")
+
+	parsed, err = utils.ParseSnowflakeString(utils.GenSnowflakeIDString())
+	testingutil.AssertNil(t, err, "ParseSnowflakeString error")
+	testingutil.AssertTrue(t, parsed.Node <= utils.SnowflakeMaxNode, "default node")
+	testingutil.AssertEquals(t, utils.ErrSnowflakeInUse, utils.SetSnowflakeNode((parsed.Node+1)&utils.SnowflakeMaxNode), "SetSnowflakeNode after generating")
+	testingutil.AssertNil(t, utils.SetSnowflakeNode(parsed.Node), "SetSnowflakeNode of the same node")
+	_, err = utils.ParseSnowflakeString("abc")
+	testingutil.AssertNotNil(t, err, "ParseSnowflakeString invalid")
+}
+
+func TestULID(t *testing.T) {
+	var mutex sync.Mutex
+	ids := []string{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				id := utils.GenULID()
+				mutex.Lock()
+				ids = append(ids, id)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	unique := map[string]bool{}
+	for _, id := range ids {
+		testingutil.AssertEquals(t, utils.ULIDLength, len(id), "ULID length")
+		testingutil.AssertTrue(t, utils.IsULID(id), "IsULID "+id)
+		unique[id] = true
+	}
+	testingutil.AssertEquals(t, len(ids), len(unique), "ULID unique")
+
+	first := utils.GenULID()
+	second := utils.GenULID()
+	testingutil.AssertTrue(t, sort.StringsAreSorted([]string{first, second}), "ULID sortable")
+	ts, err := utils.ParseULID(first)
+	testingutil.AssertNil(t, err, "ParseULID error")
+	testingutil.AssertTrue(t, time.Since(ts) < time.Minute, "ParseULID time")
+
+	ts, err = utils.ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	testingutil.AssertNil(t, err, "ParseULID spec error")
+	testingutil.AssertEquals(t, int64(1469922850259), ts.UnixMilli(), "ParseULID spec time")
+	testingutil.AssertFalse(t, utils.IsULID("8ZZZZZZZZZZZZZZZZZZZZZZZZZ"), "ULID overflow")
+	testingutil.AssertFalse(t, utils.IsULID("01ARZ3NDEKTSV4RRFFQ69G5FAU"), "ULID invalid character")
+	testingutil.AssertFalse(t, utils.IsULID("01ARZ3"), "ULID invalid length")
+}
+
+func TestNanoID(t *testing.T) {
+	id := utils.GenNanoID()
+	testingutil.AssertEquals(t, utils.NanoIDLength, len(id), "GenNanoID length")
+	testingutil.AssertTrue(t, utils.IsNanoID(id), "IsNanoID "+id)
+	testingutil.AssertTrue(t, id != utils.GenNanoID(), "GenNanoID random")
+
+	hex, err := utils.GenNanoIDWith("0123456789abcdef", 32)
+	testingutil.AssertNil(t, err, "GenNanoIDWith error")
+	testingutil.AssertTrue(t, utils.IsNanoIDWith(hex, "0123456789abcdef", 32), "IsNanoIDWith "+hex)
+	testingutil.AssertFalse(t, utils.IsNanoIDWith("xyz", "0123456789abcdef", 3), "IsNanoIDWith invalid")
+	_, err = utils.GenNanoIDWith("a", 10)
+	testingutil.AssertNotNil(t, err, "GenNanoIDWith invalid alphabet")
+	_, err = utils.GenNanoIDWith("ab", 0)
+	testingutil.AssertNotNil(t, err, "GenNanoIDWith invalid size")
+}
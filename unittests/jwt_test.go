@@ -0,0 +1,100 @@
+package unittests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/cryptoes"
+)
+
+func TestJWTSignAndVerify(t *testing.T) {
+	rsaKey, err := cryptoes.GenerateRSAKey(2048)
+	testingutil.AssertNil(t, err, "cryptoes.GenerateRSAKey error")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testingutil.AssertNil(t, err, "ecdsa.GenerateKey error")
+	now := time.Unix(1700000000, 0)
+	opts := cryptoes.JWTValidateOptions{Issuer: "golib", Audience: "api", Now: func() time.Time { return now }}
+	for _, key := range []*cryptoes.JWTKey{
+		cryptoes.NewHMACJWTKey("hs", []byte("secret")),
+		cryptoes.NewRSAJWTKey("rs", rsaKey),
+		cryptoes.NewECDSAJWTKey("es", ecKey),
+	} {
+		keys := cryptoes.NewJWTKeySet(key)
+		claims := &cryptoes.JWTClaims{
+			Issuer:    "golib",
+			Subject:   "user1",
+			Audience:  cryptoes.JWTAudience{"api"},
+			ExpiresAt: now.Add(time.Minute).Unix(),
+			IssuedAt:  now.Unix(),
+			Extra:     map[string]interface{}{"role": "admin"},
+		}
+		token, err := cryptoes.JWTSign(claims, keys)
+		testingutil.AssertNil(t, err, key.Algorithm+" cryptoes.JWTSign error")
+		verified, err := cryptoes.JWTVerify(token, keys, opts)
+		testingutil.AssertNil(t, err, key.Algorithm+" cryptoes.JWTVerify error")
+		testingutil.AssertEquals(t, "user1", verified.Subject, key.Algorithm+" cryptoes.JWTVerify subject")
+		testingutil.AssertEquals(t, "admin", verified.Extra["role"], key.Algorithm+" cryptoes.JWTVerify extra claim")
+
+		_, err = cryptoes.JWTVerify(token[:len(token)-2]+"AA", keys, opts)
+		testingutil.AssertEquals(t, cryptoes.ErrJWTSignatureInvalid, err, key.Algorithm+" cryptoes.JWTVerify tampered")
+	}
+}
+
+func TestJWTValidateClaims(t *testing.T) {
+	keys := cryptoes.NewJWTKeySet(cryptoes.NewHMACJWTKey("k1", []byte("secret")))
+	now := time.Unix(1700000000, 0)
+	token, err := cryptoes.JWTSign(&cryptoes.JWTClaims{Issuer: "golib", Audience: cryptoes.JWTAudience{"api", "web"}, ExpiresAt: now.Unix(), NotBefore: now.Unix()}, keys)
+	testingutil.AssertNil(t, err, "cryptoes.JWTSign error")
+	at := func(tm time.Time, leeway time.Duration) cryptoes.JWTValidateOptions {
+		return cryptoes.JWTValidateOptions{Leeway: leeway, Now: func() time.Time { return tm }}
+	}
+
+	_, err = cryptoes.JWTVerify(token, keys, at(now, 0))
+	testingutil.AssertEquals(t, cryptoes.ErrJWTExpired, err, "expired at exp")
+	_, err = cryptoes.JWTVerify(token, keys, at(now.Add(time.Second), 5*time.Second))
+	testingutil.AssertNil(t, err, "expired within leeway")
+	_, err = cryptoes.JWTVerify(token, keys, at(now.Add(-2*time.Second), 0))
+	testingutil.AssertEquals(t, cryptoes.ErrJWTNotValidYet, err, "not valid before nbf")
+	_, err = cryptoes.JWTVerify(token, keys, at(now.Add(-2*time.Second), 5*time.Second))
+	testingutil.AssertNil(t, err, "nbf within leeway")
+
+	opts := at(now.Add(-time.Second), 5*time.Second)
+	opts.Audience = "web"
+	_, err = cryptoes.JWTVerify(token, keys, opts)
+	testingutil.AssertNil(t, err, "audience web")
+	opts.Audience = "admin"
+	_, err = cryptoes.JWTVerify(token, keys, opts)
+	testingutil.AssertEquals(t, cryptoes.ErrJWTInvalidAudience, err, "audience admin")
+	opts.Audience = ""
+	opts.Issuer = "other"
+	_, err = cryptoes.JWTVerify(token, keys, opts)
+	testingutil.AssertEquals(t, cryptoes.ErrJWTInvalidIssuer, err, "issuer other")
+}
+
+func TestJWTKeyRotation(t *testing.T) {
+	keys := cryptoes.NewJWTKeySet(cryptoes.NewHMACJWTKey("k1", []byte("secret1")))
+	oldToken, err := cryptoes.JWTSign(&cryptoes.JWTClaims{Subject: "user1"}, keys)
+	testingutil.AssertNil(t, err, "cryptoes.JWTSign with k1 error")
+	keys.AddKey(cryptoes.NewHMACJWTKey("k2", []byte("secret2")), true)
+	newToken, err := cryptoes.JWTSign(&cryptoes.JWTClaims{Subject: "user1"}, keys)
+	testingutil.AssertNil(t, err, "cryptoes.JWTSign with k2 error")
+
+	_, err = cryptoes.JWTVerify(oldToken, keys, cryptoes.JWTValidateOptions{})
+	testingutil.AssertNil(t, err, "verify the token of rotated key")
+	keys.RemoveKey("k1")
+	_, err = cryptoes.JWTVerify(oldToken, keys, cryptoes.JWTValidateOptions{})
+	testingutil.AssertEquals(t, cryptoes.ErrJWTKeyNotFound, err, "verify the token of removed key")
+	_, err = cryptoes.JWTVerify(newToken, keys, cryptoes.JWTValidateOptions{})
+	testingutil.AssertNil(t, err, "verify the token of current key")
+
+	// the token claiming other algorithm than the key is rejected
+	rsaKey, _ := cryptoes.GenerateRSAKey(2048)
+	rsKeys := cryptoes.NewJWTKeySet(cryptoes.NewRSAJWTKey("k2", rsaKey))
+	rsToken, _ := cryptoes.JWTSign(&cryptoes.JWTClaims{Subject: "user1"}, rsKeys)
+	_, err = cryptoes.JWTVerify(rsToken, keys, cryptoes.JWTValidateOptions{})
+	testingutil.AssertEquals(t, cryptoes.ErrJWTUnsupportedAlg, err, "verify the token of other algorithm")
+}
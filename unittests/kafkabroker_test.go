@@ -0,0 +1,624 @@
+package unittests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	k "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/apiversions"
+	"github.com/segmentio/kafka-go/protocol/describegroups"
+	"github.com/segmentio/kafka-go/protocol/fetch"
+	"github.com/segmentio/kafka-go/protocol/findcoordinator"
+	"github.com/segmentio/kafka-go/protocol/heartbeat"
+	"github.com/segmentio/kafka-go/protocol/joingroup"
+	"github.com/segmentio/kafka-go/protocol/leavegroup"
+	"github.com/segmentio/kafka-go/protocol/listoffsets"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/offsetcommit"
+	"github.com/segmentio/kafka-go/protocol/offsetfetch"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"github.com/segmentio/kafka-go/protocol/syncgroup"
+)
+
+// 模拟broker 的错误码和等待时间
+const (
+	fakeKafkaUnknownTopic      = 3
+	fakeKafkaUnknownMemberID   = 25
+	fakeKafkaFetchMaxWait      = 100 * time.Millisecond // fetch 请求最长等待时间，避免关闭reader 时长时间阻塞
+	fakeKafkaFetchMaxRecords   = 100
+	fakeKafkaFetchResponseVers = 10
+)
+
+// fakeKafkaRecord 模拟broker 保存的一条消息.
+type fakeKafkaRecord struct {
+	Key         []byte
+	Value       []byte
+	Headers     []protocol.Header
+	Time        time.Time
+	Compression k.Compression // 生产时record batch 的压缩方式
+}
+
+// fakeKafkaMember 消费者组的一个成员.
+type fakeKafkaMember struct {
+	clientID   string
+	protocol   string
+	metadata   []byte
+	assignment []byte
+}
+
+// fakeKafkaBroker 基于kafka-go 的protocol 包模拟单节点broker，支持生产、消费、消费者组和管理接口用到的请求.
+// 为了不等待心跳触发的再平衡，每个加入消费者组的成员都单独作为一代的leader 分配订阅topic 的全部分区，
+// 所以同一个消费者组里的成员应订阅不同的topic.
+type fakeKafkaBroker struct {
+	t          *testing.T
+	listener   net.Listener
+	partitions int
+	mu         sync.Mutex
+	logs       map[string][][]fakeKafkaRecord
+	missing    map[string]bool
+	leaderless map[string]bool  // topic/partition 没有leader
+	committed  map[string]int64 // group/topic/partition 已提交的偏移量
+	produceErr map[string]int16 // topic 生产消息时返回的错误码
+	groups     map[string]map[string]*fakeKafkaMember
+	generation int32
+	fetches    map[string]int
+	describes  [][]string
+	conns      map[net.Conn]bool
+	changed    chan struct{}
+	closed     chan struct{}
+}
+
+// newFakeKafkaBroker 启动模拟broker，自动创建的topic 有partitions 个分区，测试结束时关闭.
+func newFakeKafkaBroker(t *testing.T, partitions int) *fakeKafkaBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fake kafka broker failed with error:%v", err)
+	}
+	b := &fakeKafkaBroker{
+		t:          t,
+		listener:   listener,
+		partitions: partitions,
+		logs:       map[string][][]fakeKafkaRecord{},
+		missing:    map[string]bool{},
+		leaderless: map[string]bool{},
+		committed:  map[string]int64{},
+		produceErr: map[string]int16{},
+		groups:     map[string]map[string]*fakeKafkaMember{},
+		fetches:    map[string]int{},
+		conns:      map[net.Conn]bool{},
+		changed:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+	go b.serve()
+	t.Cleanup(b.close)
+	return b
+}
+
+func (b *fakeKafkaBroker) addr() string {
+	return b.listener.Addr().String()
+}
+
+func (b *fakeKafkaBroker) close() {
+	b.listener.Close()
+	b.mu.Lock()
+	select {
+	case <-b.closed:
+	default:
+		close(b.closed)
+	}
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+}
+
+func (b *fakeKafkaBroker) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns[conn] = true
+		b.mu.Unlock()
+		go b.serveConn(conn)
+	}
+}
+
+// serveConn 按顺序处理一个连接上的请求.
+func (b *fakeKafkaBroker) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		b.mu.Lock()
+		delete(b.conns, conn)
+		b.mu.Unlock()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		apiVersion, correlationID, clientID, msg, err := protocol.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		if req, ok := msg.(*fetch.Request); ok {
+			err = b.writeFetch(conn, apiVersion, correlationID, req)
+		} else if res := b.handle(clientID, msg); nil != res {
+			err = protocol.WriteResponse(conn, apiVersion, correlationID, res)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle 返回请求的响应，不需要响应时返回nil.
+func (b *fakeKafkaBroker) handle(clientID string, msg protocol.Message) protocol.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch req := msg.(type) {
+	case *apiversions.Request:
+		return b.apiVersions()
+	case *metadata.Request:
+		return b.metadata(req)
+	case *produce.Request:
+		res := b.produce(req)
+		if !req.HasResponse() {
+			return nil
+		}
+		return res
+	case *listoffsets.Request:
+		return b.listOffsets(req)
+	case *findcoordinator.Request:
+		host, port := b.hostPort()
+		return &findcoordinator.Response{NodeID: 1, Host: host, Port: port}
+	case *joingroup.Request:
+		return b.joinGroup(clientID, req)
+	case *syncgroup.Request:
+		member := b.groups[req.GroupID][req.MemberID]
+		if nil == member {
+			return &syncgroup.Response{ErrorCode: fakeKafkaUnknownMemberID}
+		}
+		for _, assignment := range req.Assignments {
+			if assignment.MemberID == req.MemberID {
+				member.assignment = assignment.Assignment
+			}
+		}
+		return &syncgroup.Response{ProtocolType: "consumer", ProtocolName: member.protocol, Assignments: member.assignment}
+	case *heartbeat.Request:
+		if nil == b.groups[req.GroupID][req.MemberID] {
+			return &heartbeat.Response{ErrorCode: fakeKafkaUnknownMemberID}
+		}
+		return &heartbeat.Response{}
+	case *leavegroup.Request:
+		delete(b.groups[req.GroupID], req.MemberID)
+		res := &leavegroup.Response{}
+		for _, member := range req.Members {
+			delete(b.groups[req.GroupID], member.MemberID)
+			res.Members = append(res.Members, leavegroup.ResponseMember{MemberID: member.MemberID})
+		}
+		return res
+	case *offsetfetch.Request:
+		res := &offsetfetch.Response{}
+		for _, topic := range req.Topics {
+			rt := offsetfetch.ResponseTopic{Name: topic.Name}
+			for _, partition := range topic.PartitionIndexes {
+				offset, ok := b.committed[committedKey(req.GroupID, topic.Name, int(partition))]
+				if !ok {
+					offset = -1
+				}
+				rt.Partitions = append(rt.Partitions, offsetfetch.ResponsePartition{PartitionIndex: partition, CommittedOffset: offset})
+			}
+			res.Topics = append(res.Topics, rt)
+		}
+		return res
+	case *offsetcommit.Request:
+		res := &offsetcommit.Response{}
+		for _, topic := range req.Topics {
+			rt := offsetcommit.ResponseTopic{Name: topic.Name}
+			for _, partition := range topic.Partitions {
+				b.committed[committedKey(req.GroupID, topic.Name, int(partition.PartitionIndex))] = partition.CommittedOffset
+				rt.Partitions = append(rt.Partitions, offsetcommit.ResponsePartition{PartitionIndex: partition.PartitionIndex})
+			}
+			res.Topics = append(res.Topics, rt)
+		}
+		return res
+	case *describegroups.Request:
+		return b.describeGroups(req)
+	}
+	b.t.Errorf("fake kafka broker received unexpected request %T", msg)
+	return nil
+}
+
+func (b *fakeKafkaBroker) apiVersions() *apiversions.Response {
+	keys := []protocol.ApiKey{
+		protocol.ApiVersions, protocol.Metadata, protocol.Produce, protocol.Fetch, protocol.ListOffsets,
+		protocol.FindCoordinator, protocol.JoinGroup, protocol.SyncGroup, protocol.Heartbeat,
+		protocol.LeaveGroup, protocol.OffsetFetch, protocol.OffsetCommit, protocol.DescribeGroups,
+	}
+	res := &apiversions.Response{}
+	for _, key := range keys {
+		maxVersion := key.MaxVersion()
+		if key == protocol.Fetch {
+			// fetch 响应手动编码，只支持v10
+			maxVersion = fakeKafkaFetchResponseVers
+		}
+		res.ApiKeys = append(res.ApiKeys, apiversions.ApiKeyResponse{ApiKey: int16(key), MinVersion: key.MinVersion(), MaxVersion: maxVersion})
+	}
+	return res
+}
+
+func (b *fakeKafkaBroker) hostPort() (string, int32) {
+	addr := b.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), int32(addr.Port)
+}
+
+// log 返回topic 的分区，不存在时自动创建，调用方需持有mu.
+func (b *fakeKafkaBroker) log(topic string) [][]fakeKafkaRecord {
+	partitions, ok := b.logs[topic]
+	if !ok {
+		partitions = make([][]fakeKafkaRecord, b.partitions)
+		b.logs[topic] = partitions
+	}
+	return partitions
+}
+
+func (b *fakeKafkaBroker) metadata(req *metadata.Request) *metadata.Response {
+	host, port := b.hostPort()
+	res := &metadata.Response{
+		Brokers:      []metadata.ResponseBroker{{NodeID: 1, Host: host, Port: port}},
+		ControllerID: 1,
+	}
+	topics := req.TopicNames
+	if len(topics) == 0 {
+		for topic := range b.logs {
+			topics = append(topics, topic)
+		}
+	}
+	for _, topic := range topics {
+		if b.missing[topic] {
+			res.Topics = append(res.Topics, metadata.ResponseTopic{Name: topic, ErrorCode: fakeKafkaUnknownTopic})
+			continue
+		}
+		rt := metadata.ResponseTopic{Name: topic}
+		for i := range b.log(topic) {
+			leader := int32(1)
+			if b.leaderless[topic+"/"+strconv.Itoa(i)] {
+				leader = -1
+			}
+			rt.Partitions = append(rt.Partitions, metadata.ResponsePartition{
+				PartitionIndex: int32(i),
+				LeaderID:       leader,
+				ReplicaNodes:   []int32{1},
+				IsrNodes:       []int32{1},
+			})
+		}
+		res.Topics = append(res.Topics, rt)
+	}
+	return res
+}
+
+func (b *fakeKafkaBroker) produce(req *produce.Request) *produce.Response {
+	res := &produce.Response{}
+	for _, topic := range req.Topics {
+		rt := produce.ResponseTopic{Topic: topic.Topic}
+		partitions := b.log(topic.Topic)
+		for _, partition := range topic.Partitions {
+			rp := produce.ResponsePartition{Partition: partition.Partition, BaseOffset: int64(len(partitions[partition.Partition]))}
+			if code := b.produceErr[topic.Topic]; code != 0 {
+				rp.ErrorCode = code
+				rt.Partitions = append(rt.Partitions, rp)
+				continue
+			}
+			for {
+				record, err := partition.RecordSet.Records.ReadRecord()
+				if err != nil {
+					break
+				}
+				partitions[partition.Partition] = append(partitions[partition.Partition], fakeKafkaRecord{
+					Key:         readRecordBytes(record.Key),
+					Value:       readRecordBytes(record.Value),
+					Headers:     record.Headers,
+					Time:        record.Time,
+					Compression: partition.RecordSet.Attributes.Compression(),
+				})
+			}
+			rt.Partitions = append(rt.Partitions, rp)
+		}
+		res.Topics = append(res.Topics, rt)
+	}
+	b.notifyChanged()
+	return res
+}
+
+func readRecordBytes(data protocol.Bytes) []byte {
+	if nil == data {
+		return nil
+	}
+	value, _ := io.ReadAll(data)
+	return value
+}
+
+// notifyChanged 唤醒等待新消息的fetch 请求，调用方需持有mu.
+func (b *fakeKafkaBroker) notifyChanged() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+func (b *fakeKafkaBroker) listOffsets(req *listoffsets.Request) *listoffsets.Response {
+	res := &listoffsets.Response{}
+	for _, topic := range req.Topics {
+		rt := listoffsets.ResponseTopic{Topic: topic.Topic}
+		partitions := b.log(topic.Topic)
+		for _, partition := range topic.Partitions {
+			records := partitions[partition.Partition]
+			offset := int64(len(records))
+			switch partition.Timestamp {
+			case -2:
+				offset = 0
+			case -1:
+			default:
+				for i, record := range records {
+					if record.Time.UnixMilli() >= partition.Timestamp {
+						offset = int64(i)
+						break
+					}
+				}
+			}
+			rt.Partitions = append(rt.Partitions, listoffsets.ResponsePartition{Partition: partition.Partition, Timestamp: -1, Offset: offset, LeaderEpoch: -1})
+		}
+		res.Topics = append(res.Topics, rt)
+	}
+	return res
+}
+
+// joinGroup 成员总是成为新一代的leader，只看到自己.
+func (b *fakeKafkaBroker) joinGroup(clientID string, req *joingroup.Request) *joingroup.Response {
+	members, ok := b.groups[req.GroupID]
+	if !ok {
+		members = map[string]*fakeKafkaMember{}
+		b.groups[req.GroupID] = members
+	}
+	memberID := req.MemberID
+	if memberID == "" {
+		memberID = fmt.Sprintf("%s-%d", clientID, len(members)+int(b.generation)+1)
+	}
+	member := &fakeKafkaMember{clientID: clientID}
+	if len(req.Protocols) > 0 {
+		member.protocol = req.Protocols[0].Name
+		member.metadata = req.Protocols[0].Metadata
+	}
+	members[memberID] = member
+	b.generation++
+	return &joingroup.Response{
+		GenerationID: b.generation,
+		ProtocolName: member.protocol,
+		LeaderID:     memberID,
+		MemberID:     memberID,
+		Members:      []joingroup.ResponseMember{{MemberID: memberID, Metadata: member.metadata}},
+	}
+}
+
+func (b *fakeKafkaBroker) describeGroups(req *describegroups.Request) *describegroups.Response {
+	b.describes = append(b.describes, req.Groups)
+	res := &describegroups.Response{}
+	for _, groupID := range req.Groups {
+		group := describegroups.ResponseGroup{GroupID: groupID, GroupState: "Dead"}
+		if members := b.groups[groupID]; len(members) > 0 {
+			group.GroupState = "Stable"
+			group.ProtocolType = "consumer"
+			for memberID, member := range members {
+				group.ProtocolData = member.protocol
+				group.Members = append(group.Members, describegroups.ResponseGroupMember{
+					MemberID:         memberID,
+					ClientID:         member.clientID,
+					ClientHost:       "/127.0.0.1",
+					MemberMetadata:   member.metadata,
+					MemberAssignment: member.assignment,
+				})
+			}
+		}
+		res.Groups = append(res.Groups, group)
+	}
+	return res
+}
+
+// writeFetch 等待请求的分区有新消息或超时后返回fetch v10 响应.
+// kafka-go 编码的record batch 起始偏移量总是0，所以手动编码响应并修改batch 的起始偏移量.
+func (b *fakeKafkaBroker) writeFetch(w io.Writer, apiVersion int16, correlationID int32, req *fetch.Request) error {
+	if apiVersion != fakeKafkaFetchResponseVers || len(req.Topics) != 1 || len(req.Topics[0].Partitions) != 1 {
+		return fmt.Errorf("unsupported fetch request v%d", apiVersion)
+	}
+	topic := req.Topics[0].Topic
+	partition := req.Topics[0].Partitions[0].Partition
+	offset := req.Topics[0].Partitions[0].FetchOffset
+	wait := time.Duration(req.MaxWaitTime) * time.Millisecond
+	if wait > fakeKafkaFetchMaxWait {
+		wait = fakeKafkaFetchMaxWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	b.mu.Lock()
+	b.fetches[topic]++
+	b.mu.Unlock()
+	var records []fakeKafkaRecord
+	var highWatermark int64
+	for {
+		b.mu.Lock()
+		partitions := b.log(topic)
+		highWatermark = int64(len(partitions[partition]))
+		if offset < highWatermark {
+			records = partitions[partition][offset:]
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		if len(records) > 0 {
+			break
+		}
+		select {
+		case <-changed:
+			continue
+		case <-timer.C:
+		case <-b.closed:
+		}
+		break
+	}
+	if len(records) > fakeKafkaFetchMaxRecords {
+		records = records[:fakeKafkaFetchMaxRecords]
+	}
+
+	recordSet := []byte{0, 0, 0, 0}
+	if len(records) > 0 {
+		rs := make([]protocol.Record, len(records))
+		for i, record := range records {
+			rs[i] = protocol.Record{
+				Time:    record.Time,
+				Key:     protocol.NewBytes(record.Key),
+				Value:   protocol.NewBytes(record.Value),
+				Headers: record.Headers,
+			}
+		}
+		buffer := &bytes.Buffer{}
+		set := protocol.RecordSet{Version: 2, Records: protocol.NewRecordReader(rs...)}
+		if _, err := set.WriteTo(buffer); err != nil {
+			return err
+		}
+		recordSet = buffer.Bytes()
+		// 长度前缀之后是batch 的起始偏移量，不在crc 校验范围内
+		binary.BigEndian.PutUint64(recordSet[4:12], uint64(offset))
+	}
+
+	body := &bytes.Buffer{}
+	writeInt := func(v interface{}) { binary.Write(body, binary.BigEndian, v) }
+	writeInt(correlationID)
+	writeInt(int32(0)) // throttle time
+	writeInt(int16(0)) // error code
+	writeInt(int32(0)) // session id
+	writeInt(int32(1))
+	writeInt(int16(len(topic)))
+	body.WriteString(topic)
+	writeInt(int32(1))
+	writeInt(partition)
+	writeInt(int16(0))
+	writeInt(highWatermark) // high watermark
+	writeInt(highWatermark) // last stable offset
+	writeInt(int64(0))      // log start offset
+	writeInt(int32(0))      // aborted transactions
+	body.Write(recordSet)
+
+	frame := make([]byte, 4, 4+body.Len())
+	binary.BigEndian.PutUint32(frame, uint32(body.Len()))
+	_, err := w.Write(append(frame, body.Bytes()...))
+	return err
+}
+
+func committedKey(groupID string, topic string, partition int) string {
+	return groupID + "/" + topic + "/" + strconv.Itoa(partition)
+}
+
+// createTopics 创建topic，writer 只从缓存的元数据中查找topic，需要在发送之前创建.
+func (b *fakeKafkaBroker) createTopics(topics ...string) {
+	b.mu.Lock()
+	for _, topic := range topics {
+		b.log(topic)
+	}
+	b.mu.Unlock()
+}
+
+// setMissing 设置topic 不存在，元数据返回UnknownTopicOrPartition.
+func (b *fakeKafkaBroker) setMissing(topic string) {
+	b.mu.Lock()
+	b.missing[topic] = true
+	b.mu.Unlock()
+}
+
+// setLeaderless 设置分区没有leader.
+func (b *fakeKafkaBroker) setLeaderless(topic string, partition int) {
+	b.mu.Lock()
+	b.leaderless[topic+"/"+strconv.Itoa(partition)] = true
+	b.mu.Unlock()
+}
+
+// setProduceError 设置topic 生产消息时返回的错误码，0 表示成功.
+func (b *fakeKafkaBroker) setProduceError(topic string, code k.Error) {
+	b.mu.Lock()
+	b.produceErr[topic] = int16(code)
+	b.mu.Unlock()
+}
+
+// setCommitted 设置消费者组在分区上已提交的偏移量.
+func (b *fakeKafkaBroker) setCommitted(groupID string, topic string, partition int, offset int64) {
+	b.mu.Lock()
+	b.committed[committedKey(groupID, topic, partition)] = offset
+	b.mu.Unlock()
+}
+
+// append 向topic 的分区追加消息.
+func (b *fakeKafkaBroker) append(topic string, partition int, records ...fakeKafkaRecord) {
+	b.mu.Lock()
+	partitions := b.log(topic)
+	for _, record := range records {
+		if record.Time.IsZero() {
+			record.Time = time.Now()
+		}
+		partitions[partition] = append(partitions[partition], record)
+	}
+	b.notifyChanged()
+	b.mu.Unlock()
+}
+
+// records 返回topic 所有分区的消息.
+func (b *fakeKafkaBroker) records(topic string) []fakeKafkaRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	results := []fakeKafkaRecord{}
+	for _, records := range b.logs[topic] {
+		results = append(results, records...)
+	}
+	return results
+}
+
+// partitionRecords 返回topic 一个分区的消息.
+func (b *fakeKafkaBroker) partitionRecords(topic string, partition int) []fakeKafkaRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeKafkaRecord{}, b.log(topic)[partition]...)
+}
+
+// fetched 返回broker 收到的topic 的fetch 请求数量.
+func (b *fakeKafkaBroker) fetched(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fetches[topic]
+}
+
+// describedGroups 返回每次describe groups 请求的消费者组.
+func (b *fakeKafkaBroker) describedGroups() [][]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([][]string{}, b.describes...)
+}
+
+// waitFor 在timeout 内轮询直到cond 成立，超时时测试失败.
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool, valueName string) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Errorf("%s not satisfied in %v", valueName, timeout)
+			return false
+		}
+		<-ticker.C
+	}
+	return true
+}
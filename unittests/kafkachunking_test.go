@@ -0,0 +1,87 @@
+package unittests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaMessageChunking(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 3)
+	broker.createTopics("replies")
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+	p.ConfigMaxMessageBytes(10)
+
+	large := strings.Repeat("0123456789", 3) + "tail"
+	testingutil.AssertNil(t, p.Send("replies", []byte(large)), "send large")
+	testingutil.AssertNil(t, p.Send("replies", []byte("small")), "send small")
+	waitFor(t, 10*time.Second, func() bool { return len(broker.records("replies")) == 5 }, "chunk records")
+	chunkPartitions := 0
+	for partition := 0; partition < 3; partition++ {
+		chunks := 0
+		for _, record := range broker.partitionRecords("replies", partition) {
+			for _, h := range record.Headers {
+				if kafka.HeaderChunkIndex == h.Key {
+					testingutil.AssertEquals(t, chunks, int(h.Value[0]-'0'), "chunk index in order")
+					chunks++
+				}
+			}
+		}
+		if chunks > 0 {
+			testingutil.AssertEquals(t, 4, chunks, "chunks in one partition")
+			chunkPartitions++
+		}
+	}
+	testingutil.AssertEquals(t, 1, chunkPartitions, "chunk partitions")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := p.SendMessageContext(ctx, "replies", kafka.PartitionMessage(2, []byte(large)))
+	testingutil.AssertNil(t, err, "send large synchronously")
+	records := broker.partitionRecords("replies", 2)
+	testingutil.AssertEquals(t, int64(len(records)-1), result.Offset, "offset of last chunk")
+
+	// 消费者组的reader 交替读取多个分区，各分区的分片都能组装
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	received := &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("replies", received.add), "receive from partitions")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 3 }, "reassembled messages from partitions")
+	testingutil.AssertEquals(t, large+","+large+",small", strings.Join(received.sorted(), ","), "reassembled values from partitions")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close from partitions")
+
+	// 单分区的topic 上交替发送的分片和普通消息按顺序组装
+	single := newFakeKafkaBroker(t, 1)
+	single.createTopics("replies")
+	sp := kafka.NewProducer(single.addr(), 0)
+	defer sp.Close()
+	sp.ConfigMaxMessageBytes(10)
+	testingutil.AssertNil(t, sp.Send("replies", []byte(large)), "send large to single partition")
+	testingutil.AssertNil(t, sp.Send("replies", []byte("small")), "send small to single partition")
+	_, err = sp.SendContext(ctx, "replies", []byte(large+"!"))
+	testingutil.AssertNil(t, err, "send large synchronously to single partition")
+
+	c = kafka.NewConsumer(single.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	received = &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("replies", received.add), "receive")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 3 }, "reassembled messages")
+	testingutil.AssertEquals(t, large+","+large+"!,small", strings.Join(received.sorted(), ","), "reassembled values")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close")
+
+	// 使用外部偏移量存储时每个分区一个reader，多分区的分片也能组装
+	c = kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	store := kafka.NewMemoryOffsetStore()
+	c.ConfigOffsetStore(store)
+	received = &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("replies", received.add), "receive with offset store")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 3 }, "reassembled messages with offset store")
+	testingutil.AssertEquals(t, large+","+large+",small", strings.Join(received.sorted(), ","), "reassembled values with offset store")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close with offset store")
+}
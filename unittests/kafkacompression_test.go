@@ -0,0 +1,40 @@
+package unittests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+func TestKafkaProducerCompression(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	codecs := map[string]k.Compression{
+		"none":   0,
+		"gzip":   k.Gzip,
+		"snappy": k.Snappy,
+		"LZ4":    k.Lz4,
+		"zstd":   k.Zstd,
+	}
+	for codec, compression := range codecs {
+		topic := "orders-" + codec
+		broker.createTopics(topic)
+		p := kafka.NewProducer(broker.addr(), 0)
+		testingutil.AssertNil(t, p.ConfigCompression(codec), "config compression "+codec)
+		testingutil.AssertNil(t, p.Send(topic, []byte(`{"order":"`+codec+`"}`)), "send "+codec)
+		testingutil.AssertEquals(t, compression, p.Writer[topic].Compression, "writer compression "+codec)
+		if waitFor(t, 10*time.Second, func() bool { return len(broker.records(topic)) == 1 }, "records of "+codec) {
+			record := broker.records(topic)[0]
+			testingutil.AssertEquals(t, `{"order":"`+codec+`"}`, string(record.Value), "decompressed value "+codec)
+			testingutil.AssertEquals(t, compression, record.Compression, "record batch compression "+codec)
+		}
+		p.Close()
+	}
+
+	p := kafka.NewProducer(broker.addr(), 0)
+	testingutil.AssertNotNil(t, p.ConfigCompression("brotli"), "unknown compression")
+	_, err := kafka.InitKafka("compression-invalid", kafka.Config{Hosts: broker.addr(), GroupID: "group", Compression: "brotli"})
+	testingutil.AssertNotNil(t, err, "init with unknown compression")
+}
@@ -0,0 +1,165 @@
+package unittests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+// receiveReaderConfig 订阅topic 后返回reader 的配置和起始偏移量，并停止消费.
+func receiveReaderConfig(t *testing.T, c *kafka.Consumer, topic string) (k.ReaderConfig, int64, error) {
+	if err := c.Receive(topic, func([]byte) {}); err != nil {
+		return k.ReaderConfig{}, 0, err
+	}
+	reader := c.Readers[topic]
+	config, offset := reader.Config(), reader.Offset()
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close "+topic)
+	return config, offset, nil
+}
+
+func TestKafkaReaderConfigOffsetModes(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 3)
+	now := time.Now()
+	broker.append("orders", 2,
+		fakeKafkaRecord{Value: []byte("old"), Time: now.Add(-2 * time.Hour)},
+		fakeKafkaRecord{Value: []byte("new"), Time: now.Add(-30 * time.Minute)},
+	)
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigPartition(2)
+
+	config, _, err := receiveReaderConfig(t, c, "orders")
+	testingutil.AssertNil(t, err, "latest receive error")
+	testingutil.AssertEquals(t, "group", config.GroupID, "latest group id")
+	testingutil.AssertEquals(t, k.LastOffset, config.StartOffset, "latest start offset")
+	testingutil.AssertEquals(t, time.Second, config.CommitInterval, "latest commit interval")
+
+	c.ConfigOffsetMode("EARLIEST")
+	config, _, err = receiveReaderConfig(t, c, "orders")
+	testingutil.AssertNil(t, err, "earliest receive error")
+	testingutil.AssertEquals(t, "group", config.GroupID, "earliest group id")
+	testingutil.AssertEquals(t, k.FirstOffset, config.StartOffset, "earliest start offset")
+
+	c.ConfigStartOffset(100)
+	config, offset, err := receiveReaderConfig(t, c, "orders")
+	testingutil.AssertNil(t, err, "explicit receive error")
+	testingutil.AssertEquals(t, "", config.GroupID, "explicit group id")
+	testingutil.AssertEquals(t, 2, config.Partition, "explicit partition")
+	testingutil.AssertEquals(t, time.Duration(0), config.CommitInterval, "explicit commit interval")
+	testingutil.AssertEquals(t, int64(100), offset, "explicit offset")
+
+	c.ConfigStartTime(now.Add(-time.Hour))
+	config, offset, err = receiveReaderConfig(t, c, "orders")
+	testingutil.AssertNil(t, err, "timestamp receive error")
+	testingutil.AssertEquals(t, "", config.GroupID, "timestamp group id")
+	testingutil.AssertEquals(t, 2, config.Partition, "timestamp partition")
+	testingutil.AssertEquals(t, int64(1), offset, "first offset after start time")
+
+	c.ConfigOffsetMode("unknown")
+	testingutil.AssertNotNil(t, c.Receive("orders", func([]byte) {}), "unknown mode error")
+	testingutil.AssertEquals(t, 0, len(c.Readers), "reader of unknown mode")
+
+	c.ConfigGroupID("")
+	c.ConfigOffsetMode(kafka.OffsetModeLatest)
+	config, _, err = receiveReaderConfig(t, c, "orders")
+	testingutil.AssertNil(t, err, "receive without group error")
+	testingutil.AssertTrue(t, strings.HasPrefix(config.GroupID, "orders-"), "generated group id")
+}
+
+func TestKafkaReaderConfigPrivateTopicIgnoresOffsetMode(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "replies", "group")
+	defer worker.Close(5 * time.Second)
+	worker.Consumer.ConfigPartition(1)
+	worker.Consumer.ConfigStartOffset(100)
+
+	testingutil.AssertNil(t, worker.Subscribe("replies", &mqenv.MQConsumerProxy{Queue: "replies"}), "subscribe private topic")
+	config := worker.Consumer.Readers["replies"].Config()
+	testingutil.AssertEquals(t, "group", config.GroupID, "private topic group id")
+	testingutil.AssertEquals(t, k.LastOffset, config.StartOffset, "private topic start offset")
+
+	testingutil.AssertNil(t, worker.Subscribe("orders", &mqenv.MQConsumerProxy{Queue: "orders"}), "subscribe topic")
+	reader := worker.Consumer.Readers["orders"]
+	testingutil.AssertEquals(t, "", reader.Config().GroupID, "topic group id")
+	testingutil.AssertEquals(t, 1, reader.Config().Partition, "topic partition")
+	testingutil.AssertEquals(t, int64(100), reader.Offset(), "topic offset")
+}
+
+func TestKafkaSubscribeReceiveError(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "", "group")
+	defer worker.Close(5 * time.Second)
+	worker.Consumer.ConfigOffsetMode("unknown")
+	proxy := &mqenv.MQConsumerProxy{Queue: "orders", ConsumerTag: "tag"}
+	testingutil.AssertNotNil(t, worker.Subscribe("orders", proxy), "subscribe error")
+
+	// 失败的订阅没有登记，修正配置后重新订阅会创建reader
+	worker.Consumer.ConfigOffsetMode(kafka.OffsetModeLatest)
+	testingutil.AssertNil(t, worker.Subscribe("orders", proxy), "subscribe again")
+	testingutil.AssertTrue(t, nil != worker.Consumer.Readers["orders"], "reader created after failed subscribe")
+}
+
+func TestKafkaCloseKeepsProducerWhileCallbacksProcessing(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("replies")
+	data, err := proto.Marshal(&kafka.KafkaPacket{SendTo: "orders", Body: []byte("order")})
+	testingutil.AssertNil(t, err, "marshal packet")
+	broker.append("orders", 0, fakeKafkaRecord{Value: data})
+
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "", "group")
+	worker.Consumer.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	testingutil.AssertNil(t, worker.Subscribe("orders", &mqenv.MQConsumerProxy{
+		Queue: "orders",
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			close(started)
+			<-release
+			return nil
+		},
+	}), "subscribe")
+	testingutil.AssertNil(t, worker.Producer.Send("replies", []byte("reply")), "send reply")
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("callback not started")
+	}
+
+	// 回调仍在处理时关闭超时，保留生产者
+	testingutil.AssertNotNil(t, worker.Close(10*time.Millisecond), "close timeout error")
+	testingutil.AssertEquals(t, "replies", worker.Stats().Producer.Topic, "producer kept open after timeout")
+
+	close(release)
+	testingutil.AssertNil(t, worker.Close(5*time.Second), "close error")
+	testingutil.AssertEquals(t, "", worker.Stats().Producer.Topic, "producer closed")
+	testingutil.AssertEquals(t, 1, len(broker.records("replies")), "buffered messages sent on close")
+}
+
+func TestKafkaConsumerOffsetsPerPartition(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	// 两个分区的偏移量交错，分区1 的偏移量不大于分区0 已处理的偏移量
+	broker.append("orders", 0,
+		fakeKafkaRecord{Value: []byte("a0")},
+		fakeKafkaRecord{Value: []byte("a1")},
+		fakeKafkaRecord{Value: []byte("a2")},
+	)
+	broker.append("orders", 1,
+		fakeKafkaRecord{Value: []byte("b0")},
+		fakeKafkaRecord{Value: []byte("b1")},
+	)
+
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	c.ConfigConcurrency(2)
+	c.ConfigPartitionOrdering(true)
+	received := &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("orders", received.add), "receive")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 5 }, "received messages")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close")
+	testingutil.AssertEquals(t, "a0,a1,a2,b0,b1", strings.Join(received.sorted(), ","), "messages of both partitions")
+}
@@ -0,0 +1,171 @@
+package unittests
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaConsumerPartitionOrdering(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	for seq := 0; seq < 30; seq++ {
+		for partition := 0; partition < 3; partition++ {
+			broker.append("orders", 0, fakeKafkaRecord{Value: []byte(fmt.Sprintf("%d:%d", partition, seq))})
+		}
+	}
+	for _, workers := range []int{1, 4} {
+		var mu sync.Mutex
+		received := map[int][]int{}
+		total := 0
+		c := kafka.NewConsumer(broker.addr(), fmt.Sprintf("group-%d", workers))
+		c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+		c.ConfigConcurrency(workers)
+		c.ConfigPartitionOrdering(true)
+		err := c.Receive("orders", func(value []byte) {
+			fields := strings.Split(string(value), ":")
+			partition, _ := strconv.Atoi(fields[0])
+			seq, _ := strconv.Atoi(fields[1])
+			// 不同消息处理耗时不同，乱序只可能由分发导致
+			for i := 0; i < seq%3*100; i++ {
+				runtime.Gosched()
+			}
+			mu.Lock()
+			received[partition] = append(received[partition], seq)
+			total++
+			mu.Unlock()
+		})
+		testingutil.AssertNil(t, err, "receive error")
+		waitFor(t, 10*time.Second, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return total == 90
+		}, "received messages")
+		testingutil.AssertNil(t, c.Close(5*time.Second), "close consumer")
+		for partition := 0; partition < 3; partition++ {
+			seqs := received[partition]
+			testingutil.AssertEquals(t, 30, len(seqs), fmt.Sprintf("workers %d partition %d messages", workers, partition))
+			for i, seq := range seqs {
+				testingutil.AssertEquals(t, i, seq, fmt.Sprintf("workers %d partition %d message order", workers, partition))
+			}
+		}
+	}
+}
+
+func TestKafkaConsumerBackpressure(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	for i := 1; i <= 3; i++ {
+		broker.append("orders", 0, fakeKafkaRecord{Value: []byte(strconv.Itoa(i))})
+	}
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	c.ConfigConcurrency(2)
+	c.ConfigMaxInFlight(2)
+	testingutil.AssertNil(t, c.Receive("orders", func(value []byte) {
+		started <- string(value)
+		<-release
+	}), "receive error")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("messages not dispatched")
+		}
+	}
+	// 在途消息达到上限时不再分发
+	select {
+	case value := <-started:
+		t.Fatalf("message %s dispatched while max in flight reached", value)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case value := <-started:
+		testingutil.AssertEquals(t, "3", value, "message dispatched after released")
+	case <-time.After(10 * time.Second):
+		t.Fatalf("dispatch not released after messages processed")
+	}
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close consumer")
+}
+
+func TestKafkaConsumerPanicRecovered(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.append("orders", 0, fakeKafkaRecord{Value: []byte("panic")}, fakeKafkaRecord{Value: []byte("ok")}, fakeKafkaRecord{Value: []byte("ok")})
+	var processed int32
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	c.ConfigConcurrency(2)
+	testingutil.AssertNil(t, c.Receive("orders", func(value []byte) {
+		atomic.AddInt32(&processed, 1)
+		if string(value) == "panic" {
+			panic("callback panic")
+		}
+	}), "receive error")
+	waitFor(t, 10*time.Second, func() bool { return atomic.LoadInt32(&processed) == 3 }, "processed messages after panic")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close consumer")
+}
+
+// TestKafkaConsumerConcurrentReplies 多个处理协程同时回复并订阅新的处理函数，需要使用-race 运行.
+func TestKafkaConsumerConcurrentReplies(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("replies-0", "replies-1", "replies-2")
+	for i := 0; i < 40; i++ {
+		data, err := proto.Marshal(&kafka.KafkaPacket{
+			SendTo:        "requests",
+			ReplyTo:       fmt.Sprintf("replies-%d", i%3),
+			CorrelationId: strconv.Itoa(i),
+			RoutingKey:    fmt.Sprintf("method-%d", i%25),
+			Body:          []byte(strconv.Itoa(i)),
+		})
+		testingutil.AssertNil(t, err, "marshal packet")
+		broker.append("requests", 0, fakeKafkaRecord{Value: data})
+	}
+
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "", "group")
+	defer worker.Close(5 * time.Second)
+	worker.Consumer.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	worker.Consumer.ConfigConcurrency(4)
+	var handled int32
+	callback := func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		atomic.AddInt32(&handled, 1)
+		return &mqenv.MQPublishMessage{Body: msg.Body}
+	}
+	testingutil.AssertNil(t, worker.Subscribe("requests", &mqenv.MQConsumerProxy{Queue: "requests", Callback: callback}), "subscribe")
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			worker.Subscribe("requests", &mqenv.MQConsumerProxy{Queue: "requests", ConsumerTag: fmt.Sprintf("method-%d", i), Callback: callback})
+		}
+	}()
+	replies := func() int {
+		n := 0
+		for i := 0; i < 3; i++ {
+			for _, record := range broker.records(fmt.Sprintf("replies-%d", i)) {
+				if !strings.Contains(string(record.Value), "_register_private") {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	waitFor(t, 20*time.Second, func() bool { return replies() == 40 }, "replies")
+	wg.Wait()
+
+	testingutil.AssertEquals(t, int32(40), atomic.LoadInt32(&handled), "handled messages")
+	testingutil.AssertEquals(t, "replies-0,replies-1,replies-2", worker.Stats().Producer.Topic, "reply writers")
+}
@@ -0,0 +1,68 @@
+package unittests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+// healthChecksString 把检查结果格式化为name=healthy 便于比较.
+func healthChecksString(status *mqenv.MQHealthStatus) string {
+	items := []string{}
+	for _, check := range status.Checks {
+		if check.Healthy {
+			items = append(items, check.Name+"=ok")
+		} else {
+			items = append(items, check.Name+"="+check.Message)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+func TestKafkaHealthCheck(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	broker.createTopics("audits", "refunds")
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "replies", "group")
+	defer worker.Close(5 * time.Second)
+	subscribeKafkaTopic(t, broker, worker, &mqenv.MQConsumerProxy{Queue: "orders"})
+	subscribeKafkaTopic(t, broker, worker, &mqenv.MQConsumerProxy{Queue: "payments"})
+	subscribeKafkaTopic(t, broker, worker, &mqenv.MQConsumerProxy{Queue: "replies"})
+	testingutil.AssertNil(t, worker.Producer.Send("audits", []byte("v")), "send audits")
+	testingutil.AssertNil(t, worker.Producer.Send("refunds", []byte("v")), "send refunds")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	testingutil.AssertNil(t, worker.Ping(ctx), "ping broker")
+	status := worker.HealthCheck(ctx)
+	// 私有topic 不检查消费者组，订阅同一个消费者组的topic 只查询一次
+	testingutil.AssertEquals(t, "brokers=ok,topic:audits=ok,topic:orders=ok,topic:payments=ok,topic:refunds=ok,topic:replies=ok,group:group/orders=ok,group:group/payments=ok", healthChecksString(status), "healthy checks")
+	testingutil.AssertTrue(t, status.Healthy, "healthy")
+	testingutil.AssertEquals(t, "2 partitions with leader", status.Checks[2].Message, "partitions with leader")
+	testingutil.AssertEquals(t, "1 members assigned to topic", status.Checks[6].Message, "assigned members")
+	described := broker.describedGroups()
+	testingutil.AssertEquals(t, "group", strings.Join(described[len(described)-1], ","), "described groups")
+
+	broker.setLeaderless("payments", 1)
+	broker.setMissing("refunds")
+	status = worker.HealthCheck(ctx)
+	testingutil.AssertEquals(t, "brokers=ok,topic:audits=ok,topic:orders=ok,topic:payments=partitions [1] without leader,topic:refunds="+k.UnknownTopicOrPartition.Error()+",topic:replies=ok,group:group/orders=ok,group:group/payments=ok", healthChecksString(status), "unhealthy checks")
+	testingutil.AssertFalse(t, status.Healthy, "unhealthy")
+}
+
+func TestKafkaHealthCheckUnreachable(t *testing.T) {
+	worker := kafka.NewKafkaWorker("127.0.0.1:1", 0, "", "group")
+	defer worker.Close(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	testingutil.AssertNotNil(t, worker.Ping(ctx), "ping unreachable broker")
+	status := worker.HealthCheck(ctx)
+	testingutil.AssertFalse(t, status.Healthy, "unreachable broker unhealthy")
+	testingutil.AssertEquals(t, mqenv.DriverTypeKafka, status.Driver, "driver type")
+	testingutil.AssertEquals(t, "brokers", status.Checks[0].Name, "brokers check")
+}
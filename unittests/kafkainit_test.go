@@ -0,0 +1,37 @@
+package unittests
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaConcurrentInitSameName(t *testing.T) {
+	const name = "concurrent-init"
+	const total = 16
+	workers := make([]*kafka.KafkaWorker, total)
+	var wg sync.WaitGroup
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			worker, err := kafka.InitKafka(name, kafka.Config{Hosts: "127.0.0.1:1", GroupID: "group"})
+			testingutil.AssertNil(t, err, "InitKafka error")
+			workers[i] = worker
+		}(i)
+	}
+	wg.Wait()
+
+	testingutil.AssertNotNil(t, workers[0], "kafka instance")
+	for i := 1; i < total; i++ {
+		testingutil.AssertTrue(t, workers[0] == workers[i], "same kafka instance")
+	}
+	worker, err := kafka.GetKafka(name)
+	testingutil.AssertNil(t, err, "GetKafka error")
+	testingutil.AssertTrue(t, workers[0] == worker, "registered kafka instance")
+	testingutil.AssertNil(t, kafka.StopKafka(name), "StopKafka error")
+	_, err = kafka.GetKafka(name)
+	testingutil.AssertNotNil(t, err, "stopped kafka instance")
+}
@@ -0,0 +1,76 @@
+package unittests
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+// receivedValues 线程安全地记录回调收到的消息.
+type receivedValues struct {
+	values []string
+	mu     sync.Mutex
+}
+
+func (r *receivedValues) add(value []byte) {
+	r.mu.Lock()
+	r.values = append(r.values, string(value))
+	r.mu.Unlock()
+}
+
+func (r *receivedValues) sorted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := append([]string{}, r.values...)
+	sort.Strings(values)
+	return values
+}
+
+func TestKafkaConsumerOffsetStore(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	broker.append("orders", 0, fakeKafkaRecord{Value: []byte("p0-0")}, fakeKafkaRecord{Value: []byte("p0-1")}, fakeKafkaRecord{Value: []byte("p0-2")})
+	broker.append("orders", 1, fakeKafkaRecord{Value: []byte("p1-0")}, fakeKafkaRecord{Value: []byte("p1-1")})
+	store := kafka.NewMemoryOffsetStore()
+	ctx := context.Background()
+	testingutil.AssertNil(t, store.SaveOffset(ctx, "group", "orders", 0, 1), "save offset")
+
+	c := kafka.NewConsumer(broker.addr(), "")
+	c.ConfigOffsetStore(store)
+	testingutil.AssertEquals(t, kafka.ErrOffsetStoreNeedsGroup, c.Receive("orders", func([]byte) {}), "offset store without group")
+
+	c = kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	c.ConfigOffsetStore(store)
+	received := &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("orders", received.add), "receive")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 4 }, "received messages")
+	testingutil.AssertEquals(t, "p0-1,p0-2,p1-0,p1-1", strings.Join(received.sorted(), ","), "resumed from stored offset")
+	waitFor(t, 10*time.Second, func() bool {
+		offset, _, _ := store.LoadOffset(ctx, "group", "orders", 1)
+		return offset == 2
+	}, "saved offset of partition 1")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close")
+
+	// 重新订阅时从最后处理的消息之后继续消费
+	broker.append("orders", 0, fakeKafkaRecord{Value: []byte("p0-3")})
+	broker.append("orders", 1, fakeKafkaRecord{Value: []byte("p1-2")})
+	c = kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetStore(store)
+	received = &receivedValues{}
+	testingutil.AssertNil(t, c.Receive("orders", received.add), "receive again")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 2 }, "received new messages")
+	testingutil.AssertEquals(t, "p0-3,p1-2", strings.Join(received.sorted(), ","), "resumed after restart")
+	offset, ok, _ := store.LoadOffset(ctx, "group", "orders", 0)
+	testingutil.AssertTrue(t, ok, "offset of partition 0 saved")
+	waitFor(t, 10*time.Second, func() bool {
+		offset, _, _ = store.LoadOffset(ctx, "group", "orders", 0)
+		return offset == 4
+	}, "saved offset of partition 0")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close again")
+}
@@ -0,0 +1,110 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+func TestKafkaSendMessagePartitions(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 3)
+	broker.createTopics("orders")
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+
+	err := p.SendMessage("orders", kafka.PartitionMessage(-1, []byte("invalid")))
+	testingutil.AssertEquals(t, kafka.ErrInvalidPartition, err, "negative explicit partition")
+
+	for i := 0; i < 4; i++ {
+		testingutil.AssertNil(t, p.SendMessage("orders", kafka.PartitionMessage(2, []byte("explicit"))), "send explicit")
+	}
+	waitFor(t, 10*time.Second, func() bool { return len(broker.partitionRecords("orders", 2)) == 4 }, "explicit partition records")
+
+	for i := 0; i < 6; i++ {
+		msg := kafka.KeyedMessage([]byte("customer-1"), []byte("keyed"))
+		msg.Headers = map[string]string{"trace": "t1"}
+		testingutil.AssertNil(t, p.SendMessage("orders", msg), "send keyed")
+	}
+	waitFor(t, 10*time.Second, func() bool { return len(broker.records("orders")) == 10 }, "keyed records")
+	keyedPartitions := 0
+	for partition := 0; partition < 3; partition++ {
+		keyed := 0
+		for _, record := range broker.partitionRecords("orders", partition) {
+			if "customer-1" == string(record.Key) {
+				keyed++
+				testingutil.AssertEquals(t, 1, len(record.Headers), "record headers")
+				testingutil.AssertEquals(t, "trace", record.Headers[0].Key, "record header name")
+			}
+		}
+		if keyed > 0 {
+			testingutil.AssertEquals(t, 6, keyed, "keyed messages in one partition")
+			keyedPartitions++
+		}
+	}
+	testingutil.AssertEquals(t, 1, keyedPartitions, "keyed partitions")
+
+	// 轮询时即使key 相同也分散到所有分区
+	for i := 0; i < 6; i++ {
+		msg := kafka.KeyedMessage([]byte("customer-2"), []byte("round robin"))
+		msg.Strategy = kafka.PartitionRoundRobin
+		testingutil.AssertNil(t, p.SendMessage("orders", msg), "send round robin")
+	}
+	waitFor(t, 10*time.Second, func() bool { return len(broker.records("orders")) == 16 }, "round robin records")
+	for partition := 0; partition < 3; partition++ {
+		roundRobin := 0
+		for _, record := range broker.partitionRecords("orders", partition) {
+			if "customer-2" == string(record.Key) {
+				roundRobin++
+			}
+		}
+		testingutil.AssertEquals(t, 2, roundRobin, "round robin messages in partition")
+	}
+}
+
+func TestKafkaSendMessageContext(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 3)
+	broker.createTopics("orders")
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := p.SendMessageContext(ctx, "orders", kafka.PartitionMessage(1, []byte("first")))
+	testingutil.AssertNil(t, err, "send first")
+	testingutil.AssertEquals(t, kafka.ProduceResult{Topic: "orders", Partition: 1, Offset: 0}, result, "first result")
+	result, err = p.SendMessageContext(ctx, "orders", kafka.PartitionMessage(1, []byte("second")))
+	testingutil.AssertNil(t, err, "send second")
+	testingutil.AssertEquals(t, int64(1), result.Offset, "second offset")
+	records := broker.partitionRecords("orders", 1)
+	testingutil.AssertEquals(t, 2, len(records), "records written before returning")
+	testingutil.AssertEquals(t, "second", string(records[1].Value), "second record")
+
+	keyed, err := p.SendMessageContext(ctx, "orders", kafka.KeyedMessage([]byte("customer-1"), []byte("keyed")))
+	testingutil.AssertNil(t, err, "send keyed")
+	again, err := p.SendMessageContext(ctx, "orders", kafka.KeyedMessage([]byte("customer-1"), []byte("keyed")))
+	testingutil.AssertNil(t, err, "send keyed again")
+	testingutil.AssertEquals(t, keyed.Partition, again.Partition, "keyed partition")
+	testingutil.AssertEquals(t, keyed.Offset+1, again.Offset, "keyed offset")
+
+	result, err = p.SendContext(ctx, "orders", []byte("round robin"))
+	testingutil.AssertNil(t, err, "send round robin")
+	testingutil.AssertTrue(t, result.Offset >= 0, "round robin offset")
+
+	broker.setProduceError("orders", k.NotEnoughReplicas)
+	_, err = p.SendContext(ctx, "orders", []byte("failed"))
+	testingutil.AssertTrue(t, errors.Is(err, k.NotEnoughReplicas), "produce error")
+
+	unreachable := kafka.NewProducer("127.0.0.1:1", 0)
+	defer unreachable.Close()
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer timeoutCancel()
+	start := time.Now()
+	_, err = unreachable.SendContext(timeoutCtx, "orders", []byte("timeout"))
+	testingutil.AssertNotNil(t, err, "unreachable broker")
+	testingutil.AssertTrue(t, time.Since(start) < 5*time.Second, "bounded by context")
+}
@@ -0,0 +1,108 @@
+package unittests
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+)
+
+func TestKafkaIsFatalError(t *testing.T) {
+	testingutil.AssertTrue(t, kafka.IsFatalError(k.SASLAuthenticationFailed), "sasl authentication failed")
+	testingutil.AssertTrue(t, kafka.IsFatalError(fmt.Errorf("write: %w", k.TopicAuthorizationFailed)), "wrapped authorization failed")
+	testingutil.AssertFalse(t, kafka.IsFatalError(k.LeaderNotAvailable), "leader not available")
+	testingutil.AssertFalse(t, kafka.IsFatalError(k.NotLeaderForPartition), "not leader for partition")
+	testingutil.AssertFalse(t, kafka.IsFatalError(io.EOF), "connection closed")
+	testingutil.AssertFalse(t, kafka.IsFatalError(errors.New("dial tcp: connection refused")), "network error")
+}
+
+func TestKafkaWriterBackoff(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("orders", "payments", "refunds")
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+	testingutil.AssertNil(t, p.Send("orders", []byte("v")), "send orders")
+	testingutil.AssertEquals(t, kafka.DefaultReconnectBackoff, p.Writer["orders"].WriteBackoffMin, "default backoff")
+	testingutil.AssertEquals(t, kafka.DefaultReconnectBackoffMax, p.Writer["orders"].WriteBackoffMax, "default max backoff")
+
+	p.ConfigReconnectInterval(500)
+	p.ConfigReconnectBackoffMax(1200)
+	testingutil.AssertNil(t, p.Send("payments", []byte("v")), "send payments")
+	testingutil.AssertEquals(t, 500*time.Millisecond, p.Writer["payments"].WriteBackoffMin, "configured backoff")
+	testingutil.AssertEquals(t, 1200*time.Millisecond, p.Writer["payments"].WriteBackoffMax, "configured max backoff")
+
+	p.ConfigReconnectBackoffMax(100)
+	testingutil.AssertNil(t, p.Send("refunds", []byte("v")), "send refunds")
+	testingutil.AssertEquals(t, 500*time.Millisecond, p.Writer["refunds"].WriteBackoffMax, "max backoff less than backoff")
+}
+
+func TestKafkaProducerCompletionErrors(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("orders")
+	broker.setProduceError("orders", k.LeaderNotAvailable)
+	p := kafka.NewProducer(broker.addr(), 0)
+	defer p.Close()
+	p.ConfigReconnectInterval(1)
+	p.ConfigReconnectBackoffMax(2)
+	events := make(chan kafka.ErrorEvent, 4)
+	p.SetErrorCallback(func(event kafka.ErrorEvent) {
+		events <- event
+	})
+	var completed int32
+	completions := make(chan error, 5)
+	p.SetCompletionCallback(func(messages []k.Message, err error) {
+		atomic.AddInt32(&completed, 1)
+		completions <- err
+	})
+	send := func(name string) {
+		testingutil.AssertNil(t, p.Send("orders", []byte(name)), "send "+name)
+		select {
+		case <-completions:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s not completed", name)
+		}
+	}
+	nextEvent := func(name string) kafka.ErrorEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s error event not notified", name)
+		}
+		return kafka.ErrorEvent{}
+	}
+
+	send("first")
+	writer := p.Writer["orders"]
+	nextEvent("first")
+	send("second")
+	event := nextEvent("second")
+	testingutil.AssertEquals(t, "orders", event.Topic, "event topic")
+	testingutil.AssertEquals(t, 2, event.Failures, "consecutive failures")
+	testingutil.AssertTrue(t, event.Producer, "producer event")
+	testingutil.AssertFalse(t, event.Fatal, "retryable error")
+	testingutil.AssertTrue(t, errors.Is(event.Err, k.LeaderNotAvailable), "event error")
+	testingutil.AssertTrue(t, p.Writer["orders"] == writer, "writer kept")
+
+	// 连续失败3次后丢弃writer，下次发送时重新创建
+	send("third")
+	nextEvent("third")
+	testingutil.AssertTrue(t, p.Writer["orders"] == nil, "writer dropped after failures")
+
+	broker.setProduceError("orders", 0)
+	send("succeeded")
+	testingutil.AssertTrue(t, p.Writer["orders"] != writer, "writer recreated")
+	broker.setProduceError("orders", k.SASLAuthenticationFailed)
+	send("fatal")
+	event = nextEvent("fatal")
+	testingutil.AssertEquals(t, 1, event.Failures, "failures reset after success")
+	testingutil.AssertTrue(t, event.Fatal, "fatal error")
+	testingutil.AssertEquals(t, 0, len(events), "events after success")
+	testingutil.AssertEquals(t, int32(5), atomic.LoadInt32(&completed), "completion callback")
+}
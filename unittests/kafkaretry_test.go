@@ -0,0 +1,158 @@
+package unittests
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	proto "github.com/golang/protobuf/proto"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaRetryPolicyTopics(t *testing.T) {
+	policy := kafka.RetryPolicy{}
+	testingutil.AssertEquals(t, "orders-retry-5s", policy.RetryTopic("orders", 1), "first retry topic")
+	testingutil.AssertEquals(t, "orders-retry-1m", policy.RetryTopic("orders", 2), "second retry topic")
+	testingutil.AssertEquals(t, "orders-retry-10m", policy.RetryTopic("orders", 3), "third retry topic")
+	testingutil.AssertEquals(t, "orders-dlq", policy.DeadLetterTopicOf("orders"), "dead letter topic")
+
+	policy = kafka.RetryPolicy{Delays: []time.Duration{500 * time.Millisecond, 90 * time.Second, 2 * time.Hour}, DeadLetterTopic: "failures"}
+	testingutil.AssertEquals(t, "orders-retry-500ms", policy.RetryTopic("orders", 1), "milliseconds retry topic")
+	testingutil.AssertEquals(t, "orders-retry-90s", policy.RetryTopic("orders", 2), "seconds retry topic")
+	testingutil.AssertEquals(t, "orders-retry-2h", policy.RetryTopic("orders", 3), "hours retry topic")
+	testingutil.AssertEquals(t, "failures", policy.DeadLetterTopicOf("orders"), "configured dead letter topic")
+}
+
+// retryPacketRecord 把消息封装成KafkaPacket 记录.
+func retryPacketRecord(t *testing.T, topic string, messageID string, headers map[string]string) fakeKafkaRecord {
+	p := &kafka.KafkaPacket{SendTo: topic, MessageId: messageID, Body: []byte("body")}
+	for k, v := range headers {
+		p.Headers = append(p.Headers, &kafka.KafkaPacket_Header{Name: k, Value: v})
+	}
+	data, err := proto.Marshal(p)
+	testingutil.AssertNil(t, err, "marshal packet")
+	return fakeKafkaRecord{Value: data}
+}
+
+// retryPackets 返回topic 中的KafkaPacket 记录，忽略打开通道时发送的记录.
+func retryPackets(broker *fakeKafkaBroker, topic string) []fakeKafkaRecord {
+	records := []fakeKafkaRecord{}
+	for _, record := range broker.records(topic) {
+		if !strings.Contains(string(record.Value), "_register_private") {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// retryPacketHeaders 解析记录中KafkaPacket 的header.
+func retryPacketHeaders(t *testing.T, record fakeKafkaRecord) (string, map[string]string) {
+	p := &kafka.KafkaPacket{}
+	testingutil.AssertNil(t, proto.Unmarshal(record.Value, p), "unmarshal packet")
+	headers := map[string]string{}
+	for _, h := range p.Headers {
+		headers[h.Name] = h.Value
+	}
+	return p.MessageId, headers
+}
+
+func TestKafkaSubscribeWithRetry(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("orders-retry-50ms", "orders-retry-100ms", "orders-dlq")
+	broker.append("orders", 0, retryPacketRecord(t, "orders", "mid-1", map[string]string{"X-Trace": "t-1"}))
+
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "", "group")
+	defer worker.Close(5 * time.Second)
+	worker.Consumer.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	var mu sync.Mutex
+	attempts := []time.Time{}
+	policy := kafka.RetryPolicy{Delays: []time.Duration{50 * time.Millisecond, 100 * time.Millisecond}}
+	err := worker.SubscribeWithRetry("orders", func(message mqenv.MQConsumerMessage) error {
+		mu.Lock()
+		attempts = append(attempts, time.Now())
+		n := len(attempts)
+		mu.Unlock()
+		if 1 == n {
+			panic("handler panic")
+		}
+		return errors.New("failed " + strconv.Itoa(n))
+	}, policy)
+	testingutil.AssertNil(t, err, "subscribe with retry")
+	waitFor(t, 20*time.Second, func() bool { return len(retryPackets(broker, "orders-dlq")) > 0 }, "dead letter")
+
+	mu.Lock()
+	defer mu.Unlock()
+	testingutil.AssertEquals(t, 3, len(attempts), "handled attempts")
+	if 3 == len(attempts) {
+		testingutil.AssertTrue(t, attempts[1].Sub(attempts[0]) >= 40*time.Millisecond, "first retry delayed")
+		testingutil.AssertTrue(t, attempts[2].Sub(attempts[1]) >= 90*time.Millisecond, "second retry delayed")
+	}
+
+	// 处理函数panic 视为失败，转发到第一级重试topic
+	retries := retryPackets(broker, "orders-retry-50ms")
+	testingutil.AssertEquals(t, 1, len(retries), "first retry messages")
+	if 1 == len(retries) {
+		messageID, headers := retryPacketHeaders(t, retries[0])
+		testingutil.AssertEquals(t, "mid-1", messageID, "retry message id")
+		testingutil.AssertEquals(t, "1", headers[kafka.HeaderRetryAttempt], "retry attempt")
+		testingutil.AssertEquals(t, "retry handler panic: handler panic", headers[kafka.HeaderRetryError], "retry error")
+		testingutil.AssertEquals(t, "orders", headers[kafka.HeaderOriginalTopic], "original topic")
+		testingutil.AssertEquals(t, "t-1", headers["X-Trace"], "retry keeps headers")
+		testingutil.AssertNotEquals(t, "", headers[kafka.HeaderRetryNotBefore], "retry not before")
+	}
+	testingutil.AssertEquals(t, 1, len(retryPackets(broker, "orders-retry-100ms")), "second retry messages")
+
+	deadLetters := retryPackets(broker, "orders-dlq")
+	testingutil.AssertEquals(t, 1, len(deadLetters), "dead letters")
+	messageID, headers := retryPacketHeaders(t, deadLetters[0])
+	testingutil.AssertEquals(t, "mid-1", messageID, "dead letter message id")
+	testingutil.AssertEquals(t, "3", headers[kafka.HeaderRetryAttempt], "dead letter attempts")
+	testingutil.AssertEquals(t, "failed 3", headers[kafka.HeaderRetryError], "dead letter error")
+	testingutil.AssertEquals(t, "t-1", headers["X-Trace"], "dead letter keeps headers")
+	_, ok := headers[kafka.HeaderRetryNotBefore]
+	testingutil.AssertFalse(t, ok, "dead letter without retry time")
+}
+
+func TestKafkaRetryWaitInterruptedByClose(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	later := strconv.FormatInt(time.Now().Add(time.Hour).UnixNano()/int64(time.Millisecond), 10)
+	due := strconv.FormatInt(time.Now().Add(-time.Second).UnixNano()/int64(time.Millisecond), 10)
+	broker.append("orders-retry-1h", 0,
+		retryPacketRecord(t, "orders-retry-1h", "later", map[string]string{kafka.HeaderRetryAttempt: "1", kafka.HeaderRetryNotBefore: later}),
+		retryPacketRecord(t, "orders-retry-1h", "due", map[string]string{kafka.HeaderRetryAttempt: "1", kafka.HeaderRetryNotBefore: due}),
+	)
+
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "", "group")
+	worker.Consumer.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	// 两个协程并发处理，已到期的消息处理时未到期的消息一定已经分发，正在等待重试时间
+	worker.Consumer.ConfigConcurrency(2)
+	handled := make(chan string, 2)
+	err := worker.SubscribeWithRetry("orders", func(message mqenv.MQConsumerMessage) error {
+		handled <- message.MessageID
+		return nil
+	}, kafka.RetryPolicy{Delays: []time.Duration{time.Hour}})
+	testingutil.AssertNil(t, err, "subscribe with retry")
+	select {
+	case messageID := <-handled:
+		testingutil.AssertEquals(t, "due", messageID, "due message handled")
+	case <-time.After(20 * time.Second):
+		t.Fatalf("due message not handled")
+	}
+
+	// 关闭worker 时等待中的消息发回重试topic
+	testingutil.AssertNil(t, worker.Close(5*time.Second), "close worker")
+	testingutil.AssertEquals(t, 0, len(handled), "waiting message not handled")
+	records := retryPackets(broker, "orders-retry-1h")
+	testingutil.AssertEquals(t, 3, len(records), "retry topic messages")
+	if 3 == len(records) {
+		messageID, headers := retryPacketHeaders(t, records[2])
+		testingutil.AssertEquals(t, "later", messageID, "republished message")
+		testingutil.AssertEquals(t, later, headers[kafka.HeaderRetryNotBefore], "republished retry time")
+		testingutil.AssertEquals(t, "1", headers[kafka.HeaderRetryAttempt], "republished attempt")
+	}
+}
@@ -0,0 +1,74 @@
+package unittests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaRequestWithoutPrivateTopic(t *testing.T) {
+	worker := kafka.NewKafkaWorker("127.0.0.1:1", 0, "", "group")
+	_, err := worker.Request("requests", []byte("ping"), time.Second)
+	testingutil.AssertEquals(t, kafka.ErrNoPrivateTopic, err, "request without private topic")
+	_, err = worker.Send("requests", &mqenv.MQPublishMessage{Body: []byte("ping")}, true)
+	testingutil.AssertEquals(t, kafka.ErrNoPrivateTopic, err, "send with reply without private topic")
+}
+
+// subscribeKafkaTopic 订阅topic 并等待reader 开始拉取消息，之后发送的消息不会因为从最新位置消费而丢失.
+func subscribeKafkaTopic(t *testing.T, broker *fakeKafkaBroker, worker *kafka.KafkaWorker, proxy *mqenv.MQConsumerProxy) {
+	testingutil.AssertNil(t, worker.Subscribe(proxy.Queue, proxy), "subscribe "+proxy.Queue)
+	waitFor(t, 10*time.Second, func() bool { return broker.fetched(proxy.Queue) > 0 }, "fetching "+proxy.Queue)
+}
+
+func TestKafkaRequestReply(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	server := kafka.NewKafkaWorker(broker.addr(), 0, "", "server")
+	defer server.Close(time.Second)
+	subscribeKafkaTopic(t, broker, server, &mqenv.MQConsumerProxy{
+		Queue: "requests",
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			if string(msg.Body) == "ignore" {
+				return nil
+			}
+			return &mqenv.MQPublishMessage{Body: append([]byte("re:"), msg.Body...)}
+		},
+	})
+
+	client := kafka.NewKafkaWorker(broker.addr(), 0, "replies", "client")
+	defer client.Close(time.Second)
+	subscribeKafkaTopic(t, broker, client, &mqenv.MQConsumerProxy{Queue: "replies"})
+
+	reply, err := client.Request("requests", []byte("ping"), 10*time.Second)
+	testingutil.AssertNil(t, err, "request error")
+	if nil != reply {
+		testingutil.AssertEquals(t, "re:ping", string(reply.Body), "reply body")
+	}
+	testingutil.AssertEquals(t, 0, client.PendingRequests(), "pending requests after reply")
+
+	// 没有回复的请求超时后不再等待
+	_, err = client.Request("requests", []byte("ignore"), 200*time.Millisecond)
+	testingutil.AssertTrue(t, errors.Is(err, kafka.ErrRequestTimeout), "request timeout")
+	testingutil.AssertEquals(t, 0, client.PendingRequests(), "pending requests after timeout")
+
+	// 同一个correlation id 正在等待回复时再次请求返回ErrDuplicatedRPCID
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestMessage("requests", &mqenv.MQPublishMessage{CorrelationID: "cid-1", Body: []byte("ignore")}, 2*time.Second)
+		done <- err
+	}()
+	waitFor(t, 5*time.Second, func() bool { return client.PendingRequests() == 1 }, "pending request")
+	_, err = client.RequestMessage("requests", &mqenv.MQPublishMessage{CorrelationID: "cid-1", Body: []byte("ping")}, time.Second)
+	testingutil.AssertEquals(t, kafka.ErrDuplicatedRPCID, err, "duplicated correlation id")
+	testingutil.AssertTrue(t, errors.Is(<-done, kafka.ErrRequestTimeout), "first request timeout")
+
+	// 超时之后的请求仍然可以收到回复
+	reply, err = client.Request("requests", []byte("pong"), 10*time.Second)
+	testingutil.AssertNil(t, err, "request after timeout error")
+	if nil != reply {
+		testingutil.AssertEquals(t, "re:pong", string(reply.Body), "reply body after timeout")
+	}
+}
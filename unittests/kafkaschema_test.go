@@ -0,0 +1,66 @@
+package unittests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaSchemaFrame(t *testing.T) {
+	data := kafka.FrameSchemaPayload(258, []byte("payload"))
+	testingutil.AssertEquals(t, string([]byte{0, 0, 0, 1, 2}), string(data[:5]), "frame header")
+	id, payload, err := kafka.ParseSchemaFrame(data)
+	testingutil.AssertNil(t, err, "ParseSchemaFrame error")
+	testingutil.AssertEquals(t, 258, id, "schema id")
+	testingutil.AssertEquals(t, "payload", string(payload), "payload")
+
+	_, _, err = kafka.ParseSchemaFrame([]byte{1, 0, 0, 0, 1})
+	testingutil.AssertEquals(t, kafka.ErrInvalidSchemaFrame, err, "invalid magic byte")
+}
+
+func TestKafkaAvroSerializer(t *testing.T) {
+	schema := `{"type":"record","name":"User","fields":[{"name":"name","type":"string"},{"name":"age","type":"int"}]}`
+	registered := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/subjects/users-value/versions":
+			registered++
+			w.Write([]byte(`{"id":7}`))
+		case r.Method == "GET" && r.URL.Path == "/schemas/ids/7":
+			body, _ := json.Marshal(map[string]string{"schema": schema})
+			w.Write(body)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := kafka.NewSchemaRegistryClient(kafka.SchemaRegistryConfig{URL: server.URL})
+	serializer, err := kafka.NewSchemaSerializer(registry, kafka.TopicSchema{Type: "avro", Schema: schema})
+	testingutil.AssertNil(t, err, "NewSchemaSerializer error")
+
+	data, err := serializer.Serialize("users", []byte(`{"name":"tom","age":18}`))
+	testingutil.AssertNil(t, err, "Serialize error")
+	id, _, err := kafka.ParseSchemaFrame(data)
+	testingutil.AssertNil(t, err, "ParseSchemaFrame error")
+	testingutil.AssertEquals(t, 7, id, "schema id")
+
+	_, err = serializer.Serialize("users", []byte(`{"name":"jerry","age":3}`))
+	testingutil.AssertNil(t, err, "Serialize error")
+	testingutil.AssertEquals(t, 1, registered, "schema registered once")
+
+	body, err := serializer.Deserialize("users", data)
+	testingutil.AssertNil(t, err, "Deserialize error")
+	testingutil.AssertTrue(t, strings.Contains(string(body), `"name":"tom"`), "deserialized body")
+
+	_, err = serializer.Serialize("users", []byte(`{"name":"tom"}`))
+	testingutil.AssertNotNil(t, err, "Serialize with missing field")
+
+	_, err = kafka.NewSchemaSerializer(registry, kafka.TopicSchema{Type: "thrift"})
+	testingutil.AssertNotNil(t, err, "unsupported serializer type")
+}
@@ -0,0 +1,82 @@
+package unittests
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaDescribeConsumerGroupLag(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 4)
+	for partition := 0; partition < 3; partition++ {
+		for i := 0; i < 10; i++ {
+			broker.append("orders", partition, fakeKafkaRecord{Value: []byte("v")})
+		}
+	}
+	broker.setCommitted("group", "orders", 1, 6)
+	broker.setCommitted("group", "orders", 2, 12)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	info, err := kafka.NewAdmin(broker.addr()).DescribeConsumerGroup(ctx, "group", "orders")
+	testingutil.AssertNil(t, err, "describe consumer group error")
+	if nil == info {
+		return
+	}
+	lags := []string{}
+	for _, lag := range info.Lags {
+		lags = append(lags, fmt.Sprintf("%d:%d/%d=%d", lag.Partition, lag.CommittedOffset, lag.LatestOffset, lag.Lag))
+	}
+	// 没有提交过偏移量时落后全部消息，提交的偏移量超过最新位置时没有延迟
+	testingutil.AssertEquals(t, "[0:-1/10=10 1:6/10=4 2:12/10=0 3:-1/0=0]", fmt.Sprint(lags), "partition lags")
+	testingutil.AssertEquals(t, int64(14), info.TotalLag, "total lag")
+}
+
+func TestKafkaCollectStats(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 2)
+	worker := kafka.NewKafkaWorker(broker.addr(), 0, "replies", "group")
+	defer worker.Close(5 * time.Second)
+	subscribeKafkaTopic(t, broker, worker, &mqenv.MQConsumerProxy{Queue: "orders"})
+	subscribeKafkaTopic(t, broker, worker, &mqenv.MQConsumerProxy{Queue: "replies"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stats, err := worker.CollectStats(ctx)
+	testingutil.AssertNil(t, err, "collect stats error")
+	testingutil.AssertEquals(t, "orders,replies", stats.Consumer.Topic, "consumer topics")
+	// 私有topic 不计算消费延迟
+	testingutil.AssertEquals(t, 2, len(stats.Lags), "lags of subscribed topic")
+	for _, lag := range stats.Lags {
+		testingutil.AssertEquals(t, "orders", lag.Topic, "lag topic")
+	}
+	testingutil.AssertEquals(t, int64(0), stats.TotalLag, "total lag")
+}
+
+// TestKafkaStatsWhileSending 统计时生产者同时创建writer，需要使用-race 运行.
+func TestKafkaStatsWhileSending(t *testing.T) {
+	worker := kafka.NewKafkaWorker("127.0.0.1:1", 0, "", "group")
+	defer worker.Close(time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			worker.Producer.Send(fmt.Sprintf("stats-%d", i), []byte("v"))
+		}(i)
+	}
+	for i := 0; i < 20; i++ {
+		worker.Stats()
+	}
+	wg.Wait()
+	stats := worker.Stats()
+	testingutil.AssertEquals(t, "stats-0,stats-1,stats-2,stats-3", stats.Producer.Topic, "producer topics")
+	testingutil.AssertEquals(t, "", stats.Consumer.Topic, "consumer topics")
+	testingutil.AssertTrue(t, !stats.Time.IsZero(), "stats time")
+	testingutil.AssertEquals(t, 0, len(stats.Lags), "lags before collected")
+}
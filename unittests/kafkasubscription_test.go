@@ -0,0 +1,55 @@
+package unittests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestKafkaReceiveTopics(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.append("orders", 0, fakeKafkaRecord{Value: []byte("order")})
+	broker.append("refunds", 0, fakeKafkaRecord{Value: []byte("refund")})
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	received := &receivedValues{}
+	callback := func(topic string, value []byte) {
+		received.add([]byte(topic + ":" + string(value)))
+	}
+	testingutil.AssertNil(t, c.ReceiveTopics([]string{"orders", "refunds"}, callback), "receive topics")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 2 }, "received messages")
+	testingutil.AssertEquals(t, "orders:order,refunds:refund", strings.Join(received.sorted(), ","), "received topics")
+	testingutil.AssertNotNil(t, c.ReceiveTopics([]string{"payments", "orders"}, callback), "already subscribed")
+	_, ok := c.Readers["payments"]
+	testingutil.AssertFalse(t, ok, "no topic subscribed when any subscribed")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close")
+}
+
+func TestKafkaReceivePattern(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.append("orders.created", 0, fakeKafkaRecord{Value: []byte("created")})
+	broker.append("payments", 0, fakeKafkaRecord{Value: []byte("payment")})
+	broker.createTopics("__consumer_offsets")
+	c := kafka.NewConsumer(broker.addr(), "group")
+	c.ConfigOffsetMode(kafka.OffsetModeEarliest)
+	c.ConfigTopicRefreshInterval(20)
+	received := &receivedValues{}
+	callback := func(topic string, value []byte) {
+		received.add([]byte(topic + ":" + string(value)))
+	}
+	testingutil.AssertNotNil(t, c.ReceivePattern("orders.(", callback), "invalid pattern")
+	testingutil.AssertNil(t, c.ReceivePattern(`^orders\.`, callback), "receive pattern")
+	testingutil.AssertNotNil(t, c.ReceivePattern(`^orders\.`, callback), "pattern already subscribed")
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 1 }, "received existing topic")
+
+	// 新建的匹配topic 会被自动订阅
+	broker.append("orders.cancelled", 0, fakeKafkaRecord{Value: []byte("cancelled")})
+	waitFor(t, 10*time.Second, func() bool { return len(received.sorted()) == 2 }, "received new topic")
+	testingutil.AssertEquals(t, "orders.cancelled:cancelled,orders.created:created", strings.Join(received.sorted(), ","), "received topics")
+	_, ok := c.Readers["payments"]
+	testingutil.AssertFalse(t, ok, "unmatched topic")
+	testingutil.AssertNil(t, c.Close(5*time.Second), "close")
+}
@@ -0,0 +1,349 @@
+package unittests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+	k "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/addoffsetstotxn"
+	"github.com/segmentio/kafka-go/protocol/addpartitionstotxn"
+	"github.com/segmentio/kafka-go/protocol/endtxn"
+	"github.com/segmentio/kafka-go/protocol/initproducerid"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/txnoffsetcommit"
+)
+
+// producedBatch 模拟的分区leader 收到的produce 请求.
+type producedBatch struct {
+	correlationID   int32
+	transactionalID string
+	topic           string
+	partition       int32
+	attributes      protocol.Attributes
+	batch           *protocol.RecordBatch
+	key             []byte
+	value           []byte
+}
+
+// decodeProduceRequest 解码不含长度前缀的produce v3 请求.
+func decodeProduceRequest(data []byte) (*producedBatch, error) {
+	if binary.BigEndian.Uint16(data) != uint16(protocol.Produce) || binary.BigEndian.Uint16(data[2:]) != 3 {
+		return nil, errors.New("unexpected api")
+	}
+	produced := &producedBatch{correlationID: int32(binary.BigEndian.Uint32(data[4:]))}
+	data = data[8:]
+	readString := func() string {
+		n := int16(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if n < 0 {
+			return ""
+		}
+		value := string(data[:n])
+		data = data[n:]
+		return value
+	}
+	readString() // client id
+	produced.transactionalID = readString()
+	data = data[2+4+4:] // acks, timeout, topic 数量
+	produced.topic = readString()
+	produced.partition = int32(binary.BigEndian.Uint32(data[4:]))
+	rs := protocol.RecordSet{}
+	if _, err := rs.ReadFrom(bytes.NewReader(data[8:])); err != nil {
+		return nil, err
+	}
+	produced.attributes = rs.Attributes
+	stream, ok := rs.Records.(*protocol.RecordStream)
+	if !ok || len(stream.Records) == 0 {
+		return nil, errors.New("no record batch")
+	}
+	if produced.batch, ok = stream.Records[0].(*protocol.RecordBatch); !ok {
+		return nil, errors.New("no record batch")
+	}
+	record, err := rs.Records.ReadRecord()
+	if err != nil {
+		return nil, err
+	}
+	if nil != record.Key {
+		produced.key, _ = io.ReadAll(record.Key)
+	}
+	produced.value, _ = io.ReadAll(record.Value)
+	return produced, nil
+}
+
+// encodeProduceResponse 编码只包含一个分区的produce v3 响应.
+func encodeProduceResponse(correlationID int32, topic string, partition int32, errorCode int16) []byte {
+	body := binary.BigEndian.AppendUint32(nil, uint32(correlationID))
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(topic)))
+	body = append(body, topic...)
+	body = binary.BigEndian.AppendUint32(body, 1)
+	body = binary.BigEndian.AppendUint32(body, uint32(partition))
+	body = binary.BigEndian.AppendUint16(body, uint16(errorCode))
+	body = binary.BigEndian.AppendUint64(body, 0)                  // base offset
+	body = binary.BigEndian.AppendUint64(body, 0xffffffffffffffff) // log append time
+	body = binary.BigEndian.AppendUint32(body, 0)                  // throttle time
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(body))), body...)
+}
+
+// fakeTxnTransport 模拟事务协调者的响应，记录收到的请求，produce 请求发送到本地监听的分区leader.
+type fakeTxnTransport struct {
+	mu               sync.Mutex
+	requests         []k.Request
+	inits            int
+	conns            int
+	produceErr       int16
+	badCorrelationID bool
+	listener         net.Listener
+	produced         []*producedBatch
+}
+
+// serve 模拟分区leader 处理produce 请求，同一连接上可以有多个请求.
+func (f *fakeTxnTransport) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		f.mu.Unlock()
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				header := make([]byte, 4)
+				if _, err := io.ReadFull(r, header); err != nil {
+					return
+				}
+				data := make([]byte, binary.BigEndian.Uint32(header))
+				if _, err := io.ReadFull(r, data); err != nil {
+					return
+				}
+				produced, err := decodeProduceRequest(data)
+				if err != nil {
+					return
+				}
+				f.mu.Lock()
+				f.produced = append(f.produced, produced)
+				errorCode := f.produceErr
+				correlationID := produced.correlationID
+				if f.badCorrelationID {
+					correlationID++
+				}
+				f.mu.Unlock()
+				conn.Write(encodeProduceResponse(correlationID, produced.topic, produced.partition, errorCode))
+			}
+		}()
+	}
+}
+
+func (f *fakeTxnTransport) RoundTrip(ctx context.Context, addr net.Addr, req k.Request) (k.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	switch r := req.(type) {
+	case *initproducerid.Request:
+		f.inits++
+		return &initproducerid.Response{ProducerID: 42, ProducerEpoch: int16(f.inits)}, nil
+	case *metadata.Request:
+		addr := f.listener.Addr().(*net.TCPAddr)
+		return &metadata.Response{
+			Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: addr.IP.String(), Port: int32(addr.Port)}},
+			Topics: []metadata.ResponseTopic{{
+				Name:       r.TopicNames[0],
+				Partitions: []metadata.ResponsePartition{{PartitionIndex: 0, LeaderID: 1}},
+			}},
+		}, nil
+	case *addpartitionstotxn.Request:
+		resp := &addpartitionstotxn.Response{}
+		for _, topic := range r.Topics {
+			result := addpartitionstotxn.ResponseResult{Name: topic.Name}
+			for _, partition := range topic.Partitions {
+				result.Results = append(result.Results, addpartitionstotxn.ResponsePartition{PartitionIndex: partition})
+			}
+			resp.Results = append(resp.Results, result)
+		}
+		return resp, nil
+	case *addoffsetstotxn.Request:
+		return &addoffsetstotxn.Response{}, nil
+	case *txnoffsetcommit.Request:
+		resp := &txnoffsetcommit.Response{}
+		for _, topic := range r.Topics {
+			result := txnoffsetcommit.ResponseTopic{Name: topic.Name}
+			for _, partition := range topic.Partitions {
+				result.Partitions = append(result.Partitions, txnoffsetcommit.ResponsePartition{Partition: partition.Partition})
+			}
+			resp.Topics = append(resp.Topics, result)
+		}
+		return resp, nil
+	case *endtxn.Request:
+		return &endtxn.Response{}, nil
+	}
+	return nil, errors.New("unexpected request")
+}
+
+// take 返回并清空已记录的请求.
+func (f *fakeTxnTransport) take() []k.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+// takeProduced 返回并清空分区leader 收到的消息批次.
+func (f *fakeTxnTransport) takeProduced() []*producedBatch {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	produced := f.produced
+	f.produced = nil
+	return produced
+}
+
+func (f *fakeTxnTransport) setProduceErr(code int16, badCorrelationID bool) {
+	f.mu.Lock()
+	f.produceErr = code
+	f.badCorrelationID = badCorrelationID
+	f.mu.Unlock()
+}
+
+// counts 返回初始化producer id 的次数和leader 接受的连接数.
+func (f *fakeTxnTransport) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.inits, f.conns
+}
+
+func newFakeTransactionalProducer(t *testing.T) (*kafka.TransactionalProducer, *fakeTxnTransport) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen fake kafka leader failed with error:%v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	transport := &fakeTxnTransport{listener: listener}
+	go transport.serve()
+	p := kafka.NewTransactionalProducer("localhost:9092", "txn-test")
+	p.ConfigTransport(transport)
+	return p, transport
+}
+
+func TestKafkaTransactionalProducerStateMachine(t *testing.T) {
+	ctx := context.Background()
+	p, transport := newFakeTransactionalProducer(t)
+
+	testingutil.AssertEquals(t, kafka.ErrTransactionNotBegun, p.Send(ctx, "orders", nil, []byte("v")), "send before begin")
+	testingutil.AssertEquals(t, kafka.ErrTransactionNotBegun, p.CommitTxn(ctx), "commit before begin")
+	testingutil.AssertEquals(t, kafka.ErrTransactionNotBegun, p.AbortTxn(ctx), "abort before begin")
+
+	testingutil.AssertNil(t, p.BeginTxn(ctx), "BeginTxn error")
+	testingutil.AssertTrue(t, p.InTransaction(), "in transaction")
+	testingutil.AssertEquals(t, kafka.ErrTransactionInProgress, p.BeginTxn(ctx), "begin twice")
+	inits, _ := transport.counts()
+	testingutil.AssertEquals(t, 1, inits, "init producer id once")
+
+	testingutil.AssertNil(t, p.Send(ctx, "orders", []byte("k"), []byte("a")), "first Send error")
+	testingutil.AssertNil(t, p.Send(ctx, "orders", nil, []byte("b")), "second Send error")
+	testingutil.AssertNil(t, p.SendOffsetsToTxn(ctx, "group", []kafka.TopicPartitionOffset{{Topic: "input", Partition: 0, Offset: 10}}), "SendOffsetsToTxn error")
+	testingutil.AssertNil(t, p.CommitTxn(ctx), "CommitTxn error")
+	testingutil.AssertFalse(t, p.InTransaction(), "not in transaction after commit")
+	_, conns := transport.counts()
+	testingutil.AssertEquals(t, 1, conns, "leader connection reused in transaction")
+
+	addPartitions, sequences, commits := 0, []int32{}, []bool{}
+	for _, req := range transport.take() {
+		switch r := req.(type) {
+		case *addpartitionstotxn.Request:
+			addPartitions++
+		case *txnoffsetcommit.Request:
+			testingutil.AssertEquals(t, int64(10), r.Topics[0].Partitions[0].CommittedOffset, "committed offset")
+		case *endtxn.Request:
+			commits = append(commits, r.Committed)
+		}
+	}
+	produced := transport.takeProduced()
+	for _, batch := range produced {
+		testingutil.AssertEquals(t, "txn-test", batch.transactionalID, "produce transactional id")
+		testingutil.AssertTrue(t, batch.attributes.Transactional(), "transactional attribute")
+		testingutil.AssertEquals(t, int64(42), batch.batch.ProducerID, "produce producer id")
+		testingutil.AssertEquals(t, int16(1), batch.batch.ProducerEpoch, "produce producer epoch")
+		sequences = append(sequences, batch.batch.BaseSequence)
+	}
+	testingutil.AssertEquals(t, 1, addPartitions, "partition added to transaction once")
+	testingutil.AssertEquals(t, "[0 1]", fmt.Sprint(sequences), "sequences")
+	testingutil.AssertEquals(t, "[true]", fmt.Sprint(commits), "end txn committed")
+	testingutil.AssertEquals(t, "k", string(produced[0].key), "record key")
+	testingutil.AssertEquals(t, "a", string(produced[0].value), "record value")
+	testingutil.AssertTrue(t, nil == produced[1].key, "nil record key")
+
+	// 没有任何操作的事务回滚时不需要请求协调者
+	testingutil.AssertNil(t, p.BeginTxn(ctx), "BeginTxn error")
+	testingutil.AssertNil(t, p.AbortTxn(ctx), "AbortTxn error")
+	testingutil.AssertEquals(t, 0, len(transport.take()), "empty transaction requests")
+
+	// 发送失败后回滚，下一个事务重新初始化并重置序列号
+	testingutil.AssertNil(t, p.BeginTxn(ctx), "BeginTxn error")
+	transport.setProduceErr(6, false) // NOT_LEADER_FOR_PARTITION
+	testingutil.AssertEquals(t, true, nil != p.Send(ctx, "orders", nil, []byte("c")), "failed Send")
+	transport.setProduceErr(0, false)
+	testingutil.AssertNil(t, p.AbortTxn(ctx), "AbortTxn error")
+	testingutil.AssertNil(t, p.BeginTxn(ctx), "BeginTxn after failure error")
+	inits, conns = transport.counts()
+	testingutil.AssertEquals(t, 2, inits, "reinit producer id after failed send")
+	testingutil.AssertEquals(t, 2, conns, "new leader connection after committed transaction")
+	transport.takeProduced()
+	testingutil.AssertNil(t, p.Send(ctx, "orders", nil, []byte("d")), "Send after reinit error")
+	produced = transport.takeProduced()
+	testingutil.AssertEquals(t, 1, len(produced), "produced after reinit")
+	testingutil.AssertEquals(t, int16(2), produced[0].batch.ProducerEpoch, "bumped epoch")
+	testingutil.AssertEquals(t, int32(0), produced[0].batch.BaseSequence, "reset sequence")
+
+	// 响应的correlation id 不匹配时发送失败
+	transport.setProduceErr(0, true)
+	testingutil.AssertEquals(t, true, nil != p.Send(ctx, "orders", nil, []byte("e")), "mismatched correlation id")
+	transport.setProduceErr(0, false)
+	testingutil.AssertNil(t, p.Close(), "Close error")
+	testingutil.AssertFalse(t, p.InTransaction(), "aborted on close")
+}
+
+func TestKafkaWorkerTransactionWithoutTransactionalID(t *testing.T) {
+	worker := kafka.NewKafkaWorker("localhost:9092", 0, "", "group")
+	txn, err := worker.BeginTxn()
+	testingutil.AssertEquals(t, kafka.ErrNoTransactionalID, err, "BeginTxn")
+	testingutil.AssertTrue(t, nil == txn, "no transaction")
+	testingutil.AssertEquals(t, kafka.ErrNoTransactionalID, worker.SubscribeTransactional("input", nil), "SubscribeTransactional")
+}
+
+func TestKafkaWorkerTransactionHandle(t *testing.T) {
+	p, transport := newFakeTransactionalProducer(t)
+	worker := kafka.NewKafkaWorker("localhost:9092", 0, "", "group")
+	worker.Transaction = p
+	txn, err := worker.BeginTxn()
+	testingutil.AssertNil(t, err, "BeginTxn error")
+	_, err = worker.BeginTxn()
+	testingutil.AssertEquals(t, kafka.ErrTransactionInProgress, err, "begin twice")
+	testingutil.AssertNil(t, txn.Send("orders", &mqenv.MQPublishMessage{Body: []byte("a")}), "txn Send error")
+	testingutil.AssertNil(t, txn.SendOffsets("group", []kafka.TopicPartitionOffset{{Topic: "input", Offset: 1}}), "txn SendOffsets error")
+	testingutil.AssertNil(t, txn.Commit(), "txn Commit error")
+	testingutil.AssertTrue(t, txn.Done(), "txn done")
+	testingutil.AssertEquals(t, 1, len(transport.takeProduced()), "produced in transaction")
+
+	// 结束后的句柄不能操作之后开启的事务
+	next, err := worker.BeginTxn()
+	testingutil.AssertNil(t, err, "BeginTxn next error")
+	testingutil.AssertEquals(t, kafka.ErrTransactionNotBegun, txn.Send("orders", &mqenv.MQPublishMessage{Body: []byte("b")}), "Send after commit")
+	testingutil.AssertEquals(t, kafka.ErrTransactionNotBegun, txn.Abort(), "Abort after commit")
+	testingutil.AssertTrue(t, p.InTransaction(), "next transaction kept")
+	testingutil.AssertNil(t, next.Abort(), "next Abort error")
+}
@@ -0,0 +1,260 @@
+package unittests
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/locking"
+	"github.com/libpub/golib/scheduler"
+	"github.com/libpub/golib/testingutil"
+)
+
+func testLocker(t *testing.T, kind string, locker locking.Locker) {
+	ctx := context.Background()
+	lock, err := locker.TryLock(ctx, "job", time.Second)
+	testingutil.AssertNil(t, err, kind+" TryLock error")
+	testingutil.AssertEquals(t, "job", lock.Key(), kind+" lock key")
+	_, err = locker.TryLock(ctx, "job", time.Second)
+	testingutil.AssertTrue(t, errors.Is(err, locking.ErrNotAcquired), kind+" TryLock held lock")
+	other, err := locker.TryLock(ctx, "other", time.Second)
+	testingutil.AssertNil(t, err, kind+" TryLock other key error")
+	testingutil.AssertNil(t, other.Unlock(ctx), kind+" Unlock other")
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = locker.Lock(waitCtx, "job", time.Second)
+	cancel()
+	testingutil.AssertTrue(t, errors.Is(err, context.DeadlineExceeded), kind+" Lock timed out")
+
+	acquired := make(chan locking.Lock)
+	go func() {
+		next, _ := locker.Lock(ctx, "job", time.Second)
+		acquired <- next
+	}()
+	time.Sleep(30 * time.Millisecond)
+	testingutil.AssertNil(t, lock.Refresh(ctx), kind+" Refresh")
+	testingutil.AssertNil(t, lock.Unlock(ctx), kind+" Unlock")
+	var next locking.Lock
+	select {
+	case next = <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("%s Lock not acquired after unlocked", kind)
+	}
+	testingutil.AssertTrue(t, next.Token() > lock.Token(), kind+" fencing token increased")
+	testingutil.AssertTrue(t, errors.Is(lock.Refresh(ctx), locking.ErrLockLost), kind+" Refresh released lock")
+	select {
+	case <-lock.Lost():
+	default:
+		t.Fatalf("%s released lock not marked lost after refresh", kind)
+	}
+	testingutil.AssertNil(t, next.Unlock(ctx), kind+" Unlock next")
+}
+
+func TestMemoryLocker(t *testing.T) {
+	testLocker(t, "memory", locking.NewLocker(locking.NewMemoryBackend(), locking.WithRetryInterval(5*time.Millisecond)))
+
+	// the renewal keeps the lock beyond its ttl
+	locker := locking.NewLocker(locking.NewMemoryBackend())
+	lock, err := locker.TryLock(context.Background(), "renewed", 60*time.Millisecond)
+	testingutil.AssertNil(t, err, "TryLock renewed error")
+	time.Sleep(150 * time.Millisecond)
+	_, err = locker.TryLock(context.Background(), "renewed", time.Second)
+	testingutil.AssertTrue(t, errors.Is(err, locking.ErrNotAcquired), "renewed lock still held")
+	lock.Unlock(context.Background())
+
+	locker = locking.NewLocker(locking.NewMemoryBackend(), locking.WithAutoRenew(false))
+	_, err = locker.TryLock(context.Background(), "expired", 30*time.Millisecond)
+	testingutil.AssertNil(t, err, "TryLock expired error")
+	time.Sleep(50 * time.Millisecond)
+	_, err = locker.TryLock(context.Background(), "expired", time.Second)
+	testingutil.AssertNil(t, err, "TryLock expired lock")
+}
+
+func TestSchedulerWithLocking(t *testing.T) {
+	locker := locking.NewSchedulerLocker(locking.NewLocker(locking.NewMemoryBackend()))
+	var runs int32
+	instances := []*scheduler.Scheduler{}
+	for i := 0; i < 3; i++ {
+		s := scheduler.NewScheduler(scheduler.WithLocker(locker))
+		s.Start()
+		testingutil.AssertNil(t, s.AddOnce("locked", time.Now().Add(10*time.Millisecond), func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, scheduler.WithDistributedLock(time.Minute)), "AddOnce locked error")
+		instances = append(instances, s)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, s := range instances {
+		s.Stop()
+	}
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&runs), "locked job run on one instance")
+}
+
+// serveFakeRedisScripts runs the lua scripts of locking by their commands in memory
+func serveFakeRedisScripts(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	values := map[string]string{}
+	expires := map[string]time.Time{}
+	m := sync.Mutex{}
+	get := func(key string) (string, bool) {
+		if expire, ok := expires[key]; ok && !time.Now().Before(expire) {
+			delete(values, key)
+			delete(expires, key)
+		}
+		v, ok := values[key]
+		return v, ok
+	}
+	eval := func(script string, keys []string, args []string) int64 {
+		m.Lock()
+		defer m.Unlock()
+		switch {
+		case strings.Contains(script, "INCR"):
+			if _, ok := get(keys[0]); ok {
+				return 0
+			}
+			ms, _ := strconv.Atoi(args[1])
+			values[keys[0]] = args[0]
+			expires[keys[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			n, _ := strconv.ParseInt(values[keys[1]], 10, 64)
+			values[keys[1]] = strconv.FormatInt(n+1, 10)
+			return n + 1
+		case strings.Contains(script, "PEXPIRE"):
+			if v, ok := get(keys[0]); !ok || v != args[0] {
+				return 0
+			}
+			ms, _ := strconv.Atoi(args[1])
+			expires[keys[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			return 1
+		default:
+			if v, ok := get(keys[0]); !ok || v != args[0] {
+				return 0
+			}
+			delete(values, keys[0])
+			delete(expires, keys[0])
+			return 1
+		}
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					args := []string{}
+					for i := 0; i < n; i++ {
+						header, err := r.ReadString('\n')
+						if nil != err {
+							return
+						}
+						size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+						buf := make([]byte, size+2)
+						if _, err := io.ReadFull(r, buf); nil != err {
+							return
+						}
+						args = append(args, string(buf[:size]))
+					}
+					switch strings.ToUpper(args[0]) {
+					case "EVALSHA":
+						conn.Write([]byte("-NOSCRIPT No matching script\r\n"))
+					case "EVAL":
+						numKeys, _ := strconv.Atoi(args[2])
+						result := eval(args[1], args[3:3+numKeys], args[3+numKeys:])
+						conn.Write([]byte(fmt.Sprintf(":%d\r\n", result)))
+					default:
+						conn.Write([]byte("+OK\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() {
+		l.Close()
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	addr, stop := serveFakeRedisScripts(t)
+	defer stop()
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: 0})
+	defer client.Close()
+	testLocker(t, "redis", locking.NewRedisLocker(client, "", locking.WithRetryInterval(5*time.Millisecond)))
+}
+
+// newFakeEtcdLocks serves the lease and txn apis of etcd v3 json gateway
+func newFakeEtcdLocks() *httptest.Server {
+	kvs := map[string]string{}    // key -> lease
+	leases := map[string]string{} // lease -> key
+	revision := 10
+	m := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			revision++
+			json.NewEncoder(w).Encode(map[string]string{"ID": fmt.Sprint(revision), "TTL": fmt.Sprint(req["TTL"])})
+		case "/v3/lease/keepalive":
+			ttl := "0"
+			if _, ok := leases[fmt.Sprint(req["ID"])]; ok {
+				ttl = "1"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]string{"ID": fmt.Sprint(req["ID"]), "TTL": ttl}})
+		case "/v3/lease/revoke":
+			lease := fmt.Sprint(req["ID"])
+			if key, ok := leases[lease]; ok {
+				delete(kvs, key)
+				delete(leases, lease)
+			}
+			w.Write([]byte("{}"))
+		case "/v3/kv/txn":
+			compare := req["compare"].([]interface{})[0].(map[string]interface{})
+			key, _ := base64.StdEncoding.DecodeString(fmt.Sprint(compare["key"]))
+			if _, ok := kvs[string(key)]; ok {
+				json.NewEncoder(w).Encode(map[string]interface{}{"header": map[string]string{"revision": fmt.Sprint(revision)}})
+				return
+			}
+			put := req["success"].([]interface{})[0].(map[string]interface{})["request_put"].(map[string]interface{})
+			revision++
+			kvs[string(key)] = fmt.Sprint(put["lease"])
+			leases[fmt.Sprint(put["lease"])] = string(key)
+			json.NewEncoder(w).Encode(map[string]interface{}{"header": map[string]string{"revision": fmt.Sprint(revision)}, "succeeded": true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestEtcdLocker(t *testing.T) {
+	etcd := newFakeEtcdLocks()
+	defer etcd.Close()
+	testLocker(t, "etcd", locking.NewEtcdLocker(etcd.URL, "", locking.WithRetryInterval(5*time.Millisecond)))
+}
@@ -0,0 +1,34 @@
+package unittests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestLoggerBackend(t *testing.T) {
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	defer logger.SetBackend(nil)
+
+	logger.Error.Printf("legacy %d", 1)
+	logger.Module("httpclient").With("url", "http://localhost").Warn("request failed", "status", 502)
+	logger.Trace.Println("hidden by level")
+
+	testingutil.AssertEquals(t, 2, len(entries), "backend entries")
+	testingutil.AssertEquals(t, logger.LogLevelError, entries[0].Level, "legacy level")
+	testingutil.AssertEquals(t, "legacy 1", entries[0].Message, "legacy message")
+	testingutil.AssertTrue(t, strings.HasPrefix(entries[0].Caller(), "loggerbackend_test.go:"), "legacy caller:"+entries[0].Caller())
+	testingutil.AssertEquals(t, "httpclient", entries[1].Module, "module")
+	testingutil.AssertEquals(t, 4, len(entries[1].Fields), "fields")
+	testingutil.AssertEquals(t, "url", entries[1].Fields[0], "with field key")
+	testingutil.AssertEquals(t, 502, entries[1].Fields[3], "field value")
+
+	logger.SetBackend(nil)
+	testingutil.AssertNotNil(t, logger.GetBackend(), "default backend restored")
+	testingutil.AssertEquals(t, "", logger.Entry{}.Caller(), "entry without caller")
+}
@@ -0,0 +1,86 @@
+package unittests
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func logForCaller(msg string) {
+	logger.Output(logger.LogLevelError, 2, msg)
+}
+
+func panicAndRecover() {
+	defer logger.Module("kafka").RecoverAndLog("callback panic", "topic", "orders")
+	var values map[string]int
+	values["panic"] = 1
+}
+
+func TestLoggerOutputCallDepth(t *testing.T) {
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	defer logger.SetBackend(nil)
+
+	_, _, line, _ := runtime.Caller(0)
+	logForCaller("for caller")
+	testingutil.AssertEquals(t, 1, len(entries), "entries")
+	testingutil.AssertEquals(t, "loggercaller_test.go:"+strconv.Itoa(line+1), entries[0].Caller(), "caller of helper")
+
+	logger.SetCallerEnabled(false)
+	logger.Error.Println("without caller")
+	logger.SetCallerEnabled(true)
+	testingutil.AssertEquals(t, "", entries[1].Caller(), "caller disabled")
+}
+
+func TestLoggerStackLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetStackLevel(logger.LogLevelError)
+	defer func() {
+		logger.SetStackLevel(logger.StackDisabled)
+		logger.SetOutput(os.Stdout)
+	}()
+
+	logger.Info.Println("info without stack")
+	testingutil.AssertEquals(t, 1, strings.Count(buf.String(), "\n"), "info lines")
+	buf.Reset()
+	logger.Module("httpclient").Error("error with stack")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	testingutil.AssertTrue(t, len(lines) > 2, "error lines")
+	testingutil.AssertTrue(t, strings.Contains(lines[0], "error with stack"), "error message line")
+	testingutil.AssertTrue(t, strings.HasSuffix(lines[1], "unittests.TestLoggerStackLevel()"), "stack top function:"+lines[1])
+	testingutil.AssertTrue(t, strings.Contains(lines[2], "loggercaller_test.go:"), "stack top file:"+lines[2])
+}
+
+func TestLoggerRecoverAndLog(t *testing.T) {
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	defer logger.SetBackend(nil)
+
+	panicAndRecover()
+	testingutil.AssertEquals(t, 1, len(entries), "panic entries")
+	entry := entries[0]
+	testingutil.AssertEquals(t, logger.LogLevelError, entry.Level, "panic level")
+	testingutil.AssertEquals(t, "kafka", entry.Module, "panic module")
+	testingutil.AssertEquals(t, "callback panic", entry.Message, "panic message")
+	testingutil.AssertEquals(t, 4, len(entry.Fields), "panic fields")
+	testingutil.AssertEquals(t, logger.PanicField, entry.Fields[2], "panic field key")
+	testingutil.AssertTrue(t, strings.Contains(entry.Fields[3].(string), "nil map"), "panic value")
+	testingutil.AssertTrue(t, strings.Contains(entry.Stack, "unittests.panicAndRecover"), "panic stack")
+	testingutil.AssertEquals(t, "loggercaller_test.go:22", entry.Caller(), "panic caller")
+
+	func() {
+		defer logger.RecoverAndLog("not panicked")
+	}()
+	testingutil.AssertEquals(t, 1, len(entries), "entries without panic")
+}
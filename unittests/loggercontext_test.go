@@ -0,0 +1,50 @@
+package unittests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestLoggerWithContext(t *testing.T) {
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	defer logger.SetBackend(nil)
+
+	ctx := logger.ContextWithTraceID(context.Background(), "trace-1")
+	ctx = logger.ContextWithRequestID(ctx, "req-1")
+	ctx = logger.ContextWithFields(ctx, "user", "u-1")
+	testingutil.AssertEquals(t, "trace-1", logger.TraceIDFromContext(ctx), "trace id")
+	testingutil.AssertEquals(t, "req-1", logger.RequestIDFromContext(ctx), "request id")
+
+	logger.WithContext(ctx).Info("handled", "status", 200)
+	logger.Module("kafka").WithContext(context.Background()).Info("no ids")
+	testingutil.AssertEquals(t, 2, len(entries), "entries")
+	testingutil.AssertEquals(t, "[trace_id trace-1 request_id req-1 user u-1 status 200]", fmt.Sprint(entries[0].Fields), "context fields")
+	testingutil.AssertEquals(t, 0, len(entries[1].Fields), "context without ids")
+}
+
+func TestHTTPQueryWithTraceContext(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	ctx := logger.ContextWithRequestID(logger.ContextWithTraceID(context.Background(), "trace-2"), "req-2")
+	resp, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithTraceContext(ctx))
+	testingutil.AssertNil(t, err, "httpclient.HTTPQuery error")
+	testingutil.AssertEquals(t, "ok", string(resp), "httpclient.HTTPQuery response")
+	header := <-headers
+	testingutil.AssertEquals(t, "trace-2", header.Get(logger.HeaderTraceID), "trace id header")
+	testingutil.AssertEquals(t, "req-2", header.Get(logger.HeaderRequestID), "request id header")
+}
@@ -0,0 +1,124 @@
+package unittests
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+// rotatedLogFiles the rotated files of app.log in dir sorted by name
+func rotatedLogFiles(t *testing.T, dir string) string {
+	entries, err := os.ReadDir(dir)
+	testingutil.AssertNil(t, err, "ReadDir error")
+	files := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "app-2") {
+			files = append(files, entry.Name())
+		}
+	}
+	return strings.Join(files, ",")
+}
+
+func TestFileSinkSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 1, 2, 10, 0, 0, 0, time.Local)
+	sink, err := logger.NewFileSink(logger.FileSinkConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxBytes:   10,
+		Compress:   true,
+		MaxBackups: 2,
+		Now:        func() time.Time { return now },
+	})
+	testingutil.AssertNil(t, err, "NewFileSink error")
+	for _, line := range []string{"line-1\n", "line-2\n", "line-3\n", "line-4\n"} {
+		_, err = sink.Write([]byte(line))
+		testingutil.AssertNil(t, err, "Write error")
+		now = now.Add(time.Second)
+	}
+	testingutil.AssertNil(t, sink.Close(), "Close error")
+
+	testingutil.AssertEquals(t, "app-20200102T100002.000.log.gz,app-20200102T100003.000.log.gz", rotatedLogFiles(t, dir), "rotated files")
+	current, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	testingutil.AssertEquals(t, "line-4\n", string(current), "current file")
+
+	f, err := os.Open(filepath.Join(dir, "app-20200102T100003.000.log.gz"))
+	testingutil.AssertNil(t, err, "open compressed file error")
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	testingutil.AssertNil(t, err, "gzip reader error")
+	data, _ := io.ReadAll(zr)
+	testingutil.AssertEquals(t, "line-3\n", string(data), "compressed content")
+
+	_, err = sink.Write([]byte("closed"))
+	testingutil.AssertNotNil(t, err, "write after closed")
+}
+
+func TestFileSinkTimeRotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2020, 1, 2, 23, 59, 0, 0, time.Local)
+	os.WriteFile(filepath.Join(dir, "app-20191201T000000.000.log"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(dir, "app-other.log"), []byte("other"), 0644)
+
+	sink, err := logger.NewFileSink(logger.FileSinkConfig{
+		Filename:    filepath.Join(dir, "app.log"),
+		RotateEvery: 24 * time.Hour,
+		MaxAge:      7 * 24 * time.Hour,
+		Now:         func() time.Time { return now },
+	})
+	testingutil.AssertNil(t, err, "NewFileSink error")
+	sink.Write([]byte("day-1\n"))
+	now = now.Add(2 * time.Minute)
+	sink.Write([]byte("day-2\n"))
+	testingutil.AssertNil(t, sink.Close(), "Close error")
+
+	testingutil.AssertEquals(t, "app-20200103T000100.000.log", rotatedLogFiles(t, dir), "rotated and expired files")
+	_, err = os.Stat(filepath.Join(dir, "app-other.log"))
+	testingutil.AssertNil(t, err, "other files kept")
+	current, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	testingutil.AssertEquals(t, "day-2\n", string(current), "current file")
+}
+
+func TestFileSinkRotationAlignment(t *testing.T) {
+	for _, c := range []struct {
+		interval time.Duration
+		before   time.Time
+		rotated  string
+	}{
+		{time.Hour, time.Date(2020, 1, 2, 10, 59, 0, 0, time.Local), "app-20200102T110000.000.log"},
+		{6 * time.Hour, time.Date(2020, 1, 2, 11, 59, 0, 0, time.Local), "app-20200102T120000.000.log"},
+	} {
+		dir := t.TempDir()
+		now := time.Date(2020, 1, 2, 10, 30, 0, 0, time.Local)
+		sink, err := logger.NewFileSink(logger.FileSinkConfig{
+			Filename:    filepath.Join(dir, "app.log"),
+			RotateEvery: c.interval,
+			Now:         func() time.Time { return now },
+		})
+		testingutil.AssertNil(t, err, "NewFileSink error")
+		now = c.before
+		sink.Write([]byte("before\n"))
+		testingutil.AssertEquals(t, "", rotatedLogFiles(t, dir), c.interval.String()+" not rotated before aligned time")
+		now = c.before.Add(time.Minute)
+		sink.Write([]byte("after\n"))
+		testingutil.AssertNil(t, sink.Close(), "Close error")
+		testingutil.AssertEquals(t, c.rotated, rotatedLogFiles(t, dir), c.interval.String()+" rotated at aligned time")
+	}
+}
+
+func TestLoggerInitFileSinkRotation(t *testing.T) {
+	err := logger.Init(&logger.Logger{Type: logger.RecordingTypeFileSink, Address: filepath.Join(t.TempDir(), "app.log"), Rotation: "weekly"})
+	testingutil.AssertNotNil(t, err, "invalid rotation")
+
+	defer logger.SetOutput(os.Stdout)
+	for _, rotation := range []string{"Daily", "30m"} {
+		err = logger.Init(&logger.Logger{Type: logger.RecordingTypeFileSink, Address: filepath.Join(t.TempDir(), "app.log"), Rotation: rotation})
+		testingutil.AssertNil(t, err, rotation+" rotation")
+	}
+}
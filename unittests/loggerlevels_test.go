@@ -0,0 +1,97 @@
+package unittests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestLoggerModuleLoggers(t *testing.T) {
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	level := logger.GetLevel()
+	logger.SetLevel(logger.LogLevelInfo)
+	defer func() {
+		logger.ResetModuleLevel(kafka.LoggerModule)
+		logger.SetLevel(level)
+		logger.SetBackend(nil)
+	}()
+
+	logs := logger.NewModuleLoggers(kafka.LoggerModule)
+	logs.Debug.Printf("debug of %s", "orders")
+	testingutil.AssertFalse(t, logs.IsDebugEnabled(), "debug disabled by default level")
+	testingutil.AssertEquals(t, 0, len(entries), "debug entries filtered")
+
+	logger.SetModuleLevel(kafka.LoggerModule, logger.LogLevelDebug)
+	testingutil.AssertTrue(t, logs.IsDebugEnabled(), "debug enabled for module")
+	testingutil.AssertFalse(t, logger.IsDebugEnabled(), "debug disabled without module")
+	testingutil.AssertEquals(t, logger.LogLevelInfo, logger.GetModuleLevel(httpclient.LoggerModule), "level of module not overridden")
+	logs.Debug.Printf("debug of %s", "orders")
+	logger.Debug.Println("debug without module")
+	testingutil.AssertEquals(t, 1, len(entries), "debug entries of module")
+	testingutil.AssertEquals(t, "kafka", entries[0].Module, "entry module")
+	testingutil.AssertEquals(t, "debug of orders", entries[0].Message, "entry message")
+	testingutil.AssertTrue(t, strings.HasPrefix(entries[0].Caller(), "loggerlevels_test.go:"), "entry caller:"+entries[0].Caller())
+}
+
+func TestLoggerLevelHandler(t *testing.T) {
+	level := logger.GetLevel()
+	defer func() {
+		logger.ResetModuleLevel(kafka.LoggerModule)
+		logger.ResetModuleLevel(httpclient.LoggerModule)
+		logger.SetLevel(level)
+	}()
+	logger.SetLevel(logger.LogLevelInfo)
+	mux := http.NewServeMux()
+	logger.ServeLevel(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	query := func(method string, query string, body string) (int, logger.LevelConfig) {
+		req, _ := http.NewRequest(method, server.URL+logger.LevelPath+query, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		testingutil.AssertNil(t, err, method+" levels")
+		defer resp.Body.Close()
+		config := logger.LevelConfig{}
+		json.NewDecoder(resp.Body).Decode(&config)
+		return resp.StatusCode, config
+	}
+
+	status, config := query(http.MethodPut, "", `{"modules":{"kafka":"debug","httpclient":"WARN"}}`)
+	testingutil.AssertEquals(t, http.StatusOK, status, "put levels status")
+	testingutil.AssertEquals(t, "INFO", config.Level, "default level")
+	testingutil.AssertEquals(t, "DEBUG", config.Modules["kafka"], "kafka level")
+	testingutil.AssertEquals(t, logger.LogLevelWarning, logger.GetModuleLevel(httpclient.LoggerModule), "httpclient level")
+
+	status, _ = query(http.MethodPost, "?module=httpclient&level=TRACE", "")
+	testingutil.AssertEquals(t, http.StatusOK, status, "post query status")
+	testingutil.AssertEquals(t, logger.LogLevelTrace, logger.GetModuleLevel(httpclient.LoggerModule), "httpclient level by query")
+
+	status, _ = query(http.MethodPut, "", `{"level":"ERROR","modules":{"kafka":"VERBOSE"}}`)
+	testingutil.AssertEquals(t, http.StatusBadRequest, status, "unknown level status")
+	testingutil.AssertEquals(t, logger.LogLevelInfo, logger.GetLevel(), "default level not changed by unknown level")
+	testingutil.AssertEquals(t, logger.LogLevelDebug, logger.GetModuleLevel(kafka.LoggerModule), "kafka level not changed by unknown level")
+
+	status, config = query(http.MethodPut, "", `{"level":"WARN","modules":{"kafka":""}}`)
+	testingutil.AssertEquals(t, http.StatusOK, status, "reset kafka status")
+	testingutil.AssertEquals(t, "WARN", config.Level, "default level changed")
+	_, ok := config.Modules["kafka"]
+	testingutil.AssertFalse(t, ok, "kafka level reset")
+	testingutil.AssertEquals(t, logger.LogLevelWarning, logger.GetModuleLevel(kafka.LoggerModule), "kafka level follows default")
+
+	status, config = query(http.MethodGet, "", "")
+	testingutil.AssertEquals(t, http.StatusOK, status, "get levels status")
+	testingutil.AssertEquals(t, "TRACE", config.Modules["httpclient"], "get httpclient level")
+
+	status, _ = query(http.MethodDelete, "", "")
+	testingutil.AssertEquals(t, http.StatusMethodNotAllowed, status, "delete levels status")
+}
@@ -0,0 +1,86 @@
+package unittests
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+// captureEntries sets a backend collecting the entries until the test ends
+// captureEntries captures the entries of messages only, so that the entries logged by the background routines
+// of other tests such as the log rotator are ignored
+func captureEntries(t *testing.T, messages ...string) func() []logger.Entry {
+	var m sync.Mutex
+	entries := []logger.Entry{}
+	logger.SetBackend(logger.BackendFunc(func(entry logger.Entry) {
+		for _, message := range messages {
+			if message == entry.Message {
+				m.Lock()
+				entries = append(entries, entry)
+				m.Unlock()
+				return
+			}
+		}
+	}))
+	t.Cleanup(func() { logger.SetBackend(nil) })
+	return func() []logger.Entry {
+		m.Lock()
+		defer m.Unlock()
+		return append([]logger.Entry{}, entries...)
+	}
+}
+
+func TestLoggerEvery(t *testing.T) {
+	entries := captureEntries(t, "broker down")
+	every := logger.Module("kafka").Every(20 * time.Millisecond)
+	every.Error("broker down")
+	every.Error("broker down")
+	testingutil.AssertEquals(t, 1, len(entries()), "entries within interval")
+
+	// 间隔之后写入的日志带上被丢弃的数量
+	calls := 0
+	waitFor(t, 5*time.Second, func() bool {
+		every.Error("broker down")
+		calls++
+		return len(entries()) == 2
+	}, "entry after interval")
+	written := entries()
+	testingutil.AssertEquals(t, "broker down", written[1].Message, "message after interval")
+	testingutil.AssertEquals(t, fmt.Sprint([]interface{}{logger.SuppressedField, calls}), fmt.Sprint(written[1].Fields), "suppressed entries")
+}
+
+func TestLoggerSampled(t *testing.T) {
+	entries := captureEntries(t, "read failed", "every entry")
+	sampled := logger.Module("kafka").With("topic", "orders").Sampled(3)
+	for i := 0; i < 8; i++ {
+		sampled.Error("read failed", "attempt", i)
+	}
+	results := []string{}
+	for _, entry := range entries() {
+		results = append(results, fmt.Sprint(entry.Fields))
+	}
+	testingutil.AssertEquals(t, "[[topic orders attempt 0] [topic orders attempt 3 suppressed 2] [topic orders attempt 6 suppressed 2]]", fmt.Sprint(results), "sampled entries")
+
+	all := logger.Sampled(0)
+	all.Info("every entry")
+	all.Info("every entry")
+	testingutil.AssertEquals(t, 5, len(entries()), "sampled by 1")
+}
+
+func TestLoggerErrorEvery(t *testing.T) {
+	entries := captureEntries(t, "broker down", "slow consumer")
+	every := logger.ErrorEvery(time.Hour)
+	every.Println("broker down")
+	every.Println("broker down")
+	testingutil.AssertEquals(t, 1, len(entries()), "rate limited entries")
+	testingutil.AssertEquals(t, logger.LogLevelError, entries()[0].Level, "rate limited level")
+	testingutil.AssertEquals(t, "broker down", entries()[0].Message, "rate limited message")
+
+	warning := logger.WarningEvery(time.Hour)
+	warning.Println("slow consumer")
+	testingutil.AssertEquals(t, logger.LogLevelWarning, entries()[1].Level, "warning level")
+}
@@ -0,0 +1,39 @@
+//go:build go1.21
+
+package unittests
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestSlogBackend(t *testing.T) {
+	buf := &bytes.Buffer{}
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true, Level: slog.LevelInfo})
+	logger.SetBackend(logger.NewSlogBackend(slog.New(handler)))
+	defer logger.SetBackend(nil)
+
+	logger.Module("kafka").Info("message sent", "topic", "orders")
+	logger.Debug.Println("filtered by slog handler")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	testingutil.AssertEquals(t, 1, len(lines), "slog lines:"+buf.String())
+	entry := map[string]interface{}{}
+	testingutil.AssertNil(t, json.Unmarshal([]byte(lines[0]), &entry), "slog json entry")
+	testingutil.AssertEquals(t, "INFO", entry["level"], "slog level")
+	testingutil.AssertEquals(t, "message sent", entry["msg"], "slog msg")
+	testingutil.AssertEquals(t, "kafka", entry["module"], "slog module")
+	testingutil.AssertEquals(t, "orders", entry["topic"], "slog field")
+	source, _ := entry["source"].(map[string]interface{})
+	testingutil.AssertTrue(t, strings.HasSuffix(source["file"].(string), "loggerslog_test.go"), "slog source")
+
+	testingutil.AssertEquals(t, logger.SlogLevelTrace, logger.SlogLevel(logger.LogLevelTrace), "trace level")
+	testingutil.AssertEquals(t, slog.LevelWarn, logger.SlogLevel(logger.LogLevelWarning), "warn level")
+	testingutil.AssertEquals(t, logger.SlogLevelFatal, logger.SlogLevel(logger.LogLevelFatal), "fatal level")
+}
@@ -0,0 +1,74 @@
+package unittests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestStructuredLoggerJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	logger.SetFormat(logger.FormatJSON)
+	defer func() {
+		logger.SetOutput(os.Stdout)
+		logger.SetFormat(logger.FormatText)
+	}()
+
+	logger.Module("kafka").With("topic", "orders").Info("message received", "partition", 3, "error", errors.New("failed"))
+	entry := map[string]interface{}{}
+	testingutil.AssertNil(t, json.Unmarshal(buf.Bytes(), &entry), "json entry")
+	testingutil.AssertEquals(t, "INFO", entry["level"], "level")
+	testingutil.AssertEquals(t, "kafka", entry["module"], "module")
+	testingutil.AssertEquals(t, "message received", entry["msg"], "msg")
+	testingutil.AssertEquals(t, "orders", entry["topic"], "with field")
+	testingutil.AssertEquals(t, float64(3), entry["partition"], "field")
+	testingutil.AssertEquals(t, "failed", entry["error"], "error field")
+	testingutil.AssertTrue(t, strings.HasPrefix(entry["caller"].(string), "loggerstructured_test.go:"), "caller")
+
+	buf.Reset()
+	logger.Info.Printf("legacy %s", "message")
+	entry = map[string]interface{}{}
+	testingutil.AssertNil(t, json.Unmarshal(buf.Bytes(), &entry), "legacy json entry")
+	testingutil.AssertEquals(t, "legacy message", entry["msg"], "legacy msg")
+	testingutil.AssertTrue(t, strings.HasPrefix(entry["caller"].(string), "loggerstructured_test.go:"), "legacy caller")
+}
+
+func TestStructuredLoggerLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf)
+	level := logger.GetLevel()
+	defer func() {
+		logger.SetOutput(os.Stdout)
+		logger.SetLevel(level)
+		logger.ResetModuleLevel("kafka")
+	}()
+
+	logger.SetLevel(logger.LogLevelWarning)
+	testingutil.AssertFalse(t, logger.IsDebugEnabled(), "debug disabled")
+	logger.Info.Println("hidden")
+	logger.Warning.Println("shown", "warning")
+	logger.Module("http").Debug("hidden")
+
+	logger.SetModuleLevel("kafka", logger.LogLevelDebug)
+	kafkaLogger := logger.Module("kafka")
+	testingutil.AssertTrue(t, kafkaLogger.Enabled(logger.LogLevelDebug), "module level overridden")
+	kafkaLogger.Debug("module debug", "key", "two words")
+	logger.ResetModuleLevel("kafka")
+	kafkaLogger.Debug("hidden")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	testingutil.AssertEquals(t, 2, len(lines), "written lines:"+buf.String())
+	testingutil.AssertTrue(t, strings.HasPrefix(lines[0], "[WARN] "), "legacy text prefix")
+	testingutil.AssertTrue(t, strings.Contains(lines[0], " loggerstructured_test.go:"), "legacy text caller:"+lines[0])
+	testingutil.AssertTrue(t, strings.HasSuffix(lines[0], ": shown warning"), "legacy text message:"+lines[0])
+	testingutil.AssertTrue(t, strings.HasSuffix(lines[1], `[kafka] module debug key="two words"`), "module text entry:"+lines[1])
+	testingutil.AssertEquals(t, logger.LogLevelInfo, logger.ParseLevel("info"), "parse level")
+	testingutil.AssertEquals(t, "WARN", logger.LogLevelWarning.String(), "level name")
+}
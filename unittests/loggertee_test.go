@@ -0,0 +1,142 @@
+package unittests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/logger"
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestLoggerLevelOutputs(t *testing.T) {
+	output, errOutput, fileOutput := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	logger.SetOutput(output)
+	level := logger.GetLevel()
+	logger.SetLevel(logger.LogLevelTrace)
+	logger.SetLevelOutput(logger.LogLevelError, errOutput, fileOutput)
+	logger.SetLevelOutput(logger.LogLevelTrace, fileOutput)
+	defer func() {
+		logger.SetLevelOutput(logger.LogLevelError)
+		logger.SetLevelOutput(logger.LogLevelTrace)
+		logger.SetLevel(level)
+		logger.SetOutput(os.Stdout)
+	}()
+
+	logger.Error.Println("error entry")
+	logger.Trace.Println("trace entry")
+	logger.Info.Println("info entry")
+
+	testingutil.AssertTrue(t, strings.Contains(errOutput.String(), "error entry"), "error written to error output")
+	testingutil.AssertFalse(t, strings.Contains(errOutput.String(), "trace entry"), "trace not written to error output")
+	testingutil.AssertTrue(t, strings.Contains(fileOutput.String(), "error entry"), "error written to file output")
+	testingutil.AssertTrue(t, strings.Contains(fileOutput.String(), "trace entry"), "trace written to file output")
+	testingutil.AssertEquals(t, 1, strings.Count(output.String(), "\n"), "output entries")
+	testingutil.AssertTrue(t, strings.Contains(output.String(), "info entry"), "info written to output")
+
+	logger.SetLevelOutput(logger.LogLevelError)
+	logger.Error.Println("restored error entry")
+	testingutil.AssertTrue(t, strings.Contains(output.String(), "restored error entry"), "error output restored")
+}
+
+func TestLoggerTee(t *testing.T) {
+	logger.SetOutput(&bytes.Buffer{})
+	defer logger.SetOutput(os.Stdout)
+	entries := []logger.Entry{}
+	logger.AddTee("testing", logger.LogLevelWarning, logger.BackendFunc(func(entry logger.Entry) {
+		entries = append(entries, entry)
+	}))
+	logger.Info.Println("not teed")
+	logger.Warning.Println("teed warning")
+	logger.Module("kafka").Error("teed error", "topic", "orders")
+	testingutil.AssertNotNil(t, logger.RemoveTee("testing"), "remove tee")
+	testingutil.AssertNil(t, logger.RemoveTee("testing"), "remove tee twice")
+	logger.Error.Println("after removed")
+
+	testingutil.AssertEquals(t, 2, len(entries), "teed entries")
+	testingutil.AssertEquals(t, "teed warning", entries[0].Message, "teed warning")
+	testingutil.AssertEquals(t, "kafka", entries[1].Module, "teed module")
+}
+
+func TestLoggerHTTPCollectorTee(t *testing.T) {
+	logger.SetOutput(&bytes.Buffer{})
+	defer logger.SetOutput(os.Stdout)
+	lines := []map[string]interface{}{}
+	mu := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		for scanner.Scan() {
+			line := map[string]interface{}{}
+			json.Unmarshal(scanner.Bytes(), &line)
+			lines = append(lines, line)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	err := logger.Init(&logger.Logger{
+		Level:   "DEBUG",
+		Outputs: map[string][]string{"ERROR": {"file", filepath.Join(dir, "error.log")}},
+		Tees:    []logger.TeeConfig{{Type: logger.TeeTypeHTTP, Address: server.URL, Level: "WARN"}},
+	})
+	testingutil.AssertNil(t, err, "init logger with tees")
+	logger.Info.Println("not collected")
+	logger.Module("httpclient").Warn("collected", "status", 502)
+	logger.Error.Println("collected error")
+
+	// initializing again closes the tees and outputs of the former config
+	testingutil.AssertNil(t, logger.Init(&logger.Logger{Level: "DEBUG"}), "init logger without tees")
+	mu.Lock()
+	testingutil.AssertEquals(t, 2, len(lines), "collected lines")
+	testingutil.AssertEquals(t, "collected", lines[0]["msg"], "collected msg")
+	testingutil.AssertEquals(t, "httpclient", lines[0]["module"], "collected module")
+	testingutil.AssertEquals(t, float64(502), lines[0]["status"], "collected field")
+	mu.Unlock()
+	content, err := os.ReadFile(filepath.Join(dir, "error.log"))
+	testingutil.AssertNil(t, err, "read error log")
+	testingutil.AssertTrue(t, strings.Contains(string(content), "collected error"), "error log content")
+	testingutil.AssertFalse(t, strings.Contains(string(content), "not collected"), "info not in error log")
+
+	testingutil.AssertNotNil(t, logger.Init(&logger.Logger{Tees: []logger.TeeConfig{{Type: "unknown"}}}), "unknown tee type")
+	testingutil.AssertNil(t, logger.Init(&logger.Logger{Level: "DEBUG"}), "reset logger")
+}
+
+func TestLoggerMQTee(t *testing.T) {
+	logger.SetOutput(&bytes.Buffer{})
+	defer logger.SetOutput(os.Stdout)
+	mq.InitMockMQTopic("testing-logtee", "testing.logs")
+	received := make(chan mqenv.MQConsumerMessage, 2)
+	testingutil.AssertNil(t, mq.Subscribe("testing-logtee", "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		received <- msg
+		return nil
+	}), "subscribe logs")
+
+	tee := mq.NewLogTee("testing-logtee")
+	logger.AddTee("testing-mq", logger.LogLevelWarning, tee)
+	logger.Module("kafka").Error("consume failed", "topic", "orders")
+	logger.RemoveTee("testing-mq")
+	testingutil.AssertNil(t, tee.Close(), "close mq tee")
+
+	select {
+	case msg := <-received:
+		entry := map[string]interface{}{}
+		testingutil.AssertNil(t, json.Unmarshal(msg.Body, &entry), "decode log message")
+		testingutil.AssertEquals(t, "ERROR", entry["level"], "log message level")
+		testingutil.AssertEquals(t, "consume failed", entry["msg"], "log message msg")
+		testingutil.AssertEquals(t, "orders", entry["fields"].(map[string]interface{})["topic"], "log message field")
+	case <-time.After(time.Second):
+		t.Fatal("log entry not published")
+	}
+}
@@ -0,0 +1,108 @@
+package unittests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testDecodeBase struct {
+	ID      primitive.ObjectID `json:"_id"`
+	Created time.Time          `json:"created"`
+}
+
+type testDecodeServer struct {
+	Host string `json:"host"`
+	Port uint16 `json:"port"`
+}
+
+type testDecodePayload struct {
+	testDecodeBase
+	Name     string             `json:"name"`
+	Count    int                `json:"count"`
+	Ratio    float64            `json:"ratio"`
+	Enabled  bool               `json:"enabled"`
+	Timeout  time.Duration      `json:"timeout"`
+	Tags     []string           `json:"tags"`
+	Server   *testDecodeServer  `json:"server"`
+	Servers  []testDecodeServer `json:"servers"`
+	Labels   map[string]int     `json:"labels"`
+	Extra    interface{}        `json:"extra"`
+	Ignored  string             `json:"-"`
+	NoTag    string
+	Remains  map[string]interface{}       `json:",remain"`
+	Children map[string]*testDecodeServer `json:"children"`
+}
+
+func TestDecodeMap(t *testing.T) {
+	oid := primitive.NewObjectID()
+	input := map[string]interface{}{
+		"_id":     oid.Hex(),
+		"created": "2024-03-05 10:20:30",
+		"name":    "payload",
+		"count":   "1,234",
+		"ratio":   "0.5",
+		"enabled": "yes",
+		"timeout": "1m30s",
+		"tags":    "a,b",
+		"server":  map[string]interface{}{"host": "localhost", "port": 8080.0},
+		"servers": []interface{}{map[string]interface{}{"HOST": "a", "port": "81"}},
+		"labels":  map[string]interface{}{"x": "1", "y": 2.0},
+		"extra":   []interface{}{1, "b"},
+		"Ignored": "ignored",
+		"notag":   "by field name",
+		"unknown": "kept",
+		"children": map[string]interface{}{
+			"main": map[string]interface{}{"host": "main"},
+		},
+	}
+	payload := testDecodePayload{}
+	testingutil.AssertNil(t, utils.DecodeMap(input, &payload), "DecodeMap error")
+	testingutil.AssertEquals(t, oid, payload.ID, "embedded object id")
+	testingutil.AssertEquals(t, 2024, payload.Created.Year(), "embedded time")
+	testingutil.AssertEquals(t, "payload", payload.Name, "string")
+	testingutil.AssertEquals(t, 1234, payload.Count, "int coerced")
+	testingutil.AssertEquals(t, 0.5, payload.Ratio, "float coerced")
+	testingutil.AssertTrue(t, payload.Enabled, "bool coerced")
+	testingutil.AssertEquals(t, 90*time.Second, payload.Timeout, "duration")
+	testingutil.AssertEquals(t, "a|b", strings.Join(payload.Tags, "|"), "comma separated slice")
+	testingutil.AssertEquals(t, uint16(8080), payload.Server.Port, "nested pointer")
+	testingutil.AssertEquals(t, "a", payload.Servers[0].Host, "case insensitive key")
+	testingutil.AssertEquals(t, uint16(81), payload.Servers[0].Port, "slice of structs")
+	testingutil.AssertEquals(t, 2, payload.Labels["y"], "map values")
+	testingutil.AssertEquals(t, 2, len(payload.Extra.([]interface{})), "interface passthrough")
+	testingutil.AssertEquals(t, "", payload.Ignored, "skipped field")
+	testingutil.AssertEquals(t, "by field name", payload.NoTag, "field name")
+	testingutil.AssertEquals(t, "kept", payload.Remains["unknown"], "remain keys")
+	testingutil.AssertEquals(t, "main", payload.Children["main"].Host, "map of pointers")
+
+	strict := testDecodeServer{}
+	err := utils.DecodeMap(map[string]interface{}{"host": 1, "port": 70000, "other": true}, &strict, utils.WithStrictTypes(), utils.WithErrorUnused())
+	testingutil.AssertNotNil(t, err, "DecodeMap strict error")
+	var errs definations.ValidationErrors
+	testingutil.AssertTrue(t, errors.As(err, &errs), "DecodeMap errors aggregated")
+	testingutil.AssertEquals(t, 3, len(errs), "DecodeMap error count")
+	msg := err.Error()
+	testingutil.AssertTrue(t, strings.Contains(msg, "host: cannot decode int into string"), "strict string error: "+msg)
+	testingutil.AssertTrue(t, strings.Contains(msg, "port: cannot decode int into uint16: 70000 overflows"), "overflow error: "+msg)
+	testingutil.AssertTrue(t, strings.Contains(msg, "other: unknown field"), "unused error: "+msg)
+
+	err = utils.DecodeMap(map[string]interface{}{"servers": []interface{}{map[string]interface{}{"port": "x"}}}, &payload)
+	testingutil.AssertTrue(t, nil != err && strings.HasPrefix(err.Error(), "servers[0].port: "), "nested error path")
+
+	server := testDecodeServer{}
+	testingutil.AssertNil(t, utils.DecodeMap(`{"host":"json","port":90}`, &server), "DecodeMap json text error")
+	testingutil.AssertEquals(t, "json", server.Host, "json text")
+	yamlServer := struct {
+		Host string `yaml:"address"`
+	}{}
+	testingutil.AssertNil(t, utils.DecodeMap(map[string]interface{}{"address": "y"}, &yamlServer, utils.WithDecodeTagName("yaml")), "DecodeMap tag name error")
+	testingutil.AssertEquals(t, "y", yamlServer.Host, "tag name")
+	testingutil.AssertNotNil(t, utils.DecodeMap(input, payload), "DecodeMap into non pointer")
+}
@@ -0,0 +1,40 @@
+package unittests
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/maputil"
+)
+
+func TestMaputilHelpers(t *testing.T) {
+	m := map[string]int{"b": 2, "a": 1, "c": 3}
+	keys := maputil.Keys(m)
+	sort.Strings(keys)
+	testingutil.AssertEquals(t, "a,b,c", strings.Join(keys, ","), "Keys")
+	testingutil.AssertEquals(t, "a,b,c", strings.Join(maputil.SortedKeys(m), ","), "SortedKeys")
+	values := maputil.Values(m)
+	sort.Ints(values)
+	testingutil.AssertEquals(t, "1,2,3", joinInts(values), "Values")
+
+	header := http.Header{"X-Trace": {"t"}, "Accept": {"*/*"}}
+	testingutil.AssertEquals(t, "Accept,X-Trace", strings.Join(maputil.SortedKeys(header), ","), "SortedKeys of named map type")
+
+	merged := maputil.Merge(map[string]int{"a": 1, "b": 2}, nil, map[string]int{"b": 20, "d": 4})
+	testingutil.AssertEquals(t, 3, len(merged), "Merge size")
+	testingutil.AssertEquals(t, 20, merged["b"], "Merge overrides")
+	cloned := maputil.Clone(m)
+	cloned["a"] = 10
+	testingutil.AssertEquals(t, 1, m["a"], "Clone copies")
+	testingutil.AssertTrue(t, nil == maputil.Clone(map[string]int(nil)), "Clone nil")
+	odd := maputil.Filter(m, func(k string, v int) bool { return v%2 == 1 })
+	testingutil.AssertEquals(t, "a,c", strings.Join(maputil.SortedKeys(odd), ","), "Filter")
+
+	added, removed, changed := maputil.Diff(map[string]string{"host": "a", "port": "80", "user": "u"}, map[string]string{"host": "b", "port": "80", "tls": "on"})
+	testingutil.AssertEquals(t, "tls", strings.Join(added, ","), "Diff added")
+	testingutil.AssertEquals(t, "user", strings.Join(removed, ","), "Diff removed")
+	testingutil.AssertEquals(t, "host", strings.Join(changed, ","), "Diff changed")
+}
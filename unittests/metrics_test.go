@@ -0,0 +1,113 @@
+package unittests
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/metrics"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestMetricsPrometheus(t *testing.T) {
+	registry := metrics.NewRegistry()
+	requests := registry.Counter("requests_total", "Requests served", "code")
+	requests.Inc("200")
+	requests.Add(2, "200")
+	requests.Add(-1, "200")
+	registry.Counter("requests_total", "", "code").Inc("500")
+	registry.Gauge("inflight", "").Set(3)
+	latency := registry.Histogram("latency_seconds", "Latency\nin seconds", []float64{0.5, 0.1})
+	latency.Observe(0.05)
+	latency.Observe(0.3)
+	latency.Observe(2)
+
+	buf := bytes.Buffer{}
+	testingutil.AssertNil(t, metrics.WritePrometheus(&buf, registry), "WritePrometheus")
+	expected := `# TYPE inflight gauge
+inflight 3
+# HELP latency_seconds Latency\nin seconds
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="0.5"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 2.35
+latency_seconds_count 3
+# HELP requests_total Requests served
+# TYPE requests_total counter
+requests_total{code="200"} 3
+requests_total{code="500"} 1
+`
+	testingutil.AssertEquals(t, expected, buf.String(), "prometheus text")
+
+	server := httptest.NewServer(metrics.PrometheusHandler(registry))
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	testingutil.AssertNil(t, err, "scrape")
+	resp.Body.Close()
+	testingutil.AssertTrue(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4"), "content type")
+}
+
+func TestMetricsStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	testingutil.AssertNil(t, err, "listen udp")
+	defer conn.Close()
+	registry := metrics.NewRegistry()
+	pusher, err := metrics.NewStatsDPusher(registry, metrics.StatsDConfig{Address: conn.LocalAddr().String(), Prefix: "svc."})
+	testingutil.AssertNil(t, err, "NewStatsDPusher")
+	defer pusher.Stop()
+
+	counter := registry.Counter("jobs_total", "", "queue")
+	counter.Add(3, "mail")
+	registry.Gauge("workers", "").Set(2)
+	registry.Histogram("took", "", []float64{1}).Observe(0.5)
+	testingutil.AssertNil(t, pusher.Push(), "first push")
+	testingutil.AssertEquals(t, "svc.jobs_total:3|c|#queue:mail\nsvc.took_count:1|c\nsvc.took_sum:0.5|c\nsvc.workers:2|g", readStatsDPacket(t, conn), "first packet")
+
+	counter.Inc("mail")
+	testingutil.AssertNil(t, pusher.Push(), "second push")
+	testingutil.AssertEquals(t, "svc.jobs_total:1|c|#queue:mail\nsvc.workers:2|g", readStatsDPacket(t, conn), "only deltas of counters")
+}
+
+func TestHTTPQueryMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	_, err := httpclient.HTTPQuery("POST", server.URL, nil)
+	testingutil.AssertNil(t, err, "HTTPQuery")
+
+	counted, observed := false, false
+	for _, snapshot := range metrics.DefaultRegistry.Snapshots() {
+		for _, s := range snapshot.Samples {
+			switch snapshot.Name {
+			case "httpclient_requests_total":
+				counted = counted || ("POST" == s.LabelValues[0] && host == s.LabelValues[1] && "200" == s.LabelValues[2] && 1 == s.Value)
+			case "httpclient_request_duration_seconds":
+				observed = observed || ("POST" == s.LabelValues[0] && host == s.LabelValues[1] && 1 == s.Count)
+			}
+		}
+	}
+	testingutil.AssertTrue(t, counted, "request counted")
+	testingutil.AssertTrue(t, observed, "duration observed")
+
+	testingutil.AssertNil(t, metrics.Init(metrics.Config{Exporter: metrics.ExporterPrometheus, Path: "/testmetrics"}), "init prometheus")
+	recorder := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(recorder, httptest.NewRequest("GET", "/testmetrics", nil))
+	testingutil.AssertTrue(t, strings.Contains(recorder.Body.String(), `httpclient_requests_total{method="POST",host="`+host+`",status="200"} 1`), "exported on default mux")
+	testingutil.AssertNotNil(t, metrics.Init(metrics.Config{Exporter: "unknown"}), "unsupported exporter")
+}
+
+func readStatsDPacket(t *testing.T, conn net.PacketConn) string {
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	testingutil.AssertNil(t, err, "read statsd packet")
+	return string(buf[:n])
+}
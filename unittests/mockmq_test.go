@@ -1,7 +1,9 @@
 package unittests
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/libpub/golib/mq"
@@ -39,3 +41,161 @@ func TestMockMQWithReply(t *testing.T) {
 	testingutil.AssertNotNil(t, resp, "mq.QueryMQ")
 	fmt.Printf("Testing query mock mq response: %+v\n", resp)
 }
+
+func TestMockMQDriverInterface(t *testing.T) {
+	mqCategory := "testing-driver"
+	topic := "testing.driver"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	driver, err := mq.GetMQDriver(mqCategory)
+	testingutil.AssertNil(t, err, "mq.GetMQDriver error")
+	testingutil.AssertEquals(t, mqenv.DriverTypeMock, driver.DriverType(), "driver.DriverType")
+
+	received := make(chan string, 1)
+	err = driver.ConsumeMessage(&mqenv.MQConsumerProxy{
+		Queue:       topic,
+		ConsumerTag: topic,
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- string(msg.Body)
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "driver.ConsumeMessage error")
+	err = mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("driver-message")})
+	testingutil.AssertNil(t, err, "mq.PublishMQ error")
+	testingutil.AssertEquals(t, "driver-message", <-received, "received body")
+
+	_, err = mq.GetMQDriver("testing-driver-not-exists")
+	testingutil.AssertNotNil(t, err, "mq.GetMQDriver with unknown category")
+}
+
+func TestMockMQPublishAndSubscribe(t *testing.T) {
+	mqCategory := "testing-publisher"
+	topic := "testing.publisher"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	received := make(chan mqenv.MQConsumerMessage, 2)
+	err := mq.Subscribe(mqCategory, "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		received <- msg
+		return nil
+	})
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	err = mq.Publish(mqCategory, mqenv.MQPublishMessage{Body: []byte(`{"name":"publisher"}`)})
+	testingutil.AssertNil(t, err, "mq.Publish error")
+	msg := <-received
+	testingutil.AssertEquals(t, mq.ContentTypeJSON, msg.ContentType, "json content type")
+
+	err = mq.Publish(mqCategory, mqenv.MQPublishMessage{
+		Body:    []byte("plain"),
+		Headers: map[string]string{"content-type": "text/csv"},
+	})
+	testingutil.AssertNil(t, err, "mq.Publish error")
+	msg = <-received
+	testingutil.AssertEquals(t, "text/csv", msg.ContentType, "header content type")
+
+	err = mq.Publish(mqCategory, mqenv.MQPublishMessage{
+		Body:    []byte("plain"),
+		Headers: map[string]string{mq.HeaderCorrelationID: "correlation-1", "X-Trace": "trace-1"},
+	})
+	testingutil.AssertNil(t, err, "mq.Publish error")
+	msg = <-received
+	testingutil.AssertEquals(t, "correlation-1", msg.CorrelationID, "header correlation id")
+	testingutil.AssertEquals(t, "trace-1", msg.Headers["X-Trace"], "custom header")
+	testingutil.AssertEquals(t, 1, len(msg.Headers), "headers of message fields removed")
+
+	err = mq.Publish("testing-publisher-not-exists", mqenv.MQPublishMessage{})
+	testingutil.AssertNotNil(t, err, "mq.Publish with unknown category")
+}
+
+func TestMockMQInterceptors(t *testing.T) {
+	mqCategory := "testing-interceptor"
+	topic := "testing.interceptor"
+	mq.InitMockMQTopic(mqCategory, topic)
+	defer mq.ClearInterceptors(mqCategory)
+
+	steps := []string{}
+	mq.UsePublishInterceptors(mqCategory, func(category string, pm *mqenv.MQPublishMessage, next mq.PublishHandler) error {
+		steps = append(steps, "publish")
+		if nil == pm.Headers {
+			pm.Headers = map[string]string{}
+		}
+		pm.Headers["X-Trace-Id"] = "trace-1"
+		return next(pm)
+	})
+	mq.UseConsumeInterceptors(mqCategory,
+		func(category string, msg mqenv.MQConsumerMessage, next mqenv.MQConsumerCallback) *mqenv.MQPublishMessage {
+			steps = append(steps, "consume-1:"+msg.GetHeader("X-Trace-Id"))
+			return next(msg)
+		},
+		func(category string, msg mqenv.MQConsumerMessage, next mqenv.MQConsumerCallback) *mqenv.MQPublishMessage {
+			steps = append(steps, "consume-2")
+			if "drop" == string(msg.Body) {
+				return nil
+			}
+			return next(msg)
+		},
+	)
+
+	received := make(chan string, 2)
+	err := mq.ConsumeMQ(mqCategory, &mqenv.MQConsumerProxy{
+		Queue:       topic,
+		ConsumerTag: topic,
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- string(msg.Body)
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "mq.ConsumeMQ error")
+
+	err = mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("drop")})
+	testingutil.AssertNil(t, err, "mq.PublishMQ error")
+	err = mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("keep")})
+	testingutil.AssertNil(t, err, "mq.PublishMQ error")
+	testingutil.AssertEquals(t, "keep", <-received, "received body")
+	testingutil.AssertEquals(t, "publish,consume-1:trace-1,consume-2,publish,consume-1:trace-1,consume-2", strings.Join(steps, ","), "interceptor steps")
+}
+
+func TestMockMQDeliveryControls(t *testing.T) {
+	mqCategory := "testing-controls"
+	topic := "testing.controls"
+	mq.InitMockMQTopic(mqCategory, topic)
+	mock, err := mq.GetMockMQ(mqCategory)
+	testingutil.AssertNil(t, err, "mq.GetMockMQ error")
+	defer mock.Reset()
+
+	received := make(chan string, 4)
+	err = mq.ConsumeMQ(mqCategory, &mqenv.MQConsumerProxy{
+		Queue:       topic,
+		ConsumerTag: topic,
+		Callback: func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- string(msg.Body)
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "mq.ConsumeMQ error")
+
+	mock.HoldDeliveries()
+	testingutil.AssertNil(t, mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("first")}), "publish first")
+	testingutil.AssertNil(t, mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("second")}), "publish second")
+	testingutil.AssertEquals(t, 0, len(received), "held messages not delivered")
+	testingutil.AssertEquals(t, 2, mock.Pending(), "pending messages")
+	testingutil.AssertEquals(t, 1, mock.Deliver(1), "delivered one")
+	testingutil.AssertEquals(t, "first", <-received, "delivered in order")
+	testingutil.AssertEquals(t, 1, mock.ReleaseDeliveries(), "released the rest")
+	testingutil.AssertEquals(t, "second", <-received, "released message")
+
+	mock.FailPublish(errors.New("broker down"), 1)
+	err = mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("failed")})
+	testingutil.AssertEquals(t, "broker down", fmt.Sprint(err), "injected publish failure")
+	mock.DropDeliveries(1)
+	testingutil.AssertNil(t, mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("lost")}), "publish lost")
+	testingutil.AssertNil(t, mq.PublishMQ(mqCategory, &mqenv.MQPublishMessage{Body: []byte("third")}), "publish third")
+	testingutil.AssertEquals(t, "third", <-received, "delivered after the dropped one")
+
+	bodies := []string{}
+	for _, pm := range mock.Published() {
+		bodies = append(bodies, string(pm.Body))
+	}
+	testingutil.AssertEquals(t, "first,second,lost,third", strings.Join(bodies, ","), "published messages recorded")
+}
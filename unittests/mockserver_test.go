@@ -0,0 +1,55 @@
+package unittests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/errors"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestMockServer(t *testing.T) {
+	server := testingutil.NewMockServer(t)
+	server.On("GET", "/users/1").Respond(http.StatusServiceUnavailable, "warming up").Times(1)
+	server.On("GET", "/users/*").RespondJSON(http.StatusOK, map[string]string{"name": "alice"}).WithHeader("X-Version", "2")
+	server.On("POST", "/users").RespondJSON(http.StatusCreated, map[string]int{"id": 2})
+	server.On("GET", "/slow").WithLatency(5 * time.Second)
+	server.On("GET", "/reset").WithFault(testingutil.MockFaultReset)
+
+	_, err := httpclient.HTTPQuery("GET", server.URL+"/users/1", nil)
+	testingutil.AssertTrue(t, errors.Is(err, errors.Upstream5xx), "first call failed")
+	testingutil.AssertEquals(t, "warming up", string(errors.ResponseBody(err)), "canned body")
+	header := http.Header{}
+	var user map[string]string
+	err = httpclient.HTTPQueryJSON("GET", server.URL+"/users/1", nil, &user, httpclient.WithResponseHeader(&header))
+	testingutil.AssertNil(t, err, "second call")
+	testingutil.AssertEquals(t, "alice", user["name"], "canned json")
+	testingutil.AssertEquals(t, "2", header.Get("X-Version"), "canned header")
+
+	var created map[string]int
+	err = httpclient.HTTPQueryJSON("POST", server.URL+"/users?notify=true", map[string]string{"name": "bob"}, &created, httpclient.WithHTTPHeader("X-Token", "secret"))
+	testingutil.AssertNil(t, err, "post")
+	testingutil.AssertEquals(t, 2, created["id"], "created id")
+	req := server.LastRequest("POST", "/users")
+	testingutil.AssertNotNil(t, req, "post recorded")
+	testingutil.AssertEquals(t, `{"name":"bob"}`, strings.TrimSpace(string(req.Body)), "recorded body")
+	testingutil.AssertEquals(t, "true", req.Query.Get("notify"), "recorded query")
+	testingutil.AssertEquals(t, "secret", req.Header.Get("X-Token"), "recorded header")
+
+	started := time.Now()
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/slow", nil, httpclient.WithTimeout(1))
+	testingutil.AssertNotNil(t, err, "timed out by latency")
+	testingutil.AssertTrue(t, time.Since(started) < 4*time.Second, "latency interrupted by client")
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/reset", nil)
+	testingutil.AssertNotNil(t, err, "connection reset")
+	_, err = httpclient.HTTPQuery("GET", server.URL+"/missing", nil)
+	testingutil.AssertEquals(t, http.StatusNotFound, errors.HTTPStatus(err), "unmatched route")
+
+	server.AssertCalled("GET", "/users/*", 2)
+	server.AssertCalled("DELETE", "/users/1", 0)
+	server.AssertAllCalled()
+	testingutil.AssertEquals(t, 6, len(server.Requests()), "all requests recorded")
+}
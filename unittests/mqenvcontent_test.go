@@ -0,0 +1,84 @@
+package unittests
+
+import (
+	"testing"
+
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+type mqenvContentOrder struct {
+	OrderID int    `json:"orderId"`
+	Status  string `json:"status"`
+}
+
+func TestMQEnvEncodeAndDecode(t *testing.T) {
+	pm := &mqenv.MQPublishMessage{ContentEncoding: mqenv.ContentEncodingGzip}
+	testingutil.AssertNil(t, pm.Encode(mqenvContentOrder{OrderID: 42, Status: "paid"}), "encode json")
+	testingutil.AssertEquals(t, mqenv.ContentTypeJSON, pm.ContentType, "detected json content type")
+
+	msg := mqenv.NewConsumerMessageFromPublishMessage(pm)
+	order := mqenvContentOrder{}
+	testingutil.AssertNil(t, msg.Decode(&order), "decode gzip json")
+	testingutil.AssertEquals(t, 42, order.OrderID, "order id")
+	testingutil.AssertEquals(t, "paid", order.Status, "order status")
+	order = mqenvContentOrder{}
+	testingutil.AssertNil(t, msg.AsJSON(&order), "as json")
+	testingutil.AssertEquals(t, 42, order.OrderID, "as json order id")
+	body, err := msg.DecodedBody()
+	testingutil.AssertNil(t, err, "decoded body")
+	testingutil.AssertEquals(t, `{"orderId":42,"status":"paid"}`, string(body), "decoded body")
+
+	pm = &mqenv.MQPublishMessage{}
+	testingutil.AssertNil(t, pm.Encode(&kafka.KafkaPacket{SendTo: "orders", Version: 1}), "encode protobuf")
+	testingutil.AssertEquals(t, mqenv.ContentTypeProtobuf, pm.ContentType, "detected protobuf content type")
+	msg = mqenv.NewConsumerMessageFromPublishMessage(pm)
+	packet := &kafka.KafkaPacket{}
+	testingutil.AssertNil(t, msg.Decode(packet), "decode protobuf")
+	testingutil.AssertEquals(t, "orders", packet.GetSendTo(), "packet send to")
+	packet = &kafka.KafkaPacket{}
+	testingutil.AssertNil(t, msg.AsProto(packet), "as proto")
+	testingutil.AssertEquals(t, uint32(1), packet.GetVersion(), "packet version")
+	testingutil.AssertErrorIs(t, msg.Decode(&order), mqenv.ErrUnsupportedContentType, "protobuf into struct")
+
+	pm = &mqenv.MQPublishMessage{ContentEncoding: mqenv.ContentEncodingDeflate}
+	testingutil.AssertNil(t, pm.Encode("plain text"), "encode text")
+	testingutil.AssertEquals(t, mqenv.ContentTypeText, pm.ContentType, "detected text content type")
+	msg = mqenv.NewConsumerMessageFromPublishMessage(pm)
+	text := ""
+	testingutil.AssertNil(t, msg.Decode(&text), "decode text")
+	testingutil.AssertEquals(t, "plain text", text, "text")
+	testingutil.AssertErrorIs(t, msg.Decode(&order), mqenv.ErrUnsupportedContentType, "text into struct")
+
+	msg = mqenv.MQConsumerMessage{ContentEncoding: "br", Body: []byte("x")}
+	testingutil.AssertErrorIs(t, msg.Decode(&text), mqenv.ErrUnsupportedContentEncoding, "unsupported encoding")
+	pm = &mqenv.MQPublishMessage{ContentEncoding: "br"}
+	testingutil.AssertErrorIs(t, pm.SetJSON(order), mqenv.ErrUnsupportedContentEncoding, "unsupported encoding publishing")
+}
+
+func TestMQEnvContentEncodingHeader(t *testing.T) {
+	mqCategory := "testing-content-encoding"
+	topic := "testing.content.encoding"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	received := make(chan mqenv.MQConsumerMessage, 1)
+	err := mq.Subscribe(mqCategory, "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		received <- msg
+		return nil
+	})
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	pm := mqenv.MQPublishMessage{ContentEncoding: mqenv.ContentEncodingGzip}
+	testingutil.AssertNil(t, pm.SetJSON(mqenvContentOrder{OrderID: 7}), "set json")
+	pm.ContentEncoding = ""
+	pm.Headers = map[string]string{mq.HeaderContentEncoding: mqenv.ContentEncodingGzip}
+	testingutil.AssertNil(t, mq.Publish(mqCategory, pm), "mq.Publish error")
+	msg := <-received
+	testingutil.AssertEquals(t, mqenv.ContentEncodingGzip, msg.ContentEncoding, "content encoding by header")
+	testingutil.AssertEquals(t, 0, len(msg.Headers), "content encoding header removed")
+	order := mqenvContentOrder{}
+	testingutil.AssertNil(t, msg.AsJSON(&order), "as json")
+	testingutil.AssertEquals(t, 7, order.OrderID, "order id")
+}
@@ -0,0 +1,31 @@
+package unittests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestMockMQHealthCheck(t *testing.T) {
+	mqCategory := "testing-health"
+	topic := "testing.health"
+	mq.InitMockMQTopic(mqCategory, topic)
+	err := mq.Subscribe(mqCategory, "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		return nil
+	})
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	testingutil.AssertNil(t, mq.PingMQ(context.Background(), mqCategory), "mq.PingMQ error")
+	status, err := mq.HealthCheckMQ(context.Background(), mqCategory)
+	testingutil.AssertNil(t, err, "mq.HealthCheckMQ error")
+	testingutil.AssertTrue(t, status.Healthy, "mock mq healthy")
+	testingutil.AssertEquals(t, mqenv.DriverTypeMock, status.Driver, "mock mq driver")
+	testingutil.AssertEquals(t, 1, len(status.Checks), "mock mq checks")
+	testingutil.AssertEquals(t, "topic:"+topic, status.Checks[0].Name, "mock mq subscribed topic")
+
+	_, err = mq.HealthCheckMQ(context.Background(), "testing-health-not-exists")
+	testingutil.AssertNotNil(t, err, "mq.HealthCheckMQ with unknown category")
+}
@@ -0,0 +1,99 @@
+package unittests
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestMQRouterDispatch(t *testing.T) {
+	mq.InitMockMQTopic("testing-router-orders", "testing.router.orders")
+	mq.InitMockMQTopic("testing-router-users", "testing.router.users")
+
+	received := []string{}
+	mu := sync.Mutex{}
+	record := func(name string) mq.RouteHandler {
+		return func(msg mqenv.MQConsumerMessage) (*mqenv.MQPublishMessage, error) {
+			mu.Lock()
+			received = append(received, name+":"+string(msg.Body))
+			mu.Unlock()
+			return nil, nil
+		}
+	}
+	router := mq.NewRouter()
+	router.Use(func(next mq.RouteHandler) mq.RouteHandler {
+		return func(msg mqenv.MQConsumerMessage) (*mqenv.MQPublishMessage, error) {
+			msg.Body = []byte(strings.ToUpper(string(msg.Body)))
+			return next(msg)
+		}
+	})
+	_, err := router.Handle("testing-router-orders", "", record("created"), mq.WithRouteKey("order.created"))
+	testingutil.AssertNil(t, err, "handle order.created")
+	_, err = router.Handle("testing-router-orders", "", record("order"), mq.WithRouteKey("order.#"))
+	testingutil.AssertNil(t, err, "handle order.#")
+	_, err = router.Handle("testing-router-users", "", record("user"), mq.WithRouteKey("user.*"))
+	testingutil.AssertNil(t, err, "handle user.*")
+	_, err = router.Handle("testing-router-not-exists", "", record("none"))
+	testingutil.AssertNotNil(t, err, "handle unknown category")
+	testingutil.AssertEquals(t, 3, len(router.Routes()), "routes")
+	testingutil.AssertNil(t, router.Start(), "router start")
+	testingutil.AssertErrorIs(t, router.Start(), mq.ErrRouterStarted, "start twice")
+	_, err = router.Handle("testing-router-users", "", record("late"))
+	testingutil.AssertErrorIs(t, err, mq.ErrRouterStarted, "handle after started")
+
+	publish := func(category string, routingKey string, body string) {
+		testingutil.AssertNil(t, mq.Publish(category, mqenv.MQPublishMessage{RoutingKey: routingKey, Body: []byte(body)}), "publish "+routingKey)
+	}
+	publish("testing-router-orders", "order.created", "a")
+	publish("testing-router-orders", "order.paid.online", "b")
+	publish("testing-router-orders", "invoice.created", "c")
+	publish("testing-router-users", "user.signed", "d")
+	publish("testing-router-users", "user.signed.again", "e")
+
+	mu.Lock()
+	defer mu.Unlock()
+	testingutil.AssertEquals(t, "created:A,order:B,user:D", strings.Join(received, ","), "dispatched messages")
+}
+
+func TestMQRouterRetryAndConcurrency(t *testing.T) {
+	mq.InitMockMQTopic("testing-router-retry", "testing.router.retry")
+
+	attempts := 0
+	failed := make(chan error, 1)
+	router := mq.NewRouter()
+	router.OnError(func(route *mq.Route, msg mqenv.MQConsumerMessage, err error) {
+		failed <- err
+	})
+	_, err := router.HandleFunc("testing-router-retry", "", func(msg mqenv.MQConsumerMessage) error {
+		attempts++
+		if string(msg.Body) == "panic" {
+			panic("boom")
+		}
+		if attempts < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}, mq.WithRouteRetry(mq.RouteRetryPolicy{MaxAttempts: 3, Interval: time.Millisecond}), mq.WithRouteConcurrency(1))
+	testingutil.AssertNil(t, err, "handle func")
+	testingutil.AssertNil(t, router.Start(), "router start")
+
+	testingutil.AssertNil(t, mq.Publish("testing-router-retry", mqenv.MQPublishMessage{Body: []byte("retry")}), "publish retry")
+	testingutil.AssertEquals(t, 3, attempts, "attempts until succeeded")
+	select {
+	case err = <-failed:
+		t.Fatalf("unexpected failure:%v", err)
+	default:
+	}
+
+	attempts = 0
+	testingutil.AssertNil(t, mq.Publish("testing-router-retry", mqenv.MQPublishMessage{Body: []byte("panic")}), "publish panic")
+	testingutil.AssertEquals(t, 3, attempts, "attempts of panic")
+	err = <-failed
+	testingutil.AssertTrue(t, strings.Contains(err.Error(), "boom"), "panic error")
+}
@@ -0,0 +1,109 @@
+package unittests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq"
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestMockMQPublishDelayed(t *testing.T) {
+	mqCategory := "testing-delayed"
+	topic := "testing.delayed"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	received := make(chan string, 3)
+	err := mq.Subscribe(mqCategory, "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		received <- string(msg.Body)
+		return nil
+	})
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	start := time.Now()
+	_, err = mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("later")}, 150*time.Millisecond)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	_, err = mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("sooner")}, 50*time.Millisecond)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	canceledID, err := mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("canceled")}, 100*time.Millisecond)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	testingutil.AssertTrue(t, mq.CancelDelayed(canceledID), "mq.CancelDelayed")
+	testingutil.AssertFalse(t, mq.CancelDelayed(canceledID), "mq.CancelDelayed twice")
+	testingutil.AssertEquals(t, 2, mq.PendingDelayedMessages(), "pending delayed messages")
+
+	testingutil.AssertEquals(t, "sooner", <-received, "first delivered")
+	testingutil.AssertTrue(t, time.Since(start) >= 50*time.Millisecond, "delivered after delay")
+	testingutil.AssertEquals(t, "later", <-received, "second delivered")
+	testingutil.AssertTrue(t, time.Since(start) >= 150*time.Millisecond, "delivered after delay")
+	select {
+	case body := <-received:
+		t.Fatalf("unexpected delivered message:%s", body)
+	case <-time.After(50 * time.Millisecond):
+	}
+	testingutil.AssertEquals(t, 0, mq.PendingDelayedMessages(), "pending delayed messages after delivered")
+
+	_, err = mq.PublishDelayed("testing-delayed-not-exists", mqenv.MQPublishMessage{}, time.Second)
+	testingutil.AssertNotNil(t, err, "mq.PublishDelayed with unknown category")
+}
+
+func TestMockMQPublishDelayedSlowDelivery(t *testing.T) {
+	mqCategory := "testing-delayed-slow"
+	topic := "testing.delayed.slow"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	fastReceived := make(chan struct{})
+	slowFinished := make(chan bool, 1)
+	err := mq.Subscribe(mqCategory, "", func(msg mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+		switch string(msg.Body) {
+		case "slow":
+			// 慢消息阻塞到后续消息送达，后续消息不应被阻塞
+			select {
+			case <-fastReceived:
+				slowFinished <- true
+			case <-time.After(2 * time.Second):
+				slowFinished <- false
+			}
+		case "fast":
+			close(fastReceived)
+		}
+		return nil
+	})
+	testingutil.AssertNil(t, err, "mq.Subscribe error")
+
+	_, err = mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("slow")}, 10*time.Millisecond)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	_, err = mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("fast")}, 30*time.Millisecond)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	testingutil.AssertTrue(t, <-slowFinished, "delivering not blocked by slow publishing")
+}
+
+func TestFileDelayedMessageStore(t *testing.T) {
+	mqCategory := "testing-delayed-store"
+	topic := "testing.delayed.store"
+	mq.InitMockMQTopic(mqCategory, topic)
+
+	store, err := mq.NewFileDelayedMessageStore(t.TempDir())
+	testingutil.AssertNil(t, err, "mq.NewFileDelayedMessageStore error")
+	testingutil.AssertNil(t, mq.SetDelayedMessageStore(store), "mq.SetDelayedMessageStore error")
+	defer mq.SetDelayedMessageStore(nil)
+
+	ID, err := mq.PublishDelayed(mqCategory, mqenv.MQPublishMessage{Body: []byte("persisted"), Headers: map[string]string{"X-Trace": "t-1"}}, time.Hour)
+	testingutil.AssertNil(t, err, "mq.PublishDelayed error")
+	msgs, err := store.LoadAll()
+	testingutil.AssertNil(t, err, "store.LoadAll error")
+	testingutil.AssertEquals(t, 1, len(msgs), "persisted messages")
+	testingutil.AssertEquals(t, ID, msgs[0].ID, "persisted message id")
+	testingutil.AssertEquals(t, mqCategory, msgs[0].Category, "persisted message category")
+	testingutil.AssertEquals(t, "persisted", string(msgs[0].Message.Body), "persisted message body")
+	testingutil.AssertEquals(t, "t-1", msgs[0].Message.Headers["X-Trace"], "persisted message header")
+
+	// 重新加载已在队列中的消息不会重复调度
+	testingutil.AssertNil(t, mq.SetDelayedMessageStore(store), "mq.SetDelayedMessageStore again error")
+	testingutil.AssertEquals(t, 1, mq.PendingDelayedMessages(), "pending delayed messages")
+
+	testingutil.AssertTrue(t, mq.CancelDelayed(ID), "mq.CancelDelayed")
+	msgs, err = store.LoadAll()
+	testingutil.AssertNil(t, err, "store.LoadAll error")
+	testingutil.AssertEquals(t, 0, len(msgs), "persisted messages after canceled")
+}
@@ -0,0 +1,322 @@
+package unittests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/mqenv"
+	natsmq "github.com/libpub/golib/mq/nats"
+	"github.com/libpub/golib/testingutil"
+)
+
+// fakeNatsPublish a message published to the fake nats server
+type fakeNatsPublish struct {
+	subject string
+	reply   string
+	header  []byte
+}
+
+// fakeNatsSub a subscription of the fake nats server
+type fakeNatsSub struct {
+	subject string
+	queue   string
+}
+
+// fakeNatsClient a client connection of the fake nats server
+type fakeNatsClient struct {
+	conn net.Conn
+	m    sync.Mutex
+	subs map[string]fakeNatsSub
+}
+
+// fakeNatsServer serves the core nats text protocol with headers, and answers the JetStream
+// stream info and stream names apis, publishes with reply subject are acked as JetStream publishes
+type fakeNatsServer struct {
+	listener  net.Listener
+	m         sync.Mutex
+	clients   map[*fakeNatsClient]bool
+	published []fakeNatsPublish
+}
+
+func newFakeNatsServer(t *testing.T) *fakeNatsServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake nats failed with error:%v", err)
+	}
+	s := &fakeNatsServer{listener: l, clients: map[*fakeNatsClient]bool{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			c := &fakeNatsClient{conn: conn, subs: map[string]fakeNatsSub{}}
+			s.m.Lock()
+			s.clients[c] = true
+			s.m.Unlock()
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		s.m.Lock()
+		for c := range s.clients {
+			c.conn.Close()
+		}
+		s.m.Unlock()
+	})
+	return s
+}
+
+func (s *fakeNatsServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeNatsServer) serve(c *fakeNatsClient) {
+	defer func() {
+		c.conn.Close()
+		s.m.Lock()
+		delete(s.clients, c)
+		s.m.Unlock()
+	}()
+	c.write([]byte(`INFO {"server_id":"fake","version":"2.9.0","proto":1,"headers":true,"max_payload":1048576}` + "\r\n"))
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			c.write([]byte("PONG\r\n"))
+		case "SUB":
+			sub := fakeNatsSub{subject: fields[1]}
+			if len(fields) > 3 {
+				sub.queue = fields[2]
+			}
+			s.m.Lock()
+			c.subs[fields[len(fields)-1]] = sub
+			s.m.Unlock()
+		case "UNSUB":
+			s.m.Lock()
+			delete(c.subs, fields[1])
+			s.m.Unlock()
+		case "PUB", "HPUB":
+			headerSize := 0
+			args := fields[1:]
+			if "HPUB" == strings.ToUpper(fields[0]) {
+				headerSize, _ = strconv.Atoi(args[len(args)-2])
+				args = append(args[:len(args)-2], args[len(args)-1])
+			}
+			size, _ := strconv.Atoi(args[len(args)-1])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); nil != err {
+				return
+			}
+			pub := fakeNatsPublish{subject: args[0]}
+			if len(args) > 2 {
+				pub.reply = args[1]
+			}
+			if headerSize > 0 {
+				pub.header = data[:headerSize]
+			}
+			s.m.Lock()
+			s.published = append(s.published, pub)
+			s.m.Unlock()
+			s.route(pub.subject, pub.reply, headerSize, data[:size])
+		}
+	}
+}
+
+// route answers the JetStream apis and acks, otherwise delivers the message to the subscriptions
+func (s *fakeNatsServer) route(subject string, reply string, headerSize int, data []byte) {
+	switch {
+	case "" == reply:
+	case strings.HasPrefix(subject, "$JS.API.STREAM.INFO."):
+		name := strings.TrimPrefix(subject, "$JS.API.STREAM.INFO.")
+		s.route(reply, "", 0, []byte(`{"config":{"name":"`+name+`"},"state":{"messages":0}}`))
+		return
+	case "$JS.API.STREAM.NAMES" == subject:
+		s.route(reply, "", 0, []byte(`{"total":0,"offset":0,"limit":1024,"streams":null}`))
+		return
+	case !strings.HasPrefix(subject, "_INBOX."):
+		s.route(reply, "", 0, []byte(`{"stream":"fake","seq":1}`))
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	queues := map[string]bool{}
+	for c := range s.clients {
+		for sid, sub := range c.subs {
+			if !fakeNatsSubjectMatches(sub.subject, subject) || queues[sub.queue] {
+				continue
+			}
+			if "" != sub.queue {
+				queues[sub.queue] = true
+			}
+			head := fmt.Sprintf("MSG %s %s %d\r\n", subject, sid, len(data))
+			if headerSize > 0 {
+				head = fmt.Sprintf("HMSG %s %s %d %d\r\n", subject, sid, headerSize, len(data))
+			}
+			c.write(append(append([]byte(head), data...), '\r', '\n'))
+		}
+	}
+}
+
+func (c *fakeNatsClient) write(data []byte) {
+	c.m.Lock()
+	c.conn.Write(data)
+	c.m.Unlock()
+}
+
+// takePublished returns and clears the published messages
+func (s *fakeNatsServer) takePublished() []fakeNatsPublish {
+	s.m.Lock()
+	defer s.m.Unlock()
+	published := s.published
+	s.published = nil
+	return published
+}
+
+func fakeNatsSubjectMatches(pattern string, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if ">" == token {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || ("*" != token && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+func initFakeNatsMQ(t *testing.T, s *fakeNatsServer, name string, cfg *natsmq.Config) *natsmq.NatsMQ {
+	mq, err := natsmq.InitNatsMQ(name, &mqenv.MQConnectorConfig{Driver: mqenv.DriverTypeNats, Host: "127.0.0.1", Port: s.port(), Timeout: 5}, cfg)
+	if nil != err {
+		t.Fatalf("init nats mq failed with error:%v", err)
+	}
+	t.Cleanup(func() { mq.Close() })
+	return mq
+}
+
+func TestNatsMessageHeaders(t *testing.T) {
+	s := newFakeNatsServer(t)
+	mq := initFakeNatsMQ(t, s, "natstest-headers", &natsmq.Config{})
+	received := make(chan mqenv.MQConsumerMessage, 2)
+	err := mq.ConsumeMessage(&mqenv.MQConsumerProxy{
+		Queue:       "orders.created",
+		ConsumerTag: "tag",
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- m
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "ConsumeMessage")
+
+	err = mq.PublishMessage(&mqenv.MQPublishMessage{
+		Body:          []byte("hello"),
+		RoutingKey:    "orders.created",
+		CorrelationID: "cid-1",
+		ReplyTo:       "_INBOX.reply",
+		MessageID:     "mid-1",
+		AppID:         "app",
+		UserID:        "user",
+		ContentType:   "application/json",
+		Headers:       map[string]string{"Trace": "t-1"},
+	})
+	testingutil.AssertNil(t, err, "PublishMessage")
+	var m mqenv.MQConsumerMessage
+	select {
+	case m = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message not received")
+	}
+	testingutil.AssertEquals(t, mqenv.DriverTypeNats, m.Driver, "driver")
+	testingutil.AssertEquals(t, "orders.created", m.Queue, "queue")
+	testingutil.AssertEquals(t, "tag", m.ConsumerTag, "consumer tag")
+	testingutil.AssertEquals(t, "hello", string(m.Body), "body")
+	testingutil.AssertEquals(t, "cid-1", m.CorrelationID, "correlation id")
+	testingutil.AssertEquals(t, "_INBOX.reply", m.ReplyTo, "reply to")
+	testingutil.AssertEquals(t, "mid-1", m.MessageID, "message id")
+	testingutil.AssertEquals(t, "app", m.AppID, "app id")
+	testingutil.AssertEquals(t, "user", m.UserID, "user id")
+	testingutil.AssertEquals(t, "application/json", m.ContentType, "content type")
+	testingutil.AssertEquals(t, "t-1", m.Headers["Trace"], "custom header")
+	testingutil.AssertEquals(t, "", m.Exchange, "core nats message has no stream")
+
+	// empty fields are not written into the header
+	testingutil.AssertNil(t, mq.PublishMessage(&mqenv.MQPublishMessage{RoutingKey: "orders.created", Body: []byte("empty")}), "PublishMessage without headers")
+	select {
+	case m = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("message without headers not received")
+	}
+	testingutil.AssertEquals(t, 0, len(m.Headers), "empty headers")
+	published := s.takePublished()
+	testingutil.AssertEquals(t, 0, len(published[len(published)-1].header), "published without header")
+}
+
+func TestNatsQueryMessage(t *testing.T) {
+	s := newFakeNatsServer(t)
+	mq := initFakeNatsMQ(t, s, "natstest-query", &natsmq.Config{})
+	err := mq.ConsumeMessage(&mqenv.MQConsumerProxy{
+		Queue: "echo",
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			return &mqenv.MQPublishMessage{Body: append([]byte("re:"), m.Body...)}
+		},
+	})
+	testingutil.AssertNil(t, err, "ConsumeMessage")
+	resp, err := mq.QueryMessage(&mqenv.MQPublishMessage{RoutingKey: "echo", Body: []byte("ping"), CorrelationID: "cid-1", TimeoutSeconds: 5})
+	testingutil.AssertNil(t, err, "QueryMessage")
+	if nil != resp {
+		testingutil.AssertEquals(t, "re:ping", string(resp.Body), "response body")
+		testingutil.AssertEquals(t, "cid-1", resp.CorrelationID, "response correlation id")
+	}
+}
+
+// TestNatsStreamSubjects publishes the subjects captured by the configured subject by JetStream,
+// which waits the ack with a reply subject, and the others by core nats
+func TestNatsStreamSubjects(t *testing.T) {
+	s := newFakeNatsServer(t)
+	cases := []struct {
+		pattern string
+		subject string
+		matched bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.created", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders.created.eu", false},
+		{"orders.*.eu", "orders.created.eu", true},
+		{"orders.>", "orders.created.eu", true},
+		{"orders.>", "orders", false},
+		{">", "orders", true},
+		{"orders.created", "orders.deleted", false},
+	}
+	for _, c := range cases {
+		mq := initFakeNatsMQ(t, s, "natstest-stream", &natsmq.Config{JetStream: true, Stream: "ORDERS", Subject: c.pattern})
+		s.takePublished()
+		err := mq.PublishMessage(&mqenv.MQPublishMessage{RoutingKey: c.subject, Body: []byte("v")})
+		testingutil.AssertNil(t, err, c.pattern+" ~ "+c.subject+" publish")
+		var pub fakeNatsPublish
+		for _, p := range s.takePublished() {
+			if p.subject == c.subject {
+				pub = p
+			}
+		}
+		testingutil.AssertEquals(t, c.matched, "" != pub.reply, c.pattern+" ~ "+c.subject)
+	}
+}
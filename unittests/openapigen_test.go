@@ -0,0 +1,71 @@
+package unittests
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/httpclient/openapi"
+	"github.com/libpub/golib/httpclient/openapi/examples/petstore"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestOpenAPIGenerateUpToDate(t *testing.T) {
+	data, err := ioutil.ReadFile("../httpclient/openapi/examples/petstore/petstore.yaml")
+	testingutil.AssertNil(t, err, "read spec")
+	spec, err := openapi.ParseSpec(data)
+	testingutil.AssertNil(t, err, "ParseSpec")
+	src, err := openapi.Generate(spec, openapi.GenerateOptions{Package: "petstore", Source: "petstore.yaml"})
+	testingutil.AssertNil(t, err, "Generate")
+	generated, err := ioutil.ReadFile("../httpclient/openapi/examples/petstore/client_gen.go")
+	testingutil.AssertNil(t, err, "read generated client")
+	testingutil.AssertEquals(t, string(generated), string(src), "generated client is up to date, run go generate")
+
+	_, err = openapi.ParseSpec([]byte(`{"swagger":"2.0"}`))
+	testingutil.AssertNotNil(t, err, "swagger 2 unsupported")
+}
+
+func TestOpenAPIGeneratedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /pets":
+			pets := []petstore.Pet{{ID: 1, Name: r.Header.Get("X-Tenant") + ":" + r.URL.Query().Get("limit"), Labels: map[string]string{"tags": r.URL.RawQuery}}}
+			json.NewEncoder(w).Encode(pets)
+		case "POST /pets":
+			pet := petstore.NewPet{}
+			json.NewDecoder(r.Body).Decode(&pet)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(petstore.Pet{ID: 2, Name: pet.Name, Status: pet.Status, Owner: &petstore.PetOwner{Name: "alice"}})
+		case "GET /pets/2":
+			json.NewEncoder(w).Encode(petstore.Pet{ID: 2, Name: "kitty"})
+		case "DELETE /pets/2":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := petstore.NewClient(server.URL+"/", httpclient.WithTimeout(3))
+
+	pets, err := client.ListPets(&petstore.ListPetsParams{Limit: 10, Tags: []string{"a", "b"}, XTenant: "t1"})
+	testingutil.AssertNil(t, err, "ListPets")
+	testingutil.AssertEquals(t, 1, len(pets), "pets")
+	testingutil.AssertEquals(t, "t1:10", pets[0].Name, "query and header params")
+	testingutil.AssertEquals(t, "limit=10&tags=a&tags=b", pets[0].Labels["tags"], "encoded query")
+
+	pet, err := client.CreatePet(&petstore.NewPet{Name: "kitty", Status: petstore.StatusAvailable})
+	testingutil.AssertNil(t, err, "CreatePet")
+	testingutil.AssertEquals(t, "kitty", pet.Name, "created pet")
+	testingutil.AssertEquals(t, petstore.StatusAvailable, pet.Status, "enum value")
+	testingutil.AssertEquals(t, "alice", pet.Owner.Name, "inline object")
+
+	pet, err = client.GetPet(2)
+	testingutil.AssertNil(t, err, "GetPet")
+	testingutil.AssertEquals(t, int64(2), pet.ID, "path param")
+	testingutil.AssertNil(t, client.DeletePetsByPetID(2), "DeletePetsByPetID")
+	_, err = client.GetPet(3)
+	testingutil.AssertNotNil(t, err, "not found")
+}
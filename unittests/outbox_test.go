@@ -0,0 +1,164 @@
+package unittests
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/kafka"
+	"github.com/libpub/golib/mq/outbox"
+	"github.com/libpub/golib/netutils/dboptions"
+	"github.com/libpub/golib/scheduler"
+	"github.com/libpub/golib/testingutil"
+)
+
+func newTestingOutbox(t *testing.T, options ...outbox.Option) (*sql.DB, *outbox.Outbox) {
+	db, err := sql.Open(dboptions.DriverSQLite, filepath.Join(t.TempDir(), "outbox.db"))
+	testingutil.AssertNil(t, err, "open sqlite")
+	t.Cleanup(func() { db.Close() })
+	ob := outbox.NewOutbox(db, dboptions.DriverSQLite, options...)
+	testingutil.AssertNil(t, ob.CreateTable(context.Background()), "create outbox table")
+	testingutil.AssertNil(t, ob.CreateTable(context.Background()), "create outbox table again")
+	_, err = db.Exec("CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT)")
+	testingutil.AssertNil(t, err, "create orders table")
+	return db, ob
+}
+
+func TestOutboxPublishAfterCommit(t *testing.T) {
+	db, ob := newTestingOutbox(t)
+	ctx := context.Background()
+
+	tx, err := db.Begin()
+	testingutil.AssertNil(t, err, "begin rollback tx")
+	_, err = tx.Exec("INSERT INTO orders (id, status) VALUES (1, 'created')")
+	testingutil.AssertNil(t, err, "insert order")
+	testingutil.AssertNil(t, ob.Add(ctx, tx, &outbox.Message{Topic: "orders", Key: "1", Payload: []byte("rolled back")}), "add rolled back")
+	testingutil.AssertNil(t, tx.Rollback(), "rollback")
+
+	tx, err = db.Begin()
+	testingutil.AssertNil(t, err, "begin commit tx")
+	_, err = tx.Exec("INSERT INTO orders (id, status) VALUES (2, 'created')")
+	testingutil.AssertNil(t, err, "insert order")
+	err = ob.Add(ctx, tx,
+		&outbox.Message{Topic: "orders", Key: "2", Payload: []byte("created"), Headers: map[string]string{"trace": "t1"}},
+		&outbox.Message{Topic: "orders", Key: "2", Payload: []byte("paid")})
+	testingutil.AssertNil(t, err, "add committed")
+	testingutil.AssertNotNil(t, ob.Add(ctx, tx, &outbox.Message{Payload: []byte("no topic")}), "add without topic")
+	testingutil.AssertNil(t, tx.Commit(), "commit")
+
+	pending, err := ob.Pending(ctx)
+	testingutil.AssertNil(t, err, "pending")
+	testingutil.AssertEquals(t, int64(2), pending, "pending after commit")
+
+	published := []*outbox.Message{}
+	n, err := ob.Relay(ctx, outbox.PublisherFunc(func(ctx context.Context, msg *outbox.Message) error {
+		published = append(published, msg)
+		return nil
+	}))
+	testingutil.AssertNil(t, err, "relay")
+	testingutil.AssertEquals(t, 2, n, "relayed")
+	testingutil.AssertEquals(t, "created", string(published[0].Payload), "first published")
+	testingutil.AssertEquals(t, "t1", published[0].Headers["trace"], "published headers")
+	testingutil.AssertEquals(t, "paid", string(published[1].Payload), "second published")
+
+	n, err = ob.Relay(ctx, outbox.PublisherFunc(func(ctx context.Context, msg *outbox.Message) error {
+		t.Fatalf("message:%d relayed twice", msg.ID)
+		return nil
+	}))
+	testingutil.AssertNil(t, err, "relay again")
+	testingutil.AssertEquals(t, 0, n, "relayed again")
+
+	purged, err := ob.Purge(ctx, time.Now().Add(time.Minute))
+	testingutil.AssertNil(t, err, "purge")
+	testingutil.AssertEquals(t, int64(2), purged, "purged")
+}
+
+func TestOutboxRelayRetry(t *testing.T) {
+	db, ob := newTestingOutbox(t, outbox.WithRetryPolicy(20*time.Millisecond, 2), outbox.WithBatchSize(1))
+	ctx := context.Background()
+	err := ob.Add(ctx, db,
+		&outbox.Message{Topic: "orders", Key: "1", Payload: []byte("1-created")},
+		&outbox.Message{Topic: "orders", Key: "1", Payload: []byte("1-paid")},
+		&outbox.Message{Topic: "orders", Key: "2", Payload: []byte("2-created")})
+	testingutil.AssertNil(t, err, "add")
+
+	published := []string{}
+	failing := true
+	publisher := outbox.PublisherFunc(func(ctx context.Context, msg *outbox.Message) error {
+		if failing && strings.HasPrefix(string(msg.Payload), "1-") {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, string(msg.Payload))
+		return nil
+	})
+	n, err := ob.Relay(ctx, publisher)
+	testingutil.AssertNil(t, err, "relay failing")
+	testingutil.AssertEquals(t, 1, n, "relayed while key 1 failing")
+	testingutil.AssertEquals(t, "2-created", strings.Join(published, ","), "other keys published")
+
+	failing = false
+	n, err = ob.Relay(ctx, publisher)
+	testingutil.AssertNil(t, err, "relay before retry interval")
+	testingutil.AssertEquals(t, 0, n, "later messages of key wait for retry")
+
+	time.Sleep(30 * time.Millisecond)
+	n, err = ob.Relay(ctx, publisher)
+	testingutil.AssertNil(t, err, "relay after retry interval")
+	testingutil.AssertEquals(t, 2, n, "relayed after retry interval")
+	testingutil.AssertEquals(t, "2-created,1-created,1-paid", strings.Join(published, ","), "published in key order")
+
+	testingutil.AssertNil(t, ob.Add(ctx, db, &outbox.Message{Topic: "orders", Payload: []byte("dropped")}), "add failing")
+	failing = true
+	dropping := outbox.PublisherFunc(func(ctx context.Context, msg *outbox.Message) error {
+		return errors.New("rejected")
+	})
+	_, err = ob.Relay(ctx, dropping)
+	testingutil.AssertNil(t, err, "relay first attempt")
+	time.Sleep(30 * time.Millisecond)
+	_, err = ob.Relay(ctx, dropping)
+	testingutil.AssertNil(t, err, "relay last attempt")
+	failed, err := ob.Failed(ctx, 10)
+	testingutil.AssertNil(t, err, "failed")
+	testingutil.AssertEquals(t, 1, len(failed), "failed messages")
+	testingutil.AssertEquals(t, 2, failed[0].Attempts, "failed attempts")
+	testingutil.AssertEquals(t, "rejected", failed[0].LastError, "failed error")
+
+	testingutil.AssertNil(t, ob.Retry(ctx, failed[0].ID), "retry failed")
+	n, err = ob.Relay(ctx, publisher)
+	testingutil.AssertNil(t, err, "relay retried")
+	testingutil.AssertEquals(t, 1, n, "relayed retried")
+}
+
+func TestOutboxKafkaRelay(t *testing.T) {
+	broker := newFakeKafkaBroker(t, 1)
+	broker.createTopics("orders")
+	producer := kafka.NewProducer(broker.addr(), 0)
+	defer producer.Close()
+
+	db, ob := newTestingOutbox(t)
+	testingutil.AssertNil(t, ob.Add(context.Background(), db, &outbox.Message{Topic: "orders", Key: "1", Payload: []byte("created")}), "add")
+
+	relayed := make(chan struct{}, 1)
+	publisher := outbox.KafkaPublisher(producer)
+	s := scheduler.NewScheduler()
+	err := ob.Schedule(s, "outbox-relay", 10*time.Millisecond, outbox.PublisherFunc(func(ctx context.Context, msg *outbox.Message) error {
+		err := publisher.Publish(ctx, msg)
+		if nil == err {
+			relayed <- struct{}{}
+		}
+		return err
+	}))
+	testingutil.AssertNil(t, err, "schedule relay")
+	s.Start()
+	defer s.Stop()
+	<-relayed
+
+	records := broker.records("orders")
+	testingutil.AssertEquals(t, 1, len(records), "kafka records")
+	testingutil.AssertEquals(t, "1", string(records[0].Key), "record key")
+	testingutil.AssertEquals(t, "created", string(records[0].Value), "record value")
+}
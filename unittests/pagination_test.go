@@ -0,0 +1,94 @@
+package unittests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+)
+
+type paginatedItem struct {
+	ID int `json:"id"`
+}
+
+// servePaginatedItems serves the items 0 to total-1 in all the pagination styles
+func servePaginatedItems(total int) *httptest.Server {
+	items := func(from int, size int) []paginatedItem {
+		page := []paginatedItem{}
+		for i := from; i < from+size && i < total; i++ {
+			page = append(page, paginatedItem{ID: i})
+		}
+		return page
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pages", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"items": items((page-1)*size, size)}})
+	})
+	mux.HandleFunc("/offsets", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items(offset, limit), "total": total})
+	})
+	mux.HandleFunc("/cursors", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		next := ""
+		if from+2 < total {
+			next = strconv.Itoa(from + 2)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items(from, 2), "next": next})
+	})
+	mux.HandleFunc("/links", func(w http.ResponseWriter, r *http.Request) {
+		from, _ := strconv.Atoi(r.URL.Query().Get("from"))
+		if from+2 < total {
+			w.Header().Set("Link", fmt.Sprintf(`</links?from=%d>; rel="next", </links?from=0>; rel="first"`, from+2))
+		}
+		json.NewEncoder(w).Encode(items(from, 2))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHTTPGetAllPages(t *testing.T) {
+	server := servePaginatedItems(5)
+	defer server.Close()
+
+	specs := map[string]httpclient.PaginationSpec{
+		"/pages":   {Style: httpclient.PaginationPageSize, PageSize: 2, ItemsPath: "data.items"},
+		"/offsets": {Style: httpclient.PaginationOffsetLimit, PageSize: 5, ItemsPath: "items", TotalPath: "total"},
+		"/cursors": {Style: httpclient.PaginationCursor, ItemsPath: "items", NextCursorPath: "next"},
+		"/links":   {Style: httpclient.PaginationLinkHeader},
+	}
+	for path, spec := range specs {
+		items := []paginatedItem{}
+		err := httpclient.HTTPGetAllPages(server.URL+path, nil, spec, &items)
+		testingutil.AssertNil(t, err, path+" HTTPGetAllPages")
+		testingutil.AssertEquals(t, "[{0} {1} {2} {3} {4}]", fmt.Sprint(items), path+" items")
+	}
+
+	items := []paginatedItem{}
+	err := httpclient.HTTPGetAllPages(server.URL+"/pages", nil, httpclient.PaginationSpec{PageSize: 2, ItemsPath: "data.items", MaxPages: 2}, &items)
+	testingutil.AssertEquals(t, httpclient.ErrPaginationLimitExceeded, err, "max pages exceeded")
+	testingutil.AssertEquals(t, 4, len(items), "items in max pages")
+
+	items = []paginatedItem{}
+	err = httpclient.HTTPGetAllPages(server.URL+"/cursors", nil, httpclient.PaginationSpec{Style: httpclient.PaginationCursor, ItemsPath: "items", NextCursorPath: "next", MaxItems: 3}, &items)
+	testingutil.AssertEquals(t, httpclient.ErrPaginationLimitExceeded, err, "max items exceeded")
+	testingutil.AssertEquals(t, "[{0} {1} {2}]", fmt.Sprint(items), "items in max items")
+
+	pages := []int{}
+	err = httpclient.HTTPEachPage(server.URL+"/links", nil, httpclient.PaginationSpec{Style: httpclient.PaginationLinkHeader}, func(page *httpclient.Page) error {
+		pages = append(pages, page.Number)
+		if 2 == page.Number {
+			return httpclient.ErrStopPagination
+		}
+		return nil
+	})
+	testingutil.AssertNil(t, err, "stopped by callback")
+	testingutil.AssertEquals(t, "[1 2]", fmt.Sprint(pages), "pages before stopped")
+}
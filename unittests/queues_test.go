@@ -1,8 +1,12 @@
 package unittests
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/libpub/golib/definations"
 	"github.com/libpub/golib/queues"
@@ -143,3 +147,209 @@ func TestQueuesFindElements(t *testing.T) {
 
 	fmt.Println("Testing queue find elements finished")
 }
+
+func TestOrderedQueueIndexByID(t *testing.T) {
+	queue := queues.NewAscOrderingQueue()
+	for i := 0; i < 20; i++ {
+		queue.Push(&demoElement{val: fmt.Sprintf("same-%d", i), ordering: 5})
+	}
+	queue.Push(&demoElement{val: "first", ordering: 1})
+	queue.Push(&demoElement{val: "last", ordering: 9})
+	testingutil.AssertEquals(t, 22, queue.GetSize(), "queue size")
+
+	for _, i := range []int{0, 10, 19} {
+		ID := fmt.Sprintf("same-%d", i)
+		e, ok := queue.GetElement(ID)
+		testingutil.AssertTrue(t, ok, "GetElement "+ID)
+		testingutil.AssertTrue(t, queue.Remove(e.(queues.IElement)), "Remove element among the equal ordering values "+ID)
+		_, ok = queue.GetOne(&demoElement{val: ID})
+		testingutil.AssertTrue(t, !ok, "GetOne removed "+ID)
+	}
+	testingutil.AssertEquals(t, 19, queue.GetSize(), "queue size after removed")
+	testingutil.AssertTrue(t, !queue.Remove(&demoElement{val: "same-10", ordering: 5}), "Remove twice")
+
+	queue.Push(&demoElement{val: "first", ordering: 10})
+	testingutil.AssertEquals(t, 19, queue.GetSize(), "element of the same id replaced")
+	e, _ := queue.First()
+	testingutil.AssertEquals(t, "same-1", e.(queues.IElement).GetID(), "replaced element reordered")
+
+	moved := &demoElement{val: "moved", ordering: 3}
+	queue.Push(moved)
+	moved.ordering = 100
+	testingutil.AssertTrue(t, queue.Remove(moved), "Remove element of which the ordering value changed")
+	items, n := queue.PopMany(100)
+	testingutil.AssertEquals(t, 19, n, "popped elements")
+	testingutil.AssertEquals(t, "first", items[n-1].(queues.IElement).GetID(), "last element")
+	_, ok := queue.GetElement("first")
+	testingutil.AssertTrue(t, !ok, "popped elements unindexed")
+}
+
+// snapshotElement element of exported fields for snapshots
+type snapshotElement struct {
+	ID       string
+	Ordering int64
+}
+
+func (e *snapshotElement) GetID() string        { return e.ID }
+func (e *snapshotElement) GetName() string      { return e.ID }
+func (e *snapshotElement) OrderingValue() int64 { return e.Ordering }
+func (e *snapshotElement) DebugString() string  { return e.ID }
+
+func TestQueuesSnapshot(t *testing.T) {
+	factory := func() queues.IElement { return &snapshotElement{} }
+	for _, format := range []queues.SnapshotFormat{queues.SnapshotJSON, queues.SnapshotGob} {
+		ordered := queues.NewDescOrderingQueue()
+		fifo := queues.NewFIFOQueue()
+		for _, e := range []*snapshotElement{{ID: "b", Ordering: 2}, {ID: "c", Ordering: 3}, {ID: "a", Ordering: 1}} {
+			ordered.Push(e)
+			fifo.Push(e)
+		}
+		data, err := ordered.Marshal(format)
+		testingutil.AssertNil(t, err, "ordered.Marshal "+string(format))
+		restored := queues.NewDescOrderingQueue()
+		restored.Push(&snapshotElement{ID: "stale", Ordering: 9})
+		testingutil.AssertNil(t, restored.Unmarshal(data, format, factory), "ordered.Unmarshal "+string(format))
+		testingutil.AssertEquals(t, "c b a", queueIDs(restored.Elements()), "restored ordered elements "+string(format))
+		_, ok := restored.GetElement("b")
+		testingutil.AssertTrue(t, ok, "restored element indexed "+string(format))
+
+		data, err = fifo.Marshal(format)
+		testingutil.AssertNil(t, err, "fifo.Marshal "+string(format))
+		restoredFIFO := queues.NewFIFOQueue()
+		testingutil.AssertNil(t, restoredFIFO.Unmarshal(data, format, factory), "fifo.Unmarshal "+string(format))
+		testingutil.AssertEquals(t, "b c a", queueIDs(restoredFIFO.Elements()), "restored fifo elements "+string(format))
+	}
+	_, err := queues.NewFIFOQueue().Marshal("xml")
+	testingutil.AssertErrorIs(t, err, queues.ErrUnknownSnapshotFormat, "unknown format")
+	err = queues.NewFIFOQueue().Unmarshal([]byte("[1]"), queues.SnapshotJSON, factory)
+	testingutil.AssertNotNil(t, err, "invalid element")
+}
+
+func queueIDs(elements []queues.IElement) string {
+	IDs := []string{}
+	for _, e := range elements {
+		IDs = append(IDs, e.GetID())
+	}
+	return strings.Join(IDs, " ")
+}
+
+// expiringElement element expiring at expireAt
+type expiringElement struct {
+	snapshotElement
+	expireAt time.Time
+}
+
+func (e *expiringElement) ExpireAt() time.Time { return e.expireAt }
+
+func TestQueuesExpirySweeper(t *testing.T) {
+	queue := queues.NewAscOrderingQueue()
+	now := time.Now()
+	queue.Push(&expiringElement{snapshotElement: snapshotElement{ID: "expired", Ordering: 1}, expireAt: now.Add(-time.Second)})
+	queue.Push(&expiringElement{snapshotElement: snapshotElement{ID: "alive", Ordering: 2}, expireAt: now.Add(time.Hour)})
+	queue.Push(&expiringElement{snapshotElement: snapshotElement{ID: "forever", Ordering: 3}})
+	queue.Push(&snapshotElement{ID: "plain", Ordering: 4})
+
+	expired := make(chan string, 10)
+	sweeper := queues.NewExpirySweeper(queue, time.Millisecond, func(e queues.IElement) { expired <- e.GetID() })
+	sweeper.Start()
+	defer sweeper.Stop()
+	testingutil.AssertEquals(t, "expired", <-expired, "evicted by sweeper")
+	testingutil.AssertEquals(t, "alive forever plain", queueIDs(queue.Elements()), "unexpired elements kept")
+
+	queue.Push(&expiringElement{snapshotElement: snapshotElement{ID: "later", Ordering: 5}, expireAt: time.Now()})
+	testingutil.AssertEquals(t, "later", <-expired, "element expired after pushed")
+	testingutil.AssertEquals(t, 0, sweeper.Sweep(), "nothing to sweep")
+}
+
+func TestRingQueue(t *testing.T) {
+	var _ queues.IQueue = queues.NewRingQueue(1, queues.RingOverwriteOldest)
+	ring := queues.NewRingQueue(3, queues.RingOverwriteOldest)
+	for _, ID := range []string{"a", "b", "c", "d", "e"} {
+		testingutil.AssertTrue(t, ring.Push(&snapshotElement{ID: ID}), "push "+ID)
+	}
+	testingutil.AssertEquals(t, "c d e", queueIDs(ring.Elements()), "oldest overwritten")
+	testingutil.AssertEquals(t, uint64(2), ring.Dropped(), "overwritten count")
+	testingutil.AssertTrue(t, ring.Remove(&snapshotElement{ID: "d"}), "remove d")
+	testingutil.AssertTrue(t, ring.Push(&snapshotElement{ID: "f"}), "push f")
+	testingutil.AssertEquals(t, "c e f", queueIDs(ring.Elements()), "wrapped elements after removed")
+	e, ok := ring.GetElement("f")
+	testingutil.AssertTrue(t, ok, "GetElement f")
+	testingutil.AssertEquals(t, "f", e.(queues.IElement).GetID(), "element f")
+	items, n := ring.PopMany(2)
+	testingutil.AssertEquals(t, 2, n, "popped")
+	testingutil.AssertEquals(t, "c", items[0].(queues.IElement).GetID(), "popped first")
+	testingutil.AssertEquals(t, 1, ring.GetSize(), "size after popped")
+
+	reject := queues.NewRingQueue(2, queues.RingRejectNewest)
+	testingutil.AssertTrue(t, reject.Push(&snapshotElement{ID: "a"}), "push a")
+	testingutil.AssertTrue(t, reject.Push(&snapshotElement{ID: "b"}), "push b")
+	testingutil.AssertTrue(t, !reject.Push(&snapshotElement{ID: "c"}), "rejected when full")
+	testingutil.AssertEquals(t, "a b", queueIDs(reject.Elements()), "newest rejected")
+	testingutil.AssertEquals(t, "b", queueIDs(reject.CutAfter(0)), "CutAfter")
+	testingutil.AssertEquals(t, "a", queueIDs(reject.CutBefore(1)), "CutBefore")
+	_, ok = reject.Pop()
+	testingutil.AssertTrue(t, !ok, "empty after cut")
+
+	element := &snapshotElement{ID: "x"}
+	allocs := testing.AllocsPerRun(100, func() {
+		ring.Push(element)
+	})
+	testingutil.AssertEquals(t, float64(0), allocs, "no allocation per push")
+}
+
+func TestPubSub(t *testing.T) {
+	pubsub := queues.NewPubSub()
+	ctx := context.Background()
+	received := make(chan string, 10)
+	first, err := pubsub.Subscribe("orders", func(msg *queues.Message) { received <- "first:" + msg.Payload.(string) })
+	testingutil.AssertNil(t, err, "subscribe first")
+	_, err = pubsub.Subscribe("orders", func(msg *queues.Message) { received <- "second:" + msg.Payload.(string) })
+	testingutil.AssertNil(t, err, "subscribe second")
+	_, err = pubsub.Subscribe("orders", func(msg *queues.Message) { panic("handler panic") })
+	testingutil.AssertNil(t, err, "subscribe panicking")
+
+	n, err := pubsub.Publish(ctx, "orders", "o-1")
+	testingutil.AssertNil(t, err, "publish")
+	testingutil.AssertEquals(t, 3, n, "buffered by subscribers")
+	got := []string{<-received, <-received}
+	sort.Strings(got)
+	testingutil.AssertEquals(t, "first:o-1 second:o-1", strings.Join(got, " "), "fan out")
+	n, _ = pubsub.Publish(ctx, "users", "u-1")
+	testingutil.AssertEquals(t, 0, n, "no subscribers of topic")
+
+	first.Unsubscribe()
+	n, _ = pubsub.Publish(ctx, "orders", "o-2")
+	testingutil.AssertEquals(t, 2, n, "unsubscribed")
+	testingutil.AssertEquals(t, "second:o-2", <-received, "delivered to the rest")
+
+	release := make(chan struct{})
+	blocked := make(chan string, 10)
+	slow, _ := pubsub.Subscribe("slow", func(msg *queues.Message) {
+		<-release
+		blocked <- msg.Payload.(string)
+	}, queues.WithBufferSize(1))
+	latest, _ := pubsub.Subscribe("slow", func(msg *queues.Message) {}, queues.WithBufferSize(1), queues.WithSlowSubscriberPolicy(queues.SlowSubscriberDropOldest))
+	for i := 0; i < 5; i++ {
+		pubsub.Publish(ctx, "slow", fmt.Sprint(i))
+	}
+	testingutil.AssertTrue(t, slow.Dropped() >= 3, "dropped by slow subscriber")
+	testingutil.AssertTrue(t, latest.Dropped() <= 4, "overwritten by slow subscriber")
+
+	waiting, _ := pubsub.Subscribe("block", func(msg *queues.Message) {
+		<-release
+		blocked <- msg.Payload.(string)
+	}, queues.WithBufferSize(1), queues.WithSlowSubscriberPolicy(queues.SlowSubscriberBlock))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	var publishErr error
+	for i := 0; i < 3 && nil == publishErr; i++ {
+		_, publishErr = pubsub.Publish(timeoutCtx, "block", "b")
+	}
+	testingutil.AssertErrorIs(t, publishErr, context.DeadlineExceeded, "publishing blocked by slow subscriber")
+	testingutil.AssertEquals(t, uint64(0), waiting.Dropped(), "nothing dropped by blocking")
+
+	close(release)
+	testingutil.AssertNil(t, pubsub.Close(ctx), "Close")
+	_, err = pubsub.Publish(ctx, "orders", "o-3")
+	testingutil.AssertErrorIs(t, err, queues.ErrPubSubClosed, "publish after closed")
+}
@@ -0,0 +1,220 @@
+package unittests
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/ratelimit"
+	"github.com/libpub/golib/testingutil"
+)
+
+// fakeClock the time of limiters moved by tests
+type fakeClock struct {
+	m   sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.m.Lock()
+	c.now = c.now.Add(d)
+	c.m.Unlock()
+}
+
+func takeRateLimit(t *testing.T, l ratelimit.Limiter, key string) (bool, time.Duration) {
+	ok, retryAfter, err := l.Take(context.Background(), key)
+	testingutil.AssertNil(t, err, "take "+key)
+	return ok, retryAfter
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	b := ratelimit.NewTokenBucket(ratelimit.Config{Limit: 2, Interval: time.Second, Burst: 3, Now: clock.Now})
+	for i := 0; i < 3; i++ {
+		ok, _ := takeRateLimit(t, b, "a")
+		testingutil.AssertTrue(t, ok, "burst of a")
+	}
+	ok, retryAfter := takeRateLimit(t, b, "a")
+	testingutil.AssertFalse(t, ok, "a exhausted")
+	testingutil.AssertTrue(t, retryAfter > 499*time.Millisecond && retryAfter <= 501*time.Millisecond, "retry after a token refilled")
+	ok, _ = takeRateLimit(t, b, "b")
+	testingutil.AssertTrue(t, ok, "keys limited separately")
+
+	clock.Add(500 * time.Millisecond)
+	ok, _ = takeRateLimit(t, b, "a")
+	testingutil.AssertTrue(t, ok, "a refilled")
+	ok, _ = takeRateLimit(t, b, "a")
+	testingutil.AssertFalse(t, ok, "a exhausted again")
+
+	// the buckets refilled fully are removed
+	testingutil.AssertEquals(t, 2, b.Len(), "keys")
+	clock.Add(1500 * time.Millisecond)
+	takeRateLimit(t, b, "c")
+	testingutil.AssertEquals(t, 1, b.Len(), "keys after expired")
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	w := ratelimit.NewSlidingWindow(ratelimit.Config{Limit: 4, Interval: time.Second, Now: clock.Now})
+	for i := 0; i < 4; i++ {
+		ok, _ := takeRateLimit(t, w, "a")
+		testingutil.AssertTrue(t, ok, "limit of a")
+	}
+	ok, retryAfter := takeRateLimit(t, w, "a")
+	testingutil.AssertFalse(t, ok, "a exhausted")
+	// all 4 events of the current window slide out after a window
+	testingutil.AssertTrue(t, retryAfter > time.Second-time.Millisecond && retryAfter <= time.Second+time.Millisecond, "retry after the next window")
+
+	// 80% of the previous window overlaps the sliding window
+	clock.Add(1200 * time.Millisecond)
+	ok, _ = takeRateLimit(t, w, "a")
+	testingutil.AssertTrue(t, ok, "a allowed by weighted count 3.2")
+	ok, retryAfter = takeRateLimit(t, w, "a")
+	testingutil.AssertFalse(t, ok, "a exhausted by weighted count 4.2")
+	testingutil.AssertTrue(t, retryAfter > 49*time.Millisecond && retryAfter <= 51*time.Millisecond, "retry after the previous events slide out")
+
+	clock.Add(3 * time.Second)
+	takeRateLimit(t, w, "b")
+	testingutil.AssertEquals(t, 1, w.Len(), "keys after expired")
+}
+
+func TestRateLimitWait(t *testing.T) {
+	b := ratelimit.NewTokenBucket(ratelimit.Config{Limit: 1, Interval: 20 * time.Millisecond})
+	testingutil.AssertTrue(t, ratelimit.Allow(context.Background(), b, "a"), "allow")
+	testingutil.AssertFalse(t, ratelimit.Allow(context.Background(), b, "a"), "not allowed")
+	testingutil.AssertNil(t, ratelimit.Wait(context.Background(), b, "a"), "wait until refilled")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	testingutil.AssertTrue(t, ratelimit.ErrLimitExceeded == ratelimit.Wait(ctx, b, "a"), "not allowed before deadline")
+}
+
+// serveFakeRedisRateLimit runs the sliding window script of ratelimit by the counters in memory
+func serveFakeRedisRateLimit(t *testing.T) (string, map[string]int64) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	counters := map[string]int64{}
+	m := sync.Mutex{}
+	eval := func(keys []string, args []string) []interface{} {
+		m.Lock()
+		defer m.Unlock()
+		limit, _ := strconv.ParseFloat(args[0], 64)
+		weight, _ := strconv.ParseFloat(args[1], 64)
+		current, previous := counters[keys[0]], counters[keys[1]]
+		if float64(previous)*weight+float64(current) >= limit {
+			return []interface{}{int64(0), previous, current}
+		}
+		counters[keys[0]]++
+		return []interface{}{int64(1), previous, counters[keys[0]]}
+	}
+	conns := []net.Conn{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			m.Lock()
+			conns = append(conns, conn)
+			m.Unlock()
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					args := []string{}
+					for i := 0; i < n; i++ {
+						header, err := r.ReadString('\n')
+						if nil != err {
+							return
+						}
+						size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+						buf := make([]byte, size+2)
+						if _, err := io.ReadFull(r, buf); nil != err {
+							return
+						}
+						args = append(args, string(buf[:size]))
+					}
+					switch strings.ToUpper(args[0]) {
+					case "EVALSHA":
+						conn.Write([]byte("-NOSCRIPT No matching script\r\n"))
+					case "EVAL":
+						numKeys, _ := strconv.Atoi(args[2])
+						conn.Write([]byte(fakeRESP(eval(args[3:3+numKeys], args[3+numKeys:]))))
+					default:
+						conn.Write([]byte("+OK\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		m.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		m.Unlock()
+	})
+	return l.Addr().String(), counters
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	addr, counters := serveFakeRedisRateLimit(t)
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: 0})
+	defer client.Close()
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	config := ratelimit.Config{Limit: 2, Interval: time.Second, Now: clock.Now}
+	// the instances share the limit
+	a := ratelimit.NewRedisSlidingWindow(client, "", config)
+	b := ratelimit.NewRedisSlidingWindow(client, "", config)
+	ok, _ := takeRateLimit(t, a, "k")
+	testingutil.AssertTrue(t, ok, "first of instance a")
+	ok, _ = takeRateLimit(t, b, "k")
+	testingutil.AssertTrue(t, ok, "second of instance b")
+	ok, retryAfter := takeRateLimit(t, a, "k")
+	testingutil.AssertFalse(t, ok, "limit shared")
+	testingutil.AssertTrue(t, retryAfter > 0, "retry after")
+	testingutil.AssertEquals(t, int64(2), counters["ratelimit:{k}:1700000000"], "window counter")
+
+	clock.Add(1500 * time.Millisecond)
+	ok, _ = takeRateLimit(t, b, "k")
+	testingutil.AssertTrue(t, ok, "allowed by weighted count 1")
+	ok, _ = takeRateLimit(t, a, "k")
+	testingutil.AssertFalse(t, ok, "exhausted by weighted count 2")
+}
+
+func TestHTTPQueryRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	limiter := ratelimit.NewTokenBucket(ratelimit.Config{Limit: 1, Interval: time.Hour})
+	resp, err := httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithRateLimiter(limiter), httpclient.WithTimeout(1))
+	testingutil.AssertNil(t, err, "first query")
+	testingutil.AssertEquals(t, "ok", string(resp), "first response")
+	_, err = httpclient.HTTPQuery("GET", server.URL, nil, httpclient.WithRateLimiter(limiter), httpclient.WithTimeout(1))
+	testingutil.AssertTrue(t, ratelimit.ErrLimitExceeded == err, "query rate limited")
+}
@@ -0,0 +1,159 @@
+package unittests
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/libpub/golib/caching/cachingenv"
+	"github.com/libpub/golib/netutils/redisoptions"
+	"github.com/libpub/golib/testingutil"
+)
+
+func TestRedisOptionsURL(t *testing.T) {
+	opts, err := redisoptions.NewRedisOptionsWithURL("redis://:secret@10.0.0.1/3?pool_size=20&dial_timeout=2s&read_timeout=500")
+	testingutil.AssertNil(t, err, "parse redis url error")
+	testingutil.AssertEquals(t, redisoptions.ModeStandalone, opts.Mode, "redis mode")
+	testingutil.AssertEquals(t, "10.0.0.1:6379", opts.Addrs[0], "redis default port")
+	testingutil.AssertEquals(t, "secret", opts.Password, "redis password")
+	testingutil.AssertEquals(t, 3, opts.DB, "redis db")
+	testingutil.AssertEquals(t, 20, opts.PoolSize, "redis pool_size")
+	testingutil.AssertEquals(t, 2000, opts.DialTimeout, "redis dial_timeout duration")
+	testingutil.AssertEquals(t, 500, opts.ReadTimeout, "redis read_timeout millis")
+	testingutil.AssertEquals(t, "redis://:****@10.0.0.1:6379/3?pool_size=20", opts.Redacted(), "redis Redacted")
+
+	opts, err = redisoptions.NewRedisOptionsWithURL("rediss://secret@cache.local:6380?skip_verify=true")
+	testingutil.AssertNil(t, err, "parse rediss url error")
+	testingutil.AssertTrue(t, opts.TLS.Enabled, "rediss tls enabled")
+	testingutil.AssertTrue(t, opts.TLS.SkipVerify, "rediss skip verify")
+	testingutil.AssertEquals(t, "secret", opts.Password, "rediss password without colon")
+	tlsConfig, err := opts.TLSConfig()
+	testingutil.AssertNil(t, err, "rediss TLSConfig error")
+	testingutil.AssertEquals(t, "cache.local", tlsConfig.ServerName, "rediss tls server name")
+
+	opts, err = redisoptions.NewRedisOptionsWithURL("redis-sentinel://:pw@s1,s2:26380/1?master=mymaster")
+	testingutil.AssertNil(t, err, "parse sentinel url error")
+	testingutil.AssertEquals(t, redisoptions.ModeSentinel, opts.Mode, "sentinel mode")
+	testingutil.AssertEquals(t, "s1:26379,s2:26380", strings.Join(opts.Addrs, ","), "sentinel addrs")
+	testingutil.AssertEquals(t, "mymaster", opts.MasterName, "sentinel master")
+	testingutil.AssertEquals(t, "redis-sentinel://:****@s1:26379,s2:26380/1?master=mymaster", opts.Redacted(), "sentinel Redacted")
+
+	_, err = redisoptions.NewRedisOptionsWithURL("redis-sentinel://s1,s2")
+	testingutil.AssertTrue(t, err == redisoptions.ErrNoMasterName, "sentinel without master")
+
+	opts, err = redisoptions.NewRedisOptionsWithURL("redis-cluster://n1:7000,n2:7001,[::1]:7002?read_only=true")
+	testingutil.AssertNil(t, err, "parse cluster url error")
+	testingutil.AssertEquals(t, redisoptions.ModeCluster, opts.Mode, "cluster mode")
+	testingutil.AssertEquals(t, 3, len(opts.Addrs), "cluster addrs")
+	testingutil.AssertEquals(t, "[::1]:7002", opts.Addrs[2], "cluster ipv6 addr")
+	testingutil.AssertTrue(t, opts.ReadOnly, "cluster read_only")
+	client, err := opts.NewClient()
+	testingutil.AssertNil(t, err, "cluster NewClient error")
+	_, isCluster := client.(*redis.ClusterClient)
+	testingutil.AssertTrue(t, isCluster, "cluster client type")
+	client.Close()
+
+	_, err = redisoptions.NewRedisOptionsWithURL("http://localhost")
+	testingutil.AssertNotNil(t, err, "unsupported scheme")
+	_, err = redisoptions.NewRedisOptionsWithURL("redis://localhost/x")
+	testingutil.AssertNotNil(t, err, "invalid db")
+	_, err = redisoptions.NewRedisOptionsWithURL("redis://localhost?pool_size=x")
+	testingutil.AssertNotNil(t, err, "invalid option")
+
+	opts = redisoptions.NewRedisOptionsWithCacheConfig(cachingenv.CacheConnectorConfig{Host: "c1,c2", Port: 7000, ClusterMode: true})
+	testingutil.AssertEquals(t, redisoptions.ModeCluster, opts.Mode, "cache config cluster mode")
+	testingutil.AssertEquals(t, "c1:7000,c2:7000", strings.Join(opts.Addrs, ","), "cache config addrs")
+}
+
+// serveFakeRedis answers PING with PONG and other commands with OK
+func serveFakeRedis(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	conns := []net.Conn{}
+	m := sync.Mutex{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			m.Lock()
+			conns = append(conns, conn)
+			m.Unlock()
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if nil != err {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					args := []string{}
+					for i := 0; i < n; i++ {
+						r.ReadString('\n')
+						arg, _ := r.ReadString('\n')
+						args = append(args, strings.TrimSpace(arg))
+					}
+					if len(args) > 0 && "PING" == strings.ToUpper(args[0]) {
+						conn.Write([]byte("+PONG\r\n"))
+					} else {
+						conn.Write([]byte("+OK\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+	return l.Addr().String(), func() {
+		l.Close()
+		m.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		m.Unlock()
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	addr, stop := serveFakeRedis(t)
+	opts, err := redisoptions.NewRedisOptionsWithURL("redis://:pw@" + addr + "/2?dial_timeout=200&max_retries=0")
+	testingutil.AssertNil(t, err, "parse url error")
+
+	clients := make([]*redisoptions.RedisClient, 4)
+	wg := sync.WaitGroup{}
+	for i := range clients {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			clients[i], _ = redisoptions.InitRedisClient("testredis", opts)
+		}(i)
+	}
+	wg.Wait()
+	client := redisoptions.GetRedisClient("testredis")
+	testingutil.AssertTrue(t, nil != client, "GetRedisClient")
+	for _, c := range clients {
+		testingutil.AssertTrue(t, c == client, "InitRedisClient concurrently returns the same client")
+	}
+	testingutil.AssertTrue(t, client.Healthy(), "client healthy")
+	testingutil.AssertNil(t, redisoptions.PingAllRedisClients()["testredis"], "PingAllRedisClients")
+	testingutil.AssertTrue(t, client.PoolStats().TotalConns > 0, "PoolStats")
+
+	client.StartHealthCheck(10 * time.Millisecond)
+	stop()
+	time.Sleep(50 * time.Millisecond)
+	testingutil.AssertFalse(t, client.Healthy(), "client unhealthy after server stopped")
+
+	testingutil.AssertNil(t, redisoptions.CloseRedisClient("testredis"), "CloseRedisClient")
+	testingutil.AssertTrue(t, nil == redisoptions.GetRedisClient("testredis"), "GetRedisClient after closed")
+
+	_, err = redisoptions.InitRedisClient("badredis", opts)
+	testingutil.AssertNotNil(t, err, "InitRedisClient unreachable redis")
+	testingutil.AssertTrue(t, nil == redisoptions.GetRedisClient("badredis"), "GetRedisClient of failed client")
+}
@@ -0,0 +1,493 @@
+package unittests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/mq/mqenv"
+	"github.com/libpub/golib/mq/redisstream"
+	"github.com/libpub/golib/testingutil"
+)
+
+// fakeStreamEntry an entry of the fake redis stream
+type fakeStreamEntry struct {
+	id     string
+	fields []string
+}
+
+// fakeStreamPending a delivered but not acked entry of the consumer group
+type fakeStreamPending struct {
+	consumer   string
+	delivered  time.Time
+	deliveries int64
+}
+
+// fakeStreamGroup a consumer group of the fake redis stream
+type fakeStreamGroup struct {
+	next    int // index of the next entry delivered by >
+	pending map[string]*fakeStreamPending
+}
+
+// fakeRedisStreams serves the stream commands used by redisstream in memory
+type fakeRedisStreams struct {
+	listener  net.Listener
+	m         sync.Mutex
+	seq       int64
+	streams   map[string][]fakeStreamEntry
+	groups    map[string]map[string]*fakeStreamGroup
+	destroyed []string
+	changed   chan struct{}
+}
+
+func newFakeRedisStreams(t *testing.T) *fakeRedisStreams {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen fake redis failed with error:%v", err)
+	}
+	s := &fakeRedisStreams{
+		listener: l,
+		streams:  map[string][]fakeStreamEntry{},
+		groups:   map[string]map[string]*fakeStreamGroup{},
+		changed:  make(chan struct{}),
+	}
+	conns := []net.Conn{}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if nil != err {
+				return
+			}
+			s.m.Lock()
+			conns = append(conns, conn)
+			s.m.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		s.m.Lock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+		s.m.Unlock()
+	})
+	return s
+}
+
+func (s *fakeRedisStreams) connConfig() *mqenv.MQConnectorConfig {
+	return &mqenv.MQConnectorConfig{Driver: mqenv.DriverTypeRedisStream, Host: s.listener.Addr().String(), Timeout: 5}
+}
+
+func (s *fakeRedisStreams) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if nil != err {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := []string{}
+		for i := 0; i < n; i++ {
+			header, err := r.ReadString('\n')
+			if nil != err {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); nil != err {
+				return
+			}
+			args = append(args, string(buf[:size]))
+		}
+		conn.Write([]byte(fakeRESP(s.handle(args))))
+	}
+}
+
+// fakeRESP encodes the reply, string is bulk string, error is error reply and nil is null array
+func fakeRESP(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "*-1\r\n"
+	case error:
+		return "-" + v.Error() + "\r\n"
+	case int64:
+		return fmt.Sprintf(":%d\r\n", v)
+	case string:
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case []interface{}:
+		b := strings.Builder{}
+		b.WriteString(fmt.Sprintf("*%d\r\n", len(v)))
+		for _, item := range v {
+			b.WriteString(fakeRESP(item))
+		}
+		return b.String()
+	}
+	return "+OK\r\n"
+}
+
+func (s *fakeRedisStreams) handle(args []string) interface{} {
+	cmd := strings.ToUpper(args[0])
+	if "XREAD" == cmd || "XREADGROUP" == cmd {
+		return s.read(cmd, args)
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	switch cmd {
+	case "PING":
+		return struct{}{}
+	case "XADD":
+		fields := args[3:]
+		if "MAXLEN" == strings.ToUpper(args[2]) {
+			fields = args[6:]
+		}
+		s.seq++
+		return s.add(args[1], fmt.Sprintf("%d-%d", time.Now().UnixNano()/int64(time.Millisecond), s.seq), fields...)
+	case "XGROUP":
+		switch strings.ToUpper(args[1]) {
+		case "CREATE":
+			if _, ok := s.groups[args[2]][args[3]]; ok {
+				return fmt.Errorf("BUSYGROUP Consumer Group name already exists")
+			}
+			s.createGroup(args[2], args[3], "$" == args[4])
+			return struct{}{}
+		case "DESTROY":
+			delete(s.groups[args[2]], args[3])
+			s.destroyed = append(s.destroyed, args[3])
+			return int64(1)
+		}
+	case "XACK":
+		acked := int64(0)
+		for _, id := range args[3:] {
+			if g, ok := s.groups[args[1]][args[2]]; ok && nil != g.pending[id] {
+				delete(g.pending, id)
+				acked++
+			}
+		}
+		return acked
+	case "XPENDING":
+		g, ok := s.groups[args[1]][args[2]]
+		if !ok {
+			return fmt.Errorf("NOGROUP No such key or consumer group")
+		}
+		if 3 == len(args) {
+			return []interface{}{int64(len(g.pending)), nil, nil, nil}
+		}
+		ids := []string{}
+		for id := range g.pending {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		pendings := []interface{}{}
+		for _, id := range ids {
+			p := g.pending[id]
+			pendings = append(pendings, []interface{}{id, p.consumer, int64(time.Since(p.delivered) / time.Millisecond), p.deliveries})
+		}
+		return pendings
+	case "XCLAIM":
+		g := s.groups[args[1]][args[2]]
+		minIdle, _ := strconv.ParseInt(args[4], 10, 64)
+		claimed := []interface{}{}
+		for _, id := range args[5:] {
+			p := g.pending[id]
+			if nil == p || time.Since(p.delivered) < time.Duration(minIdle)*time.Millisecond {
+				continue
+			}
+			p.consumer, p.delivered = args[3], time.Now()
+			p.deliveries++
+			claimed = append(claimed, s.entries(args[1], func(e fakeStreamEntry) bool { return e.id == id })...)
+		}
+		return claimed
+	case "XRANGE":
+		return s.entries(args[1], func(e fakeStreamEntry) bool { return e.id == args[2] })
+	case "DEL":
+		delete(s.streams, args[1])
+		return int64(1)
+	case "EXPIRE":
+		return int64(1)
+	}
+	return struct{}{}
+}
+
+// add appends the entry to the stream and wakes the blocking reads
+func (s *fakeRedisStreams) add(stream string, id string, fields ...string) string {
+	s.streams[stream] = append(s.streams[stream], fakeStreamEntry{id: id, fields: fields})
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return id
+}
+
+func (s *fakeRedisStreams) createGroup(stream string, group string, latest bool) *fakeStreamGroup {
+	if nil == s.groups[stream] {
+		s.groups[stream] = map[string]*fakeStreamGroup{}
+	}
+	g := &fakeStreamGroup{pending: map[string]*fakeStreamPending{}}
+	if latest {
+		g.next = len(s.streams[stream])
+	}
+	s.groups[stream][group] = g
+	return g
+}
+
+func (s *fakeRedisStreams) entries(stream string, matches func(e fakeStreamEntry) bool) []interface{} {
+	entries := []interface{}{}
+	for _, e := range s.streams[stream] {
+		if matches(e) {
+			fields := []interface{}{}
+			for _, f := range e.fields {
+				fields = append(fields, f)
+			}
+			entries = append(entries, []interface{}{e.id, fields})
+		}
+	}
+	return entries
+}
+
+// read serves XREAD and XREADGROUP of a single stream, the block of XREADGROUP is shortened to 100ms
+// so that the consumers stop promptly
+func (s *fakeRedisStreams) read(cmd string, args []string) interface{} {
+	block := time.Duration(-1)
+	for i, arg := range args {
+		if "BLOCK" == strings.ToUpper(arg) {
+			ms, _ := strconv.ParseInt(args[i+1], 10, 64)
+			block = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if "XREADGROUP" == cmd && block > 100*time.Millisecond {
+		block = 100 * time.Millisecond
+	}
+	stream, start := args[len(args)-2], args[len(args)-1]
+	deadline := time.After(block)
+	for {
+		s.m.Lock()
+		var entries []interface{}
+		if "XREADGROUP" == cmd {
+			g := s.groups[stream][args[2]]
+			if nil == g {
+				s.m.Unlock()
+				return fmt.Errorf("NOGROUP No such key or consumer group")
+			}
+			for ; g.next < len(s.streams[stream]); g.next++ {
+				e := s.streams[stream][g.next]
+				g.pending[e.id] = &fakeStreamPending{consumer: args[3], delivered: time.Now(), deliveries: 1}
+				entries = append(entries, s.entries(stream, func(entry fakeStreamEntry) bool { return entry.id == e.id })...)
+			}
+		} else {
+			entries = s.entries(stream, func(e fakeStreamEntry) bool { return "0" == start })
+		}
+		changed := s.changed
+		s.m.Unlock()
+		if len(entries) > 0 {
+			return []interface{}{[]interface{}{stream, entries}}
+		}
+		if block < 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+// preload adds the entry to the stream as delivered to consumer for idle duration
+func (s *fakeRedisStreams) preload(stream string, group string, id string, idle time.Duration, deliveries int64, fields ...string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	g := s.groups[stream][group]
+	if nil == g {
+		g = s.createGroup(stream, group, false)
+	}
+	s.add(stream, id, fields...)
+	g.next = len(s.streams[stream])
+	g.pending[id] = &fakeStreamPending{consumer: "dead", delivered: time.Now().Add(-idle), deliveries: deliveries}
+}
+
+func (s *fakeRedisStreams) pendingIDs(stream string, group string) string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	ids := []string{}
+	for id := range s.groups[stream][group].pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func (s *fakeRedisStreams) streamIDs(stream string) string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	ids := []string{}
+	for _, e := range s.streams[stream] {
+		ids = append(ids, e.id)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (s *fakeRedisStreams) groupNames(stream string) []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	names := []string{}
+	for name := range s.groups[stream] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func initFakeRedisStreamMQ(t *testing.T, s *fakeRedisStreams, name string, cfg *redisstream.Config) *redisstream.RedisStreamMQ {
+	mq, err := redisstream.InitRedisStreamMQ(name, s.connConfig(), cfg)
+	if nil != err {
+		t.Fatalf("init redis stream mq failed with error:%v", err)
+	}
+	t.Cleanup(func() { mq.Close() })
+	return mq
+}
+
+func receiveStreamMessage(t *testing.T, received chan mqenv.MQConsumerMessage, name string) mqenv.MQConsumerMessage {
+	select {
+	case m := <-received:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s not received", name)
+	}
+	return mqenv.MQConsumerMessage{}
+}
+
+func TestRedisStreamMessageFields(t *testing.T) {
+	s := newFakeRedisStreams(t)
+	mq := initFakeRedisStreamMQ(t, s, "streamtest-fields", &redisstream.Config{Stream: "orders"})
+	received := make(chan mqenv.MQConsumerMessage, 2)
+	err := mq.ConsumeMessage(&mqenv.MQConsumerProxy{
+		ConsumerTag: "tag",
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- m
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "ConsumeMessage")
+
+	before := time.Now().Add(-time.Millisecond)
+	err = mq.PublishMessage(&mqenv.MQPublishMessage{
+		Body:          []byte("hello"),
+		CorrelationID: "cid-1",
+		ReplyTo:       "rpc:test:cid-1",
+		MessageID:     "mid-1",
+		AppID:         "app",
+		UserID:        "user",
+		ContentType:   "application/json",
+		Headers:       map[string]string{"Trace": "t-1"},
+	})
+	testingutil.AssertNil(t, err, "PublishMessage")
+	m := receiveStreamMessage(t, received, "message")
+	testingutil.AssertEquals(t, mqenv.DriverTypeRedisStream, m.Driver, "driver")
+	testingutil.AssertEquals(t, "orders", m.Queue, "queue")
+	testingutil.AssertEquals(t, "tag", m.ConsumerTag, "consumer tag")
+	testingutil.AssertEquals(t, "hello", string(m.Body), "body")
+	testingutil.AssertEquals(t, "cid-1", m.CorrelationID, "correlation id")
+	testingutil.AssertEquals(t, "rpc:test:cid-1", m.ReplyTo, "reply to")
+	testingutil.AssertEquals(t, "mid-1", m.MessageID, "message id")
+	testingutil.AssertEquals(t, "app", m.AppID, "app id")
+	testingutil.AssertEquals(t, "user", m.UserID, "user id")
+	testingutil.AssertEquals(t, "application/json", m.ContentType, "content type")
+	testingutil.AssertEquals(t, "t-1", m.Headers["Trace"], "header")
+	testingutil.AssertFalse(t, m.Timestamp.Before(before), "timestamp of entry id")
+
+	// 没有MessageId 字段时使用stream entry id
+	testingutil.AssertNil(t, mq.PublishMessage(&mqenv.MQPublishMessage{Body: []byte("x")}), "PublishMessage empty message")
+	m = receiveStreamMessage(t, received, "empty message")
+	ids := strings.Split(s.streamIDs("orders"), ",")
+	testingutil.AssertEquals(t, ids[len(ids)-1], m.MessageID, "entry id as message id")
+	testingutil.AssertEquals(t, 0, len(m.Headers), "empty message headers")
+	testingutil.AssertEquals(t, "", m.CorrelationID, "empty message correlation id")
+	waitFor(t, 5*time.Second, func() bool { return "" == s.pendingIDs("orders", "streamtest-fields") }, "acked messages")
+}
+
+func TestRedisStreamQueryMessage(t *testing.T) {
+	s := newFakeRedisStreams(t)
+	mq := initFakeRedisStreamMQ(t, s, "streamtest-query", &redisstream.Config{})
+	err := mq.ConsumeMessage(&mqenv.MQConsumerProxy{
+		Queue: "echo",
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			return &mqenv.MQPublishMessage{Body: append([]byte("re:"), m.Body...)}
+		},
+	})
+	testingutil.AssertNil(t, err, "ConsumeMessage")
+	resp, err := mq.QueryMessage(&mqenv.MQPublishMessage{RoutingKey: "echo", Body: []byte("ping"), CorrelationID: "cid-1", TimeoutSeconds: 5})
+	testingutil.AssertNil(t, err, "QueryMessage")
+	if nil != resp {
+		testingutil.AssertEquals(t, "re:ping", string(resp.Body), "response body")
+		testingutil.AssertEquals(t, "cid-1", resp.CorrelationID, "response correlation id")
+	}
+	testingutil.AssertEquals(t, "", s.streamIDs("rpc:streamtest-query:cid-1"), "reply stream deleted")
+}
+
+func TestRedisStreamClaimPendings(t *testing.T) {
+	s := newFakeRedisStreams(t)
+	s.preload("orders", "group", "1600000000123-1", 2*time.Minute, 1, "body", "claimed")
+	s.preload("orders", "group", "1600000000123-2", 10*time.Second, 9, "body", "busy")
+	s.preload("orders", "group", "1600000000123-3", 2*time.Minute, 3, "body", "dead-3")
+	s.preload("orders", "group", "1600000000123-4", 2*time.Minute, 4, "body", "dead-4")
+
+	mq := initFakeRedisStreamMQ(t, s, "streamtest-claim", &redisstream.Config{Stream: "orders", Group: "group", MaxDeliveries: 3, DeadLetterStream: "orders-dead"})
+	received := make(chan mqenv.MQConsumerMessage, 4)
+	err := mq.ConsumeMessage(&mqenv.MQConsumerProxy{
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- m
+			return nil
+		},
+	})
+	testingutil.AssertNil(t, err, "ConsumeMessage")
+	m := receiveStreamMessage(t, received, "claimed message")
+	testingutil.AssertEquals(t, "claimed", string(m.Body), "claimed message body")
+	testingutil.AssertEquals(t, int64(1600000000123), m.Timestamp.UnixNano()/int64(time.Millisecond), "timestamp of entry id")
+
+	// 空闲未超时的消息保持pending，超过投递次数的消息移入死信stream
+	waitFor(t, 5*time.Second, func() bool { return "1600000000123-2" == s.pendingIDs("orders", "group") }, "pending messages")
+	pending, err := mq.Pending("")
+	testingutil.AssertNil(t, err, "Pending")
+	testingutil.AssertEquals(t, int64(1), pending, "pending count")
+	testingutil.AssertEquals(t, 2, len(strings.Split(s.streamIDs("orders-dead"), ",")), "dead letters")
+	select {
+	case m = <-received:
+		t.Fatalf("message %s delivered again", string(m.Body))
+	default:
+	}
+}
+
+func TestRedisStreamFanoutGroupPerInstance(t *testing.T) {
+	s := newFakeRedisStreams(t)
+	received := make(chan mqenv.MQConsumerMessage, 2)
+	proxy := &mqenv.MQConsumerProxy{
+		Queue: "events",
+		Callback: func(m mqenv.MQConsumerMessage) *mqenv.MQPublishMessage {
+			received <- m
+			return nil
+		},
+	}
+	a := initFakeRedisStreamMQ(t, s, "streamtest-fanout-a", &redisstream.Config{MessageType: "fanout"})
+	b := initFakeRedisStreamMQ(t, s, "streamtest-fanout-b", &redisstream.Config{MessageType: "fanout"})
+	testingutil.AssertNil(t, a.ConsumeMessage(proxy), "ConsumeMessage a")
+	testingutil.AssertNil(t, b.ConsumeMessage(proxy), "ConsumeMessage b")
+	groups := s.groupNames("events")
+	testingutil.AssertEquals(t, 2, len(groups), "fanout groups")
+	testingutil.AssertTrue(t, strings.HasPrefix(groups[0], "streamtest-fanout-a-"), "fanout group prefix")
+
+	testingutil.AssertNil(t, a.PublishMessage(&mqenv.MQPublishMessage{RoutingKey: "events", Body: []byte("e")}), "PublishMessage")
+	receiveStreamMessage(t, received, "message of first instance")
+	receiveStreamMessage(t, received, "message of second instance")
+
+	// 关闭时删除实例的消费者组
+	testingutil.AssertNil(t, a.Close(), "Close a")
+	testingutil.AssertNil(t, b.Close(), "Close b")
+	testingutil.AssertEquals(t, 0, len(s.groupNames("events")), "groups after closed")
+}
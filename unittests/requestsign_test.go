@@ -0,0 +1,62 @@
+package unittests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/cryptoes"
+)
+
+func TestHMAC(t *testing.T) {
+	// RFC 4231 test case 2
+	testingutil.AssertEquals(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		cryptoes.HMACSHA256Hex([]byte("what do ya want for nothing?"), []byte("Jefe")), "cryptoes.HMACSHA256Hex")
+	// RFC 2202 test case 2
+	testingutil.AssertEquals(t, "effcdf6ae5eb2fa2d27416d5f184df9c259a7c79",
+		cryptoes.HMACSHA1Hex([]byte("what do ya want for nothing?"), []byte("Jefe")), "cryptoes.HMACSHA1Hex")
+	testingutil.AssertTrue(t, cryptoes.HMACEqual(cryptoes.HMACSHA256([]byte("a"), []byte("k")), cryptoes.HMACSHA256([]byte("a"), []byte("k"))), "cryptoes.HMACEqual")
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	query := url.Values{"b": {"2", "1"}, "a": {"x y"}}
+	canonical := cryptoes.CanonicalRequest("post", "/v1/items", query, []byte{})
+	testingutil.AssertEquals(t, "POST\n/v1/items\na=x+y&b=1&b=2\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", canonical, "cryptoes.CanonicalRequest")
+}
+
+func TestHTTPQueryWithRequestSigner(t *testing.T) {
+	now := time.Now()
+	verifier := cryptoes.NewRequestSigner("ak", []byte("sk"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := verifier.VerifyRequest(r, body, time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	signer := cryptoes.NewRequestSigner("ak", []byte("sk"))
+	resp, err := httpclient.HTTPQuery("POST", server.URL+"/v1/items?b=2&a=1", bytes.NewReader([]byte("content")), httpclient.WithRequestSigner(signer))
+	testingutil.AssertNil(t, err, "httpclient.HTTPQuery signed")
+	testingutil.AssertEquals(t, "content", string(resp), "httpclient.HTTPQuery signed response")
+
+	signer = cryptoes.NewRequestSigner("ak", []byte("other"))
+	resp, err = httpclient.HTTPQuery("POST", server.URL+"/v1/items", bytes.NewReader([]byte("content")), httpclient.WithRequestSigner(signer))
+	testingutil.AssertNotNil(t, err, "httpclient.HTTPQuery signed by other secret")
+	testingutil.AssertEquals(t, cryptoes.ErrSignatureInvalid.Error(), string(resp), "httpclient.HTTPQuery signed by other secret response")
+
+	signer = cryptoes.NewRequestSigner("ak", []byte("sk"))
+	signer.Now = func() time.Time { return now.Add(-time.Hour) }
+	resp, err = httpclient.HTTPQuery("GET", server.URL+"/v1/items", nil, httpclient.WithRequestSigner(signer))
+	testingutil.AssertNotNil(t, err, "httpclient.HTTPQuery signed long ago")
+	testingutil.AssertEquals(t, cryptoes.ErrSignatureExpired.Error(), string(resp), "httpclient.HTTPQuery signed long ago response")
+}
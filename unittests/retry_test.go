@@ -0,0 +1,87 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	policy := utils.RetryPolicy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+	testingutil.AssertEquals(t, 100*time.Millisecond, policy.Backoff(0), "Backoff first")
+	testingutil.AssertEquals(t, 400*time.Millisecond, policy.Backoff(2), "Backoff exponential")
+	testingutil.AssertEquals(t, time.Second, policy.Backoff(10), "Backoff max interval")
+	testingutil.AssertEquals(t, time.Second, policy.Backoff(1000), "Backoff max interval overflow")
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := policy.Backoff(0)
+		testingutil.AssertTrue(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, "Backoff jitter range")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	policy := utils.RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond}
+	attempts := 0
+	err := utils.Retry(context.Background(), policy, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	})
+	testingutil.AssertNil(t, err, "Retry succeed error")
+	testingutil.AssertEquals(t, 3, attempts, "Retry succeed attempts")
+
+	attempts = 0
+	failure := errors.New("failure")
+	retried := []int{}
+	policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		retried = append(retried, attempt)
+	}
+	err = utils.Retry(context.Background(), policy, func() error {
+		attempts++
+		return failure
+	})
+	var retryErr *utils.RetryError
+	testingutil.AssertTrue(t, errors.As(err, &retryErr), "Retry exhausted error type")
+	testingutil.AssertEquals(t, 3, retryErr.Attempts, "Retry exhausted attempts")
+	testingutil.AssertTrue(t, errors.Is(err, failure), "Retry exhausted wraps last error")
+	testingutil.AssertEquals(t, 2, len(retried), "OnRetry calls")
+
+	attempts = 0
+	err = utils.Retry(context.Background(), policy, func() error {
+		attempts++
+		return utils.PermanentError(failure)
+	})
+	testingutil.AssertEquals(t, failure, err, "Retry permanent error unwrapped")
+	testingutil.AssertEquals(t, 1, attempts, "Retry permanent attempts")
+
+	attempts = 0
+	policy.Retryable = func(err error) bool { return err != failure }
+	err = utils.Retry(context.Background(), policy, func() error {
+		attempts++
+		return failure
+	})
+	testingutil.AssertEquals(t, failure, err, "Retry classified error")
+	testingutil.AssertEquals(t, 1, attempts, "Retry classified attempts")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = utils.Retry(ctx, utils.RetryPolicy{InitialInterval: time.Hour}, func() error { return errors.New("temporary") })
+	testingutil.AssertTrue(t, errors.Is(err, context.DeadlineExceeded), "Retry context done")
+	testingutil.AssertTrue(t, time.Since(start) < time.Second, "Retry context done in time")
+
+	attempts = 0
+	err = utils.Retry(context.Background(), utils.RetryPolicy{MaxElapsedTime: 30 * time.Millisecond, InitialInterval: 20 * time.Millisecond, Multiplier: 1}, func() error {
+		attempts++
+		return failure
+	})
+	testingutil.AssertTrue(t, errors.As(err, &retryErr), "Retry elapsed exhausted")
+	testingutil.AssertEquals(t, 2, attempts, "Retry elapsed attempts")
+}
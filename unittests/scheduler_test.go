@@ -0,0 +1,228 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/scheduler"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/timeutil"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC) // tuesday
+	for _, c := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 5, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2024, 3, 5, 10, 20, 45, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC)},
+		{"30 10 5 * *", time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)},
+		{"0 10 5 * *", time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * sun", time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)},
+		{"0,30 10-11 * * ?", time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 3, 5, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", time.Date(2024, 3, 5, 10, 22, 0, 0, time.UTC)},
+	} {
+		s, err := scheduler.ParseCron(c.expr)
+		testingutil.AssertNil(t, err, "ParseCron error of "+c.expr)
+		testingutil.AssertEquals(t, c.expected, s.Next(base), "ParseCron next of "+c.expr)
+	}
+	for _, expr := range []string{"", "* * *", "60 * * * *", "* * 0 * *", "* * * foo *", "5-1 * * * *", "*/0 * * * *"} {
+		_, err := scheduler.ParseCron(expr)
+		testingutil.AssertNotNil(t, err, "ParseCron invalid "+expr)
+	}
+	testingutil.AssertTrue(t, scheduler.MustParseCron("0 0 30 2 *").Next(base).IsZero(), "cron never matched")
+}
+
+func TestSchedulerJobs(t *testing.T) {
+	s := scheduler.NewScheduler()
+	s.Start()
+	defer s.Stop()
+
+	var intervalRuns, intervalInFlight, panics int32
+	testingutil.AssertNil(t, s.AddInterval("interval", 20*time.Millisecond, 5*time.Millisecond, func(ctx context.Context) error {
+		atomic.AddInt32(&intervalInFlight, 1)
+		defer atomic.AddInt32(&intervalInFlight, -1)
+		// the run dispatched before Remove starts with the canceled ctx
+		if nil == ctx.Err() {
+			atomic.AddInt32(&intervalRuns, 1)
+		}
+		return nil
+	}), "AddInterval error")
+	testingutil.AssertTrue(t, errors.Is(s.AddInterval("interval", time.Second, 0, func(ctx context.Context) error { return nil }), scheduler.ErrJobExists), "duplicated job")
+	testingutil.AssertNil(t, s.AddInterval("panic", 20*time.Millisecond, 0, func(ctx context.Context) error {
+		atomic.AddInt32(&panics, 1)
+		panic("job panic")
+	}), "AddInterval panic job error")
+
+	once := make(chan struct{})
+	testingutil.AssertNil(t, s.AddOnce("once", time.Now().Add(10*time.Millisecond), func(ctx context.Context) error {
+		close(once)
+		return nil
+	}), "AddOnce error")
+	select {
+	case <-once:
+	case <-time.After(time.Second):
+		t.Fatalf("once job not run")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	testingutil.AssertTrue(t, atomic.LoadInt32(&intervalRuns) >= 3, "interval job runs")
+	testingutil.AssertTrue(t, atomic.LoadInt32(&panics) >= 3, "panic job keeps running")
+	info, ok := s.Job("panic")
+	testingutil.AssertTrue(t, ok, "Job info")
+	testingutil.AssertNotNil(t, info.LastError, "panic recorded as error")
+	_, ok = s.Job("once")
+	testingutil.AssertFalse(t, ok, "once job removed after running")
+
+	testingutil.AssertTrue(t, s.Remove("interval"), "Remove job")
+	// the run already in flight while removing may still finish
+	testingutil.AssertEventually(t, func() bool {
+		return 0 == atomic.LoadInt32(&intervalInFlight)
+	}, time.Second, time.Millisecond, "in-flight run of removed job finished")
+	runs := atomic.LoadInt32(&intervalRuns)
+	time.Sleep(60 * time.Millisecond)
+	testingutil.AssertEquals(t, runs, atomic.LoadInt32(&intervalRuns), "removed job not run")
+	testingutil.AssertFalse(t, s.Remove("interval"), "Remove job twice")
+}
+
+func TestSchedulerOverlapAndCancel(t *testing.T) {
+	s := scheduler.NewScheduler()
+	s.Start()
+	var started int32
+	canceled := make(chan struct{})
+	testingutil.AssertNil(t, s.AddInterval("slow", 10*time.Millisecond, 0, func(ctx context.Context) error {
+		atomic.AddInt32(&started, 1)
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	}), "AddInterval slow error")
+	time.Sleep(60 * time.Millisecond)
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&started), "overlapped runs skipped")
+	info, _ := s.Job("slow")
+	testingutil.AssertTrue(t, info.Running, "job running")
+	testingutil.AssertTrue(t, info.Skips > 0, "skips counted")
+	s.Stop()
+	select {
+	case <-canceled:
+	default:
+		t.Fatalf("running job not canceled by Stop")
+	}
+}
+
+type testSchedulerLocker struct {
+	mutex sync.Mutex
+	held  map[string]bool
+}
+
+func (l *testSchedulerLocker) TryLock(ctx context.Context, name string, ttl time.Duration) (func(), bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return func() {
+		l.mutex.Lock()
+		delete(l.held, name)
+		l.mutex.Unlock()
+	}, true, nil
+}
+
+func TestSchedulerDistributedLock(t *testing.T) {
+	locker := &testSchedulerLocker{held: map[string]bool{}}
+	var runs int32
+	instances := []*scheduler.Scheduler{}
+	for i := 0; i < 3; i++ {
+		s := scheduler.NewScheduler(scheduler.WithLocker(locker))
+		s.Start()
+		testingutil.AssertNil(t, s.AddOnce("locked", time.Now().Add(10*time.Millisecond), func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, scheduler.WithDistributedLock(time.Minute)), "AddOnce locked error")
+		instances = append(instances, s)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, s := range instances {
+		s.Stop()
+	}
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&runs), "locked job run on one instance")
+}
+
+func TestSchedulerOnceJobContextReleased(t *testing.T) {
+	s := scheduler.NewScheduler()
+	s.Start()
+	defer s.Stop()
+	jobCtx := make(chan context.Context, 1)
+	release := make(chan struct{})
+	testingutil.AssertNil(t, s.AddOnce("once", time.Now(), func(ctx context.Context) error {
+		jobCtx <- ctx
+		<-release
+		if nil != ctx.Err() {
+			return errors.New("ctx canceled while running")
+		}
+		return nil
+	}), "AddOnce error")
+	var ctx context.Context
+	select {
+	case ctx = <-jobCtx:
+	case <-time.After(time.Second):
+		t.Fatalf("once job not run")
+	}
+	testingutil.AssertNil(t, ctx.Err(), "ctx of running once job")
+	close(release)
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("ctx of finished once job not canceled")
+	}
+}
+
+func TestSchedulerFakeClock(t *testing.T) {
+	start := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	clock := timeutil.NewFakeClock(start)
+	s := scheduler.NewScheduler(scheduler.WithClock(clock), scheduler.WithLocation(time.UTC))
+	s.Start()
+	defer s.Stop()
+
+	var runs int32
+	testingutil.AssertNil(t, s.AddInterval("interval", time.Minute, 0, func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}), "AddInterval error")
+	onceRan := make(chan time.Time, 1)
+	testingutil.AssertNil(t, s.AddOnce("once", start.Add(time.Hour), func(ctx context.Context) error {
+		onceRan <- clock.Now()
+		return nil
+	}), "AddOnce error")
+	time.Sleep(20 * time.Millisecond)
+	testingutil.AssertEquals(t, int32(0), atomic.LoadInt32(&runs), "interval job not run before advancing")
+
+	// the scheduler may reset its timer after the advancing, so that the clock is advanced until the job runs
+	testingutil.AssertEventually(t, func() bool {
+		clock.Advance(time.Minute)
+		return atomic.LoadInt32(&runs) > 0
+	}, time.Second, 5*time.Millisecond, "interval job run by fake clock")
+	testingutil.AssertEventually(t, func() bool {
+		info, _ := s.Job("interval")
+		return !info.LastRun.IsZero()
+	}, time.Second, time.Millisecond, "interval job finished")
+	info, _ := s.Job("interval")
+	testingutil.AssertTrue(t, !info.LastRun.Before(start.Add(time.Minute)), "last run of fake clock")
+
+	testingutil.AssertEventually(t, func() bool {
+		clock.Advance(10 * time.Minute)
+		return len(onceRan) > 0
+	}, time.Second, 5*time.Millisecond, "once job run by fake clock")
+	testingutil.AssertFalse(t, (<-onceRan).Before(start.Add(time.Hour)), "once job run at its time")
+}
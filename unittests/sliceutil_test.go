@@ -0,0 +1,65 @@
+package unittests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/sliceutil"
+)
+
+type sliceutilOrder struct {
+	ID       string
+	Customer string
+	Amount   int
+}
+
+func TestSliceutilSearch(t *testing.T) {
+	topics := []string{"orders", "payments", "orders", "refunds"}
+	testingutil.AssertTrue(t, sliceutil.Contains(topics, "payments"), "Contains")
+	testingutil.AssertFalse(t, sliceutil.Contains(topics, "users"), "Contains missing")
+	testingutil.AssertFalse(t, sliceutil.Contains([]int(nil), 1), "Contains nil slice")
+	testingutil.AssertEquals(t, 1, sliceutil.IndexOf(topics, "payments"), "IndexOf")
+	testingutil.AssertEquals(t, -1, sliceutil.IndexOf(topics, "users"), "IndexOf missing")
+	testingutil.AssertEquals(t, 3, sliceutil.IndexFunc(topics, func(s string) bool { return strings.HasPrefix(s, "ref") }), "IndexFunc")
+	testingutil.AssertTrue(t, sliceutil.ContainsFunc([]int{1, 3, 4}, func(n int) bool { return n%2 == 0 }), "ContainsFunc")
+}
+
+func TestSliceutilTransforms(t *testing.T) {
+	testingutil.AssertEquals(t, "3,1,2", joinInts(sliceutil.Unique([]int{3, 1, 3, 2, 1})), "Unique")
+	orders := []sliceutilOrder{
+		{ID: "o1", Customer: "alice", Amount: 10},
+		{ID: "o2", Customer: "bob", Amount: 20},
+		{ID: "o3", Customer: "alice", Amount: 30},
+	}
+	firstOrders := sliceutil.UniqueBy(orders, func(o sliceutilOrder) string { return o.Customer })
+	testingutil.AssertEquals(t, "o1,o2", strings.Join(sliceutil.Map(firstOrders, func(o sliceutilOrder) string { return o.ID }), ","), "UniqueBy")
+
+	groups := sliceutil.GroupBy(orders, func(o sliceutilOrder) string { return o.Customer })
+	testingutil.AssertEquals(t, 2, len(groups), "GroupBy groups")
+	testingutil.AssertEquals(t, "o1", groups["alice"][0].ID, "GroupBy first of group")
+	testingutil.AssertEquals(t, "o3", groups["alice"][1].ID, "GroupBy order of group")
+	testingutil.AssertEquals(t, 60, sliceutil.Reduce(orders, 0, func(sum int, o sliceutilOrder) int { return sum + o.Amount }), "Reduce")
+	large := sliceutil.Filter(orders, func(o sliceutilOrder) bool { return o.Amount >= 20 })
+	testingutil.AssertEquals(t, 2, len(large), "Filter")
+	testingutil.AssertEquals(t, 3, len(orders), "Filter keeps source")
+
+	chunks := sliceutil.Chunk([]int{1, 2, 3, 4, 5}, 2)
+	testingutil.AssertEquals(t, 3, len(chunks), "Chunk count")
+	testingutil.AssertEquals(t, "5", joinInts(chunks[2]), "Chunk last")
+	source := []int{1, 2, 3, 4}
+	chunks = sliceutil.Chunk(source, 2)
+	chunks[0] = append(chunks[0], 9)
+	testingutil.AssertEquals(t, "1,2,3,4", joinInts(source), "Chunk append not overwriting")
+	testingutil.AssertEquals(t, 0, len(sliceutil.Chunk([]int{}, 2)), "Chunk empty")
+	testingutil.AssertTrue(t, nil == sliceutil.Chunk(source, 0), "Chunk invalid size")
+
+	testingutil.AssertEquals(t, "1,3,1", joinInts(sliceutil.Diff([]int{1, 2, 3, 1}, []int{2, 4})), "Diff")
+	testingutil.AssertEquals(t, "2,3", joinInts(sliceutil.Intersect([]int{2, 3, 2, 5}, []int{3, 2, 4})), "Intersect")
+	testingutil.AssertEquals(t, 0, len(sliceutil.Diff(nil, []int{1})), "Diff nil")
+}
+
+func joinInts(values []int) string {
+	return strings.Join(sliceutil.Map(values, func(n int) string { return fmt.Sprint(n) }), ",")
+}
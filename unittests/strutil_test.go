@@ -0,0 +1,83 @@
+package unittests
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/strutil"
+)
+
+func TestStrutilNamingConverters(t *testing.T) {
+	testingutil.AssertEquals(t, "HTTP,Server,ID", strings.Join(strutil.SplitWords("HTTPServerID"), ","), "SplitWords acronyms")
+	testingutil.AssertEquals(t, "user,id2,Name", strings.Join(strutil.SplitWords("user_id2Name"), ","), "SplitWords digits")
+	testingutil.AssertEquals(t, "Order,Items", strings.Join(strutil.SplitWords("  Order - Items.."), ","), "SplitWords separators")
+	testingutil.AssertEquals(t, "HTTP2,Server", strings.Join(strutil.SplitWords("HTTP2Server"), ","), "SplitWords acronym with digit")
+	testingutil.AssertEquals(t, 0, len(strutil.SplitWords("__")), "SplitWords without words")
+
+	for _, c := range []struct {
+		input, camel, pascal, snake, screaming, kebab, dot string
+	}{
+		{"user_id", "userId", "UserId", "user_id", "USER_ID", "user-id", "user.id"},
+		{"HTTPServerID", "httpServerId", "HttpServerId", "http_server_id", "HTTP_SERVER_ID", "http-server-id", "http.server.id"},
+		{"order-item count", "orderItemCount", "OrderItemCount", "order_item_count", "ORDER_ITEM_COUNT", "order-item-count", "order.item.count"},
+		{"parseJSONBody", "parseJsonBody", "ParseJsonBody", "parse_json_body", "PARSE_JSON_BODY", "parse-json-body", "parse.json.body"},
+		{"ÉtatCivil", "étatCivil", "ÉtatCivil", "état_civil", "ÉTAT_CIVIL", "état-civil", "état.civil"},
+	} {
+		testingutil.AssertEquals(t, c.camel, strutil.ToCamelCase(c.input), "ToCamelCase "+c.input)
+		testingutil.AssertEquals(t, c.pascal, strutil.ToPascalCase(c.input), "ToPascalCase "+c.input)
+		testingutil.AssertEquals(t, c.snake, strutil.ToSnakeCase(c.input), "ToSnakeCase "+c.input)
+		testingutil.AssertEquals(t, c.screaming, strutil.ToScreamingSnakeCase(c.input), "ToScreamingSnakeCase "+c.input)
+		testingutil.AssertEquals(t, c.kebab, strutil.ToKebabCase(c.input), "ToKebabCase "+c.input)
+		testingutil.AssertEquals(t, c.dot, strutil.ToDotCase(c.input), "ToDotCase "+c.input)
+	}
+}
+
+func TestStrutilTruncate(t *testing.T) {
+	testingutil.AssertEquals(t, "hello", strutil.Truncate("hello", 5), "Truncate not cut")
+	testingutil.AssertEquals(t, "hell...", strutil.Truncate("hello world", 7), "Truncate with ellipsis")
+	testingutil.AssertEquals(t, "中文字...", strutil.Truncate("中文字符串截断", 6), "Truncate multi-byte")
+	testingutil.AssertEquals(t, "..", strutil.Truncate("hello world", 2), "Truncate shorter than ellipsis")
+	testingutil.AssertEquals(t, "", strutil.Truncate("hello", 0), "Truncate to zero")
+	testingutil.AssertEquals(t, "hello…", strutil.TruncateWith("hello world", 6, "…"), "TruncateWith")
+	testingutil.AssertEquals(t, "/var/l...ce.log", strutil.TruncateMiddle("/var/log/service.log", 15), "TruncateMiddle")
+	testingutil.AssertEquals(t, "short", strutil.TruncateMiddle("short", 15), "TruncateMiddle not cut")
+
+	cut := strutil.TruncateBytes("中文字符", 7)
+	testingutil.AssertEquals(t, "中文", cut, "TruncateBytes multi-byte")
+	testingutil.AssertTrue(t, utf8.ValidString(cut), "TruncateBytes valid utf-8")
+	testingutil.AssertEquals(t, "abc", strutil.TruncateBytes("abcdef", 3), "TruncateBytes ascii")
+	testingutil.AssertEquals(t, "abc", strutil.TruncateBytes("abc", 10), "TruncateBytes not cut")
+}
+
+func TestStrutilRandomString(t *testing.T) {
+	s, err := strutil.RandomString(32, strutil.CharsetReadable)
+	testingutil.AssertNil(t, err, "RandomString error")
+	testingutil.AssertEquals(t, 32, len(s), "RandomString length")
+	for _, r := range s {
+		testingutil.AssertTrue(t, strings.ContainsRune(strutil.CharsetReadable, r), "RandomString charset "+string(r))
+	}
+	testingutil.AssertTrue(t, s != strutil.MustRandomString(32, strutil.CharsetReadable), "RandomString random")
+	code := strutil.MustRandomString(6, strutil.CharsetDigits)
+	testingutil.AssertEquals(t, 6, len(strings.Trim(code, strutil.CharsetDigits))+6, "digits code")
+	unicodeText := strutil.MustRandomString(5, "甲乙丙")
+	testingutil.AssertEquals(t, 5, utf8.RuneCountInString(unicodeText), "RandomString unicode charset")
+	_, err = strutil.RandomString(8, "")
+	testingutil.AssertErrorIs(t, err, strutil.ErrEmptyCharset, "RandomString empty charset")
+}
+
+func TestStrutilMask(t *testing.T) {
+	testingutil.AssertEquals(t, "ab****gh", strutil.Mask("abcdefgh", 2, 2), "Mask")
+	testingutil.AssertEquals(t, "***", strutil.Mask("abc", 2, 2), "Mask short secret")
+	testingutil.AssertEquals(t, "张**", strutil.Mask("张三丰", 1, 0), "Mask multi-byte")
+	testingutil.AssertEquals(t, "j******e@example.com", strutil.MaskEmail("john.doe@example.com"), "MaskEmail")
+	testingutil.AssertEquals(t, "j*@example.com", strutil.MaskEmail("jd@example.com"), "MaskEmail short local part")
+	testingutil.AssertEquals(t, "n*****l", strutil.MaskEmail("noemail"), "MaskEmail without at")
+	testingutil.AssertEquals(t, "138****5678", strutil.MaskPhone("13812345678"), "MaskPhone mobile")
+	testingutil.AssertEquals(t, "+** ***-****-5678", strutil.MaskPhone("+86 138-1234-5678"), "MaskPhone international")
+	testingutil.AssertEquals(t, "(***) ***-4567", strutil.MaskPhone("(555) 123-4567"), "MaskPhone short")
+	testingutil.AssertEquals(t, "***4", strutil.MaskPhone("1234"), "MaskPhone too short")
+	testingutil.AssertEquals(t, "**** **** **** 1111", strutil.MaskCard("4111 1111 1111 1111"), "MaskCard")
+	testingutil.AssertEquals(t, "************1111", strutil.MaskCard("4111111111111111"), "MaskCard without separators")
+}
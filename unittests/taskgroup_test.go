@@ -0,0 +1,116 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/concurrency"
+)
+
+func TestTaskGroupFirstError(t *testing.T) {
+	failure := errors.New("failure")
+	g, ctx := concurrency.NewGroup(context.Background())
+	var canceled int32
+	g.Go(func(ctx context.Context) error {
+		return failure
+	})
+	g.Go(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&canceled, 1)
+		case <-time.After(time.Second):
+		}
+		return ctx.Err()
+	})
+	err := g.Wait()
+	testingutil.AssertEquals(t, failure, err, "first error returned")
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&canceled), "other tasks canceled")
+	testingutil.AssertNotNil(t, ctx.Err(), "group context canceled")
+
+	var started int32
+	g, _ = concurrency.NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error { return failure })
+	time.Sleep(20 * time.Millisecond)
+	g.Go(func(ctx context.Context) error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	})
+	testingutil.AssertEquals(t, failure, g.Wait(), "first error")
+	testingutil.AssertEquals(t, int32(0), atomic.LoadInt32(&started), "task skipped after failure")
+
+	parent, cancel := context.WithCancel(context.Background())
+	g, _ = concurrency.NewGroup(parent)
+	cancel()
+	g.Go(func(ctx context.Context) error {
+		atomic.AddInt32(&started, 1)
+		return nil
+	})
+	testingutil.AssertEquals(t, context.Canceled, g.Wait(), "parent cancellation returned")
+	testingutil.AssertEquals(t, int32(0), atomic.LoadInt32(&started), "task skipped after parent canceled")
+}
+
+func TestTaskGroupCollectErrors(t *testing.T) {
+	g, _ := concurrency.NewGroup(context.Background(), concurrency.WithGroupMode(concurrency.GroupCollectErrors))
+	failure := errors.New("failure")
+	var finished int32
+	for i := 0; i < 5; i++ {
+		n := i
+		g.Go(func(ctx context.Context) error {
+			time.Sleep(time.Duration(5-n) * time.Millisecond)
+			atomic.AddInt32(&finished, 1)
+			switch n {
+			case 1:
+				return failure
+			case 3:
+				panic("task panic")
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+	testingutil.AssertEquals(t, int32(5), atomic.LoadInt32(&finished), "all tasks finished")
+	var errs concurrency.Errors
+	testingutil.AssertTrue(t, errors.As(err, &errs), "Errors aggregated")
+	testingutil.AssertEquals(t, 2, len(errs), "errors count")
+	var taskErr *concurrency.TaskError
+	testingutil.AssertTrue(t, errors.As(errs[0], &taskErr), "TaskError")
+	testingutil.AssertEquals(t, 1, taskErr.Index, "errors sorted by index")
+	testingutil.AssertTrue(t, errors.Is(err, failure), "errors.Is through aggregated errors")
+	testingutil.AssertTrue(t, errs.Is(failure), "Errors.Is")
+	testingutil.AssertTrue(t, errs.As(&taskErr), "Errors.As")
+	testingutil.AssertTrue(t, strings.Contains(err.Error(), "task 3: task panic: task panic"), "panic error: "+err.Error())
+}
+
+func TestTaskGroupLimitTimeout(t *testing.T) {
+	g, _ := concurrency.NewGroup(context.Background(), concurrency.WithGroupLimit(2), concurrency.WithGroupMode(concurrency.GroupCollectErrors))
+	var running, maxRunning int32
+	for i := 0; i < 10; i++ {
+		g.Go(func(ctx context.Context) error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	testingutil.AssertNil(t, g.Wait(), "limited group error")
+	testingutil.AssertTrue(t, atomic.LoadInt32(&maxRunning) <= 2, fmt.Sprintf("max running %d", maxRunning))
+
+	g, _ = concurrency.NewGroup(context.Background(), concurrency.WithTaskTimeout(20*time.Millisecond))
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	testingutil.AssertTrue(t, errors.Is(g.Wait(), context.DeadlineExceeded), "task timeout")
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/libpub/golib/testingutil"
 	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/timeutil"
 )
 
 func TestTimeFormattionParse(t *testing.T) {
@@ -18,3 +19,156 @@ func TestTimeFormattionParse(t *testing.T) {
 	// v4 := utils.TimeToHuman("YYYY-MM-DD HH:mm:ss", v3)
 	// testingutil.AssertEquals(t, tv, v4, "utils.HumanToTimestamp")
 }
+
+func TestTimeutilParse(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	expected := time.Date(2024, 3, 15, 10, 30, 45, 0, loc)
+	for _, text := range []string{
+		"2024-03-15T10:30:45+08:00",
+		"2024-03-15T02:30:45Z",
+		"2024-03-15 10:30:45",
+		"2024-03-15T10:30:45",
+		"2024/03/15 10:30:45",
+		"20240315103045",
+		"Fri, 15 Mar 2024 10:30:45 +0800",
+		"Mar 15, 2024 10:30:45",
+		"2024年03月15日 10:30:45",
+		"1710469845",
+		"1710469845000",
+		"1710469845000000",
+		"1710469845000000000",
+	} {
+		v, err := timeutil.ParseInLocation(text, loc)
+		testingutil.AssertNil(t, err, "parse "+text)
+		testingutil.AssertTrue(t, expected.Equal(v), "parsed "+text+" as "+v.String())
+	}
+	v, err := timeutil.ParseInLocation("20240315", loc)
+	testingutil.AssertNil(t, err, "parse yyyyMMdd")
+	testingutil.AssertTrue(t, time.Date(2024, 3, 15, 0, 0, 0, 0, loc).Equal(v), "parsed yyyyMMdd as "+v.String())
+	v, err = timeutil.Parse("1710469845.25")
+	testingutil.AssertNil(t, err, "parse fractional seconds")
+	testingutil.AssertEquals(t, int64(1710469845250), v.UnixMilli(), "fractional seconds")
+
+	_, err = timeutil.Parse("yesterday")
+	testingutil.AssertErrorIs(t, err, timeutil.ErrUnknownFormat, "unknown format")
+	_, err = timeutil.Parse(" ")
+	testingutil.AssertErrorIs(t, err, timeutil.ErrUnknownFormat, "empty text")
+}
+
+func TestTimeutilTruncate(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	v := time.Date(2024, 2, 15, 10, 30, 45, 123, loc)
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 10, 0, 0, 0, loc), timeutil.StartOfHour(v), "StartOfHour")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 0, 0, 0, 0, loc), timeutil.StartOfDay(v), "StartOfDay")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 23, 59, 59, 999999999, loc), timeutil.EndOfDay(v), "EndOfDay")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 12, 0, 0, 0, 0, loc), timeutil.StartOfWeek(v, time.Monday), "StartOfWeek monday")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 11, 0, 0, 0, 0, loc), timeutil.StartOfWeek(v, time.Sunday), "StartOfWeek sunday")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 18, 23, 59, 59, 999999999, loc), timeutil.EndOfWeek(v, time.Monday), "EndOfWeek")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 1, 0, 0, 0, 0, loc), timeutil.StartOfMonth(v), "StartOfMonth")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 29, 23, 59, 59, 999999999, loc), timeutil.EndOfMonth(v), "EndOfMonth of leap year")
+	testingutil.AssertEquals(t, time.Date(2024, 1, 1, 0, 0, 0, 0, loc), timeutil.StartOfYear(v), "StartOfYear")
+
+	// the 6 hours buckets are aligned to the local midnight instead of UTC
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 6, 0, 0, 0, loc), timeutil.Truncate(v, 6*time.Hour), "Truncate 6h")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 12, 0, 0, 0, loc), timeutil.Round(v, 6*time.Hour), "Round 6h")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 10, 30, 0, 0, loc), timeutil.Round(v, 15*time.Minute), "Round 15m")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 10, 45, 0, 0, loc), timeutil.Ceil(v, 15*time.Minute), "Ceil 15m")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 16, 0, 0, 0, 0, loc), timeutil.Ceil(v, 24*time.Hour), "Ceil day")
+	testingutil.AssertEquals(t, time.Date(2024, 2, 15, 0, 0, 0, 0, loc), timeutil.Truncate(v, 48*time.Hour), "Truncate beyond day")
+	aligned := time.Date(2024, 2, 15, 10, 45, 0, 0, loc)
+	testingutil.AssertEquals(t, aligned, timeutil.Ceil(aligned, 15*time.Minute), "Ceil aligned")
+
+	testingutil.AssertEquals(t, 15, timeutil.DaysBetween(time.Date(2024, 2, 28, 23, 0, 0, 0, loc), time.Date(2024, 3, 14, 1, 0, 0, 0, loc)), "DaysBetween")
+	testingutil.AssertEquals(t, -1, timeutil.DaysBetween(v, v.Add(-11*time.Hour)), "DaysBetween backward")
+}
+
+func TestTimeutilBusinessDays(t *testing.T) {
+	friday := time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC)
+	saturday := friday.AddDate(0, 0, 1)
+	testingutil.AssertTrue(t, timeutil.IsBusinessDay(friday), "friday is business day")
+	testingutil.AssertFalse(t, timeutil.IsBusinessDay(saturday), "saturday is weekend")
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, 3), timeutil.AddBusinessDays(friday, 1), "next business day of friday")
+	testingutil.AssertEquals(t, friday, timeutil.AddBusinessDays(friday.AddDate(0, 0, 3), -1), "previous business day of monday")
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, 3), timeutil.AddBusinessDays(saturday, 0), "business day of saturday")
+	testingutil.AssertEquals(t, 5, timeutil.BusinessDaysBetween(friday, friday.AddDate(0, 0, 7)), "business days of a week")
+	testingutil.AssertEquals(t, -5, timeutil.BusinessDaysBetween(friday.AddDate(0, 0, 7), friday), "business days backward")
+
+	calendar := timeutil.NewBusinessCalendar()
+	calendar.AddHolidays(friday.AddDate(0, 0, 3))
+	calendar.AddWorkdays(friday.AddDate(0, 0, 2))
+	testingutil.AssertFalse(t, calendar.IsBusinessDay(friday.AddDate(0, 0, 3)), "holiday monday")
+	testingutil.AssertTrue(t, calendar.IsBusinessDay(friday.AddDate(0, 0, 2)), "workday sunday")
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, 4), calendar.AddBusinessDays(friday, 2), "business days skipping holiday")
+	testingutil.AssertEquals(t, 4, calendar.BusinessDaysBetween(friday, friday.AddDate(0, 0, 6)), "business days with holiday and workday")
+
+	closed := timeutil.NewBusinessCalendar(time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday)
+	testingutil.AssertEquals(t, friday, closed.AddBusinessDays(friday, 1), "calendar without business days")
+	closed.AddWorkdays(friday.AddDate(0, 0, -7), friday.AddDate(0, 0, 2))
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, 2), closed.NextBusinessDay(friday), "next marked workday")
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, 2), closed.AddBusinessDays(friday, 1), "business day of marked workday")
+	testingutil.AssertEquals(t, friday.AddDate(0, 0, -7), closed.AddBusinessDays(friday, -1), "previous marked workday")
+	// the marked workdays are all behind
+	later := friday.AddDate(0, 0, 3)
+	testingutil.AssertEquals(t, later, closed.NextBusinessDay(later), "next business day beyond marked workdays")
+	testingutil.AssertEquals(t, later, closed.AddBusinessDays(later, 1), "business days beyond marked workdays")
+	testingutil.AssertEquals(t, later, closed.AddBusinessDays(later, -3), "business days before marked workdays")
+}
+
+func TestTimeutilFakeClock(t *testing.T) {
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	clock := timeutil.NewFakeClock(start)
+	late := clock.NewTimer(2 * time.Minute)
+	early := clock.NewTimer(time.Minute)
+	stopped := clock.NewTimer(time.Minute)
+	fired := make(chan struct{})
+	clock.AfterFunc(90*time.Second, func() { close(fired) })
+	testingutil.AssertEquals(t, 4, clock.Timers(), "pending timers")
+	testingutil.AssertTrue(t, stopped.Stop(), "stop pending timer")
+
+	clock.Advance(time.Minute)
+	testingutil.AssertEquals(t, start.Add(time.Minute), <-early.C(), "early timer fired")
+	select {
+	case <-late.C():
+		t.Fatal("late timer fired early")
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+	testingutil.AssertFalse(t, early.Stop(), "stop fired timer")
+	clock.Advance(time.Minute)
+	testingutil.AssertEquals(t, start.Add(2*time.Minute), <-late.C(), "late timer fired")
+	<-fired
+	testingutil.AssertEquals(t, 0, clock.Timers(), "no pending timers")
+
+	testingutil.AssertFalse(t, late.Reset(time.Second), "reset fired timer")
+	clock.Set(start)
+	testingutil.AssertEquals(t, start.Add(2*time.Minute), clock.Now(), "time not moved backward")
+	clock.Set(clock.Now().Add(time.Second))
+	<-late.C()
+
+	slept := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Hour)
+		close(slept)
+	}()
+	testingutil.AssertEventually(t, func() bool { return clock.Timers() == 1 }, time.Second, time.Millisecond, "sleeping timer")
+	clock.Advance(time.Hour)
+	<-slept
+
+	stopwatch := timeutil.StartStopwatchWithClock(clock)
+	clock.Advance(time.Second)
+	testingutil.AssertEquals(t, time.Second, stopwatch.Lap(), "first lap")
+	clock.Advance(2 * time.Second)
+	testingutil.AssertEquals(t, 3*time.Second, stopwatch.Stop(), "stopped elapsed")
+	clock.Advance(time.Minute)
+	testingutil.AssertEquals(t, 3*time.Second, stopwatch.Elapsed(), "elapsed not counted while stopped")
+	testingutil.AssertEquals(t, time.Duration(0), stopwatch.Lap(), "lap while stopped")
+	stopwatch.Start()
+	clock.Advance(time.Second)
+	testingutil.AssertEquals(t, 4*time.Second, stopwatch.Elapsed(), "elapsed resumed")
+	testingutil.AssertEquals(t, 1, len(stopwatch.Laps()), "laps")
+	stopwatch.Reset()
+	testingutil.AssertEquals(t, time.Duration(0), stopwatch.Elapsed(), "elapsed reset")
+	testingutil.AssertTrue(t, stopwatch.Running(), "running after reset")
+	testingutil.AssertTrue(t, timeutil.StartStopwatch().Elapsed() >= 0, "system stopwatch")
+}
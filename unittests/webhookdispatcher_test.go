@@ -0,0 +1,109 @@
+package unittests
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/eventbus"
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils"
+	"github.com/libpub/golib/utils/cryptoes"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	payload := []byte(`{"id":"e1"}`)
+	now := time.Now().Unix()
+	signature := eventbus.SignWebhookPayload(secret, payload, now)
+	testingutil.AssertNil(t, eventbus.VerifyWebhookSignature(secret, payload, signature, time.Minute), "verify signature")
+	testingutil.AssertErrorIs(t, eventbus.VerifyWebhookSignature([]byte("other"), payload, signature, time.Minute), cryptoes.ErrSignatureInvalid, "verify with other secret")
+	testingutil.AssertErrorIs(t, eventbus.VerifyWebhookSignature(secret, []byte(`{"id":"e2"}`), signature, time.Minute), cryptoes.ErrSignatureInvalid, "verify tampered payload")
+	testingutil.AssertErrorIs(t, eventbus.VerifyWebhookSignature(secret, payload, eventbus.SignWebhookPayload(secret, payload, now-3600), time.Minute), cryptoes.ErrSignatureExpired, "verify expired")
+	testingutil.AssertErrorIs(t, eventbus.VerifyWebhookSignature(secret, payload, "", time.Minute), cryptoes.ErrSignatureMissing, "verify missing")
+}
+
+func TestWebhookDispatcherDelivery(t *testing.T) {
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries former retries")
+	backoff := httpclient.RetryBackoff
+	httpclient.RetryBackoff = utils.RetryPolicy{InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Multiplier: 2}
+	defer func() {
+		httpclient.RetryBackoff = backoff
+	}()
+
+	secret := []byte("s3cret")
+	var signed, failing, failingHits int32
+	atomic.StoreInt32(&failing, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/signed":
+			if nil != eventbus.VerifyWebhookSignature(secret, body, r.Header.Get(eventbus.HeaderWebhookSignature), time.Minute) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			atomic.AddInt32(&signed, 1)
+			w.WriteHeader(http.StatusNoContent)
+		case "/failing":
+			atomic.AddInt32(&failingHits, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	deadLetters := make(chan eventbus.Delivery, 1)
+	dispatcher := eventbus.NewWebhookDispatcher(eventbus.WithWebhookRetries(2), eventbus.WithDeadLetterHandler(func(delivery eventbus.Delivery) {
+		deadLetters <- delivery
+	}))
+	testingutil.AssertErrorIs(t, dispatcher.Register(eventbus.WebhookTarget{ID: "no-url"}), eventbus.ErrWebhookTargetInvalid, "register without url")
+	testingutil.AssertNil(t, dispatcher.Register(eventbus.WebhookTarget{ID: "signed", URL: server.URL + "/signed", Secret: secret, EventTypes: []string{"order.created"}}), "register signed")
+	testingutil.AssertNil(t, dispatcher.Register(eventbus.WebhookTarget{ID: "failing", URL: server.URL + "/failing", EventTypes: []string{eventbus.AllEventTypes}}), "register failing")
+	testingutil.AssertEquals(t, 2, len(dispatcher.Targets("order.created")), "targets of order.created")
+	testingutil.AssertEquals(t, 1, len(dispatcher.Targets("order.paid")), "targets of order.paid")
+
+	bus := eventbus.NewBus()
+	bus.Route(eventbus.AllEventTypes, dispatcher)
+	event, err := bus.Emit(context.Background(), "order.created", map[string]string{"orderId": "o1"})
+	testingutil.AssertNil(t, err, "emit")
+	deliveries := dispatcher.Deliveries(event.ID)
+	testingutil.AssertEquals(t, 2, len(deliveries), "deliveries of event")
+
+	dead := <-deadLetters
+	testingutil.AssertEquals(t, "failing", dead.TargetID, "dead letter target")
+	testingutil.AssertEquals(t, eventbus.DeliveryFailed, dead.Status, "dead letter status")
+	testingutil.AssertEquals(t, 3, dead.Attempts, "dead letter attempts")
+	testingutil.AssertEquals(t, http.StatusServiceUnavailable, dead.StatusCode, "dead letter status code")
+	testingutil.AssertEquals(t, int32(3), atomic.LoadInt32(&failingHits), "failing target hits")
+	testingutil.AssertEquals(t, 1, len(dispatcher.DeadLetters()), "dead letters")
+
+	for _, delivery := range deliveries {
+		if "signed" == delivery.TargetID {
+			testingutil.AssertEventually(t, func() bool {
+				delivered, _ := dispatcher.Delivery(delivery.ID)
+				return eventbus.DeliverySucceeded == delivered.Status
+			}, 2*time.Second, 5*time.Millisecond, "signed delivery succeeded")
+		}
+	}
+	testingutil.AssertEquals(t, int32(1), atomic.LoadInt32(&signed), "signed target hits")
+
+	atomic.StoreInt32(&failing, 0)
+	testingutil.AssertNil(t, dispatcher.Redeliver(dead.ID), "redeliver")
+	testingutil.AssertErrorIs(t, dispatcher.Redeliver(dead.ID), eventbus.ErrDeliveryNotFound, "redeliver twice")
+	testingutil.AssertEventually(t, func() bool {
+		delivered, _ := dispatcher.Delivery(dead.ID)
+		return eventbus.DeliverySucceeded == delivered.Status
+	}, 2*time.Second, 5*time.Millisecond, "redelivery succeeded")
+	delivered, _ := dispatcher.Delivery(dead.ID)
+	testingutil.AssertEquals(t, 4, delivered.Attempts, "redelivered attempts")
+	testingutil.AssertEquals(t, 0, len(dispatcher.DeadLetters()), "dead letters after redelivery")
+	testingutil.AssertNil(t, httpclient.StopRetries(context.Background()), "StopRetries error")
+}
@@ -0,0 +1,117 @@
+package unittests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libpub/golib/httpclient"
+	"github.com/libpub/golib/testingutil"
+	"github.com/libpub/golib/utils/concurrency"
+)
+
+func TestWorkerPool(t *testing.T) {
+	var processed int32
+	var panics int32
+	pool := concurrency.NewPool(4, concurrency.WithCapacity(16), concurrency.WithPanicHandler(func(r interface{}) {
+		atomic.AddInt32(&panics, 1)
+	}))
+	for i := 0; i < 100; i++ {
+		n := i
+		testingutil.AssertNil(t, pool.Submit(func() {
+			atomic.AddInt32(&processed, 1)
+			if n%10 == 0 {
+				panic("task panic")
+			}
+		}), "Submit error")
+	}
+	pool.Stop()
+	testingutil.AssertEquals(t, int32(100), atomic.LoadInt32(&processed), "processed tasks")
+	testingutil.AssertEquals(t, int32(10), atomic.LoadInt32(&panics), "recovered panics")
+	stats := pool.Stats()
+	testingutil.AssertEquals(t, int64(100), stats.Completed, "completed stats")
+	testingutil.AssertEquals(t, int64(10), stats.Panics, "panics stats")
+	testingutil.AssertEquals(t, 0, stats.Pending, "pending after stop")
+	testingutil.AssertTrue(t, errors.Is(pool.Submit(func() {}), concurrency.ErrPoolStopped), "Submit after stop")
+}
+
+func TestWorkerPoolBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	pool := concurrency.NewPool(1, concurrency.WithCapacity(2))
+	testingutil.AssertNil(t, pool.Submit(func() { <-release }), "Submit running")
+	testingutil.AssertNil(t, pool.Submit(func() {}), "Submit queued")
+	time.Sleep(20 * time.Millisecond)
+	stats := pool.Stats()
+	testingutil.AssertEquals(t, 2, stats.Pending, "pending stats")
+	testingutil.AssertEquals(t, 1, stats.Running, "running stats")
+	testingutil.AssertEquals(t, 1, stats.QueueDepth, "queue depth stats")
+	testingutil.AssertTrue(t, errors.Is(pool.TrySubmit(func() {}), concurrency.ErrPoolFull), "TrySubmit full")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	testingutil.AssertTrue(t, errors.Is(pool.SubmitContext(ctx, func() {}), context.DeadlineExceeded), "SubmitContext timeout")
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stopCancel()
+	testingutil.AssertNotNil(t, pool.StopContext(stopCtx), "StopContext timeout while task running")
+	close(release)
+	pool.Stop()
+	testingutil.AssertEquals(t, int64(2), pool.Stats().Completed, "completed after stop")
+}
+
+func TestWorkerPoolKeyedOrdering(t *testing.T) {
+	var mutex sync.Mutex
+	received := map[int][]int{}
+	pool := concurrency.NewPool(4, concurrency.WithCapacity(8), concurrency.WithKeyedQueues())
+	for seq := 0; seq < 50; seq++ {
+		for key := 0; key < 3; key++ {
+			k, s := key, seq
+			pool.SubmitKey(k, func() {
+				time.Sleep(time.Duration(s%3) * time.Millisecond)
+				mutex.Lock()
+				received[k] = append(received[k], s)
+				mutex.Unlock()
+			})
+		}
+	}
+	pool.Stop()
+	for key := 0; key < 3; key++ {
+		testingutil.AssertEquals(t, 50, len(received[key]), "keyed tasks")
+		for i, seq := range received[key] {
+			testingutil.AssertEquals(t, i, seq, "keyed tasks order")
+		}
+	}
+}
+
+func TestHTTPQueryAsync(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	results := []string{}
+	for _, text := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		err := httpclient.HTTPQueryAsync(http.MethodPost, server.URL, strings.NewReader(text), func(respBody []byte, err error) {
+			defer wg.Done()
+			testingutil.AssertNil(t, err, "HTTPQueryAsync callback error")
+			mutex.Lock()
+			results = append(results, string(respBody))
+			mutex.Unlock()
+		})
+		testingutil.AssertNil(t, err, "HTTPQueryAsync error")
+	}
+	wg.Wait()
+	testingutil.AssertEquals(t, 3, len(results), "HTTPQueryAsync results")
+	testingutil.AssertTrue(t, httpclient.AsyncStats().Completed >= 3, "AsyncStats completed")
+}
@@ -0,0 +1,228 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GroupMode error mode of task group
+type GroupMode int
+
+// Group modes
+const (
+	GroupFirstError    = GroupMode(0) // the group context is canceled on the first error which is returned by Wait
+	GroupCollectErrors = GroupMode(1) // all tasks run to the end and all the errors are returned by Wait
+)
+
+// GroupOption options of task group
+type GroupOption func(*groupOptions)
+
+type groupOptions struct {
+	limit       int
+	taskTimeout time.Duration
+	mode        GroupMode
+}
+
+// Group runs tasks concurrently and waits for them like errgroup, with the concurrency limit, per task timeout
+// and error modes
+type Group struct {
+	options groupOptions
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	sem     chan struct{}
+	wg      sync.WaitGroup
+	mutex   sync.Mutex
+	tasks   int
+	errs    []*TaskError
+}
+
+// TaskError error of the index-th task started by Group.Go
+type TaskError struct {
+	Index int
+	Err   error
+}
+
+// Errors aggregated errors of tasks
+type Errors []error
+
+// WithGroupLimit at most limit tasks run at the same time, Go blocks while the limit reached, 0 means unlimited
+func WithGroupLimit(limit int) GroupOption {
+	return func(o *groupOptions) {
+		o.limit = limit
+	}
+}
+
+// WithTaskTimeout the context of each task is timed out after timeout
+func WithTaskTimeout(timeout time.Duration) GroupOption {
+	return func(o *groupOptions) {
+		o.taskTimeout = timeout
+	}
+}
+
+// WithGroupMode error mode, GroupFirstError by default
+func WithGroupMode(mode GroupMode) GroupOption {
+	return func(o *groupOptions) {
+		o.mode = mode
+	}
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("task %d: %v", e.Index, e.Err)
+}
+
+// Unwrap the error returned by task
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap the errors for errors.Is and errors.As
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is whether any of the errors matches target, errors.Is of go 1.19 does not unwrap []error
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors matches target, errors.As of go 1.19 does not unwrap []error
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewGroup task group derived from ctx, the returned context is canceled on the first error in GroupFirstError
+// mode or after Wait returns
+func NewGroup(ctx context.Context, options ...GroupOption) (*Group, context.Context) {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	g := &Group{parent: ctx}
+	for _, option := range options {
+		option(&g.options)
+	}
+	if g.options.limit > 0 {
+		g.sem = make(chan struct{}, g.options.limit)
+	}
+	g.ctx, g.cancel = context.WithCancel(ctx)
+	return g, g.ctx
+}
+
+// Go runs task in a new goroutine with the task context, the panic of task is returned as error, the task is
+// skipped if the group context is already done in GroupFirstError mode, and the cancellation of the parent context
+// is returned by Wait if no task failed
+func (g *Group) Go(task func(ctx context.Context) error) {
+	g.mutex.Lock()
+	index := g.tasks
+	g.tasks++
+	g.mutex.Unlock()
+	if nil != g.sem {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			if GroupFirstError == g.options.mode {
+				g.skip(index)
+				return
+			}
+			g.sem <- struct{}{}
+		}
+	}
+	if GroupFirstError == g.options.mode && nil != g.ctx.Err() {
+		if nil != g.sem {
+			<-g.sem
+		}
+		g.skip(index)
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if nil != g.sem {
+			defer func() { <-g.sem }()
+		}
+		if err := g.invoke(task); nil != err {
+			g.fail(index, err)
+		}
+	}()
+}
+
+func (g *Group) invoke(task func(ctx context.Context) error) (err error) {
+	ctx := g.ctx
+	if g.options.taskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.options.taskTimeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panic: %v", r)
+		}
+	}()
+	return task(ctx)
+}
+
+// skip the task canceled before started, only the cancellation of parent context is recorded
+func (g *Group) skip(index int) {
+	if err := g.parent.Err(); nil != err {
+		g.fail(index, err)
+	}
+}
+
+func (g *Group) fail(index int, err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if GroupFirstError == g.options.mode {
+		if len(g.errs) == 0 {
+			g.errs = append(g.errs, &TaskError{Index: index, Err: err})
+			g.cancel()
+		}
+		return
+	}
+	g.errs = append(g.errs, &TaskError{Index: index, Err: err})
+}
+
+// Wait waits for all tasks finished, the first error is returned as it is in GroupFirstError mode, the errors
+// sorted by task index are returned as Errors of *TaskError in GroupCollectErrors mode
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if len(g.errs) == 0 {
+		return nil
+	}
+	if GroupFirstError == g.options.mode {
+		return g.errs[0].Err
+	}
+	sort.Slice(g.errs, func(i, j int) bool {
+		return g.errs[i].Index < g.errs[j].Index
+	})
+	errs := make(Errors, len(g.errs))
+	for i, err := range g.errs {
+		errs[i] = err
+	}
+	return errs
+}
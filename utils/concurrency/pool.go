@@ -0,0 +1,253 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libpub/golib/logger"
+)
+
+// errors
+var (
+	ErrPoolStopped = errors.New("worker pool stopped")
+	ErrPoolFull    = errors.New("worker pool full")
+)
+
+// PoolOption options of worker pool
+type PoolOption func(*poolOptions)
+
+type poolOptions struct {
+	capacity     int
+	keyed        bool
+	panicHandler func(r interface{})
+}
+
+// Pool bounded worker pool, at most capacity tasks are pending (queued and running), the Submit blocks when
+// the capacity reached so that the producers are backpressured
+type Pool struct {
+	options  poolOptions
+	workers  int
+	queues   []chan func()
+	inflight chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.RWMutex
+	stopped  bool
+	next     uint64
+
+	running   int64
+	submitted int64
+	completed int64
+	panics    int64
+}
+
+// PoolStats metrics of worker pool
+type PoolStats struct {
+	Workers    int
+	Capacity   int
+	Pending    int // queued and running tasks
+	QueueDepth int // queued tasks waiting for workers
+	Running    int
+	Submitted  int64
+	Completed  int64
+	Panics     int64
+}
+
+// WithCapacity the max pending tasks including the running ones, twice of workers by default
+func WithCapacity(capacity int) PoolOption {
+	return func(o *poolOptions) {
+		o.capacity = capacity
+	}
+}
+
+// WithKeyedQueues each worker has its own queue, the tasks submitted by SubmitKey with the same key are run
+// in order by the same worker
+func WithKeyedQueues() PoolOption {
+	return func(o *poolOptions) {
+		o.keyed = true
+	}
+}
+
+// WithPanicHandler handles the panics of tasks, the panics are logged by default
+func WithPanicHandler(handler func(r interface{})) PoolOption {
+	return func(o *poolOptions) {
+		o.panicHandler = handler
+	}
+}
+
+// NewPool worker pool with workers goroutines, at least 1 worker
+func NewPool(workers int, options ...PoolOption) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	opts := poolOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.capacity <= 0 {
+		opts.capacity = workers * 2
+	}
+	if opts.capacity < workers {
+		opts.capacity = workers
+	}
+	p := &Pool{
+		options:  opts,
+		workers:  workers,
+		inflight: make(chan struct{}, opts.capacity),
+	}
+	// the queues never block since the pending tasks are limited by inflight
+	if opts.keyed {
+		p.queues = make([]chan func(), workers)
+		for i := range p.queues {
+			p.queues[i] = make(chan func(), opts.capacity)
+		}
+	} else {
+		p.queues = []chan func(){make(chan func(), opts.capacity)}
+	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.run(p.queues[i%len(p.queues)])
+	}
+	return p
+}
+
+// Submit submits task, blocks while the capacity reached
+func (p *Pool) Submit(task func()) error {
+	return p.SubmitContext(context.Background(), task)
+}
+
+// SubmitContext submits task, blocks while the capacity reached until ctx done
+func (p *Pool) SubmitContext(ctx context.Context, task func()) error {
+	return p.submit(ctx, p.nextKey(), task, true)
+}
+
+// SubmitKey submits task, the tasks of the same key are run in order if the pool is WithKeyedQueues
+func (p *Pool) SubmitKey(key int, task func()) error {
+	if key < 0 {
+		key = -key
+	}
+	return p.submit(context.Background(), uint64(key), task, true)
+}
+
+// TrySubmit submits task without blocking, ErrPoolFull is returned while the capacity reached
+func (p *Pool) TrySubmit(task func()) error {
+	return p.submit(context.Background(), p.nextKey(), task, false)
+}
+
+func (p *Pool) nextKey() uint64 {
+	if !p.options.keyed {
+		return 0
+	}
+	return atomic.AddUint64(&p.next, 1)
+}
+
+func (p *Pool) submit(ctx context.Context, key uint64, task func(), wait bool) error {
+	if nil == task {
+		return errors.New("submit nil task")
+	}
+	if p.isStopped() {
+		return ErrPoolStopped
+	}
+	if wait {
+		select {
+		case p.inflight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	} else {
+		select {
+		case p.inflight <- struct{}{}:
+		default:
+			return ErrPoolFull
+		}
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.stopped {
+		<-p.inflight
+		return ErrPoolStopped
+	}
+	atomic.AddInt64(&p.submitted, 1)
+	p.queues[key%uint64(len(p.queues))] <- task
+	return nil
+}
+
+func (p *Pool) isStopped() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.stopped
+}
+
+// Stop stops accepting tasks and waits for the submitted tasks finished
+func (p *Pool) Stop() {
+	p.StopContext(context.Background())
+}
+
+// StopContext stops accepting tasks and waits for the submitted tasks finished until ctx done
+func (p *Pool) StopContext(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats metrics of pool
+func (p *Pool) Stats() PoolStats {
+	pending := len(p.inflight)
+	running := int(atomic.LoadInt64(&p.running))
+	depth := pending - running
+	if depth < 0 {
+		depth = 0
+	}
+	return PoolStats{
+		Workers:    p.workers,
+		Capacity:   p.options.capacity,
+		Pending:    pending,
+		QueueDepth: depth,
+		Running:    running,
+		Submitted:  atomic.LoadInt64(&p.submitted),
+		Completed:  atomic.LoadInt64(&p.completed),
+		Panics:     atomic.LoadInt64(&p.panics),
+	}
+}
+
+func (p *Pool) run(queue chan func()) {
+	defer p.wg.Done()
+	for task := range queue {
+		p.invoke(task)
+	}
+}
+
+func (p *Pool) invoke(task func()) {
+	atomic.AddInt64(&p.running, 1)
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddInt64(&p.panics, 1)
+			if nil != p.options.panicHandler {
+				p.options.panicHandler(r)
+			} else {
+				logger.Error.Printf("worker pool task panic: %v", r)
+			}
+		}
+		atomic.AddInt64(&p.running, -1)
+		atomic.AddInt64(&p.completed, 1)
+		<-p.inflight
+	}()
+	task()
+}
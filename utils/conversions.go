@@ -0,0 +1,676 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrInvalidConversion the value could not be converted into the type
+var ErrInvalidConversion = errors.New("invalid conversion")
+
+// the layouts of ToTimeE tried in order, the ones without zone are parsed in local time
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"20060102150405",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+var (
+	groupedNumberRegex = regexp.MustCompile(`^[+-]?\d{1,3}(,\d{3})+(\.\d+)?$`)
+	dayDurationRegex   = regexp.MustCompile(`^(\d+)d(.*)$`)
+)
+
+// unix timestamps greater than this are milliseconds
+const unixMillisecondsThreshold = 1e12
+
+func conversionError(val interface{}, typeName string) error {
+	return fmt.Errorf("%w: %v(%T) to %s", ErrInvalidConversion, val, val, typeName)
+}
+
+// ToInt64E converts the numbers, bools and number texts such as "1,234", "0x1f" and "3.7" into int64,
+// the floats are truncated and the empty string is 0
+func ToInt64E(val interface{}) (int64, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case string:
+		return parseInt64Text(v)
+	case []byte:
+		return parseInt64Text(string(v))
+	case json.Number:
+		return parseInt64Text(string(v))
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if value.Uint() > math.MaxInt64 {
+			return 0, conversionError(val, "int64")
+		}
+		return int64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return floatToInt64(value.Float(), val)
+	case reflect.String:
+		return parseInt64Text(value.String())
+	case reflect.Bool:
+		return ToInt64E(value.Bool())
+	}
+	return 0, conversionError(val, "int64")
+}
+
+// ToIntE converts val into int by ToInt64E
+func ToIntE(val interface{}) (int, error) {
+	n, err := ToInt64E(val)
+	if nil != err {
+		return 0, err
+	}
+	if n > math.MaxInt || n < math.MinInt {
+		return 0, conversionError(val, "int")
+	}
+	return int(n), nil
+}
+
+// ToUint64E converts val into uint64, the negative values are invalid
+func ToUint64E(val interface{}) (uint64, error) {
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint(), nil
+	case reflect.String:
+		text := normalizeNumberText(value.String())
+		if n, err := strconv.ParseUint(text, 10, 64); nil == err {
+			return n, nil
+		}
+	}
+	n, err := ToInt64E(val)
+	if nil != err || n < 0 {
+		return 0, conversionError(val, "uint64")
+	}
+	return uint64(n), nil
+}
+
+// ToFloat64E converts the numbers, bools and number texts such as "1,234.5" into float64
+func ToFloat64E(val interface{}) (float64, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case string:
+		return parseFloat64Text(v)
+	case []byte:
+		return parseFloat64Text(string(v))
+	case json.Number:
+		return parseFloat64Text(string(v))
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(value.Uint()), nil
+	case reflect.String:
+		return parseFloat64Text(value.String())
+	case reflect.Bool:
+		if value.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, conversionError(val, "float64")
+}
+
+// ToBoolE converts val into bool, the texts 1, t, true, y, yes, on, enabled are true and
+// 0, f, false, n, no, off, disabled and empty are false case insensitively, the non zero numbers are true
+func ToBoolE(val interface{}) (bool, error) {
+	switch v := val.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case []byte:
+		return parseBoolText(string(v), val)
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.String:
+		return parseBoolText(value.String(), val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() != 0, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() != 0, nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float() != 0, nil
+	}
+	return false, conversionError(val, "bool")
+}
+
+// ToTimeE converts val into time, the numbers are unix seconds or milliseconds if greater than 1e12,
+// the texts are unix timestamps or the common layouts such as RFC3339, "2006-01-02 15:04:05" and "2006-01-02"
+func ToTimeE(val interface{}) (time.Time, error) {
+	switch v := val.(type) {
+	case nil:
+		return time.Time{}, nil
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if nil == v {
+			return time.Time{}, nil
+		}
+		return *v, nil
+	case primitive.DateTime:
+		return v.Time(), nil
+	case string:
+		return parseTimeText(v)
+	case []byte:
+		return parseTimeText(string(v))
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := ToInt64E(value.Interface())
+		if nil != err {
+			return time.Time{}, conversionError(val, "time")
+		}
+		return unixTime(float64(n), n), nil
+	case reflect.Float32, reflect.Float64:
+		return unixTime(value.Float(), int64(value.Float())), nil
+	case reflect.String:
+		return parseTimeText(value.String())
+	}
+	return time.Time{}, conversionError(val, "time")
+}
+
+// ToDurationE converts val into duration, the numbers are milliseconds and the texts are the
+// go durations such as "1m30s" with days such as "2d12h" or milliseconds
+func ToDurationE(val interface{}) (time.Duration, error) {
+	switch v := val.(type) {
+	case nil:
+		return 0, nil
+	case time.Duration:
+		return v, nil
+	case string:
+		return parseDurationText(v)
+	case []byte:
+		return parseDurationText(string(v))
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.String:
+		return parseDurationText(value.String())
+	case reflect.Float32, reflect.Float64:
+		return time.Duration(value.Float() * float64(time.Millisecond)), nil
+	}
+	n, err := ToInt64E(val)
+	if nil != err {
+		return 0, conversionError(val, "duration")
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+// AsInt64 converts val into int64 by ToInt64E, 0 if invalid, the lenient counterpart of ToInt64
+func AsInt64(val interface{}) int64 {
+	n, _ := ToInt64E(val)
+	return n
+}
+
+// AsInt converts val into int by ToIntE, 0 if invalid
+func AsInt(val interface{}) int {
+	n, _ := ToIntE(val)
+	return n
+}
+
+// AsUint64 converts val into uint64 by ToUint64E, 0 if invalid
+func AsUint64(val interface{}) uint64 {
+	n, _ := ToUint64E(val)
+	return n
+}
+
+// AsFloat64 converts val into float64 by ToFloat64E, 0 if invalid, the lenient counterpart of ToDouble
+func AsFloat64(val interface{}) float64 {
+	n, _ := ToFloat64E(val)
+	return n
+}
+
+// AsBool converts val into bool by ToBoolE, false if invalid, the lenient counterpart of ToBoolean
+func AsBool(val interface{}) bool {
+	b, _ := ToBoolE(val)
+	return b
+}
+
+// ToTime converts val into time, the zero time if invalid
+func ToTime(val interface{}) time.Time {
+	t, _ := ToTimeE(val)
+	return t
+}
+
+// ToDuration converts val into duration, 0 if invalid
+func ToDuration(val interface{}) time.Duration {
+	d, _ := ToDurationE(val)
+	return d
+}
+
+// MustInt converts val into int, panics if invalid
+func MustInt(val interface{}) int {
+	n, err := ToIntE(val)
+	if nil != err {
+		panic(err)
+	}
+	return n
+}
+
+// MustInt64 converts val into int64, panics if invalid
+func MustInt64(val interface{}) int64 {
+	n, err := ToInt64E(val)
+	if nil != err {
+		panic(err)
+	}
+	return n
+}
+
+// MustUint64 converts val into uint64, panics if invalid
+func MustUint64(val interface{}) uint64 {
+	n, err := ToUint64E(val)
+	if nil != err {
+		panic(err)
+	}
+	return n
+}
+
+// MustFloat64 converts val into float64, panics if invalid
+func MustFloat64(val interface{}) float64 {
+	n, err := ToFloat64E(val)
+	if nil != err {
+		panic(err)
+	}
+	return n
+}
+
+// MustBool converts val into bool, panics if invalid
+func MustBool(val interface{}) bool {
+	b, err := ToBoolE(val)
+	if nil != err {
+		panic(err)
+	}
+	return b
+}
+
+// MustTime converts val into time, panics if invalid
+func MustTime(val interface{}) time.Time {
+	t, err := ToTimeE(val)
+	if nil != err {
+		panic(err)
+	}
+	return t
+}
+
+// MustDuration converts val into duration, panics if invalid
+func MustDuration(val interface{}) time.Duration {
+	d, err := ToDurationE(val)
+	if nil != err {
+		panic(err)
+	}
+	return d
+}
+
+// ToStringSliceE converts the slices, json array texts or comma separated texts into strings by ToString
+func ToStringSliceE(val interface{}) ([]string, error) {
+	items, err := toItems(val)
+	if nil != err {
+		return nil, err
+	}
+	results := make([]string, 0, len(items))
+	for _, item := range items {
+		results = append(results, ToString(item))
+	}
+	return results, nil
+}
+
+// ToInt64SliceE converts the slices, json array texts or comma separated texts into int64s
+func ToInt64SliceE(val interface{}) ([]int64, error) {
+	items, err := toItems(val)
+	if nil != err {
+		return nil, err
+	}
+	results := make([]int64, 0, len(items))
+	for i, item := range items {
+		n, err := ToInt64E(item)
+		if nil != err {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		results = append(results, n)
+	}
+	return results, nil
+}
+
+// ToFloat64SliceE converts the slices, json array texts or comma separated texts into float64s
+func ToFloat64SliceE(val interface{}) ([]float64, error) {
+	items, err := toItems(val)
+	if nil != err {
+		return nil, err
+	}
+	results := make([]float64, 0, len(items))
+	for i, item := range items {
+		n, err := ToFloat64E(item)
+		if nil != err {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		results = append(results, n)
+	}
+	return results, nil
+}
+
+// ToStringSlice converts val into strings, nil if invalid
+func ToStringSlice(val interface{}) []string {
+	results, _ := ToStringSliceE(val)
+	return results
+}
+
+// ToInt64Slice converts val into int64s, nil if invalid
+func ToInt64Slice(val interface{}) []int64 {
+	results, _ := ToInt64SliceE(val)
+	return results
+}
+
+// ToStringMapE converts the maps, structs by json or json object texts into map[string]interface{}
+func ToStringMapE(val interface{}) (map[string]interface{}, error) {
+	switch v := val.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		return parseJSONObject(v, val)
+	case []byte:
+		return parseJSONObject(string(v), val)
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Map:
+		results := make(map[string]interface{}, value.Len())
+		for _, key := range value.MapKeys() {
+			results[ToString(key.Interface())] = value.MapIndex(key).Interface()
+		}
+		return results, nil
+	case reflect.Struct:
+		bs, err := json.Marshal(val)
+		if nil != err {
+			return nil, err
+		}
+		return parseJSONObject(string(bs), val)
+	}
+	return nil, conversionError(val, "map[string]interface{}")
+}
+
+// ToStringMapStringE converts val into map[string]string by ToStringMapE and ToString
+func ToStringMapStringE(val interface{}) (map[string]string, error) {
+	if v, ok := val.(map[string]string); ok {
+		return v, nil
+	}
+	m, err := ToStringMapE(val)
+	if nil != err {
+		return nil, err
+	}
+	results := make(map[string]string, len(m))
+	for k, v := range m {
+		results[k] = ToString(v)
+	}
+	return results, nil
+}
+
+// ToStringMap converts val into map[string]interface{}, nil if invalid
+func ToStringMap(val interface{}) map[string]interface{} {
+	m, _ := ToStringMapE(val)
+	return m
+}
+
+// ToStringMapString converts val into map[string]string, nil if invalid
+func ToStringMapString(val interface{}) map[string]string {
+	m, _ := ToStringMapStringE(val)
+	return m
+}
+
+// indirectValue the value dereferenced from pointers
+func indirectValue(val interface{}) reflect.Value {
+	value := reflect.ValueOf(val)
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// normalizeNumberText trims the spaces, underscores and the thousands separators of "1,234,567.89"
+func normalizeNumberText(text string) string {
+	text = strings.TrimSpace(text)
+	if groupedNumberRegex.MatchString(text) {
+		text = strings.ReplaceAll(text, ",", "")
+	}
+	return strings.ReplaceAll(text, "_", "")
+}
+
+// ParseInt64 parses the number text as ToInt64E without boxing the text into interface
+func ParseInt64(text string) (int64, error) {
+	return parseInt64Text(text)
+}
+
+// ParseFloat64 parses the number text as ToFloat64E without boxing the text into interface
+func ParseFloat64(text string) (float64, error) {
+	return parseFloat64Text(text)
+}
+
+// ParseBool parses the bool text as ToBoolE without boxing the text into interface
+func ParseBool(text string) (bool, error) {
+	return parseBoolText(text, text)
+}
+
+// ParseTime parses the time text as ToTimeE without boxing the text into interface
+func ParseTime(text string) (time.Time, error) {
+	return parseTimeText(text)
+}
+
+// ParseDuration parses the duration text as ToDurationE without boxing the text into interface
+func ParseDuration(text string) (time.Duration, error) {
+	return parseDurationText(text)
+}
+
+func parseInt64Text(text string) (int64, error) {
+	text = normalizeNumberText(text)
+	if "" == text {
+		return 0, nil
+	}
+	lower := strings.ToLower(strings.TrimLeft(text, "+-"))
+	if strings.HasPrefix(lower, "0x") || strings.HasPrefix(lower, "0b") || strings.HasPrefix(lower, "0o") {
+		if n, err := strconv.ParseInt(text, 0, 64); nil == err {
+			return n, nil
+		}
+		return 0, conversionError(text, "int64")
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); nil == err {
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if nil != err {
+		return 0, conversionError(text, "int64")
+	}
+	return floatToInt64(f, text)
+}
+
+func parseFloat64Text(text string) (float64, error) {
+	text = normalizeNumberText(text)
+	if "" == text {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if nil != err {
+		n, err := parseInt64Text(text)
+		if nil != err {
+			return 0, conversionError(text, "float64")
+		}
+		return float64(n), nil
+	}
+	return f, nil
+}
+
+func floatToInt64(f float64, val interface{}) (int64, error) {
+	if math.IsNaN(f) || f >= math.MaxInt64 || f < math.MinInt64 {
+		return 0, conversionError(val, "int64")
+	}
+	return int64(f), nil
+}
+
+func parseBoolText(text string, val interface{}) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(text)) {
+	case "1", "t", "true", "y", "yes", "on", "enable", "enabled":
+		return true, nil
+	case "", "0", "f", "false", "n", "no", "off", "disable", "disabled":
+		return false, nil
+	}
+	if f, err := parseFloat64Text(text); nil == err {
+		return f != 0, nil
+	}
+	return false, conversionError(val, "bool")
+}
+
+func parseTimeText(text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if "" == text {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); nil == err && len(text) != 8 && len(text) != 14 {
+		// the yyyyMMdd and yyyyMMddHHmmss texts are parsed by layouts
+		return unixTime(float64(n), n), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); nil == err {
+			return t, nil
+		}
+	}
+	if f, err := strconv.ParseFloat(text, 64); nil == err {
+		return unixTime(f, int64(f)), nil
+	}
+	return time.Time{}, conversionError(text, "time")
+}
+
+func unixTime(f float64, n int64) time.Time {
+	if math.Abs(f) > unixMillisecondsThreshold {
+		return time.UnixMilli(n)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second)))
+}
+
+func parseDurationText(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if "" == text {
+		return 0, nil
+	}
+	if n, err := strconv.ParseFloat(text, 64); nil == err {
+		return time.Duration(n * float64(time.Millisecond)), nil
+	}
+	var days time.Duration
+	if matches := dayDurationRegex.FindStringSubmatch(text); nil != matches {
+		n, _ := strconv.ParseInt(matches[1], 10, 64)
+		days = time.Duration(n) * 24 * time.Hour
+		if text = matches[2]; "" == text {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(text)
+	if nil != err {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidConversion, err)
+	}
+	return days + d, nil
+}
+
+// toItems the items of slices, json arrays, comma separated texts or the single value
+func toItems(val interface{}) ([]interface{}, error) {
+	switch v := val.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case []interface{}:
+		return v, nil
+	case []byte:
+		return toItems(string(v))
+	case string:
+		text := strings.TrimSpace(v)
+		if "" == text {
+			return []interface{}{}, nil
+		}
+		if strings.HasPrefix(text, "[") {
+			items := []interface{}{}
+			decoder := json.NewDecoder(strings.NewReader(text))
+			decoder.UseNumber()
+			if err := decoder.Decode(&items); nil != err {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidConversion, err)
+			}
+			return items, nil
+		}
+		items := []interface{}{}
+		for _, item := range strings.Split(text, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+		return items, nil
+	}
+	value := indirectValue(val)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, value.Index(i).Interface())
+		}
+		return items, nil
+	case reflect.Map, reflect.Struct, reflect.Func, reflect.Chan:
+		return nil, conversionError(val, "slice")
+	}
+	return []interface{}{val}, nil
+}
+
+func parseJSONObject(text string, val interface{}) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	if "" == strings.TrimSpace(text) {
+		return results, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err := decoder.Decode(&results); nil != err {
+		return nil, fmt.Errorf("%w: %T to map[string]interface{}: %v", ErrInvalidConversion, val, err)
+	}
+	return results, nil
+}
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
 	"strconv"
 )
 
@@ -23,6 +26,9 @@ func (k AESCryptoModeError) Error() string {
 	return "AES crypto failed with invalid mode: " + strconv.Itoa(int(k))
 }
 
+// ErrAESGCMCiphertextTooShort the ciphertext is shorter than the nonce
+var ErrAESGCMCiphertextTooShort = errors.New("AES GCM ciphertext too short")
+
 // PKCS7Padding do pkcs7 padding for aes encrypt
 func PKCS7Padding(ciphertext []byte, blockSize int) []byte {
 	padding := blockSize - len(ciphertext)%blockSize
@@ -76,6 +82,20 @@ func AesEncrypt(origData, key []byte, iv string, mode AESEncryptoMode) ([]byte,
 	if err != nil {
 		return nil, err
 	}
+	return blockEncrypt(block, origData, key, iv, mode)
+}
+
+// AesDecrypt do aes decrypt
+func AesDecrypt(crypted, key []byte, iv string, mode AESEncryptoMode) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return blockDecrypt(block, crypted, key, iv, mode)
+}
+
+// blockEncrypt do encrypt with pkcs7 padding by the block cipher, the iv is the key prefix if empty
+func blockEncrypt(block cipher.Block, origData, key []byte, iv string, mode AESEncryptoMode) ([]byte, error) {
 	blockSize := block.BlockSize()
 	origData = PKCS7Padding(origData, blockSize)
 	ivBytes := []byte(iv)
@@ -91,12 +111,8 @@ func AesEncrypt(origData, key []byte, iv string, mode AESEncryptoMode) ([]byte,
 	return crypted, nil
 }
 
-// AesDecrypt do aes decrypt
-func AesDecrypt(crypted, key []byte, iv string, mode AESEncryptoMode) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
+// blockDecrypt do decrypt and pkcs7 unpadding by the block cipher
+func blockDecrypt(block cipher.Block, crypted, key []byte, iv string, mode AESEncryptoMode) ([]byte, error) {
 	blockSize := block.BlockSize()
 	ivBytes := []byte(iv)
 	if iv == "" {
@@ -112,6 +128,50 @@ func AesDecrypt(crypted, key []byte, iv string, mode AESEncryptoMode) ([]byte, e
 	return origData, nil
 }
 
+// AesEncryptGCM do aes gcm encrypt with random nonce, the nonce is prepended to the sealed data
+func AesEncryptGCM(origData, key, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcmSeal(aead, origData, additionalData)
+}
+
+// AesDecryptGCM do aes gcm decrypt, fails if the data or additionalData has been tampered
+func AesDecryptGCM(crypted, key, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return gcmOpen(aead, crypted, additionalData)
+}
+
+// gcmSeal seals with random nonce prepended
+func gcmSeal(aead cipher.AEAD, origData, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(origData)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, origData, additionalData), nil
+}
+
+// gcmOpen opens the data sealed by gcmSeal
+func gcmOpen(aead cipher.AEAD, crypted, additionalData []byte) ([]byte, error) {
+	if len(crypted) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrAESGCMCiphertextTooShort
+	}
+	nonce, sealed := crypted[:aead.NonceSize()], crypted[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 type ecb struct {
 	b         cipher.Block
 	blockSize int
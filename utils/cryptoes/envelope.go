@@ -0,0 +1,156 @@
+package cryptoes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Constants of key derivation and envelope encryption
+const (
+	DataKeySize = 32 // AES-256 data key
+
+	DefaultPBKDF2Iterations = 600000
+	DefaultScryptN          = 32768
+	DefaultScryptR          = 8
+	DefaultScryptP          = 1
+)
+
+// ErrInvalidEnvelope the envelope is malformed
+var ErrInvalidEnvelope = errors.New("invalid encryption envelope")
+
+// GenerateRandomBytes n bytes from crypto/rand, could be used as salt or key
+func GenerateRandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// DerivePBKDF2 derives keyLen bytes key from password with PBKDF2-HMAC-SHA256
+func DerivePBKDF2(password, salt []byte, iterations int, keyLen int) []byte {
+	return pbkdf2.Key(password, salt, iterations, keyLen, sha256.New)
+}
+
+// DeriveScrypt derives keyLen bytes key from password with scrypt, n should be power of 2 such as DefaultScryptN
+func DeriveScrypt(password, salt []byte, n, r, p int, keyLen int) ([]byte, error) {
+	return scrypt.Key(password, salt, n, r, p, keyLen)
+}
+
+// DeriveHKDF derives keyLen bytes key from high entropy secret with HKDF-SHA256, info binds the key to the usage
+func DeriveHKDF(secret, salt, info []byte, keyLen int) ([]byte, error) {
+	key := make([]byte, keyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// KeyWrapper wraps and unwraps the data keys of envelope, it could be a local master key or a KMS
+type KeyWrapper interface {
+	WrapKey(dataKey []byte) ([]byte, error)
+	UnwrapKey(wrappedKey []byte) ([]byte, error)
+}
+
+// KeyWrapperFuncs KeyWrapper by callbacks such as calling the encrypt and decrypt api of KMS
+type KeyWrapperFuncs struct {
+	Wrap   func(dataKey []byte) ([]byte, error)
+	Unwrap func(wrappedKey []byte) ([]byte, error)
+}
+
+// WrapKey calls Wrap
+func (f KeyWrapperFuncs) WrapKey(dataKey []byte) ([]byte, error) {
+	return f.Wrap(dataKey)
+}
+
+// UnwrapKey calls Unwrap
+func (f KeyWrapperFuncs) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return f.Unwrap(wrappedKey)
+}
+
+// masterKeyWrapper wraps the data keys with AES-GCM by the master key
+type masterKeyWrapper struct {
+	masterKey []byte
+}
+
+// NewMasterKeyWrapper KeyWrapper with AES-GCM by master key of 16, 24 or 32 bytes
+func NewMasterKeyWrapper(masterKey []byte) KeyWrapper {
+	return &masterKeyWrapper{masterKey: masterKey}
+}
+
+func (w *masterKeyWrapper) WrapKey(dataKey []byte) ([]byte, error) {
+	return AesEncryptGCM(dataKey, w.masterKey, nil)
+}
+
+func (w *masterKeyWrapper) UnwrapKey(wrappedKey []byte) ([]byte, error) {
+	return AesDecryptGCM(wrappedKey, w.masterKey, nil)
+}
+
+// Envelope the data encrypted by one-off data key with the wrapped data key, the []byte fields are base64 encoded in json
+type Envelope struct {
+	WrappedKey []byte `json:"key"`
+	Ciphertext []byte `json:"data"`
+}
+
+// EnvelopeEncrypt encrypts plaintext by new data key with AES-GCM and wraps the data key by wrapper,
+// additionalData is authenticated but not encrypted and could be nil
+func EnvelopeEncrypt(plaintext, additionalData []byte, wrapper KeyWrapper) (*Envelope, error) {
+	dataKey, err := GenerateRandomBytes(DataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := AesEncryptGCM(plaintext, dataKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := wrapper.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{WrappedKey: wrappedKey, Ciphertext: ciphertext}, nil
+}
+
+// EnvelopeDecrypt unwraps the data key by wrapper and decrypts the envelope
+func EnvelopeDecrypt(envelope *Envelope, additionalData []byte, wrapper KeyWrapper) ([]byte, error) {
+	if nil == envelope || len(envelope.WrappedKey) == 0 {
+		return nil, ErrInvalidEnvelope
+	}
+	dataKey, err := wrapper.UnwrapKey(envelope.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return AesDecryptGCM(envelope.Ciphertext, dataKey, additionalData)
+}
+
+// EnvelopeEncryptString encrypts plaintext as base64 encoded json envelope, which could be saved in config files
+func EnvelopeEncryptString(plaintext []byte, wrapper KeyWrapper) (string, error) {
+	envelope, err := EnvelopeEncrypt(plaintext, nil, wrapper)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return Base64Encode(data), nil
+}
+
+// EnvelopeDecryptString decrypts the envelope encrypted by EnvelopeEncryptString
+func EnvelopeDecryptString(encrypted string, wrapper KeyWrapper) ([]byte, error) {
+	data, err := Base64Decode(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	envelope := &Envelope{}
+	if err = json.Unmarshal(data, envelope); err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+	return EnvelopeDecrypt(envelope, nil, wrapper)
+}
@@ -0,0 +1,156 @@
+package cryptoes
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Request signature headers and algorithms
+const (
+	HeaderSignAccessKey = "X-Access-Key"
+	HeaderSignTimestamp = "X-Timestamp"
+	HeaderSignature     = "X-Signature"
+
+	SignAlgHMACSHA256 = "HMAC-SHA256"
+	SignAlgHMACSHA1   = "HMAC-SHA1"
+)
+
+// Errors of request signature
+var (
+	ErrSignatureMissing   = errors.New("request signature missing")
+	ErrSignatureInvalid   = errors.New("request signature is invalid")
+	ErrSignatureExpired   = errors.New("request signature timestamp out of allowed skew")
+	ErrSignatureAccessKey = errors.New("request signature access key is unknown")
+)
+
+// HMACSHA256 hmac of data with sha256
+func HMACSHA256(data, key []byte) []byte {
+	return hmacSum(sha256.New, data, key)
+}
+
+// HMACSHA1 hmac of data with sha1, only for the legacy signature schemes
+func HMACSHA1(data, key []byte) []byte {
+	return hmacSum(sha1.New, data, key)
+}
+
+// HMACSHA256Hex hex encoded HMACSHA256
+func HMACSHA256Hex(data, key []byte) string {
+	return hex.EncodeToString(HMACSHA256(data, key))
+}
+
+// HMACSHA1Hex hex encoded HMACSHA1
+func HMACSHA1Hex(data, key []byte) string {
+	return hex.EncodeToString(HMACSHA1(data, key))
+}
+
+// HMACEqual compares the macs in constant time
+func HMACEqual(mac1, mac2 []byte) bool {
+	return hmac.Equal(mac1, mac2)
+}
+
+func hmacSum(h func() hash.Hash, data, key []byte) []byte {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// CanonicalRequest formats the request as method\npath\nsorted query\nhex(sha256(body)),
+// the query keys and values are sorted and escaped by url.QueryEscape
+func CanonicalRequest(method string, path string, query url.Values, body []byte) string {
+	if path == "" {
+		path = "/"
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, k := range keys {
+		values := append([]string{}, query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	bodyHash := sha256.Sum256(body)
+	return strings.ToUpper(method) + "\n" + path + "\n" + strings.Join(pairs, "&") + "\n" + hex.EncodeToString(bodyHash[:])
+}
+
+// RequestSigner signs the http requests for the api gateway signature schemes,
+// the signature is hex(hmac(timestamp\ncanonical request, secret)) with the access key and timestamp headers
+type RequestSigner struct {
+	AccessKey string
+	SecretKey []byte
+	Algorithm string           // SignAlgHMACSHA256 if empty
+	Now       func() time.Time // time.Now if nil
+}
+
+// NewRequestSigner HMAC-SHA256 request signer
+func NewRequestSigner(accessKey string, secretKey []byte) *RequestSigner {
+	return &RequestSigner{AccessKey: accessKey, SecretKey: secretKey, Algorithm: SignAlgHMACSHA256}
+}
+
+// Sign signature of the request at timestamp in seconds
+func (s *RequestSigner) Sign(method string, path string, query url.Values, body []byte, timestamp int64) string {
+	stringToSign := strconv.FormatInt(timestamp, 10) + "\n" + CanonicalRequest(method, path, query, body)
+	if s.Algorithm == SignAlgHMACSHA1 {
+		return HMACSHA1Hex([]byte(stringToSign), s.SecretKey)
+	}
+	return HMACSHA256Hex([]byte(stringToSign), s.SecretKey)
+}
+
+// SignRequest sets the access key, timestamp and signature headers of req, body is the request body
+func (s *RequestSigner) SignRequest(req *http.Request, body []byte) error {
+	now := time.Now
+	if nil != s.Now {
+		now = s.Now
+	}
+	timestamp := now().Unix()
+	req.Header.Set(HeaderSignAccessKey, s.AccessKey)
+	req.Header.Set(HeaderSignTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, s.Sign(req.Method, req.URL.EscapedPath(), req.URL.Query(), body, timestamp))
+	return nil
+}
+
+// VerifyRequest verifies the signature headers of req signed by SignRequest, the timestamp should be within maxSkew
+func (s *RequestSigner) VerifyRequest(req *http.Request, body []byte, maxSkew time.Duration) error {
+	signature := req.Header.Get(HeaderSignature)
+	timestampStr := req.Header.Get(HeaderSignTimestamp)
+	if signature == "" || timestampStr == "" {
+		return ErrSignatureMissing
+	}
+	if req.Header.Get(HeaderSignAccessKey) != s.AccessKey {
+		return ErrSignatureAccessKey
+	}
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	now := time.Now
+	if nil != s.Now {
+		now = s.Now
+	}
+	if maxSkew > 0 {
+		skew := now().Sub(time.Unix(timestamp, 0))
+		if skew > maxSkew || skew < -maxSkew {
+			return ErrSignatureExpired
+		}
+	}
+	expected := s.Sign(req.Method, req.URL.EscapedPath(), req.URL.Query(), body, timestamp)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
@@ -41,7 +41,27 @@ func AESDecryptCBC(crypted, key string, iv string) ([]byte, error) {
 	return origData, nil
 }
 
+// AESEncryptGCM authenticated encrypt with random nonce, additionalData is authenticated but not encrypted and could be nil
+func AESEncryptGCM(origData []byte, key string, additionalData []byte) (string, error) {
+	crypted, err := AesEncryptGCM(origData, []byte(key), additionalData)
+	if err != nil {
+		return "", err
+	}
+	return Base64Encode(crypted), nil
+}
+
+// AESDecryptGCM authenticated decrypt, additionalData should be the same as encrypting
+func AESDecryptGCM(crypted, key string, additionalData []byte) ([]byte, error) {
+	cryptedBytes, err := Base64Decode(crypted)
+	if err != nil {
+		return nil, err
+	}
+	return AesDecryptGCM(cryptedBytes, []byte(key), additionalData)
+}
+
 // AESEncryptECB encrypt
+//
+// Deprecated: ECB leaks the patterns of plaintext and is not authenticated, use AESEncryptGCM for new use.
 func AESEncryptECB(origData []byte, key string) (string, error) {
 	crypted, err := AesEncrypt(origData, []byte(key), "", AESEncryptoModeECB)
 	if err != nil {
@@ -51,6 +71,8 @@ func AESEncryptECB(origData []byte, key string) (string, error) {
 }
 
 // AESDecryptECB aes decrypt
+//
+// Deprecated: ECB leaks the patterns of plaintext and is not authenticated, use AESDecryptGCM for new use.
 func AESDecryptECB(crypted, key string) ([]byte, error) {
 	cryptedBytes, err := Base64Decode(crypted)
 	if err != nil {
@@ -63,6 +85,24 @@ func AESDecryptECB(crypted, key string) ([]byte, error) {
 	return origData, nil
 }
 
+// SM4EncryptCBC encrypt
+func SM4EncryptCBC(origData []byte, key string, iv string) (string, error) {
+	crypted, err := SM4Encrypt(origData, []byte(key), iv, AESEncryptoModeCBC)
+	if err != nil {
+		return "", err
+	}
+	return Base64Encode(crypted), nil
+}
+
+// SM4DecryptCBC decrypt
+func SM4DecryptCBC(crypted, key string, iv string) ([]byte, error) {
+	cryptedBytes, err := Base64Decode(crypted)
+	if err != nil {
+		return nil, err
+	}
+	return SM4Decrypt(cryptedBytes, []byte(key), iv, AESEncryptoModeCBC)
+}
+
 // RSAEncryptNE rsa encrypt
 func RSAEncryptNE(origData []byte, n *big.Int, e int) (string, error) {
 	pubKey := &rsa.PublicKey{
@@ -0,0 +1,373 @@
+package cryptoes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWT signing algorithms
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+	JWTAlgES256 = "ES256"
+)
+
+// Errors of JWT
+var (
+	ErrJWTMalformed        = errors.New("malformed jwt")
+	ErrJWTUnsupportedAlg   = errors.New("unsupported jwt signing algorithm")
+	ErrJWTInvalidKey       = errors.New("invalid jwt key for the algorithm")
+	ErrJWTKeyNotFound      = errors.New("jwt key not found")
+	ErrJWTSignatureInvalid = errors.New("jwt signature is invalid")
+	ErrJWTExpired          = errors.New("jwt is expired")
+	ErrJWTNotValidYet      = errors.New("jwt is not valid yet")
+	ErrJWTInvalidIssuer    = errors.New("jwt issuer is invalid")
+	ErrJWTInvalidAudience  = errors.New("jwt audience is invalid")
+)
+
+// JWTAudience the aud claim, which could be encoded as single string or array
+type JWTAudience []string
+
+// UnmarshalJSON accepts both "aud" and ["aud1","aud2"]
+func (a *JWTAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JWTAudience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = JWTAudience(multiple)
+	return nil
+}
+
+// JWTClaims the registered claims, the other claims are kept in Extra
+type JWTClaims struct {
+	Issuer    string                 `json:"iss,omitempty"`
+	Subject   string                 `json:"sub,omitempty"`
+	Audience  JWTAudience            `json:"aud,omitempty"`
+	ExpiresAt int64                  `json:"exp,omitempty"`
+	NotBefore int64                  `json:"nbf,omitempty"`
+	IssuedAt  int64                  `json:"iat,omitempty"`
+	ID        string                 `json:"jti,omitempty"`
+	Extra     map[string]interface{} `json:"-"`
+}
+
+type jwtRegisteredClaims JWTClaims
+
+// MarshalJSON encodes the registered claims with Extra
+func (c JWTClaims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(jwtRegisteredClaims(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+	values := map[string]interface{}{}
+	for k, v := range c.Extra {
+		values[k] = v
+	}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON decodes the registered claims and keeps the others in Extra
+func (c *JWTClaims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*jwtRegisteredClaims)(c)); err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	for _, name := range []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"} {
+		delete(values, name)
+	}
+	c.Extra = nil
+	if len(values) > 0 {
+		c.Extra = values
+	}
+	return nil
+}
+
+// JWTKey signing and verification key, SignKey is []byte for HS256, *rsa.PrivateKey for RS256 and *ecdsa.PrivateKey for ES256,
+// VerifyKey is []byte, *rsa.PublicKey or *ecdsa.PublicKey, SignKey could be nil for the verification only keys
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// NewHMACJWTKey HS256 key
+func NewHMACJWTKey(kid string, secret []byte) *JWTKey {
+	return &JWTKey{ID: kid, Algorithm: JWTAlgHS256, SignKey: secret, VerifyKey: secret}
+}
+
+// NewRSAJWTKey RS256 key
+func NewRSAJWTKey(kid string, privKey *rsa.PrivateKey) *JWTKey {
+	return &JWTKey{ID: kid, Algorithm: JWTAlgRS256, SignKey: privKey, VerifyKey: &privKey.PublicKey}
+}
+
+// NewECDSAJWTKey ES256 key, the curve should be P-256
+func NewECDSAJWTKey(kid string, privKey *ecdsa.PrivateKey) *JWTKey {
+	return &JWTKey{ID: kid, Algorithm: JWTAlgES256, SignKey: privKey, VerifyKey: &privKey.PublicKey}
+}
+
+// JWTKeyProvider provides the current signing key and the verification keys by kid, which makes key rotation possible
+type JWTKeyProvider interface {
+	SigningKey() (*JWTKey, error)
+	VerificationKey(kid string) (*JWTKey, error)
+}
+
+// JWTKeySet JWTKeyProvider holding the keys in memory, the tokens signed by the rotated keys could be verified until the keys are removed
+type JWTKeySet struct {
+	keys    map[string]*JWTKey
+	current string
+	m       sync.RWMutex
+}
+
+// NewJWTKeySet with keys, the first key is the signing key
+func NewJWTKeySet(keys ...*JWTKey) *JWTKeySet {
+	s := &JWTKeySet{keys: map[string]*JWTKey{}}
+	for i, key := range keys {
+		s.AddKey(key, i == 0)
+	}
+	return s
+}
+
+// AddKey adds the key, signs the new tokens with it if current
+func (s *JWTKeySet) AddKey(key *JWTKey, current bool) {
+	s.m.Lock()
+	s.keys[key.ID] = key
+	if current {
+		s.current = key.ID
+	}
+	s.m.Unlock()
+}
+
+// RemoveKey removes the key, the tokens signed by it would not be verified any more
+func (s *JWTKeySet) RemoveKey(kid string) {
+	s.m.Lock()
+	delete(s.keys, kid)
+	if s.current == kid {
+		s.current = ""
+	}
+	s.m.Unlock()
+}
+
+// SigningKey the current signing key
+func (s *JWTKeySet) SigningKey() (*JWTKey, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	key, ok := s.keys[s.current]
+	if !ok || nil == key.SignKey {
+		return nil, ErrJWTKeyNotFound
+	}
+	return key, nil
+}
+
+// VerificationKey the key of kid
+func (s *JWTKeySet) VerificationKey(kid string) (*JWTKey, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrJWTKeyNotFound
+	}
+	return key, nil
+}
+
+// JWTValidateOptions the claims validation of JWTVerify
+type JWTValidateOptions struct {
+	Leeway   time.Duration    // allowed clock skew while checking exp, nbf
+	Issuer   string           // the expected iss if not empty
+	Audience string           // the expected aud if not empty
+	Now      func() time.Time // time.Now if nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// JWTSign signs the claims with the signing key of provider, the kid header is set as the key ID
+func JWTSign(claims *JWTClaims, provider JWTKeyProvider) (string, error) {
+	key, err := provider.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	header, err := json.Marshal(jwtHeader{Alg: key.Algorithm, Typ: "JWT", Kid: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := jwtSignature(key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// JWTVerify verifies the signature by the key of kid and validates the claims
+func JWTVerify(token string, provider JWTKeyProvider, opts JWTValidateOptions) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	header := jwtHeader{}
+	if err = json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	key, err := provider.VerificationKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	// only accepts the algorithm of the key to prevent alg confusion
+	if header.Alg != key.Algorithm {
+		return nil, ErrJWTUnsupportedAlg
+	}
+	if err = jwtVerifySignature(key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+	claims := &JWTClaims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+	if err = claims.Validate(opts); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Validate checks exp, nbf with leeway, and iss, aud if expected
+func (c *JWTClaims) Validate(opts JWTValidateOptions) error {
+	now := time.Now()
+	if nil != opts.Now {
+		now = opts.Now()
+	}
+	if c.ExpiresAt != 0 && !now.Before(time.Unix(c.ExpiresAt, 0).Add(opts.Leeway)) {
+		return ErrJWTExpired
+	}
+	if c.NotBefore != 0 && now.Add(opts.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return ErrJWTNotValidYet
+	}
+	if opts.Issuer != "" && c.Issuer != opts.Issuer {
+		return ErrJWTInvalidIssuer
+	}
+	if opts.Audience != "" {
+		for _, aud := range c.Audience {
+			if aud == opts.Audience {
+				return nil
+			}
+		}
+		return ErrJWTInvalidAudience
+	}
+	return nil
+}
+
+func jwtSignature(key *JWTKey, signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	switch key.Algorithm {
+	case JWTAlgHS256:
+		secret, ok := key.SignKey.([]byte)
+		if !ok {
+			return nil, ErrJWTInvalidKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case JWTAlgRS256:
+		privKey, ok := key.SignKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrJWTInvalidKey
+		}
+		return rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, digest[:])
+	case JWTAlgES256:
+		privKey, ok := key.SignKey.(*ecdsa.PrivateKey)
+		if !ok || privKey.Curve.Params().BitSize != 256 {
+			return nil, ErrJWTInvalidKey
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, privKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// the signature is fixed length R || S
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, ErrJWTUnsupportedAlg
+}
+
+func jwtVerifySignature(key *JWTKey, signingInput []byte, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	switch key.Algorithm {
+	case JWTAlgHS256:
+		secret, ok := key.VerifyKey.([]byte)
+		if !ok {
+			return ErrJWTInvalidKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrJWTSignatureInvalid
+		}
+		return nil
+	case JWTAlgRS256:
+		pubKey, ok := key.VerifyKey.(*rsa.PublicKey)
+		if !ok {
+			return ErrJWTInvalidKey
+		}
+		if rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrJWTSignatureInvalid
+		}
+		return nil
+	case JWTAlgES256:
+		pubKey, ok := key.VerifyKey.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrJWTInvalidKey
+		}
+		if len(signature) != 64 {
+			return ErrJWTSignatureInvalid
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pubKey, digest[:], r, s) {
+			return ErrJWTSignatureInvalid
+		}
+		return nil
+	}
+	return ErrJWTUnsupportedAlg
+}
@@ -0,0 +1,158 @@
+package cryptoes
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+// Errors
+var (
+	ErrInvalidPasswordHash     = errors.New("invalid password hash")
+	ErrUnsupportedPasswordHash = errors.New("unsupported password hash algorithm")
+)
+
+// PasswordHashOptions options of password hashing
+type PasswordHashOptions struct {
+	Algorithm     string // PasswordAlgorithmArgon2id or PasswordAlgorithmBcrypt
+	BcryptCost    int
+	Argon2Time    uint32 // iterations
+	Argon2Memory  uint32 // memory in KiB
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+// DefaultPasswordHashOptions argon2id with the parameters recommended by RFC 9106
+var DefaultPasswordHashOptions = PasswordHashOptions{
+	Algorithm:     PasswordAlgorithmArgon2id,
+	BcryptCost:    bcrypt.DefaultCost,
+	Argon2Time:    1,
+	Argon2Memory:  64 * 1024,
+	Argon2Threads: 4,
+	Argon2KeyLen:  32,
+	Argon2SaltLen: 16,
+}
+
+// argon2Params the parameters encoded in argon2id hash
+type argon2Params struct {
+	version int
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// HashPassword hashes the password with DefaultPasswordHashOptions
+func HashPassword(password string) (string, error) {
+	return HashPasswordWithOptions(password, DefaultPasswordHashOptions)
+}
+
+// HashPasswordWithOptions hashes the password as $argon2id$v=19$m=65536,t=1,p=4$salt$key or bcrypt $2a$10$...
+func HashPasswordWithOptions(password string, opts PasswordHashOptions) (string, error) {
+	switch opts.Algorithm {
+	case PasswordAlgorithmBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), opts.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	case PasswordAlgorithmArgon2id, "":
+		salt := make([]byte, opts.Argon2SaltLen)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, opts.Argon2Time, opts.Argon2Memory, opts.Argon2Threads, opts.Argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, opts.Argon2Memory, opts.Argon2Time, opts.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	return "", ErrUnsupportedPasswordHash
+}
+
+// VerifyPassword checks the password against the hash generated by HashPassword, returns error only if the hash is malformed
+func VerifyPassword(password string, hash string) (bool, error) {
+	switch passwordHashAlgorithm(hash) {
+	case PasswordAlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case PasswordAlgorithmArgon2id:
+		params, err := parseArgon2Hash(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+		return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+	}
+	return false, ErrUnsupportedPasswordHash
+}
+
+// PasswordNeedsRehash whether the hash was generated by other algorithm or weaker parameters than opts, the password should be rehashed after verified
+func PasswordNeedsRehash(hash string, opts PasswordHashOptions) bool {
+	algorithm := opts.Algorithm
+	if algorithm == "" {
+		algorithm = PasswordAlgorithmArgon2id
+	}
+	if passwordHashAlgorithm(hash) != algorithm {
+		return true
+	}
+	switch algorithm {
+	case PasswordAlgorithmBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < opts.BcryptCost
+	case PasswordAlgorithmArgon2id:
+		params, err := parseArgon2Hash(hash)
+		return err != nil || params.version != argon2.Version || params.memory < opts.Argon2Memory || params.time < opts.Argon2Time ||
+			params.threads < opts.Argon2Threads || uint32(len(params.key)) < opts.Argon2KeyLen || uint32(len(params.salt)) < opts.Argon2SaltLen
+	}
+	return true
+}
+
+func passwordHashAlgorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return PasswordAlgorithmArgon2id
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return PasswordAlgorithmBcrypt
+	}
+	return ""
+}
+
+// parseArgon2Hash parses $argon2id$v=19$m=65536,t=1,p=4$salt$key
+func parseArgon2Hash(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, ErrInvalidPasswordHash
+	}
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &params.version); err != nil {
+		return nil, ErrInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, ErrInvalidPasswordHash
+	}
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidPasswordHash
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, ErrInvalidPasswordHash
+	}
+	return params, nil
+}
@@ -0,0 +1,145 @@
+package cryptoes
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+// PEM block types
+const (
+	PEMTypeRSAPrivateKey = "RSA PRIVATE KEY"
+	PEMTypePrivateKey    = "PRIVATE KEY"
+	PEMTypeRSAPublicKey  = "RSA PUBLIC KEY"
+	PEMTypePublicKey     = "PUBLIC KEY"
+	PEMTypeCertificate   = "CERTIFICATE"
+)
+
+// Errors
+var (
+	ErrInvalidPEM = errors.New("invalid PEM data")
+	ErrNotRSAKey  = errors.New("the key is not a RSA key")
+)
+
+// GenerateRSAKey generates RSA private key with bits such as 2048
+func GenerateRSAKey(bits int) (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, bits)
+}
+
+// RSAEncryptOAEP encrypts with RSA-OAEP using SHA-256, the data should be shorter than key size - 66 bytes
+func RSAEncryptOAEP(origData []byte, pubKey *rsa.PublicKey) ([]byte, error) {
+	return rsa.EncryptOAEP(sha256.New(), rand.Reader, pubKey, origData, nil)
+}
+
+// RSADecryptOAEP decrypts the data encrypted by RSAEncryptOAEP
+func RSADecryptOAEP(crypted []byte, privKey *rsa.PrivateKey) ([]byte, error) {
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, privKey, crypted, nil)
+}
+
+// RSASignPSS signs the SHA-256 digest of data with RSA-PSS
+func RSASignPSS(data []byte, privKey *rsa.PrivateKey) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPSS(rand.Reader, privKey, crypto.SHA256, digest[:], nil)
+}
+
+// RSAVerifyPSS verifies the RSA-PSS signature of data, returns nil if the signature is valid
+func RSAVerifyPSS(data []byte, signature []byte, pubKey *rsa.PublicKey) error {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPSS(pubKey, crypto.SHA256, digest[:], signature, nil)
+}
+
+// RSASignPKCS1v15 signs the SHA-256 digest of data with RSASSA-PKCS1-v1_5
+func RSASignPKCS1v15(data []byte, privKey *rsa.PrivateKey) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, privKey, crypto.SHA256, digest[:])
+}
+
+// RSAVerifyPKCS1v15 verifies the RSASSA-PKCS1-v1_5 signature of data, returns nil if the signature is valid
+func RSAVerifyPKCS1v15(data []byte, signature []byte, pubKey *rsa.PublicKey) error {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, digest[:], signature)
+}
+
+// RSAPrivateKeyToPEM encodes the private key as PKCS#1 PEM
+func RSAPrivateKeyToPEM(privKey *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PEMTypeRSAPrivateKey,
+		Bytes: x509.MarshalPKCS1PrivateKey(privKey),
+	})
+}
+
+// RSAPrivateKeyToPKCS8PEM encodes the private key as PKCS#8 PEM
+func RSAPrivateKeyToPKCS8PEM(privKey *rsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: der}), nil
+}
+
+// RSAPublicKeyToPEM encodes the public key as PKIX PEM
+func RSAPublicKeyToPEM(pubKey *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePublicKey, Bytes: der}), nil
+}
+
+// ParseRSAPrivateKeyPEM parses PKCS#1 or PKCS#8 PEM private key
+func ParseRSAPrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if nil == block {
+		return nil, ErrInvalidPEM
+	}
+	switch block.Type {
+	case PEMTypeRSAPrivateKey:
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case PEMTypePrivateKey:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		privKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrNotRSAKey
+		}
+		return privKey, nil
+	}
+	return nil, ErrInvalidPEM
+}
+
+// ParseRSAPublicKeyPEM parses PKIX or PKCS#1 PEM public key, or the public key of PEM certificate
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if nil == block {
+		return nil, ErrInvalidPEM
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case PEMTypeRSAPublicKey:
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case PEMTypePublicKey:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case PEMTypeCertificate:
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, ErrInvalidPEM
+	}
+	if err != nil {
+		return nil, err
+	}
+	pubKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrNotRSAKey
+	}
+	return pubKey, nil
+}
@@ -0,0 +1,283 @@
+package cryptoes
+
+import (
+	"bytes"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+)
+
+// SM2DefaultUID the default user id of SM2 signature
+const SM2DefaultUID = "1234567812345678"
+
+// Errors of SM2
+var (
+	ErrSM2InvalidPublicKey  = errors.New("invalid SM2 public key")
+	ErrSM2InvalidPrivateKey = errors.New("invalid SM2 private key")
+	ErrSM2InvalidCiphertext = errors.New("invalid SM2 ciphertext")
+	ErrSM2DecryptFailed     = errors.New("SM2 decryption failed")
+)
+
+var (
+	sm2Curve     *elliptic.CurveParams
+	sm2CurveOnce sync.Once
+)
+
+// SM2PublicKey SM2 public key
+type SM2PublicKey struct {
+	X, Y *big.Int
+}
+
+// SM2PrivateKey SM2 private key
+type SM2PrivateKey struct {
+	SM2PublicKey
+	D *big.Int
+}
+
+type sm2Signature struct {
+	R, S *big.Int
+}
+
+// SM2Curve the sm2p256v1 curve of GB/T 32918.5-2017, its a = -3 so that the generic elliptic.CurveParams could be used.
+// The generic implementation is NOT constant-time, the scalar multiplications leak the timing of private keys and
+// nonces, so that the SM2 functions of this package are for interoperability and testing, NOT safe for production keys
+func SM2Curve() elliptic.Curve {
+	sm2CurveOnce.Do(func() {
+		sm2Curve = &elliptic.CurveParams{Name: "sm2p256v1", BitSize: 256}
+		sm2Curve.P, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF", 16)
+		sm2Curve.N, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123", 16)
+		sm2Curve.B, _ = new(big.Int).SetString("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93", 16)
+		sm2Curve.Gx, _ = new(big.Int).SetString("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7", 16)
+		sm2Curve.Gy, _ = new(big.Int).SetString("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0", 16)
+	})
+	return sm2Curve
+}
+
+// GenerateSM2Key generates SM2 private key, NOT safe for production keys, see SM2Curve
+func GenerateSM2Key() (*SM2PrivateKey, error) {
+	curve := SM2Curve()
+	// d is in [1, n-2]
+	max := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+	d, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	d.Add(d, big.NewInt(1))
+	return NewSM2PrivateKey(d.FillBytes(make([]byte, 32)))
+}
+
+// NewSM2PrivateKey the private key of 32 bytes big-endian d
+func NewSM2PrivateKey(d []byte) (*SM2PrivateKey, error) {
+	curve := SM2Curve()
+	k := new(big.Int).SetBytes(d)
+	if k.Sign() <= 0 || k.Cmp(new(big.Int).Sub(curve.Params().N, big.NewInt(1))) >= 0 {
+		return nil, ErrSM2InvalidPrivateKey
+	}
+	x, y := curve.ScalarBaseMult(k.FillBytes(make([]byte, 32)))
+	return &SM2PrivateKey{SM2PublicKey: SM2PublicKey{X: x, Y: y}, D: k}, nil
+}
+
+// Bytes the 32 bytes big-endian d
+func (priv *SM2PrivateKey) Bytes() []byte {
+	return priv.D.FillBytes(make([]byte, 32))
+}
+
+// ParseSM2PublicKey parses the uncompressed public key 04||X||Y
+func ParseSM2PublicKey(data []byte) (*SM2PublicKey, error) {
+	if len(data) != 65 || data[0] != 4 {
+		return nil, ErrSM2InvalidPublicKey
+	}
+	pub := &SM2PublicKey{X: new(big.Int).SetBytes(data[1:33]), Y: new(big.Int).SetBytes(data[33:])}
+	if !SM2Curve().IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrSM2InvalidPublicKey
+	}
+	return pub, nil
+}
+
+// Bytes the uncompressed public key 04||X||Y
+func (pub *SM2PublicKey) Bytes() []byte {
+	b := make([]byte, 65)
+	b[0] = 4
+	pub.X.FillBytes(b[1:33])
+	pub.Y.FillBytes(b[33:])
+	return b
+}
+
+// SM2Sign signs msg with the user id, uid would be SM2DefaultUID if empty, the signature is ASN.1 DER encoded (r, s),
+// the signing is not constant-time, see SM2Curve
+func SM2Sign(priv *SM2PrivateKey, msg []byte, uid []byte) ([]byte, error) {
+	curve := SM2Curve()
+	n := curve.Params().N
+	e := sm2Digest(&priv.SM2PublicKey, msg, uid)
+	// (1+d)^-1
+	dInv := new(big.Int).ModInverse(new(big.Int).Add(priv.D, big.NewInt(1)), n)
+	if nil == dInv {
+		return nil, ErrSM2InvalidPrivateKey
+	}
+	for {
+		k, err := sm2RandScalar(n)
+		if err != nil {
+			return nil, err
+		}
+		x1, _ := curve.ScalarBaseMult(k.FillBytes(make([]byte, 32)))
+		r := new(big.Int).Add(e, x1)
+		r.Mod(r, n)
+		if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+			continue
+		}
+		// s = (1+d)^-1 * (k - r*d) mod n
+		s := new(big.Int).Mul(r, priv.D)
+		s.Sub(k, s)
+		s.Mul(s, dInv)
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		return asn1.Marshal(sm2Signature{R: r, S: s})
+	}
+}
+
+// SM2Verify verifies the signature of msg signed by SM2Sign with the same uid
+func SM2Verify(pub *SM2PublicKey, msg []byte, uid []byte, signature []byte) bool {
+	sig := sm2Signature{}
+	if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) > 0 {
+		return false
+	}
+	curve := SM2Curve()
+	n := curve.Params().N
+	one := big.NewInt(1)
+	if sig.R.Cmp(one) < 0 || sig.R.Cmp(n) >= 0 || sig.S.Cmp(one) < 0 || sig.S.Cmp(n) >= 0 {
+		return false
+	}
+	t := new(big.Int).Add(sig.R, sig.S)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	e := sm2Digest(pub, msg, uid)
+	x1, y1 := curve.ScalarBaseMult(sig.S.FillBytes(make([]byte, 32)))
+	x2, y2 := curve.ScalarMult(pub.X, pub.Y, t.FillBytes(make([]byte, 32)))
+	x, _ := curve.Add(x1, y1, x2, y2)
+	x.Add(x, e)
+	x.Mod(x, n)
+	return x.Cmp(sig.R) == 0
+}
+
+// SM2Encrypt encrypts msg as C1||C3||C2 of GB/T 32918.4-2016, C1 is the uncompressed point
+func SM2Encrypt(pub *SM2PublicKey, msg []byte) ([]byte, error) {
+	curve := SM2Curve()
+	if nil == pub || !curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, ErrSM2InvalidPublicKey
+	}
+	for {
+		k, err := sm2RandScalar(curve.Params().N)
+		if err != nil {
+			return nil, err
+		}
+		kBytes := k.FillBytes(make([]byte, 32))
+		x1, y1 := curve.ScalarBaseMult(kBytes)
+		x2, y2 := curve.ScalarMult(pub.X, pub.Y, kBytes)
+		x2Bytes, y2Bytes := x2.FillBytes(make([]byte, 32)), y2.FillBytes(make([]byte, 32))
+		t, ok := sm2KDF(append(append([]byte{}, x2Bytes...), y2Bytes...), len(msg))
+		if !ok {
+			continue
+		}
+		c1 := (&SM2PublicKey{X: x1, Y: y1}).Bytes()
+		c3 := sm2C3(x2Bytes, msg, y2Bytes)
+		c2 := make([]byte, len(msg))
+		for i := range msg {
+			c2[i] = msg[i] ^ t[i]
+		}
+		result := make([]byte, 0, len(c1)+len(c3)+len(c2))
+		return append(append(append(result, c1...), c3...), c2...), nil
+	}
+}
+
+// SM2Decrypt decrypts the C1||C3||C2 ciphertext encrypted by SM2Encrypt, the decryption is not constant-time, see SM2Curve
+func SM2Decrypt(priv *SM2PrivateKey, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 65+SM3Size {
+		return nil, ErrSM2InvalidCiphertext
+	}
+	c1, err := ParseSM2PublicKey(ciphertext[:65])
+	if err != nil {
+		return nil, ErrSM2InvalidCiphertext
+	}
+	c3, c2 := ciphertext[65:65+SM3Size], ciphertext[65+SM3Size:]
+	x2, y2 := SM2Curve().ScalarMult(c1.X, c1.Y, priv.Bytes())
+	x2Bytes, y2Bytes := x2.FillBytes(make([]byte, 32)), y2.FillBytes(make([]byte, 32))
+	t, ok := sm2KDF(append(append([]byte{}, x2Bytes...), y2Bytes...), len(c2))
+	if !ok {
+		return nil, ErrSM2DecryptFailed
+	}
+	msg := make([]byte, len(c2))
+	for i := range c2 {
+		msg[i] = c2[i] ^ t[i]
+	}
+	if !bytes.Equal(sm2C3(x2Bytes, msg, y2Bytes), c3) {
+		return nil, ErrSM2DecryptFailed
+	}
+	return msg, nil
+}
+
+// sm2Digest e = SM3(ZA||M), ZA = SM3(ENTLA||ID||a||b||Gx||Gy||XA||YA)
+func sm2Digest(pub *SM2PublicKey, msg []byte, uid []byte) *big.Int {
+	if len(uid) == 0 {
+		uid = []byte(SM2DefaultUID)
+	}
+	params := SM2Curve().Params()
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+	h := NewSM3()
+	var entl [2]byte
+	binary.BigEndian.PutUint16(entl[:], uint16(len(uid)*8))
+	h.Write(entl[:])
+	h.Write(uid)
+	for _, v := range []*big.Int{a, params.B, params.Gx, params.Gy, pub.X, pub.Y} {
+		h.Write(v.FillBytes(make([]byte, 32)))
+	}
+	za := h.Sum(nil)
+	h.Reset()
+	h.Write(za)
+	h.Write(msg)
+	return new(big.Int).SetBytes(h.Sum(nil))
+}
+
+// sm2KDF the key derivation function with SM3, returns false if the derived key is all zero
+func sm2KDF(z []byte, klen int) ([]byte, bool) {
+	key := make([]byte, 0, klen+SM3Size)
+	var ct [4]byte
+	for i := uint32(1); len(key) < klen; i++ {
+		binary.BigEndian.PutUint32(ct[:], i)
+		h := NewSM3()
+		h.Write(z)
+		h.Write(ct[:])
+		key = h.Sum(key)
+	}
+	key = key[:klen]
+	for _, b := range key {
+		if b != 0 {
+			return key, true
+		}
+	}
+	return key, klen == 0
+}
+
+func sm2C3(x2, msg, y2 []byte) []byte {
+	h := NewSM3()
+	h.Write(x2)
+	h.Write(msg)
+	h.Write(y2)
+	return h.Sum(nil)
+}
+
+// sm2RandScalar random k in [1, n-1]
+func sm2RandScalar(n *big.Int) (*big.Int, error) {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(1)))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
@@ -0,0 +1,146 @@
+package cryptoes
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Constants of SM3
+const (
+	SM3Size      = 32
+	SM3BlockSize = 64
+)
+
+var sm3IV = [8]uint32{0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600, 0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e}
+
+// sm3Digest SM3 hash of GB/T 32905-2016
+type sm3Digest struct {
+	v   [8]uint32
+	buf [SM3BlockSize]byte
+	nx  int
+	len uint64
+}
+
+// NewSM3 returns the SM3 hash.Hash
+func NewSM3() hash.Hash {
+	d := &sm3Digest{}
+	d.Reset()
+	return d
+}
+
+// SM3Sum SM3 checksum of data
+func SM3Sum(data []byte) [SM3Size]byte {
+	d := &sm3Digest{}
+	d.Reset()
+	d.Write(data)
+	var sum [SM3Size]byte
+	d.checkSum(sum[:0])
+	return sum
+}
+
+func (d *sm3Digest) Reset() {
+	d.v = sm3IV
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *sm3Digest) Size() int { return SM3Size }
+
+func (d *sm3Digest) BlockSize() int { return SM3BlockSize }
+
+func (d *sm3Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.buf[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx == SM3BlockSize {
+			d.block(d.buf[:])
+			d.nx = 0
+		}
+	}
+	for len(p) >= SM3BlockSize {
+		d.block(p[:SM3BlockSize])
+		p = p[SM3BlockSize:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.buf[:], p)
+	}
+	return n, nil
+}
+
+func (d *sm3Digest) Sum(in []byte) []byte {
+	// sum on a copy so that the caller could keep writing
+	d0 := *d
+	return d0.checkSum(in)
+}
+
+func (d *sm3Digest) checkSum(in []byte) []byte {
+	length := d.len
+	var tmp [SM3BlockSize + 8]byte
+	tmp[0] = 0x80
+	padding := 56 - int(length%64)
+	if padding <= 0 {
+		padding += 64
+	}
+	binary.BigEndian.PutUint64(tmp[padding:], length<<3)
+	d.Write(tmp[:padding+8])
+	for _, v := range d.v {
+		in = binary.BigEndian.AppendUint32(in, v)
+	}
+	return in
+}
+
+func (d *sm3Digest) block(p []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for j := 16; j < 68; j++ {
+		w[j] = sm3P1(w[j-16]^w[j-9]^bits.RotateLeft32(w[j-3], 15)) ^ bits.RotateLeft32(w[j-13], 7) ^ w[j-6]
+	}
+	a, b, c, dd, e, f, g, h := d.v[0], d.v[1], d.v[2], d.v[3], d.v[4], d.v[5], d.v[6], d.v[7]
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff = a ^ b ^ c
+			gg = e ^ f ^ g
+		} else {
+			t = 0x7a879d8a
+			ff = (a & b) | (a & c) | (b & c)
+			gg = (e & f) | (^e & g)
+		}
+		a12 := bits.RotateLeft32(a, 12)
+		ss1 := bits.RotateLeft32(a12+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ a12
+		tt1 := ff + dd + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + h + ss1 + w[j]
+		dd = c
+		c = bits.RotateLeft32(b, 9)
+		b = a
+		a = tt1
+		h = g
+		g = bits.RotateLeft32(f, 19)
+		f = e
+		e = sm3P0(tt2)
+	}
+	d.v[0] ^= a
+	d.v[1] ^= b
+	d.v[2] ^= c
+	d.v[3] ^= dd
+	d.v[4] ^= e
+	d.v[5] ^= f
+	d.v[6] ^= g
+	d.v[7] ^= h
+}
+
+func sm3P0(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17)
+}
+
+func sm3P1(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23)
+}
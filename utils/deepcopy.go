@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// MergeStrategy strategy of MergeStructs
+type MergeStrategy int
+
+// Merge strategies, the MergeAppendSlices could be combined with others such as MergeKeepExisting|MergeAppendSlices
+const (
+	MergeOverride     = MergeStrategy(0) // the non zero src fields override the dst fields
+	MergeKeepExisting = MergeStrategy(1) // only the zero dst fields are filled by src fields
+	MergeAppendSlices = MergeStrategy(2) // the src slices are appended to the dst slices
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type deepCopyVisit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+type deepCopier struct {
+	visited map[deepCopyVisit]reflect.Value
+}
+
+// DeepCopy copies src into the pointer dst recursively, the maps, slices and pointers are allocated newly so that
+// dst shares nothing with src except the funcs and channels. Only the exported fields of structs are copied, the
+// unexported fields such as the mutexes are not touched, unless the struct or its pointer has the DeepCopy
+// method returning the value or pointer of the same type, which should not call DeepCopy on the same type in turn.
+// The time values are copied as they are, the src could be either the value or pointer of the dst element type
+func DeepCopy(dst interface{}, src interface{}) error {
+	dv := reflect.ValueOf(dst)
+	if !dv.IsValid() || reflect.Ptr != dv.Kind() || dv.IsNil() {
+		return fmt.Errorf("deep copy into %T, a non nil pointer is required", dst)
+	}
+	sv := reflect.ValueOf(src)
+	if !sv.IsValid() {
+		dv.Elem().Set(reflect.Zero(dv.Elem().Type()))
+		return nil
+	}
+	if sv.Type() != dv.Elem().Type() && reflect.Ptr == sv.Kind() && sv.Type().Elem() == dv.Elem().Type() {
+		if sv.IsNil() {
+			dv.Elem().Set(reflect.Zero(dv.Elem().Type()))
+			return nil
+		}
+		sv = sv.Elem()
+	}
+	if sv.Type() != dv.Elem().Type() {
+		return fmt.Errorf("deep copy %T into %T, the types mismatch", src, dst)
+	}
+	c := &deepCopier{visited: map[deepCopyVisit]reflect.Value{}}
+	c.copyValue(dv.Elem(), sv)
+	return nil
+}
+
+func (c *deepCopier) copyValue(dst reflect.Value, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		if v, ok := copyByMethod(src); ok {
+			dst.Set(v)
+			return
+		}
+		key := deepCopyVisit{ptr: src.Pointer(), typ: src.Type()}
+		if v, ok := c.visited[key]; ok {
+			dst.Set(v)
+			return
+		}
+		v := reflect.New(src.Type().Elem())
+		c.visited[key] = v
+		c.copyValue(v.Elem(), src.Elem())
+		dst.Set(v)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		v := reflect.New(src.Elem().Type()).Elem()
+		c.copyValue(v, src.Elem())
+		dst.Set(v)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		key := deepCopyVisit{ptr: src.Pointer(), typ: src.Type()}
+		if v, ok := c.visited[key]; ok {
+			dst.Set(v)
+			return
+		}
+		v := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.visited[key] = v
+		for _, k := range src.MapKeys() {
+			item := reflect.New(src.Type().Elem()).Elem()
+			c.copyValue(item, src.MapIndex(k))
+			v.SetMapIndex(k, item)
+		}
+		dst.Set(v)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		v := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			c.copyValue(v.Index(i), src.Index(i))
+		}
+		dst.Set(v)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		if src.Type() == timeType {
+			dst.Set(src)
+			return
+		}
+		if v, ok := copyByMethod(src); ok {
+			dst.Set(v)
+			return
+		}
+		t := src.Type()
+		for i := 0; i < src.NumField(); i++ {
+			if "" != t.Field(i).PkgPath {
+				continue
+			}
+			c.copyValue(dst.Field(i), src.Field(i))
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// copyByMethod copies the value by its DeepCopy method returning the value or pointer of the same type
+func copyByMethod(src reflect.Value) (reflect.Value, bool) {
+	method := src.MethodByName("DeepCopy")
+	if !method.IsValid() && reflect.Struct == src.Kind() && hasDeepCopyMethod(reflect.PtrTo(src.Type())) {
+		// the method of pointer receiver
+		tmp := reflect.New(src.Type())
+		tmp.Elem().Set(src)
+		method = tmp.MethodByName("DeepCopy")
+	}
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	v := method.Call(nil)[0]
+	if reflect.Interface == v.Kind() {
+		v = v.Elem()
+	}
+	switch {
+	case !v.IsValid():
+		return reflect.Value{}, false
+	case v.Type() == src.Type():
+		return v, true
+	case reflect.Ptr == v.Kind() && v.Type().Elem() == src.Type():
+		if v.IsNil() {
+			return reflect.Zero(src.Type()), true
+		}
+		return v.Elem(), true
+	}
+	return reflect.Value{}, false
+}
+
+func hasDeepCopyMethod(t reflect.Type) bool {
+	_, ok := t.MethodByName("DeepCopy")
+	return ok
+}
+
+// MergeStructs merges the exported fields of src into the struct pointer dst by strategy recursively, the nested
+// structs and struct pointers are merged field by field, the maps are merged by keys and the slices are replaced
+// or appended, the merged values are deeply copied from src. The zero values are treated as not set, so the false
+// and 0 of src never override dst, the src could be either the value or pointer of the dst element type
+func MergeStructs(dst interface{}, src interface{}, strategy MergeStrategy) error {
+	dv := reflect.ValueOf(dst)
+	if !dv.IsValid() || reflect.Ptr != dv.Kind() || dv.IsNil() || reflect.Struct != dv.Elem().Kind() {
+		return fmt.Errorf("merge into %T, a non nil struct pointer is required", dst)
+	}
+	sv := reflect.ValueOf(src)
+	if sv.IsValid() && reflect.Ptr == sv.Kind() {
+		if sv.IsNil() {
+			return nil
+		}
+		sv = sv.Elem()
+	}
+	if !sv.IsValid() {
+		return errors.New("merge from nil")
+	}
+	if sv.Type() != dv.Elem().Type() {
+		return fmt.Errorf("merge %T into %T, the types mismatch", src, dst)
+	}
+	mergeValue(dv.Elem(), sv, strategy)
+	return nil
+}
+
+func mergeValue(dst reflect.Value, src reflect.Value, strategy MergeStrategy) {
+	keepExisting := 0 != strategy&MergeKeepExisting
+	switch dst.Kind() {
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			break
+		}
+		t := dst.Type()
+		for i := 0; i < dst.NumField(); i++ {
+			if "" != t.Field(i).PkgPath {
+				continue
+			}
+			mergeValue(dst.Field(i), src.Field(i), strategy)
+		}
+		return
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if !dst.IsNil() && reflect.Struct == dst.Type().Elem().Kind() && dst.Type().Elem() != timeType {
+			mergeValue(dst.Elem(), src.Elem(), strategy)
+			return
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+		}
+		for _, k := range src.MapKeys() {
+			sv := src.MapIndex(k)
+			dv := dst.MapIndex(k)
+			item := reflect.New(dst.Type().Elem()).Elem()
+			if !dv.IsValid() {
+				deepCopyValue(item, sv)
+			} else if isMergeableKind(dv) {
+				item.Set(dv)
+				mergeValue(item, sv, strategy)
+			} else if isMapInterface(dv) && isMapInterface(sv) && dv.Elem().Type() == sv.Elem().Type() {
+				// the nested maps of map[string]interface{} such as the decoded json or yaml
+				item.Set(dv)
+				mergeValue(dv.Elem(), sv.Elem(), strategy)
+			} else if keepExisting {
+				continue
+			} else {
+				deepCopyValue(item, sv)
+			}
+			dst.SetMapIndex(k, item)
+		}
+		return
+	case reflect.Slice:
+		if src.Len() == 0 {
+			return
+		}
+		if 0 != strategy&MergeAppendSlices {
+			items := reflect.New(src.Type()).Elem()
+			deepCopyValue(items, src)
+			dst.Set(reflect.AppendSlice(dst, items))
+			return
+		}
+	}
+	if src.IsZero() || (keepExisting && !dst.IsZero()) {
+		return
+	}
+	deepCopyValue(dst, src)
+}
+
+// isMergeableKind the map value merged rather than replaced
+func isMergeableKind(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		return v.Type() != timeType
+	case reflect.Map:
+		return true
+	case reflect.Ptr:
+		return !v.IsNil() && reflect.Struct == v.Type().Elem().Kind()
+	}
+	return false
+}
+
+func isMapInterface(v reflect.Value) bool {
+	return reflect.Interface == v.Kind() && !v.IsNil() && reflect.Map == v.Elem().Kind() && !v.Elem().IsNil()
+}
+
+func deepCopyValue(dst reflect.Value, src reflect.Value) {
+	c := &deepCopier{visited: map[deepCopyVisit]reflect.Value{}}
+	c.copyValue(dst, src)
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants of id generators
+const (
+	SnowflakeEpoch   = int64(1577836800000) // 2020-01-01 00:00:00 UTC in milliseconds
+	SnowflakeMaxNode = int64(1<<snowflakeNodeBits - 1)
+
+	ULIDLength        = 26
+	ULIDEncoding      = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // crockford base32
+	NanoIDAlphabet    = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	NanoIDLength      = 21
+	ulidMaxTime       = uint64(1<<48 - 1)
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeMaxSeq   = int64(1<<snowflakeSeqBits - 1)
+)
+
+// errors
+var (
+	ErrInvalidID = errors.New("invalid id")
+	// ErrSnowflakeInUse the node id could not be changed after the default generator generated ids
+	ErrSnowflakeInUse = errors.New("default snowflake generator already in use")
+)
+
+var (
+	_defaultSnowflake      *Snowflake
+	_defaultSnowflakeUsed  bool
+	_defaultSnowflakeMutex = sync.Mutex{}
+	_ulidMutex             = sync.Mutex{}
+	_ulidLastTime          uint64
+	_ulidLastEntropy       [10]byte
+)
+
+// Snowflake generator of the 63 bits sortable ids composed of 41 bits milliseconds since SnowflakeEpoch, 10 bits
+// node id and 12 bits sequence, the ids are kept increasing even though the clock moves backwards
+type Snowflake struct {
+	mutex    sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+// SnowflakeID parsed snowflake id
+type SnowflakeID struct {
+	Time     time.Time
+	Node     int64
+	Sequence int64
+}
+
+// NewSnowflake snowflake generator of node in [0, SnowflakeMaxNode]
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > SnowflakeMaxNode {
+		return nil, fmt.Errorf("snowflake node %d out of range [0, %d]", node, SnowflakeMaxNode)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+// Node the node id
+func (s *Snowflake) Node() int64 {
+	return s.node
+}
+
+// Generate the next id
+func (s *Snowflake) Generate() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now().UnixMilli() - SnowflakeEpoch
+	if now <= s.lastTime {
+		// the same millisecond or the clock moved backwards, keep increasing on the last time
+		s.sequence = (s.sequence + 1) & snowflakeMaxSeq
+		if 0 == s.sequence {
+			s.lastTime++
+		}
+	} else {
+		s.lastTime = now
+		s.sequence = 0
+	}
+	return s.lastTime<<(snowflakeNodeBits+snowflakeSeqBits) | s.node<<snowflakeSeqBits | s.sequence
+}
+
+// GenerateString the next id in decimal string
+func (s *Snowflake) GenerateString() string {
+	return strconv.FormatInt(s.Generate(), 10)
+}
+
+// SetSnowflakeNode configures the node id of GenSnowflakeID, it should be called before generating any id,
+// ErrSnowflakeInUse is returned once GenSnowflakeID is called, the node id is derived from the hostname and
+// process id by default
+func SetSnowflakeNode(node int64) error {
+	s, err := NewSnowflake(node)
+	if nil != err {
+		return err
+	}
+	_defaultSnowflakeMutex.Lock()
+	defer _defaultSnowflakeMutex.Unlock()
+	if _defaultSnowflakeUsed && s.node != _defaultSnowflake.node {
+		return ErrSnowflakeInUse
+	}
+	if !_defaultSnowflakeUsed {
+		_defaultSnowflake = s
+	}
+	return nil
+}
+
+func defaultSnowflake() *Snowflake {
+	_defaultSnowflakeMutex.Lock()
+	defer _defaultSnowflakeMutex.Unlock()
+	if nil == _defaultSnowflake {
+		hostname, _ := os.Hostname()
+		node := int64(crc32.ChecksumIEEE([]byte(fmt.Sprintf("%s:%d", hostname, os.Getpid())))) & SnowflakeMaxNode
+		_defaultSnowflake, _ = NewSnowflake(node)
+	}
+	_defaultSnowflakeUsed = true
+	return _defaultSnowflake
+}
+
+// GenSnowflakeID generate snowflake id
+func GenSnowflakeID() int64 {
+	return defaultSnowflake().Generate()
+}
+
+// GenSnowflakeIDString generate snowflake id in decimal string
+func GenSnowflakeIDString() string {
+	return defaultSnowflake().GenerateString()
+}
+
+// ParseSnowflake parse snowflake id into time, node and sequence
+func ParseSnowflake(id int64) (SnowflakeID, error) {
+	if id <= 0 {
+		return SnowflakeID{}, fmt.Errorf("%w: snowflake %d", ErrInvalidID, id)
+	}
+	return SnowflakeID{
+		Time:     time.UnixMilli(id>>(snowflakeNodeBits+snowflakeSeqBits) + SnowflakeEpoch),
+		Node:     id >> snowflakeSeqBits & SnowflakeMaxNode,
+		Sequence: id & snowflakeMaxSeq,
+	}, nil
+}
+
+// ParseSnowflakeString parse snowflake id in decimal string
+func ParseSnowflakeString(id string) (SnowflakeID, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if nil != err {
+		return SnowflakeID{}, fmt.Errorf("%w: snowflake %q", ErrInvalidID, id)
+	}
+	return ParseSnowflake(n)
+}
+
+// GenULID generate ULID of 26 characters, 48 bits milliseconds and 80 bits randomness encoded in crockford base32,
+// the ids generated in the same millisecond are increasing monotonically
+func GenULID() string {
+	ms := uint64(time.Now().UnixMilli())
+	_ulidMutex.Lock()
+	defer _ulidMutex.Unlock()
+	if ms <= _ulidLastTime {
+		// increase the randomness of the last id, move to the next millisecond on overflow
+		ms = _ulidLastTime
+		if !increaseBytes(_ulidLastEntropy[:]) {
+			ms++
+		}
+	} else if _, err := crand.Read(_ulidLastEntropy[:]); nil != err {
+		_randomMutex.Lock()
+		_random.Read(_ulidLastEntropy[:])
+		_randomMutex.Unlock()
+	}
+	_ulidLastTime = ms
+	return encodeULID(ms, _ulidLastEntropy)
+}
+
+func increaseBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if 0 != b[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(ms uint64, entropy [10]byte) string {
+	// 128 bits as hi 64 bits and lo 64 bits, encoded from the lowest 5 bits
+	hi := ms<<16 | uint64(entropy[0])<<8 | uint64(entropy[1])
+	lo := uint64(0)
+	for _, b := range entropy[2:] {
+		lo = lo<<8 | uint64(b)
+	}
+	result := make([]byte, ULIDLength)
+	for i := ULIDLength - 1; i >= 0; i-- {
+		result[i] = ULIDEncoding[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(result)
+}
+
+// ParseULID parse the time of ULID
+func ParseULID(id string) (time.Time, error) {
+	if len(id) != ULIDLength {
+		return time.Time{}, fmt.Errorf("%w: ulid %q should be %d characters", ErrInvalidID, id, ULIDLength)
+	}
+	var hi, lo uint64
+	for i, c := range strings.ToUpper(id) {
+		idx := strings.IndexRune(ULIDEncoding, c)
+		if idx < 0 || (0 == i && idx > 7) {
+			// the first character holds 3 bits only
+			return time.Time{}, fmt.Errorf("%w: ulid %q", ErrInvalidID, id)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(idx)
+	}
+	ms := hi >> 16
+	if ms > ulidMaxTime {
+		return time.Time{}, fmt.Errorf("%w: ulid %q", ErrInvalidID, id)
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// IsULID whether the id is valid ULID
+func IsULID(id string) bool {
+	_, err := ParseULID(id)
+	return nil == err
+}
+
+// GenNanoID generate nanoid of 21 url safe characters
+func GenNanoID() string {
+	id, _ := GenNanoIDWith(NanoIDAlphabet, NanoIDLength)
+	return id
+}
+
+// GenNanoIDWith generate nanoid of alphabet and size, the characters are picked uniformly
+func GenNanoIDWith(alphabet string, size int) (string, error) {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return "", fmt.Errorf("nanoid alphabet length %d out of range [2, 256]", len(alphabet))
+	}
+	if size <= 0 {
+		return "", fmt.Errorf("nanoid size %d should be positive", size)
+	}
+	// the random bytes are masked and the values out of alphabet are dropped to avoid bias
+	mask := byte(1<<bits.Len(uint(len(alphabet)-1)) - 1)
+	step := 1 + int(1.6*float64(int(mask)*size)/float64(len(alphabet)))
+	result := make([]byte, 0, size)
+	buf := make([]byte, step)
+	for {
+		if _, err := crand.Read(buf); nil != err {
+			return "", err
+		}
+		for _, b := range buf {
+			idx := int(b & mask)
+			if idx < len(alphabet) {
+				result = append(result, alphabet[idx])
+				if len(result) == size {
+					return string(result), nil
+				}
+			}
+		}
+	}
+}
+
+// IsNanoID whether the id is nanoid of the default alphabet and size
+func IsNanoID(id string) bool {
+	return IsNanoIDWith(id, NanoIDAlphabet, NanoIDLength)
+}
+
+// IsNanoIDWith whether the id is nanoid of alphabet and size
+func IsNanoIDWith(id string, alphabet string, size int) bool {
+	if len(id) != size {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(alphabet, c) {
+			return false
+		}
+	}
+	return true
+}
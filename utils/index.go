@@ -19,6 +19,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/libpub/golib/utils/sliceutil"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -528,6 +529,8 @@ func IsObjectEquals(l interface{}, r interface{}) bool {
 }
 
 // RandomString random string
+//
+// Deprecated: use strutil.RandomString which chooses the characters by crypto/rand from the given charset
 func RandomString(l int) string {
 	str := []byte(CharactorsBase)
 	sl := len(str)
@@ -648,15 +651,13 @@ func RemoveItemFromList(v string, l []string) []string {
 
 // IsInList whether a value is in the list
 func IsInList(key int, list []int) bool {
-	for _, v := range list {
-		if v == key {
-			return true
-		}
-	}
-	return false
+	return sliceutil.Contains(list, key)
 }
 
 // PascalCaseString converts xx_yy to XxYy
+//
+// Deprecated: use strutil.ToPascalCase which also splits the words by case changes and acronyms,
+// this one is kept for the names already generated by it
 func PascalCaseString(s string) string {
 	data := make([]byte, 0, len(s))
 	j := false
@@ -682,6 +683,8 @@ func PascalCaseString(s string) string {
 }
 
 // CamelCaseString converts xx_yy to xxYY
+//
+// Deprecated: use strutil.ToCamelCase, this one is kept for the names already generated by it
 func CamelCaseString(s string) string {
 	if strings.HasPrefix(s, "ID") {
 		s = "id" + s[2:]
@@ -692,6 +695,9 @@ func CamelCaseString(s string) string {
 }
 
 // SnakeCaseString converts XxYy to xx_yy, XxYY to xx_yy
+//
+// Deprecated: use strutil.ToSnakeCase which keeps the acronyms as one word, such as HTTPServer into
+// http_server instead of h_t_t_p_server, this one is kept for the table names already generated by it
 func SnakeCaseString(s string) string {
 	data := make([]byte, 0, len(s)*2)
 	j := false
@@ -710,6 +716,9 @@ func SnakeCaseString(s string) string {
 }
 
 // KebabCaseString converts XxYy to xx-yy, XxYY to xx-yy
+//
+// Deprecated: use strutil.ToKebabCase which keeps the acronyms as one word, this one is kept for the
+// routes already generated by it
 func KebabCaseString(s string) string {
 	data := make([]byte, 0, len(s)*2)
 	j := false
@@ -730,6 +739,8 @@ func KebabCaseString(s string) string {
 }
 
 // CamelCaseSlices splits XxYy into ["Xx", "Yy"]
+//
+// Deprecated: use strutil.SplitWords which also splits by the separators and keeps the acronyms
 func CamelCaseSlices(s string) []string {
 	num := len(s)
 	results := []string{}
@@ -0,0 +1,436 @@
+package utils
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/libpub/golib/definations"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Constants of map decoder
+const (
+	DefaultDecodeTagName = "json"
+)
+
+// DecoderOption options of DecodeMap
+type DecoderOption func(*decoderOptions)
+
+type decoderOptions struct {
+	tagName     string
+	errorUnused bool
+	strictTypes bool
+}
+
+var (
+	durationType       = reflect.TypeOf(time.Duration(0))
+	objectIDType       = reflect.TypeOf(primitive.ObjectID{})
+	textUnmarshalerPtr = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// WithDecodeTagName the tag name of field names, json by default
+func WithDecodeTagName(tagName string) DecoderOption {
+	return func(o *decoderOptions) {
+		o.tagName = tagName
+	}
+}
+
+// WithErrorUnused the keys not mapped to any field are reported as errors
+func WithErrorUnused() DecoderOption {
+	return func(o *decoderOptions) {
+		o.errorUnused = true
+	}
+}
+
+// WithStrictTypes the values are not coerced between kinds such as "1" into int, only the numbers are converted
+// between each other
+func WithStrictTypes() DecoderOption {
+	return func(o *decoderOptions) {
+		o.strictTypes = true
+	}
+}
+
+// DecodeMap decodes the input such as map[string]interface{} of json payloads into the output pointer of struct,
+// map, slice or any type:
+//   - the keys are mapped to the fields by the tag names or field names, case insensitively if not matched exactly,
+//     the "-" fields are skipped
+//   - the embedded structs without tag names and the fields tagged with squash such as `json:",squash"` are decoded
+//     from the same map, the map[string]interface{} field tagged with remain receives the unmapped keys
+//   - the values are coerced by ToInt64E, ToFloat64E, ToBoolE, ToTimeE, ToDurationE and ToString unless strict, the
+//     ObjectID and encoding.TextUnmarshaler fields are decoded from strings
+//
+// all the errors are returned as definations.ValidationErrors with the field paths
+func DecodeMap(input interface{}, output interface{}, options ...DecoderOption) error {
+	opts := decoderOptions{tagName: DefaultDecodeTagName}
+	for _, option := range options {
+		option(&opts)
+	}
+	out := reflect.ValueOf(output)
+	if !out.IsValid() || reflect.Ptr != out.Kind() || out.IsNil() {
+		return fmt.Errorf("decode into %T, a non nil pointer is required", output)
+	}
+	errs := definations.ValidationErrors{}
+	d := &mapDecoder{opts: opts, errs: &errs}
+	d.decode("", input, out.Elem())
+	return errs.Err()
+}
+
+type mapDecoder struct {
+	opts decoderOptions
+	errs *definations.ValidationErrors
+}
+
+func (d *mapDecoder) fail(path string, input interface{}, out reflect.Value, err error) {
+	if nil == err {
+		err = fmt.Errorf("cannot decode %T into %s", input, out.Type())
+	} else {
+		err = fmt.Errorf("cannot decode %T into %s: %v", input, out.Type(), err)
+	}
+	d.errs.Add(path, err)
+}
+
+func (d *mapDecoder) decode(path string, input interface{}, out reflect.Value) {
+	in := reflect.ValueOf(input)
+	for in.IsValid() && (reflect.Ptr == in.Kind() || reflect.Interface == in.Kind()) {
+		if in.IsNil() {
+			return
+		}
+		in = in.Elem()
+	}
+	if !in.IsValid() {
+		return
+	}
+	input = in.Interface()
+	if in.Type().AssignableTo(out.Type()) && reflect.Interface != out.Kind() && !isCompositeKind(in.Kind()) {
+		out.Set(in)
+		return
+	}
+
+	switch out.Type() {
+	case timeType:
+		t, err := ToTimeE(input)
+		if nil != err || (d.opts.strictTypes && reflect.String != in.Kind()) {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.Set(reflect.ValueOf(t))
+		return
+	case durationType:
+		duration, err := ToDurationE(input)
+		if nil != err || (d.opts.strictTypes && reflect.String != in.Kind()) {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.SetInt(int64(duration))
+		return
+	case objectIDType:
+		oid, err := primitive.ObjectIDFromHex(ToString(input))
+		if nil != err {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.Set(reflect.ValueOf(oid))
+		return
+	}
+	if text, ok := input.(string); ok && reflect.Ptr != out.Kind() && out.CanAddr() && out.Addr().Type().Implements(textUnmarshalerPtr) {
+		if err := out.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); nil != err {
+			d.fail(path, input, out, err)
+		}
+		return
+	}
+
+	switch out.Kind() {
+	case reflect.Interface:
+		if in.Type().AssignableTo(out.Type()) {
+			out.Set(in)
+		} else {
+			d.fail(path, input, out, nil)
+		}
+	case reflect.Ptr:
+		v := reflect.New(out.Type().Elem())
+		if !out.IsNil() {
+			v = out
+		}
+		errCount := len(*d.errs)
+		d.decode(path, input, v.Elem())
+		if len(*d.errs) == errCount {
+			out.Set(v)
+		}
+	case reflect.Bool:
+		if d.opts.strictTypes && reflect.Bool != in.Kind() {
+			d.fail(path, input, out, nil)
+			return
+		}
+		b, err := ToBoolE(input)
+		if nil != err {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if d.opts.strictTypes && !isNumberKind(in.Kind()) {
+			d.fail(path, input, out, nil)
+			return
+		}
+		n, err := ToInt64E(input)
+		if nil == err && out.OverflowInt(n) {
+			err = fmt.Errorf("%d overflows", n)
+		}
+		if nil != err {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if d.opts.strictTypes && !isNumberKind(in.Kind()) {
+			d.fail(path, input, out, nil)
+			return
+		}
+		n, err := ToUint64E(input)
+		if nil == err && out.OverflowUint(n) {
+			err = fmt.Errorf("%d overflows", n)
+		}
+		if nil != err {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if d.opts.strictTypes && !isNumberKind(in.Kind()) {
+			d.fail(path, input, out, nil)
+			return
+		}
+		n, err := ToFloat64E(input)
+		if nil != err {
+			d.fail(path, input, out, err)
+			return
+		}
+		out.SetFloat(n)
+	case reflect.String:
+		switch {
+		case reflect.String == in.Kind():
+			out.SetString(in.String())
+		case reflect.Slice == in.Kind() && reflect.Uint8 == in.Type().Elem().Kind():
+			out.SetString(string(in.Bytes()))
+		case !d.opts.strictTypes && !isCompositeKind(in.Kind()):
+			out.SetString(ToString(input))
+		default:
+			d.fail(path, input, out, nil)
+		}
+	case reflect.Slice, reflect.Array:
+		d.decodeSlice(path, in, out)
+	case reflect.Map:
+		d.decodeMap(path, in, out)
+	case reflect.Struct:
+		d.decodeStruct(path, in, out)
+	default:
+		d.fail(path, input, out, nil)
+	}
+}
+
+func (d *mapDecoder) decodeSlice(path string, in reflect.Value, out reflect.Value) {
+	if reflect.Slice == out.Kind() && reflect.Uint8 == out.Type().Elem().Kind() && reflect.String == in.Kind() {
+		out.SetBytes([]byte(in.String()))
+		return
+	}
+	var items []interface{}
+	switch {
+	case reflect.Slice == in.Kind() || reflect.Array == in.Kind():
+		items = make([]interface{}, 0, in.Len())
+		for i := 0; i < in.Len(); i++ {
+			items = append(items, in.Index(i).Interface())
+		}
+	case d.opts.strictTypes:
+		d.fail(path, in.Interface(), out, nil)
+		return
+	default:
+		// the json arrays, comma separated texts or single values
+		var err error
+		if items, err = toItems(in.Interface()); nil != err {
+			d.fail(path, in.Interface(), out, err)
+			return
+		}
+	}
+	if reflect.Array == out.Kind() {
+		if len(items) > out.Len() {
+			d.fail(path, in.Interface(), out, fmt.Errorf("%d items exceed the array length", len(items)))
+			return
+		}
+		for i, item := range items {
+			d.decode(fmt.Sprintf("%s[%d]", path, i), item, out.Index(i))
+		}
+		return
+	}
+	results := reflect.MakeSlice(out.Type(), len(items), len(items))
+	for i, item := range items {
+		d.decode(fmt.Sprintf("%s[%d]", path, i), item, results.Index(i))
+	}
+	out.Set(results)
+}
+
+func (d *mapDecoder) decodeMap(path string, in reflect.Value, out reflect.Value) {
+	if reflect.Map != in.Kind() {
+		if reflect.String == in.Kind() && !d.opts.strictTypes {
+			m, err := ToStringMapE(in.String())
+			if nil != err {
+				d.fail(path, in.Interface(), out, err)
+				return
+			}
+			in = reflect.ValueOf(m)
+		} else {
+			d.fail(path, in.Interface(), out, nil)
+			return
+		}
+	}
+	if out.IsNil() {
+		out.Set(reflect.MakeMapWithSize(out.Type(), in.Len()))
+	}
+	for _, k := range in.MapKeys() {
+		itemPath := joinDecodePath(path, ToString(k.Interface()))
+		key := reflect.New(out.Type().Key()).Elem()
+		errCount := len(*d.errs)
+		d.decode(itemPath, k.Interface(), key)
+		item := reflect.New(out.Type().Elem()).Elem()
+		if existing := out.MapIndex(key); existing.IsValid() {
+			item.Set(existing)
+		}
+		d.decode(itemPath, in.MapIndex(k).Interface(), item)
+		if len(*d.errs) == errCount {
+			out.SetMapIndex(key, item)
+		}
+	}
+}
+
+func (d *mapDecoder) decodeStruct(path string, in reflect.Value, out reflect.Value) {
+	if reflect.String == in.Kind() && !d.opts.strictTypes {
+		m, err := ToStringMapE(in.String())
+		if nil != err {
+			d.fail(path, in.Interface(), out, err)
+			return
+		}
+		in = reflect.ValueOf(m)
+	}
+	if reflect.Map != in.Kind() {
+		d.fail(path, in.Interface(), out, nil)
+		return
+	}
+	values := make(map[string]reflect.Value, in.Len())
+	for _, k := range in.MapKeys() {
+		values[ToString(k.Interface())] = in.MapIndex(k)
+	}
+	used := map[string]bool{}
+	var remain reflect.Value
+	d.decodeFields(path, values, used, out, &remain)
+
+	unused := map[string]interface{}{}
+	for key, value := range values {
+		if !used[key] {
+			unused[key] = value.Interface()
+		}
+	}
+	if len(unused) == 0 {
+		return
+	}
+	if remain.IsValid() {
+		if remain.IsNil() {
+			remain.Set(reflect.MakeMapWithSize(remain.Type(), len(unused)))
+		}
+		for key, value := range unused {
+			remain.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		}
+	} else if d.opts.errorUnused {
+		for key := range unused {
+			d.errs.Add(joinDecodePath(path, key), fmt.Errorf("unknown field"))
+		}
+	}
+}
+
+func (d *mapDecoder) decodeFields(path string, values map[string]reflect.Value, used map[string]bool, out reflect.Value, remain *reflect.Value) {
+	t := out.Type()
+	for i := 0; i < out.NumField(); i++ {
+		ft := t.Field(i)
+		f := out.Field(i)
+		tags := strings.Split(ft.Tag.Get(d.opts.tagName), ",")
+		name := tags[0]
+		if "-" == name {
+			continue
+		}
+		squash, isRemain := false, false
+		for _, tag := range tags[1:] {
+			squash = squash || "squash" == tag || "inline" == tag
+			isRemain = isRemain || "remain" == tag
+		}
+		if ft.Anonymous && "" == name {
+			squash = true
+		}
+		if squash {
+			if reflect.Ptr == f.Kind() && reflect.Struct == ft.Type.Elem().Kind() && f.CanSet() {
+				if f.IsNil() {
+					f.Set(reflect.New(ft.Type.Elem()))
+				}
+				f = f.Elem()
+			}
+			if reflect.Struct == f.Kind() {
+				d.decodeFields(path, values, used, f, remain)
+				continue
+			}
+		}
+		if "" != ft.PkgPath {
+			continue
+		}
+		if isRemain && reflect.Map == f.Kind() && reflect.String == ft.Type.Key().Kind() {
+			*remain = f
+			continue
+		}
+		if "" == name {
+			name = ft.Name
+		}
+		key, ok := matchDecodeKey(values, name)
+		if !ok {
+			continue
+		}
+		used[key] = true
+		d.decode(joinDecodePath(path, name), values[key].Interface(), f)
+	}
+}
+
+// matchDecodeKey the key matched exactly, or case insensitively
+func matchDecodeKey(values map[string]reflect.Value, name string) (string, bool) {
+	if _, ok := values[name]; ok {
+		return name, true
+	}
+	for key := range values {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isCompositeKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	}
+	return false
+}
+
+func joinDecodePath(path string, name string) string {
+	if "" == path {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,86 @@
+package maputil
+
+import (
+	"sort"
+
+	"github.com/libpub/golib/utils/sliceutil"
+)
+
+// Keys the keys of m in random order
+func Keys[M ~map[K]V, K comparable, V any](m M) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// SortedKeys the keys of m in ascending order, for the stable outputs such as the reports and signatures
+func SortedKeys[M ~map[K]V, K sliceutil.Ordered, V any](m M) []K {
+	keys := Keys(m)
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// Values the values of m in random order
+func Values[M ~map[K]V, K comparable, V any](m M) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Merge the new map of the entries of maps, the values of the later maps override the former ones
+func Merge[M ~map[K]V, K comparable, V any](maps ...M) M {
+	size := 0
+	for _, m := range maps {
+		size += len(m)
+	}
+	result := make(M, size)
+	for _, m := range maps {
+		for k, v := range m {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Clone the shallow copy of m, nil if m is nil
+func Clone[M ~map[K]V, K comparable, V any](m M) M {
+	if nil == m {
+		return nil
+	}
+	return Merge(m)
+}
+
+// Filter the new map of the entries of m satisfying keep
+func Filter[M ~map[K]V, K comparable, V any](m M, keep func(K, V) bool) M {
+	result := make(M)
+	for k, v := range m {
+		if keep(k, v) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Diff the keys only in b as added, only in a as removed, and in both with different values as changed,
+// all in ascending order, such as comparing the reloaded configurations
+func Diff[M ~map[K]V, K sliceutil.Ordered, V comparable](a M, b M) (added []K, removed []K, changed []K) {
+	for _, k := range SortedKeys(a) {
+		if v, ok := b[k]; !ok {
+			removed = append(removed, k)
+		} else if v != a[k] {
+			changed = append(changed, k)
+		}
+	}
+	for _, k := range SortedKeys(b) {
+		if _, ok := a[k]; !ok {
+			added = append(added, k)
+		}
+	}
+	return added, removed, changed
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryPolicy backoff policy of Retry, the delay before the n-th retry is InitialInterval*Multiplier^(n-1) limited
+// by MaxInterval and randomized by Jitter
+type RetryPolicy struct {
+	MaxAttempts     int           // max attempts including the first one, 0 means unlimited
+	MaxElapsedTime  time.Duration // max elapsed time since the first attempt, 0 means unlimited
+	InitialInterval time.Duration // the delay before the first retry
+	MaxInterval     time.Duration // the max delay, 0 means unlimited
+	Multiplier      float64       // the delay multiplier, 1 means constant delay, 2 by default
+	Jitter          float64       // the randomization factor in [0, 1], the delay is picked in [d*(1-Jitter), d*(1+Jitter)]
+	// Retryable classifies the errors, all errors are retried except the permanent errors if not specified
+	Retryable func(err error) bool
+	// OnRetry is called before waiting for the next attempt
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// RetryError the error of the last attempt after retrying exhausted
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+type permanentError struct {
+	err error
+}
+
+// DefaultRetryPolicy 5 attempts with exponential backoff from 100ms up to 10s and 20% jitter
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap the error of the last attempt
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// PermanentError marks the error not retryable, Retry stops and returns the err unwrapped
+func PermanentError(err error) error {
+	if nil == err {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanentError whether the error is marked by PermanentError
+func IsPermanentError(err error) bool {
+	var pe *permanentError
+	return errors.As(err, &pe)
+}
+
+// Backoff the delay before the next attempt after retries times retried, the retries starts from 0
+func (p RetryPolicy) Backoff(retries int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(retries))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		_randomMutex.Lock()
+		delay = delay * (1 - jitter + 2*jitter*_random.Float64())
+		_randomMutex.Unlock()
+	}
+	if delay >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(delay)
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if IsPermanentError(err) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return nil == p.Retryable || p.Retryable(err)
+}
+
+// Retry calls fn until it succeeds, the error is not retryable, the attempts or elapsed time are exhausted or the
+// ctx is done, the not retryable error is returned as it is, the exhausted one is returned as *RetryError
+func Retry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if nil == err {
+			return nil
+		}
+		if !policy.retryable(err) {
+			var pe *permanentError
+			if errors.As(err, &pe) && pe == err {
+				return pe.err
+			}
+			return err
+		}
+		if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		delay := policy.Backoff(attempt - 1)
+		if policy.MaxElapsedTime > 0 && time.Since(start)+delay > policy.MaxElapsedTime {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		if nil != policy.OnRetry {
+			policy.OnRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w after %d attempts: %v", ctx.Err(), attempt, err)
+		case <-timer.C:
+		}
+	}
+}
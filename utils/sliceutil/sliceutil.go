@@ -0,0 +1,148 @@
+package sliceutil
+
+// Ordered the types ordered by the < operator, as constraints.Ordered
+type Ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64 | ~string
+}
+
+// Contains whether v is an element of s
+func Contains[T comparable](s []T, v T) bool {
+	return IndexOf(s, v) >= 0
+}
+
+// ContainsFunc whether any element of s satisfies match
+func ContainsFunc[T any](s []T, match func(T) bool) bool {
+	return IndexFunc(s, match) >= 0
+}
+
+// IndexOf the index of the first element equal to v, -1 if not found
+func IndexOf[T comparable](s []T, v T) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// IndexFunc the index of the first element satisfying match, -1 if not found
+func IndexFunc[T any](s []T, match func(T) bool) int {
+	for i, e := range s {
+		if match(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Unique the new slice of the distinct elements of s in the order of their first occurrences
+func Unique[T comparable](s []T) []T {
+	return UniqueBy(s, func(e T) T { return e })
+}
+
+// UniqueBy the new slice of the elements of s with distinct keys in the order of their first occurrences
+func UniqueBy[T any, K comparable](s []T, key func(T) K) []T {
+	seen := make(map[K]struct{}, len(s))
+	result := make([]T, 0, len(s))
+	for _, e := range s {
+		k := key(e)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		result = append(result, e)
+	}
+	return result
+}
+
+// Chunk splits s into the consecutive chunks of size elements, the last chunk may be shorter, nil if size
+// is not positive. The chunks share the underlying array of s with their capacities limited, so that
+// appending to a chunk does not overwrite the next one
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		return nil
+	}
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for start := 0; start < len(s); start += size {
+		end := start + size
+		if end > len(s) {
+			end = len(s)
+		}
+		chunks = append(chunks, s[start:end:end])
+	}
+	return chunks
+}
+
+// GroupBy groups the elements of s by their keys keeping the order of the elements in each group
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := map[K][]T{}
+	for _, e := range s {
+		k := key(e)
+		groups[k] = append(groups[k], e)
+	}
+	return groups
+}
+
+// Diff the elements of a not in b in the order of a
+func Diff[T comparable](a []T, b []T) []T {
+	exclude := toSet(b)
+	result := make([]T, 0, len(a))
+	for _, e := range a {
+		if _, ok := exclude[e]; !ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Intersect the distinct elements of a also in b in the order of a
+func Intersect[T comparable](a []T, b []T) []T {
+	include := toSet(b)
+	result := make([]T, 0)
+	for _, e := range a {
+		if _, ok := include[e]; ok {
+			result = append(result, e)
+			delete(include, e)
+		}
+	}
+	return result
+}
+
+// Filter the new slice of the elements of s satisfying keep
+func Filter[T any](s []T, keep func(T) bool) []T {
+	result := make([]T, 0, len(s))
+	for _, e := range s {
+		if keep(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Map the new slice of the elements of s converted by convert
+func Map[T any, R any](s []T, convert func(T) R) []R {
+	result := make([]R, len(s))
+	for i, e := range s {
+		result[i] = convert(e)
+	}
+	return result
+}
+
+// Reduce folds the elements of s into the accumulated value starting with initial
+func Reduce[T any, R any](s []T, initial R, accumulate func(R, T) R) R {
+	result := initial
+	for _, e := range s {
+		result = accumulate(result, e)
+	}
+	return result
+}
+
+func toSet[T comparable](s []T) map[T]struct{} {
+	set := make(map[T]struct{}, len(s))
+	for _, e := range s {
+		set[e] = struct{}{}
+	}
+	return set
+}
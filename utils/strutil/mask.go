@@ -0,0 +1,94 @@
+package strutil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaskChar the character replacing the masked characters
+const MaskChar = '*'
+
+// Mask replaces the characters of s except the first keepStart and the last keepEnd characters by MaskChar,
+// all the characters are masked if s is not longer than keepStart+keepEnd so that the short secrets are not exposed
+func Mask(s string, keepStart int, keepEnd int) string {
+	runes := []rune(s)
+	if keepStart < 0 {
+		keepStart = 0
+	}
+	if keepEnd < 0 {
+		keepEnd = 0
+	}
+	if len(runes) <= keepStart+keepEnd {
+		return strings.Repeat(string(MaskChar), len(runes))
+	}
+	for i := keepStart; i < len(runes)-keepEnd; i++ {
+		runes[i] = MaskChar
+	}
+	return string(runes)
+}
+
+// MaskEmail masks the local part of the email address except its first and last characters,
+// such as john.doe@example.com into j******e@example.com, the text without @ is masked by Mask(s, 1, 1)
+func MaskEmail(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return Mask(email, 1, 1)
+	}
+	local := []rune(email[:at])
+	if len(local) <= 2 {
+		return Mask(string(local), 1, 0) + email[at:]
+	}
+	return Mask(string(local), 1, 1) + email[at:]
+}
+
+// MaskPhone masks the digits of the phone number except the first 3 and the last 4 digits of the numbers
+// of 11 digits or longer, or the last 4 digits of the shorter ones, the separators and the leading +
+// are kept, such as 13812345678 into 138****5678 and +86 138-1234-5678 into +** ***-****-5678
+func MaskPhone(phone string) string {
+	digits := countDigits(phone)
+	keepStart, keepEnd := 0, 4
+	if digits >= 11 && !strings.HasPrefix(strings.TrimSpace(phone), "+") {
+		keepStart = 3
+	}
+	if digits <= keepEnd+keepStart {
+		keepStart, keepEnd = 0, digits/3
+	}
+	return maskDigits(phone, keepStart, keepEnd)
+}
+
+// MaskCard masks the digits of the bank card number except the last 4 digits keeping the separators,
+// such as 4111 1111 1111 1111 into **** **** **** 1111
+func MaskCard(card string) string {
+	keepEnd := 4
+	if digits := countDigits(card); digits <= 8 {
+		keepEnd = digits / 3
+	}
+	return maskDigits(card, 0, keepEnd)
+}
+
+// maskDigits masks the digits of s except the first keepStart and the last keepEnd digits
+func maskDigits(s string, keepStart int, keepEnd int) string {
+	runes := []rune(s)
+	digits := countDigits(s)
+	index := 0
+	for i, r := range runes {
+		if !unicode.IsDigit(r) {
+			continue
+		}
+		if index >= keepStart && index < digits-keepEnd {
+			runes[i] = MaskChar
+		}
+		index++
+	}
+	return string(runes)
+}
+
+func countDigits(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			n++
+		}
+	}
+	return n
+}
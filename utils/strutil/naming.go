@@ -0,0 +1,102 @@
+package strutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitWords splits the identifier into words by the separators such as _ - . and spaces, the case changes
+// and the digits, the acronyms are kept as one word, such as HTTPServerID into [HTTP Server ID] and
+// user_id2 into [user id2]
+func SplitWords(s string) []string {
+	words := []string{}
+	runes := []rune(s)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := runes[i-1]
+		if unicode.IsUpper(r) {
+			// lower or digit followed by upper: userID, id2Name
+			// acronym followed by word: HTTPServer splits before the S
+			if !unicode.IsUpper(prev) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
+
+// ToPascalCase converts the identifier such as user_id or http-server into UserId or HttpServer
+func ToPascalCase(s string) string {
+	buf := strings.Builder{}
+	for _, word := range SplitWords(s) {
+		buf.WriteString(capitalize(word))
+	}
+	return buf.String()
+}
+
+// ToCamelCase converts the identifier such as user_id or HTTPServer into userId or httpServer
+func ToCamelCase(s string) string {
+	buf := strings.Builder{}
+	for i, word := range SplitWords(s) {
+		if i == 0 {
+			buf.WriteString(strings.ToLower(word))
+		} else {
+			buf.WriteString(capitalize(word))
+		}
+	}
+	return buf.String()
+}
+
+// ToSnakeCase converts the identifier such as userID or HTTPServer into user_id or http_server
+func ToSnakeCase(s string) string {
+	return joinWords(s, "_", strings.ToLower)
+}
+
+// ToScreamingSnakeCase converts the identifier such as userID into USER_ID for the environment variables
+// and constants
+func ToScreamingSnakeCase(s string) string {
+	return joinWords(s, "_", strings.ToUpper)
+}
+
+// ToKebabCase converts the identifier such as userID or HTTPServer into user-id or http-server
+func ToKebabCase(s string) string {
+	return joinWords(s, "-", strings.ToLower)
+}
+
+// ToDotCase converts the identifier such as userID into user.id for the property keys
+func ToDotCase(s string) string {
+	return joinWords(s, ".", strings.ToLower)
+}
+
+func joinWords(s string, sep string, convert func(string) string) string {
+	words := SplitWords(s)
+	for i, word := range words {
+		words[i] = convert(word)
+	}
+	return strings.Join(words, sep)
+}
+
+// capitalize upper cases the first letter and lower cases the others
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if r == utf8.RuneError {
+		return word
+	}
+	return string(unicode.ToUpper(r)) + strings.ToLower(word[size:])
+}
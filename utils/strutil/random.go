@@ -0,0 +1,54 @@
+package strutil
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+)
+
+// Charsets of random strings
+const (
+	CharsetDigits       = "0123456789"
+	CharsetLower        = "abcdefghijklmnopqrstuvwxyz"
+	CharsetUpper        = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	CharsetLetters      = CharsetLower + CharsetUpper
+	CharsetAlphanumeric = CharsetDigits + CharsetLetters
+	CharsetHex          = "0123456789abcdef"
+	CharsetURLSafe      = CharsetAlphanumeric + "-_"
+	// CharsetReadable the alphanumerics without the ambiguous 0 O o 1 l I, for the codes typed by people
+	CharsetReadable = "23456789abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ"
+)
+
+// ErrEmptyCharset the charset of random string is empty
+var ErrEmptyCharset = errors.New("empty charset")
+
+// RandomString generates the string of length characters chosen uniformly from charset by crypto/rand,
+// so that it could be used as the tokens, verification codes and passwords
+func RandomString(length int, charset string) (string, error) {
+	chars := []rune(charset)
+	if len(chars) == 0 {
+		return "", ErrEmptyCharset
+	}
+	if length <= 0 {
+		return "", nil
+	}
+	result := make([]rune, length)
+	max := big.NewInt(int64(len(chars)))
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if nil != err {
+			return "", err
+		}
+		result[i] = chars[n.Int64()]
+	}
+	return string(result), nil
+}
+
+// MustRandomString generates the random string as RandomString and panics on error
+func MustRandomString(length int, charset string) string {
+	s, err := RandomString(length, charset)
+	if nil != err {
+		panic(err)
+	}
+	return s
+}
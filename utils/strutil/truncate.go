@@ -0,0 +1,77 @@
+package strutil
+
+import (
+	"unicode/utf8"
+)
+
+// Ellipsis the default suffix of the truncated texts
+const Ellipsis = "..."
+
+// Truncate cuts s to at most maxRunes characters ending with Ellipsis if cut, the multi-byte characters
+// such as chinese are never split
+func Truncate(s string, maxRunes int) string {
+	return TruncateWith(s, maxRunes, Ellipsis)
+}
+
+// TruncateWith cuts s to at most maxRunes characters including the ellipsis if cut, the ellipsis is also
+// cut if maxRunes is not longer than it
+func TruncateWith(s string, maxRunes int, ellipsis string) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	ellipsisRunes := utf8.RuneCountInString(ellipsis)
+	if ellipsisRunes >= maxRunes {
+		return cutRunes(ellipsis, maxRunes)
+	}
+	return cutRunes(s, maxRunes-ellipsisRunes) + ellipsis
+}
+
+// TruncateMiddle cuts the middle of s to at most maxRunes characters with Ellipsis between the head and tail,
+// so that both the prefix and the suffix such as the file names of paths are kept
+func TruncateMiddle(s string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	ellipsisRunes := utf8.RuneCountInString(Ellipsis)
+	if ellipsisRunes >= maxRunes {
+		return cutRunes(Ellipsis, maxRunes)
+	}
+	kept := maxRunes - ellipsisRunes
+	head := (kept + 1) / 2
+	tail := kept - head
+	return string(runes[:head]) + Ellipsis + string(runes[len(runes)-tail:])
+}
+
+// TruncateBytes cuts s to at most maxBytes bytes without splitting the multi-byte characters, for the
+// limits of bytes such as the log lines, headers and columns
+func TruncateBytes(s string, maxBytes int) string {
+	if maxBytes <= 0 {
+		return ""
+	}
+	if len(s) <= maxBytes {
+		return s
+	}
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	return s[:end]
+}
+
+func cutRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
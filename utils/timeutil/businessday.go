@@ -0,0 +1,172 @@
+package timeutil
+
+import (
+	"sync"
+	"time"
+)
+
+// BusinessCalendar the weekends and holidays skipped by the business day arithmetic, the days are compared
+// by the dates in the location of the given times
+type BusinessCalendar struct {
+	m        sync.RWMutex
+	weekends map[time.Weekday]bool
+	holidays map[dateKey]bool
+	workdays map[dateKey]bool
+}
+
+type dateKey struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) dateKey {
+	year, month, day := t.Date()
+	return dateKey{year: year, month: month, day: day}
+}
+
+func (k dateKey) before(o dateKey) bool {
+	if k.year != o.year {
+		return k.year < o.year
+	}
+	if k.month != o.month {
+		return k.month < o.month
+	}
+	return k.day < o.day
+}
+
+// DefaultBusinessCalendar the calendar with saturday and sunday weekends and no holidays
+var DefaultBusinessCalendar = NewBusinessCalendar()
+
+// NewBusinessCalendar business calendar with the weekends, saturday and sunday if none given
+func NewBusinessCalendar(weekends ...time.Weekday) *BusinessCalendar {
+	if len(weekends) == 0 {
+		weekends = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	c := &BusinessCalendar{weekends: map[time.Weekday]bool{}, holidays: map[dateKey]bool{}, workdays: map[dateKey]bool{}}
+	for _, weekday := range weekends {
+		c.weekends[weekday] = true
+	}
+	return c
+}
+
+// AddHolidays marks the dates of days as holidays
+func (c *BusinessCalendar) AddHolidays(days ...time.Time) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for _, day := range days {
+		c.holidays[dateOf(day)] = true
+		delete(c.workdays, dateOf(day))
+	}
+}
+
+// AddWorkdays marks the dates of days as business days even though on the weekends, such as the adjusted
+// workdays of the public holidays
+func (c *BusinessCalendar) AddWorkdays(days ...time.Time) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for _, day := range days {
+		c.workdays[dateOf(day)] = true
+		delete(c.holidays, dateOf(day))
+	}
+}
+
+// IsBusinessDay whether the date of t is neither weekend nor holiday, or is a marked workday
+func (c *BusinessCalendar) IsBusinessDay(t time.Time) bool {
+	key := dateOf(t)
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if c.workdays[key] {
+		return true
+	}
+	return !c.weekends[t.Weekday()] && !c.holidays[key]
+}
+
+// AddBusinessDays moves t by n business days keeping the clock, backward if n is negative. The result of 0 is t
+// itself if t is a business day or the next business day. t is returned unchanged if there are not enough
+// business days, such as all the weekdays are weekends and the marked workdays are behind
+func (c *BusinessCalendar) AddBusinessDays(t time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	if n == 0 {
+		return c.NextBusinessDay(t)
+	}
+	origin := t
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if c.beyondWorkdays(t, step) {
+			return origin
+		}
+		if c.IsBusinessDay(t) {
+			n--
+		}
+	}
+	return t
+}
+
+// NextBusinessDay t if it is a business day, or the first business day after t keeping the clock,
+// t is returned unchanged if no business day is after t
+func (c *BusinessCalendar) NextBusinessDay(t time.Time) time.Time {
+	origin := t
+	for !c.IsBusinessDay(t) {
+		if c.beyondWorkdays(t, 1) {
+			return origin
+		}
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// BusinessDaysBetween the count of business days after the day of from until the day of to inclusively,
+// negative if to is before from
+func (c *BusinessCalendar) BusinessDaysBetween(from time.Time, to time.Time) int {
+	to = to.In(from.Location())
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	days := DaysBetween(from, to)
+	count := 0
+	day := StartOfDay(from)
+	for i := 0; i < days; i++ {
+		day = day.AddDate(0, 0, 1)
+		if c.IsBusinessDay(day) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+// beyondWorkdays whether no business day could be found from t in the direction of step, that is all the
+// weekdays are weekends and no marked workday is at or beyond t, so that the arithmetic does not loop forever
+func (c *BusinessCalendar) beyondWorkdays(t time.Time, step int) bool {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	if len(c.weekends) < 7 {
+		return false
+	}
+	key := dateOf(t)
+	for workday := range c.workdays {
+		if (step > 0 && !workday.before(key)) || (step < 0 && !key.before(workday)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBusinessDay whether t is a business day of DefaultBusinessCalendar
+func IsBusinessDay(t time.Time) bool {
+	return DefaultBusinessCalendar.IsBusinessDay(t)
+}
+
+// AddBusinessDays moves t by n business days of DefaultBusinessCalendar
+func AddBusinessDays(t time.Time, n int) time.Time {
+	return DefaultBusinessCalendar.AddBusinessDays(t, n)
+}
+
+// BusinessDaysBetween the count of business days of DefaultBusinessCalendar between from and to
+func BusinessDaysBetween(from time.Time, to time.Time) int {
+	return DefaultBusinessCalendar.BusinessDaysBetween(from, to)
+}
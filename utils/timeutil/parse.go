@@ -0,0 +1,131 @@
+package timeutil
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat the text matches none of the supported time formats
+var ErrUnknownFormat = errors.New("unknown time format")
+
+// Layouts the layouts tried by Parse in order, the layouts without zone are parsed in the given location
+var Layouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05.999999999",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"20060102150405",
+	"20060102T150405Z0700",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC850,
+	time.RFC822Z,
+	time.RFC822,
+	time.UnixDate,
+	time.RubyDate,
+	time.ANSIC,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006",
+	"Jan 2, 2006 15:04:05",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2006年01月02日 15:04:05",
+	"2006年01月02日",
+}
+
+// Parse parses the time text of the Layouts in the local location, or the unix timestamps of which the precision
+// is detected by the digits as seconds, milliseconds, microseconds or nanoseconds
+func Parse(text string) (time.Time, error) {
+	return ParseInLocation(text, time.Local)
+}
+
+// ParseInLocation parses the time text as Parse, the texts without zone are in loc
+func ParseInLocation(text string, loc *time.Location) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if "" == text {
+		return time.Time{}, fmt.Errorf("%w: empty text", ErrUnknownFormat)
+	}
+	if t, ok := parseTimestamp(text); ok {
+		return t.In(loc), nil
+	}
+	for _, layout := range Layouts {
+		if t, err := time.ParseInLocation(layout, text, loc); nil == err {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %s", ErrUnknownFormat, text)
+}
+
+// MustParse parses the time text as Parse and panics on error, for the constants of tests and configurations
+func MustParse(text string) time.Time {
+	t, err := Parse(text)
+	if nil != err {
+		panic(err)
+	}
+	return t
+}
+
+// FromUnix the time of the unix timestamp of which the precision is detected by the magnitude,
+// the timestamps in seconds are valid until year 5138
+func FromUnix(ts int64) time.Time {
+	abs := ts
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < 1e11:
+		return time.Unix(ts, 0)
+	case abs < 1e14:
+		return time.UnixMilli(ts)
+	case abs < 1e17:
+		return time.UnixMicro(ts)
+	}
+	return time.Unix(0, ts)
+}
+
+// parseTimestamp parses the integer and fractional seconds timestamps, the 8 and 14 digits texts
+// are left to the yyyyMMdd and yyyyMMddHHmmss layouts
+func parseTimestamp(text string) (time.Time, bool) {
+	digits := strings.TrimPrefix(text, "-")
+	if len(digits) == 8 || len(digits) == 14 {
+		return time.Time{}, false
+	}
+	if ts, err := strconv.ParseInt(text, 10, 64); nil == err {
+		return FromUnix(ts), true
+	}
+	if dot := strings.IndexByte(digits, '.'); dot > 0 {
+		sec, err := strconv.ParseInt(text[:len(text)-len(digits)+dot], 10, 64)
+		if nil != err {
+			return time.Time{}, false
+		}
+		frac := digits[dot+1:]
+		if "" == frac || len(frac) > 9 {
+			return time.Time{}, false
+		}
+		nsec, err := strconv.ParseUint(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if nil != err {
+			return time.Time{}, false
+		}
+		if strings.HasPrefix(text, "-") {
+			return time.Unix(sec, -int64(nsec)), true
+		}
+		return time.Unix(sec, int64(nsec)), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,101 @@
+package timeutil
+
+import (
+	"sync"
+	"time"
+)
+
+// Stopwatch measures the elapsed durations by the monotonic clock readings of time.Now, so that the wall
+// clock adjustments do not affect the measurements. It is safe for concurrent use
+type Stopwatch struct {
+	m       sync.Mutex
+	clock   Clock
+	start   time.Time
+	lap     time.Time
+	elapsed time.Duration // accumulated before the last start
+	running bool
+	laps    []time.Duration
+}
+
+// StartStopwatch new running stopwatch of SystemClock
+func StartStopwatch() *Stopwatch {
+	return StartStopwatchWithClock(SystemClock)
+}
+
+// StartStopwatchWithClock new running stopwatch of clock such as FakeClock
+func StartStopwatchWithClock(clock Clock) *Stopwatch {
+	s := &Stopwatch{clock: clock}
+	s.Start()
+	return s
+}
+
+// Start resumes the stopwatch, nothing happens if it is running
+func (s *Stopwatch) Start() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.running {
+		return
+	}
+	s.start = s.clock.Now()
+	s.lap = s.start
+	s.running = true
+}
+
+// Stop pauses the stopwatch and returns the elapsed duration
+func (s *Stopwatch) Stop() time.Duration {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.running {
+		s.elapsed += s.clock.Now().Sub(s.start)
+		s.running = false
+	}
+	return s.elapsed
+}
+
+// Reset clears the elapsed duration and laps, the running stopwatch keeps running from now
+func (s *Stopwatch) Reset() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.elapsed = 0
+	s.laps = nil
+	s.start = s.clock.Now()
+	s.lap = s.start
+}
+
+// Elapsed the total running duration
+func (s *Stopwatch) Elapsed() time.Duration {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.running {
+		return s.elapsed + s.clock.Now().Sub(s.start)
+	}
+	return s.elapsed
+}
+
+// Lap records and returns the duration since the last lap or start, 0 if the stopwatch is stopped
+func (s *Stopwatch) Lap() time.Duration {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if !s.running {
+		return 0
+	}
+	now := s.clock.Now()
+	lap := now.Sub(s.lap)
+	s.lap = now
+	s.laps = append(s.laps, lap)
+	return lap
+}
+
+// Laps the recorded lap durations
+func (s *Stopwatch) Laps() []time.Duration {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]time.Duration{}, s.laps...)
+}
+
+// Running whether the stopwatch is running
+func (s *Stopwatch) Running() bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.running
+}
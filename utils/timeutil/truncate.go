@@ -0,0 +1,98 @@
+package timeutil
+
+import "time"
+
+// StartOfHour the beginning of the hour of t in its location
+func StartOfHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// StartOfDay the midnight beginning the day of t in its location
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// EndOfDay the last nanosecond of the day of t in its location
+func EndOfDay(t time.Time) time.Time {
+	return StartOfDay(t).AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// StartOfWeek the beginning of the week of t starting on weekStart such as time.Monday
+func StartOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	offset := (int(t.Weekday()) - int(weekStart) + 7) % 7
+	return StartOfDay(t).AddDate(0, 0, -offset)
+}
+
+// EndOfWeek the last nanosecond of the week of t starting on weekStart
+func EndOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	return StartOfWeek(t, weekStart).AddDate(0, 0, 7).Add(-time.Nanosecond)
+}
+
+// StartOfMonth the beginning of the first day of the month of t
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// EndOfMonth the last nanosecond of the month of t
+func EndOfMonth(t time.Time) time.Time {
+	return StartOfMonth(t).AddDate(0, 1, 0).Add(-time.Nanosecond)
+}
+
+// StartOfYear the beginning of the first day of the year of t
+func StartOfYear(t time.Time) time.Time {
+	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Truncate rounds t down to a multiple of d since the midnight of its location, unlike time.Truncate which
+// counts from the zero time in UTC, so that the buckets such as 6h are aligned to the local wall clock.
+// The d of a day or longer truncates to StartOfDay
+func Truncate(t time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return t
+	}
+	start := StartOfDay(t)
+	if d >= 24*time.Hour {
+		return start
+	}
+	return start.Add(t.Sub(start) / d * d)
+}
+
+// Round rounds t to the nearest multiple of d since the midnight of its location as Truncate,
+// the halfway values are rounded up
+func Round(t time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return t
+	}
+	truncated := Truncate(t, d)
+	if d >= 24*time.Hour {
+		if t.Sub(truncated) >= 12*time.Hour {
+			return truncated.AddDate(0, 0, 1)
+		}
+		return truncated
+	}
+	if t.Sub(truncated)*2 >= d {
+		return truncated.Add(d)
+	}
+	return truncated
+}
+
+// Ceil rounds t up to a multiple of d since the midnight of its location as Truncate
+func Ceil(t time.Time, d time.Duration) time.Time {
+	truncated := Truncate(t, d)
+	if truncated.Equal(t) || d <= 0 {
+		return truncated
+	}
+	if d >= 24*time.Hour {
+		return truncated.AddDate(0, 0, 1)
+	}
+	return truncated.Add(d)
+}
+
+// DaysBetween the count of calendar days from the day of from to the day of to in the location of from,
+// negative if to is before from
+func DaysBetween(from time.Time, to time.Time) int {
+	to = to.In(from.Location())
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start) / (24 * time.Hour))
+}
@@ -19,6 +19,12 @@ const (
 	ValidateTypeRequired = "required"
 	ValidateTypeRegex    = "regex"
 	ValidateTypeObjectID = "objectId"
+	ValidateTypeMin      = "min"
+	ValidateTypeMax      = "max"
+	ValidateTypeOneOf    = "oneof"
+	ValidateTypeURL      = "url"
+	ValidateTypeHostPort = "hostport"
+	ValidateTypeFile     = "file"
 )
 
 // Validate validator
@@ -106,6 +112,18 @@ func ValidateFieldValue(f reflect.Value, validateInfo string, label string) erro
 		case ValidateTypeObjectID:
 			err = validates.ValidateObjectID(f, label)
 			break
+		case ValidateTypeMin:
+			err = validates.ValidateMin(f, ele.Content, label)
+		case ValidateTypeMax:
+			err = validates.ValidateMax(f, ele.Content, label)
+		case ValidateTypeOneOf:
+			err = validates.ValidateOneOf(f, ele.Content, label)
+		case ValidateTypeURL:
+			err = validates.ValidateURL(f, label)
+		case ValidateTypeHostPort:
+			err = validates.ValidateHostPort(f, label)
+		case ValidateTypeFile:
+			err = validates.ValidateFile(f, label)
 		}
 		if err != nil {
 			msgs = append(msgs, err.Error())
@@ -131,6 +149,10 @@ func AnalyzeValidateElements(validateInfo string) []ValidateElement {
 		case "regex":
 			ele.Content = slices[1]
 			break
+		case ValidateTypeMin, ValidateTypeMax, ValidateTypeOneOf:
+			if len(slices) > 1 {
+				ele.Content = strings.TrimSpace(slices[1])
+			}
 		}
 		elements = append(elements, ele)
 	}
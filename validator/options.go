@@ -0,0 +1,99 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/libpub/golib/definations"
+)
+
+const maxValidateDepth = 32
+
+// ValidateOptions validates v by the validate tags of all the nested fields and the Validate methods of
+// definations.Validatable values such as definations.TLSOptions, the nested structs, pointers, slices and map values
+// are walked recursively. Unlike Validate the default tags are not applied, and all the errors are returned as
+// definations.ValidationErrors of the parent field paths. The Validate methods should not call ValidateOptions on itself
+func ValidateOptions(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return nil
+	}
+	if reflect.Ptr != value.Kind() {
+		// the addressable copy for Validate methods of pointer receivers
+		tmp := reflect.New(value.Type()).Elem()
+		tmp.Set(value)
+		value = tmp
+	}
+	errs := definations.ValidationErrors{}
+	validateOptionsValue(value, "", &errs, 0)
+	return errs.Err()
+}
+
+func validateOptionsValue(value reflect.Value, path string, errs *definations.ValidationErrors, depth int) {
+	if depth > maxValidateDepth {
+		return
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			validateOptionsValue(value.Elem(), path, errs, depth+1)
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateOptionsValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs, depth+1)
+		}
+		return
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			item := reflect.New(value.Type().Elem()).Elem()
+			item.Set(value.MapIndex(key))
+			validateOptionsValue(item, joinOptionsPath(path, fmt.Sprint(key.Interface())), errs, depth+1)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	if value.CanAddr() && value.Addr().CanInterface() {
+		if validatable, ok := value.Addr().Interface().(definations.Validatable); ok {
+			errs.Add(path, validatable.Validate())
+		}
+	}
+	t := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		ft := t.Field(i)
+		if "" != ft.PkgPath {
+			continue
+		}
+		f := value.Field(i)
+		name := optionsFieldName(ft)
+		if validateInfo := ft.Tag.Get("validate"); "" != validateInfo {
+			errs.Add(path, ValidateFieldValue(f, validateInfo, name))
+		}
+		fieldPath := path
+		if !ft.Anonymous {
+			fieldPath = joinOptionsPath(path, name)
+		}
+		validateOptionsValue(f, fieldPath, errs, depth+1)
+	}
+}
+
+// optionsFieldName the field name in path by yaml tag, json tag or field name
+func optionsFieldName(ft reflect.StructField) string {
+	for _, tagName := range []string{"yaml", "json"} {
+		if tag := strings.Split(ft.Tag.Get(tagName), ",")[0]; "" != tag && "-" != tag {
+			return tag
+		}
+	}
+	return ft.Name
+}
+
+func joinOptionsPath(path string, name string) string {
+	if "" == path {
+		return name
+	}
+	return path + "." + name
+}
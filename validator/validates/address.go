@@ -0,0 +1,48 @@
+package validates
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+)
+
+// ValidateURL validator, the value should be an absolute url with host, the empty value is skipped
+func ValidateURL(v reflect.Value, label string) error {
+	if v.Type().Kind() != reflect.String || v.String() == "" {
+		return nil
+	}
+	u, err := url.Parse(v.String())
+	if nil != err || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s不是有效的url地址", label)
+	}
+	return nil
+}
+
+// ValidateHostPort validator, the value should be an address of host:port, the empty value is skipped
+func ValidateHostPort(v reflect.Value, label string) error {
+	if v.Type().Kind() != reflect.String || v.String() == "" {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(v.String()); nil != err || port == "" {
+		return fmt.Errorf("%s的值%s不是有效的host:port地址", label, v.String())
+	}
+	return nil
+}
+
+// ValidateFile validator, the value should be the path of a readable file, the empty value is skipped
+func ValidateFile(v reflect.Value, label string) error {
+	if v.Type().Kind() != reflect.String || v.String() == "" {
+		return nil
+	}
+	f, err := os.Open(v.String())
+	if nil != err {
+		return fmt.Errorf("%s文件%s不可读:%v", label, v.String(), err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); nil != err || info.IsDir() {
+		return fmt.Errorf("%s文件%s不可读", label, v.String())
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package validates
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateMin validator, the number value or the length of string, array and map should not be less than limit
+func ValidateMin(v reflect.Value, limitText string, label string) error {
+	return validateLimit(v, limitText, label, true)
+}
+
+// ValidateMax validator, the number value or the length of string, array and map should not be greater than limit
+func ValidateMax(v reflect.Value, limitText string, label string) error {
+	return validateLimit(v, limitText, label, false)
+}
+
+// ValidateOneOf validator, the value text should be one of the options separated by |, the empty value is skipped
+func ValidateOneOf(v reflect.Value, options string, label string) error {
+	if !v.IsValid() || v.IsZero() {
+		return nil
+	}
+	text := fmt.Sprint(v.Interface())
+	for _, option := range strings.Split(options, "|") {
+		if text == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s的值%s必须是%s之一", label, text, strings.ReplaceAll(options, "|", ", "))
+}
+
+func validateLimit(v reflect.Value, limitText string, label string, isMin bool) error {
+	limit, err := strconv.ParseFloat(limitText, 64)
+	if nil != err {
+		return fmt.Errorf("%s的校验规则参数%s无效", label, limitText)
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	var size float64
+	unit := ""
+	switch v.Type().Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(v.String()))
+		unit = "长度"
+	case reflect.Array, reflect.Map, reflect.Slice:
+		size = float64(v.Len())
+		unit = "长度"
+	default:
+		return nil
+	}
+	if isMin && size < limit {
+		return fmt.Errorf("%s%s不能小于%s", label, unit, limitText)
+	}
+	if !isMin && size > limit {
+		return fmt.Errorf("%s%s不能大于%s", label, unit, limitText)
+	}
+	return nil
+}